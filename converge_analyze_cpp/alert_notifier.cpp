@@ -0,0 +1,74 @@
+#include "alert_notifier.h"
+#include "http_client.h"
+#include <iostream>
+
+AlertNotifier::AlertNotifier(const std::string& webhook_url)
+    : webhook_url_(webhook_url) {
+}
+
+AlertNotifier::~AlertNotifier() {
+    stop();
+}
+
+void AlertNotifier::start() {
+    if (running_.load()) {
+        return;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&AlertNotifier::worker_loop, this);
+}
+
+void AlertNotifier::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    queue_cv_.notify_all();
+
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+}
+
+void AlertNotifier::notify(const std::string& payload) {
+    std::unique_lock<std::mutex> lock(queue_mutex_);
+
+    // 告警端点不可达时避免无限堆积
+    if (pending_.size() >= MAX_PENDING_ALERTS) {
+        pending_.pop();
+        failed_count_.fetch_add(1);
+    }
+
+    pending_.push(payload);
+    lock.unlock();
+
+    queue_cv_.notify_one();
+}
+
+void AlertNotifier::worker_loop() {
+    std::unique_lock<std::mutex> lock(queue_mutex_);
+
+    while (running_.load() || !pending_.empty()) {
+        queue_cv_.wait(lock, [this] {
+            return !pending_.empty() || !running_.load();
+        });
+
+        while (!pending_.empty()) {
+            std::string payload = std::move(pending_.front());
+            pending_.pop();
+            lock.unlock();
+
+            auto response = HttpClient::post(webhook_url_, payload);
+            if (response.ok()) {
+                sent_count_.fetch_add(1);
+            } else {
+                failed_count_.fetch_add(1);
+                std::cerr << "⚠️  Webhook告警发送失败: " << response.error << "\n";
+            }
+
+            lock.lock();
+        }
+    }
+}
@@ -0,0 +1,45 @@
+#pragma once
+
+#include <string>
+#include <queue>
+#include <thread>
+#include <mutex>
+#include <condition_variable>
+#include <atomic>
+
+// Webhook告警发送器：独立线程投递，避免HTTP请求阻塞收敛检查
+class AlertNotifier {
+private:
+    std::string webhook_url_;
+
+    std::queue<std::string> pending_;
+    std::mutex queue_mutex_;
+    std::condition_variable queue_cv_;
+
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+
+    std::atomic<int64_t> sent_count_{0};
+    std::atomic<int64_t> failed_count_{0};
+
+    static constexpr size_t MAX_PENDING_ALERTS = 100;
+
+    void worker_loop();
+
+public:
+    explicit AlertNotifier(const std::string& webhook_url);
+    ~AlertNotifier();
+
+    AlertNotifier(const AlertNotifier&) = delete;
+    AlertNotifier& operator=(const AlertNotifier&) = delete;
+
+    void start();
+    // 停止前会尽量发送完队列中的告警
+    void stop();
+
+    // 入队一条JSON告警负载
+    void notify(const std::string& payload);
+
+    int64_t sent_count() const { return sent_count_.load(); }
+    int64_t failed_count() const { return failed_count_.load(); }
+};
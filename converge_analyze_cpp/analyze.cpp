@@ -0,0 +1,650 @@
+#include "analyze.h"
+#include "log_reader.h"
+#include <algorithm>
+#include <cstdlib>
+#include <getopt.h>
+#include <iomanip>
+#include <iostream>
+#include <optional>
+#include <set>
+#include <tuple>
+
+bool SessionReplay::load(const std::string& path, std::string& error) {
+    std::vector<JsonObject> records;
+    int malformed = 0;
+    if (!LogReader::read_file(path, records, malformed, error)) {
+        return false;
+    }
+    original_ = ReportData();
+    original_.source_path = path;
+    original_.malformed_lines = malformed;
+    ConvergenceReport::build(records, original_);
+
+    events_.clear();
+    run_end_ms_.clear();
+    // 与ConvergenceReport::build相同，按monitoring_started区分追加到同一文件的多次运行
+    int run_index = 0;
+    std::map<std::pair<int, std::string>, int64_t> last_seen;
+    for (const auto& record : records) {
+        std::string event_type = LogReader::get_string(record, "event_type");
+        std::string router = LogReader::get_string(record, "router_name");
+        if (event_type == "monitoring_started") {
+            run_index++;
+        }
+        std::string timestamp = LogReader::get_timestamp(record, "timestamp");
+        int64_t time_ms = LogReader::parse_timestamp_ms(timestamp);
+        if (time_ms < 0) {
+            continue;
+        }
+        auto key = std::make_pair(run_index, router);
+        last_seen[key] = std::max(last_seen[key], time_ms);
+        if (event_type == "monitoring_completed") {
+            run_end_ms_[key] = time_ms;
+            continue;
+        }
+        if (event_type != "session_started" && event_type != "route_event") {
+            continue;
+        }
+
+        ReplayEvent event;
+        event.run_index = run_index;
+        event.router_name = router;
+        event.time_ms = time_ms;
+        event.timestamp = timestamp;
+        event.original_session = static_cast<int>(LogReader::get_int(record, "session_id"));
+        if (event_type == "session_started") {
+            event.trigger = true;
+            event.source = LogReader::get_string(record, "trigger_source");
+            event.type = LogReader::get_string(record, "trigger_event_type");
+            event.campaign_step = LogReader::get_string(record, "campaign_step");
+            event.info = LogReader::parse_string_map(LogReader::get_string(record, "trigger_info"));
+        } else {
+            event.source = "route";
+            event.type = LogReader::get_string(record, "route_event_type");
+            event.coalesced = static_cast<int>(std::max<int64_t>(1, LogReader::get_int(record, "coalesced_count", 1)));
+            event.info = LogReader::parse_string_map(LogReader::get_string(record, "route_info"));
+        }
+        events_.push_back(std::move(event));
+    }
+    for (const auto& entry : last_seen) {
+        run_end_ms_.emplace(entry.first, entry.second);
+    }
+
+    // 稳定排序，同一时刻的触发仍排在其会话事件之前
+    std::stable_sort(events_.begin(), events_.end(), [](const ReplayEvent& a, const ReplayEvent& b) {
+        if (a.run_index != b.run_index) {
+            return a.run_index < b.run_index;
+        }
+        if (a.router_name != b.router_name) {
+            return a.router_name < b.router_name;
+        }
+        return a.time_ms < b.time_ms;
+    });
+    return true;
+}
+
+ReplayResult SessionReplay::replay(const ReplayOptions& options) const {
+    ReplayResult result;
+    result.data.source_path = original_.source_path;
+    result.data.routers = original_.routers;
+    result.data.convergence_threshold_ms = options.threshold_ms;
+    result.data.total_listen_duration_ms = original_.total_listen_duration_ms;
+    result.data.malformed_lines = original_.malformed_lines;
+    result.data.clock_offsets = original_.clock_offsets;
+
+    struct OpenSession {
+        ReportSession session;
+        int64_t last_ms = 0;        // 最后一条事件的时刻，没有事件时为触发时刻
+        bool has_events = false;
+        int64_t threshold_ms = 0;
+        std::vector<int> origins;
+    };
+    std::optional<OpenSession> open;
+    std::map<std::tuple<int, std::string, int>, std::set<size_t>> covered;  // 原会话 -> 覆盖它的新会话
+
+    // 以end_ms为当前时刻结束会话：静默期已满即收敛，否则与实时监控一样记为运行结束时的强制结束，没有收敛时间
+    auto finish = [&](int64_t end_ms) {
+        ReportSession& session = open->session;
+        session.completed = true;
+        if (end_ms - open->last_ms >= open->threshold_ms) {
+            session.convergence_time_ms = open->has_events ? open->last_ms - session.start_time_ms : 0;
+            session.duration_ms = open->last_ms + open->threshold_ms - session.start_time_ms;
+        } else {
+            session.forced = true;
+            session.duration_ms = end_ms - session.start_time_ms;
+        }
+        std::string origins;
+        for (int id : open->origins) {
+            origins += (origins.empty() ? "" : ",") + std::to_string(id);
+            covered[std::make_tuple(session.run_index, session.router_name, id)].insert(result.data.sessions.size());
+        }
+        if (open->origins.size() > 1) {
+            result.merged_sessions++;
+        }
+        result.origins.push_back(origins);
+        result.data.sessions.push_back(std::move(session));
+        open.reset();
+    };
+
+    int next_session_id = 0;
+    for (size_t i = 0; i < events_.size(); ++i) {
+        const ReplayEvent& event = events_[i];
+        bool group_start = i == 0 || event.run_index != events_[i - 1].run_index ||
+                           event.router_name != events_[i - 1].router_name;
+        if (group_start) {
+            next_session_id = 0;
+        }
+
+        if (!options.filter.empty() && !options.filter.matches(event.info, event.source == "route")) {
+            result.filtered_events += event.coalesced;
+        } else {
+            result.replayed_events += event.coalesced;
+            if (open && event.time_ms - open->last_ms >= open->threshold_ms) {
+                finish(event.time_ms);
+            }
+            if (!open) {
+                open.emplace();
+                ReportSession& session = open->session;
+                session.run_index = event.run_index;
+                session.router_name = event.router_name;
+                session.session_id = ++next_session_id;
+                session.start_timestamp = event.timestamp;
+                session.start_time_ms = event.time_ms;
+                session.trigger_source = event.source;
+                session.trigger_event_type = event.type;
+                session.trigger_info = event.info;
+                session.campaign_step = event.campaign_step;
+                open->last_ms = event.time_ms;
+                open->threshold_ms = options.threshold_ms;
+                if (!options.overrides.empty()) {
+                    auto iface_it = event.info.find("interface");
+                    std::string rule;
+                    open->threshold_ms = options.overrides.match(
+                        iface_it != event.info.end() ? iface_it->second : std::string(), event.source, event.type,
+                        rule).value_or(options.threshold_ms);
+                }
+            } else if (!event.trigger) {
+                // 会话内的路由事件；进行中的会话收到的新触发与实时状态机一样被忽略，不计入事件，也不重置静默期
+                ReportEvent replayed;
+                replayed.offset_ms = event.time_ms - open->session.start_time_ms;
+                replayed.type = event.type + (event.coalesced > 1 ? " x" + std::to_string(event.coalesced) : "");
+                replayed.info = event.info;
+                ReportSession& session = open->session;
+                size_t second = static_cast<size_t>(replayed.offset_ms / 1000);
+                if (session.churn_per_second.size() <= second) {
+                    session.churn_per_second.resize(second + 1, 0);
+                }
+                session.churn_per_second[second] += event.coalesced;
+                session.route_events += event.coalesced;
+                session.events.push_back(std::move(replayed));
+                open->last_ms = event.time_ms;
+                open->has_events = true;
+            }
+            if (open->origins.empty() || open->origins.back() != event.original_session) {
+                open->origins.push_back(event.original_session);
+            }
+        }
+
+        bool group_end = i + 1 == events_.size() || events_[i + 1].run_index != event.run_index ||
+                         events_[i + 1].router_name != event.router_name;
+        if (group_end && open) {
+            auto end_it = run_end_ms_.find(std::make_pair(event.run_index, event.router_name));
+            finish(end_it != run_end_ms_.end() ? std::max(end_it->second, open->last_ms) : open->last_ms);
+        }
+    }
+
+    for (const auto& entry : covered) {
+        if (entry.second.size() > 1) {
+            result.split_sessions++;
+        }
+    }
+    return result;
+}
+
+bool parse_sweep(const std::string& spec, std::vector<int64_t>& thresholds, std::string& error) {
+    thresholds.clear();
+    try {
+        if (spec.find(':') != std::string::npos) {
+            size_t first = spec.find(':');
+            size_t second = spec.find(':', first + 1);
+            if (second == std::string::npos) {
+                error = "格式应为 FROM:TO:STEP";
+                return false;
+            }
+            int64_t from = std::stoll(spec.substr(0, first));
+            int64_t to = std::stoll(spec.substr(first + 1, second - first - 1));
+            int64_t step = std::stoll(spec.substr(second + 1));
+            if (from <= 0 || to < from || step <= 0) {
+                error = "需要 0 < FROM <= TO 且 STEP > 0";
+                return false;
+            }
+            if ((to - from) / step + 1 > static_cast<int64_t>(MAX_SWEEP_POINTS)) {
+                error = "阈值点过多(最多" + std::to_string(MAX_SWEEP_POINTS) + "个)";
+                return false;
+            }
+            for (int64_t value = from; value <= to; value += step) {
+                thresholds.push_back(value);
+            }
+        } else {
+            size_t start = 0;
+            while (start <= spec.size()) {
+                size_t comma = spec.find(',', start);
+                std::string item = spec.substr(start, comma == std::string::npos ? std::string::npos : comma - start);
+                int64_t value = std::stoll(item);
+                if (value <= 0) {
+                    error = "阈值必须为正数: " + item;
+                    return false;
+                }
+                thresholds.push_back(value);
+                if (comma == std::string::npos) {
+                    break;
+                }
+                start = comma + 1;
+            }
+        }
+    } catch (const std::exception&) {
+        error = "无效的阈值: " + spec;
+        return false;
+    }
+    std::sort(thresholds.begin(), thresholds.end());
+    thresholds.erase(std::unique(thresholds.begin(), thresholds.end()), thresholds.end());
+    if (thresholds.size() < 2 || thresholds.size() > MAX_SWEEP_POINTS) {
+        error = "至少需要2个、最多" + std::to_string(MAX_SWEEP_POINTS) + "个不同的阈值";
+        return false;
+    }
+    return true;
+}
+
+SweepPlateau find_sweep_plateau(const std::vector<SweepPoint>& points, int64_t original_threshold_ms) {
+    SweepPlateau plateau;
+    if (points.empty()) {
+        return plateau;
+    }
+    auto contains_original = [&](size_t begin, size_t end) {
+        return original_threshold_ms >= points[begin].threshold_ms && original_threshold_ms <= points[end].threshold_ms;
+    };
+    for (size_t begin = 0; begin < points.size();) {
+        size_t end = begin;
+        while (end + 1 < points.size() && points[end + 1].sessions == points[begin].sessions) {
+            end++;
+        }
+        size_t length = end - begin;
+        size_t best_length = plateau.end - plateau.begin;
+        if (length > best_length || (length == best_length && contains_original(begin, end) &&
+                                     !contains_original(plateau.begin, plateau.end))) {
+            plateau.begin = begin;
+            plateau.end = end;
+        }
+        begin = end + 1;
+    }
+    for (size_t i = plateau.begin; i <= plateau.end; ++i) {
+        if (points[i].stats.count == 0) {
+            continue;
+        }
+        double median = points[i].stats.median;
+        plateau.median_min_ms = plateau.has_stats ? std::min(plateau.median_min_ms, median) : median;
+        plateau.median_max_ms = plateau.has_stats ? std::max(plateau.median_max_ms, median) : median;
+        plateau.has_stats = true;
+    }
+    plateau.found = plateau.end > plateau.begin;
+    plateau.contains_original = plateau.found && original_threshold_ms > 0 &&
+                                contains_original(plateau.begin, plateau.end);
+    return plateau;
+}
+
+namespace {
+
+void print_analyze_usage(const char* program_name) {
+    std::cout << "用法: " << program_name << " analyze --input LOG [--threshold MS | --sweep FROM:TO:STEP] [过滤条件] [--format markdown|ndjson]\n\n";
+    std::cout << "在已有日志上按新的收敛阈值/过滤条件重新划分会话，对比原结果(what-if)，无需重做实验\n\n";
+    std::cout << "选项:\n";
+    std::cout << "  -i, --input PATH            输入日志文件(NDJSON)\n";
+    std::cout << "  -t, --threshold MS          静默期阈值(默认沿用日志中的阈值)\n";
+    std::cout << "      --threshold-override RULES 按接口或触发类型覆盖阈值，格式同监控选项(可重复)\n";
+    std::cout << "      --filter-interface NAME 只重放该接口上的事件(可重复)\n";
+    std::cout << "      --filter-prefix CIDR    只重放目的地址落在该前缀内的路由事件(可重复)\n";
+    std::cout << "      --sweep FROM:TO:STEP    依次按一组阈值重放，报告结果对阈值的敏感性(也可用逗号分隔的列表)\n";
+    std::cout << "  -f, --format FORMAT         输出格式: markdown(默认)、ndjson(每行一个会话或阈值点，最后一行为汇总)\n";
+    std::cout << "  -h, --help                  显示此帮助信息\n\n";
+    std::cout << "只能重放日志中记录下来的事件：原运行中被过滤或丢弃的事件无法恢复\n";
+}
+
+enum AnalyzeOption {
+    OPT_THRESHOLD_OVERRIDE = 1000,
+    OPT_FILTER_INTERFACE,
+    OPT_FILTER_PREFIX,
+    OPT_SWEEP,
+};
+
+void print_stats_row(const std::string& name, const DistributionStats& original, const DistributionStats& replayed,
+                     double DistributionStats::*field) {
+    std::cout << "| " << name << " | " << (original.count > 0 ? original.*field : 0.0) << " | "
+              << (replayed.count > 0 ? replayed.*field : 0.0) << " |\n";
+}
+
+int64_t count_timed_out(const std::vector<ReportSession>& sessions) {
+    return std::count_if(sessions.begin(), sessions.end(), [](const ReportSession& s) {
+        return s.completed && s.timed_out;
+    });
+}
+
+int64_t count_forced(const std::vector<ReportSession>& sessions) {
+    return std::count_if(sessions.begin(), sessions.end(), [](const ReportSession& s) {
+        return s.completed && s.forced;
+    });
+}
+
+int64_t count_completed(const std::vector<ReportSession>& sessions) {
+    return std::count_if(sessions.begin(), sessions.end(), [](const ReportSession& s) { return s.completed; });
+}
+
+// 逐个阈值重放并输出敏感性报告
+int run_sweep(const SessionReplay& replay, ReplayOptions options, const std::vector<int64_t>& thresholds,
+              const std::string& format) {
+    const ReportData& original = replay.original();
+    std::vector<SweepPoint> points;
+    for (int64_t threshold : thresholds) {
+        options.threshold_ms = threshold;
+        ReplayResult result = replay.replay(options);
+        SweepPoint point;
+        point.threshold_ms = threshold;
+        point.sessions = static_cast<int64_t>(result.data.sessions.size());
+        point.timed_out = count_timed_out(result.data.sessions);
+        point.stats = ConvergenceReport::compute_stats(ConvergenceReport::convergence_times(result.data.sessions));
+        if (!points.empty() && points.back().stats.count > 0 && points.back().stats.median > 0 &&
+            point.stats.count > 0) {
+            point.median_change_pct = (point.stats.median - points.back().stats.median) / points.back().stats.median * 100.0;
+        }
+        points.push_back(point);
+    }
+
+    SweepPlateau plateau = find_sweep_plateau(points, original.convergence_threshold_ms);
+
+    if (format == "ndjson") {
+        for (const auto& point : points) {
+            JsonObject record;
+            record["event_type"] = "analyze_sweep_point";
+            record["schema_version"] = Logger::SCHEMA_VERSION;
+            record["threshold_ms"] = point.threshold_ms;
+            record["sessions"] = point.sessions;
+            record["timed_out"] = point.timed_out;
+            if (point.stats.count > 0) {
+                record["mean_ms"] = point.stats.mean;
+                record["median_ms"] = point.stats.median;
+                record["p95_ms"] = point.stats.p95;
+                record["max_ms"] = point.stats.max;
+            }
+            if (point.median_change_pct) {
+                record["median_change_pct"] = *point.median_change_pct;
+            }
+            std::cout << Logger::json_to_string(record) << "\n";
+        }
+        JsonObject summary;
+        summary["event_type"] = "analyze_sweep_summary";
+        summary["schema_version"] = Logger::SCHEMA_VERSION;
+        summary["original_threshold_ms"] = original.convergence_threshold_ms;
+        summary["points"] = static_cast<int64_t>(points.size());
+        summary["original_in_plateau"] = plateau.contains_original;
+        if (plateau.found) {
+            summary["plateau_from_ms"] = points[plateau.begin].threshold_ms;
+            summary["plateau_to_ms"] = points[plateau.end].threshold_ms;
+            summary["plateau_sessions"] = points[plateau.begin].sessions;
+            if (plateau.has_stats) {
+                summary["plateau_median_min_ms"] = plateau.median_min_ms;
+                summary["plateau_median_max_ms"] = plateau.median_max_ms;
+            }
+        }
+        std::cout << Logger::json_to_string(summary) << "\n";
+        return 0;
+    }
+
+    std::cout << "## Threshold sensitivity\n\n";
+    std::cout << "- Log: " << original.source_path << "\n";
+    std::cout << "- Thresholds: " << points.front().threshold_ms << " .. " << points.back().threshold_ms << " ms ("
+              << points.size() << " points), recorded threshold: " << original.convergence_threshold_ms << " ms\n";
+    if (!options.overrides.empty()) {
+        std::cout << "- Threshold overrides: " << options.overrides.text() << "\n";
+    }
+    if (!options.filter.empty()) {
+        std::cout << "- Filter: interfaces="
+                  << (options.filter.interfaces.empty() ? "all" : options.filter.interfaces_text())
+                  << ", prefixes=" << (options.filter.prefixes.empty() ? "all" : options.filter.prefixes_text())
+                  << "\n";
+    }
+    std::cout << "\n| Threshold (ms) | Sessions | Timed out | Mean (ms) | Median (ms) | P95 (ms) | Max (ms) | Median change |\n";
+    std::cout << "|---:|---:|---:|---:|---:|---:|---:|---:|\n";
+    std::cout << std::fixed << std::setprecision(1);
+    for (size_t i = 0; i < points.size(); ++i) {
+        const SweepPoint& point = points[i];
+        bool in_plateau = plateau.found && i >= plateau.begin && i <= plateau.end;
+        std::cout << "| " << point.threshold_ms << (in_plateau ? " *" : "") << " | "
+                  << point.sessions << " | " << point.timed_out << " | ";
+        if (point.stats.count > 0) {
+            std::cout << point.stats.mean << " | " << point.stats.median << " | " << point.stats.p95 << " | "
+                      << point.stats.max << " | ";
+        } else {
+            std::cout << "- | - | - | - | ";
+        }
+        if (point.median_change_pct) {
+            std::cout << std::showpos << *point.median_change_pct << std::noshowpos << "%";
+        } else {
+            std::cout << "-";
+        }
+        std::cout << " |\n";
+    }
+    if (!plateau.found) {
+        std::cout << "\nNo stable plateau: the session count changes at every threshold, try a finer step.\n";
+        std::cout.unsetf(std::ios::floatfield);
+        return 0;
+    }
+    std::cout << "\nStable plateau (*): " << points[plateau.begin].threshold_ms << " .. "
+              << points[plateau.end].threshold_ms
+              << " ms, " << points[plateau.begin].sessions << " session(s)";
+    if (plateau.has_stats) {
+        std::cout << ", median " << plateau.median_min_ms << " .. " << plateau.median_max_ms << " ms";
+    }
+    std::cout << "\n";
+    std::cout.unsetf(std::ios::floatfield);
+    if (original.convergence_threshold_ms > 0) {
+        std::cout << "Recorded threshold " << original.convergence_threshold_ms << " ms is "
+                  << (plateau.contains_original ? "inside" : "outside") << " the plateau.\n";
+    }
+    return 0;
+}
+
+} // namespace
+
+int analyze_main(int argc, char* argv[]) {
+    std::string input_path;
+    std::string format = "markdown";
+    std::optional<int64_t> threshold;
+    std::vector<int64_t> sweep;
+    ReplayOptions options;
+
+    static struct option long_options[] = {
+        {"input", required_argument, 0, 'i'},
+        {"threshold", required_argument, 0, 't'},
+        {"threshold-override", required_argument, 0, OPT_THRESHOLD_OVERRIDE},
+        {"filter-interface", required_argument, 0, OPT_FILTER_INTERFACE},
+        {"filter-prefix", required_argument, 0, OPT_FILTER_PREFIX},
+        {"sweep", required_argument, 0, OPT_SWEEP},
+        {"format", required_argument, 0, 'f'},
+        {"help", no_argument, 0, 'h'},
+        {0, 0, 0, 0}
+    };
+
+    optind = 1;
+    int c;
+    while ((c = getopt_long(argc, argv, "i:t:f:h", long_options, nullptr)) != -1) {
+        switch (c) {
+            case 'i': input_path = optarg; break;
+            case 't': {
+                char* end = nullptr;
+                int64_t value = strtoll(optarg, &end, 10);
+                if (end == optarg || *end != '\0' || value <= 0) {
+                    std::cerr << "❌ 错误: 无效的阈值 " << optarg << "\n";
+                    return 1;
+                }
+                threshold = value;
+                break;
+            }
+            case 'f': format = optarg; break;
+            case OPT_THRESHOLD_OVERRIDE: {
+                std::string error;
+                if (!options.overrides.parse(optarg, error)) {
+                    std::cerr << "❌ 错误: --threshold-override " << error << "\n";
+                    return 1;
+                }
+                break;
+            }
+            case OPT_FILTER_INTERFACE: options.filter.interfaces.push_back(optarg); break;
+            case OPT_FILTER_PREFIX: {
+                EventFilter::Prefix prefix;
+                if (!EventFilter::parse_prefix(optarg, prefix)) {
+                    std::cerr << "❌ 错误: 无效的前缀 " << optarg << "\n";
+                    return 1;
+                }
+                options.filter.prefixes.push_back(prefix);
+                break;
+            }
+            case OPT_SWEEP: {
+                std::string error;
+                if (!parse_sweep(optarg, sweep, error)) {
+                    std::cerr << "❌ 错误: --sweep " << error << "\n";
+                    return 1;
+                }
+                break;
+            }
+            case 'h': print_analyze_usage(argv[0]); return 0;
+            default:  print_analyze_usage(argv[0]); return 1;
+        }
+    }
+
+    if (input_path.empty()) {
+        std::cerr << "❌ 错误: 必须指定 --input\n";
+        return 1;
+    }
+    if (threshold && !sweep.empty()) {
+        std::cerr << "❌ 错误: --threshold 与 --sweep 不能同时使用\n";
+        return 1;
+    }
+    if (format != "ndjson" && format != "markdown" && format != "md") {
+        std::cerr << "❌ 错误: 不支持的输出格式 " << format << "\n";
+        return 1;
+    }
+
+    SessionReplay replay;
+    std::string error;
+    if (!replay.load(input_path, error)) {
+        std::cerr << "❌ " << error << "\n";
+        return 1;
+    }
+    if (!sweep.empty()) {
+        return run_sweep(replay, options, sweep, format);
+    }
+
+    const ReportData& original = replay.original();
+    options.threshold_ms = threshold.value_or(original.convergence_threshold_ms);
+    if (options.threshold_ms <= 0) {
+        std::cerr << "❌ 错误: 日志中没有记录收敛阈值，请指定 --threshold\n";
+        return 1;
+    }
+
+    ReplayResult result = replay.replay(options);
+    auto original_stats = ConvergenceReport::compute_stats(ConvergenceReport::convergence_times(original.sessions));
+    auto replayed_stats = ConvergenceReport::compute_stats(ConvergenceReport::convergence_times(result.data.sessions));
+
+    if (format == "ndjson") {
+        for (size_t i = 0; i < result.data.sessions.size(); ++i) {
+            JsonObject record = ConvergenceReport::session_to_json(result.data.sessions[i]);
+            record["event_type"] = "analyze_session";
+            record["schema_version"] = Logger::SCHEMA_VERSION;
+            record["threshold_ms"] = options.threshold_ms;
+            record["original_sessions"] = result.origins[i];
+            std::cout << Logger::json_to_string(record) << "\n";
+        }
+        JsonObject summary;
+        summary["event_type"] = "analyze_summary";
+        summary["schema_version"] = Logger::SCHEMA_VERSION;
+        summary["original_threshold_ms"] = original.convergence_threshold_ms;
+        summary["threshold_ms"] = options.threshold_ms;
+        if (!options.overrides.empty()) {
+            summary["threshold_overrides"] = options.overrides.text();
+        }
+        if (!options.filter.interfaces.empty()) {
+            summary["filter_interfaces"] = options.filter.interfaces_text();
+        }
+        if (!options.filter.prefixes.empty()) {
+            summary["filter_prefixes"] = options.filter.prefixes_text();
+        }
+        summary["original_sessions"] = count_completed(original.sessions);
+        summary["sessions"] = static_cast<int64_t>(result.data.sessions.size());
+        summary["original_timed_out"] = count_timed_out(original.sessions);
+        summary["timed_out"] = count_timed_out(result.data.sessions);
+        summary["original_forced"] = count_forced(original.sessions);
+        summary["forced"] = count_forced(result.data.sessions);
+        summary["merged_sessions"] = static_cast<int64_t>(result.merged_sessions);
+        summary["split_sessions"] = static_cast<int64_t>(result.split_sessions);
+        summary["replayed_events"] = result.replayed_events;
+        summary["filtered_events"] = result.filtered_events;
+        if (original_stats.count > 0) {
+            summary["original_median_ms"] = original_stats.median;
+            summary["original_p95_ms"] = original_stats.p95;
+            summary["original_max_ms"] = original_stats.max;
+        }
+        if (replayed_stats.count > 0) {
+            summary["median_ms"] = replayed_stats.median;
+            summary["p95_ms"] = replayed_stats.p95;
+            summary["max_ms"] = replayed_stats.max;
+        }
+        std::cout << Logger::json_to_string(summary) << "\n";
+        return 0;
+    }
+
+    std::cout << "## What-if analysis\n\n";
+    std::cout << "- Log: " << original.source_path << "\n";
+    std::cout << "- Threshold: " << original.convergence_threshold_ms << " ms -> " << options.threshold_ms << " ms\n";
+    if (!options.overrides.empty()) {
+        std::cout << "- Threshold overrides: " << options.overrides.text() << "\n";
+    }
+    if (!options.filter.empty()) {
+        std::cout << "- Filter: interfaces="
+                  << (options.filter.interfaces.empty() ? "all" : options.filter.interfaces_text())
+                  << ", prefixes=" << (options.filter.prefixes.empty() ? "all" : options.filter.prefixes_text())
+                  << "\n";
+    }
+    std::cout << "- Replayed events: " << result.replayed_events << " (filtered out: " << result.filtered_events
+              << ")\n";
+    std::cout << "- Sessions merged from several original sessions: " << result.merged_sessions
+              << ", original sessions split: " << result.split_sessions << "\n\n";
+
+    std::cout << "| Metric | Original | What-if |\n";
+    std::cout << "|---|---:|---:|\n";
+    std::cout << "| Sessions | " << count_completed(original.sessions) << " | " << result.data.sessions.size() << " |\n";
+    std::cout << "| Timed out | " << count_timed_out(original.sessions) << " | "
+              << count_timed_out(result.data.sessions) << " |\n";
+    std::cout << "| Forced (still open at run end) | " << count_forced(original.sessions) << " | "
+              << count_forced(result.data.sessions) << " |\n";
+    std::cout << std::fixed << std::setprecision(1);
+    print_stats_row("Mean convergence (ms)", original_stats, replayed_stats, &DistributionStats::mean);
+    print_stats_row("Median convergence (ms)", original_stats, replayed_stats, &DistributionStats::median);
+    print_stats_row("P95 convergence (ms)", original_stats, replayed_stats, &DistributionStats::p95);
+    print_stats_row("Max convergence (ms)", original_stats, replayed_stats, &DistributionStats::max);
+    std::cout.unsetf(std::ios::floatfield);
+
+    if (!result.data.sessions.empty()) {
+        std::cout << "\n### What-if sessions\n\n";
+        std::cout << "| Router | Session | Start | Trigger | Interface | Convergence (ms) | Route events | Original sessions |\n";
+        std::cout << "|---|---:|---|---|---|---:|---:|---|\n";
+        for (size_t i = 0; i < result.data.sessions.size(); ++i) {
+            const ReportSession& session = result.data.sessions[i];
+            std::cout << "| " << session.router_name << " | " << session.session_id << " | "
+                      << session.start_timestamp << " | " << session.trigger_source << "/"
+                      << session.trigger_event_type << " | " << session.interface() << " | "
+                      << (session.convergence_time_ms.has_value()
+                              ? std::to_string(session.convergence_time_ms.value()) : "-")
+                      << (session.timed_out ? " (timeout)" : session.forced ? " (forced)" : "") << " | " << session.route_events << " | " << result.origins[i] << " |\n";
+        }
+    }
+    return 0;
+}
@@ -0,0 +1,96 @@
+#pragma once
+
+#include <cstdint>
+#include <map>
+#include <optional>
+#include <string>
+#include <unordered_map>
+#include <utility>
+#include <vector>
+#include "event_filter.h"
+#include "report.h"
+#include "threshold_override.h"
+
+// 日志中可重放的一条事件：原会话的触发或会话内的路由事件
+struct ReplayEvent {
+    int run_index = 0;
+    std::string router_name;
+    int64_t time_ms = 0;
+    std::string timestamp;
+    bool trigger = false;          // 原日志中作为会话触发记录
+    std::string source;            // 触发来源(netem/route/...)，会话内的路由事件为route
+    std::string type;              // 触发事件类型或route_event_type
+    int coalesced = 1;             // 合并记录代表的事件数
+    int original_session = 0;
+    std::string campaign_step;
+    std::unordered_map<std::string, std::string> info;
+};
+
+// 重放条件
+struct ReplayOptions {
+    int64_t threshold_ms = 0;
+    ThresholdOverrides overrides;
+    EventFilter filter;
+};
+
+// 一次重放的结果
+struct ReplayResult {
+    ReportData data;                   // 重新划分出的会话
+    std::vector<std::string> origins;  // 与data.sessions一一对应：覆盖的原会话ID，如 "3" 或 "3,4"
+    int64_t replayed_events = 0;
+    int64_t filtered_events = 0;
+    int merged_sessions = 0;  // 覆盖了多个原会话的新会话数(阈值变大)
+    int split_sessions = 0;   // 被拆成多个新会话的原会话数(阈值变小)
+};
+
+// 按新的静默期阈值/过滤条件在已有日志上重新运行收敛状态机(analyze子命令)：
+// 空闲时第一条事件开启会话，距上一条事件超过阈值即收敛，运行结束时仍未收敛的会话记为超时。
+// 只能重放日志里记录下来的事件，原运行中被过滤、预热期内或被忽略规则丢弃的事件无法恢复，
+// 因此放宽过滤条件不会多出会话，较大的阈值也只能合并原本相邻的会话
+class SessionReplay {
+private:
+    ReportData original_;
+    std::vector<ReplayEvent> events_;                            // 按(运行, 路由器, 时间)排序
+    std::map<std::pair<int, std::string>, int64_t> run_end_ms_;  // monitoring_completed的时刻，缺失时为最后一条记录
+
+public:
+    bool load(const std::string& path, std::string& error);
+
+    ReplayResult replay(const ReplayOptions& options) const;
+
+    const ReportData& original() const { return original_; }
+    size_t event_count() const { return events_.size(); }
+};
+
+// --sweep的最大阈值点数
+constexpr size_t MAX_SWEEP_POINTS = 1000;
+
+// 解析 FROM:TO:STEP(如 500:5000:250) 或逗号分隔的阈值列表，结果升序去重，至少2个不同的正阈值
+bool parse_sweep(const std::string& spec, std::vector<int64_t>& thresholds, std::string& error);
+
+// 扫描中的一个阈值点
+struct SweepPoint {
+    int64_t threshold_ms = 0;
+    int64_t sessions = 0;
+    int64_t timed_out = 0;
+    DistributionStats stats;
+    std::optional<double> median_change_pct;  // 相对上一个阈值点的中位数变化
+};
+
+// 扫描的平台区：会话数保持不变的最长连续阈值区间(points下标的闭区间)，
+// 会话划分在区间内不随阈值变化，在区间内选取的阈值不会改变结论
+struct SweepPlateau {
+    bool found = false;  // 每个阈值点的会话数都不同时没有平台区
+    size_t begin = 0;
+    size_t end = 0;
+    bool has_stats = false;  // 区间内有收敛时间时给出中位数的范围
+    double median_min_ms = 0.0;
+    double median_max_ms = 0.0;
+    bool contains_original = false;  // 日志原阈值落在区间内
+};
+
+// 按阈值升序的points中选出平台区，长度相同时优先包含original_threshold_ms的区间
+SweepPlateau find_sweep_plateau(const std::vector<SweepPoint>& points, int64_t original_threshold_ms);
+
+// analyze 子命令入口
+int analyze_main(int argc, char* argv[]);
@@ -0,0 +1,67 @@
+#include "anomaly_detector.h"
+#include <algorithm>
+#include <cmath>
+
+AnomalyDetector::AnomalyDetector(double sigma, size_t window)
+    : sigma_(sigma), window_(std::max(window, MIN_BASELINE)) {
+}
+
+AnomalyResult AnomalyDetector::observe(double convergence_ms) {
+    AnomalyResult result;
+    result.baseline_sessions = baseline_.size();
+
+    if (baseline_.size() < MIN_BASELINE) {
+        baseline_.push_back(convergence_ms);
+        return result;
+    }
+
+    double sum = 0;
+    for (double value : baseline_) {
+        sum += value;
+    }
+    double mean = sum / baseline_.size();
+    double variance = 0;
+    for (double value : baseline_) {
+        variance += (value - mean) * (value - mean);
+    }
+    double stddev = std::max(std::sqrt(variance / (baseline_.size() - 1)), MIN_STDDEV_MS);
+
+    result.baseline_mean_ms = mean;
+    result.baseline_stddev_ms = stddev;
+    result.z_score = (convergence_ms - mean) / stddev;
+    result.direction = convergence_ms >= mean ? "slower" : "faster";
+    result.outlier = std::fabs(result.z_score) > sigma_;
+
+    // 双边CUSUM：单个会话偏离不大、但持续朝同一方向偏移时也能发现
+    double z = std::clamp(result.z_score, -CUSUM_CLAMP, CUSUM_CLAMP);
+    cusum_high_ = std::max(0.0, cusum_high_ + z - CUSUM_DRIFT);
+    cusum_low_ = std::max(0.0, cusum_low_ - z - CUSUM_DRIFT);
+    // 连续朝同一方向偏离的会话，变点后作为新基线的起点
+    bool drifting = std::fabs(result.z_score) > CUSUM_DRIFT;
+    if (!drifting || (!drift_values_.empty() && (drift_values_.back() >= mean) != (convergence_ms >= mean))) {
+        drift_values_.clear();
+    }
+    if (drifting) {
+        drift_values_.push_back(convergence_ms);
+    }
+    if (cusum_high_ > CUSUM_THRESHOLD || cusum_low_ > CUSUM_THRESHOLD) {
+        result.changepoint = true;
+        result.direction = cusum_high_ > CUSUM_THRESHOLD ? "slower" : "faster";
+        cusum_high_ = 0;
+        cusum_low_ = 0;
+        baseline_.assign(drift_values_.begin(), drift_values_.end());
+        drift_values_.clear();
+        while (baseline_.size() > window_) {
+            baseline_.pop_front();
+        }
+        return result;
+    }
+
+    if (!result.outlier) {
+        baseline_.push_back(convergence_ms);
+        if (baseline_.size() > window_) {
+            baseline_.pop_front();
+        }
+    }
+    return result;
+}
@@ -0,0 +1,48 @@
+#pragma once
+
+#include <cstddef>
+#include <deque>
+#include <string>
+
+// 一次收敛时间与滚动基线的比较结果
+struct AnomalyResult {
+    bool outlier = false;       // 偏离基线均值超过N倍标准差
+    bool changepoint = false;   // CUSUM累计偏移越过阈值，基线水平发生了持续变化
+    double baseline_mean_ms = 0;
+    double baseline_stddev_ms = 0;
+    double z_score = 0;
+    size_t baseline_sessions = 0;
+    std::string direction;      // slower/faster
+
+    bool detected() const { return outlier || changepoint; }
+};
+
+// 收敛时间的滚动基线(--anomaly-sigma/--anomaly-window)，用于无人值守的长时间测试中发现异常会话。
+// 离群会话不进入基线，避免单次异常拉高标准差；检测到变点后以变点之后的会话重新建立基线。
+class AnomalyDetector {
+public:
+    // 基线至少有这么多会话才开始判断
+    static constexpr size_t MIN_BASELINE = 5;
+    // 标准差下限(毫秒)，避免收敛时间几乎不变时毫秒级抖动也被判为异常
+    static constexpr double MIN_STDDEV_MS = 1.0;
+    // CUSUM参数：每个会话允许的漂移量与报警阈值，单位为基线标准差
+    static constexpr double CUSUM_DRIFT = 0.5;
+    static constexpr double CUSUM_THRESHOLD = 5.0;
+    // 单个会话计入CUSUM的偏离上限，一次极端离群不会单独触发变点
+    static constexpr double CUSUM_CLAMP = 2.5;
+
+    AnomalyDetector(double sigma, size_t window);
+
+    // 先与当前基线比较，再按结果更新基线
+    AnomalyResult observe(double convergence_ms);
+
+    size_t baseline_size() const { return baseline_.size(); }
+
+private:
+    double sigma_;
+    size_t window_;
+    std::deque<double> baseline_;
+    double cusum_high_ = 0;
+    double cusum_low_ = 0;
+    std::deque<double> drift_values_;  // 最近连续朝同一方向偏离基线的会话
+};
@@ -0,0 +1,364 @@
+#include "bmp_collector.h"
+#include "listen_socket.h"
+#include <arpa/inet.h>
+#include <cerrno>
+#include <chrono>
+#include <cstring>
+#include <iostream>
+#include <netinet/in.h>
+#include <poll.h>
+#include <sys/socket.h>
+#include <unistd.h>
+
+namespace {
+
+constexpr size_t COMMON_HEADER_SIZE = 6;
+constexpr size_t PER_PEER_HEADER_SIZE = 42;
+constexpr size_t BGP_HEADER_SIZE = 19;
+constexpr size_t MAX_MESSAGE_SIZE = 1 << 20;
+
+constexpr uint8_t PEER_FLAG_V = 0x80;
+constexpr uint8_t ATTR_FLAG_EXTENDED = 0x10;
+constexpr uint8_t ATTR_MP_REACH_NLRI = 14;
+constexpr uint8_t ATTR_MP_UNREACH_NLRI = 15;
+constexpr uint16_t AFI_IPV4 = 1;
+constexpr uint16_t AFI_IPV6 = 2;
+
+uint16_t read_u16(const uint8_t* p) {
+    return static_cast<uint16_t>((p[0] << 8) | p[1]);
+}
+
+uint32_t read_u32(const uint8_t* p) {
+    return (static_cast<uint32_t>(p[0]) << 24) | (static_cast<uint32_t>(p[1]) << 16) |
+           (static_cast<uint32_t>(p[2]) << 8) | p[3];
+}
+
+std::string format_address(const uint8_t* bytes, bool ipv6) {
+    char buffer[INET6_ADDRSTRLEN];
+    if (ipv6) {
+        inet_ntop(AF_INET6, bytes, buffer, sizeof(buffer));
+    } else {
+        inet_ntop(AF_INET, bytes, buffer, sizeof(buffer));
+    }
+    return buffer;
+}
+
+int64_t now_ms() {
+    return std::chrono::duration_cast<std::chrono::milliseconds>(
+        std::chrono::system_clock::now().time_since_epoch()).count();
+}
+
+// 解析连续的 <长度, 前缀> NLRI 编码
+bool parse_prefixes(const uint8_t* p, size_t length, bool ipv6, std::vector<std::string>& out) {
+    size_t max_bits = ipv6 ? 128 : 32;
+    size_t pos = 0;
+    while (pos < length) {
+        uint8_t bits = p[pos++];
+        size_t bytes = (bits + 7) / 8;
+        if (bits > max_bits || pos + bytes > length) {
+            return false;
+        }
+        uint8_t addr[16] = {0};
+        memcpy(addr, p + pos, bytes);
+        pos += bytes;
+        out.push_back(format_address(addr, ipv6) + "/" + std::to_string(bits));
+    }
+    return true;
+}
+
+bool parse_bgp_update(const uint8_t* p, size_t length, BmpMessage& message) {
+    if (length < BGP_HEADER_SIZE + 4) {
+        return false;
+    }
+    uint8_t bgp_type = p[18];
+    if (bgp_type != 2) {
+        return true;  // 只关心UPDATE
+    }
+
+    size_t pos = BGP_HEADER_SIZE;
+    uint16_t withdrawn_length = read_u16(p + pos);
+    pos += 2;
+    if (pos + withdrawn_length + 2 > length ||
+        !parse_prefixes(p + pos, withdrawn_length, false, message.withdrawn)) {
+        return false;
+    }
+    pos += withdrawn_length;
+
+    uint16_t attrs_length = read_u16(p + pos);
+    pos += 2;
+    if (pos + attrs_length > length) {
+        return false;
+    }
+
+    size_t attrs_end = pos + attrs_length;
+    while (pos < attrs_end) {
+        if (pos + 3 > attrs_end) {
+            return false;
+        }
+        uint8_t flags = p[pos];
+        uint8_t type = p[pos + 1];
+        size_t attr_length;
+        if (flags & ATTR_FLAG_EXTENDED) {
+            if (pos + 4 > attrs_end) return false;
+            attr_length = read_u16(p + pos + 2);
+            pos += 4;
+        } else {
+            attr_length = p[pos + 2];
+            pos += 3;
+        }
+        if (pos + attr_length > attrs_end) {
+            return false;
+        }
+
+        const uint8_t* value = p + pos;
+        if (type == ATTR_MP_REACH_NLRI && attr_length >= 5) {
+            uint16_t afi = read_u16(value);
+            uint8_t next_hop_length = value[3];
+            size_t nlri_offset = 4 + next_hop_length + 1;
+            if ((afi == AFI_IPV4 || afi == AFI_IPV6) && nlri_offset <= attr_length) {
+                parse_prefixes(value + nlri_offset, attr_length - nlri_offset, afi == AFI_IPV6,
+                               message.announced);
+            }
+        } else if (type == ATTR_MP_UNREACH_NLRI && attr_length >= 3) {
+            uint16_t afi = read_u16(value);
+            if (afi == AFI_IPV4 || afi == AFI_IPV6) {
+                parse_prefixes(value + 3, attr_length - 3, afi == AFI_IPV6, message.withdrawn);
+            }
+        }
+        pos += attr_length;
+    }
+
+    return parse_prefixes(p + attrs_end, length - attrs_end, false, message.announced);
+}
+
+} // namespace
+
+std::string BmpMessage::type_name(int type) {
+    switch (type) {
+        case ROUTE_MONITORING:  return "route_monitoring";
+        case STATISTICS_REPORT: return "statistics_report";
+        case PEER_DOWN:         return "peer_down";
+        case PEER_UP:           return "peer_up";
+        case INITIATION:        return "initiation";
+        case TERMINATION:       return "termination";
+        case ROUTE_MIRRORING:   return "route_mirroring";
+        default:                return "unknown";
+    }
+}
+
+bool BmpCollector::parse_message(const uint8_t* data, size_t length, BmpMessage& message) {
+    if (length < COMMON_HEADER_SIZE || data[0] != 3 || read_u32(data + 1) != length) {
+        return false;
+    }
+    message.type = data[5];
+    message.timestamp_ms = now_ms();
+
+    const uint8_t* p = data + COMMON_HEADER_SIZE;
+    size_t remaining = length - COMMON_HEADER_SIZE;
+
+    if (message.type == BmpMessage::INITIATION || message.type == BmpMessage::TERMINATION) {
+        // TLV: type(2) length(2) value
+        size_t pos = 0;
+        while (pos + 4 <= remaining) {
+            uint16_t tlv_type = read_u16(p + pos);
+            uint16_t tlv_length = read_u16(p + pos + 2);
+            if (pos + 4 + tlv_length > remaining) {
+                return false;
+            }
+            if (message.type == BmpMessage::INITIATION && tlv_type == 2) {
+                message.sys_name.assign(reinterpret_cast<const char*>(p + pos + 4), tlv_length);
+            }
+            pos += 4 + tlv_length;
+        }
+        return true;
+    }
+
+    if (remaining < PER_PEER_HEADER_SIZE) {
+        return false;
+    }
+
+    // 每对等体头: type(1) flags(1) distinguisher(8) address(16) AS(4) BGP ID(4) sec(4) usec(4)
+    uint8_t peer_flags = p[1];
+    bool ipv6 = peer_flags & PEER_FLAG_V;
+    message.peer_address = ipv6 ? format_address(p + 10, true) : format_address(p + 22, false);
+    message.peer_as = read_u32(p + 26);
+    message.peer_bgp_id = format_address(p + 30, false);
+    uint32_t seconds = read_u32(p + 34);
+    uint32_t micros = read_u32(p + 38);
+    if (seconds != 0) {
+        message.timestamp_ms = static_cast<int64_t>(seconds) * 1000 + micros / 1000;
+    }
+
+    p += PER_PEER_HEADER_SIZE;
+    remaining -= PER_PEER_HEADER_SIZE;
+
+    if (message.type == BmpMessage::ROUTE_MONITORING) {
+        return parse_bgp_update(p, remaining, message);
+    }
+    if (message.type == BmpMessage::PEER_DOWN && remaining >= 1) {
+        message.peer_down_reason = p[0];
+    }
+    return true;
+}
+
+bool BmpCollector::parse_listen_spec(const std::string& spec, std::string& address, int& port) {
+    std::string port_text = spec;
+    address.clear();
+
+    if (!spec.empty() && spec[0] == '[') {
+        size_t close = spec.find("]:");
+        if (close == std::string::npos) {
+            return false;
+        }
+        address = spec.substr(1, close - 1);
+        port_text = spec.substr(close + 2);
+    } else {
+        size_t colon = spec.rfind(':');
+        if (colon != std::string::npos) {
+            if (spec.find(':') != colon) {
+                return false;  // IPv6地址需用[]括起来
+            }
+            address = spec.substr(0, colon);
+            port_text = spec.substr(colon + 1);
+        }
+    }
+
+    try {
+        size_t pos = 0;
+        port = std::stoi(port_text, &pos);
+        if (pos != port_text.size()) {
+            return false;
+        }
+    } catch (const std::exception&) {
+        return false;
+    }
+    return port > 0 && port < 65536;
+}
+
+BmpCollector::BmpCollector(const std::string& listen_address, int port, Callback callback)
+    : listen_address_(listen_address), port_(port), callback_(std::move(callback)) {
+}
+
+BmpCollector::~BmpCollector() {
+    stop();
+}
+
+bool BmpCollector::start(std::string& error) {
+    if (running_.load()) {
+        return true;
+    }
+
+    listen_fd_ = open_listen_socket(listen_address_, port_, SOCK_STREAM, error);
+    if (listen_fd_ < 0) {
+        return false;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&BmpCollector::worker_loop, this);
+    return true;
+}
+
+void BmpCollector::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+    if (listen_fd_ >= 0) {
+        close(listen_fd_);
+        listen_fd_ = -1;
+    }
+}
+
+void BmpCollector::worker_loop() {
+    struct Connection {
+        int fd;
+        std::string peer;
+        std::vector<uint8_t> buffer;
+    };
+    std::vector<Connection> connections;
+
+    while (running_.load()) {
+        std::vector<struct pollfd> fds;
+        fds.push_back({listen_fd_, POLLIN, 0});
+        for (const auto& conn : connections) {
+            fds.push_back({conn.fd, POLLIN, 0});
+        }
+
+        int ready = poll(fds.data(), fds.size(), 200);
+        if (ready <= 0) {
+            continue;
+        }
+
+        if (fds[0].revents & POLLIN) {
+            struct sockaddr_storage peer_addr;
+            socklen_t peer_length = sizeof(peer_addr);
+            int fd = accept4(listen_fd_, reinterpret_cast<struct sockaddr*>(&peer_addr),
+                             &peer_length, SOCK_CLOEXEC);
+            if (fd >= 0) {
+                char host[INET6_ADDRSTRLEN] = "unknown";
+                if (peer_addr.ss_family == AF_INET) {
+                    inet_ntop(AF_INET, &reinterpret_cast<struct sockaddr_in*>(&peer_addr)->sin_addr,
+                              host, sizeof(host));
+                } else {
+                    inet_ntop(AF_INET6, &reinterpret_cast<struct sockaddr_in6*>(&peer_addr)->sin6_addr,
+                              host, sizeof(host));
+                }
+                std::cout << "📡 BMP连接建立: " << host << "\n";
+                connections.push_back({fd, host, {}});
+            }
+        }
+
+        for (size_t i = 1; i < fds.size(); ++i) {
+            if (!(fds[i].revents & (POLLIN | POLLHUP | POLLERR))) {
+                continue;
+            }
+            Connection& conn = connections[i - 1];
+            uint8_t chunk[65536];
+            ssize_t len = read(conn.fd, chunk, sizeof(chunk));
+            if (len <= 0) {
+                std::cout << "📡 BMP连接断开: " << conn.peer << "\n";
+                close(conn.fd);
+                conn.fd = -1;
+                continue;
+            }
+            conn.buffer.insert(conn.buffer.end(), chunk, chunk + len);
+
+            // 按公共头中的长度切分完整消息
+            size_t pos = 0;
+            while (conn.buffer.size() - pos >= COMMON_HEADER_SIZE) {
+                uint32_t message_length = read_u32(conn.buffer.data() + pos + 1);
+                if (conn.buffer[pos] != 3 || message_length < COMMON_HEADER_SIZE ||
+                    message_length > MAX_MESSAGE_SIZE) {
+                    std::cerr << "⚠️  BMP流格式错误，断开 " << conn.peer << "\n";
+                    close(conn.fd);
+                    conn.fd = -1;
+                    break;
+                }
+                if (conn.buffer.size() - pos < message_length) {
+                    break;
+                }
+                BmpMessage message;
+                if (parse_message(conn.buffer.data() + pos, message_length, message)) {
+                    message.router = conn.peer;
+                    callback_(message);
+                }
+                pos += message_length;
+            }
+            if (conn.fd >= 0) {
+                conn.buffer.erase(conn.buffer.begin(), conn.buffer.begin() + static_cast<long>(pos));
+            }
+        }
+
+        for (auto it = connections.begin(); it != connections.end();) {
+            it = it->fd < 0 ? connections.erase(it) : it + 1;
+        }
+    }
+
+    for (const auto& conn : connections) {
+        close(conn.fd);
+    }
+}
@@ -0,0 +1,66 @@
+#pragma once
+
+#include <atomic>
+#include <cstdint>
+#include <functional>
+#include <string>
+#include <thread>
+#include <vector>
+
+// 解析后的BMP消息(RFC 7854)
+struct BmpMessage {
+    enum Type {
+        ROUTE_MONITORING = 0,
+        STATISTICS_REPORT = 1,
+        PEER_DOWN = 2,
+        PEER_UP = 3,
+        INITIATION = 4,
+        TERMINATION = 5,
+        ROUTE_MIRRORING = 6,
+    };
+
+    int type = -1;
+    std::string router;        // BMP发送方地址
+    std::string sys_name;      // Initiation中的sysName
+    std::string peer_address;
+    uint32_t peer_as = 0;
+    std::string peer_bgp_id;
+    int64_t timestamp_ms = 0;  // 每对等体头中的时间戳，为0时使用接收时间
+    std::vector<std::string> announced;
+    std::vector<std::string> withdrawn;
+    int peer_down_reason = 0;
+
+    static std::string type_name(int type);
+};
+
+// 内嵌BMP采集器：监听TCP端口，接收FRR/GoBGP等推送的BMP流
+class BmpCollector {
+public:
+    using Callback = std::function<void(const BmpMessage&)>;
+
+private:
+    std::string listen_address_;
+    int port_;
+    Callback callback_;
+    int listen_fd_ = -1;
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+
+    void worker_loop();
+
+public:
+    BmpCollector(const std::string& listen_address, int port, Callback callback);
+    ~BmpCollector();
+
+    BmpCollector(const BmpCollector&) = delete;
+    BmpCollector& operator=(const BmpCollector&) = delete;
+
+    bool start(std::string& error);
+    void stop();
+
+    // 解析 "PORT" 或 "ADDR:PORT"/"[V6ADDR]:PORT"
+    static bool parse_listen_spec(const std::string& spec, std::string& address, int& port);
+
+    // 解析单条完整BMP消息，data长度必须等于公共头中的长度
+    static bool parse_message(const uint8_t* data, size_t length, BmpMessage& message);
+};
@@ -0,0 +1,143 @@
+#include "bpf_util.h"
+#include <cerrno>
+#include <cstdio>
+#include <cstring>
+#include <ctime>
+#include <poll.h>
+#include <sys/mman.h>
+#include <sys/syscall.h>
+#include <sys/utsname.h>
+#include <unistd.h>
+
+namespace {
+
+// 5.0之前的内核加载kprobe程序时要求kern_version与运行中的内核一致，其他类型忽略该字段
+uint32_t kernel_version() {
+    struct utsname name;
+    unsigned major = 0, minor = 0, patch = 0;
+    if (uname(&name) == 0) {
+        sscanf(name.release, "%u.%u.%u", &major, &minor, &patch);
+    }
+    return (major << 16) | (minor << 8) | (patch > 255 ? 255 : patch);
+}
+
+int64_t clock_ns(clockid_t clock) {
+    struct timespec ts;
+    clock_gettime(clock, &ts);
+    return static_cast<int64_t>(ts.tv_sec) * 1000000000 + ts.tv_nsec;
+}
+
+} // namespace
+
+bpf_insn bpf_make_insn(uint8_t code, uint8_t dst, uint8_t src, int16_t off, int32_t imm) {
+    bpf_insn insn{};
+    insn.code = code;
+    insn.dst_reg = dst;
+    insn.src_reg = src;
+    insn.off = off;
+    insn.imm = imm;
+    return insn;
+}
+
+int bpf_syscall(int cmd, union bpf_attr& attr) {
+    return static_cast<int>(syscall(__NR_bpf, cmd, &attr, sizeof(attr)));
+}
+
+int bpf_load_program(uint32_t prog_type, const std::vector<bpf_insn>& insns, std::string& error) {
+    char log[4096] = "";
+    union bpf_attr attr;
+    memset(&attr, 0, sizeof(attr));
+    attr.prog_type = prog_type;
+    attr.insns = reinterpret_cast<uint64_t>(insns.data());
+    attr.insn_cnt = static_cast<uint32_t>(insns.size());
+    attr.license = reinterpret_cast<uint64_t>("GPL");
+    attr.log_buf = reinterpret_cast<uint64_t>(log);
+    attr.log_size = sizeof(log);
+    attr.log_level = 1;
+    attr.kern_version = kernel_version();
+    int prog_fd = bpf_syscall(BPF_PROG_LOAD, attr);
+    if (prog_fd < 0) {
+        error = std::string("BPF_PROG_LOAD: ") + strerror(errno);
+        if (log[0] != '\0') {
+            error += ": " + std::string(log);
+        }
+    }
+    return prog_fd;
+}
+
+int64_t bpf_realtime_offset_ns() {
+    return clock_ns(CLOCK_REALTIME) - clock_ns(CLOCK_MONOTONIC);
+}
+
+BpfRingbuf::~BpfRingbuf() {
+    close();
+}
+
+bool BpfRingbuf::open(uint32_t size, std::string& error) {
+    union bpf_attr attr;
+    memset(&attr, 0, sizeof(attr));
+    attr.map_type = BPF_MAP_TYPE_RINGBUF;
+    attr.max_entries = size;
+    fd_ = bpf_syscall(BPF_MAP_CREATE, attr);
+    if (fd_ < 0) {
+        error = std::string("BPF_MAP_CREATE(ringbuf): ") + strerror(errno);
+        return false;
+    }
+    size_ = size;
+
+    page_size_ = static_cast<size_t>(sysconf(_SC_PAGESIZE));
+    consumer_page_ = mmap(nullptr, page_size_, PROT_READ | PROT_WRITE, MAP_SHARED, fd_, 0);
+    producer_pages_ = mmap(nullptr, page_size_ + 2 * static_cast<size_t>(size_), PROT_READ, MAP_SHARED, fd_,
+                           static_cast<off_t>(page_size_));
+    if (consumer_page_ == MAP_FAILED || producer_pages_ == MAP_FAILED) {
+        error = std::string("mmap ringbuf: ") + strerror(errno);
+        close();
+        return false;
+    }
+    return true;
+}
+
+void BpfRingbuf::close() {
+    if (consumer_page_ && consumer_page_ != MAP_FAILED) {
+        munmap(consumer_page_, page_size_);
+    }
+    if (producer_pages_ && producer_pages_ != MAP_FAILED) {
+        munmap(producer_pages_, page_size_ + 2 * static_cast<size_t>(size_));
+    }
+    consumer_page_ = nullptr;
+    producer_pages_ = nullptr;
+    if (fd_ >= 0) {
+        ::close(fd_);
+        fd_ = -1;
+    }
+}
+
+void BpfRingbuf::poll(int timeout_ms, const Handler& handler) {
+    struct pollfd pfd = {fd_, POLLIN, 0};
+    if (::poll(&pfd, 1, timeout_ms) < 0 && errno != EINTR) {
+        return;
+    }
+
+    auto* consumer_pos = static_cast<uint64_t*>(consumer_page_);
+    auto* producer_pos = static_cast<uint64_t*>(producer_pages_);
+    const char* data = static_cast<const char*>(producer_pages_) + page_size_;
+
+    uint64_t consumer = __atomic_load_n(consumer_pos, __ATOMIC_ACQUIRE);
+    uint64_t producer = __atomic_load_n(producer_pos, __ATOMIC_ACQUIRE);
+    while (consumer < producer) {
+        const auto* header = reinterpret_cast<const uint32_t*>(data + (consumer & (size_ - 1)));
+        uint32_t len = __atomic_load_n(header, __ATOMIC_ACQUIRE);
+        if (len & BPF_RINGBUF_BUSY_BIT) {
+            break;
+        }
+        bool discarded = len & BPF_RINGBUF_DISCARD_BIT;
+        len &= ~(BPF_RINGBUF_BUSY_BIT | BPF_RINGBUF_DISCARD_BIT);
+
+        if (!discarded) {
+            handler(reinterpret_cast<const char*>(header) + BPF_RINGBUF_HDR_SZ, len);
+        }
+
+        consumer += (BPF_RINGBUF_HDR_SZ + len + 7) / 8 * 8;
+        __atomic_store_n(consumer_pos, consumer, __ATOMIC_RELEASE);
+    }
+}
@@ -0,0 +1,48 @@
+#pragma once
+
+#include <cstddef>
+#include <cstdint>
+#include <functional>
+#include <linux/bpf.h>
+#include <string>
+#include <vector>
+
+// fib_tracer与dataplane_probe共用的eBPF辅助：直接使用bpf()系统调用，不依赖libbpf
+
+bpf_insn bpf_make_insn(uint8_t code, uint8_t dst, uint8_t src, int16_t off, int32_t imm);
+
+int bpf_syscall(int cmd, union bpf_attr& attr);
+
+// 加载程序并返回fd，失败时返回-1，error中附带校验器日志
+int bpf_load_program(uint32_t prog_type, const std::vector<bpf_insn>& insns, std::string& error);
+
+// bpf_ktime_get_ns(CLOCK_MONOTONIC)换算到系统时钟要加上的纳秒数
+int64_t bpf_realtime_offset_ns();
+
+// BPF_MAP_TYPE_RINGBUF及其用户态消费端(mmap的consumer/producer页)
+class BpfRingbuf {
+public:
+    using Handler = std::function<void(const char* data, uint32_t len)>;
+
+private:
+    int fd_ = -1;
+    uint32_t size_ = 0;
+    void* consumer_page_ = nullptr;
+    void* producer_pages_ = nullptr;
+    size_t page_size_ = 0;
+
+public:
+    BpfRingbuf() = default;
+    ~BpfRingbuf();
+
+    BpfRingbuf(const BpfRingbuf&) = delete;
+    BpfRingbuf& operator=(const BpfRingbuf&) = delete;
+
+    // size须为页大小的2的幂次倍
+    bool open(uint32_t size, std::string& error);
+    void close();
+    int fd() const { return fd_; }
+
+    // 最多等待timeout_ms，然后把已提交的记录逐条交给handler
+    void poll(int timeout_ms, const Handler& handler);
+};
@@ -0,0 +1,366 @@
+#include "campaign.h"
+#include "cli_utils.h"
+#include "convergence_monitor.h"
+#include <getopt.h>
+#include <iostream>
+#include <memory>
+
+namespace {
+
+bool read_duration(const YamlNode& node, const std::string& key, int64_t& out, std::string& error) {
+    const YamlNode* value = node.get(key);
+    if (value == nullptr) {
+        return true;
+    }
+    int64_t ms = value->is_scalar() ? parse_duration_ms(value->scalar) : -1;
+    if (ms < 0) {
+        error = "invalid " + key + ": " + value->scalar;
+        return false;
+    }
+    out = ms;
+    return true;
+}
+
+bool read_step_fields(const YamlNode& node, CampaignStep& step, std::string& error) {
+    step.netem.interface = node.get_string("iface", step.netem.interface);
+
+    int64_t delay_ms = step.netem.delay_us / 1000;
+    int64_t jitter_ms = step.netem.jitter_us / 1000;
+    if (!read_duration(node, "delay", delay_ms, error) ||
+        !read_duration(node, "jitter", jitter_ms, error) ||
+        !read_duration(node, "hold", step.hold_ms, error) ||
+        !read_duration(node, "interval", step.interval_ms, error) ||
+        !read_duration(node, "duration", step.duration_ms, error)) {
+        return false;
+    }
+    step.netem.delay_us = delay_ms * 1000;
+    step.netem.jitter_us = jitter_ms * 1000;
+
+    std::string loss = node.get_string("loss");
+    if (!loss.empty()) {
+        step.netem.loss_percent = parse_percent(loss);
+        if (step.netem.loss_percent < 0) {
+            error = "invalid loss: " + loss;
+            return false;
+        }
+    }
+
+    std::string repeat = node.get_string("repeat");
+    if (!repeat.empty()) {
+        try {
+            step.repeat = std::stoi(repeat);
+        } catch (const std::exception&) {
+            step.repeat = 0;
+        }
+        if (step.repeat <= 0) {
+            error = "invalid repeat: " + repeat;
+            return false;
+        }
+    }
+    return true;
+}
+
+} // namespace
+
+CampaignRunner::CampaignRunner(NetemInjector& injector, ConvergenceMonitor* monitor)
+    : injector_(injector), monitor_(monitor) {
+}
+
+bool CampaignRunner::load(const std::string& path, CampaignPlan& plan, std::string& error) {
+    YamlNode root;
+    if (!YamlParser::parse_file(path, root, error)) {
+        return false;
+    }
+    return from_yaml(root, plan, error);
+}
+
+bool CampaignRunner::from_yaml(const YamlNode& root, CampaignPlan& plan, std::string& error) {
+    if (!root.is_map()) {
+        error = "campaign plan must be a mapping";
+        return false;
+    }
+    plan.name = root.get_string("name", "campaign");
+
+    // defaults中的字段作为每个步骤的初始值
+    CampaignStep defaults;
+    if (const YamlNode* node = root.get("defaults")) {
+        if (!node->is_map() || !read_step_fields(*node, defaults, error)) {
+            error = "defaults: " + (error.empty() ? "must be a mapping" : error);
+            return false;
+        }
+    }
+
+    const YamlNode* steps = root.get("steps");
+    if (steps == nullptr || !steps->is_sequence() || steps->items.empty()) {
+        error = "campaign plan requires a non-empty 'steps' list";
+        return false;
+    }
+
+    for (size_t i = 0; i < steps->items.size(); ++i) {
+        const YamlNode& node = steps->items[i];
+        std::string where = "steps[" + std::to_string(i) + "]";
+        if (!node.is_map()) {
+            error = where + ": must be a mapping";
+            return false;
+        }
+
+        CampaignStep step = defaults;
+        step.id = node.get_string("id", "step-" + std::to_string(i + 1));
+        if (!read_step_fields(node, step, error)) {
+            error = where + " (" + step.id + "): " + error;
+            return false;
+        }
+
+        step.action = node.get_string("action");
+        if (step.action.empty()) {
+            step.action = node.get("duration") ? "wait" : "netem";
+        }
+
+        if (step.action == "wait") {
+            if (step.duration_ms <= 0) {
+                error = where + " (" + step.id + "): wait step requires 'duration'";
+                return false;
+            }
+        } else if (step.action == "netem" || step.action == "link-down") {
+            if (step.netem.interface.empty()) {
+                error = where + " (" + step.id + "): missing 'iface'";
+                return false;
+            }
+            if (step.hold_ms <= 0) {
+                error = where + " (" + step.id + "): 'hold' must be positive";
+                return false;
+            }
+        } else {
+            error = where + " (" + step.id + "): unknown action '" + step.action + "'";
+            return false;
+        }
+
+        plan.steps.push_back(step);
+    }
+    return true;
+}
+
+void CampaignRunner::print_plan(const CampaignPlan& plan) {
+    std::cout << "📋 故障计划: " << plan.name << " (" << plan.steps.size() << " 个步骤)\n";
+    for (const auto& step : plan.steps) {
+        std::cout << "  - " << step.id << ": ";
+        if (step.action == "wait") {
+            std::cout << "等待 " << step.duration_ms << "ms\n";
+            continue;
+        }
+        std::cout << step.action << " dev " << step.netem.interface;
+        if (step.action == "netem") {
+            std::cout << " delay " << step.netem.delay_us / 1000 << "ms";
+            if (step.netem.jitter_us > 0) {
+                std::cout << " jitter " << step.netem.jitter_us / 1000 << "ms";
+            }
+            if (step.netem.loss_percent > 0) {
+                std::cout << " loss " << step.netem.loss_percent << "%";
+            }
+        }
+        std::cout << ", 保持" << step.hold_ms << "ms, 间隔" << step.interval_ms
+                  << "ms, 共" << step.repeat << "次\n";
+    }
+}
+
+void CampaignRunner::tag_sessions(const CampaignStep& step, int iteration) {
+    if (!monitor_) {
+        return;
+    }
+    monitor_->set_session_tags({
+        {"campaign", campaign_name_},
+        {"campaign_step", step.id},
+        {"campaign_iteration", std::to_string(iteration)},
+    });
+}
+
+void CampaignRunner::log_step_event(const std::string& event_type, const CampaignStep& step, int iteration) {
+    if (!monitor_) {
+        return;
+    }
+    monitor_->log_external_event(event_type, {
+        {"campaign", campaign_name_},
+        {"campaign_step", step.id},
+        {"campaign_iteration", std::to_string(iteration)},
+        {"action", step.action},
+        {"interface", step.netem.interface},
+    });
+}
+
+bool CampaignRunner::inject_once(const CampaignStep& step, int iteration, std::string& error) {
+    // 先打标签再注入，保证故障触发的会话能带上步骤ID
+    tag_sessions(step, iteration);
+
+    bool applied = step.action == "netem"
+        ? injector_.apply(step.netem, error)
+        : injector_.set_link_state(step.netem.interface, false, error);
+    if (!applied) {
+        return false;
+    }
+    std::cout << "💉 [" << step.id << " " << iteration << "/" << step.repeat << "] 已施加"
+              << step.action << "\n";
+    log_step_event("fault_injected", step, iteration);
+
+    bool keep_going = interruptible_sleep_ms(step.hold_ms);
+
+    bool cleared = step.action == "netem"
+        ? injector_.clear(step.netem.interface, error)
+        : injector_.set_link_state(step.netem.interface, true, error);
+    if (!cleared) {
+        return false;
+    }
+    std::cout << "🧹 [" << step.id << " " << iteration << "/" << step.repeat << "] 已恢复\n";
+    log_step_event("fault_cleared", step, iteration);
+
+    // 间隔期间恢复触发的会话同样归属于当前步骤
+    return keep_going && interruptible_sleep_ms(step.interval_ms);
+}
+
+bool CampaignRunner::run(const CampaignPlan& plan, std::string& error) {
+    campaign_name_ = plan.name;
+
+    for (const auto& step : plan.steps) {
+        if (stop_requested()) {
+            break;
+        }
+        if (step.action == "wait") {
+            std::cout << "⏳ [" << step.id << "] 等待 " << step.duration_ms << "ms\n";
+            tag_sessions(step, 1);
+            if (!interruptible_sleep_ms(step.duration_ms)) {
+                break;
+            }
+            continue;
+        }
+
+        for (int i = 1; i <= step.repeat; ++i) {
+            if (!inject_once(step, i, error)) {
+                if (!error.empty()) {
+                    error = step.id + ": " + error;
+                    return false;
+                }
+                break;
+            }
+        }
+    }
+
+    if (monitor_) {
+        monitor_->set_session_tags({});
+    }
+    return true;
+}
+
+namespace {
+
+void print_campaign_usage(const char* program_name) {
+    std::cout << "用法: " << program_name << " campaign PLAN.yml [选项]\n\n";
+    std::cout << "按YAML故障计划依次注入netem/链路中断故障，并用步骤ID标记产生的会话\n\n";
+    std::cout << "选项:\n";
+    std::cout << "      --dry-run             只解析并打印计划，不注入故障\n";
+    std::cout << "      --no-monitor          只注入故障，不启动收敛监控\n";
+    std::cout << "  -t, --threshold MS        收敛判断阈值(默认3000ms)\n";
+    std::cout << "  -r, --router-name NAME    路由器名称\n";
+    std::cout << "  -l, --log-path PATH       日志文件路径\n";
+    std::cout << "  -h, --help                显示此帮助信息\n\n";
+    std::cout << "计划文件示例:\n";
+    std::cout << "  name: spine-failover\n";
+    std::cout << "  defaults: {iface: eth1, hold: 30s, interval: 10s}\n";
+    std::cout << "  steps:\n";
+    std::cout << "    - id: delay-50\n";
+    std::cout << "      delay: 50ms\n";
+    std::cout << "      loss: 10%\n";
+    std::cout << "      repeat: 5\n";
+    std::cout << "    - id: settle\n";
+    std::cout << "      action: wait\n";
+    std::cout << "      duration: 60s\n";
+    std::cout << "    - id: eth1-flap\n";
+    std::cout << "      action: link-down\n";
+    std::cout << "      hold: 10s\n";
+}
+
+enum CampaignOption {
+    OPT_DRY_RUN = 1000,
+    OPT_NO_MONITOR,
+};
+
+} // namespace
+
+int campaign_main(int argc, char* argv[]) {
+    bool dry_run = false;
+    bool with_monitor = true;
+    MonitorConfig config;
+
+    static struct option long_options[] = {
+        {"dry-run", no_argument, 0, OPT_DRY_RUN},
+        {"no-monitor", no_argument, 0, OPT_NO_MONITOR},
+        {"threshold", required_argument, 0, 't'},
+        {"router-name", required_argument, 0, 'r'},
+        {"log-path", required_argument, 0, 'l'},
+        {"help", no_argument, 0, 'h'},
+        {0, 0, 0, 0}
+    };
+
+    optind = 1;
+    int c;
+    while ((c = getopt_long(argc, argv, "t:r:l:h", long_options, nullptr)) != -1) {
+        switch (c) {
+            case OPT_DRY_RUN: dry_run = true; break;
+            case OPT_NO_MONITOR: with_monitor = false; break;
+            case 't': config.convergence_threshold_ms = std::stoll(optarg); break;
+            case 'r': config.router_name = optarg; break;
+            case 'l': config.log_path = optarg; break;
+            case 'h': print_campaign_usage(argv[0]); return 0;
+            default:  print_campaign_usage(argv[0]); return 1;
+        }
+    }
+
+    if (optind >= argc) {
+        std::cerr << "❌ 错误: 必须指定计划文件\n";
+        print_campaign_usage(argv[0]);
+        return 1;
+    }
+
+    CampaignPlan plan;
+    std::string error;
+    if (!CampaignRunner::load(argv[optind], plan, error)) {
+        std::cerr << "❌ 加载计划失败: " << error << "\n";
+        return 1;
+    }
+
+    CampaignRunner::print_plan(plan);
+    if (dry_run) {
+        return 0;
+    }
+
+    NetemInjector injector;
+    if (!injector.open(error)) {
+        std::cerr << "❌ " << error << "\n";
+        return 1;
+    }
+
+    install_stop_signal_handlers();
+
+    if (config.router_name.empty()) {
+        config.router_name = "campaign_" + plan.name;
+    }
+    std::unique_ptr<ConvergenceMonitor> monitor;
+    if (with_monitor) {
+        monitor = std::make_unique<ConvergenceMonitor>(config);
+        monitor->start_monitoring();
+    }
+
+    CampaignRunner runner(injector, monitor.get());
+    bool ok = runner.run(plan, error);
+    if (!ok) {
+        std::cerr << "❌ 计划执行失败: " << error << "\n";
+    }
+
+    if (monitor) {
+        // 等待最后一个步骤的收敛
+        if (ok && !stop_requested()) {
+            interruptible_sleep_ms(config.convergence_threshold_ms + 1000);
+        }
+        monitor->stop_monitoring();
+    }
+
+    return ok ? 0 : 1;
+}
@@ -0,0 +1,52 @@
+#pragma once
+
+#include "inject.h"
+#include "yaml_lite.h"
+#include <string>
+#include <vector>
+
+class ConvergenceMonitor;
+
+// 故障计划中的单个步骤
+struct CampaignStep {
+    std::string id;
+    std::string action;          // netem | link-down | wait
+    NetemSpec netem;             // netem参数，interface同时用于link-down
+    int64_t hold_ms = 30000;     // 故障保持时间
+    int64_t interval_ms = 30000; // 故障移除后到下一次注入的间隔
+    int repeat = 1;
+    int64_t duration_ms = 0;     // wait步骤的等待时间
+};
+
+// YAML故障计划
+struct CampaignPlan {
+    std::string name;
+    std::vector<CampaignStep> steps;
+};
+
+class CampaignRunner {
+private:
+    NetemInjector& injector_;
+    ConvergenceMonitor* monitor_;  // 可为空(--no-monitor)
+    std::string campaign_name_;
+
+    void tag_sessions(const CampaignStep& step, int iteration);
+    void log_step_event(const std::string& event_type, const CampaignStep& step, int iteration);
+    bool inject_once(const CampaignStep& step, int iteration, std::string& error);
+
+public:
+    CampaignRunner(NetemInjector& injector, ConvergenceMonitor* monitor);
+
+    // 从YAML加载计划并校验
+    static bool load(const std::string& path, CampaignPlan& plan, std::string& error);
+    static bool from_yaml(const YamlNode& root, CampaignPlan& plan, std::string& error);
+
+    // 按顺序执行所有步骤，收到停止信号时移除当前故障后返回
+    bool run(const CampaignPlan& plan, std::string& error);
+
+    // 打印计划内容（--dry-run）
+    static void print_plan(const CampaignPlan& plan);
+};
+
+// campaign 子命令入口
+int campaign_main(int argc, char* argv[]);
@@ -0,0 +1,254 @@
+#include "clab_inject.h"
+#include "cli_utils.h"
+#include "logger.h"
+#include "subprocess.h"
+#include <getopt.h>
+#include <iostream>
+#include <sstream>
+#include <unistd.h>
+
+ClabNetemInjector::ClabNetemInjector(const ClabTopology& topology, const std::string& clab_binary)
+    : topology_(topology), clab_binary_(clab_binary) {
+}
+
+std::vector<std::string> ClabNetemInjector::build_set_command(const std::string& node,
+                                                              const NetemSpec& spec) const {
+    std::vector<std::string> args = {
+        clab_binary_, "tools", "netem", "set",
+        "-n", topology_.container_name(node),
+        "-i", spec.interface,
+    };
+    if (spec.delay_us > 0) {
+        args.push_back("--delay");
+        args.push_back(std::to_string(spec.delay_us / 1000) + "ms");
+    }
+    if (spec.jitter_us > 0) {
+        args.push_back("--jitter");
+        args.push_back(std::to_string(spec.jitter_us / 1000) + "ms");
+    }
+    if (spec.loss_percent > 0) {
+        std::ostringstream loss;
+        loss << spec.loss_percent;
+        args.push_back("--loss");
+        args.push_back(loss.str());
+    }
+    return args;
+}
+
+std::vector<std::string> ClabNetemInjector::build_reset_command(const std::string& node,
+                                                                const std::string& interface) const {
+    // 所有参数置零即移除损伤
+    return {
+        clab_binary_, "tools", "netem", "set",
+        "-n", topology_.container_name(node),
+        "-i", interface,
+        "--delay", "0ms", "--jitter", "0ms", "--loss", "0",
+    };
+}
+
+namespace {
+
+bool run_clab(const std::vector<std::string>& args, std::string& error) {
+    std::string output;
+    int code = run_command(args, output, error);
+    if (code != 0) {
+        if (error.empty()) {
+            error = "exit code " + std::to_string(code) + ": " + output;
+        }
+        return false;
+    }
+    return true;
+}
+
+} // namespace
+
+bool ClabNetemInjector::apply(const std::string& node, const NetemSpec& spec, std::string& error) {
+    return run_clab(build_set_command(node, spec), error);
+}
+
+bool ClabNetemInjector::clear(const std::string& node, const std::string& interface, std::string& error) {
+    return run_clab(build_reset_command(node, interface), error);
+}
+
+namespace {
+
+void print_clab_usage(const char* program_name) {
+    std::cout << "用法: " << program_name << " clab --topology LAB.yml --node NODE --iface IF [netem参数] [选项]\n\n";
+    std::cout << "通过 clab tools netem 在containerlab节点上周期性施加/移除故障，\n";
+    std::cout << "并打印各节点监控器应使用的标签参数，使会话带上clab节点与链路名\n\n";
+    std::cout << "选项:\n";
+    std::cout << "      --topology PATH       containerlab拓扑文件\n";
+    std::cout << "      --node NAME           注入故障的节点\n";
+    std::cout << "      --iface NAME          节点上的接口(拓扑文件中的端点名)\n";
+    std::cout << "      --delay DURATION      时延(如 50ms)\n";
+    std::cout << "      --jitter DURATION     抖动(如 5ms)\n";
+    std::cout << "      --loss PERCENT        丢包率(如 10%)\n";
+    std::cout << "      --hold DURATION       每次故障保持时间(默认30s)\n";
+    std::cout << "      --interval DURATION   故障移除后到下一次注入的间隔(默认同--hold)\n";
+    std::cout << "      --repeat N            注入次数(默认1)\n";
+    std::cout << "      --clab PATH           clab可执行文件(默认clab)\n";
+    std::cout << "      --dry-run             只打印将要执行的命令\n";
+    std::cout << "  -l, --log-path PATH       注入记录日志路径\n";
+    std::cout << "  -h, --help                显示此帮助信息\n";
+}
+
+enum ClabOption {
+    OPT_TOPOLOGY = 1000,
+    OPT_NODE,
+    OPT_IFACE,
+    OPT_DELAY,
+    OPT_JITTER,
+    OPT_LOSS,
+    OPT_HOLD,
+    OPT_INTERVAL,
+    OPT_REPEAT,
+    OPT_CLAB_BINARY,
+    OPT_DRY_RUN,
+};
+
+void log_fault_event(Logger& logger, const std::string& event_type, const std::string& router_name,
+                     const ClabTopology& topology, const std::string& node,
+                     const std::string& interface, const std::string& link_name, int iteration) {
+    auto log = Logger::create_event_log(event_type, router_name, Logger::current_user());
+    log["clab_lab"] = topology.lab_name;
+    log["clab_node"] = node;
+    log["clab_container"] = topology.container_name(node);
+    log["interface"] = interface;
+    log["clab_link"] = link_name;
+    log["iteration"] = static_cast<int64_t>(iteration);
+    logger.log_async(log);
+}
+
+} // namespace
+
+int clab_main(int argc, char* argv[]) {
+    std::string topology_path;
+    std::string node;
+    std::string clab_binary = "clab";
+    std::string log_path;
+    NetemSpec spec;
+    int64_t hold_ms = 30000;
+    int64_t interval_ms = -1;
+    int repeat = 1;
+    bool dry_run = false;
+
+    static struct option long_options[] = {
+        {"topology", required_argument, 0, OPT_TOPOLOGY},
+        {"node", required_argument, 0, OPT_NODE},
+        {"iface", required_argument, 0, OPT_IFACE},
+        {"delay", required_argument, 0, OPT_DELAY},
+        {"jitter", required_argument, 0, OPT_JITTER},
+        {"loss", required_argument, 0, OPT_LOSS},
+        {"hold", required_argument, 0, OPT_HOLD},
+        {"interval", required_argument, 0, OPT_INTERVAL},
+        {"repeat", required_argument, 0, OPT_REPEAT},
+        {"clab", required_argument, 0, OPT_CLAB_BINARY},
+        {"dry-run", no_argument, 0, OPT_DRY_RUN},
+        {"log-path", required_argument, 0, 'l'},
+        {"help", no_argument, 0, 'h'},
+        {0, 0, 0, 0}
+    };
+
+    optind = 1;
+    int c;
+    while ((c = getopt_long(argc, argv, "l:h", long_options, nullptr)) != -1) {
+        switch (c) {
+            case OPT_TOPOLOGY: topology_path = optarg; break;
+            case OPT_NODE: node = optarg; break;
+            case OPT_IFACE: spec.interface = optarg; break;
+            case OPT_DELAY: spec.delay_us = parse_duration_ms(optarg) * 1000; break;
+            case OPT_JITTER: spec.jitter_us = parse_duration_ms(optarg) * 1000; break;
+            case OPT_LOSS: spec.loss_percent = parse_percent(optarg); break;
+            case OPT_HOLD: hold_ms = parse_duration_ms(optarg); break;
+            case OPT_INTERVAL: interval_ms = parse_duration_ms(optarg); break;
+            case OPT_REPEAT: repeat = std::stoi(optarg); break;
+            case OPT_CLAB_BINARY: clab_binary = optarg; break;
+            case OPT_DRY_RUN: dry_run = true; break;
+            case 'l': log_path = optarg; break;
+            case 'h': print_clab_usage(argv[0]); return 0;
+            default:  print_clab_usage(argv[0]); return 1;
+        }
+    }
+
+    if (topology_path.empty() || node.empty() || spec.interface.empty()) {
+        std::cerr << "❌ 错误: 必须指定 --topology、--node 和 --iface\n";
+        return 1;
+    }
+    if (spec.delay_us < 0 || spec.jitter_us < 0 || spec.loss_percent < 0 || hold_ms <= 0 || repeat <= 0) {
+        std::cerr << "❌ 错误: 无效的故障参数\n";
+        return 1;
+    }
+    if (interval_ms < 0) {
+        interval_ms = hold_ms;
+    }
+
+    ClabTopology topology;
+    std::string error;
+    if (!ClabTopology::load(topology_path, topology, error)) {
+        std::cerr << "❌ 加载拓扑失败: " << error << "\n";
+        return 1;
+    }
+    if (!topology.has_node(node)) {
+        std::cerr << "❌ 错误: 拓扑 " << topology.lab_name << " 中不存在节点 " << node << "\n";
+        return 1;
+    }
+
+    const ClabLink* link = topology.find_link(node, spec.interface);
+    std::string link_name = link ? link->name() : node + ":" + spec.interface;
+    if (!link) {
+        std::cerr << "⚠️  拓扑中没有包含 " << link_name << " 的链路\n";
+    }
+
+    ClabNetemInjector injector(topology, clab_binary);
+
+    std::cout << "🧪 实验室: " << topology.lab_name << ", 故障链路: " << link_name << "\n";
+    std::cout << "   施加: " << format_command(injector.build_set_command(node, spec)) << "\n";
+    std::cout << "   移除: " << format_command(injector.build_reset_command(node, spec.interface)) << "\n";
+    std::cout << "   保持" << hold_ms << "ms, 间隔" << interval_ms << "ms, 共" << repeat << "次\n\n";
+    std::cout << "📡 各节点监控器建议参数(会话将带上clab节点与链路名):\n";
+    for (const auto& name : topology.nodes) {
+        std::cout << "   docker exec -d " << topology.container_name(name)
+                  << " ConvergenceAnalyzer --router-name " << name
+                  << " --tag clab_node=" << name
+                  << " --tag 'clab_link=" << link_name << "'\n";
+    }
+    std::cout << "\n";
+
+    if (dry_run) {
+        return 0;
+    }
+
+    install_stop_signal_handlers();
+
+    std::string router_name = "clab_" + topology.lab_name;
+    Logger logger(log_path);
+    logger.start();
+
+    int exit_code = 0;
+    for (int i = 1; i <= repeat && !stop_requested(); ++i) {
+        if (!injector.apply(node, spec, error)) {
+            std::cerr << "❌ 施加netem失败: " << error << "\n";
+            exit_code = 1;
+            break;
+        }
+        std::cout << "💉 [" << i << "/" << repeat << "] 已在 " << node << ":" << spec.interface << " 施加netem\n";
+        log_fault_event(logger, "fault_injected", router_name, topology, node, spec.interface, link_name, i);
+
+        bool keep_going = interruptible_sleep_ms(hold_ms);
+
+        if (!injector.clear(node, spec.interface, error)) {
+            std::cerr << "❌ 移除netem失败: " << error << "\n";
+            exit_code = 1;
+            break;
+        }
+        std::cout << "🧹 [" << i << "/" << repeat << "] 已移除netem\n";
+        log_fault_event(logger, "fault_cleared", router_name, topology, node, spec.interface, link_name, i);
+
+        if (!keep_going || (i < repeat && !interruptible_sleep_ms(interval_ms))) {
+            break;
+        }
+    }
+
+    logger.stop();
+    return exit_code;
+}
@@ -0,0 +1,25 @@
+#pragma once
+
+#include "clab_topology.h"
+#include "inject.h"
+#include <string>
+#include <vector>
+
+// 通过 `clab tools netem set` 在containerlab节点上施加/移除netem
+class ClabNetemInjector {
+private:
+    const ClabTopology& topology_;
+    std::string clab_binary_;
+
+public:
+    ClabNetemInjector(const ClabTopology& topology, const std::string& clab_binary = "clab");
+
+    std::vector<std::string> build_set_command(const std::string& node, const NetemSpec& spec) const;
+    std::vector<std::string> build_reset_command(const std::string& node, const std::string& interface) const;
+
+    bool apply(const std::string& node, const NetemSpec& spec, std::string& error);
+    bool clear(const std::string& node, const std::string& interface, std::string& error);
+};
+
+// clab 子命令入口
+int clab_main(int argc, char* argv[]);
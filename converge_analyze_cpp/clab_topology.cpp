@@ -0,0 +1,121 @@
+#include "clab_topology.h"
+#include "yaml_lite.h"
+
+namespace {
+
+bool parse_endpoint(const std::string& text, ClabEndpoint& endpoint) {
+    size_t colon = text.find(':');
+    if (colon == std::string::npos || colon == 0 || colon + 1 == text.size()) {
+        return false;
+    }
+    endpoint.node = text.substr(0, colon);
+    endpoint.interface = text.substr(colon + 1);
+    return true;
+}
+
+// 端点既可以是 "node:iface" 字符串，也可以是新版语法的 {node: x, interface: y}
+bool read_endpoint(const YamlNode& node, ClabEndpoint& endpoint) {
+    if (node.is_scalar()) {
+        return parse_endpoint(node.scalar, endpoint);
+    }
+    if (node.is_map()) {
+        endpoint.node = node.get_string("node");
+        endpoint.interface = node.get_string("interface");
+        return !endpoint.node.empty() && !endpoint.interface.empty();
+    }
+    return false;
+}
+
+} // namespace
+
+bool ClabTopology::load(const std::string& path, ClabTopology& topology, std::string& error) {
+    YamlNode root;
+    if (!YamlParser::parse_file(path, root, error)) {
+        return false;
+    }
+    if (!root.is_map()) {
+        error = "topology file must be a mapping";
+        return false;
+    }
+
+    topology.lab_name = root.get_string("name");
+    if (topology.lab_name.empty()) {
+        error = "topology file has no 'name'";
+        return false;
+    }
+    if (const YamlNode* prefix = root.get("prefix")) {
+        topology.prefix = prefix->is_scalar() ? prefix->scalar : "";
+    }
+
+    const YamlNode* topo = root.get("topology");
+    if (topo == nullptr || !topo->is_map()) {
+        error = "topology file has no 'topology' section";
+        return false;
+    }
+
+    if (const YamlNode* nodes = topo->get("nodes")) {
+        for (const auto& entry : nodes->entries) {
+            topology.nodes.push_back(entry.first);
+        }
+    }
+
+    if (const YamlNode* links = topo->get("links")) {
+        for (size_t i = 0; i < links->items.size(); ++i) {
+            const YamlNode* endpoints = links->items[i].get("endpoints");
+            if (endpoints == nullptr || !endpoints->is_sequence()) {
+                continue;  // host/mgmt-net等单端点链路
+            }
+            if (endpoints->items.size() != 2) {
+                continue;
+            }
+            ClabLink link;
+            if (!read_endpoint(endpoints->items[0], link.a) ||
+                !read_endpoint(endpoints->items[1], link.b)) {
+                error = "links[" + std::to_string(i) + "]: invalid endpoint";
+                return false;
+            }
+            topology.links.push_back(link);
+        }
+    }
+
+    return true;
+}
+
+bool ClabTopology::has_node(const std::string& node) const {
+    for (const auto& name : nodes) {
+        if (name == node) {
+            return true;
+        }
+    }
+    return false;
+}
+
+std::string ClabTopology::container_name(const std::string& node) const {
+    if (prefix.empty()) {
+        return node;
+    }
+    if (prefix == "__lab-name") {
+        return lab_name + "-" + node;
+    }
+    return prefix + "-" + lab_name + "-" + node;
+}
+
+const ClabLink* ClabTopology::find_link(const std::string& node, const std::string& interface) const {
+    for (const auto& link : links) {
+        if ((link.a.node == node && link.a.interface == interface) ||
+            (link.b.node == node && link.b.interface == interface)) {
+            return &link;
+        }
+    }
+    return nullptr;
+}
+
+std::vector<const ClabLink*> ClabTopology::links_of(const std::string& node) const {
+    std::vector<const ClabLink*> result;
+    for (const auto& link : links) {
+        if (link.a.node == node || link.b.node == node) {
+            result.push_back(&link);
+        }
+    }
+    return result;
+}
@@ -0,0 +1,43 @@
+#pragma once
+
+#include <string>
+#include <vector>
+
+// containerlab链路端点，如 spine1:e1-1
+struct ClabEndpoint {
+    std::string node;
+    std::string interface;
+
+    std::string to_string() const { return node + ":" + interface; }
+};
+
+// containerlab点对点链路
+struct ClabLink {
+    ClabEndpoint a;
+    ClabEndpoint b;
+
+    // 逻辑链路名，如 "spine1:e1-1 <-> leaf2:e1-1"
+    std::string name() const { return a.to_string() + " <-> " + b.to_string(); }
+};
+
+// containerlab拓扑文件(lab.clab.yml)中与故障注入/事件标注相关的部分
+class ClabTopology {
+public:
+    std::string lab_name;
+    std::string prefix = "clab";  // 容器名前缀，对应顶层prefix字段
+    std::vector<std::string> nodes;
+    std::vector<ClabLink> links;
+
+    static bool load(const std::string& path, ClabTopology& topology, std::string& error);
+
+    bool has_node(const std::string& node) const;
+
+    // 节点对应的容器名，遵循containerlab的prefix规则
+    std::string container_name(const std::string& node) const;
+
+    // 查找包含指定端点的链路，不存在时返回nullptr
+    const ClabLink* find_link(const std::string& node, const std::string& interface) const;
+
+    // 指定节点的所有链路
+    std::vector<const ClabLink*> links_of(const std::string& node) const;
+};
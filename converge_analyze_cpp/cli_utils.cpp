@@ -0,0 +1,184 @@
+#include "cli_utils.h"
+#include <algorithm>
+#include <atomic>
+#include <cctype>
+#include <cerrno>
+#include <chrono>
+#include <cmath>
+#include <cstdlib>
+#include <csignal>
+#include <stdexcept>
+#include <thread>
+
+int64_t parse_duration_ms(const std::string& text) {
+    size_t pos = 0;
+    int64_t value;
+    try {
+        value = std::stoll(text, &pos);
+    } catch (const std::exception&) {
+        return -1;
+    }
+
+    std::string unit = text.substr(pos);
+    if (unit == "ms") return value;
+    if (unit.empty() || unit == "s") return value * 1000;
+    if (unit == "m") return value * 60 * 1000;
+    if (unit == "h") return value * 3600 * 1000;
+    return -1;
+}
+
+bool parse_int64(const std::string& text, int64_t& value) {
+    if (text.empty() || std::isspace(static_cast<unsigned char>(text[0]))) {
+        return false;
+    }
+    char* end = nullptr;
+    errno = 0;
+    long long parsed = std::strtoll(text.c_str(), &end, 10);
+    if (errno != 0 || *end != '\0') {
+        return false;
+    }
+    value = parsed;
+    return true;
+}
+
+bool parse_double(const std::string& text, double& value) {
+    if (text.empty() || std::isspace(static_cast<unsigned char>(text[0]))) {
+        return false;
+    }
+    char* end = nullptr;
+    errno = 0;
+    double parsed = std::strtod(text.c_str(), &end);
+    if (errno != 0 || *end != '\0' || !std::isfinite(parsed)) {
+        return false;
+    }
+    value = parsed;
+    return true;
+}
+
+double parse_percent(const std::string& text) {
+    size_t pos = 0;
+    double value;
+    try {
+        value = std::stod(text, &pos);
+    } catch (const std::exception&) {
+        return -1;
+    }
+
+    std::string unit = text.substr(pos);
+    if (!unit.empty() && unit != "%") {
+        return -1;
+    }
+    if (value < 0 || value > 100) {
+        return -1;
+    }
+    return value;
+}
+
+namespace {
+
+std::atomic<bool> stop_flag{false};
+
+void stop_signal_handler(int) {
+    stop_flag.store(true);
+}
+
+} // namespace
+
+void install_stop_signal_handlers() {
+    signal(SIGINT, stop_signal_handler);
+    signal(SIGTERM, stop_signal_handler);
+}
+
+bool stop_requested() {
+    return stop_flag.load();
+}
+
+bool interruptible_sleep_ms(int64_t ms) {
+    auto deadline = std::chrono::steady_clock::now() + std::chrono::milliseconds(ms);
+    while (std::chrono::steady_clock::now() < deadline) {
+        if (stop_flag.load()) {
+            return false;
+        }
+        std::this_thread::sleep_for(std::chrono::milliseconds(50));
+    }
+    return !stop_flag.load();
+}
+
+std::vector<std::string> strip_options(int argc, char* argv[],
+                                       const std::vector<std::string>& flags,
+                                       const std::vector<std::string>& options_with_value) {
+    std::vector<std::string> result;
+    for (int i = 1; i < argc; ++i) {
+        std::string arg = argv[i];
+        std::string name = arg.substr(0, arg.find('='));
+        bool has_inline_value = name.size() != arg.size();
+
+        if (std::find(flags.begin(), flags.end(), name) != flags.end()) {
+            continue;
+        }
+        if (std::find(options_with_value.begin(), options_with_value.end(), name) != options_with_value.end()) {
+            if (!has_inline_value) {
+                ++i;  // 跳过紧随其后的值
+            }
+            continue;
+        }
+        result.push_back(arg);
+    }
+    return result;
+}
+
+bool options_from_env(const struct option* long_options, const std::vector<std::string>& repeatable,
+                      std::vector<std::string>& args, std::vector<std::string>& applied, std::string& error) {
+    for (const struct option* opt = long_options; opt->name != nullptr; ++opt) {
+        std::string name = opt->name;
+        if (name == "help") {
+            continue;
+        }
+        std::string variable = env_variable_name(name);
+        const char* value = getenv(variable.c_str());
+        if (value == nullptr) {
+            continue;
+        }
+
+        std::string text = value;
+        if (opt->has_arg == no_argument) {
+            std::string lower;
+            for (char ch : text) {
+                lower += static_cast<char>(tolower(static_cast<unsigned char>(ch)));
+            }
+            if (lower == "1" || lower == "true" || lower == "yes" || lower == "on") {
+                args.push_back("--" + name);
+            } else if (!(lower.empty() || lower == "0" || lower == "false" || lower == "no" || lower == "off")) {
+                error = variable + ": expected 1/true/yes/on or 0/false/no/off, got " + text;
+                return false;
+            } else {
+                continue;
+            }
+        } else if (std::find(repeatable.begin(), repeatable.end(), name) != repeatable.end()) {
+            size_t start = 0;
+            while (start <= text.size()) {
+                size_t comma = text.find(',', start);
+                std::string item = text.substr(start, comma == std::string::npos ? std::string::npos : comma - start);
+                if (!item.empty()) {
+                    args.push_back("--" + name + "=" + item);
+                }
+                if (comma == std::string::npos) {
+                    break;
+                }
+                start = comma + 1;
+            }
+        } else {
+            args.push_back("--" + name + "=" + text);
+        }
+        applied.push_back(variable);
+    }
+    return true;
+}
+
+std::string env_variable_name(const std::string& option_name) {
+    std::string variable = "CONVERGE_";
+    for (char ch : option_name) {
+        variable += ch == '-' ? '_' : static_cast<char>(toupper(static_cast<unsigned char>(ch)));
+    }
+    return variable;
+}
@@ -0,0 +1,40 @@
+#pragma once
+
+#include <cstdint>
+#include <getopt.h>
+#include <string>
+#include <vector>
+
+// 解析时长参数，支持 ms/s/m/h 后缀，无后缀按秒计算；非法输入返回-1
+int64_t parse_duration_ms(const std::string& text);
+
+// 解析整数/小数参数，整个字符串须是一个数且不溢出；非法输入返回false，value不变
+bool parse_int64(const std::string& text, int64_t& value);
+bool parse_double(const std::string& text, double& value);
+
+// 解析百分比参数(如 "10%" 或 "10")，返回0-100之间的值；非法输入返回-1
+double parse_percent(const std::string& text);
+
+// 为长时间运行的子命令安装SIGINT/SIGTERM处理，仅设置停止标志
+void install_stop_signal_handlers();
+
+// 是否已收到停止信号
+bool stop_requested();
+
+// 可被停止信号打断的睡眠，返回false表示收到停止请求
+bool interruptible_sleep_ms(int64_t ms);
+
+// 从argv[1..]中去掉指定选项(支持 "--opt value" 与 "--opt=value" 两种写法)，
+// 用于把其余参数原样转发给子进程
+std::vector<std::string> strip_options(int argc, char* argv[],
+                                       const std::vector<std::string>& flags,
+                                       const std::vector<std::string>& options_with_value);
+
+// 把CONVERGE_<选项名>环境变量(选项名大写、'-'换成'_'，如CONVERGE_ROUTER_NAME)转换成"--选项=值"参数，
+// 调用方把它们放在命令行参数之前，命令行上的同名选项因此优先。无参数选项取1/true/yes/on时启用，
+// 0/false/no/off或空值时忽略；repeatable中的选项按逗号拆分为多次出现。applied返回用到的变量名
+bool options_from_env(const struct option* long_options, const std::vector<std::string>& repeatable,
+                      std::vector<std::string>& args, std::vector<std::string>& applied, std::string& error);
+
+// 长选项对应的环境变量名，如 sla-ms -> CONVERGE_SLA_MS
+std::string env_variable_name(const std::string& option_name);
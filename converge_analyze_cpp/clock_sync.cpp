@@ -0,0 +1,283 @@
+#include "clock_sync.h"
+#include "listen_socket.h"
+#include <cerrno>
+#include <chrono>
+#include <cstring>
+#include <ctime>
+#include <netdb.h>
+#include <poll.h>
+#include <sys/socket.h>
+#include <unistd.h>
+
+namespace {
+
+constexpr size_t NTP_PACKET_SIZE = 48;
+constexpr uint64_t NTP_UNIX_EPOCH_DELTA = 2208988800ULL;  // 1900-01-01到1970-01-01的秒数
+constexpr int NTP_MODE_CLIENT = 3;
+constexpr int NTP_MODE_SERVER = 4;
+constexpr int PROBE_TIMEOUT_MS = 500;
+
+// 当前时间(CLOCK_REALTIME)的纳秒数
+int64_t now_ns() {
+    struct timespec ts;
+    clock_gettime(CLOCK_REALTIME, &ts);
+    return static_cast<int64_t>(ts.tv_sec) * 1000000000LL + ts.tv_nsec;
+}
+
+// 64位NTP时间戳：高32位为1900年起的秒数，低32位为秒的小数部分
+void write_timestamp(uint8_t* out, int64_t unix_ns) {
+    uint64_t seconds = static_cast<uint64_t>(unix_ns / 1000000000LL) + NTP_UNIX_EPOCH_DELTA;
+    uint64_t fraction = (static_cast<uint64_t>(unix_ns % 1000000000LL) << 32) / 1000000000ULL;
+    uint64_t value = (seconds << 32) | fraction;
+    for (int i = 7; i >= 0; --i) {
+        out[i] = static_cast<uint8_t>(value & 0xff);
+        value >>= 8;
+    }
+}
+
+int64_t read_timestamp(const uint8_t* in) {
+    uint64_t value = 0;
+    for (int i = 0; i < 8; ++i) {
+        value = (value << 8) | in[i];
+    }
+    int64_t seconds = static_cast<int64_t>(value >> 32) - static_cast<int64_t>(NTP_UNIX_EPOCH_DELTA);
+    int64_t nanos = static_cast<int64_t>(((value & 0xffffffffULL) * 1000000000ULL) >> 32);
+    return seconds * 1000000000LL + nanos;
+}
+
+} // namespace
+
+bool ClockProbe::parse_reference(const std::string& spec, std::string& host, std::string& port) {
+    port = "123";
+    if (!spec.empty() && spec.front() == '[') {
+        size_t close = spec.find(']');
+        if (close == std::string::npos) {
+            return false;
+        }
+        host = spec.substr(1, close - 1);
+        if (close + 1 < spec.size()) {
+            if (spec[close + 1] != ':') {
+                return false;
+            }
+            port = spec.substr(close + 2);
+        }
+    } else {
+        // 不带方括号的IPv6地址整体作为主机
+        size_t colon = spec.find(':');
+        if (colon != std::string::npos && spec.find(':', colon + 1) == std::string::npos) {
+            host = spec.substr(0, colon);
+            port = spec.substr(colon + 1);
+        } else {
+            host = spec;
+        }
+    }
+    return !host.empty() && !port.empty() && port.find_first_not_of("0123456789") == std::string::npos;
+}
+
+ClockProbe::ClockProbe(const std::string& host, const std::string& port, int64_t interval_ms, Callback callback)
+    : host_(host), port_(port), interval_ms_(interval_ms), callback_(std::move(callback)) {
+}
+
+ClockProbe::~ClockProbe() {
+    stop();
+}
+
+void ClockProbe::start() {
+    if (running_.load()) {
+        return;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&ClockProbe::worker_loop, this);
+}
+
+void ClockProbe::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    {
+        std::lock_guard<std::mutex> lock(wait_mutex_);
+        running_.store(false);
+    }
+    wait_cv_.notify_all();
+
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+}
+
+void ClockProbe::worker_loop() {
+    while (running_.load()) {
+        ClockSample sample = measure(host_, port_);
+        if (!running_.load()) {
+            break;
+        }
+        callback_(sample);
+
+        std::unique_lock<std::mutex> lock(wait_mutex_);
+        wait_cv_.wait_for(lock, std::chrono::milliseconds(interval_ms_), [this] {
+            return !running_.load();
+        });
+    }
+}
+
+ClockSample ClockProbe::measure(const std::string& host, const std::string& port) {
+    ClockSample sample;
+    sample.reference = (host.find(':') != std::string::npos ? "[" + host + "]" : host) + ":" + port;
+    sample.timestamp_ms = now_ns() / 1000000;
+
+    struct addrinfo hints;
+    memset(&hints, 0, sizeof(hints));
+    hints.ai_family = AF_UNSPEC;
+    hints.ai_socktype = SOCK_DGRAM;
+    struct addrinfo* result = nullptr;
+    int rc = getaddrinfo(host.c_str(), port.c_str(), &hints, &result);
+    if (rc != 0) {
+        sample.error = "resolve " + host + ": " + gai_strerror(rc);
+        return sample;
+    }
+    int fd = -1;
+    for (struct addrinfo* ai = result; ai != nullptr && fd < 0; ai = ai->ai_next) {
+        fd = socket(ai->ai_family, SOCK_DGRAM | SOCK_CLOEXEC, 0);
+        if (fd >= 0 && connect(fd, ai->ai_addr, ai->ai_addrlen) < 0) {
+            close(fd);
+            fd = -1;
+        }
+    }
+    freeaddrinfo(result);
+    if (fd < 0) {
+        sample.error = "connect " + sample.reference + ": " + strerror(errno);
+        return sample;
+    }
+
+    // 对称往返的假设下 offset = ((T2-T1)+(T3-T4))/2，rtt = (T4-T1)-(T3-T2)
+    int64_t best_rtt_ns = -1;
+    int64_t best_offset_ns = 0;
+    for (int i = 0; i < PROBES_PER_SAMPLE; ++i) {
+        uint8_t request[NTP_PACKET_SIZE] = {0};
+        request[0] = (4 << 3) | NTP_MODE_CLIENT;
+        int64_t t1 = now_ns();
+        write_timestamp(request + 40, t1);
+        if (send(fd, request, sizeof(request), 0) < 0) {
+            sample.error = "send: " + std::string(strerror(errno));
+            continue;
+        }
+
+        int64_t deadline = t1 + PROBE_TIMEOUT_MS * 1000000LL;
+        while (true) {
+            int remaining_ms = static_cast<int>((deadline - now_ns()) / 1000000);
+            struct pollfd pfd = {fd, POLLIN, 0};
+            if (remaining_ms <= 0 || poll(&pfd, 1, remaining_ms) <= 0) {
+                sample.error = "no reply from " + sample.reference;
+                break;
+            }
+            uint8_t reply[NTP_PACKET_SIZE + 64];
+            ssize_t len = recv(fd, reply, sizeof(reply), 0);
+            int64_t t4 = now_ns();
+            if (len < 0) {
+                sample.error = "recv: " + std::string(strerror(errno));
+                break;
+            }
+            // 迟到的上一个请求的回复通过originate时间戳丢弃；stratum为0是拒绝服务的kiss-o'-death
+            if (static_cast<size_t>(len) < NTP_PACKET_SIZE || (reply[0] & 0x07) != NTP_MODE_SERVER ||
+                memcmp(reply + 24, request + 40, 8) != 0) {
+                continue;
+            }
+            if (reply[1] == 0) {
+                sample.error = "reference refused request (kiss-o'-death)";
+                break;
+            }
+            int64_t t2 = read_timestamp(reply + 32);
+            int64_t t3 = read_timestamp(reply + 40);
+            int64_t rtt = (t4 - t1) - (t3 - t2);
+            if (rtt < 0) {
+                rtt = 0;
+            }
+            if (best_rtt_ns < 0 || rtt < best_rtt_ns) {
+                best_rtt_ns = rtt;
+                best_offset_ns = ((t2 - t1) + (t3 - t4)) / 2;
+            }
+            sample.probes++;
+            break;
+        }
+    }
+    close(fd);
+
+    if (best_rtt_ns >= 0) {
+        sample.error.clear();
+        sample.offset_ms = static_cast<double>(best_offset_ns) / 1e6;
+        sample.rtt_ms = static_cast<double>(best_rtt_ns) / 1e6;
+    }
+    return sample;
+}
+
+ClockServer::ClockServer(const std::string& listen_address, int port)
+    : listen_address_(listen_address), port_(port) {
+}
+
+ClockServer::~ClockServer() {
+    stop();
+}
+
+bool ClockServer::start(std::string& error) {
+    if (running_.load()) {
+        return true;
+    }
+
+    socket_fd_ = open_listen_socket(listen_address_, port_, SOCK_DGRAM, error);
+    if (socket_fd_ < 0) {
+        return false;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&ClockServer::worker_loop, this);
+    return true;
+}
+
+void ClockServer::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+    if (socket_fd_ >= 0) {
+        close(socket_fd_);
+        socket_fd_ = -1;
+    }
+}
+
+void ClockServer::worker_loop() {
+    uint8_t request[512];
+    while (running_.load()) {
+        struct pollfd pfd = {socket_fd_, POLLIN, 0};
+        if (poll(&pfd, 1, 200) <= 0 || !(pfd.revents & POLLIN)) {
+            continue;
+        }
+        struct sockaddr_storage peer;
+        socklen_t peer_length = sizeof(peer);
+        ssize_t len = recvfrom(socket_fd_, request, sizeof(request), 0,
+                               reinterpret_cast<struct sockaddr*>(&peer), &peer_length);
+        int64_t received_ns = now_ns();
+        if (len < static_cast<ssize_t>(NTP_PACKET_SIZE) || (request[0] & 0x07) != NTP_MODE_CLIENT) {
+            continue;
+        }
+
+        uint8_t reply[NTP_PACKET_SIZE] = {0};
+        int version = (request[0] >> 3) & 0x07;
+        reply[0] = static_cast<uint8_t>((version << 3) | NTP_MODE_SERVER);
+        reply[1] = 1;                            // stratum: 本机时钟即为参考
+        reply[2] = request[2];                   // poll
+        reply[3] = static_cast<uint8_t>(-20);    // precision约1微秒
+        memcpy(reply + 12, "LOCL", 4);           // reference id
+        write_timestamp(reply + 16, received_ns);
+        memcpy(reply + 24, request + 40, 8);     // originate = 请求的transmit
+        write_timestamp(reply + 32, received_ns);
+        write_timestamp(reply + 40, now_ns());
+        sendto(socket_fd_, reply, sizeof(reply), 0, reinterpret_cast<struct sockaddr*>(&peer), peer_length);
+        request_count_.fetch_add(1);
+    }
+}
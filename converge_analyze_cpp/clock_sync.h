@@ -0,0 +1,83 @@
+#pragma once
+
+#include <atomic>
+#include <condition_variable>
+#include <cstdint>
+#include <functional>
+#include <mutex>
+#include <string>
+#include <thread>
+
+// 一次时钟偏差测量：offset为参考时钟减本地时钟，本地时间戳加上offset即为参考时间
+struct ClockSample {
+    std::string reference;   // HOST:PORT
+    int64_t timestamp_ms = 0;  // 本地测量时间
+    double offset_ms = 0.0;
+    double rtt_ms = 0.0;     // 所选那次请求的往返时间，偏差估计的误差不超过它的一半
+    int probes = 0;          // 本轮收到回复的请求数
+    std::string error;       // 全部请求失败时的原因
+};
+
+// 时钟偏差探测(--clock-reference)：按固定间隔向参考节点发送一组SNTP(RFC 4330)请求，
+// 取往返时间最短的一次估计偏差。参考节点可以是NTP服务器，也可以是开启了--clock-listen的另一个监控器，
+// 各节点对同一参考的偏差之差即为两两之间的偏差，merge据此校正跨节点对齐
+class ClockProbe {
+public:
+    using Callback = std::function<void(const ClockSample&)>;
+    static constexpr int PROBES_PER_SAMPLE = 4;
+
+private:
+    std::string host_;
+    std::string port_;
+    int64_t interval_ms_;
+    Callback callback_;
+
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+    std::mutex wait_mutex_;
+    std::condition_variable wait_cv_;
+
+    void worker_loop();
+
+public:
+    ClockProbe(const std::string& host, const std::string& port, int64_t interval_ms, Callback callback);
+    ~ClockProbe();
+
+    ClockProbe(const ClockProbe&) = delete;
+    ClockProbe& operator=(const ClockProbe&) = delete;
+
+    // 启动后立即测量一次，之后每interval_ms测量一次
+    void start();
+    void stop();
+
+    // 同步测量一次
+    static ClockSample measure(const std::string& host, const std::string& port);
+
+    // HOST、HOST:PORT或[V6]:PORT，默认端口123
+    static bool parse_reference(const std::string& spec, std::string& host, std::string& port);
+};
+
+// 时钟参考(--clock-listen [ADDR:]PORT)：以本机时钟回答SNTP请求，供其他监控器的--clock-reference使用
+class ClockServer {
+private:
+    std::string listen_address_;
+    int port_;
+    int socket_fd_ = -1;
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+    std::atomic<int64_t> request_count_{0};
+
+    void worker_loop();
+
+public:
+    ClockServer(const std::string& listen_address, int port);
+    ~ClockServer();
+
+    ClockServer(const ClockServer&) = delete;
+    ClockServer& operator=(const ClockServer&) = delete;
+
+    bool start(std::string& error);
+    void stop();
+
+    int64_t request_count() const { return request_count_.load(); }
+};
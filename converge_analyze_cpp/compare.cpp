@@ -0,0 +1,286 @@
+#include "compare.h"
+#include <cmath>
+#include <getopt.h>
+#include <iomanip>
+#include <iostream>
+#include <numeric>
+#include <sstream>
+
+namespace {
+
+// 正则化不完全Beta函数的连分式展开（Numerical Recipes betacf）
+double beta_continued_fraction(double a, double b, double x) {
+    const int max_iterations = 200;
+    const double epsilon = 3.0e-12;
+    const double fpmin = 1.0e-300;
+
+    double qab = a + b, qap = a + 1.0, qam = a - 1.0;
+    double c = 1.0;
+    double d = 1.0 - qab * x / qap;
+    if (std::fabs(d) < fpmin) d = fpmin;
+    d = 1.0 / d;
+    double h = d;
+
+    for (int m = 1; m <= max_iterations; ++m) {
+        int m2 = 2 * m;
+        double aa = m * (b - m) * x / ((qam + m2) * (a + m2));
+        d = 1.0 + aa * d;
+        if (std::fabs(d) < fpmin) d = fpmin;
+        c = 1.0 + aa / c;
+        if (std::fabs(c) < fpmin) c = fpmin;
+        d = 1.0 / d;
+        h *= d * c;
+
+        aa = -(a + m) * (qab + m) * x / ((a + m2) * (qap + m2));
+        d = 1.0 + aa * d;
+        if (std::fabs(d) < fpmin) d = fpmin;
+        c = 1.0 + aa / c;
+        if (std::fabs(c) < fpmin) c = fpmin;
+        d = 1.0 / d;
+        double delta = d * c;
+        h *= delta;
+        if (std::fabs(delta - 1.0) < epsilon) {
+            break;
+        }
+    }
+    return h;
+}
+
+double regularized_incomplete_beta(double a, double b, double x) {
+    if (x <= 0.0) return 0.0;
+    if (x >= 1.0) return 1.0;
+
+    double ln_front = std::lgamma(a + b) - std::lgamma(a) - std::lgamma(b) +
+                      a * std::log(x) + b * std::log(1.0 - x);
+    double front = std::exp(ln_front);
+
+    if (x < (a + 1.0) / (a + b + 2.0)) {
+        return front * beta_continued_fraction(a, b, x) / a;
+    }
+    return 1.0 - front * beta_continued_fraction(b, a, 1.0 - x) / b;
+}
+
+double sample_variance(const std::vector<double>& values, double mean) {
+    double sum = 0.0;
+    for (double v : values) {
+        sum += (v - mean) * (v - mean);
+    }
+    return sum / (values.size() - 1);
+}
+
+double delta_pct(double baseline, double candidate) {
+    if (baseline == 0.0) {
+        return candidate == 0.0 ? 0.0 : 100.0;
+    }
+    return (candidate - baseline) / baseline * 100.0;
+}
+
+// 强制结束的会话只统计到监听结束为止，路由事件数与时长都不完整，不参与对比
+std::vector<double> route_event_counts(const ReportData& data) {
+    std::vector<double> values;
+    for (const auto& s : data.sessions) {
+        if (s.completed && !s.forced) {
+            values.push_back(static_cast<double>(s.route_events));
+        }
+    }
+    return values;
+}
+
+std::vector<double> session_durations(const ReportData& data) {
+    std::vector<double> values;
+    for (const auto& s : data.sessions) {
+        if (s.completed && !s.forced) {
+            values.push_back(static_cast<double>(s.duration_ms));
+        }
+    }
+    return values;
+}
+
+} // namespace
+
+double RunComparison::welch_p_value(const std::vector<double>& a, const std::vector<double>& b) {
+    if (a.size() < 2 || b.size() < 2) {
+        return 1.0;
+    }
+
+    double mean_a = std::accumulate(a.begin(), a.end(), 0.0) / a.size();
+    double mean_b = std::accumulate(b.begin(), b.end(), 0.0) / b.size();
+    double var_a = sample_variance(a, mean_a) / a.size();
+    double var_b = sample_variance(b, mean_b) / b.size();
+
+    double se2 = var_a + var_b;
+    if (se2 <= 0.0) {
+        return mean_a == mean_b ? 1.0 : 0.0;
+    }
+
+    double t = (mean_b - mean_a) / std::sqrt(se2);
+    double df = se2 * se2 /
+                (var_a * var_a / (a.size() - 1) + var_b * var_b / (b.size() - 1));
+
+    // 双侧p值 = I_{df/(df+t^2)}(df/2, 1/2)
+    return regularized_incomplete_beta(df / 2.0, 0.5, df / (df + t * t));
+}
+
+std::vector<MetricComparison> RunComparison::compare(const ReportData& baseline,
+                                                     const ReportData& candidate,
+                                                     double tolerance_pct,
+                                                     double alpha) {
+    struct MetricInput {
+        std::string name;
+        std::vector<double> baseline;
+        std::vector<double> candidate;
+    };
+
+    std::vector<MetricInput> inputs = {
+        {"convergence_time_ms",
+         ConvergenceReport::convergence_times(baseline.sessions),
+         ConvergenceReport::convergence_times(candidate.sessions)},
+        {"route_events", route_event_counts(baseline), route_event_counts(candidate)},
+        {"session_duration_ms", session_durations(baseline), session_durations(candidate)},
+    };
+
+    std::vector<MetricComparison> results;
+    for (const auto& input : inputs) {
+        MetricComparison result;
+        result.metric = input.name;
+        result.baseline = ConvergenceReport::compute_stats(input.baseline);
+        result.candidate = ConvergenceReport::compute_stats(input.candidate);
+        result.mean_delta_pct = delta_pct(result.baseline.mean, result.candidate.mean);
+        result.p95_delta_pct = delta_pct(result.baseline.p95, result.candidate.p95);
+        result.p_value = welch_p_value(input.baseline, input.candidate);
+
+        bool significant = result.p_value < alpha;
+        bool worse = result.mean_delta_pct > tolerance_pct || result.p95_delta_pct > tolerance_pct;
+        bool better = result.mean_delta_pct < -tolerance_pct && result.p95_delta_pct <= 0.0;
+        result.regression = significant && worse;
+        result.improvement = significant && better;
+        results.push_back(result);
+    }
+
+    return results;
+}
+
+namespace {
+
+std::string fmt(double value, int precision = 1) {
+    std::ostringstream oss;
+    oss << std::fixed << std::setprecision(precision) << value;
+    return oss.str();
+}
+
+std::string signed_pct(double value) {
+    return (value > 0 ? "+" : "") + fmt(value) + "%";
+}
+
+} // namespace
+
+std::string RunComparison::render_table(const std::vector<MetricComparison>& results) {
+    std::ostringstream out;
+    out << std::left << std::setw(22) << "metric"
+        << std::right << std::setw(12) << "base mean" << std::setw(12) << "cand mean"
+        << std::setw(10) << "Δmean" << std::setw(12) << "base p95" << std::setw(12) << "cand p95"
+        << std::setw(10) << "Δp95" << std::setw(10) << "p-value" << "  flag\n";
+    for (const auto& r : results) {
+        std::string flag = r.regression ? "REGRESSION" : (r.improvement ? "improved" : "-");
+        out << std::left << std::setw(22) << r.metric << std::right
+            << std::setw(12) << fmt(r.baseline.mean) << std::setw(12) << fmt(r.candidate.mean)
+            << std::setw(10) << signed_pct(r.mean_delta_pct)
+            << std::setw(12) << fmt(r.baseline.p95) << std::setw(12) << fmt(r.candidate.p95)
+            << std::setw(10) << signed_pct(r.p95_delta_pct)
+            << std::setw(10) << fmt(r.p_value, 4) << "  " << flag << "\n";
+    }
+    return out.str();
+}
+
+namespace {
+
+void print_compare_usage(const char* program_name) {
+    std::cout << "用法: " << program_name << " compare BASELINE_LOG CANDIDATE_LOG [选项]\n\n";
+    std::cout << "对比两次运行(例如修改FRR定时器前后)的收敛指标差异\n\n";
+    std::cout << "选项:\n";
+    std::cout << "      --tolerance PCT   均值或P95恶化超过该百分比且显著时判定为回归(默认10)\n";
+    std::cout << "      --alpha P         显著性水平(默认0.05)\n";
+    std::cout << "      --format FORMAT   输出格式: text(默认)、json\n";
+    std::cout << "  -h, --help            显示此帮助信息\n\n";
+    std::cout << "存在回归时以退出码2结束。\n";
+}
+
+} // namespace
+
+int compare_main(int argc, char* argv[]) {
+    double tolerance_pct = 10.0;
+    double alpha = 0.05;
+    std::string format = "text";
+
+    static struct option long_options[] = {
+        {"tolerance", required_argument, 0, 't'},
+        {"alpha", required_argument, 0, 'a'},
+        {"format", required_argument, 0, 'f'},
+        {"help", no_argument, 0, 'h'},
+        {0, 0, 0, 0}
+    };
+
+    optind = 1;
+    int c;
+    while ((c = getopt_long(argc, argv, "t:a:f:h", long_options, nullptr)) != -1) {
+        switch (c) {
+            case 't': tolerance_pct = std::stod(optarg); break;
+            case 'a': alpha = std::stod(optarg); break;
+            case 'f': format = optarg; break;
+            case 'h': print_compare_usage(argv[0]); return 0;
+            default:  print_compare_usage(argv[0]); return 1;
+        }
+    }
+
+    if (argc - optind != 2) {
+        print_compare_usage(argv[0]);
+        return 1;
+    }
+
+    std::string baseline_path = argv[optind];
+    std::string candidate_path = argv[optind + 1];
+
+    ReportData baseline, candidate;
+    std::string error;
+    if (!ConvergenceReport::load(baseline_path, baseline, error) ||
+        !ConvergenceReport::load(candidate_path, candidate, error)) {
+        std::cerr << "❌ " << error << "\n";
+        return 1;
+    }
+
+    auto results = RunComparison::compare(baseline, candidate, tolerance_pct, alpha);
+    bool any_regression = false;
+    for (const auto& r : results) {
+        any_regression = any_regression || r.regression;
+    }
+
+    if (format == "json") {
+        std::cout << "{\"baseline\":\"" << Logger::escape_json_string(baseline_path)
+                  << "\",\"candidate\":\"" << Logger::escape_json_string(candidate_path)
+                  << "\",\"tolerance_pct\":" << fmt(tolerance_pct, 3)
+                  << ",\"alpha\":" << fmt(alpha, 3) << ",\"metrics\":[";
+        for (size_t i = 0; i < results.size(); ++i) {
+            const auto& r = results[i];
+            std::cout << (i ? "," : "") << "{\"metric\":\"" << r.metric << "\""
+                      << ",\"baseline_count\":" << r.baseline.count
+                      << ",\"candidate_count\":" << r.candidate.count
+                      << ",\"baseline_mean\":" << fmt(r.baseline.mean, 3)
+                      << ",\"candidate_mean\":" << fmt(r.candidate.mean, 3)
+                      << ",\"baseline_p95\":" << fmt(r.baseline.p95, 3)
+                      << ",\"candidate_p95\":" << fmt(r.candidate.p95, 3)
+                      << ",\"mean_delta_pct\":" << fmt(r.mean_delta_pct, 3)
+                      << ",\"p95_delta_pct\":" << fmt(r.p95_delta_pct, 3)
+                      << ",\"p_value\":" << fmt(r.p_value, 6)
+                      << ",\"regression\":" << (r.regression ? "true" : "false")
+                      << ",\"improvement\":" << (r.improvement ? "true" : "false") << "}";
+        }
+        std::cout << "],\"regression\":" << (any_regression ? "true" : "false") << "}\n";
+    } else {
+        std::cout << "基线: " << baseline_path << " (" << baseline.sessions.size() << " 会话)\n";
+        std::cout << "对比: " << candidate_path << " (" << candidate.sessions.size() << " 会话)\n\n";
+        std::cout << RunComparison::render_table(results);
+        std::cout << "\n" << (any_regression ? "❌ 检测到收敛回归" : "✅ 未检测到显著回归") << "\n";
+    }
+
+    return any_regression ? 2 : 0;
+}
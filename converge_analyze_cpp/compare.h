@@ -0,0 +1,35 @@
+#pragma once
+
+#include <string>
+#include <vector>
+#include "report.h"
+
+// 单项指标的对比结果
+struct MetricComparison {
+    std::string metric;
+    DistributionStats baseline;
+    DistributionStats candidate;
+    double mean_delta_pct = 0.0;
+    double p95_delta_pct = 0.0;
+    double p_value = 1.0;       // Welch t检验双侧p值
+    bool regression = false;
+    bool improvement = false;
+};
+
+class RunComparison {
+public:
+    // tolerance_pct: 均值或P95恶化超过该百分比且显著时判定为回归
+    static std::vector<MetricComparison> compare(const ReportData& baseline,
+                                                 const ReportData& candidate,
+                                                 double tolerance_pct,
+                                                 double alpha);
+
+    // Welch t检验的双侧p值
+    static double welch_p_value(const std::vector<double>& a, const std::vector<double>& b);
+
+    // 逐项指标的对比表格(text格式)
+    static std::string render_table(const std::vector<MetricComparison>& results);
+};
+
+// compare 子命令入口
+int compare_main(int argc, char* argv[]);
@@ -0,0 +1,90 @@
+#include "console_detail.h"
+#include "i18n.h"
+
+bool parse_console_detail(const std::string& text, ConsoleDetail& detail) {
+    if (text == "off") {
+        detail = ConsoleDetail::OFF;
+    } else if (text == "summary") {
+        detail = ConsoleDetail::SUMMARY;
+    } else if (text == "full") {
+        detail = ConsoleDetail::FULL;
+    } else {
+        return false;
+    }
+    return true;
+}
+
+ConsoleThrottle::ConsoleThrottle(ConsoleDetail detail, int max_lines_per_second)
+    : detail_(detail), max_lines_per_second_(max_lines_per_second) {}
+
+void ConsoleThrottle::roll_locked(int64_t now_ms) {
+    int64_t second = now_ms / 1000;
+    if (second == window_second_) {
+        return;
+    }
+    if (window_route_events_ > 0 || window_suppressed_ > 0) {
+        pending_seconds_++;
+        pending_route_events_ += window_route_events_;
+        pending_suppressed_ += window_suppressed_;
+    }
+    window_second_ = second;
+    window_lines_ = 0;
+    window_route_events_ = 0;
+    window_suppressed_ = 0;
+}
+
+bool ConsoleThrottle::allow_line(int64_t now_ms) {
+    if (detail_ != ConsoleDetail::SUMMARY) {
+        return detail_ == ConsoleDetail::FULL;
+    }
+    std::lock_guard<std::mutex> lock(mutex_);
+    roll_locked(now_ms);
+    if (window_lines_ < max_lines_per_second_) {
+        window_lines_++;
+        return true;
+    }
+    window_suppressed_++;
+    return false;
+}
+
+bool ConsoleThrottle::note_route_event(int64_t now_ms, int session_id) {
+    if (detail_ != ConsoleDetail::SUMMARY) {
+        return detail_ == ConsoleDetail::FULL;
+    }
+    std::lock_guard<std::mutex> lock(mutex_);
+    roll_locked(now_ms);
+    window_route_events_++;
+    session_id_ = session_id;
+    return false;
+}
+
+std::string ConsoleThrottle::flush(int64_t now_ms, bool all) {
+    if (detail_ != ConsoleDetail::SUMMARY) {
+        return "";
+    }
+    std::lock_guard<std::mutex> lock(mutex_);
+    roll_locked(now_ms);
+    if (all) {
+        roll_locked(now_ms + 1000);
+    }
+    if (pending_route_events_ == 0 && pending_suppressed_ == 0) {
+        return "";
+    }
+
+    std::string line = "   📈 ";
+    if (pending_route_events_ > 0) {
+        line += tr("会话 #", "Session #") + std::to_string(session_id_) + " +" +
+                std::to_string(pending_route_events_) + tr(" 路由事件", " route events");
+    }
+    if (pending_suppressed_ > 0) {
+        line += (pending_route_events_ > 0 ? tr("，", ", ") : "") +
+                std::string(tr("省略 ", "suppressed ")) + std::to_string(pending_suppressed_) +
+                tr(" 行明细", " detail lines");
+    }
+    line += pending_seconds_ > 1 ? tr(" (", " (over ") + std::to_string(pending_seconds_) + tr("秒内)", " seconds)")
+                                 : std::string(tr(" (最近1秒)", " (last second)"));
+    pending_seconds_ = 0;
+    pending_route_events_ = 0;
+    pending_suppressed_ = 0;
+    return line;
+}
@@ -0,0 +1,49 @@
+#pragma once
+
+#include <cstdint>
+#include <mutex>
+#include <string>
+
+// 会话内逐条事件的控制台输出(--console-detail)，结构化日志不受影响：
+//   off      只输出会话开始/结束等状态行
+//   summary  链路、FRR日志、BGP等明细行每秒最多若干条，另按秒输出会话内路由事件数 (默认)
+//   full     输出全部明细行，并逐条输出会话内路由事件
+enum class ConsoleDetail { OFF, SUMMARY, FULL };
+
+// 解析 off/summary/full
+bool parse_console_detail(const std::string& text, ConsoleDetail& detail);
+
+// 按秒统计明细行与路由事件；可从任意线程调用，摘要由收敛检查线程定期取出并输出
+class ConsoleThrottle {
+private:
+    ConsoleDetail detail_;
+    int max_lines_per_second_;
+
+    std::mutex mutex_;
+    int64_t window_second_ = -1;
+    int window_lines_ = 0;
+    // 已结束但尚未输出的秒
+    int64_t pending_seconds_ = 0;
+    int64_t pending_route_events_ = 0;
+    int64_t pending_suppressed_ = 0;
+    int64_t window_route_events_ = 0;
+    int64_t window_suppressed_ = 0;
+    int session_id_ = 0;
+
+    // 进入新的一秒时把上一秒的计数移入pending，调用方持有mutex_
+    void roll_locked(int64_t now_ms);
+
+public:
+    static constexpr int DEFAULT_MAX_LINES_PER_SECOND = 10;
+
+    explicit ConsoleThrottle(ConsoleDetail detail, int max_lines_per_second = DEFAULT_MAX_LINES_PER_SECOND);
+
+    ConsoleDetail detail() const { return detail_; }
+
+    // 是否输出一条明细行；summary下超出每秒上限的只计数
+    bool allow_line(int64_t now_ms);
+    // 计入一条会话内路由事件，返回是否应逐条输出(仅full)
+    bool note_route_event(int64_t now_ms, int session_id);
+    // 返回已结束各秒的摘要行(不含换行)，没有内容时返回空；all为true时当前秒也一并结束
+    std::string flush(int64_t now_ms, bool all);
+};
@@ -0,0 +1,54 @@
+#include "container_discovery.h"
+#include "subprocess.h"
+#include <algorithm>
+#include <sstream>
+
+namespace {
+
+std::string trim_line(const std::string& s) {
+    size_t end = s.find_last_not_of(" \t\r\n");
+    return end == std::string::npos ? "" : s.substr(0, end + 1);
+}
+
+} // namespace
+
+bool discover_containers(const std::string& runtime, const std::string& name_prefix,
+                         std::vector<NetnsTarget>& targets, std::string& error) {
+    std::string output;
+    int code = run_command({runtime, "ps", "--format", "{{.Names}}"}, output, error);
+    if (code != 0) {
+        if (error.empty()) {
+            error = runtime + " ps: " + trim_line(output);
+        }
+        return false;
+    }
+
+    std::vector<std::string> names;
+    std::istringstream iss(output);
+    std::string line;
+    while (std::getline(iss, line)) {
+        line = trim_line(line);
+        if (line.empty() || line.compare(0, name_prefix.size(), name_prefix) != 0) {
+            continue;
+        }
+        names.push_back(line);
+    }
+    std::sort(names.begin(), names.end());
+
+    for (const auto& name : names) {
+        std::string pid_output;
+        code = run_command({runtime, "inspect", "-f", "{{.State.Pid}}", name}, pid_output, error);
+        if (code != 0) {
+            if (error.empty()) {
+                error = runtime + " inspect " + name + ": " + trim_line(pid_output);
+            }
+            return false;
+        }
+        std::string pid = trim_line(pid_output);
+        if (pid.empty() || pid == "0" || pid.find_first_not_of("0123456789") != std::string::npos) {
+            continue;  // 容器已退出或输出异常
+        }
+        targets.push_back({name, "/proc/" + pid + "/ns/net"});
+    }
+    return true;
+}
@@ -0,0 +1,10 @@
+#pragma once
+
+#include "netns.h"
+#include <string>
+#include <vector>
+
+// 通过docker兼容CLI(docker/nerdctl/podman)发现运行中的容器，
+// 返回以容器名命名、指向 /proc/PID/ns/net 的监控目标
+bool discover_containers(const std::string& runtime, const std::string& name_prefix,
+                         std::vector<NetnsTarget>& targets, std::string& error);
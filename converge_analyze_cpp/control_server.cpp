@@ -0,0 +1,167 @@
+#include "control_server.h"
+#include <cerrno>
+#include <cstring>
+#include <poll.h>
+#include <sstream>
+#include <sys/socket.h>
+#include <sys/stat.h>
+#include <sys/un.h>
+#include <unistd.h>
+
+ControlServer::ControlServer(const std::string& path, Handler handler)
+    : path_(path), handler_(std::move(handler)) {}
+
+ControlServer::~ControlServer() {
+    stop();
+}
+
+bool ControlServer::start(std::string& error) {
+    if (running_.load()) {
+        return true;
+    }
+
+    struct sockaddr_un addr;
+    memset(&addr, 0, sizeof(addr));
+    addr.sun_family = AF_UNIX;
+    if (path_.empty() || path_.size() >= sizeof(addr.sun_path)) {
+        error = "invalid socket path " + path_;
+        return false;
+    }
+    memcpy(addr.sun_path, path_.c_str(), path_.size());
+
+    // 上次运行异常退出时留下的套接字文件：连不上才删除，避免抢占另一个监控器
+    struct stat st;
+    if (lstat(path_.c_str(), &st) == 0) {
+        if (!S_ISSOCK(st.st_mode)) {
+            error = path_ + " exists and is not a socket";
+            return false;
+        }
+        int probe = socket(AF_UNIX, SOCK_STREAM | SOCK_CLOEXEC, 0);
+        bool in_use = probe >= 0 &&
+                      connect(probe, reinterpret_cast<struct sockaddr*>(&addr), sizeof(addr)) == 0;
+        if (probe >= 0) {
+            close(probe);
+        }
+        if (in_use) {
+            error = path_ + " is in use by another process";
+            return false;
+        }
+        unlink(path_.c_str());
+    }
+
+    listen_fd_ = socket(AF_UNIX, SOCK_STREAM | SOCK_CLOEXEC, 0);
+    if (listen_fd_ < 0) {
+        error = "socket: " + std::string(strerror(errno));
+        return false;
+    }
+
+    // 命令可以结束会话、清空统计，只允许属主访问
+    mode_t old_mask = umask(0077);
+    int bound = bind(listen_fd_, reinterpret_cast<struct sockaddr*>(&addr), sizeof(addr));
+    umask(old_mask);
+    if (bound < 0 || listen(listen_fd_, 8) < 0) {
+        error = "bind/listen " + path_ + ": " + strerror(errno);
+        close(listen_fd_);
+        listen_fd_ = -1;
+        return false;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&ControlServer::worker_loop, this);
+    return true;
+}
+
+void ControlServer::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+    if (listen_fd_ >= 0) {
+        close(listen_fd_);
+        listen_fd_ = -1;
+        unlink(path_.c_str());
+    }
+}
+
+void ControlServer::worker_loop() {
+    struct Connection {
+        int fd;
+        std::string buffer;
+    };
+    std::vector<Connection> connections;
+
+    while (running_.load()) {
+        std::vector<struct pollfd> fds;
+        fds.push_back({listen_fd_, POLLIN, 0});
+        for (const auto& conn : connections) {
+            fds.push_back({conn.fd, POLLIN, 0});
+        }
+
+        int ready = poll(fds.data(), fds.size(), 200);
+        if (ready <= 0) {
+            continue;
+        }
+
+        if (fds[0].revents & POLLIN) {
+            int fd = accept4(listen_fd_, nullptr, nullptr, SOCK_CLOEXEC);
+            if (fd >= 0) {
+                connections.push_back({fd, {}});
+            }
+        }
+
+        for (size_t i = 1; i < fds.size(); ++i) {
+            if (!(fds[i].revents & (POLLIN | POLLHUP | POLLERR))) {
+                continue;
+            }
+            Connection& conn = connections[i - 1];
+            char chunk[1024];
+            ssize_t len = read(conn.fd, chunk, sizeof(chunk));
+            bool closed = len <= 0;
+            if (len > 0) {
+                conn.buffer.append(chunk, static_cast<size_t>(len));
+            } else if (len == 0 && !conn.buffer.empty()) {
+                // 对端关闭前发送的最后一条命令可以不带换行
+                conn.buffer += '\n';
+            }
+
+            size_t newline;
+            while (conn.fd >= 0 && (newline = conn.buffer.find('\n')) != std::string::npos) {
+                std::string line = conn.buffer.substr(0, newline);
+                conn.buffer.erase(0, newline + 1);
+
+                std::istringstream iss(line);
+                std::vector<std::string> args;
+                std::string word;
+                while (iss >> word) {
+                    args.push_back(word);
+                }
+                if (args.empty()) {
+                    continue;
+                }
+
+                command_count_.fetch_add(1);
+                std::string response = handler_(args) + "\n";
+                if (send(conn.fd, response.data(), response.size(), MSG_NOSIGNAL) < 0) {
+                    close(conn.fd);
+                    conn.fd = -1;
+                }
+            }
+            if (conn.fd >= 0 && (closed || conn.buffer.size() > MAX_LINE_LENGTH)) {
+                close(conn.fd);
+                conn.fd = -1;
+            }
+        }
+
+        for (auto it = connections.begin(); it != connections.end();) {
+            it = it->fd < 0 ? connections.erase(it) : it + 1;
+        }
+    }
+
+    for (const auto& conn : connections) {
+        close(conn.fd);
+    }
+}
@@ -0,0 +1,43 @@
+#pragma once
+
+#include <atomic>
+#include <functional>
+#include <string>
+#include <thread>
+#include <vector>
+
+// 控制套接字(--control-socket)：在Unix流套接字上按行接收命令，每条命令回复一行JSON。
+// 命令按空白拆分后交给回调处理，回调在监听线程中调用。
+//   echo status | socat - UNIX-CONNECT:/run/convergence.sock
+class ControlServer {
+public:
+    // 参数为拆分后的命令(至少一个元素)，返回单行JSON
+    using Handler = std::function<std::string(const std::vector<std::string>&)>;
+
+private:
+    std::string path_;
+    Handler handler_;
+    int listen_fd_ = -1;
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+    std::atomic<int64_t> command_count_{0};
+
+    static constexpr size_t MAX_LINE_LENGTH = 4096;
+
+    void worker_loop();
+
+public:
+    ControlServer(const std::string& path, Handler handler);
+    ~ControlServer();
+
+    ControlServer(const ControlServer&) = delete;
+    ControlServer& operator=(const ControlServer&) = delete;
+
+    // 已存在的同名套接字若无人监听则被替换，仍在使用时失败
+    bool start(std::string& error);
+    // 停止监听并删除套接字文件
+    void stop();
+
+    const std::string& path() const { return path_; }
+    int64_t command_count() const { return command_count_.load(); }
+};
@@ -1,12 +1,23 @@
 #include "convergence_monitor.h"
+#include "run_manifest.h"
+#include "report.h"
+#include "route_tables.h"
+#include "i18n.h"
+#include "yaml_lite.h"
+#include "timestamp_format.h"
 #include <chrono>
+#include <fstream>
 #include <iostream>
 #include <iomanip>
+#include <map>
 #include <ratio>
 #include <sstream>
 #include <algorithm>
+#include <arpa/inet.h>
+#include <cstring>
 #include <cmath>
-#include <pwd.h>
+#include <sys/resource.h>
+#include <sys/stat.h>
 #include <unistd.h>
 #include <uuid/uuid.h>
 #include <numeric>
@@ -22,6 +33,27 @@
     #define HAS_SHARED_MUTEX 0
 #endif
 
+// 从/proc/self/status读取常驻内存、峰值与线程数
+static void read_process_usage(JsonObject& status) {
+    std::ifstream file("/proc/self/status");
+    std::string line;
+    while (std::getline(file, line)) {
+        std::istringstream iss(line);
+        std::string key;
+        int64_t value = 0;
+        if (!(iss >> key >> value)) {
+            continue;
+        }
+        if (key == "VmRSS:") {
+            status["rss_kb"] = value;
+        } else if (key == "VmHWM:") {
+            status["peak_rss_kb"] = value;
+        } else if (key == "Threads:") {
+            status["threads"] = value;
+        }
+    }
+}
+
 // ConvergenceSession 实现
 ConvergenceSession::ConvergenceSession(int id, int64_t netem_time, 
                                      const std::unordered_map<std::string, std::string>& netem_info_map)
@@ -29,12 +61,81 @@ ConvergenceSession::ConvergenceSession(int id, int64_t netem_time,
 }
 
 void ConvergenceSession::add_route_event(int64_t timestamp, const std::string& event_type,
-                                        const std::unordered_map<std::string, std::string>& route_info) {
+                                        const std::unordered_map<std::string, std::string>& route_info,
+                                        bool keep_detail) {
     std::lock_guard<std::mutex> lock(mutex_);
 
     int64_t offset = timestamp - netem_event_time;
-    route_events.emplace_back(timestamp, event_type, route_info, offset);
+    if (keep_detail) {
+        route_events.emplace_back(timestamp, event_type, route_info, offset);
+    }
+    auto iface_it = route_info.find("interface");
+    if (iface_it != route_info.end()) {
+        route_interfaces.insert(iface_it->second);
+    }
+    route_event_count_++;
+    event_type_counts[event_type]++;
+    std::string route_class = RouteEvent::classify(route_info);
+    if (!route_class.empty()) {
+        route_class_counts[route_class]++;
+    }
+    auto origin_it = route_info.find("origin");
+    if (origin_it != route_info.end()) {
+        route_origin_counts[origin_it->second]++;
+    }
+    auto family_it = route_info.find("family");
+    if (family_it != route_info.end() &&
+        (family_it->second == std::to_string(AF_INET) || family_it->second == std::to_string(AF_INET6))) {
+        std::string family = family_it->second == std::to_string(AF_INET) ? "ipv4" : "ipv6";
+        family_event_counts[family]++;
+        family_last_offsets[family] = std::max<int64_t>(0, offset);
+    }
     last_route_event_time = timestamp;
+
+    size_t second = static_cast<size_t>(std::max<int64_t>(0, offset) / 1000);
+    if (churn_per_second.size() <= second) {
+        churn_per_second.resize(second + 1, 0);
+    }
+    churn_per_second[second]++;
+}
+
+void ConvergenceSession::note_default_route(int64_t timestamp, const std::string& event_type,
+                                            const std::string& nexthop, const std::string& previous_nexthop) {
+    std::lock_guard<std::mutex> lock(mutex_);
+
+    int64_t offset = timestamp - netem_event_time;
+    default_route_events++;
+    if (default_nexthop_before.empty()) {
+        default_nexthop_before = previous_nexthop;
+    }
+    // 丢弃类默认路由(如blackhole default)不算恢复
+    if (event_type == "route_del" || nexthop.empty()) {
+        if (!default_lost_offset.has_value() || default_restored_offset.has_value()) {
+            default_lost_offset = offset;
+        }
+        default_restored_offset.reset();
+        default_nexthop_after.clear();
+    } else {
+        default_restored_offset = offset;
+        default_nexthop_after = nexthop;
+    }
+}
+
+bool ConvergenceSession::default_route_down() const {
+    std::lock_guard<std::mutex> lock(mutex_);
+    return default_lost_offset.has_value() && !default_restored_offset.has_value();
+}
+
+size_t ConvergenceSession::release_route_events() {
+    std::lock_guard<std::mutex> lock(mutex_);
+    size_t released = route_events.size();
+    std::vector<RouteEvent>().swap(route_events);
+    return released;
+}
+
+size_t ConvergenceSession::retained_event_count() const {
+    std::lock_guard<std::mutex> lock(mutex_);
+    return route_events.size();
 }
 
 bool ConvergenceSession::check_convergence(int64_t quiet_period_ms) {
@@ -72,9 +173,46 @@ bool ConvergenceSession::check_convergence(int64_t quiet_period_ms) {
     return false;
 }
 
+bool ConvergenceSession::dual_stack() const {
+    std::lock_guard<std::mutex> lock(mutex_);
+    return family_event_counts.count("ipv4") > 0 && family_event_counts.count("ipv6") > 0;
+}
+
+bool ConvergenceSession::mark_converged(int64_t timestamp, const std::string& nexthop) {
+    std::lock_guard<std::mutex> lock(mutex_);
+
+    if (is_converged.load()) {
+        return false;
+    }
+
+    is_converged.store(true);
+    convergence_detected_time = std::chrono::duration_cast<std::chrono::milliseconds>(
+        std::chrono::system_clock::now().time_since_epoch()).count();
+    convergence_time = timestamp - netem_event_time;
+    converged_prefix_nexthop = nexthop;
+    return true;
+}
+
+bool ConvergenceSession::exceeds_max_duration(int64_t now_ms, int64_t max_session_ms) const {
+    return max_session_ms > 0 && !is_converged.load() && now_ms - netem_event_time >= max_session_ms;
+}
+
+void ConvergenceSession::time_out() {
+    check_convergence(0);
+    timed_out = true;
+}
+
+void ConvergenceSession::force_finish() {
+    check_convergence(0);
+    std::lock_guard<std::mutex> lock(mutex_);
+    // 最后一条路由事件的时刻不是收敛时刻
+    convergence_time.reset();
+    forced = true;
+}
+
 int ConvergenceSession::get_route_event_count() const {
     std::lock_guard<std::mutex> lock(mutex_);
-    return route_events.size();
+    return route_event_count_;
 }
 
 int64_t ConvergenceSession::get_session_duration() const {
@@ -89,13 +227,93 @@ int64_t ConvergenceSession::get_session_duration() const {
     return current_time - netem_event_time;
 }
 
+void ConvergenceSession::restore_summary(int route_events, int64_t duration_ms) {
+    std::lock_guard<std::mutex> lock(mutex_);
+    route_event_count_ = route_events;
+    convergence_detected_time = netem_event_time + duration_ms;
+}
+
+void ConvergenceSession::add_event_counts_to_json(JsonObject& obj) const {
+    std::lock_guard<std::mutex> lock(mutex_);
+    // 逐秒路由变化数，用于在报告中显示收敛的形状(突发与长尾)
+    if (!churn_per_second.empty()) {
+        std::string series;
+        int64_t peak = 0, peak_second = 0;
+        for (size_t i = 0; i < churn_per_second.size(); ++i) {
+            series += (i ? "," : "") + std::to_string(churn_per_second[i]);
+            if (churn_per_second[i] > peak) {
+                peak = churn_per_second[i];
+                peak_second = static_cast<int64_t>(i);
+            }
+        }
+        obj["churn_per_second"] = series;
+        obj["peak_churn_rate"] = peak;
+        obj["peak_churn_second"] = peak_second;
+    }
+    // 按类型的路由事件数：route_add为新增可达性，route_replace为已有路由的下一跳等被改指，
+    // rule_*为策略路由规则，mroute_*为组播转发表项，fdb_*为二层转发表项，tunnel_*为隧道接口，
+    // lag_*/lacp_*为bond/team成员切换
+    for (const char* type : {"route_add", "route_del", "route_replace", "rule_add", "rule_del",
+                             "mroute_add", "mroute_del", "fdb_add", "fdb_del", "fdb_move",
+                             "tunnel_add", "tunnel_del", "tunnel_up", "tunnel_down", "tunnel_endpoint_change",
+                             "lag_failover", "lag_member_change", "lacp_state_change"}) {
+        auto count_it = event_type_counts.find(type);
+        if (count_it != event_type_counts.end()) {
+            obj[std::string(type) + "_events"] = count_it->second;
+        }
+    }
+    // 丢弃类路由按类别计数，如blackhole_route_events
+    for (const auto& count : route_class_counts) {
+        obj[count.first + "_route_events"] = count.second;
+    }
+    // 按来源计数，区分接口地址变化带来的直连路由抖动与路由协议安装的路由，如connected_origin_events
+    for (const auto& count : route_origin_counts) {
+        obj[count.first + "_origin_events"] = count.second;
+    }
+}
+
+void ConvergenceSession::add_default_route_to_json(JsonObject& obj) const {
+    std::lock_guard<std::mutex> lock(mutex_);
+    if (default_route_events == 0) {
+        return;
+    }
+    obj["default_route_events"] = static_cast<int64_t>(default_route_events);
+    if (default_lost_offset.has_value()) {
+        obj["default_route_lost_ms"] = default_lost_offset.value();
+    }
+    obj["default_route_restored"] = default_restored_offset.has_value();
+    if (default_restored_offset.has_value()) {
+        obj["default_route_restored_ms"] = default_restored_offset.value();
+    }
+    obj["default_nexthop_before"] = default_nexthop_before;
+    obj["default_nexthop_after"] = default_nexthop_after;
+}
+
+void ConvergenceSession::add_dual_stack_to_json(JsonObject& obj) const {
+    if (!dual_stack()) {
+        return;
+    }
+    std::lock_guard<std::mutex> lock(mutex_);
+    int64_t ipv4_ms = family_last_offsets.at("ipv4");
+    int64_t ipv6_ms = family_last_offsets.at("ipv6");
+    obj["ipv4_route_events"] = family_event_counts.at("ipv4");
+    obj["ipv6_route_events"] = family_event_counts.at("ipv6");
+    obj["ipv4_convergence_ms"] = ipv4_ms;
+    obj["ipv6_convergence_ms"] = ipv6_ms;
+    obj["dual_stack_gap_ms"] = std::abs(ipv6_ms - ipv4_ms);
+    obj["slower_family"] = ipv6_ms > ipv4_ms ? "ipv6" : ipv4_ms > ipv6_ms ? "ipv4" : "none";
+}
+
 // ConvergenceMonitor 实现
-ConvergenceMonitor::ConvergenceMonitor(int64_t convergence_threshold_ms,
-                                     const std::string& router_name,
-                                     const std::string& log_path)
-    : router_name_(router_name),
-      convergence_threshold_ms_(convergence_threshold_ms),
+ConvergenceMonitor::ConvergenceMonitor(const MonitorConfig& config)
+    : config_(config),
+      router_name_(config.router_name),
+      convergence_threshold_ms_(config.convergence_threshold_ms),
       monitoring_start_time_(get_current_timestamp_ms()) {
+
+    session_tags_ = config_.session_tags;
+    filter_ = config_.filter;
+    console_throttle_ = std::make_unique<ConsoleThrottle>(config_.console_detail);
     
     // 生成监控器ID
     uuid_t uuid;
@@ -105,22 +323,266 @@ ConvergenceMonitor::ConvergenceMonitor(int64_t convergence_threshold_ms,
     monitor_id_ = std::string(uuid_str);
     
     // 创建日志记录器
-    logger_ = std::make_unique<Logger>(log_path);
+    logger_ = std::make_unique<Logger>(config_.log_path);
     log_file_path_ = logger_->get_log_file_path();
+    JsonObject label_fields;
+    for (const auto& label : config_.labels) {
+        label_fields["label_" + label.first] = label.second;
+    }
+    logger_->set_labels(label_fields);
+
+    // 创建额外输出，按--output顺序注册到日志记录器，--store最后
+    SinkOptions sink_options;
+    sink_options.monitor_id = monitor_id_;
+    sink_options.key_field = config_.output_key_field;
+    for (const auto& tag : config_.session_tags) {
+        sink_options.tag_keys.push_back(tag.first);
+    }
+    for (const auto& label : label_fields) {
+        sink_options.tag_keys.push_back(label.first);
+    }
+    for (const auto& output : config_.outputs) {
+        auto sink = RecordSink::create(output, sink_options);
+        if (!sink) {
+            throw std::runtime_error("Unsupported output: " + output);
+        }
+        sinks_.emplace_back(output, std::move(sink));
+    }
+    if (!config_.store.empty()) {
+        std::string db_path;
+        if (!SqliteStore::parse_spec(config_.store, db_path)) {
+            throw std::runtime_error("Unsupported store: " + config_.store);
+        }
+        sinks_.emplace_back(config_.store,
+                            std::make_unique<SqliteStore>(db_path, monitor_id_, sink_options.tag_keys));
+    }
+    for (auto& sink : sinks_) {
+        logger_->add_sink(sink.second.get());
+    }
+    if (config_.tui) {
+        tui_ = std::make_unique<TuiDashboard>(router_name_, convergence_threshold_ms_.load());
+        logger_->add_sink(tui_.get());
+    }
+
+    // 创建告警发送器
+    if (!config_.alert_webhook_url.empty()) {
+        alert_notifier_ = std::make_unique<AlertNotifier>(config_.alert_webhook_url);
+    }
+
+    // 创建会话完成钩子
+    if (!config_.on_session_complete.empty()) {
+        session_hook_ = std::make_unique<SessionHook>(config_.on_session_complete, config_.hook_timeout_ms,
+            [this](const SessionHookResult& result) {
+                this->handle_hook_result(result);
+            });
+    }
+
+    // 创建FRR日志跟踪器
+    if (!config_.frr_log_path.empty()) {
+        frr_log_tailer_ = std::make_unique<FrrLogTailer>(config_.frr_log_path,
+            [this](const FrrLogEvent& event) {
+                this->handle_frr_log_event(event);
+            });
+    }
+
+    // 创建IGP邻接跟踪器
+    if (config_.igp_adjacency) {
+        igp_tracker_ = std::make_unique<IgpAdjacencyTracker>(config_.vtysh_path, config_.igp_poll_ms,
+            [this](const IgpAdjacencyEvent& event) {
+                this->handle_igp_adjacency_event(event);
+            });
+    }
+
+    // 创建gNMI订阅
+    if (!config_.gnmi.target.empty()) {
+        gnmi_subscriber_ = std::make_unique<GnmiSubscriber>(config_.gnmi,
+            [this](const GnmiUpdate& update) {
+                this->handle_gnmi_update(update);
+            });
+    }
+
+    // 创建SNMP Trap接收器(复用BMP的监听地址格式)
+    if (!config_.snmp_trap_listen.empty()) {
+        std::string address;
+        int port = 0;
+        if (!BmpCollector::parse_listen_spec(config_.snmp_trap_listen, address, port)) {
+            throw std::runtime_error("Invalid SNMP trap listen address: " + config_.snmp_trap_listen);
+        }
+        snmp_receiver_ = std::make_unique<SnmpTrapReceiver>(address, port, config_.snmp_community,
+            [this](const SnmpTrap& trap) {
+                this->handle_snmp_trap(trap);
+            });
+    }
+
+    // 创建时钟偏差探测与时钟参考
+    if (!config_.clock_reference.empty()) {
+        std::string host;
+        std::string port;
+        if (!ClockProbe::parse_reference(config_.clock_reference, host, port)) {
+            throw std::runtime_error("Invalid clock reference: " + config_.clock_reference);
+        }
+        clock_probe_ = std::make_unique<ClockProbe>(host, port, config_.clock_interval_ms,
+            [this](const ClockSample& sample) {
+                this->handle_clock_sample(sample);
+            });
+    }
+    if (!config_.clock_listen.empty()) {
+        std::string address;
+        int port = 0;
+        if (!BmpCollector::parse_listen_spec(config_.clock_listen, address, port)) {
+            throw std::runtime_error("Invalid clock listen address: " + config_.clock_listen);
+        }
+        clock_server_ = std::make_unique<ClockServer>(address, port);
+    }
+
+    // 创建事件源插件
+    for (const auto& spec : config_.sources) {
+        std::string error;
+        auto source = EventSourceRegistry::create(spec,
+            [this](const std::string& name, const SourceEvent& event) {
+                this->handle_source_event(name, event);
+            }, error);
+        if (!source) {
+            throw std::runtime_error("Invalid event source " + spec + ": " + error);
+        }
+        sources_.emplace_back(spec, std::move(source));
+    }
+
+    // 创建BMP采集器
+    if (!config_.bmp_listen.empty()) {
+        std::string address;
+        int port = 0;
+        if (!BmpCollector::parse_listen_spec(config_.bmp_listen, address, port)) {
+            throw std::runtime_error("Invalid BMP listen address: " + config_.bmp_listen);
+        }
+        bmp_collector_ = std::make_unique<BmpCollector>(address, port,
+            [this](const BmpMessage& message) {
+                this->handle_bmp_message(message);
+            });
+    }
+
+    // 创建FRR状态采集器
+    if (config_.frr_state) {
+        frr_poller_ = std::make_unique<FrrStatePoller>(config_.vtysh_path,
+            [this](int session_id, const std::string& phase, const FrrSnapshot& snapshot) {
+                this->log_frr_state(session_id, phase, snapshot);
+            });
+    }
     
+    // 创建路由表采样器
+    if (config_.route_table_sample_ms > 0) {
+        route_sampler_ = std::make_unique<RouteTableSampler>(config_.route_table_sample_ms,
+            [this](const RouteTableSample& sample) {
+                this->log_route_table_sample(sample);
+            });
+    }
+
     // 创建netlink监控器
     netlink_monitor_ = std::make_unique<NetlinkMonitor>();
+    netlink_monitor_->set_queue_capacity(config_.netlink_queue_size);
+    netlink_monitor_->set_worker_count(config_.netlink_workers);
+    netlink_monitor_->set_mroute_monitoring(config_.mroute);
+    netlink_monitor_->set_fdb_monitoring(config_.fdb);
+    netlink_monitor_->set_link_monitoring(config_.tunnels || config_.bonding || config_.link_events);
+    netlink_monitor_->set_tc_monitoring(!config_.route_only);
     
     // 设置回调函数
     netlink_monitor_->set_route_callback(
-        [this](const void* data, const std::string& type) {
-            this->on_route_event(data, type);
+        [this](const NetlinkEvent& event) {
+            this->on_route_event(event);
         });
     
     netlink_monitor_->set_qdisc_callback(
-        [this](const void* data, const std::string& type) {
-            this->on_qdisc_event(data, type);
+        [this](const NetlinkEvent& event) {
+            this->on_qdisc_event(event);
+        });
+
+    // 接口状态跟踪：接口消息先与上一次的状态比较，只有隧道、bond/team等关心的变化才成为事件
+    if (config_.tunnels || config_.bonding || config_.link_events) {
+        link_tracker_ = std::make_unique<LinkTracker>();
+        link_tracker_->set_tunnel_tracking(config_.tunnels);
+        link_tracker_->set_lag_tracking(config_.bonding);
+        link_tracker_->set_attribute_tracking(config_.link_events);
+        netlink_monitor_->set_link_callback(
+            [this](const NetlinkEvent& event) {
+                this->on_link_event(event);
+            });
+    }
+    if (config_.nexthop_cache > 0) {
+        nexthop_tracker_ = std::make_unique<NexthopTracker>(config_.nexthop_cache);
+    }
+    if (config_.tunnels) {
+        wireguard_poller_ = std::make_unique<WireguardPoller>(config_.wireguard_poll_ms,
+            [this]() {
+                return link_tracker_->interfaces_of_kind("wireguard");
+            },
+            [this](const WireguardPeerEvent& event) {
+                this->handle_wireguard_peer_event(event);
+            });
+    }
+    if (!config_.loop_probe_target.empty()) {
+        loop_prober_ = std::make_unique<LoopProber>(config_.loop_probe_target, config_.loop_probe_ms,
+            config_.loop_probe_max_ttl,
+            [this]() {
+                return state_.load() == MonitorState::MONITORING;
+            },
+            [this](const LoopProbeResult& result) {
+                this->handle_loop_probe(result);
+            });
+    }
+    if (config_.qdisc_stats_ms > 0) {
+        qdisc_stats_poller_ = std::make_unique<QdiscStatsPoller>(config_.qdisc_stats_ms,
+            [this]() {
+                std::lock_guard<std::mutex> lock(session_mutex_);
+                if (!current_session_) {
+                    return std::string();
+                }
+                auto it = current_session_->netem_info.find("interface");
+                return it != current_session_->netem_info.end() ? it->second : std::string();
+            },
+            [this](const QdiscStats& stats) {
+                this->handle_qdisc_stats(stats);
+            });
+    }
+    if (config_.fib_trace) {
+        fib_tracer_ = std::make_unique<FibTracer>([this](const FibTraceEvent& event) {
+            this->handle_fib_trace(event);
+        });
+    }
+    if (!config_.dataplane_flow.dst.empty()) {
+        dataplane_probe_ = std::make_unique<DataplaneProbe>(config_.dataplane_flow, config_.dataplane_interfaces,
+            [this](const DataplanePacket& packet) {
+                this->handle_dataplane_packet(packet);
+            });
+    }
+    if (config_.anomaly_sigma > 0) {
+        anomaly_detector_ = std::make_unique<AnomalyDetector>(config_.anomaly_sigma,
+                                                              static_cast<size_t>(config_.anomaly_window));
+    }
+
+    // 订阅看门狗重建套接字后记录事件，避免静默地什么也监听不到
+    netlink_monitor_->set_restart_callback(
+        [this](const std::string& reason, const std::string& detail) {
+            this->on_subscription_restarted(reason, detail);
         });
+    netlink_monitor_->set_error_callback(
+        [this](const std::string& severity, const std::string& message) {
+            this->log_error(severity, "netlink", message);
+        });
+
+    // 调试通道：记录每条原始netlink消息，便于排查预期的触发为何没有发生
+    if (log_enabled(LogLevel::DBG)) {
+        debug_channel_ = std::make_unique<DebugChannel>(
+            config_.debug_log_path.empty() ? log_file_path_ + ".debug" : config_.debug_log_path);
+        netlink_monitor_->set_raw_callback(
+            [this](const struct nlmsghdr* nlh) {
+                JsonObject record;
+                for (const auto& field : NetlinkMessageParser::describe_message(nlh)) {
+                    record[field.first] = field.second;
+                }
+                debug_channel_->write("netlink", std::move(record));
+            });
+    }
 }
 
 ConvergenceMonitor::~ConvergenceMonitor() {
@@ -134,31 +596,264 @@ void ConvergenceMonitor::start_monitoring() {
     
     running_.store(true);
     
+    for (auto& sink : sinks_) {
+        std::string error;
+        if (!sink.second->start(error)) {
+            throw std::runtime_error("Failed to start output " + sink.first + ": " + error);
+        }
+    }
+
+    if (config_.resume) {
+        resume_from_log();
+    }
+
     // 启动日志记录器
     logger_->start();
+
+    if (debug_channel_) {
+        std::string error;
+        if (!debug_channel_->open(error)) {
+            throw std::runtime_error(error);
+        }
+        info_out() << "🐞 " << tr("调试日志: ", "Debug log: ") << debug_channel_->path() << "\n";
+    }
+
+    if (alert_notifier_) {
+        alert_notifier_->start();
+    }
+
+    if (session_hook_) {
+        session_hook_->start();
+    }
+
+    if (frr_poller_) {
+        frr_poller_->start();
+    }
+
+    if (route_sampler_) {
+        route_sampler_->start();
+    }
+
+    if (frr_log_tailer_) {
+        frr_log_tailer_->start();
+    }
+
+    if (igp_tracker_) {
+        igp_tracker_->start();
+    }
+
+    if (snmp_receiver_) {
+        std::string error;
+        if (!snmp_receiver_->start(error)) {
+            throw std::runtime_error("Failed to start SNMP trap receiver: " + error);
+        }
+        info_out() << "📡 " << tr("SNMP Trap监听: ", "SNMP trap listener: ") << config_.snmp_trap_listen << "\n";
+    }
+
+    if (clock_server_) {
+        std::string error;
+        if (!clock_server_->start(error)) {
+            throw std::runtime_error("Failed to start clock reference: " + error);
+        }
+        info_out() << "🕒 " << tr("时钟参考(SNTP)监听: ", "Clock reference (SNTP) listening on ")
+                   << config_.clock_listen << "\n";
+    }
+
+    if (clock_probe_) {
+        clock_probe_->start();
+    }
+
+    if (gnmi_subscriber_) {
+        gnmi_subscriber_->start();
+        info_out() << "📡 " << tr("gNMI订阅: ", "gNMI subscription: ") << config_.gnmi.target << "\n";
+    }
+
+    if (bmp_collector_) {
+        std::string error;
+        if (!bmp_collector_->start(error)) {
+            throw std::runtime_error("Failed to start BMP collector: " + error);
+        }
+        info_out() << "📡 " << tr("BMP采集器监听: ", "BMP collector listening: ") << config_.bmp_listen << "\n";
+    }
+
+    for (auto& source : sources_) {
+        std::string error;
+        if (!source.second->start(error)) {
+            throw std::runtime_error("Failed to start event source " + source.first + ": " + error);
+        }
+        info_out() << "🔌 " << tr("事件源: ", "Event source: ") << source.first << "\n";
+    }
     
     // 记录监控开始日志
-    std::string user = []() {
-        struct passwd* pw = getpwuid(getuid());
-        return pw ? std::string(pw->pw_name) : "unknown";
-    }();
+    std::string user = Logger::current_user();
     
+    // 运行清单先于monitoring_started写出，日志自带复现本次运行所需的配置与环境
+    auto run_log = Logger::create_event_log("run_started", router_name_, user);
+    for (const auto& field : create_run_manifest(config_)) {
+        run_log[field.first] = field.second;
+    }
+    run_log["monitor_id"] = monitor_id_;
+    run_log["log_file_path"] = log_file_path_;
+    if (config_.resume) {
+        run_log["resumed_sessions"] = resumed_sessions_;
+    }
+    logger_->log_async(run_log);
+
     auto start_log = Logger::create_monitoring_start_log(
-        router_name_, user, convergence_threshold_ms_, 
+        router_name_, user, convergence_threshold_ms_.load(), 
         log_file_path_, monitor_id_);
     logger_->log_async(start_log);
+
+    for (const auto& warning : config_.preflight_warnings) {
+        log_error("warning", "preflight", warning);
+    }
     
+    // 当前默认路由作为基线，第一次变化时即可给出变化前的下一跳
+    if (config_.watch_default) {
+        std::string error;
+        for (const auto& route : RouteTableSampler::default_routes(error)) {
+            auto field = [&route](const char* name) {
+                auto it = route.find(name);
+                return it != route.end() ? it->second : "";
+            };
+            if (RouteEvent::classify(route).empty()) {
+                std::lock_guard<std::mutex> lock(default_mutex_);
+                default_nexthops_[field("family")] =
+                    (field("gateway") != "N/A" ? field("gateway") + " " : "") + "dev " + field("interface");
+            }
+        }
+        if (!error.empty()) {
+            log_error("warning", "watch_default", "route dump failed: " + error);
+        }
+    }
+
+    // 已有接口的状态作为基线，之后的变化才能与之比较
+    if (link_tracker_) {
+        std::string error = link_tracker_->seed();
+        if (!error.empty()) {
+            log_error("warning", "link", "link dump failed: " + error);
+        }
+    }
+    if (nexthop_tracker_) {
+        std::string error = nexthop_tracker_->seed();
+        if (!error.empty()) {
+            log_error("warning", "nexthop", "route dump failed: " + error);
+        }
+    }
+
     // 启动netlink监控
     if (!netlink_monitor_->start_monitoring()) {
         throw std::runtime_error("Failed to start netlink monitoring");
     }
+
+    if (wireguard_poller_) {
+        std::string error;
+        if (wireguard_poller_->start(error)) {
+            info_out() << "🔐 " << tr("WireGuard对端端点轮询间隔: ", "WireGuard peer endpoint poll interval: ")
+                       << config_.wireguard_poll_ms << "ms\n";
+        } else {
+            // 没有wireguard模块时其余隧道事件照常记录
+            log_error("warning", "tunnels", "wireguard endpoint polling unavailable: " + error);
+            wireguard_poller_.reset();
+        }
+    }
+
+    if (loop_prober_) {
+        loop_prober_->start();
+    }
+
+    if (qdisc_stats_poller_) {
+        qdisc_stats_poller_->start();
+    }
+
+    if (fib_tracer_) {
+        std::string error;
+        if (fib_tracer_->start(error)) {
+            info_out() << "🧬 " << tr("内核FIB打点已挂载 (fib_table_insert/fib_table_delete)",
+                                     "Kernel FIB tracing attached (fib_table_insert/fib_table_delete)") << "\n";
+        } else {
+            // 没有kprobe或权限不足时仍按netlink事件计算收敛时间
+            log_error("warning", "fib_trace", "kernel FIB tracing unavailable: " + error);
+            fib_tracer_.reset();
+        }
+    }
+
+    if (dataplane_probe_) {
+        std::string error;
+        if (dataplane_probe_->start(error)) {
+            info_out() << "🧬 " << tr("数据面探测已挂载到 ", "Dataplane probe attached to ")
+                       << config_.dataplane_interfaces.size() << tr(" 个接口的tc egress", " interface(s) at tc egress")
+                       << "\n";
+        } else {
+            log_error("warning", "dataplane_probe", "dataplane probe unavailable: " + error);
+            dataplane_probe_.reset();
+        }
+    }
     
     // 启动收敛检查线程
     convergence_checker_thread_ = std::thread(&ConvergenceMonitor::convergence_checker_loop, this);
+
+    if (!config_.control_socket.empty()) {
+        control_server_ = std::make_unique<ControlServer>(config_.control_socket,
+            [this](const std::vector<std::string>& args) {
+                return this->handle_control_command(args);
+            });
+        std::string error;
+        if (!control_server_->start(error)) {
+            throw std::runtime_error("Failed to start control socket: " + error);
+        }
+        info_out() << "🎛️  " << tr("控制套接字: ", "Control socket: ") << config_.control_socket << "\n";
+    }
+
+    if (!config_.health_listen.empty()) {
+        std::string address;
+        int port = 0;
+        BmpCollector::parse_listen_spec(config_.health_listen, address, port);
+        health_server_ = std::make_unique<HealthServer>(address, port,
+            [this](const std::string& path, std::string& body) {
+                return this->handle_health_request(path, body);
+            });
+        std::string error;
+        if (!health_server_->start(error)) {
+            throw std::runtime_error("Failed to start health endpoint: " + error);
+        }
+        info_out() << "🩺 " << tr("健康检查端点: ", "Health endpoint: ") << "http://" << config_.health_listen
+                   << "/healthz, /readyz\n";
+    }
+
+    // 其余组件都启动成功后再接管终端，启动失败的错误信息仍直接输出
+    if (tui_) {
+        std::string error;
+        if (!tui_->start(error)) {
+            throw std::runtime_error("Failed to start TUI: " + error);
+        }
+    }
     
-    std::cout << "🎯 监控开始 - 路由器: " << router_name_ << "\n";
-    std::cout << "   收敛阈值: " << convergence_threshold_ms_ << "ms\n";
-    std::cout << "   等待触发事件...\n";
+    info_out() << "🎯 " << tr("监控开始 - 路由器: ", "Monitoring started - router: ") << router_name_ << "\n";
+    info_out() << "   " << tr("收敛阈值: ", "Convergence threshold: ") << convergence_threshold_ms_.load() << "ms\n";
+    if (!config_.threshold_overrides.empty()) {
+        info_out() << "   " << tr("阈值覆盖: ", "Threshold overrides: ") << config_.threshold_overrides.text() << "\n";
+    }
+    if (config_.trigger_rules.custom()) {
+        info_out() << "   " << tr("触发规则: ", "Trigger rules: ") << config_.trigger_rules.triggers_text() << "\n";
+        if (!config_.trigger_rules.ignores_text().empty()) {
+            info_out() << "   " << tr("忽略规则: ", "Ignore rules: ") << config_.trigger_rules.ignores_text() << "\n";
+        }
+    }
+    if (config_.warmup_ms > 0) {
+        warmup_end_ms_.store(get_current_timestamp_ms() + config_.warmup_ms);
+        info_out() << "   " << tr("预热中，", "Warming up, ") << config_.warmup_ms
+                   << tr("ms内的事件不触发会话", "ms of events will not trigger sessions") << "\n";
+    } else {
+        info_out() << "   " << tr("等待触发事件...", "Waiting for trigger events...") << "\n";
+    }
+
+    // 此时各组件线程均已启动，记录下来用于最终的自身开销统计
+    JsonObject usage;
+    read_process_usage(usage);
+    if (usage.count("threads")) {
+        monitor_threads_ = usage["threads"].as_int64();
+    }
 }
 
 void ConvergenceMonitor::stop_monitoring() {
@@ -167,60 +862,737 @@ void ConvergenceMonitor::stop_monitoring() {
     }
     
     running_.store(false);
+
+    // 先停止接收控制命令，避免与下面的收尾并发修改会话
+    if (control_server_) {
+        control_server_->stop();
+    }
+    if (health_server_) {
+        health_server_->stop();
+    }
     
     // 停止netlink监控
     if (netlink_monitor_) {
         netlink_monitor_->stop_monitoring();
     }
+
+    if (frr_log_tailer_) {
+        frr_log_tailer_->stop();
+    }
+
+    if (igp_tracker_) {
+        igp_tracker_->stop();
+    }
+
+    if (gnmi_subscriber_) {
+        gnmi_subscriber_->stop();
+    }
+
+    if (snmp_receiver_) {
+        snmp_receiver_->stop();
+    }
+
+    if (clock_probe_) {
+        clock_probe_->stop();
+    }
+
+    if (clock_server_) {
+        clock_server_->stop();
+    }
+
+    if (bmp_collector_) {
+        bmp_collector_->stop();
+    }
+
+    for (auto& source : sources_) {
+        source.second->stop();
+    }
+
+    if (wireguard_poller_) {
+        wireguard_poller_->stop();
+    }
+
+    if (loop_prober_) {
+        loop_prober_->stop();
+    }
+
+    if (qdisc_stats_poller_) {
+        qdisc_stats_poller_->stop();
+    }
+
+    if (fib_tracer_) {
+        fib_tracer_->stop();
+    }
+
+    if (dataplane_probe_) {
+        dataplane_probe_->stop();
+    }
     
     // 停止收敛检查线程
     if (convergence_checker_thread_.joinable()) {
         convergence_cv_.notify_all();
         convergence_checker_thread_.join();
     }
+
+    if (route_sampler_) {
+        route_sampler_->stop();
+    }
+
+    // 恢复终端，统计信息照常输出
+    if (tui_) {
+        tui_->stop();
+    }
     
     // 打印统计信息
     print_statistics();
+
+    // 发送剩余告警
+    if (alert_notifier_) {
+        alert_notifier_->stop();
+    }
+
+    // 执行剩余的会话钩子，结果仍写入日志
+    if (session_hook_) {
+        session_hook_->stop();
+        info_out() << "🪝 " << tr("会话钩子: 执行 ", "Session hook: ran ") << session_hook_->run_count()
+                   << tr(" 次，失败 ", " times, failed ") << session_hook_->failed_count();
+        if (session_hook_->dropped_count() > 0) {
+            info_out() << tr("，积压丢弃 ", ", dropped ") << session_hook_->dropped_count();
+        }
+        info_out() << "\n";
+    }
+
+    // 完成剩余的FRR状态采集
+    if (frr_poller_) {
+        frr_poller_->stop();
+    }
     
     // 停止日志记录器
     if (logger_) {
         logger_->stop();
     }
-}
-
-void ConvergenceMonitor::on_route_event(const void* route_data, const std::string& event_type) {
-    int64_t timestamp = get_current_timestamp_ms();
-    auto route_info = parse_route_info(route_data);
-    handle_route_event(timestamp, event_type, route_info);
-}
 
-void ConvergenceMonitor::on_qdisc_event(const void* qdisc_data, const std::string& event_type) {
-    auto qdisc_info = parse_qdisc_info(qdisc_data);
-    handle_qdisc_event(qdisc_info, event_type);
+    // 日志记录器停止后再让各输出写完剩余的记录
+    for (auto& sink : sinks_) {
+        sink.second->stop();
+        info_out() << "📤 " << sink.second->summary() << "\n";
+    }
+    for (const auto& source : sources_) {
+        info_out() << "🔌 " << source.second->summary() << "\n";
+    }
+
+    if (debug_channel_) {
+        debug_channel_->close();
+        info_out() << "🐞 " << tr("调试日志: ", "Debug log: ") << debug_channel_->path()
+                   << tr(" 共 ", ", ") << debug_channel_->written_count() << tr(" 条", " records");
+        if (debug_channel_->suppressed_count() > 0) {
+            info_out() << tr("，限速丢弃 ", ", rate-limited ") << debug_channel_->suppressed_count()
+                       << tr(" 条", " dropped");
+        }
+        info_out() << "\n";
+    }
+}
+
+void ConvergenceMonitor::resume_from_log() {
+    struct stat st;
+    if (stat(log_file_path_.c_str(), &st) != 0 || st.st_size == 0) {
+        info_out() << "📂 " << tr("日志文件尚无记录，从头开始: ", "Log file has no records yet, starting fresh: ")
+                   << log_file_path_ << "\n";
+        return;
+    }
+
+    ReportData data;
+    std::string error;
+    if (!ConvergenceReport::load(log_file_path_, data, error)) {
+        throw std::runtime_error("Failed to resume from " + log_file_path_ + ": " + error);
+    }
+
+    ResumedRun run = restore_sessions(data, router_name_);
+    std::lock_guard<std::mutex> lock(session_mutex_);
+    if (anomaly_detector_) {
+        for (int64_t convergence_ms : run.baseline_convergence_ms) {
+            anomaly_detector_->observe(static_cast<double>(convergence_ms));
+        }
+    }
+    resumed_sessions_ = static_cast<int64_t>(run.sessions.size());
+    for (auto& session : run.sessions) {
+        completed_sessions_.push_back(std::move(session));
+    }
+
+    session_counter_.store(run.last_session_id);
+    total_netem_triggers_.store(run.netem_triggers);
+    total_route_triggers_.store(run.route_triggers);
+    total_route_events_.store(run.route_events);
+
+    info_out() << "📂 " << tr("已从日志恢复 ", "Resumed ") << resumed_sessions_
+               << tr(" 个已完成会话，会话编号从 #", " completed sessions from the log, numbering continues at #")
+               << (run.last_session_id + 1) << tr(" 继续", "") << "\n";
+}
+
+ResumedRun ConvergenceMonitor::restore_sessions(const ReportData& data, const std::string& router_name) {
+    // 多路由器的汇总日志只恢复本路由器的会话；只有一个路由器时不要求名称一致(默认名称每次随机生成)
+    bool single_router = data.routers.size() <= 1;
+    ResumedRun run;
+    for (const auto& prior : data.sessions) {
+        if (!single_router && prior.router_name != router_name) {
+            continue;
+        }
+        run.last_session_id = std::max(run.last_session_id, prior.session_id);
+        if (!prior.trigger_source.empty()) {
+            (prior.trigger_source == "netem" ? run.netem_triggers : run.route_triggers)++;
+        }
+        if (!prior.completed) {
+            continue;
+        }
+        auto session = std::make_unique<ConvergenceSession>(
+            prior.session_id, std::max<int64_t>(prior.start_time_ms, 0), prior.trigger_info);
+        session->trigger_source = prior.trigger_source;
+        session->convergence_time = prior.convergence_time_ms;
+        session->timed_out = prior.timed_out;
+        session->forced = prior.forced;
+        session->restore_summary(prior.route_events, prior.duration_ms);
+        session->is_converged.store(true);
+        session->resumed = true;
+        run.route_events += prior.route_events;
+        // 历史会话同样作为异常检测的基线，与实时会话一样只取真正收敛的
+        if (!prior.timed_out && !prior.forced && prior.convergence_time_ms.has_value()) {
+            run.baseline_convergence_ms.push_back(prior.convergence_time_ms.value());
+        }
+        run.sessions.push_back(std::move(session));
+    }
+    return run;
+}
+
+void ConvergenceMonitor::emergency_stop() {
+    // 正常关闭已写出最终统计、卡在之后的收尾步骤时，只需写完剩余记录
+    if (statistics_logged_.load()) {
+        size_t written = logger_->abandon();
+        std::cerr << "💾 " << tr("已写出剩余 ", "Wrote ") << written
+                  << tr(" 条记录到 ", " remaining records to ") << log_file_path_ << "\n";
+        return;
+    }
+
+    std::string user = Logger::current_user();
+    int64_t total_time = get_current_timestamp_ms() - monitoring_start_time_;
+    int64_t total_netem_triggers = total_netem_triggers_.load();
+    int64_t total_route_triggers = total_route_triggers_.load();
+
+    // 会话锁可能被卡住的线程持有，拿不到时只写计数器
+    std::vector<int64_t> convergence_times;
+    int64_t completed_count = finished_sessions_.load();
+    int unfinished_session_id = 0;
+    std::unique_lock<std::mutex> lock(session_mutex_, std::defer_lock);
+    for (int attempt = 0; attempt < 20 && !lock.try_lock(); ++attempt) {
+        std::this_thread::sleep_for(std::chrono::milliseconds(10));
+    }
+    if (lock.owns_lock()) {
+        completed_count = static_cast<int64_t>(completed_sessions_.size());
+        for (const auto& session : completed_sessions_) {
+            if (session->convergence_time.has_value()) {
+                convergence_times.push_back(session->convergence_time.value());
+            }
+        }
+        if (current_session_ && !current_session_->is_converged.load()) {
+            unfinished_session_id = current_session_->session_id;
+        }
+        lock.unlock();
+    }
+
+    auto final_log = Logger::create_monitoring_completed_log(
+        router_name_, log_file_path_, user, total_time, convergence_threshold_ms_.load(),
+        total_netem_triggers + total_route_triggers, total_netem_triggers, total_route_triggers,
+        total_route_events_.load(), static_cast<int>(completed_count), monitor_id_);
+    final_log["stop_reason"] = "forced";
+    final_log["forced_exit"] = true;
+    if (unfinished_session_id > 0) {
+        final_log["unfinished_session_id"] = static_cast<int64_t>(unfinished_session_id);
+    }
+    if (!convergence_times.empty()) {
+        std::sort(convergence_times.begin(), convergence_times.end());
+        final_log["fastest_convergence_ms"] = convergence_times.front();
+        final_log["slowest_convergence_ms"] = convergence_times.back();
+        final_log["avg_convergence_time_ms"] =
+            std::accumulate(convergence_times.begin(), convergence_times.end(), 0.0) / convergence_times.size();
+    }
+    final_log["log_records"] = logger_->records_written();
+    final_log["log_records_dropped"] = logger_->records_dropped();
+    final_log["error_events"] = error_events_.load();
+
+    size_t written = logger_->abandon(&final_log);
+    std::cerr << "💾 " << tr("已写出最终统计与剩余 ", "Wrote final statistics and ") << written
+              << tr(" 条记录到 ", " remaining records to ") << log_file_path_ << "\n";
+}
+
+void ConvergenceMonitor::on_route_event(const NetlinkEvent& event) {
+    // 以recv时间为准，队列积压时处理时间会晚于事件实际到达的时间
+    auto route_info = event.info;
+    annotate_interface(route_info);
+    annotate_table(route_info);
+    {
+        std::lock_guard<std::mutex> lock(filter_mutex_);
+        if (!filter_.matches(route_info, true)) {
+            debug_note("filtered_out", event.type, route_info);
+            return;
+        }
+    }
+    count_churn(event.received_ms);
+    std::string event_type = event.type;
+    if (event_type == "fdb_add" || event_type == "fdb_del") {
+        event_type = track_fdb_location(event_type, route_info);
+    }
+    std::string route_class = RouteEvent::classify(route_info);
+    if (!route_class.empty()) {
+        route_info["route_class"] = route_class;
+    }
+    // 删除后重新添加的窗口与静默期相同，窗口内的两条通知本来就属于同一个会话
+    NexthopChange nexthop_change;
+    bool nexthop_changed = nexthop_tracker_ &&
+        nexthop_tracker_->update(event.received_ms, event_type, route_info, convergence_threshold_ms_.load(),
+                                 nexthop_change);
+    handle_route_event(event.received_ms, event_type, route_info);
+    if (nexthop_changed) {
+        handle_nexthop_change(event.received_ms, nexthop_change);
+    }
+    // 在handle_route_event之后，默认路由的删除本身触发会话时也能记入该会话
+    if (config_.watch_default && is_default_route(route_info)) {
+        track_default_route(event.received_ms, event_type, route_info);
+    }
+    if (matches_converged_prefix(event_type, route_info)) {
+        converge_on_prefix(event.received_ms, route_info);
+    }
+}
+
+void ConvergenceMonitor::on_link_event(const NetlinkEvent& event) {
+    for (auto& change : link_tracker_->update(event.type, event.info)) {
+        if (change.type.rfind("link_", 0) == 0) {
+            handle_link_attribute_event(event.received_ms, change);
+        } else {
+            handle_derived_event(event.received_ms, change.type, std::move(change.info));
+        }
+    }
+}
+
+void ConvergenceMonitor::handle_link_attribute_event(int64_t timestamp, const LinkChange& change) {
+    auto info = change.info;
+    annotate_interface(info);
+    {
+        std::lock_guard<std::mutex> lock(filter_mutex_);
+        if (!filter_.matches(info, false)) {
+            debug_note("filtered_out", change.type, info);
+            return;
+        }
+    }
+
+    std::string user = Logger::current_user();
+
+    auto log = Logger::create_event_log("link_event", router_name_, user);
+    log["link_event_type"] = change.type;
+    log["interface"] = info["interface"];
+    for (const char* key : {"link", "mtu", "previous_mtu", "promisc", "previous_promisc", "master",
+                            "previous_master", "slave_kind", "kind", "link_state"}) {
+        auto it = info.find(key);
+        if (it != info.end()) {
+            log[key] = it->second;
+        }
+    }
+
+    // 只标注所在会话，不计入路由事件，也不推迟收敛判定
+    {
+        std::lock_guard<std::mutex> lock(session_mutex_);
+        if (current_session_) {
+            log["session_id"] = static_cast<int64_t>(current_session_->session_id);
+            log["offset_from_trigger_ms"] = timestamp - current_session_->netem_event_time;
+        }
+    }
+
+    std::string field = change.type == "link_mtu_change" ? "mtu"
+        : change.type == "link_promisc_change" ? "promisc" : "master";
+    auto value = [&info](const std::string& key) {
+        return info[key].empty() ? std::string("-") : info[key];
+    };
+    if (console_throttle_->allow_line(timestamp)) {
+        info_out() << "🔗 " << info["interface"] << " " << event_type_label(change.type) << ": "
+                   << value("previous_" + field) << " -> " << value(field) << "\n";
+    }
+
+    logger_->log_async(log);
+}
+
+void ConvergenceMonitor::handle_nexthop_change(int64_t timestamp, const NexthopChange& change) {
+    std::string user = Logger::current_user();
+
+    auto log = Logger::create_event_log("nexthop_changed", router_name_, user);
+    log["prefix"] = change.prefix;
+    log["table"] = change.table;
+    log["table_name"] = route_table_name(static_cast<uint32_t>(strtoul(change.table.c_str(), nullptr, 10)));
+    log["family"] = change.family;
+    log["old_nexthop"] = change.old_nexthop;
+    log["new_nexthop"] = change.new_nexthop;
+    log["old_gateway"] = change.old_gateway;
+    log["new_gateway"] = change.new_gateway;
+    log["change"] = change.kind;
+    if (change.kind == "del_add") {
+        log["withdrawn_ms"] = change.withdrawn_ms;
+    }
+    nexthop_changes_++;
+
+    int64_t offset = -1;
+    bool fast_reroute = false;
+    {
+        std::lock_guard<std::mutex> lock(session_mutex_);
+        if (current_session_) {
+            offset = timestamp - current_session_->netem_event_time;
+            fast_reroute = current_session_->nexthops.record(offset, config_.frr_window_ms);
+            log["session_id"] = static_cast<int64_t>(current_session_->session_id);
+            log["offset_from_trigger_ms"] = offset;
+            if (config_.frr_window_ms > 0) {
+                log["phase"] = fast_reroute ? "fast_reroute" : "spf";
+            }
+        }
+    }
+
+    if (console_throttle_->allow_line(timestamp)) {
+        info_out() << (fast_reroute ? "⚡ " : "🔀 ") << (offset >= 0 ? "+" + std::to_string(offset) + "ms " : "")
+                   << event_type_label(fast_reroute ? "fast_reroute" : "nexthop_changed") << " " << change.prefix << ": " << change.old_nexthop
+                   << " -> " << change.new_nexthop
+                   << (change.kind == "del_add"
+                           ? std::string(tr(" (中断", " (withdrawn ")) + std::to_string(change.withdrawn_ms) + "ms)"
+                           : std::string())
+                   << "\n";
+    }
+
+    logger_->log_async(log);
+}
+
+void ConvergenceMonitor::handle_wireguard_peer_event(const WireguardPeerEvent& event) {
+    // 与隧道接口的端点变化使用同一事件类型，对端公钥区分同一接口上的多个对端
+    std::unordered_map<std::string, std::string> info;
+    info["interface"] = event.interface;
+    info["kind"] = "wireguard";
+    info["public_key"] = event.peer;
+    info["tunnel_remote"] = event.endpoint;
+    info["previous_remote"] = event.previous_endpoint;
+    handle_derived_event(event.timestamp_ms, "tunnel_endpoint_change", std::move(info));
+}
+
+void ConvergenceMonitor::handle_derived_event(int64_t timestamp, const std::string& event_type,
+                                              std::unordered_map<std::string, std::string> info) {
+    annotate_interface(info);
+    {
+        std::lock_guard<std::mutex> lock(filter_mutex_);
+        if (!filter_.matches(info, false)) {
+            debug_note("filtered_out", event_type, info);
+            return;
+        }
+    }
+    count_churn(timestamp);
+    handle_route_event(timestamp, event_type, info);
+}
+
+void ConvergenceMonitor::handle_loop_probe(const LoopProbeResult& result) {
+    std::string user = Logger::current_user();
+    auto join = [](const std::vector<std::string>& items, const char* separator) {
+        std::string text;
+        for (size_t i = 0; i < items.size(); ++i) {
+            text += (i ? separator : "") + (items[i].empty() ? std::string("*") : items[i]);
+        }
+        return text;
+    };
+
+    std::lock_guard<std::mutex> lock(session_mutex_);
+    if (!current_session_) {
+        return;
+    }
+    ConvergenceSession& session = *current_session_;
+    session.micro_loop.probe_rounds++;
+    session.micro_loop.last_probe_time = result.timestamp_ms;
+    bool looping = !result.loop.empty();
+    if (looping) {
+        session.micro_loop.rounds++;
+    }
+    // 只在环路出现与消失时写记录
+    if (looping == session.micro_loop.start_time.has_value()) {
+        return;
+    }
+
+    int64_t offset = result.timestamp_ms - session.netem_event_time;
+    auto log = Logger::create_event_log("micro_loop", router_name_, user);
+    log["session_id"] = static_cast<int64_t>(session.session_id);
+    log["offset_from_trigger_ms"] = offset;
+    log["target"] = config_.loop_probe_target;
+    log["hops"] = join(result.hops, ",");
+    if (looping) {
+        session.micro_loop.start_time = result.timestamp_ms;
+        if (!session.micro_loop.onset_offset.has_value()) {
+            session.micro_loop.onset_offset = offset;
+        }
+        session.micro_loop.events++;
+        session.micro_loop.members = join(result.loop, " -> ");
+        log["phase"] = "onset";
+        log["loop"] = session.micro_loop.members;
+        info_out() << "🔁 " << tr("微环路出现 (会话 #", "Micro-loop detected (session #") << session.session_id
+                   << ", +" << offset << "ms): " << session.micro_loop.members << " -> " << result.loop.front() << "\n";
+    } else {
+        int64_t duration = result.timestamp_ms - session.micro_loop.start_time.value();
+        session.micro_loop.duration_ms += duration;
+        session.micro_loop.start_time.reset();
+        log["phase"] = "end";
+        log["loop"] = session.micro_loop.members;
+        log["loop_duration_ms"] = duration;
+        info_out() << "🔁 " << tr("微环路消失 (会话 #", "Micro-loop cleared (session #") << session.session_id
+                   << ", +" << offset << tr("ms), 持续 ", "ms), lasted ") << duration << "ms\n";
+    }
+    logger_->log_async(log);
+}
+
+void ConvergenceMonitor::handle_qdisc_stats(const QdiscStats& stats) {
+    // 读取期间会话可能已经结束或换成了另一个接口上的会话
+    std::lock_guard<std::mutex> lock(session_mutex_);
+    if (!current_session_) {
+        return;
+    }
+    auto it = current_session_->netem_info.find("interface");
+    if (it == current_session_->netem_info.end() || it->second != stats.interface) {
+        return;
+    }
+    if (current_session_->qdisc_samples.size() < QdiscStatsPoller::MAX_SESSION_SAMPLES) {
+        current_session_->qdisc_samples.push_back(stats);
+    }
+}
+
+void ConvergenceMonitor::handle_fib_trace(const FibTraceEvent& event) {
+    // 本地表(255)随地址配置变化，不是路由收敛
+    if (event.table == RT_TABLE_LOCAL) {
+        return;
+    }
+    std::lock_guard<std::mutex> lock(session_mutex_);
+    // 打点可能先于也可能晚于对应的netlink消息到达，触发路由的删除要从最近的记录中找
+    recent_fib_events_.push_back(event);
+    while (recent_fib_events_.front().timestamp_us < event.timestamp_us - 1000000) {
+        recent_fib_events_.pop_front();
+    }
+    if (current_session_ && current_session_->fib_events.size() < FibTracer::MAX_SESSION_EVENTS) {
+        current_session_->fib_events.push_back(event);
+    }
+}
+
+void ConvergenceMonitor::handle_dataplane_packet(const DataplanePacket& packet) {
+    // 程序命中后即撤防，多个CPU同时命中时取最早的一条；会话已结束时丢弃
+    std::lock_guard<std::mutex> lock(session_mutex_);
+    if (!current_session_) {
+        return;
+    }
+    auto& first = current_session_->dataplane_packet;
+    if (!first.has_value() || packet.timestamp_ns < first->timestamp_ns) {
+        first = packet;
+    }
+}
+
+bool ConvergenceMonitor::is_default_route(const std::unordered_map<std::string, std::string>& info) {
+    auto field = [&info](const char* name) {
+        auto it = info.find(name);
+        return it != info.end() ? it->second : "";
+    };
+    return field("dst") == "default" && field("table") == std::to_string(RT_TABLE_MAIN) &&
+           (field("family") == std::to_string(AF_INET) || field("family") == std::to_string(AF_INET6));
+}
+
+void ConvergenceMonitor::track_default_route(int64_t timestamp, const std::string& event_type,
+                                             const std::unordered_map<std::string, std::string>& info) {
+    auto field = [&info](const char* name) {
+        auto it = info.find(name);
+        return it != info.end() ? it->second : "";
+    };
+    // 下一跳写作 "网关 dev 接口"，丢弃类默认路由没有下一跳
+    std::string nexthop;
+    if (event_type != "route_del" && RouteEvent::classify(info).empty()) {
+        nexthop = (field("gateway") != "N/A" ? field("gateway") + " " : "") + "dev " + field("interface");
+    }
+
+    std::string previous;
+    {
+        std::lock_guard<std::mutex> lock(default_mutex_);
+        std::string& current = default_nexthops_[field("family")];
+        previous = current;
+        if (event_type == "route_del") {
+            // 删除通知带被删路由的下一跳，未知时以它作为变化前的值
+            if (previous.empty()) {
+                previous = (field("gateway") != "N/A" ? field("gateway") + " " : "") + "dev " + field("interface");
+            }
+            current.clear();
+        } else {
+            current = nexthop;
+        }
+    }
+
+    std::lock_guard<std::mutex> lock(session_mutex_);
+    if (current_session_) {
+        current_session_->note_default_route(timestamp, event_type, nexthop, previous);
+    }
+}
+
+bool ConvergenceMonitor::matches_converged_prefix(const std::string& event_type,
+                                                  const std::unordered_map<std::string, std::string>& info) const {
+    const auto& target = config_.converged_prefix;
+    if (target.text.empty() || (event_type != "route_add" && event_type != "route_replace") ||
+        !RouteEvent::classify(info).empty()) {
+        return false;
+    }
+    auto field = [&info](const char* name) {
+        auto it = info.find(name);
+        return it != info.end() ? it->second : std::string();
+    };
+    if (field("family") != std::to_string(target.family) || field("dst_len") != std::to_string(target.length)) {
+        return false;
+    }
+
+    // 目标前缀只比较前缀长度内的位，"10.0.0.1/24"与内核上报的10.0.0.0/24相同
+    uint8_t dst[16] = {};
+    std::string dst_text = field("dst");
+    if (dst_text != "default" && inet_pton(target.family, dst_text.c_str(), dst) != 1) {
+        return false;
+    }
+    int full_bytes = target.length / 8;
+    int remaining_bits = target.length % 8;
+    if (memcmp(dst, target.address, static_cast<size_t>(full_bytes)) != 0) {
+        return false;
+    }
+    if (remaining_bits > 0) {
+        uint8_t mask = static_cast<uint8_t>(0xff << (8 - remaining_bits));
+        if ((dst[full_bytes] & mask) != (target.address[full_bytes] & mask)) {
+            return false;
+        }
+    }
+
+    const std::string& via = config_.converged_via;
+    return via.empty() || field("interface") == via || field("gateway") == via;
+}
+
+void ConvergenceMonitor::converge_on_prefix(int64_t timestamp,
+                                            const std::unordered_map<std::string, std::string>& info) {
+    auto field = [&info](const char* name) {
+        auto it = info.find(name);
+        return it != info.end() ? it->second : std::string();
+    };
+    std::string nexthop = (field("gateway") != "N/A" ? field("gateway") + " " : "") + "dev " + field("interface");
+
+    std::lock_guard<std::mutex> lock(session_mutex_);
+    if (state_.load() != MonitorState::MONITORING || !current_session_ ||
+        !current_session_->mark_converged(timestamp, nexthop)) {
+        return;
+    }
+    info_out() << "✅ " << tr("会话 #", "Session #") << current_session_->session_id
+               << tr(" 收敛完成 (目标前缀 ", " converged (target prefix ") << config_.converged_prefix.text
+               << " via " << nexthop << ")\n";
+    finish_current_session();
+}
+
+std::string ConvergenceMonitor::track_fdb_location(const std::string& event_type,
+                                                   std::unordered_map<std::string, std::string>& info) {
+    auto field = [&info](const char* name) {
+        auto it = info.find(name);
+        return it != info.end() ? it->second : std::string();
+    };
+    // 网桥表项按所属网桥区分，VXLAN设备自身的表项(self)按设备区分
+    std::string owner = field("master").empty() ? field("interface") : field("master");
+    std::string key = field("mac") + "|" + field("vlan") + "|" + field("vni") + "|" + owner;
+    std::string location = field("interface") + "|" + field("remote");
+
+    std::lock_guard<std::mutex> lock(fdb_mutex_);
+    if (event_type == "fdb_del") {
+        fdb_locations_.erase(key);
+        return event_type;
+    }
+    auto it = fdb_locations_.find(key);
+    if (it == fdb_locations_.end()) {
+        fdb_locations_.emplace(key, location);
+        return event_type;
+    }
+    if (it->second == location) {
+        return event_type;
+    }
+    size_t sep = it->second.find('|');
+    info["previous_interface"] = it->second.substr(0, sep);
+    std::string previous_remote = it->second.substr(sep + 1);
+    if (!previous_remote.empty()) {
+        info["previous_remote"] = previous_remote;
+    }
+    it->second = location;
+    return "fdb_move";
+}
+
+void ConvergenceMonitor::on_qdisc_event(const NetlinkEvent& event) {
+    auto qdisc_info = event.info;
+    annotate_interface(qdisc_info);
+    {
+        std::lock_guard<std::mutex> lock(filter_mutex_);
+        if (!filter_.matches(qdisc_info, false)) {
+            debug_note("filtered_out", event.type, qdisc_info);
+            return;
+        }
+    }
+    handle_qdisc_event(event.received_ms, qdisc_info, event.type);
 }
 
 void ConvergenceMonitor::cleanup_old_events() {
     int64_t current_time = get_current_timestamp_ms();
-    int64_t cutoff_time = current_time - 300000; // 5分钟前
+    int64_t cutoff_time = current_time - config_.qdisc_cache_ttl_ms;
     
     std::lock_guard<std::mutex> lock(qdisc_events_mutex_);
     
     // 清理过期的qdisc事件
-    while (!recent_qdisc_events_.empty() && 
-           recent_qdisc_events_.front().timestamp < cutoff_time) {
-        recent_qdisc_events_.pop();
+    for (auto it = recent_qdisc_events_.begin(); it != recent_qdisc_events_.end();) {
+        if (it->second.timestamp < cutoff_time) {
+            it = recent_qdisc_events_.erase(it);
+        } else {
+            ++it;
+        }
+    }
+}
+
+std::string ConvergenceMonitor::qdisc_cache_key(const std::unordered_map<std::string, std::string>& qdisc_info) {
+    auto iface_it = qdisc_info.find("interface");
+    auto handle_it = qdisc_info.find("handle");
+    if (iface_it == qdisc_info.end() || handle_it == qdisc_info.end()) {
+        return "";
+    }
+    return iface_it->second + "|" + handle_it->second;
+}
+
+void ConvergenceMonitor::remember_qdisc_event(int64_t timestamp, const std::string& event_type,
+                                              const std::unordered_map<std::string, std::string>& qdisc_info) {
+    std::string key = qdisc_cache_key(qdisc_info);
+    if (key.empty()) {
+        return;
+    }
+
+    std::lock_guard<std::mutex> lock(qdisc_events_mutex_);
+    if (event_type == "QDISC_DEL") {
+        recent_qdisc_events_.erase(key);
+        return;
+    }
+    auto replaced_it = qdisc_info.find("replaced_handle");
+    if (replaced_it != qdisc_info.end()) {
+        recent_qdisc_events_.erase(qdisc_info.at("interface") + "|" + replaced_it->second);
+    }
+    recent_qdisc_events_.insert_or_assign(key, QdiscEvent(timestamp, event_type, qdisc_info));
+
+    // 超出上限时挤出最早的条目
+    while (config_.qdisc_cache_size > 0 &&
+           recent_qdisc_events_.size() > static_cast<size_t>(config_.qdisc_cache_size)) {
+        auto oldest = recent_qdisc_events_.begin();
+        for (auto it = recent_qdisc_events_.begin(); it != recent_qdisc_events_.end(); ++it) {
+            if (it->second.timestamp < oldest->second.timestamp) {
+                oldest = it;
+            }
+        }
+        recent_qdisc_events_.erase(oldest);
+        qdisc_cache_evicted_++;
     }
 }
 
 std::string ConvergenceMonitor::format_timestamp(int64_t timestamp_ms) const {
-    auto time_point = std::chrono::system_clock::from_time_t(timestamp_ms / 1000);
-    auto time_t = std::chrono::system_clock::to_time_t(time_point);
-    auto ms = timestamp_ms % 1000;
-    
-    std::stringstream ss;
-    ss << std::put_time(std::localtime(&time_t), "%Y-%m-%d %H:%M:%S");
-    ss << "." << std::setfill('0') << std::setw(3) << ms;
-    return ss.str();
+    return format_timestamp_ns(timestamp_ms * 1000000);
 }
 
 std::string ConvergenceMonitor::get_interface_name(int ifindex) const {
@@ -233,6 +1605,21 @@ std::string ConvergenceMonitor::get_interface_name(int ifindex) const {
     return "if" + std::to_string(ifindex);
 }
 
+void ConvergenceMonitor::debug_note(const std::string& decision, const std::string& event_type,
+                                    const std::unordered_map<std::string, std::string>& info) {
+    if (!debug_channel_) {
+        return;
+    }
+    JsonObject record;
+    for (const auto& field : info) {
+        record[field.first] = field.second;
+    }
+    record["decision"] = decision;
+    record["event_type"] = event_type;
+    record["state"] = state_.load() == MonitorState::MONITORING ? "monitoring" : "idle";
+    debug_channel_->write("decision", std::move(record));
+}
+
 void ConvergenceMonitor::convergence_checker_loop() {
     while (running_.load()) {
         std::unique_lock<std::mutex> lock(convergence_mutex_);
@@ -243,6 +1630,13 @@ void ConvergenceMonitor::convergence_checker_loop() {
             break;
         }
 
+        flush_coalesced(false);
+        flush_event_summary(false);
+        flush_churn(false);
+        flush_console(false);
+        check_warmup_end();
+        cleanup_old_events();
+
         // 检查当前会话是否需要收敛检查
         ConvergenceSession* session = nullptr;
         {
@@ -256,14 +1650,27 @@ void ConvergenceMonitor::convergence_checker_loop() {
 
         if (session) {
             // 检查收敛（不需要持有session_mutex_）
-            if (session->check_convergence(convergence_threshold_ms_)) {
+            if (session->check_convergence(session->threshold_override_ms.value_or(convergence_threshold_ms_.load()))) {
                 // 获取写锁来完成会话
                 std::lock_guard<std::mutex> write_lock(session_mutex_);
                 if (state_.load() == MonitorState::MONITORING &&
                     current_session_.get() == session &&
                     current_session_->is_converged.load()) {
 
-                    std::cout << "✅ 会话 #" << current_session_->session_id << " 收敛完成\n";
+                    info_out() << "✅ " << tr("会话 #", "Session #") << current_session_->session_id
+                               << tr(" 收敛完成", " converged") << "\n";
+                    finish_current_session();
+                }
+            } else if (session->exceeds_max_duration(get_current_timestamp_ms(), config_.max_session_ms)) {
+                // 超过--max-session-duration仍未静默，按超时结束
+                std::lock_guard<std::mutex> write_lock(session_mutex_);
+                if (state_.load() == MonitorState::MONITORING &&
+                    current_session_.get() == session &&
+                    !current_session_->is_converged.load()) {
+                    current_session_->time_out();
+                    info_out() << "⏱️  " << tr("会话 #", "Session #") << current_session_->session_id
+                               << tr(" 超过最长时长 ", " exceeded the maximum duration of ") << config_.max_session_ms
+                               << tr("ms 仍未收敛，按超时结束", "ms without converging, timed out") << "\n";
                     finish_current_session();
                 }
             }
@@ -271,297 +1678,1899 @@ void ConvergenceMonitor::convergence_checker_loop() {
     }
 }
 
-std::unordered_map<std::string, std::string> ConvergenceMonitor::parse_route_info(const void* route_data) const {
-    const struct nlmsghdr* nlh = static_cast<const struct nlmsghdr*>(route_data);
-    const struct rtmsg* rtm = static_cast<const struct rtmsg*>(NLMSG_DATA(nlh));
+void ConvergenceMonitor::annotate_table(std::unordered_map<std::string, std::string>& info) const {
+    auto table_it = info.find("table");
+    if (table_it != info.end() && !table_it->second.empty()) {
+        info["table_name"] = route_table_name(static_cast<uint32_t>(strtoul(table_it->second.c_str(), nullptr, 10)));
+    }
+}
+
+void ConvergenceMonitor::annotate_interface(std::unordered_map<std::string, std::string>& info) const {
+    if (config_.interface_links.empty()) {
+        return;
+    }
+    auto iface_it = info.find("interface");
+    if (iface_it == info.end()) {
+        return;
+    }
+    auto link_it = config_.interface_links.find(iface_it->second);
+    if (link_it != config_.interface_links.end()) {
+        info["link"] = link_it->second.link;
+        info["peer"] = link_it->second.peer;
+    }
+}
+
+bool ConvergenceMonitor::qdisc_was_netem(const std::unordered_map<std::string, std::string>& qdisc_info,
+                                         const std::string& event_type) const {
+    if (event_type != "QDISC_DEL") {
+        return false;
+    }
+    std::string key = qdisc_cache_key(qdisc_info);
+    if (key.empty()) {
+        return false;
+    }
+    std::lock_guard<std::mutex> lock(qdisc_events_mutex_);
+    auto cached_it = recent_qdisc_events_.find(key);
+    if (cached_it != recent_qdisc_events_.end() &&
+        get_current_timestamp_ms() - cached_it->second.timestamp <= config_.qdisc_cache_ttl_ms) {
+        auto event_netem_it = cached_it->second.info.find("is_netem");
+        return event_netem_it != cached_it->second.info.end() && event_netem_it->second == "true";
+    }
+    return false;
+}
+
+void ConvergenceMonitor::handle_trigger_event(int64_t timestamp, const std::string& event_type,
+                                             const std::unordered_map<std::string, std::string>& trigger_info,
+                                             const std::string& trigger_source) {
+    if (timestamp < warmup_end_ms_.load()) {
+        warmup_suppressed_++;
+        debug_note("trigger_suppressed_warmup", event_type, trigger_info);
+        return;
+    }
+
+    std::lock_guard<std::mutex> lock(session_mutex_);
+
+    // 如果当前有会话在进行且未收敛，不强制终止
+    if (current_session_ && !current_session_->is_converged.load()) {
+        debug_note("trigger_ignored_session_active", event_type, trigger_info);
+        info_out() << "⚠️  " << tr("忽略新", "Ignoring new ") << event_type_label(event_type)
+                   << tr("事件，会话 #", " event, session #") << current_session_->session_id
+                   << tr(" 仍在进行中", " still in progress") << "\n";
+        return;
+    }
+
+    // 开始新会话
+    int session_id = session_counter_.fetch_add(1) + 1;
+    current_session_ = std::make_unique<ConvergenceSession>(session_id, timestamp, trigger_info);
+    current_session_->tags = session_tags_;
+    current_session_->trigger_source = trigger_source;
+    if (!config_.threshold_overrides.empty()) {
+        auto iface_it = trigger_info.find("interface");
+        current_session_->threshold_override_ms = config_.threshold_overrides.match(
+            iface_it != trigger_info.end() ? iface_it->second : std::string(), trigger_source, event_type,
+            current_session_->threshold_rule);
+    }
+    state_.store(MonitorState::MONITORING);
+    if (fib_tracer_) {
+        current_session_->fib_events.assign(recent_fib_events_.begin(), recent_fib_events_.end());
+    }
+    // 触发接口所在的旧路径上仍可能有报文发出，不算恢复
+    if (dataplane_probe_) {
+        auto iface_it = trigger_info.find("interface");
+        dataplane_probe_->arm(iface_it != trigger_info.end() ? iface_it->second : std::string());
+    }
+
+    // 读取失败时会话照常进行，结束时不记录差值
+    if (config_.interface_counters) {
+        std::string error;
+        if (InterfaceCounterReader::read(current_session_->counters_at_trigger, error)) {
+            current_session_->counters_trigger_time = get_current_timestamp_ms();
+        } else {
+            info_out() << "⚠️  " << tr("读取接口计数失败: ", "Failed to read interface counters: ") << error << "\n";
+        }
+    }
+
+    // 文件名带监控实例ID的前8位，多次运行写入同一目录时不会覆盖
+    if (!config_.pcap.directory.empty()) {
+        std::string pcap_prefix = config_.pcap.directory + "/" + router_name_ + "-" + monitor_id_.substr(0, 8) +
+                      "-session" + std::to_string(session_id);
+        current_session_->capture = std::make_unique<SessionCapture>(config_.pcap);
+        current_session_->capture->start(pcap_prefix);
+    }
+
+    // 更新统计
+    if (trigger_source == "netem") {
+        total_netem_triggers_.fetch_add(1);
+    } else {
+        total_route_triggers_.fetch_add(1);
+    }
+
+    // 记录会话开始日志
+    std::string user = Logger::current_user();
+
+    auto session_start_log = Logger::create_session_start_log(
+        router_name_, session_id, trigger_source, event_type, trigger_info, user);
+    for (const auto& tag : session_tags_) {
+        session_start_log[tag.first] = tag.second;
+    }
+    auto trigger_link_it = trigger_info.find("link");
+    if (trigger_link_it != trigger_info.end()) {
+        session_start_log["link"] = trigger_link_it->second;
+    }
+    if (current_session_->threshold_override_ms) {
+        session_start_log["convergence_threshold_ms"] = *current_session_->threshold_override_ms;
+        session_start_log["threshold_override"] = current_session_->threshold_rule;
+    }
+    logger_->log_async(session_start_log);
+
+    if (frr_poller_) {
+        frr_poller_->request(session_id, "trigger");
+    }
+
+    // 控制台输出
+    if (trigger_source == "netem" || trigger_source == "snmp") {
+        info_out() << "🚀 " << tr("开始会话 #", "Session #") << session_id << tr(" (", " started (")
+                   << (trigger_source == "netem" ? "Netem" : "SNMP") << tr("触发: ", " trigger: ")
+                   << event_type_label(event_type) << ")\n";
+        auto iface_it = trigger_info.find("interface");
+        if (iface_it != trigger_info.end()) {
+            info_out() << "   " << tr("接口: ", "Interface: ") << iface_it->second << "\n";
+        }
+    } else if (trigger_source != "route") {
+        info_out() << "🚀 " << tr("开始会话 #", "Session #") << session_id << tr(" (", " started (")
+                   << trigger_source << tr("触发: ", " trigger: ") << event_type << ")\n";
+        auto iface_it = trigger_info.find("interface");
+        if (iface_it != trigger_info.end()) {
+            info_out() << "   " << tr("接口: ", "Interface: ") << iface_it->second << "\n";
+        }
+    } else {
+        info_out() << "🚀 " << tr("开始会话 #", "Session #") << session_id
+                   << tr(" (路由触发: ", " started (route trigger: ") << event_type_label(event_type) << ")\n";
+        auto dst_it = trigger_info.find("dst");
+        if (dst_it != trigger_info.end()) {
+            auto class_it = trigger_info.find("route_class");
+            info_out() << "   " << tr("目标: ", "Destination: ") << dst_it->second
+                       << (class_it != trigger_info.end() ? " (" + class_it->second + ")" : "") << "\n";
+        }
+    }
+    if (current_session_->threshold_override_ms) {
+        info_out() << "   " << tr("收敛阈值: ", "Convergence threshold: ") << *current_session_->threshold_override_ms
+                   << "ms (" << current_session_->threshold_rule << ")\n";
+    }
+}
+
+void ConvergenceMonitor::handle_qdisc_event(int64_t current_time,
+                                           const std::unordered_map<std::string, std::string>& qdisc_info,
+                                           const std::string& event_type) {
+
+    // 先按删除前的缓存判断，再更新缓存
+    RuleEvent rule_event;
+    rule_event.category = "qdisc";
+    rule_event.type = event_type;
+    std::transform(rule_event.type.begin(), rule_event.type.end(), rule_event.type.begin(), ::tolower);
+    rule_event.info = &qdisc_info;
+    rule_event.extra["was_netem"] = qdisc_was_netem(qdisc_info, event_type) ? "true" : "false";
+    remember_qdisc_event(current_time, event_type, qdisc_info);
+
+    if (config_.trigger_rules.is_ignored(rule_event)) {
+        debug_note("ignored_by_rule", event_type, qdisc_info);
+        return;
+    }
+    // 默认规则即netem的添加、修改、删除与替换
+    bool netem_related = config_.trigger_rules.is_trigger(rule_event);
+
+    // 检查是否为netem相关事件
+    if (netem_related) {
+        // 记录netem事件日志
+        std::string user = Logger::current_user();
+
+        auto netem_log = Logger::create_event_log("netem_detected", router_name_, user);
+        netem_log["netem_event_type"] = event_type;
+        netem_log["qdisc_info"] = ""; // 这里需要序列化qdisc_info
+        auto link_it = qdisc_info.find("link");
+        if (link_it != qdisc_info.end()) {
+            netem_log["link"] = link_it->second;
+        }
+        logger_->log_async(netem_log);
+
+        // 检查当前状态
+        MonitorState current_state;
+        bool is_monitoring;
+        bool debounced = false;
+        ConvergenceSession* session = nullptr;
+        {
+            std::lock_guard<std::mutex> lock(session_mutex_);
+            current_state = state_.load();
+            is_monitoring = (current_state == MonitorState::MONITORING &&
+                           current_session_ &&
+                           !current_session_->is_converged.load());
+            if (is_monitoring) {
+                session = current_session_.get();
+                // 同一条tc命令产生的多条qdisc消息(replace = 删除 + 添加)只算一次触发
+                auto iface_it = qdisc_info.find("interface");
+                auto trigger_iface_it = session->netem_info.find("interface");
+                if (config_.trigger_debounce_ms > 0 && session->trigger_source == "netem" &&
+                    current_time - session->netem_event_time <= config_.trigger_debounce_ms &&
+                    iface_it != qdisc_info.end() && trigger_iface_it != session->netem_info.end() &&
+                    iface_it->second == trigger_iface_it->second) {
+                    session->debounced_trigger_events++;
+                    debounced = true;
+                }
+            }
+        }
+
+        if (debounced) {
+            debug_note("trigger_debounced", event_type, qdisc_info);
+            auto debounce_log = Logger::create_event_log("trigger_debounced", router_name_, user);
+            debounce_log["session_id"] = static_cast<int64_t>(session->session_id);
+            debounce_log["qdisc_event_type"] = event_type;
+            debounce_log["offset_from_trigger_ms"] = current_time - session->netem_event_time;
+            for (const auto& pair : qdisc_info) {
+                debounce_log[pair.first] = pair.second;
+            }
+            logger_->log_async(debounce_log);
+        } else if (is_monitoring) {
+            // 当前有活跃会话，将netem事件作为普通路由事件处理
+            std::string netem_type = "netem_" + event_type;
+            std::transform(netem_type.begin(), netem_type.end(), netem_type.begin(), ::tolower);
+            session->add_route_event(current_time, netem_type, qdisc_info);
+
+            int64_t total_events = total_route_events_.fetch_add(1) + 1;
+            int64_t offset = current_time - session->netem_event_time;
+            int session_event_count = session->get_route_event_count();
+
+            // 记录路由事件日志
+            auto route_log = Logger::create_route_event_log(
+                router_name_, session->session_id, netem_type,
+                total_events, session_event_count, offset, qdisc_info, user);
+            logger_->log_async(route_log);
+        } else {
+            // 没有活跃会话，作为触发事件处理
+            handle_trigger_event(current_time, event_type, qdisc_info, "netem");
+        }
+    } else {
+        debug_note("qdisc_not_netem", event_type, qdisc_info);
+    }
+}
+
+void ConvergenceMonitor::handle_route_event(int64_t timestamp, const std::string& event_type,
+                                           const std::unordered_map<std::string, std::string>& route_info) {
+    // 检查是否应该作为触发事件
+    MonitorState current_state;
+    {
+        std::lock_guard<std::mutex> lock(session_mutex_);
+        current_state = state_.load();
+    }
+
+    RuleEvent rule_event;
+    rule_event.category = "route";
+    rule_event.type = event_type;
+    rule_event.info = &route_info;
+    if (config_.trigger_rules.is_ignored(rule_event)) {
+        debug_note("ignored_by_rule", event_type, route_info);
+        return;
+    }
+
+    bool is_rule = event_type == "rule_add" || event_type == "rule_del";
+    bool is_mroute = event_type == "mroute_add" || event_type == "mroute_del";
+    bool is_fdb = event_type == "fdb_add" || event_type == "fdb_del" || event_type == "fdb_move";
+    bool is_tunnel = event_type.rfind("tunnel_", 0) == 0;
+    bool is_lag = event_type == "lag_failover" || event_type == "lag_member_change" ||
+                  event_type == "lacp_state_change";
+    if (current_state == MonitorState::IDLE && config_.trigger_rules.is_trigger(rule_event)) {
+        // 作为触发事件处理
+        std::string trigger_type = event_type;
+
+        std::unordered_map<std::string, std::string> trigger_info;
+        trigger_info["type"] = trigger_type;
+
+        auto dst_it = route_info.find("dst");
+        trigger_info["dst"] = (dst_it != route_info.end()) ? dst_it->second : "N/A";
+
+        auto iface_it = route_info.find("interface");
+        trigger_info["interface"] = (iface_it != route_info.end()) ? iface_it->second : "N/A";
+
+        auto gw_it = route_info.find("gateway");
+        trigger_info["gateway"] = (gw_it != route_info.end()) ? gw_it->second : "N/A";
+
+        auto link_it = route_info.find("link");
+        if (link_it != route_info.end()) {
+            trigger_info["link"] = link_it->second;
+            trigger_info["peer"] = route_info.at("peer");
+        }
+
+        auto gnmi_it = route_info.find("gnmi_target");
+        if (gnmi_it != route_info.end()) {
+            trigger_info["gnmi_target"] = gnmi_it->second;
+        }
+
+        // 黑洞/不可达/禁止路由(如RTBH)没有下一跳，单独标出类别
+        auto class_it = route_info.find("route_class");
+        if (class_it != route_info.end()) {
+            trigger_info["route_class"] = class_it->second;
+        }
+
+        // SRv6路由的SID列表或本地SID行为
+        for (const char* key : {"encap", "sid_list", "seg6_mode", "seg6local_action"}) {
+            auto encap_it = route_info.find(key);
+            if (encap_it != route_info.end()) {
+                trigger_info[key] = encap_it->second;
+            }
+        }
+
+        // 组播路由以(源, 组)标识，出接口列表相当于下一跳
+        if (is_mroute) {
+            for (const char* key : {"source", "group", "oifs"}) {
+                auto mroute_it = route_info.find(key);
+                if (mroute_it != route_info.end()) {
+                    trigger_info[key] = mroute_it->second;
+                }
+            }
+        }
+
+        // MAC表项以MAC+VLAN/VNI标识，端口或远端VTEP相当于下一跳
+        if (is_fdb) {
+            for (const char* key : {"mac", "vlan", "vni", "remote", "master", "previous_interface", "previous_remote"}) {
+                auto fdb_it = route_info.find(key);
+                if (fdb_it != route_info.end()) {
+                    trigger_info[key] = fdb_it->second;
+                }
+            }
+        }
+
+        // 隧道以接口标识，端点相当于下一跳
+        if (is_tunnel) {
+            for (const char* key : {"kind", "link_state", "tunnel_local", "tunnel_remote", "previous_local",
+                                    "previous_remote", "public_key"}) {
+                auto tunnel_it = route_info.find(key);
+                if (tunnel_it != route_info.end()) {
+                    trigger_info[key] = tunnel_it->second;
+                }
+            }
+        }
+
+        // bond/team以接口标识，记录切换前后的活动成员与成员状态
+        if (is_lag) {
+            for (const char* key : {"bond_mode", "active_slave", "previous_active_slave", "ad_aggregator",
+                                    "previous_ad_aggregator", "master", "slave_state", "previous_slave_state",
+                                    "mii_status", "previous_mii_status", "ad_actor_state", "previous_ad_actor_state",
+                                    "ad_partner_state", "previous_ad_partner_state", "link_state", "previous_link_state"}) {
+                auto lag_it = route_info.find(key);
+                if (lag_it != route_info.end()) {
+                    trigger_info[key] = lag_it->second;
+                }
+            }
+        }
+
+        // 策略路由规则没有下一跳，记录选择器与查表动作
+        if (is_rule) {
+            for (const char* key : {"src", "src_len", "priority", "table", "action", "fwmark"}) {
+                auto rule_it = route_info.find(key);
+                if (rule_it != route_info.end()) {
+                    trigger_info[key] = rule_it->second;
+                }
+            }
+        }
+
+        handle_trigger_event(timestamp, event_type, trigger_info, "route");
+        return;
+    }
+
+    // 普通路由事件处理
+    ConvergenceSession* session = nullptr;
+    bool keep_detail;
+    {
+        std::lock_guard<std::mutex> lock(session_mutex_);
+        if (current_state != MonitorState::MONITORING || !current_session_) {
+            // 不在监控状态，忽略路由事件
+            debug_note("route_event_outside_session", event_type, route_info);
+            return;
+        }
+        session = current_session_.get();
+        if (config_.event_detail_summary) {
+            keep_detail = false;
+        } else {
+            keep_detail = reserve_event_slot_locked();
+            if (keep_detail) {
+                events_in_memory_++;
+            } else {
+                events_spilled_++;
+            }
+        }
+    }
+
+    // 添加路由事件到会话中(详细事件超出内存上限时只计数，完整记录见下面的route_event日志)
+    session->add_route_event(timestamp, event_type, route_info, keep_detail);
+
+    // 更新统计信息
+    int64_t total_events = total_route_events_.fetch_add(1) + 1;
+    int64_t offset = timestamp - session->netem_event_time;
+    int session_event_count = session->get_route_event_count();
+
+    if (console_throttle_->note_route_event(timestamp, session->session_id)) {
+        auto field = [&route_info](const char* name) {
+            auto it = route_info.find(name);
+            return it != route_info.end() ? it->second : std::string();
+        };
+        std::string target = field("dst");
+        if (!target.empty() && !field("dst_len").empty()) {
+            target += "/" + field("dst_len");
+        }
+        info_out() << "   ↪ +" << offset << "ms " << event_type_label(event_type)
+                   << (target.empty() ? "" : " " + target)
+                   << (field("gateway").empty() || field("gateway") == "N/A" ? "" : " via " + field("gateway"))
+                   << (field("interface").empty() || field("interface") == "N/A" ? "" : " dev " + field("interface"))
+                   << (field("table_name").empty() || field("table_name") == "main" ? "" : " table " + field("table_name"))
+                   << "\n";
+    }
+
+    if (config_.event_detail_summary) {
+        record_event_summary(*session, offset, session_event_count);
+        return;
+    }
+
+    // 记录路由事件日志
+    std::string user = Logger::current_user();
+
+    auto route_log = Logger::create_route_event_log(
+        router_name_, session->session_id, event_type,
+        total_events, session_event_count, offset, route_info, user);
+    auto class_it = route_info.find("route_class");
+    if (class_it != route_info.end()) {
+        route_log["route_class"] = class_it->second;
+    }
+    if (config_.coalesce_ms > 0) {
+        auto field = [&route_info](const char* name) {
+            auto it = route_info.find(name);
+            return it != route_info.end() ? it->second : "";
+        };
+        std::string key = std::to_string(session->session_id) + "|" + event_type + "|" + field("table") + "|" +
+                          field("dst") + "/" + field("dst_len") + "|" + field("gateway") + "|" + field("interface") + "|" +
+                          field("sid_list") + "|" + field("seg6local_action") + "|" + field("source") + "|" + field("oifs") + "|" +
+                          field("mac") + "|" + field("vlan") + "|" + field("vni") + "|" + field("remote") + "|" +
+                          field("tunnel_remote") + "|" + field("public_key");
+        coalesce_route_event(key, timestamp, offset, std::move(route_log));
+        return;
+    }
+    logger_->log_async(route_log);
+}
+
+void ConvergenceMonitor::coalesce_route_event(const std::string& key, int64_t timestamp, int64_t offset,
+                                              JsonObject log) {
+    std::vector<JsonObject> ready;
+    {
+        std::lock_guard<std::mutex> lock(coalesce_mutex_);
+        auto it = coalesce_pending_.find(key);
+        if (it != coalesce_pending_.end()) {
+            // 窗口从该组第一条事件开始计算，窗口内的重复事件只计数
+            if (timestamp - it->second.first_timestamp <= config_.coalesce_ms) {
+                it->second.count++;
+                it->second.last_offset = offset;
+                coalesced_events_++;
+                return;
+            }
+            ready.push_back(std::move(it->second.log));
+            if (it->second.count > 1) {
+                ready.back()["coalesced_count"] = it->second.count;
+                ready.back()["last_offset_from_trigger_ms"] = it->second.last_offset;
+            }
+            coalesce_pending_.erase(it);
+        }
+        coalesce_pending_.emplace(key, CoalescedRouteEvent{std::move(log), timestamp, offset, 1});
+    }
+    for (const auto& record : ready) {
+        logger_->log_async(record);
+    }
+}
+
+void ConvergenceMonitor::record_event_summary(const ConvergenceSession& session, int64_t offset,
+                                              int64_t session_total) {
+    EventRateBucket finished;
+    {
+        std::lock_guard<std::mutex> lock(summary_mutex_);
+        int64_t second = std::max<int64_t>(0, offset) / 1000;
+        if (rate_bucket_.second >= 0 &&
+            (rate_bucket_.session_id != session.session_id || rate_bucket_.second != second)) {
+            finished = rate_bucket_;
+            rate_bucket_ = EventRateBucket();
+        }
+        if (rate_bucket_.second < 0) {
+            rate_bucket_.session_id = session.session_id;
+            rate_bucket_.second = second;
+            rate_bucket_.end_ms = session.netem_event_time + (second + 1) * 1000;
+        }
+        rate_bucket_.count++;
+        rate_bucket_.last_offset = offset;
+        rate_bucket_.session_total = std::max(rate_bucket_.session_total, session_total);
+    }
+    if (finished.second >= 0) {
+        log_event_summary(finished);
+    }
+}
+
+void ConvergenceMonitor::flush_event_summary(bool all) {
+    if (!config_.event_detail_summary) {
+        return;
+    }
+
+    EventRateBucket finished;
+    {
+        std::lock_guard<std::mutex> lock(summary_mutex_);
+        if (rate_bucket_.second < 0 || (!all && get_current_timestamp_ms() < rate_bucket_.end_ms)) {
+            return;
+        }
+        finished = rate_bucket_;
+        rate_bucket_ = EventRateBucket();
+    }
+    log_event_summary(finished);
+}
+
+void ConvergenceMonitor::log_event_summary(const EventRateBucket& bucket) {
+    std::string user = Logger::current_user();
+
+    auto log = Logger::create_event_log("route_event_summary", router_name_, user);
+    log["session_id"] = static_cast<int64_t>(bucket.session_id);
+    log["second"] = bucket.second;
+    log["route_events"] = bucket.count;
+    log["session_route_events"] = bucket.session_total;
+    log["last_offset_from_trigger_ms"] = bucket.last_offset;
+    logger_->log_async(log);
+}
+
+void ConvergenceMonitor::check_warmup_end() {
+    int64_t warmup_end = warmup_end_ms_.load();
+    if (warmup_end == 0 || warmup_done_.load() || get_current_timestamp_ms() < warmup_end) {
+        return;
+    }
+    warmup_done_.store(true);
+
+    std::string user = Logger::current_user();
+    auto log = Logger::create_event_log("warmup_completed", router_name_, user);
+    log["warmup_ms"] = config_.warmup_ms;
+    log["suppressed_triggers"] = warmup_suppressed_.load();
+    logger_->log_async(log);
+
+    info_out() << "🌡️  " << tr("预热结束，忽略了 ", "Warm-up finished, ignored ") << warmup_suppressed_.load()
+               << tr(" 个触发事件，开始等待触发...", " trigger events, waiting for triggers...") << "\n";
+}
+
+void ConvergenceMonitor::count_churn(int64_t timestamp) {
+    if (!config_.churn_rate) {
+        return;
+    }
+
+    int64_t finished_second = -1, finished_count = 0;
+    {
+        std::lock_guard<std::mutex> lock(churn_mutex_);
+        int64_t second = timestamp / 1000;
+        if (churn_second_ >= 0 && churn_second_ != second) {
+            finished_second = churn_second_;
+            finished_count = churn_count_;
+            churn_count_ = 0;
+        }
+        churn_second_ = second;
+        churn_count_++;
+    }
+    if (finished_second >= 0) {
+        std::string user = Logger::current_user();
+        auto log = Logger::create_event_log("route_churn_rate", router_name_, user);
+        log["second"] = timestamp_json_value(finished_second * 1000000000);
+        log["route_changes"] = finished_count;
+        logger_->log_async(log);
+    }
+}
+
+void ConvergenceMonitor::flush_churn(bool all) {
+    if (!config_.churn_rate) {
+        return;
+    }
+
+    int64_t finished_second, finished_count;
+    {
+        std::lock_guard<std::mutex> lock(churn_mutex_);
+        if (churn_second_ < 0 || (!all && get_current_timestamp_ms() / 1000 <= churn_second_)) {
+            return;
+        }
+        finished_second = churn_second_;
+        finished_count = churn_count_;
+        churn_second_ = -1;
+        churn_count_ = 0;
+    }
+
+    std::string user = Logger::current_user();
+    auto log = Logger::create_event_log("route_churn_rate", router_name_, user);
+    log["second"] = timestamp_json_value(finished_second * 1000000000);
+    log["route_changes"] = finished_count;
+    logger_->log_async(log);
+}
+
+void ConvergenceMonitor::flush_console(bool all) {
+    std::string line = console_throttle_->flush(get_current_timestamp_ms(), all);
+    if (!line.empty()) {
+        info_out() << line << "\n";
+    }
+}
+
+void ConvergenceMonitor::flush_coalesced(bool all) {
+    if (config_.coalesce_ms <= 0) {
+        return;
+    }
+
+    int64_t now = get_current_timestamp_ms();
+    std::vector<CoalescedRouteEvent> ready;
+    {
+        std::lock_guard<std::mutex> lock(coalesce_mutex_);
+        for (auto it = coalesce_pending_.begin(); it != coalesce_pending_.end();) {
+            if (all || now - it->second.first_timestamp > config_.coalesce_ms) {
+                ready.push_back(std::move(it->second));
+                it = coalesce_pending_.erase(it);
+            } else {
+                ++it;
+            }
+        }
+    }
+
+    // 按各组第一条事件的时间顺序写出
+    std::sort(ready.begin(), ready.end(), [](const CoalescedRouteEvent& a, const CoalescedRouteEvent& b) {
+        return a.first_timestamp < b.first_timestamp;
+    });
+    for (auto& pending : ready) {
+        if (pending.count > 1) {
+            pending.log["coalesced_count"] = pending.count;
+            pending.log["last_offset_from_trigger_ms"] = pending.last_offset;
+        }
+        logger_->log_async(pending.log);
+    }
+}
+
+void ConvergenceMonitor::finish_current_session() {
+    if (!current_session_) {
+        return;
+    }
+
+    // 合并中的route_event记录与未写出的每秒汇总先于session_completed写出
+    flush_coalesced(true);
+    flush_event_summary(true);
+
+    if (dataplane_probe_) {
+        dataplane_probe_->disarm();
+    }
+
+    auto session = std::move(current_session_);
+    completed_sessions_.push_back(std::move(session));
+    finished_sessions_++;
+
+    // 记录会话完成日志
+    std::string user = Logger::current_user();
+
+    auto completed_session = completed_sessions_.back().get();
+    auto trigger_field = [completed_session](const char* key) {
+        auto it = completed_session->netem_info.find(key);
+        return it != completed_session->netem_info.end() ? it->second : std::string();
+    };
+    auto session_log = Logger::create_session_completed_log(
+        router_name_, completed_session->session_id,
+        completed_session->convergence_time,
+        completed_session->get_route_event_count(),
+        completed_session->get_session_duration(),
+        completed_session->threshold_override_ms.value_or(convergence_threshold_ms_.load()),
+        completed_session->netem_info,
+        user);
+    if (completed_session->timed_out) {
+        session_log["timed_out"] = true;
+    }
+    if (completed_session->forced) {
+        session_log["forced"] = true;
+    }
+    if (completed_session->threshold_override_ms) {
+        session_log["threshold_override"] = completed_session->threshold_rule;
+    }
+    QdiscStatsSummary qdisc =
+        QdiscStatsSummary::summarize(completed_session->qdisc_samples, completed_session->netem_event_time);
+    qdisc.add_to_json(session_log, config_.qdisc_stats_ms);
+    if (fib_tracer_) {
+        // 只有路由触发才有对应的内核FIB删除
+        bool route_trigger = completed_session->trigger_source == "route";
+        FibTraceSummary fib = FibTraceSummary::summarize(
+            completed_session->fib_events, completed_session->netem_event_time,
+            route_trigger ? trigger_field("dst") : "", route_trigger ? trigger_field("dst_len") : "",
+            completed_session->last_route_event_time);
+        completed_session->kernel_convergence_us = fib.convergence_us;
+        fib.add_to_json(session_log);
+    }
+    if (dataplane_probe_) {
+        DataplaneProbe::add_session_fields(session_log, completed_session->dataplane_packet,
+                                           completed_session->netem_event_time);
+    }
+    // 接口计数差值：有变化的接口各写一条interface_counters记录，触发接口的差值直接附在会话记录中
+    std::string trigger_interface = trigger_field("interface");
+    InterfaceCounterDelta counter_delta;
+    if (completed_session->counters_trigger_time > 0) {
+        std::string error;
+        if (InterfaceCounterReader::measure(completed_session->counters_at_trigger,
+                                            get_current_timestamp_ms() - completed_session->counters_trigger_time,
+                                            counter_delta, error)) {
+            for (const auto& pair : counter_delta.changed) {
+                auto counter_log = Logger::create_event_log("interface_counters", router_name_, user);
+                counter_log["session_id"] = static_cast<int64_t>(completed_session->session_id);
+                counter_log["interface"] = pair.first;
+                counter_log["interval_ms"] = counter_delta.interval_ms;
+                pair.second.add_to_json(counter_log, "", "_delta");
+                auto link = config_.interface_links.find(pair.first);
+                if (link != config_.interface_links.end()) {
+                    counter_log["link"] = link->second.link;
+                }
+                logger_->log_async(counter_log);
+            }
+            counter_delta.add_to_json(session_log, trigger_interface);
+        } else {
+            session_log["counter_error"] = error;
+        }
+    }
+    // 抓包在收敛时结束
+    std::vector<PcapFile> pcap_files;
+    if (completed_session->capture) {
+        pcap_files = completed_session->capture->stop();
+        SessionCapture::add_to_json(session_log, pcap_files);
+    }
+    for (const auto& tag : completed_session->tags) {
+        session_log[tag.first] = tag.second;
+    }
+    if (completed_session->netem_info.count("link")) {
+        session_log["link"] = trigger_field("link");
+    }
+    completed_session->add_event_counts_to_json(session_log);
+    if (config_.watch_default) {
+        completed_session->add_default_route_to_json(session_log);
+    }
+    // 收敛判定方式：prefix为目标前缀安装的时刻，quiet_period为静默期内最后一条事件
+    if (!config_.converged_prefix.text.empty()) {
+        bool reached = !completed_session->converged_prefix_nexthop.empty();
+        session_log["convergence_criterion"] = reached ? "prefix" : "quiet_period";
+        session_log["converged_prefix"] = config_.converged_prefix.text;
+        session_log["converged_prefix_reached"] = reached;
+        if (reached) {
+            session_log["converged_prefix_nexthop"] = completed_session->converged_prefix_nexthop;
+        }
+    }
+    completed_session->micro_loop.finish();
+    if (loop_prober_) {
+        completed_session->micro_loop.add_to_json(session_log);
+    }
+    completed_session->add_dual_stack_to_json(session_log);
+    completed_session->nexthops.add_to_json(session_log, config_.frr_window_ms);
+    if (completed_session->debounced_trigger_events > 0) {
+        session_log["debounced_trigger_events"] = static_cast<int64_t>(completed_session->debounced_trigger_events);
+    }
+    if (config_.event_detail_summary) {
+        // 没有逐条记录，最后一条事件的时间单独给出
+        session_log["event_detail"] = "summary";
+        if (completed_session->last_route_event_time.has_value()) {
+            session_log["last_route_event_timestamp"] =
+                timestamp_json_value(completed_session->last_route_event_time.value() * 1000000);
+        }
+    }
+    // 与滚动基线比较，只有真正收敛的会话参与，超时与强制结束的会话不计入基线
+    AnomalyResult anomaly;
+    if (anomaly_detector_ && !completed_session->timed_out && !completed_session->forced &&
+        completed_session->convergence_time.has_value()) {
+        anomaly = anomaly_detector_->observe(static_cast<double>(completed_session->convergence_time.value()));
+        if (anomaly.detected()) {
+            anomalies_detected_++;
+            session_log["anomaly"] = true;
+            session_log["anomaly_z_score"] = anomaly.z_score;
+        }
+    }
+    logger_->log_async(session_log);
+
+    if (anomaly.detected()) {
+        auto anomaly_log = Logger::create_event_log("anomaly_detected", router_name_, user);
+        anomaly_log["session_id"] = static_cast<int64_t>(completed_session->session_id);
+        anomaly_log["anomaly_type"] = anomaly.changepoint ? "changepoint" : "outlier";
+        anomaly_log["direction"] = anomaly.direction;
+        anomaly_log["convergence_time_ms"] = completed_session->convergence_time.value();
+        anomaly_log["baseline_mean_ms"] = anomaly.baseline_mean_ms;
+        anomaly_log["baseline_stddev_ms"] = anomaly.baseline_stddev_ms;
+        anomaly_log["baseline_sessions"] = static_cast<int64_t>(anomaly.baseline_sessions);
+        anomaly_log["z_score"] = anomaly.z_score;
+        anomaly_log["sigma"] = config_.anomaly_sigma;
+        logger_->log_async(anomaly_log);
+    }
+
+    maybe_send_alert(*completed_session, session_log);
+    run_session_hook(*completed_session, session_log);
+
+    if (frr_poller_) {
+        frr_poller_->request(completed_session->session_id,
+                             completed_session->timed_out ? "timeout" : "converged");
+    }
+
+    print_session_summary(*completed_session, qdisc, counter_delta, trigger_interface, pcap_files, anomaly);
+
+    // 重置状态
+    current_session_.reset();
+    state_.store(MonitorState::IDLE);
+}
+
+void ConvergenceMonitor::print_session_summary(const ConvergenceSession& session, const QdiscStatsSummary& qdisc,
+                                               const InterfaceCounterDelta& counters,
+                                               const std::string& trigger_interface,
+                                               const std::vector<PcapFile>& pcap_files,
+                                               const AnomalyResult& anomaly) const {
+    if (session.convergence_time.has_value()) {
+        info_out() << "   " << tr("收敛时间: ", "Convergence time: ") << session.convergence_time.value()
+                   << tr("ms, 路由事件: ", "ms, route events: ") << session.get_route_event_count() << "\n";
+    } else {
+        info_out() << "   " << tr("路由事件: ", "Route events: ") << session.get_route_event_count() << "\n";
+    }
+    if (session.kernel_convergence_us.has_value()) {
+        info_out() << "   " << tr("内核收敛时间: ", "Kernel convergence time: ")
+                   << session.kernel_convergence_us.value() / 1000.0 << "ms\n";
+    }
+    if (dataplane_probe_) {
+        const auto& packet = session.dataplane_packet;
+        if (packet.has_value()) {
+            info_out() << "   " << tr("数据面恢复: ", "Dataplane restored: ")
+                       << packet->restoration_ms(session.netem_event_time)
+                       << "ms (" << packet->interface << ")\n";
+        } else {
+            info_out() << "⚠️  " << tr("会话期间未观察到探测流的转发报文", "No probe flow packet forwarded during the session")
+                       << "\n";
+        }
+    }
+    if (session.dual_stack()) {
+        int64_t ipv4_ms = session.family_last_offsets.at("ipv4");
+        int64_t ipv6_ms = session.family_last_offsets.at("ipv6");
+        info_out() << "   " << tr("双栈收敛: IPv4=", "Dual-stack convergence: IPv4=") << ipv4_ms << "ms, IPv6="
+                   << ipv6_ms << tr("ms (相差", "ms (gap ") << std::abs(ipv6_ms - ipv4_ms) << "ms)\n";
+    }
+    if (session.nexthops.changes > 0) {
+        info_out() << "   " << tr("下一跳切换: ", "Nexthop changes: ") << session.nexthops.changes << "\n";
+    }
+    if (session.nexthops.frr_changes > 0) {
+        info_out() << "   " << tr("快速重路由生效: ", "Fast reroute active: ")
+                   << session.nexthops.frr_activation_offset.value() << "ms ("
+                   << session.nexthops.frr_changes << tr(" 个前缀)", " prefixes)");
+        if (session.convergence_time.has_value()) {
+            info_out() << tr(", 最终收敛: ", ", final convergence: ") << session.convergence_time.value()
+                       << "ms";
+        }
+        info_out() << "\n";
+    }
+    if (config_.watch_default && session.default_route_events > 0) {
+        auto nexthop = [](const std::string& value) { return value.empty() ? std::string("-") : value; };
+        if (session.default_restored_offset.has_value()) {
+            info_out() << "   " << tr("默认路由恢复: ", "Default route restored: ")
+                       << session.default_restored_offset.value() << "ms ("
+                       << nexthop(session.default_nexthop_before) << " -> "
+                       << nexthop(session.default_nexthop_after) << ")\n";
+        } else {
+            info_out() << "⚠️  " << tr("默认路由未恢复", "Default route not restored")
+                       << " (" << nexthop(session.default_nexthop_before) << " -> -)\n";
+        }
+    }
+
+    // 控制台只列出触发接口和有丢弃/错误的接口，其余见interface_counters记录
+    for (const auto& pair : counters.changed) {
+        const InterfaceCounters& delta = pair.second;
+        if (pair.first != trigger_interface &&
+            delta.rx_dropped + delta.rx_errors + delta.tx_dropped + delta.tx_errors == 0) {
+            continue;
+        }
+        info_out() << "   " << tr("接口计数 ", "Counters ") << pair.first << ": rx " << delta.rx_packets
+                   << tr(" 包", " pkts") << " (drop " << delta.rx_dropped << ", err " << delta.rx_errors
+                   << "), tx " << delta.tx_packets << tr(" 包", " pkts") << " (drop " << delta.tx_dropped
+                   << ", err " << delta.tx_errors << ")\n";
+    }
+    for (const auto& file : pcap_files) {
+        if (file.error.empty()) {
+            info_out() << "   " << tr("抓包: ", "Capture: ") << file.path << " (" << std::max<int64_t>(0, file.packets)
+                       << tr(" 个包)", " packets)") << "\n";
+        } else {
+            info_out() << "⚠️  " << tr("抓包失败 ", "Capture failed on ") << file.interface << ": " << file.error << "\n";
+        }
+    }
+    if (session.micro_loop.events > 0) {
+        info_out() << "   " << tr("微环路: 出现于 +", "Micro-loop: onset +")
+                   << session.micro_loop.onset_offset.value()
+                   << tr("ms, 累计 ", "ms, total ") << session.micro_loop.duration_ms << "ms ("
+                   << session.micro_loop.members << ")"
+                   << (session.micro_loop.unresolved ? tr("，结束时仍未消失", ", unresolved at session end")
+                                                     : "")
+                   << "\n";
+    }
+    if (qdisc.samples > 0) {
+        info_out() << "   qdisc " << qdisc.kind << " " << qdisc.handle << " "
+                   << tr("丢包 ", "drops ") << qdisc.drops
+                   << tr(", 最大积压 ", ", max backlog ") << qdisc.max_backlog
+                   << "B (" << qdisc.samples << tr(" 个采样)", " samples)") << "\n";
+    }
+
+    if (anomaly.detected()) {
+        std::ostringstream baseline;
+        baseline << std::fixed << std::setprecision(1) << anomaly.baseline_mean_ms << "±"
+                 << anomaly.baseline_stddev_ms << "ms (z=" << anomaly.z_score << ", ";
+        info_out() << "⚠️  " << (anomaly.changepoint ? tr("收敛时间变点: ", "Convergence changepoint: ")
+                                                     : tr("收敛时间异常: ", "Convergence anomaly: "))
+                   << session.convergence_time.value() << tr("ms, 基线 ", "ms, baseline ")
+                   << baseline.str() << anomaly.baseline_sessions << tr(" 个会话)", " sessions)") << "\n";
+    }
+}
+
+bool ConvergenceMonitor::reserve_event_slot_locked() {
+    int64_t limit = config_.max_events_in_memory;
+    if (limit <= 0) {
+        return true;
+    }
+
+    // 从最早完成的会话开始释放，直到低于上限或没有可释放的会话
+    while (events_in_memory_ >= limit && spill_cursor_ < completed_sessions_.size()) {
+        size_t released = completed_sessions_[spill_cursor_]->release_route_events();
+        events_in_memory_ -= static_cast<int64_t>(released);
+        events_spilled_ += static_cast<int64_t>(released);
+        spill_cursor_++;
+    }
+    return events_in_memory_ < limit;
+}
+
+void ConvergenceMonitor::force_finish_session(const std::string& reason) {
+    std::lock_guard<std::mutex> lock(session_mutex_);
+    if (current_session_) {
+        current_session_->force_finish();
+        info_out() << "📋 " << tr("强制结束会话 #", "Force-finishing session #") << current_session_->session_id
+                   << ": " << reason << "\n";
+        finish_current_session();
+    }
+}
+
+JsonObject ConvergenceMonitor::build_status() {
+    int64_t now = get_current_timestamp_ms();
+    JsonObject status;
+    status["router_name"] = router_name_;
+    status["monitor_id"] = monitor_id_;
+    status["uptime_ms"] = now - monitoring_start_time_;
+    status["convergence_threshold_ms"] = convergence_threshold_ms_.load();
+    status["netem_triggers"] = total_netem_triggers_.load();
+    status["route_triggers"] = total_route_triggers_.load();
+    status["route_events"] = total_route_events_.load();
+    status["subscription_restarts"] = netlink_monitor_->restart_count();
+    NetlinkQueueStats queue = netlink_monitor_->queue_stats();
+    status["netlink_backlog"] = queue.backlog;
+    status["netlink_dropped"] = queue.dropped;
+    status["netlink_overruns"] = queue.overruns;
+    status["error_events"] = error_events_.load();
+    if (now < warmup_end_ms_.load()) {
+        status["warmup_remaining_ms"] = warmup_end_ms_.load() - now;
+    }
+    read_process_usage(status);
+    {
+        std::lock_guard<std::mutex> lock(filter_mutex_);
+        if (!filter_.interfaces.empty()) {
+            status["filter_interfaces"] = filter_.interfaces_text();
+        }
+        if (!filter_.prefixes.empty()) {
+            status["filter_prefixes"] = filter_.prefixes_text();
+        }
+        if (!filter_.tables.empty()) {
+            status["filter_tables"] = filter_.tables_text();
+        }
+    }
+
+    std::lock_guard<std::mutex> lock(session_mutex_);
+    status["state"] = state_.load() == MonitorState::MONITORING ? "monitoring" : "idle";
+    status["completed_sessions"] = static_cast<int64_t>(completed_sessions_.size());
+    int64_t timed_out = 0;
+    for (const auto& session : completed_sessions_) {
+        if (session->timed_out) {
+            timed_out++;
+        }
+    }
+    status["timed_out_sessions"] = timed_out;
+    status["events_in_memory"] = events_in_memory_;
+    status["events_spilled"] = events_spilled_;
+    if (current_session_) {
+        status["session_id"] = static_cast<int64_t>(current_session_->session_id);
+        status["session_elapsed_ms"] = now - current_session_->netem_event_time;
+        status["session_route_events"] = static_cast<int64_t>(current_session_->get_route_event_count());
+        auto type_it = current_session_->netem_info.find("type");
+        if (type_it != current_session_->netem_info.end()) {
+            status["session_trigger"] = type_it->second;
+        }
+        if (current_session_->last_route_event_time.has_value()) {
+            status["session_quiet_ms"] = now - current_session_->last_route_event_time.value();
+        }
+    }
+    return status;
+}
+
+void ConvergenceMonitor::dump_status() {
+    JsonObject status = build_status();
+
+    std::string user = Logger::current_user();
+    auto log = Logger::create_event_log("status_report", router_name_, user);
+    for (const auto& field : status) {
+        log[field.first] = field.second;
+    }
+    logger_->log_async(log);
+
+    auto get = [&status](const char* key) {
+        auto it = status.find(key);
+        return it != status.end() ? it->second.as_int64() : 0;
+    };
+
+    // 先整体格式化再输出，避免与其他线程的控制台输出交错
+    std::ostringstream out;
+    out << "\n📟 " << tr("状态报告", "Status report") << " ("
+        << tr("已运行 ", "uptime ") << std::fixed << std::setprecision(1)
+        << (get("uptime_ms") / 1000.0) << tr("秒", "s") << ")\n";
+    if (status.count("session_id")) {
+        out << "   " << tr("状态: 监控中, 会话 #", "State: monitoring, session #") << get("session_id")
+            << tr(" 已持续 ", " running for ") << get("session_elapsed_ms")
+            << tr("ms, 路由事件 ", "ms, route events ") << get("session_route_events");
+        if (status.count("session_quiet_ms")) {
+            out << tr(", 距上次事件 ", ", quiet for ") << get("session_quiet_ms") << "ms";
+        }
+        out << "\n";
+    } else {
+        out << "   " << tr("状态: 空闲", "State: idle") << "\n";
+    }
+    out << "   " << tr("收敛阈值: ", "Convergence threshold: ") << get("convergence_threshold_ms") << "ms\n";
+    out << "   " << tr("触发事件: Netem=", "Triggers: netem=") << get("netem_triggers")
+        << tr(", 路由=", ", route=") << get("route_triggers")
+        << tr(", 路由事件: ", ", route events: ") << get("route_events")
+        << tr(", 完成会话: ", ", completed sessions: ") << get("completed_sessions")
+        << tr(" (超时 ", " (timed out ") << get("timed_out_sessions") << ")\n";
+    if (status.count("rss_kb")) {
+        out << "   " << tr("内存: RSS ", "Memory: RSS ") << (get("rss_kb") / 1024.0)
+            << tr("MB, 峰值 ", "MB, peak ") << (get("peak_rss_kb") / 1024.0)
+            << tr("MB, 线程 ", "MB, threads ") << get("threads") << "\n";
+    }
+    // 主动请求的报告，不受--log-level影响
+    std::cout << out.str();
+}
+
+int ConvergenceMonitor::handle_health_request(const std::string& path, std::string& body) {
+    // 读取线程每秒至少完成一个epoll周期，超过该时长没有心跳说明线程已退出或卡住
+    constexpr int64_t READER_STALL_MS = 5000;
+
+    bool readiness = path == "/readyz";
+    if (!readiness && path != "/healthz") {
+        body = "{\"status\":\"not_found\"}";
+        return 404;
+    }
+
+    int64_t now = get_current_timestamp_ms();
+    NetlinkHealth health = netlink_monitor_->health();
+    std::vector<std::string> problems;
+    if (!health.running) {
+        problems.push_back("netlink monitor stopped");
+    } else if (health.loop_age_ms > READER_STALL_MS) {
+        problems.push_back("netlink reader stalled for " + std::to_string(health.loop_age_ms) + "ms");
+    }
+    if (health.running && !health.subscribed) {
+        problems.push_back("netlink subscription lost (" + health.problem + ")");
+    }
+    if (config_.health_max_idle_ms > 0) {
+        int64_t idle_ms = now - std::max(health.last_route_message_ms, monitoring_start_time_);
+        if (idle_ms > config_.health_max_idle_ms) {
+            problems.push_back("no route messages for " + std::to_string(idle_ms) + "ms");
+        }
+    }
+
+    JsonObject response;
+    response["router_name"] = router_name_;
+    response["monitor_id"] = monitor_id_;
+    response["uptime_ms"] = now - monitoring_start_time_;
+    response["netlink_running"] = health.running;
+    response["reader_loop_age_ms"] = health.loop_age_ms;
+    response["subscribed"] = health.subscribed;
+    response["subscription_restarts"] = health.restart_count;
+    // -1表示启动后尚未收到该类消息
+    response["route_last_message_age_ms"] =
+        health.last_route_message_ms > 0 ? now - health.last_route_message_ms : static_cast<int64_t>(-1);
+    if (health.tc_monitoring) {
+        response["tc_last_message_age_ms"] =
+            health.last_tc_message_ms > 0 ? now - health.last_tc_message_ms : static_cast<int64_t>(-1);
+    }
+
+    if (readiness) {
+        if (!running_.load()) {
+            problems.push_back("monitor not running");
+        }
+        if (now < warmup_end_ms_.load()) {
+            response["warmup_remaining_ms"] = warmup_end_ms_.load() - now;
+            problems.push_back("warming up");
+        }
+        NetlinkQueueStats queue = netlink_monitor_->queue_stats();
+        response["netlink_backlog"] = queue.backlog;
+        if (queue.capacity > 0 && queue.backlog >= queue.capacity) {
+            problems.push_back("netlink queue full");
+        }
+    }
+
+    std::string joined;
+    for (const auto& problem : problems) {
+        joined += (joined.empty() ? "" : "; ") + problem;
+    }
+    response["status"] = problems.empty() ? "ok" : (readiness ? "not_ready" : "unhealthy");
+    if (!problems.empty()) {
+        response["problems"] = joined;
+    }
+    body = Logger::json_to_string(response);
+    return problems.empty() ? 200 : 503;
+}
+
+std::string ConvergenceMonitor::handle_control_command(const std::vector<std::string>& args) {
+    const std::string& command = args[0];
+    JsonObject response;
+    response["ok"] = true;
+    response["command"] = command;
+
+    std::string user = Logger::current_user();
+
+    if (command == "status") {
+        for (auto& field : build_status()) {
+            response[field.first] = field.second;
+        }
+    } else if (command == "force-finish") {
+        int session_id = 0;
+        {
+            std::lock_guard<std::mutex> lock(session_mutex_);
+            if (current_session_ && !current_session_->is_converged.load()) {
+                session_id = current_session_->session_id;
+            }
+        }
+        if (session_id == 0) {
+            response["ok"] = false;
+            response["error"] = "no active session";
+        } else {
+            force_finish_session(tr("控制套接字请求", "requested via control socket"));
+            response["session_id"] = static_cast<int64_t>(session_id);
+        }
+    } else if (command == "reset-stats") {
+        int64_t discarded;
+        {
+            std::lock_guard<std::mutex> lock(session_mutex_);
+            discarded = static_cast<int64_t>(completed_sessions_.size());
+            completed_sessions_.clear();
+            spill_cursor_ = 0;
+            events_in_memory_ = current_session_
+                ? static_cast<int64_t>(current_session_->retained_event_count()) : 0;
+            total_netem_triggers_.store(0);
+            total_route_triggers_.store(0);
+            total_route_events_.store(0);
+        }
+        auto log = Logger::create_event_log("stats_reset", router_name_, user);
+        log["discarded_sessions"] = discarded;
+        log["source"] = "control_socket";
+        logger_->log_async(log);
+        info_out() << "🎛️  " << tr("统计已清零，丢弃 ", "Statistics reset, discarded ") << discarded
+                   << tr(" 个已完成会话", " completed sessions") << "\n";
+        response["discarded_sessions"] = discarded;
+    } else if (command == "set-threshold") {
+        char* end = nullptr;
+        int64_t threshold = args.size() == 2 ? strtoll(args[1].c_str(), &end, 10) : 0;
+        if (args.size() != 2 || *end != '\0' || threshold <= 0) {
+            response["ok"] = false;
+            response["error"] = "usage: set-threshold MILLISECONDS";
+        } else {
+            response["previous_threshold_ms"] = apply_threshold(threshold, "control_socket");
+            response["convergence_threshold_ms"] = threshold;
+        }
+    } else if (command == "set-filter") {
+        EventFilter filter;
+        {
+            std::lock_guard<std::mutex> lock(filter_mutex_);
+            filter = filter_;
+        }
+        // 省略列表表示清空该条件
+        std::string list = args.size() >= 3 ? args[2] : "";
+        std::string error;
+        if (args.size() < 2 || args.size() > 3 ||
+            (args[1] != "interfaces" && args[1] != "prefixes" && args[1] != "tables")) {
+            response["ok"] = false;
+            response["error"] = "usage: set-filter interfaces|prefixes|tables [LIST]";
+        } else if ((args[1] == "prefixes" && !filter.set_prefixes(list, error)) ||
+                   (args[1] == "tables" && !filter.set_tables(list, error))) {
+            response["ok"] = false;
+            response["error"] = error;
+        } else {
+            if (args[1] == "interfaces") {
+                filter.set_interfaces(list);
+            }
+            apply_filter(filter, "control_socket");
+            response["filter_interfaces"] = filter.interfaces_text();
+            response["filter_prefixes"] = filter.prefixes_text();
+            response["filter_tables"] = filter.tables_text();
+        }
+    } else if (command == "reload") {
+        std::string error;
+        if (!reload_config(error)) {
+            response["ok"] = false;
+            response["error"] = error;
+        }
+    } else if (command == "help") {
+        response["commands"] = "status, force-finish, reset-stats, set-threshold MS, "
+                               "set-filter interfaces|prefixes|tables [LIST], reload";
+    } else {
+        response["ok"] = false;
+        response["error"] = "unknown command, try help";
+    }
+    return Logger::json_to_string(response);
+}
+
+int64_t ConvergenceMonitor::apply_threshold(int64_t threshold_ms, const std::string& source) {
+    int64_t previous = convergence_threshold_ms_.exchange(threshold_ms);
+    if (previous == threshold_ms) {
+        return previous;
+    }
+
+    std::string user = Logger::current_user();
+    auto log = Logger::create_event_log("threshold_changed", router_name_, user);
+    log["previous_threshold_ms"] = previous;
+    log["convergence_threshold_ms"] = threshold_ms;
+    log["source"] = source;
+    logger_->log_async(log);
+    info_out() << "🎛️  " << tr("收敛阈值: ", "Convergence threshold: ") << previous << "ms -> "
+               << threshold_ms << "ms\n";
+    return previous;
+}
+
+void ConvergenceMonitor::apply_filter(const EventFilter& filter, const std::string& source) {
+    {
+        std::lock_guard<std::mutex> lock(filter_mutex_);
+        if (filter.interfaces_text() == filter_.interfaces_text() &&
+            filter.prefixes_text() == filter_.prefixes_text() && filter.tables == filter_.tables) {
+            return;
+        }
+        filter_ = filter;
+    }
+
+    std::string user = Logger::current_user();
+    auto log = Logger::create_event_log("filter_changed", router_name_, user);
+    log["filter_interfaces"] = filter.interfaces_text();
+    log["filter_prefixes"] = filter.prefixes_text();
+    log["filter_tables"] = filter.tables_text();
+    log["source"] = source;
+    logger_->log_async(log);
+    info_out() << "🎛️  " << tr("事件过滤: 接口=", "Event filter: interfaces=")
+               << (filter.interfaces.empty() ? tr("全部", "all") : filter.interfaces_text())
+               << tr(", 前缀=", ", prefixes=")
+               << (filter.prefixes.empty() ? tr("全部", "all") : filter.prefixes_text())
+               << tr(", 路由表=", ", tables=")
+               << (filter.tables.empty() ? tr("全部", "all") : filter.tables_text()) << "\n";
+}
+
+bool ConvergenceMonitor::load_config_file(const std::string& path, MonitorConfig& config,
+                                          std::string& error) {
+    YamlNode root;
+    if (!YamlParser::parse_file(path, root, error)) {
+        return false;
+    }
+    if (!root.is_map()) {
+        error = path + ": expected a mapping";
+        return false;
+    }
+
+    // 列表既可以写成YAML序列，也可以写成逗号分隔的字符串
+    auto list_value = [](const YamlNode& node) {
+        if (!node.is_sequence()) {
+            return node.scalar;
+        }
+        std::string list;
+        for (const auto& item : node.items) {
+            list += (list.empty() ? "" : ",") + item.scalar;
+        }
+        return list;
+    };
+
+    MonitorConfig updated = config;
+    for (const auto& entry : root.entries) {
+        if (entry.first == "threshold_ms") {
+            char* end = nullptr;
+            int64_t threshold = strtoll(entry.second.scalar.c_str(), &end, 10);
+            if (entry.second.scalar.empty() || *end != '\0' || threshold <= 0) {
+                error = path + ": invalid threshold_ms " + entry.second.scalar;
+                return false;
+            }
+            updated.convergence_threshold_ms = threshold;
+        } else if (entry.first == "filter_interfaces") {
+            updated.filter.set_interfaces(list_value(entry.second));
+        } else if (entry.first == "filter_prefixes") {
+            if (!updated.filter.set_prefixes(list_value(entry.second), error)) {
+                error = path + ": " + error;
+                return false;
+            }
+        } else if (entry.first == "filter_tables") {
+            if (!updated.filter.set_tables(list_value(entry.second), error)) {
+                error = path + ": " + error;
+                return false;
+            }
+        } else {
+            error = path + ": unknown key " + entry.first;
+            return false;
+        }
+    }
+
+    config = updated;
+    return true;
+}
+
+bool ConvergenceMonitor::reload_config(std::string& error) {
+    if (config_.config_path.empty()) {
+        error = "no --config file";
+        return false;
+    }
+
+    // 启动后新建的VRF与修改过的rt_tables在重新加载后生效
+    reload_route_table_names();
+
+    // 文件中没有出现的键保持当前值
+    MonitorConfig current = config_;
+    current.convergence_threshold_ms = convergence_threshold_ms_.load();
+    {
+        std::lock_guard<std::mutex> lock(filter_mutex_);
+        current.filter = filter_;
+    }
+    if (!load_config_file(config_.config_path, current, error)) {
+        return false;
+    }
+
+    apply_threshold(current.convergence_threshold_ms, "config_reload");
+    apply_filter(current.filter, "config_reload");
+    return true;
+}
+
+void ConvergenceMonitor::run_session_hook(const ConvergenceSession& session, const JsonObject& session_log) {
+    if (!session_hook_) {
+        return;
+    }
+
+    // 变量名不与CONVERGE_<选项名>重叠，钩子中再次调用本工具时不会被当作参数
+    std::vector<std::string> env = {
+        "CONVERGE_SESSION_ID=" + std::to_string(session.session_id),
+        "CONVERGE_SESSION_ROUTER=" + router_name_,
+        "CONVERGE_SESSION_MONITOR_ID=" + monitor_id_,
+        "CONVERGE_SESSION_LOG=" + log_file_path_,
+        "CONVERGE_SESSION_TRIGGER_SOURCE=" + session.trigger_source,
+        "CONVERGE_SESSION_CONVERGENCE_MS=" +
+            (session.convergence_time ? std::to_string(session.convergence_time.value()) : std::string()),
+        "CONVERGE_SESSION_TIMED_OUT=" + std::string(session.timed_out ? "1" : "0"),
+        "CONVERGE_SESSION_ROUTE_EVENTS=" + std::to_string(session.get_route_event_count()),
+        "CONVERGE_SESSION_DURATION_MS=" + std::to_string(session.get_session_duration()),
+    };
+    auto iface_it = session.netem_info.find("interface");
+    env.push_back("CONVERGE_SESSION_INTERFACE=" +
+                  (iface_it != session.netem_info.end() ? iface_it->second : std::string()));
+    session_hook_->run(session.session_id, Logger::json_to_string(session_log), env);
+}
+
+void ConvergenceMonitor::handle_hook_result(const SessionHookResult& result) {
+    std::string user = Logger::current_user();
+
+    auto log = Logger::create_event_log("session_hook", router_name_, user);
+    log["session_id"] = result.session_id;
+    log["exit_code"] = static_cast<int64_t>(result.exit_code);
+    log["duration_ms"] = result.duration_ms;
+    log["hook_timed_out"] = result.timed_out;
+    log["output"] = result.output;
+    if (!result.error.empty()) {
+        log["error"] = result.error;
+    }
+    logger_->log_async(log);
+
+    if (result.exit_code != 0) {
+        info_out() << "⚠️  " << tr("会话 #", "Session #") << result.session_id
+                   << tr(" 钩子执行失败: ", " hook failed: ")
+                   << (result.error.empty() ? "exit " + std::to_string(result.exit_code) : result.error) << "\n";
+    }
+}
+
+void ConvergenceMonitor::maybe_send_alert(const ConvergenceSession& session,
+                                          const JsonObject& session_log) {
+    // 监听结束时被强制结束的会话不是故障，不告警
+    if (!alert_notifier_ || session.forced) {
+        return;
+    }
+
+    std::string reason;
+    if (session.timed_out) {
+        reason = "timeout";
+    } else if (config_.alert_threshold_ms > 0 &&
+               session.convergence_time.has_value() &&
+               session.convergence_time.value() > config_.alert_threshold_ms) {
+        reason = "slow_convergence";
+    } else {
+        return;
+    }
+
+    JsonObject payload = session_log;
+    payload["alert_reason"] = reason;
+    payload["alert_threshold_ms"] = config_.alert_threshold_ms;
+    alert_notifier_->notify(Logger::json_to_string(payload));
+
+    info_out() << "🔔 " << tr("会话 #", "Session #") << session.session_id << tr(" 已发送告警 (", " alert sent (")
+               << reason << ")\n";
+}
+
+void ConvergenceMonitor::log_frr_state(int session_id, const std::string& phase,
+                                       const FrrSnapshot& snapshot) {
+    std::string user = Logger::current_user();
+
+    auto log = Logger::create_event_log("frr_state", router_name_, user);
+    log["session_id"] = static_cast<int64_t>(session_id);
+    log["phase"] = phase;
+    for (const auto& pair : snapshot.to_json()) {
+        log[pair.first] = pair.second;
+    }
+    logger_->log_async(log);
+
+    if (!snapshot.error.empty()) {
+        log_error("warning", "frr_state", snapshot.error);
+    }
+}
+
+void ConvergenceMonitor::log_route_table_sample(const RouteTableSample& sample) {
+    std::string user = Logger::current_user();
+
+    auto log = Logger::create_event_log("route_table_sample", router_name_, user);
+    for (const auto& pair : sample.to_json()) {
+        log[pair.first] = pair.second;
+    }
+    if (sample.error.empty()) {
+        int64_t expected = -1;
+        first_route_total_.compare_exchange_strong(expected, sample.total());
+        last_route_total_.store(sample.total());
+        // 相对首次采样的变化，持续增长通常意味着陈旧路由没有被清理
+        log["change_since_start"] = sample.total() - first_route_total_.load();
+    }
+    {
+        std::lock_guard<std::mutex> lock(session_mutex_);
+        if (current_session_) {
+            log["session_id"] = static_cast<int64_t>(current_session_->session_id);
+        }
+    }
+    logger_->log_async(log);
+
+    if (!sample.error.empty()) {
+        log_error("warning", "route_table_sampler", sample.error);
+    }
+}
+
+void ConvergenceMonitor::log_error(const std::string& severity, const std::string& component,
+                                   const std::string& message) {
+    std::string user = Logger::current_user();
+
+    auto log = Logger::create_event_log("error", router_name_, user);
+    log["severity"] = severity;
+    log["component"] = component;
+    log["message"] = message;
+    {
+        std::lock_guard<std::mutex> lock(session_mutex_);
+        if (current_session_) {
+            log["session_id"] = static_cast<int64_t>(current_session_->session_id);
+        }
+    }
+    error_events_++;
+    logger_->log_async(log);
+}
+
+void ConvergenceMonitor::on_subscription_restarted(const std::string& reason, const std::string& detail) {
+    std::string user = Logger::current_user();
 
-    // 计算属性数据的起始位置和长度
-    int attrlen = nlh->nlmsg_len - NLMSG_LENGTH(sizeof(*rtm));
-    const struct rtattr* rta = reinterpret_cast<const struct rtattr*>(
-        reinterpret_cast<const char*>(rtm) + NLMSG_ALIGN(sizeof(*rtm)));
+    auto log = Logger::create_event_log("subscription_restarted", router_name_, user);
+    log["reason"] = reason;
+    log["error"] = detail;
+    log["restart_count"] = netlink_monitor_->restart_count();
+    {
+        // 重建期间的消息已经丢失，进行中的会话结果可能不完整
+        std::lock_guard<std::mutex> lock(session_mutex_);
+        if (current_session_) {
+            log["session_id"] = static_cast<int64_t>(current_session_->session_id);
+        }
+    }
+    logger_->log_async(log);
 
-    // 使用NetlinkMessageParser解析消息
-    return NetlinkMessageParser::parse_route_message(rtm, rta, attrlen);
+    info_out() << "⚠️  " << tr("netlink订阅已重建 (", "Netlink subscription restarted (") << reason;
+    if (!detail.empty()) {
+        info_out() << ": " << detail;
+    }
+    info_out() << ")\n";
 }
 
-std::unordered_map<std::string, std::string> ConvergenceMonitor::parse_qdisc_info(const void* qdisc_data) const {
-    const struct nlmsghdr* nlh = static_cast<const struct nlmsghdr*>(qdisc_data);
-    const struct tcmsg* tcm = static_cast<const struct tcmsg*>(NLMSG_DATA(nlh));
+void ConvergenceMonitor::handle_frr_log_event(const FrrLogEvent& event) {
+    std::string user = Logger::current_user();
 
-    // 计算属性数据的起始位置和长度
-    int attrlen = nlh->nlmsg_len - NLMSG_LENGTH(sizeof(*tcm));
-    const struct rtattr* rta = reinterpret_cast<const struct rtattr*>(
-        reinterpret_cast<const char*>(tcm) + NLMSG_ALIGN(sizeof(*tcm)));
+    auto log = Logger::create_event_log("frr_log_event", router_name_, user);
+    log["category"] = event.category;
+    log["daemon"] = event.daemon;
+    log["message"] = event.message;
+    log["frr_timestamp"] = timestamp_json_value(event.timestamp_ms * 1000000);
+
+    // 关联到进行中的会话，偏移量以FRR日志自身的时间戳计算
+    {
+        std::lock_guard<std::mutex> lock(session_mutex_);
+        if (current_session_) {
+            int64_t offset = event.timestamp_ms - current_session_->netem_event_time;
+            log["session_id"] = static_cast<int64_t>(current_session_->session_id);
+            log["offset_from_trigger_ms"] = offset;
+            if (console_throttle_->allow_line(get_current_timestamp_ms())) {
+                info_out() << "   📜 FRR " << event.category << " +" << offset << "ms: "
+                           << event.message << "\n";
+            }
+        }
+    }
 
-    // 使用NetlinkMessageParser解析消息
-    return NetlinkMessageParser::parse_qdisc_message(tcm, rta, attrlen);
+    logger_->log_async(log);
 }
 
-bool ConvergenceMonitor::is_netem_related_event(const std::unordered_map<std::string, std::string>& qdisc_info,
-                                               const std::string& event_type) const {
-    // 检查是否为netem类型
-    auto it = qdisc_info.find("is_netem");
-    if (it != qdisc_info.end() && it->second == "true") {
-        return true;
+void ConvergenceMonitor::handle_igp_adjacency_event(const IgpAdjacencyEvent& event) {
+    std::string user = Logger::current_user();
+
+    auto log = Logger::create_event_log("igp_adjacency_event", router_name_, user);
+    log["protocol"] = event.protocol;
+    log["neighbor"] = event.neighbor;
+    log["interface"] = event.interface;
+    log["old_state"] = event.old_state;
+    log["new_state"] = event.new_state;
+    auto link = config_.interface_links.find(event.interface);
+    if (link != config_.interface_links.end()) {
+        log["link"] = link->second.link;
     }
 
-    // 对于删除事件，检查最近的事件
-    if (event_type == "QDISC_DEL") {
-        auto iface_it = qdisc_info.find("interface");
-        if (iface_it != qdisc_info.end()) {
-            std::string interface_name = iface_it->second;
-
-            std::lock_guard<std::mutex> lock(qdisc_events_mutex_);
-
-            // 创建临时队列来遍历
-            std::queue<QdiscEvent> temp_queue = recent_qdisc_events_;
-            while (!temp_queue.empty()) {
-                const auto& event = temp_queue.front();
-                auto event_iface_it = event.info.find("interface");
-                if (event_iface_it != event.info.end() &&
-                    event_iface_it->second == interface_name) {
-                    auto event_netem_it = event.info.find("is_netem");
-                    if (event_netem_it != event.info.end() &&
-                        event_netem_it->second == "true") {
-                        return true;
-                    }
-                }
-                temp_queue.pop();
-            }
+    // 邻接Down通常先于路由删除被检测到，此时尚无会话；报告阶段按时间回溯关联
+    {
+        std::lock_guard<std::mutex> lock(session_mutex_);
+        if (current_session_) {
+            int64_t offset = event.timestamp_ms - current_session_->netem_event_time;
+            log["session_id"] = static_cast<int64_t>(current_session_->session_id);
+            log["offset_from_trigger_ms"] = offset;
         }
     }
 
-    return false;
-}
+    info_out() << "🤝 " << event.protocol << tr(" 邻接 ", " adjacency ") << event.neighbor
+               << " (" << event.interface << "): " << event.old_state << " -> " << event.new_state << "\n";
 
-void ConvergenceMonitor::handle_trigger_event(int64_t timestamp, const std::string& event_type,
-                                             const std::unordered_map<std::string, std::string>& trigger_info,
-                                             const std::string& trigger_source) {
-    std::lock_guard<std::mutex> lock(session_mutex_);
+    logger_->log_async(log);
+}
 
-    // 如果当前有会话在进行且未收敛，不强制终止
-    if (current_session_ && !current_session_->is_converged.load()) {
-        std::cout << "⚠️  忽略新" << event_type << "事件，会话 #"
-                  << current_session_->session_id << " 仍在进行中\n";
-        return;
+void ConvergenceMonitor::handle_gnmi_update(const GnmiUpdate& update) {
+    // 转换为与netlink事件相同的信息表，进入同一会话模型
+    std::unordered_map<std::string, std::string> info;
+    info["source"] = "gnmi";
+    info["gnmi_target"] = update.source.empty() ? config_.gnmi.target : update.source;
+    info["gnmi_timestamp"] = format_timestamp(update.timestamp_ms);
+    for (const auto& tag : update.tags) {
+        info[tag.first] = tag.second;
+    }
+    auto iface_it = update.tags.find("interface_name");
+    if (iface_it != update.tags.end()) {
+        info["interface"] = iface_it->second;
+    }
+    for (const auto& value : update.values) {
+        info[value.first] = value.second;
     }
 
-    // 开始新会话
-    int session_id = session_counter_.fetch_add(1) + 1;
-    current_session_ = std::make_unique<ConvergenceSession>(session_id, timestamp, trigger_info);
-    state_.store(MonitorState::MONITORING);
-
-    // 更新统计
-    if (trigger_source == "netem") {
-        total_netem_triggers_.fetch_add(1);
+    // 路由表项的增删可以触发会话，其余更新(接口状态等)只记入进行中的会话
+    // 设备时钟与本机可能不同步，偏移量统一使用本机接收时间
+    std::string prefix = update.prefix();
+    std::string event_type;
+    if (!prefix.empty()) {
+        info["dst"] = prefix;
+        event_type = update.deletes.empty() ? "route_add" : "route_del";
     } else {
-        total_route_triggers_.fetch_add(1);
+        event_type = update.deletes.empty() ? "gnmi_update" : "gnmi_delete";
     }
+    handle_route_event(get_current_timestamp_ms(), event_type, info);
+}
 
-    // 记录会话开始日志
-    std::string user = []() {
-        struct passwd* pw = getpwuid(getuid());
-        return pw ? std::string(pw->pw_name) : "unknown";
-    }();
+void ConvergenceMonitor::handle_clock_sample(const ClockSample& sample) {
+    if (!sample.error.empty()) {
+        // 参考节点暂时不可达时只在第一次失败时记录，恢复后再失败会再次记录
+        if (!clock_failing_.exchange(true)) {
+            log_error("warning", "clock", "clock reference " + sample.reference + ": " + sample.error);
+        }
+        return;
+    }
+    clock_failing_.store(false);
+    bool first = clock_samples_.fetch_add(1) == 0;
+    last_clock_offset_ms_.store(sample.offset_ms);
 
-    auto session_start_log = Logger::create_session_start_log(
-        router_name_, session_id, trigger_source, event_type, trigger_info, user);
-    logger_->log_async(session_start_log);
+    std::string user = Logger::current_user();
 
-    // 控制台输出
-    if (trigger_source == "netem") {
-        std::cout << "🚀 开始会话 #" << session_id << " (Netem触发: " << event_type << ")\n";
-        auto iface_it = trigger_info.find("interface");
-        if (iface_it != trigger_info.end()) {
-            std::cout << "   接口: " << iface_it->second << "\n";
-        }
-    } else {
-        std::cout << "🚀 开始会话 #" << session_id << " (路由触发: " << event_type << ")\n";
-        auto dst_it = trigger_info.find("dst");
-        if (dst_it != trigger_info.end()) {
-            std::cout << "   目标: " << dst_it->second << "\n";
+    auto log = Logger::create_event_log("clock_offset", router_name_, user);
+    log["reference"] = sample.reference;
+    log["offset_ms"] = sample.offset_ms;
+    log["rtt_ms"] = sample.rtt_ms;
+    log["probes"] = static_cast<int64_t>(sample.probes);
+    {
+        std::lock_guard<std::mutex> lock(session_mutex_);
+        if (current_session_) {
+            log["session_id"] = static_cast<int64_t>(current_session_->session_id);
         }
     }
+    logger_->log_async(log);
+
+    // 控制台只输出第一次测量和之后1ms以上的变化
+    if (first || std::abs(sample.offset_ms - printed_clock_offset_ms_.load()) >= 1.0) {
+        printed_clock_offset_ms_.store(sample.offset_ms);
+        std::ostringstream text;
+        text << std::fixed << std::setprecision(3) << std::showpos << sample.offset_ms << std::noshowpos
+             << "ms, RTT " << sample.rtt_ms << "ms";
+        info_out() << "🕒 " << tr("时钟偏差 (参考 ", "Clock offset (reference ") << sample.reference << "): "
+                   << text.str() << "\n";
+    }
 }
 
-void ConvergenceMonitor::handle_qdisc_event(const std::unordered_map<std::string, std::string>& qdisc_info,
-                                           const std::string& event_type) {
-    int64_t current_time = get_current_timestamp_ms();
+void ConvergenceMonitor::handle_snmp_trap(const SnmpTrap& trap) {
+    std::string name = trap.name();
+
+    std::unordered_map<std::string, std::string> info;
+    info["source"] = "snmp";
+    info["snmp_agent"] = trap.source;
+    info["trap_oid"] = trap.trap_oid;
+    info["trap_name"] = name.empty() ? trap.trap_oid : name;
+    std::string iface = trap.interface();
+    if (!iface.empty()) {
+        info["interface"] = iface;
+    }
+    for (const auto& varbind : trap.varbinds) {
+        info[varbind.first] = varbind.second;
+    }
+
+    // 未识别的trap只记录，不参与会话
+    if (name.empty() || name == "coldStart" || name == "warmStart") {
+        std::string user = Logger::current_user();
+        auto log = Logger::create_event_log("snmp_trap", router_name_, user);
+        log["snmp_agent"] = trap.source;
+        log["trap_oid"] = trap.trap_oid;
+        log["snmp_version"] = static_cast<int64_t>(trap.version);
+        JsonObject varbinds;
+        for (const auto& varbind : trap.varbinds) {
+            varbinds[varbind.first] = varbind.second;
+        }
+        log["varbinds"] = Logger::json_to_string(varbinds);
+        logger_->log_async(log);
+        return;
+    }
 
-    // 缓存qdisc事件
+    // 与netem事件相同：空闲时作为触发，会话进行中时作为普通事件
+    std::string event_type = "snmp_" + name;
+    ConvergenceSession* session = nullptr;
     {
-        std::lock_guard<std::mutex> lock(qdisc_events_mutex_);
-        recent_qdisc_events_.emplace(current_time, event_type, qdisc_info);
-        if (recent_qdisc_events_.size() > MAX_QDISC_EVENTS) {
-            recent_qdisc_events_.pop();
+        std::lock_guard<std::mutex> lock(session_mutex_);
+        if (state_.load() == MonitorState::MONITORING && current_session_ &&
+            !current_session_->is_converged.load()) {
+            session = current_session_.get();
         }
     }
 
-    // 检查是否为netem相关事件
-    if (is_netem_related_event(qdisc_info, event_type)) {
-        // 记录netem事件日志
-        std::string user = []() {
-            struct passwd* pw = getpwuid(getuid());
-            return pw ? std::string(pw->pw_name) : "unknown";
-        }();
+    if (!session) {
+        handle_trigger_event(trap.timestamp_ms, event_type, info, "snmp");
+        return;
+    }
 
-        auto netem_log = Logger::create_event_log("netem_detected", router_name_, user);
-        netem_log["netem_event_type"] = event_type;
-        netem_log["qdisc_info"] = ""; // 这里需要序列化qdisc_info
-        logger_->log_async(netem_log);
+    session->add_route_event(trap.timestamp_ms, event_type, info);
 
-        // 检查当前状态
-        MonitorState current_state;
-        bool is_monitoring;
-        ConvergenceSession* session = nullptr;
-        {
-            std::lock_guard<std::mutex> lock(session_mutex_);
-            current_state = state_.load();
-            is_monitoring = (current_state == MonitorState::MONITORING &&
-                           current_session_ &&
-                           !current_session_->is_converged.load());
-            if (is_monitoring) {
-                session = current_session_.get();
-            }
-        }
+    int64_t total_events = total_route_events_.fetch_add(1) + 1;
+    int64_t offset = trap.timestamp_ms - session->netem_event_time;
+    int session_event_count = session->get_route_event_count();
 
-        if (is_monitoring) {
-            // 当前有活跃会话，将netem事件作为普通路由事件处理
-            session->add_route_event(current_time, "Netem事件(" + event_type + ")", qdisc_info);
+    std::string user = Logger::current_user();
+    auto route_log = Logger::create_route_event_log(
+        router_name_, session->session_id, event_type,
+        total_events, session_event_count, offset, info, user);
+    logger_->log_async(route_log);
+}
 
-            int64_t total_events = total_route_events_.fetch_add(1) + 1;
-            int64_t offset = current_time - session->netem_event_time;
-            int session_event_count = session->get_route_event_count();
+void ConvergenceMonitor::handle_source_event(const std::string& source, const SourceEvent& event) {
+    int64_t timestamp = event.timestamp_ms > 0 ? event.timestamp_ms : get_current_timestamp_ms();
+    std::unordered_map<std::string, std::string> info = event.fields;
+    info["source"] = source;
 
-            // 记录路由事件日志
-            auto route_log = Logger::create_route_event_log(
-                router_name_, session->session_id, "Netem事件(" + event_type + ")",
-                total_events, session_event_count, offset, qdisc_info, user);
-            logger_->log_async(route_log);
-        } else {
-            // 没有活跃会话，作为触发事件处理
-            handle_trigger_event(current_time, event_type, qdisc_info, "netem");
+    ConvergenceSession* session = nullptr;
+    {
+        std::lock_guard<std::mutex> lock(session_mutex_);
+        if (state_.load() == MonitorState::MONITORING && current_session_ &&
+            !current_session_->is_converged.load()) {
+            session = current_session_.get();
         }
     }
-}
 
-void ConvergenceMonitor::handle_route_event(int64_t timestamp, const std::string& event_type,
-                                           const std::unordered_map<std::string, std::string>& route_info) {
-    // 检查是否应该作为触发事件
-    MonitorState current_state;
-    {
-        std::lock_guard<std::mutex> lock(session_mutex_);
-        current_state = state_.load();
+    // 与SNMP Trap相同：触发类事件空闲时开始会话，会话进行中时作为普通事件
+    if (event.trigger && !session) {
+        handle_trigger_event(timestamp, event.kind, info, source);
+        return;
     }
 
-    if ((event_type == "路由添加" || event_type == "路由删除") &&
-        current_state == MonitorState::IDLE) {
-        // 作为触发事件处理
-        std::string trigger_type = (event_type == "路由添加") ? "route_add" : "route_del";
+    std::string user = Logger::current_user();
 
-        std::unordered_map<std::string, std::string> trigger_info;
-        trigger_info["type"] = trigger_type;
+    if (event.trigger) {
+        session->add_route_event(timestamp, event.kind, info);
+        int64_t total_events = total_route_events_.fetch_add(1) + 1;
+        auto route_log = Logger::create_route_event_log(
+            router_name_, session->session_id, event.kind,
+            total_events, session->get_route_event_count(), timestamp - session->netem_event_time, info, user);
+        logger_->log_async(route_log);
+        return;
+    }
 
-        auto dst_it = route_info.find("dst");
-        trigger_info["dst"] = (dst_it != route_info.end()) ? dst_it->second : "N/A";
+    // 非触发事件只记录，会话进行中时附带会话与偏移
+    auto log = Logger::create_event_log("source_event", router_name_, user);
+    log["source"] = source;
+    log["source_event_type"] = event.kind;
+    JsonObject fields;
+    for (const auto& field : event.fields) {
+        fields[field.first] = field.second;
+    }
+    log["fields"] = Logger::json_to_string(fields);
+    if (session) {
+        log["session_id"] = static_cast<int64_t>(session->session_id);
+        log["offset_from_trigger_ms"] = timestamp - session->netem_event_time;
+    }
+    logger_->log_async(log);
+}
 
-        auto iface_it = route_info.find("interface");
-        trigger_info["interface"] = (iface_it != route_info.end()) ? iface_it->second : "N/A";
+void ConvergenceMonitor::handle_bmp_message(const BmpMessage& message) {
+    if (message.type == BmpMessage::STATISTICS_REPORT) {
+        return;
+    }
 
-        auto gw_it = route_info.find("gateway");
-        trigger_info["gateway"] = (gw_it != route_info.end()) ? gw_it->second : "N/A";
+    std::string user = Logger::current_user();
+
+    auto log = Logger::create_event_log("bgp_event", router_name_, user);
+    log["bmp_message_type"] = BmpMessage::type_name(message.type);
+    log["bmp_router"] = message.router;
+    if (message.type == BmpMessage::INITIATION) {
+        log["sys_name"] = message.sys_name;
+    } else if (!message.peer_address.empty()) {
+        log["peer_address"] = message.peer_address;
+        log["peer_as"] = static_cast<int64_t>(message.peer_as);
+        log["peer_bgp_id"] = message.peer_bgp_id;
+        log["bgp_timestamp"] = timestamp_json_value(message.timestamp_ms * 1000000);
+    }
+    if (message.type == BmpMessage::PEER_DOWN) {
+        log["peer_down_reason"] = static_cast<int64_t>(message.peer_down_reason);
+    }
 
-        handle_trigger_event(timestamp, event_type, trigger_info, "route");
-        return;
+    if (message.type == BmpMessage::ROUTE_MONITORING) {
+        if (message.announced.empty() && message.withdrawn.empty()) {
+            return;  // End-of-RIB等空UPDATE
+        }
+        log["announced_count"] = static_cast<int64_t>(message.announced.size());
+        log["withdrawn_count"] = static_cast<int64_t>(message.withdrawn.size());
+
+        // 大批量UPDATE只保留前若干个前缀，避免日志膨胀
+        constexpr size_t MAX_LOGGED_PREFIXES = 20;
+        auto join = [](const std::vector<std::string>& prefixes) {
+            std::string joined;
+            for (size_t i = 0; i < prefixes.size() && i < MAX_LOGGED_PREFIXES; ++i) {
+                if (i > 0) joined += ",";
+                joined += prefixes[i];
+            }
+            return joined;
+        };
+        log["announced"] = join(message.announced);
+        log["withdrawn"] = join(message.withdrawn);
     }
 
-    // 普通路由事件处理
-    ConvergenceSession* session = nullptr;
+    // 关联到进行中的会话，偏移量以BMP每对等体头中的时间戳计算
     {
         std::lock_guard<std::mutex> lock(session_mutex_);
-        if (current_state != MonitorState::MONITORING || !current_session_) {
-            return; // 不在监控状态，忽略路由事件
+        if (current_session_) {
+            int64_t offset = message.timestamp_ms - current_session_->netem_event_time;
+            log["session_id"] = static_cast<int64_t>(current_session_->session_id);
+            log["offset_from_trigger_ms"] = offset;
+            if (console_throttle_->allow_line(get_current_timestamp_ms())) {
+                info_out() << "   📡 BGP " << BmpMessage::type_name(message.type) << " +" << offset << "ms";
+                if (!message.peer_address.empty()) {
+                    info_out() << tr(" 邻居 ", " peer ") << message.peer_address;
+                }
+                if (message.type == BmpMessage::ROUTE_MONITORING) {
+                    info_out() << tr(" 通告 ", " announced ") << message.announced.size()
+                               << tr(" 撤销 ", " withdrawn ") << message.withdrawn.size();
+                }
+                info_out() << "\n";
+            }
         }
-        session = current_session_.get();
     }
 
-    // 添加路由事件到会话中
-    session->add_route_event(timestamp, event_type, route_info);
+    logger_->log_async(log);
+}
 
-    // 更新统计信息
-    int64_t total_events = total_route_events_.fetch_add(1) + 1;
-    int64_t offset = timestamp - session->netem_event_time;
-    int session_event_count = session->get_route_event_count();
+void ConvergenceMonitor::set_session_tags(const std::unordered_map<std::string, std::string>& tags) {
+    std::lock_guard<std::mutex> lock(session_mutex_);
+    // 静态标签始终保留，动态标签可覆盖同名键
+    session_tags_ = config_.session_tags;
+    for (const auto& tag : tags) {
+        session_tags_[tag.first] = tag.second;
+    }
+}
 
-    // 记录路由事件日志
-    std::string user = []() {
-        struct passwd* pw = getpwuid(getuid());
-        return pw ? std::string(pw->pw_name) : "unknown";
-    }();
+void ConvergenceMonitor::log_external_event(const std::string& event_type,
+                                            const std::unordered_map<std::string, std::string>& fields) {
+    std::string user = Logger::current_user();
 
-    auto route_log = Logger::create_route_event_log(
-        router_name_, session->session_id, event_type,
-        total_events, session_event_count, offset, route_info, user);
-    logger_->log_async(route_log);
+    auto log = Logger::create_event_log(event_type, router_name_, user);
+    for (const auto& pair : fields) {
+        log[pair.first] = pair.second;
+    }
+    logger_->log_async(log);
 }
 
-void ConvergenceMonitor::finish_current_session() {
-    if (!current_session_) {
-        return;
-    }
+SlaSummary ConvergenceMonitor::evaluate_sla() {
+    std::lock_guard<std::mutex> lock(session_mutex_);
+    return summarize_sla(completed_sessions_, config_.sla_ms);
+}
 
-    auto session = std::move(current_session_);
-    completed_sessions_.push_back(std::move(session));
+SlaSummary ConvergenceMonitor::summarize_sla(const std::vector<std::unique_ptr<ConvergenceSession>>& sessions,
+                                             int64_t sla_ms) {
+    SlaSummary summary;
+    summary.sla_ms = sla_ms;
 
-    // 记录会话完成日志
-    std::string user = []() {
-        struct passwd* pw = getpwuid(getuid());
-        return pw ? std::string(pw->pw_name) : "unknown";
-    }();
+    for (const auto& session : sessions) {
+        // 监听结束时被强制结束的会话没有真正的收敛结果，历史会话属于之前的运行，都不参与评估
+        if (session->forced || session->resumed) {
+            continue;
+        }
+        summary.sessions++;
 
-    auto completed_session = completed_sessions_.back().get();
-    auto session_log = Logger::create_session_completed_log(
-        router_name_, completed_session->session_id,
-        completed_session->convergence_time,
-        completed_session->get_route_event_count(),
-        completed_session->get_session_duration(),
-        convergence_threshold_ms_,
-        completed_session->netem_info,
-        user);
-    logger_->log_async(session_log);
+        int64_t convergence = session->convergence_time.has_value()
+                                  ? session->convergence_time.value() : 0;
+        summary.max_convergence_ms = std::max(summary.max_convergence_ms, convergence);
 
-    // 控制台输出
-    if (completed_session->convergence_time.has_value()) {
-        std::cout << "   收敛时间: " << completed_session->convergence_time.value()
-                  << "ms, 路由事件: " << completed_session->get_route_event_count() << "\n";
-    } else {
-        std::cout << "   路由事件: " << completed_session->get_route_event_count() << "\n";
+        if (session->timed_out || (summary.sla_ms > 0 && convergence > summary.sla_ms)) {
+            summary.violations++;
+        }
     }
 
-    // 重置状态
-    current_session_.reset();
-    state_.store(MonitorState::IDLE);
+    return summary;
 }
 
-void ConvergenceMonitor::force_finish_session(const std::string& reason) {
+std::vector<SessionSummary> ConvergenceMonitor::get_completed_sessions() {
     std::lock_guard<std::mutex> lock(session_mutex_);
-    if (current_session_) {
-        current_session_->check_convergence(0); // 强制收敛
-        std::cout << "📋 强制结束会话 #" << current_session_->session_id
-                  << ": " << reason << "\n";
-        finish_current_session();
+
+    std::vector<SessionSummary> summaries;
+    summaries.reserve(completed_sessions_.size());
+    for (const auto& session : completed_sessions_) {
+        if (session->resumed) {
+            continue;
+        }
+        SessionSummary summary;
+        summary.session_id = session->session_id;
+        summary.trigger_time_ms = session->netem_event_time;
+        summary.convergence_time_ms = session->convergence_time;
+        summary.route_events = session->get_route_event_count();
+        summary.duration_ms = session->get_session_duration();
+        summary.timed_out = session->timed_out;
+        summary.forced = session->forced;
+        summary.trigger_info = session->netem_info;
+        summary.tags = session->tags;
+        summaries.push_back(std::move(summary));
     }
+
+    return summaries;
 }
 
 void ConvergenceMonitor::print_statistics() {
-    // 强制结束当前会话
+    flush_console(true);
+
+    // 强制结束当前会话（force_finish_session内部自行加锁）
+    bool has_active_session;
     {
         std::lock_guard<std::mutex> lock(session_mutex_);
-        if (current_session_ && !current_session_->is_converged.load()) {
-            force_finish_session("监听结束");
-        }
+        has_active_session = current_session_ && !current_session_->is_converged.load();
+    }
+    if (has_active_session) {
+        force_finish_session(tr("监听结束", "monitoring stopped"));
     }
 
+    flush_coalesced(true);
+    flush_event_summary(true);
+    flush_churn(true);
+    // 先写完已排队的记录，日志写出统计才完整
+    logger_->flush();
+
     int64_t current_time = get_current_timestamp_ms();
     int64_t total_time = current_time - monitoring_start_time_;
 
@@ -576,10 +3585,55 @@ void ConvergenceMonitor::print_statistics() {
     std::vector<int64_t> session_durations;
     std::unordered_set<std::string> interface_set;
 
+    // 丢弃类路由：会话内事件与作为触发的事件分别按类别累计
+    std::map<std::string, int64_t> discard_route_events;
+    std::map<std::string, int64_t> discard_route_triggers;
+    // --watch-default：已恢复会话的默认路由恢复用时与未恢复的会话数
+    std::vector<int64_t> default_restore_times;
+    int64_t default_not_restored = 0;
+    // --loop-probe：出现过微环路的会话数与累计环路时长
+    int64_t micro_loop_sessions = 0;
+    int64_t micro_loop_total_ms = 0;
+    // --dataplane-probe：观察到数据面恢复的会话数
+    int64_t dataplane_restored_sessions = 0;
+    // --frr-window-ms：快速重路由生效的会话的生效时间
+    std::vector<int64_t> frr_activation_times;
+    // 双栈会话：两个地址族各自的收敛时间与差值
+    std::vector<int64_t> dual_stack_ipv4;
+    std::vector<int64_t> dual_stack_ipv6;
+    std::vector<int64_t> dual_stack_gaps;
+
     for (const auto& session : completed_sessions_) {
         if (session->convergence_time.has_value()) {
             convergence_times.push_back(session->convergence_time.value());
         }
+        for (const auto& count : session->route_class_counts) {
+            discard_route_events[count.first] += count.second;
+        }
+        auto class_it = session->netem_info.find("route_class");
+        if (class_it != session->netem_info.end()) {
+            discard_route_triggers[class_it->second]++;
+        }
+        if (session->default_restored_offset.has_value()) {
+            default_restore_times.push_back(session->default_restored_offset.value());
+        } else if (session->default_lost_offset.has_value()) {
+            default_not_restored++;
+        }
+        if (session->dataplane_packet.has_value()) {
+            dataplane_restored_sessions++;
+        }
+        if (session->nexthops.frr_activation_offset.has_value()) {
+            frr_activation_times.push_back(session->nexthops.frr_activation_offset.value());
+        }
+        if (session->dual_stack()) {
+            dual_stack_ipv4.push_back(session->family_last_offsets.at("ipv4"));
+            dual_stack_ipv6.push_back(session->family_last_offsets.at("ipv6"));
+            dual_stack_gaps.push_back(std::abs(dual_stack_ipv6.back() - dual_stack_ipv4.back()));
+        }
+        if (session->micro_loop.events > 0) {
+            micro_loop_sessions++;
+            micro_loop_total_ms += session->micro_loop.duration_ms;
+        }
         route_counts.push_back(session->get_route_event_count());
         session_durations.push_back(session->get_session_duration());
 
@@ -589,12 +3643,7 @@ void ConvergenceMonitor::print_statistics() {
             interface_set.insert(iface_it->second);
         }
 
-        for (const auto& route_event : session->route_events) {
-            auto route_iface_it = route_event.info.find("interface");
-            if (route_iface_it != route_event.info.end()) {
-                interface_set.insert(route_iface_it->second);
-            }
-        }
+        interface_set.insert(session->route_interfaces.begin(), session->route_interfaces.end());
     }
 
     // 收敛时间分布
@@ -606,16 +3655,75 @@ void ConvergenceMonitor::print_statistics() {
     }
 
     // 记录最终统计日志
-    std::string user = []() {
-        struct passwd* pw = getpwuid(getuid());
-        return pw ? std::string(pw->pw_name) : "unknown";
-    }();
+    std::string user = Logger::current_user();
 
     int64_t total_triggers = total_netem_triggers + total_route_triggers;
     auto final_log = Logger::create_monitoring_completed_log(
-        router_name_, log_file_path_, user, total_time, convergence_threshold_ms_,
+        router_name_, log_file_path_, user, total_time, convergence_threshold_ms_.load(),
         total_triggers, total_netem_triggers, total_route_triggers,
         total_route_events, completed_sessions_.size(), monitor_id_);
+    if (!stop_reason_.empty()) {
+        final_log["stop_reason"] = stop_reason_;
+    }
+    if (config_.resume) {
+        final_log["resumed_sessions"] = resumed_sessions_;
+    }
+    if (qdisc_cache_evicted_.load() > 0) {
+        final_log["qdisc_cache_evicted"] = qdisc_cache_evicted_.load();
+    }
+    std::sort(default_restore_times.begin(), default_restore_times.end());
+    if (config_.watch_default) {
+        final_log["default_route_restored_sessions"] = static_cast<int64_t>(default_restore_times.size());
+        final_log["default_route_not_restored_sessions"] = default_not_restored;
+        if (!default_restore_times.empty()) {
+            final_log["fastest_default_restore_ms"] = default_restore_times.front();
+            final_log["slowest_default_restore_ms"] = default_restore_times.back();
+            final_log["avg_default_restore_ms"] =
+                std::accumulate(default_restore_times.begin(), default_restore_times.end(), 0.0) /
+                default_restore_times.size();
+        }
+    }
+    if (loop_prober_) {
+        final_log["micro_loop_sessions"] = micro_loop_sessions;
+        final_log["micro_loop_total_ms"] = micro_loop_total_ms;
+    }
+    if (!config_.dataplane_flow.dst.empty()) {
+        final_log["dataplane_restored_sessions"] = dataplane_restored_sessions;
+    }
+    auto average = [](const std::vector<int64_t>& values) {
+        return std::accumulate(values.begin(), values.end(), 0.0) / values.size();
+    };
+    if (!dual_stack_gaps.empty()) {
+        final_log["dual_stack_sessions"] = static_cast<int64_t>(dual_stack_gaps.size());
+        final_log["avg_ipv4_convergence_ms"] = average(dual_stack_ipv4);
+        final_log["avg_ipv6_convergence_ms"] = average(dual_stack_ipv6);
+        final_log["avg_dual_stack_gap_ms"] = average(dual_stack_gaps);
+        final_log["max_dual_stack_gap_ms"] = *std::max_element(dual_stack_gaps.begin(), dual_stack_gaps.end());
+    }
+    std::sort(frr_activation_times.begin(), frr_activation_times.end());
+    if (nexthop_tracker_ && config_.frr_window_ms > 0) {
+        final_log["frr_activated_sessions"] = static_cast<int64_t>(frr_activation_times.size());
+        if (!frr_activation_times.empty()) {
+            final_log["fastest_frr_activation_ms"] = frr_activation_times.front();
+            final_log["slowest_frr_activation_ms"] = frr_activation_times.back();
+            final_log["avg_frr_activation_ms"] =
+                std::accumulate(frr_activation_times.begin(), frr_activation_times.end(), 0.0) /
+                frr_activation_times.size();
+        }
+    }
+    if (anomaly_detector_) {
+        final_log["anomalies_detected"] = anomalies_detected_;
+    }
+    for (const auto& count : discard_route_events) {
+        final_log[count.first + "_route_events"] = count.second;
+    }
+    for (const auto& count : discard_route_triggers) {
+        final_log[count.first + "_route_triggers"] = count.second;
+    }
+    if (config_.warmup_ms > 0) {
+        final_log["warmup_ms"] = config_.warmup_ms;
+        final_log["warmup_suppressed_triggers"] = warmup_suppressed_.load();
+    }
 
     // 添加详细统计信息
     if (!convergence_times.empty()) {
@@ -627,26 +3735,197 @@ void ConvergenceMonitor::print_statistics() {
         final_log["avg_convergence_time_ms"] = sum / convergence_times.size();
     }
 
+    if (config_.sla_ms > 0) {
+        auto sla = evaluate_sla();
+        final_log["sla_ms"] = sla.sla_ms;
+        final_log["sla_violations"] = static_cast<int64_t>(sla.violations);
+        final_log["sla_passed"] = sla.passed();
+    }
+
+    int64_t subscription_restarts = netlink_monitor_ ? netlink_monitor_->restart_count() : 0;
+    if (subscription_restarts > 0) {
+        final_log["subscription_restarts"] = subscription_restarts;
+    }
+    NetlinkQueueStats queue = netlink_monitor_ ? netlink_monitor_->queue_stats() : NetlinkQueueStats();
+    final_log["netlink_messages"] = queue.received;
+    final_log["netlink_dropped"] = queue.dropped;
+    final_log["netlink_overruns"] = queue.overruns;
+    final_log["netlink_max_backlog"] = queue.max_backlog;
+    final_log["netlink_queue_size"] = queue.capacity;
+    final_log["netlink_workers"] = static_cast<int64_t>(config_.netlink_workers);
+    if (clock_probe_) {
+        final_log["clock_reference"] = config_.clock_reference;
+        final_log["clock_samples"] = clock_samples_.load();
+        if (clock_samples_.load() > 0) {
+            final_log["clock_offset_ms"] = last_clock_offset_ms_.load();
+        }
+    }
+    if (clock_server_) {
+        final_log["clock_requests_served"] = clock_server_->request_count();
+    }
+    if (nexthop_tracker_) {
+        final_log["nexthop_changes"] = nexthop_changes_.load();
+        final_log["nexthop_cache_routes"] = static_cast<int64_t>(nexthop_tracker_->size());
+        int64_t overflow = nexthop_tracker_->overflow();
+        if (overflow > 0) {
+            final_log["nexthop_cache_overflow"] = overflow;
+        }
+    }
+    int64_t coalesced_events;
+    {
+        std::lock_guard<std::mutex> lock(coalesce_mutex_);
+        coalesced_events = coalesced_events_;
+    }
+    if (config_.coalesce_ms > 0) {
+        final_log["coalesce_ms"] = config_.coalesce_ms;
+        final_log["coalesced_events"] = coalesced_events;
+    }
+    if (config_.max_events_in_memory > 0) {
+        final_log["max_events_in_memory"] = config_.max_events_in_memory;
+        final_log["events_spilled"] = events_spilled_;
+    }
+    // 自身开销，用于确认高频变化测试中监控器本身不是瓶颈
+    struct rusage usage;
+    getrusage(RUSAGE_SELF, &usage);
+    int64_t cpu_user_ms = usage.ru_utime.tv_sec * 1000 + usage.ru_utime.tv_usec / 1000;
+    int64_t cpu_system_ms = usage.ru_stime.tv_sec * 1000 + usage.ru_stime.tv_usec / 1000;
+    double cpu_percent = total_time > 0 ? 100.0 * (cpu_user_ms + cpu_system_ms) / total_time : 0.0;
+    final_log["cpu_user_ms"] = cpu_user_ms;
+    final_log["cpu_system_ms"] = cpu_system_ms;
+    final_log["cpu_percent"] = cpu_percent;
+    final_log["max_rss_kb"] = static_cast<int64_t>(usage.ru_maxrss);
+    final_log["threads"] = monitor_threads_;
+    final_log["log_records"] = logger_->records_written();
+    final_log["log_records_dropped"] = logger_->records_dropped();
+    final_log["log_bytes_written"] = logger_->bytes_written();
+
+    int64_t error_events = error_events_.load();
+    final_log["error_events"] = error_events;
+    int64_t first_route_total = first_route_total_.load();
+    int64_t last_route_total = last_route_total_.load();
+    if (first_route_total >= 0) {
+        final_log["route_table_routes_start"] = first_route_total;
+        final_log["route_table_routes_end"] = last_route_total;
+    }
+
     logger_->log_sync(final_log);
+    statistics_logged_.store(true);
 
     // 控制台输出统计摘要
-    std::cout << "\n📊 监控统计摘要\n";
-    std::cout << "   路由器: " << router_name_ << "\n";
-    std::cout << "   监听时长: " << (total_time / 1000.0) << "秒\n";
-    std::cout << "   触发事件: " << total_triggers
-              << ", 路由事件: " << total_route_events
-              << ", 完成会话: " << completed_sessions_.size() << "\n";
+    info_out() << "\n📊 " << tr("监控统计摘要", "Monitoring summary") << "\n";
+    info_out() << "   " << tr("路由器: ", "Router: ") << router_name_ << "\n";
+    info_out() << "   " << tr("监听时长: ", "Duration: ") << (total_time / 1000.0) << tr("秒", "s") << "\n";
+    info_out() << "   " << tr("触发事件: ", "Trigger events: ") << total_triggers
+               << tr(", 路由事件: ", ", route events: ") << total_route_events
+               << tr(", 完成会话: ", ", completed sessions: ") << completed_sessions_.size() << "\n";
 
     if (!convergence_times.empty()) {
         double avg = std::accumulate(convergence_times.begin(), convergence_times.end(), 0.0) / convergence_times.size();
-        std::cout << "   收敛时间: 最快=" << convergence_times.front()
-                  << "ms, 最慢=" << convergence_times.back()
-                  << "ms, 平均=" << std::fixed << std::setprecision(1) << avg << "ms\n";
-        std::cout << "   分布: 快速(<100ms)=" << fast_convergence
-                  << ", 中等(100-1000ms)=" << medium_convergence
-                  << ", 慢速(>1000ms)=" << slow_convergence << "\n";
+        info_out() << "   " << tr("收敛时间: 最快=", "Convergence: min=") << convergence_times.front()
+                   << tr("ms, 最慢=", "ms, max=") << convergence_times.back()
+                   << tr("ms, 平均=", "ms, avg=") << std::fixed << std::setprecision(1) << avg << "ms\n";
+        info_out() << "   " << tr("分布: 快速(<100ms)=", "Distribution: fast(<100ms)=") << fast_convergence
+                   << tr(", 中等(100-1000ms)=", ", medium(100-1000ms)=") << medium_convergence
+                   << tr(", 慢速(>1000ms)=", ", slow(>1000ms)=") << slow_convergence << "\n";
+    }
+
+    if (!default_restore_times.empty() || default_not_restored > 0) {
+        info_out() << "   " << tr("默认路由恢复: ", "Default route restore: ");
+        if (!default_restore_times.empty()) {
+            double avg = std::accumulate(default_restore_times.begin(), default_restore_times.end(), 0.0) /
+                         default_restore_times.size();
+            info_out() << tr("最快=", "min=") << default_restore_times.front()
+                       << tr("ms, 最慢=", "ms, max=") << default_restore_times.back()
+                       << tr("ms, 平均=", "ms, avg=") << std::fixed << std::setprecision(1) << avg << "ms";
+        }
+        if (default_not_restored > 0) {
+            info_out() << (default_restore_times.empty() ? "" : ", ") << tr("未恢复会话 ", "not restored in ")
+                       << default_not_restored << tr("", " sessions");
+        }
+        info_out() << "\n";
+    }
+    if (!dual_stack_gaps.empty()) {
+        info_out() << "   " << tr("双栈会话: ", "Dual-stack sessions: ") << dual_stack_gaps.size()
+                   << tr(", 平均收敛 IPv4=", ", avg convergence IPv4=") << std::fixed << std::setprecision(1)
+                   << average(dual_stack_ipv4) << "ms, IPv6=" << average(dual_stack_ipv6)
+                   << tr("ms, 平均相差=", "ms, avg gap=") << average(dual_stack_gaps)
+                   << tr("ms, 最大相差=", "ms, max gap=") << *std::max_element(dual_stack_gaps.begin(), dual_stack_gaps.end())
+                   << "ms\n";
+    }
+    if (!frr_activation_times.empty()) {
+        double avg = std::accumulate(frr_activation_times.begin(), frr_activation_times.end(), 0.0) /
+                     frr_activation_times.size();
+        info_out() << "   " << tr("快速重路由: ", "Fast reroute: ") << frr_activation_times.size()
+                   << tr(" 个会话, 生效 最快=", " sessions, activation min=") << frr_activation_times.front()
+                   << tr("ms, 最慢=", "ms, max=") << frr_activation_times.back()
+                   << tr("ms, 平均=", "ms, avg=") << std::fixed << std::setprecision(1) << avg << "ms\n";
+    }
+    if (anomalies_detected_ > 0) {
+        info_out() << "   " << tr("异常会话: ", "Anomalous sessions: ") << anomalies_detected_ << "\n";
+    }
+    if (micro_loop_sessions > 0) {
+        info_out() << "   " << tr("微环路: ", "Micro-loops: ") << micro_loop_sessions
+                   << tr(" 个会话, 累计 ", " sessions, total ") << micro_loop_total_ms << "ms\n";
+    }
+    if (!discard_route_events.empty() || !discard_route_triggers.empty()) {
+        info_out() << "   " << tr("丢弃类路由: ", "Discard routes: ");
+        bool first = true;
+        for (const char* route_class : {"blackhole", "unreachable", "prohibit"}) {
+            int64_t events = discard_route_events[route_class];
+            int64_t triggers = discard_route_triggers[route_class];
+            if (events == 0 && triggers == 0) {
+                continue;
+            }
+            info_out() << (first ? "" : ", ") << route_class << "=" << events
+                       << tr(" (触发 ", " (triggers ") << triggers << ")";
+            first = false;
+        }
+        info_out() << "\n";
+    }
+
+    info_out() << "   " << tr("netlink消息: ", "Netlink messages: ") << queue.received
+               << tr(", 丢弃: ", ", dropped: ") << queue.dropped
+               << tr(", 内核溢出: ", ", kernel overruns: ") << queue.overruns
+               << tr(", 最大积压: ", ", max backlog: ") << queue.max_backlog << "/" << queue.capacity << "\n";
+    if (queue.dropped > 0 || queue.overruns > 0) {
+        info_out() << "⚠️  " << tr("有netlink消息丢失，收敛结果可能不完整；可增大--netlink-buffer",
+                                  "Netlink messages were lost, results may be incomplete; consider a larger --netlink-buffer")
+                   << "\n";
+    }
+    info_out() << "   " << tr("自身开销: CPU ", "Self usage: CPU ") << (cpu_user_ms + cpu_system_ms) << "ms ("
+               << std::fixed << std::setprecision(1) << cpu_percent << "%)"
+               << tr(", 最大内存 ", ", max RSS ") << usage.ru_maxrss << "KB"
+               << tr(", 线程 ", ", threads ") << monitor_threads_
+               << tr(", 日志 ", ", log ") << logger_->records_written() << tr("条/", " records/")
+               << logger_->bytes_written() << tr("字节", " bytes");
+    if (logger_->records_dropped() > 0) {
+        info_out() << tr(" (丢弃 ", " (dropped ") << logger_->records_dropped() << ")";
+    }
+    info_out() << "\n";
+    if (coalesced_events > 0) {
+        info_out() << "   " << tr("合并的重复路由事件: ", "Coalesced duplicate route events: ") << coalesced_events
+                   << tr(" (窗口 ", " (window ") << config_.coalesce_ms << "ms)\n";
+    }
+    if (events_spilled_ > 0) {
+        info_out() << "   " << tr("已从内存释放的详细路由事件: ", "Route event details released from memory: ")
+                   << events_spilled_ << tr(" (完整记录见JSON日志)", " (full records are in the JSON log)") << "\n";
+    }
+    if (first_route_total >= 0) {
+        int64_t change = last_route_total - first_route_total;
+        info_out() << "   " << tr("路由表规模: 开始 ", "Route table size: start ") << first_route_total
+                   << tr(", 结束 ", ", end ") << last_route_total
+                   << " (" << (change >= 0 ? "+" : "") << change << ")\n";
+    }
+    if (error_events > 0) {
+        info_out() << "⚠️  " << tr("运行期间记录了 ", "Logged ") << error_events
+                   << tr(" 条error事件，实验结果可能受影响，详见JSON日志", " error events, results may be affected; see the JSON log")
+                   << "\n";
+    }
+    if (subscription_restarts > 0) {
+        info_out() << "   " << tr("netlink订阅重建: ", "Netlink subscription restarts: ")
+                   << subscription_restarts << tr("次", "") << "\n";
     }
 
-    std::cout << "   JSON日志已保存到: " << log_file_path_ << "\n";
-    std::cout << "✅ 监控完成\n";
+    info_out() << "   " << tr("JSON日志已保存到: ", "JSON log saved to: ") << log_file_path_ << "\n";
+    info_out() << "✅ " << tr("监控完成", "Monitoring completed") << "\n";
 }
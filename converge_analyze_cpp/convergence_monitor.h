@@ -6,9 +6,10 @@
 #include <atomic>
 #include <mutex>
 #include <thread>
-#include <queue>
 #include <condition_variable>
 #include <chrono>
+#include <deque>
+#include <map>
 #include <unordered_map>
 #include <unordered_set>
 #include <functional>
@@ -61,10 +62,267 @@
 
 #include "logger.h"
 #include "netlink_monitor.h"
+#include "alert_notifier.h"
+#include "session_hook.h"
+#include "frr_state.h"
+#include "route_table_sampler.h"
+#include "frr_log.h"
+#include "bmp_collector.h"
+#include "igp_adjacency.h"
+#include "gnmi_subscriber.h"
+#include "snmp_trap.h"
+#include "record_sink.h"
+#include "sqlite_store.h"
+#include "tui_dashboard.h"
+#include "debug_log.h"
+#include "control_server.h"
+#include "health_server.h"
+#include "clock_sync.h"
+#include "console_detail.h"
+#include "event_filter.h"
+#include "event_source.h"
+#include "threshold_override.h"
+#include "trigger_rule.h"
+#include "link_tracker.h"
+#include "nexthop_tracker.h"
+#include "wireguard_poller.h"
+#include "loop_prober.h"
+#include "packet_capture.h"
+#include "interface_counters.h"
+#include "qdisc_stats_poller.h"
+#include "fib_tracer.h"
+#include "dataplane_probe.h"
+#include "anomaly_detector.h"
 
 // 前向声明
 class NetlinkMonitor;
 class Logger;
+struct ReportData;
+
+// 本地接口对应的拓扑链路
+struct InterfaceLink {
+    std::string link;  // 如 "spine1:e1-1 <-> leaf2:e1-1"
+    std::string peer;  // 对端，如 "leaf2:e1-1"
+};
+
+// 监控配置
+struct MonitorConfig {
+    int64_t convergence_threshold_ms = 3000;
+    std::string router_name;
+    std::string log_path;
+
+    // Webhook告警：收敛时间超过alert_threshold_ms或会话超时时触发
+    std::string alert_webhook_url;
+    int64_t alert_threshold_ms = 0;
+
+    // 会话完成钩子(--on-session-complete/--hook-timeout)：经/bin/sh -c执行，stdin为session_completed记录
+    std::string on_session_complete;
+    int64_t hook_timeout_ms = 60000;
+
+    // SLA：任一会话收敛时间超过sla_ms(或超时)即判定失败，0表示不启用
+    int64_t sla_ms = 0;
+
+    // 单个会话的最长时长(--max-session-duration)，触发后超过该时间仍未收敛即按超时结束，0表示不限
+    int64_t max_session_ms = 0;
+
+    // 附加到每个会话的静态标签(--tag KEY=VALUE)
+    std::unordered_map<std::string, std::string> session_tags;
+
+    // 附加到每条结构化记录的实验元数据(--label KEY=VALUE)，字段名为label_KEY，便于汇总多次运行后分组比较
+    std::unordered_map<std::string, std::string> labels;
+
+    // 在触发与收敛时通过vtysh采集FRR控制面状态(--frr-state)
+    bool frr_state = false;
+    std::string vtysh_path = "vtysh";
+
+    // 跟踪FRR日志并将SPF/最优路径事件关联到当前会话(--frr-log)
+    std::string frr_log_path;
+
+    // 内嵌BMP采集器监听地址(--bmp-listen)，如 "11019" 或 "0.0.0.0:11019"
+    std::string bmp_listen;
+
+    // 轮询OSPF/IS-IS邻接状态并记录变化(--igp-adjacency)，复用vtysh_path
+    bool igp_adjacency = false;
+    int64_t igp_poll_ms = 200;
+
+    // 通过gNMI订阅非Linux设备(SR Linux、cEOS等)的路由/接口变化(--gnmi)
+    GnmiOptions gnmi;
+
+    // SNMP Trap监听地址(--snmp-trap-listen)，如 "162" 或 "0.0.0.0:1162"
+    std::string snmp_trap_listen;
+    std::string snmp_community;  // 非空时只接受该community
+
+    // 时钟偏差探测的参考(--clock-reference HOST[:PORT])：NTP服务器或开启--clock-listen的监控器，
+    // 每clock_interval_ms测量一次并写出clock_offset记录，merge据此校正跨节点对齐
+    std::string clock_reference;
+    int64_t clock_interval_ms = 10000;
+    // 以本机时钟回答SNTP请求(--clock-listen [ADDR:]PORT)，作为其他监控器的时钟参考
+    std::string clock_listen;
+
+    // 事件源插件(--source)，如 "exec:/usr/local/bin/bfd-watch"，支持的SCHEME见EventSourceRegistry
+    std::vector<std::string> sources;
+
+    // 额外的记录输出(--output)，可同时指定多个，支持的URL见RecordSink::create
+    std::vector<std::string> outputs;
+    std::string output_key_field = "router_name";  // 作为消息key的记录字段，为空则不设置key
+
+    // 持久化存储(--store)，目前仅支持 sqlite:/path/db.sqlite，多次运行追加到同一数据库
+    std::string store;
+
+    // 以终端仪表盘代替逐行控制台输出(--tui)
+    bool tui = false;
+
+    // 控制套接字路径(--control-socket)，为空则不启用
+    std::string control_socket;
+
+    // 健康检查端点(--health-listen)，如 "8086" 或 "127.0.0.1:8086"，提供/healthz与/readyz，为空则不启用
+    std::string health_listen;
+    // 超过该时长没有收到路由消息时/healthz报告失败(--health-max-idle)，0表示不检查
+    int64_t health_max_idle_ms = 0;
+
+    // netlink事件过滤(--filter-interface/--filter-prefix)
+    EventFilter filter;
+
+    // 按接口或触发类型覆盖收敛阈值(--threshold-override)，未命中的会话使用convergence_threshold_ms
+    ThresholdOverrides threshold_overrides;
+
+    // 触发/忽略规则(--trigger-rule/--ignore-rule)，决定qdisc与路由类事件是否开始会话
+    TriggerRules trigger_rules;
+
+    // 事件明细(--event-detail)：summary时会话中不写逐条route_event记录，只按秒写route_event_summary
+    // (该秒事件数与最后一条的偏移)，也不在内存中保留详细事件；收敛时间计算不受影响
+    bool event_detail_summary = false;
+
+    // 控制台逐事件输出(--console-detail)：summary时明细行限速并按秒汇总会话内路由事件数
+    ConsoleDetail console_detail = ConsoleDetail::SUMMARY;
+
+    // 持续记录每秒路由变化数(--churn-rate)，不限于会话期间；会话内的逐秒速率总是记录在session_completed中
+    bool churn_rate = false;
+
+    // 内核路由表规模采样间隔(--route-table-sample)，0表示不采样
+    int64_t route_table_sample_ms = 0;
+
+    // 只监控路由(--route-only)：不订阅qdisc变化，netem不再触发会话；tc监控不被允许时也自动进入此模式
+    bool route_only = false;
+    // 启动前检查中warning级的失败("错误码: 详情")，开始监控后写入error记录
+    std::vector<std::string> preflight_warnings;
+
+    // 从已有日志恢复(--resume)：读取日志中的会话，接着之前的会话编号与累计统计继续
+    bool resume = false;
+
+    // 预热时长(--warmup)：监控开始后这段时间内的事件不触发会话，避免容器启动时的初始路由安装被当作收敛
+    int64_t warmup_ms = 0;
+
+    // 触发防抖窗口(--trigger-debounce-ms)：netem触发后该时间内同一接口上的qdisc事件视为同一次tc操作
+    // (如 tc qdisc replace 产生的删除+添加)，并入触发而不作为会话内的路由事件，0表示关闭
+    int64_t trigger_debounce_ms = 50;
+
+    // qdisc事件缓存(--qdisc-cache-size/--qdisc-cache-ttl)：按接口+handle记录最近的qdisc，
+    // 条目超过上限时挤出最早的，超过存活时间的不再参与netem删除推断
+    int64_t qdisc_cache_size = 256;
+    int64_t qdisc_cache_ttl_ms = 300000;
+
+    // 合并窗口(--coalesce-ms)：同一会话内类型、前缀、下一跳相同的路由事件在窗口内只写一条
+    // route_event记录并带coalesced_count，0表示不合并；收敛计算仍使用每一条事件
+    int64_t coalesce_ms = 0;
+
+    // 内存中最多保留的详细路由事件数(--max-events-in-memory)，0表示不限；
+    // 超出时先释放最早完成的会话的详细事件，会话摘要始终保留
+    int64_t max_events_in_memory = 0;
+
+    // netlink接收队列容量(--netlink-buffer)，积压超过该条数时丢弃新消息并计数
+    size_t netlink_queue_size = NetlinkMonitor::DEFAULT_QUEUE_CAPACITY;
+    // 并行解析netlink消息的工作线程数(--netlink-workers)，回调仍按接收顺序串行调用
+    size_t netlink_workers = NetlinkMonitor::DEFAULT_WORKER_COUNT;
+    // 订阅组播转发缓存变化(--mroute)，MFC表项的增删作为mroute_add/mroute_del路由事件
+    bool mroute = false;
+    // 订阅网桥/VXLAN转发表变化(--fdb)，MAC表项的增删与迁移作为fdb_add/fdb_del/fdb_move路由事件
+    bool fdb = false;
+    // 跟踪隧道接口(--tunnels)：GRE/IPIP/VXLAN/WireGuard等的创建删除、端点变化与链路状态作为tunnel_*路由事件，
+    // WireGuard对端端点每wireguard_poll_ms轮询一次
+    bool tunnels = false;
+    int64_t wireguard_poll_ms = 1000;
+    // 跟踪bond/team故障切换(--bonding)：活动成员切换、成员主备/MII状态与LACP状态变化作为lag_*/lacp_*路由事件
+    bool bonding = false;
+    // 记录接口MTU、混杂模式与主从关系的变化(--link-events)，写为link_event记录并标注所在会话，不参与触发与收敛判定
+    bool link_events = false;
+
+    // 前缀到下一跳缓存的容量(--nexthop-cache)，下一跳改指时写出nexthop_changed记录；0表示不跟踪
+    size_t nexthop_cache = 100000;
+    // 触发后该时间内完成的下一跳切换视为快速重路由(LFA/TI-LFA)生效(--frr-window-ms)，之后的为SPF重算结果；0表示不区分
+    int64_t frr_window_ms = 10;
+
+    // 单独跟踪main表默认路由的丢失与恢复(--watch-default)，每个会话报告默认路由恢复用时与下一跳变化
+    bool watch_default = false;
+
+    // 以指定前缀的安装作为收敛点(--converged-when-prefix/--via)：会话中该前缀被添加或替换(via非空时
+    // 还要求出接口或网关与之相同)的时刻即为收敛时间，会话立即结束；未出现时仍按静默期判定
+    EventFilter::Prefix converged_prefix;  // text为空表示不启用
+    std::string converged_via;
+
+    // 会话期间以traceroute方式探测该地址(--loop-probe)，同一跳出现在两个TTL上记为微环路；为空表示不启用
+    std::string loop_probe_target;
+    int64_t loop_probe_ms = 50;
+    int loop_probe_max_ttl = 16;
+
+    // 每个会话从触发到收敛用tcpdump抓取协议报文(--pcap-dir等)，文件路径写入session_completed
+    PcapOptions pcap;
+
+    // 在触发与收敛时读取接口收发/丢弃/错误计数(--interface-counters)，会话结束时记录差值
+    bool interface_counters = false;
+
+    // 会话期间每隔该时间读取触发接口上netem(没有时为根qdisc)的排队与丢包统计(--qdisc-stats-ms)，0表示不读取
+    int64_t qdisc_stats_ms = 0;
+
+    // 用eBPF kprobe记录IPv4路由写入内核FIB的时间，按内核时间计算收敛时间(--fib-trace)
+    bool fib_trace = false;
+
+    // 在恢复路径接口的tc egress挂载eBPF分类器(--dataplane-probe/--dataplane-ifaces)，
+    // 记录触发后第一个匹配探测流的转发报文时间作为数据面恢复时间；dst为空表示不启用
+    DataplaneFlow dataplane_flow;
+    std::vector<std::string> dataplane_interfaces;
+
+    // 收敛时间偏离最近anomaly_window个会话的基线超过该倍数标准差时记录anomaly_detected(--anomaly-sigma)，0表示不检测
+    double anomaly_sigma = 0;
+    int64_t anomaly_window = 30;
+
+    // 运行时配置文件(--config)，启动时及收到SIGHUP/reload命令时加载阈值与过滤条件
+    std::string config_path;
+
+    // 调试通道文件(--debug-log)，仅在--log-level debug时使用，为空则为"<JSON日志路径>.debug"
+    std::string debug_log_path;
+
+    // 本地接口名 -> 拓扑链路(--topology)，用于为事件标注逻辑链路
+    std::unordered_map<std::string, InterfaceLink> interface_links;
+
+    // 运行清单(run_started)：原始命令行、生效的选项(名称, 值)按出现顺序，以及用到的CONVERGE_*环境变量
+    std::string command_line;
+    std::vector<std::pair<std::string, std::string>> run_options;
+    std::vector<std::string> env_variables;
+};
+
+// 已完成会话的只读快照，用于报告输出
+struct SessionSummary {
+    int session_id = 0;
+    int64_t trigger_time_ms = 0;
+    std::optional<int64_t> convergence_time_ms;
+    int route_events = 0;
+    int64_t duration_ms = 0;
+    bool timed_out = false;
+    bool forced = false;
+    std::unordered_map<std::string, std::string> trigger_info;
+    std::unordered_map<std::string, std::string> tags;
+};
+
+// SLA评估结果
+struct SlaSummary {
+    int64_t sla_ms = 0;
+    int sessions = 0;
+    int violations = 0;
+    int64_t max_convergence_ms = 0;
+
+    bool passed() const { return violations == 0; }
+};
 
 // 路由事件结构
 struct RouteEvent {
@@ -72,11 +330,22 @@ struct RouteEvent {
     std::string type;
     std::unordered_map<std::string, std::string> info;
     int64_t offset_from_netem;
+    std::string route_class;  // blackhole/unreachable/prohibit，普通路由为空
     
     RouteEvent(int64_t ts, const std::string& t, 
                const std::unordered_map<std::string, std::string>& i, 
                int64_t offset)
-        : timestamp(ts), type(t), info(i), offset_from_netem(offset) {}
+        : timestamp(ts), type(t), info(i), offset_from_netem(offset), route_class(classify(i)) {}
+
+    // 丢弃类路由(RTBH等)按路由类型区分，其余返回空
+    static std::string classify(const std::unordered_map<std::string, std::string>& info) {
+        auto it = info.find("type");
+        if (it != info.end() &&
+            (it->second == "blackhole" || it->second == "unreachable" || it->second == "prohibit")) {
+            return it->second;
+        }
+        return "";
+    }
 };
 
 // QDisc事件结构
@@ -95,28 +364,104 @@ class ConvergenceSession {
 private:
     mutable std::mutex mutex_;
     std::atomic<int> convergence_check_count_{0};
+    int route_event_count_ = 0;
 
 public:
     int session_id;
     int64_t netem_event_time;
     std::unordered_map<std::string, std::string> netem_info;
+    // 详细路由事件；超出--max-events-in-memory时不再保留或被释放，完整记录仍在JSON日志中
     std::vector<RouteEvent> route_events;
+    std::unordered_set<std::string> route_interfaces;  // 路由事件涉及的接口，释放详细事件后仍保留
+    std::vector<int64_t> churn_per_second;  // 相对触发时间每秒的路由事件数，下标为秒序号
+    std::unordered_map<std::string, int64_t> event_type_counts;  // 按事件类型(route_add/route_replace等)的计数
+    std::unordered_map<std::string, int64_t> route_class_counts;  // 丢弃类路由事件按blackhole/unreachable/prohibit的计数
+    std::unordered_map<std::string, int64_t> route_origin_counts;  // 路由事件按来源(connected/protocol/static等)的计数
+    // 按地址族(ipv4/ipv6)的路由事件数与最后一条事件相对触发的时间，双栈会话据此分别给出收敛时间
+    std::map<std::string, int64_t> family_event_counts;
+    std::map<std::string, int64_t> family_last_offsets;
     std::optional<int64_t> last_route_event_time;
     std::optional<int64_t> convergence_time;
     std::atomic<bool> is_converged{false};
     std::optional<int64_t> convergence_detected_time;
+    bool timed_out = false;  // 超过--max-session-duration仍未收敛
+    bool forced = false;     // 监听结束或force-finish时仍未收敛，被强制结束，不告警也不计入SLA
+    bool resumed = false;    // --resume从日志恢复的历史会话，只用于编号与累计统计
+    std::unordered_map<std::string, std::string> tags;  // 会话开始时的附加标签
+    std::string trigger_source;       // netem/route/snmp
+    // --threshold-override：会话开始时命中的阈值与规则，为空时使用全局阈值
+    std::optional<int64_t> threshold_override_ms;
+    std::string threshold_rule;
+    int debounced_trigger_events = 0;  // 并入触发的qdisc事件数(--trigger-debounce-ms)，受session_mutex_保护
+    // --watch-default：默认路由最早丢失与最后恢复的时间(相对触发)及前后的下一跳，受mutex_保护
+    std::optional<int64_t> default_lost_offset;
+    std::optional<int64_t> default_restored_offset;
+    std::string default_nexthop_before;
+    std::string default_nexthop_after;
+    int default_route_events = 0;
+    SessionNexthopChanges nexthops;  // --nexthop-cache：会话期间的下一跳切换，受session_mutex_保护
+    // --converged-when-prefix：命中目标前缀的那条路由的下一跳，为空表示按静默期收敛，受mutex_保护
+    std::string converged_prefix_nexthop;
+    MicroLoopState micro_loop;  // --loop-probe：受session_mutex_保护
+    std::unique_ptr<SessionCapture> capture;  // --pcap-dir：本会话的抓包，结束会话时停止
+    InterfaceCounterSnapshot counters_at_trigger;  // --interface-counters：触发时的接口计数
+    int64_t counters_trigger_time = 0;
+    std::vector<QdiscStats> qdisc_samples;  // --qdisc-stats-ms：会话期间的qdisc统计，受session_mutex_保护
+    // --fib-trace：触发前1秒起的内核FIB变化，受session_mutex_保护；结束时算出内核时间的收敛时间(微秒)
+    std::vector<FibTraceEvent> fib_events;
+    std::optional<int64_t> kernel_convergence_us;
+    std::optional<DataplanePacket> dataplane_packet;  // --dataplane-probe：触发后第一个转发的探测报文，受session_mutex_保护
 
     ConvergenceSession(int id, int64_t netem_time, 
                       const std::unordered_map<std::string, std::string>& netem_info);
 
     void add_route_event(int64_t timestamp, const std::string& event_type, 
-                        const std::unordered_map<std::string, std::string>& route_info);
+                        const std::unordered_map<std::string, std::string>& route_info,
+                        bool keep_detail = true);
+    // 记录一次默认路由变化：删除为丢失，添加或替换为恢复；previous_nexthop为变化前的下一跳
+    void note_default_route(int64_t timestamp, const std::string& event_type,
+                            const std::string& nexthop, const std::string& previous_nexthop);
+    // 默认路由是否处于丢失后尚未恢复的状态
+    bool default_route_down() const;
+    // IPv4与IPv6路由事件都出现过
+    bool dual_stack() const;
+    // 释放详细路由事件，返回释放的条数；计数与接口等摘要不受影响
+    size_t release_route_events();
+    size_t retained_event_count() const;
     
     bool check_convergence(int64_t quiet_period_ms);
+    // 以timestamp为收敛时刻立即结束收敛判定(目标前缀已安装)，已收敛时返回false
+    bool mark_converged(int64_t timestamp, const std::string& nexthop);
+    // --max-session-duration：自触发起超过max_session_ms仍未收敛，max_session_ms为0表示不限
+    bool exceeds_max_duration(int64_t now_ms, int64_t max_session_ms) const;
+    // 按超时结束：收敛时间取最后一条路由事件，计入SLA并告警
+    void time_out();
+    // 监听结束或force-finish时强制结束：还没有静默够阈值，不给出收敛时间
+    void force_finish();
     
     int get_route_event_count() const;
     
     int64_t get_session_duration() const;
+
+    // --resume：按日志中session_completed的摘要设置路由事件数与会话时长，历史会话没有事件明细
+    void restore_summary(int route_events, int64_t duration_ms);
+
+    // session_completed中由路由事件得出的字段：逐秒变化数、按类型/类别/来源的事件数
+    void add_event_counts_to_json(JsonObject& obj) const;
+    // --watch-default：默认路由的丢失、恢复时间与前后下一跳，会话期间默认路由没有变化时不写
+    void add_default_route_to_json(JsonObject& obj) const;
+    // 双栈会话的两个地址族常以不同速度收敛，各自以该族最后一条路由事件为收敛点，非双栈会话不写
+    void add_dual_stack_to_json(JsonObject& obj) const;
+};
+
+// --resume从日志恢复的历史会话与累计统计
+struct ResumedRun {
+    std::vector<std::unique_ptr<ConvergenceSession>> sessions;
+    std::vector<int64_t> baseline_convergence_ms;  // 真正收敛的会话的收敛时间，作为异常检测的基线
+    int last_session_id = 0;
+    int64_t netem_triggers = 0;
+    int64_t route_triggers = 0;
+    int64_t route_events = 0;
 };
 
 // 监控状态枚举
@@ -129,11 +474,12 @@ enum class MonitorState {
 class ConvergenceMonitor {
 private:
     // 基本配置
+    MonitorConfig config_;
     std::unique_ptr<Logger> logger_;
     std::string log_file_path_;
     std::string router_name_;
     std::string monitor_id_;
-    int64_t convergence_threshold_ms_;
+    std::atomic<int64_t> convergence_threshold_ms_;  // 可通过控制套接字修改
     
     // 状态管理
     std::atomic<MonitorState> state_{MonitorState::IDLE};
@@ -141,22 +487,118 @@ private:
     std::unique_ptr<ConvergenceSession> current_session_;
     std::vector<std::unique_ptr<ConvergenceSession>> completed_sessions_;
     std::atomic<int> session_counter_{0};
+    std::unordered_map<std::string, std::string> session_tags_;  // 受session_mutex_保护
     
     // 统计计数器 (原子操作)
     std::atomic<int64_t> total_route_events_{0};
     std::atomic<int64_t> total_netem_triggers_{0};
     std::atomic<int64_t> total_route_triggers_{0};
+
+    // 详细路由事件的内存占用(受session_mutex_保护)
+    int64_t events_in_memory_ = 0;
+    int64_t events_spilled_ = 0;   // 未保留或已释放的详细事件数
+    size_t spill_cursor_ = 0;      // completed_sessions_中第一个可能仍保留详细事件的会话
+
+    // 等待合并窗口结束的route_event记录，键为会话、类型、前缀与下一跳
+    struct CoalescedRouteEvent {
+        JsonObject log;
+        int64_t first_timestamp;
+        int64_t last_offset;
+        int64_t count;
+    };
+    std::unordered_map<std::string, CoalescedRouteEvent> coalesce_pending_;
+    std::mutex coalesce_mutex_;
+    int64_t coalesced_events_ = 0;  // 被合并掉(未单独记录)的事件数，受coalesce_mutex_保护
+
+    // --event-detail summary下当前这一秒的事件计数，受summary_mutex_保护
+    struct EventRateBucket {
+        int session_id = 0;
+        int64_t second = -1;        // 相对触发时间的秒序号，-1表示没有未写出的计数
+        int64_t end_ms = 0;         // 这一秒结束时的时间戳
+        int64_t count = 0;
+        int64_t last_offset = 0;
+        int64_t session_total = 0;  // 截至最后一条事件的会话累计事件数
+    };
+    EventRateBucket rate_bucket_;
+    std::mutex summary_mutex_;
+
+    // --churn-rate：当前这一秒(墙上时间)通过过滤的路由事件数，受churn_mutex_保护
+    int64_t churn_second_ = -1;
+    int64_t churn_count_ = 0;
+    std::mutex churn_mutex_;
+    // --fdb：每个MAC表项(MAC+VLAN/VNI+所属网桥或VXLAN设备)当前所在的端口与远端VTEP，用于识别迁移
+    std::unordered_map<std::string, std::string> fdb_locations_;
+    std::mutex fdb_mutex_;
+    // --watch-default：每个地址族(AF_INET/AF_INET6)main表默认路由当前的下一跳，受default_mutex_保护
+    std::unordered_map<std::string, std::string> default_nexthops_;
+    std::mutex default_mutex_;
     int64_t monitoring_start_time_;
     
-    // 事件缓存
+    // 最近的qdisc状态，按"接口|handle"缓存每个qdisc最后一次事件，用于推断不带kind的QDISC_DEL是否删除了netem
     mutable std::mutex qdisc_events_mutex_;
-    std::queue<QdiscEvent> recent_qdisc_events_;
-    static constexpr size_t MAX_QDISC_EVENTS = 20;
+    std::unordered_map<std::string, QdiscEvent> recent_qdisc_events_;
+    std::atomic<int64_t> qdisc_cache_evicted_{0};  // 因超出--qdisc-cache-size被挤出的条目数
     
     // 线程管理
     std::atomic<bool> running_{false};
     std::vector<std::thread> worker_threads_;
     std::unique_ptr<NetlinkMonitor> netlink_monitor_;
+    std::unique_ptr<AlertNotifier> alert_notifier_;
+    std::unique_ptr<SessionHook> session_hook_;
+    std::unique_ptr<ConsoleThrottle> console_throttle_;
+    std::unique_ptr<FrrStatePoller> frr_poller_;
+    std::unique_ptr<RouteTableSampler> route_sampler_;
+    // 首次与最近一次采样的路由总数，-1表示尚未采样
+    std::atomic<int64_t> first_route_total_{-1};
+    std::atomic<int64_t> last_route_total_{-1};
+
+    // 已记录的error事件数
+    std::atomic<int64_t> error_events_{0};
+
+    // 启动完成时的线程数
+    int64_t monitor_threads_ = 0;
+
+    std::atomic<int64_t> finished_sessions_{0};
+
+    // 预热结束时间(0表示未启用)、预热期间被抑制的触发事件数，以及是否已记录预热结束
+    std::atomic<int64_t> warmup_end_ms_{0};
+    std::atomic<int64_t> warmup_suppressed_{0};
+    std::atomic<bool> warmup_done_{false};
+    std::string stop_reason_;
+    std::atomic<bool> statistics_logged_{false};  // monitoring_completed已写出，强制退出时不再重复
+    std::unique_ptr<FrrLogTailer> frr_log_tailer_;
+    std::unique_ptr<BmpCollector> bmp_collector_;
+    std::unique_ptr<IgpAdjacencyTracker> igp_tracker_;
+    std::unique_ptr<GnmiSubscriber> gnmi_subscriber_;
+    std::unique_ptr<SnmpTrapReceiver> snmp_receiver_;
+    std::vector<std::pair<std::string, std::unique_ptr<EventSource>>> sources_;  // (SPEC, 事件源)
+    std::vector<std::pair<std::string, std::unique_ptr<RecordSink>>> sinks_;  // (URL, sink)
+    std::unique_ptr<TuiDashboard> tui_;
+    std::unique_ptr<DebugChannel> debug_channel_;  // 仅--log-level debug时创建
+    std::unique_ptr<ControlServer> control_server_;
+    std::unique_ptr<HealthServer> health_server_;
+    std::unique_ptr<ClockProbe> clock_probe_;
+    std::unique_ptr<ClockServer> clock_server_;
+    std::atomic<int64_t> clock_samples_{0};
+    std::atomic<double> last_clock_offset_ms_{0.0};
+    std::atomic<double> printed_clock_offset_ms_{0.0};  // 控制台上次输出的偏差，变化明显时才再输出
+    std::atomic<bool> clock_failing_{false};            // 连续失败只记录第一次
+    std::unique_ptr<LinkTracker> link_tracker_;        // 仅--tunnels/--bonding/--link-events时创建
+    std::unique_ptr<NexthopTracker> nexthop_tracker_;  // --nexthop-cache为0时不创建
+    std::atomic<int64_t> nexthop_changes_{0};
+    std::unique_ptr<WireguardPoller> wireguard_poller_;
+    std::unique_ptr<LoopProber> loop_prober_;
+    std::unique_ptr<QdiscStatsPoller> qdisc_stats_poller_;
+    std::unique_ptr<FibTracer> fib_tracer_;
+    std::deque<FibTraceEvent> recent_fib_events_;  // 最近1秒的内核FIB变化，受session_mutex_保护
+    std::unique_ptr<DataplaneProbe> dataplane_probe_;
+    std::unique_ptr<AnomalyDetector> anomaly_detector_;  // 受session_mutex_保护
+    int64_t anomalies_detected_ = 0;
+    int64_t resumed_sessions_ = 0;  // --resume恢复的历史会话数
+
+    // 事件过滤，可在运行中修改
+    mutable std::mutex filter_mutex_;
+    EventFilter filter_;
     
     // 收敛检查线程
     std::thread convergence_checker_thread_;
@@ -165,27 +607,107 @@ private:
 
     // 内部方法
     void cleanup_old_events();
+    static std::string qdisc_cache_key(const std::unordered_map<std::string, std::string>& qdisc_info);
+    // 更新qdisc缓存：删除事件移除对应条目，其余事件覆盖该qdisc的最新状态
+    void remember_qdisc_event(int64_t timestamp, const std::string& event_type,
+                              const std::unordered_map<std::string, std::string>& qdisc_info);
     std::string format_timestamp(int64_t timestamp_ms) const;
     std::string get_interface_name(int ifindex) const;
-    std::unordered_map<std::string, std::string> parse_route_info(const void* route_data) const;
-    std::unordered_map<std::string, std::string> parse_qdisc_info(const void* qdisc_data) const;
-    bool is_netem_related_event(const std::unordered_map<std::string, std::string>& qdisc_info, 
-                               const std::string& event_type) const;
+    void annotate_interface(std::unordered_map<std::string, std::string>& info) const;
+    // 写出一次下一跳切换，标注所在会话并计入会话
+    void handle_nexthop_change(int64_t timestamp, const NexthopChange& change);
+    // 路由事件补充table_name(rt_tables或VRF设备名)
+    void annotate_table(std::unordered_map<std::string, std::string>& info) const;
+    // 更新FDB位置表；已有表项换了端口或远端VTEP时返回fdb_move并附带previous_*字段，否则原样返回事件类型
+    std::string track_fdb_location(const std::string& event_type,
+                                   std::unordered_map<std::string, std::string>& info);
+    // 默认路由事件：更新当前下一跳并记入当前会话(若有)
+    void track_default_route(int64_t timestamp, const std::string& event_type,
+                             const std::unordered_map<std::string, std::string>& info);
+    static bool is_default_route(const std::unordered_map<std::string, std::string>& info);
+    // 路由事件是否为--converged-when-prefix指定前缀(及--via)的安装
+    bool matches_converged_prefix(const std::string& event_type,
+                                  const std::unordered_map<std::string, std::string>& info) const;
+    void converge_on_prefix(int64_t timestamp, const std::unordered_map<std::string, std::string>& info);
+    // 删除事件本身不带kind，按同一接口同一handle最近的qdisc缓存判断删除的是否为netem
+    bool qdisc_was_netem(const std::unordered_map<std::string, std::string>& qdisc_info,
+                         const std::string& event_type) const;
     
     void handle_trigger_event(int64_t timestamp, const std::string& event_type, 
                              const std::unordered_map<std::string, std::string>& trigger_info, 
                              const std::string& trigger_source);
     
-    void handle_qdisc_event(const std::unordered_map<std::string, std::string>& qdisc_info, 
+    void handle_qdisc_event(int64_t timestamp, const std::unordered_map<std::string, std::string>& qdisc_info, 
                            const std::string& event_type);
     
     void handle_route_event(int64_t timestamp, const std::string& event_type, 
                            const std::unordered_map<std::string, std::string>& route_info);
     
+    // 向调试通道记录一次事件取舍(如未触发会话的原因)，未启用调试通道时不做任何事
+    void debug_note(const std::string& decision, const std::string& event_type,
+                    const std::unordered_map<std::string, std::string>& info);
+
     void convergence_checker_loop();
     void finish_current_session();
+    // 结束会话时的控制台输出
+    void print_session_summary(const ConvergenceSession& session, const QdiscStatsSummary& qdisc,
+                               const InterfaceCounterDelta& counters, const std::string& trigger_interface,
+                               const std::vector<PcapFile>& pcap_files, const AnomalyResult& anomaly) const;
+    void coalesce_route_event(const std::string& key, int64_t timestamp, int64_t offset, JsonObject log);
+    // 写出窗口已结束(all为true时为全部)的合并记录
+    void flush_coalesced(bool all);
+    void record_event_summary(const ConvergenceSession& session, int64_t offset, int64_t session_total);
+    // 写出已结束(all为true时无论是否结束)的每秒汇总
+    void flush_event_summary(bool all);
+    void log_event_summary(const EventRateBucket& bucket);
+    void count_churn(int64_t timestamp);
+    // 预热结束后记录一次warmup_completed
+    void check_warmup_end();
+    // 写出已结束(all为true时无论是否结束)的这一秒的路由变化数
+    void flush_churn(bool all);
+    // 输出--console-detail summary的按秒摘要
+    void flush_console(bool all);
+    // 调用方持有session_mutex_；达到--max-events-in-memory时释放最早完成会话的详细事件，
+    // 返回是否还能保留一条新的详细事件
+    bool reserve_event_slot_locked();
     void force_finish_session(const std::string& reason);
+    void maybe_send_alert(const ConvergenceSession& session, const JsonObject& session_log);
+    void run_session_hook(const ConvergenceSession& session, const JsonObject& session_log);
+    void handle_hook_result(const SessionHookResult& result);
     void print_statistics();
+    // --resume：在日志记录器打开文件之前读取已有日志
+    void resume_from_log();
+
+    // 控制套接字命令: status、force-finish、reset-stats、set-threshold MS、help，返回单行JSON
+    std::string handle_control_command(const std::vector<std::string>& args);
+    // 当前状态快照：状态、活动会话与累计计数
+    JsonObject build_status();
+    // /healthz：netlink读取线程仍在循环且订阅有效；/readyz：另外要求监控已启动、预热结束且接收队列未满。
+    // 返回HTTP状态码，body为单行JSON
+    int handle_health_request(const std::string& path, std::string& body);
+
+    // 运行中修改阈值/过滤条件并记录threshold_changed/filter_changed，source为修改来源
+    int64_t apply_threshold(int64_t threshold_ms, const std::string& source);  // 返回原阈值
+    void apply_filter(const EventFilter& filter, const std::string& source);
+    void log_frr_state(int session_id, const std::string& phase, const FrrSnapshot& snapshot);
+    void log_route_table_sample(const RouteTableSample& sample);
+    void handle_frr_log_event(const FrrLogEvent& event);
+    void handle_bmp_message(const BmpMessage& message);
+    void handle_igp_adjacency_event(const IgpAdjacencyEvent& event);
+    void handle_gnmi_update(const GnmiUpdate& update);
+    void handle_snmp_trap(const SnmpTrap& trap);
+    void handle_clock_sample(const ClockSample& sample);
+    void handle_source_event(const std::string& source, const SourceEvent& event);
+    void handle_wireguard_peer_event(const WireguardPeerEvent& event);
+    void handle_loop_probe(const LoopProbeResult& result);
+    void handle_qdisc_stats(const QdiscStats& stats);
+    void handle_fib_trace(const FibTraceEvent& event);
+    void handle_dataplane_packet(const DataplanePacket& packet);
+    // 记录一条link_event(MTU、混杂模式、主从关系变化)，会话进行中时附带会话与偏移
+    void handle_link_attribute_event(int64_t timestamp, const LinkChange& change);
+    // 经过滤与计数后按路由事件处理(触发会话或记入当前会话)，用于由接口状态推导出的事件
+    void handle_derived_event(int64_t timestamp, const std::string& event_type,
+                              std::unordered_map<std::string, std::string> info);
     
     // 获取当前时间戳（毫秒）
     static int64_t get_current_timestamp_ms() {
@@ -194,9 +716,7 @@ private:
     }
 
 public:
-    ConvergenceMonitor(int64_t convergence_threshold_ms, 
-                      const std::string& router_name, 
-                      const std::string& log_path = "");
+    explicit ConvergenceMonitor(const MonitorConfig& config);
     
     ~ConvergenceMonitor();
     
@@ -208,8 +728,56 @@ public:
     
     void start_monitoring();
     void stop_monitoring();
+
+    // 第二次Ctrl+C：不停止各线程、不等待输出，直接写出已排队的记录与最终统计并关闭日志，
+    // 随后由调用者立即退出进程
+    void emergency_stop();
+
+    // 根据本次运行完成的会话评估SLA，--resume恢复的历史会话不参与
+    SlaSummary evaluate_sla();
+    // evaluate_sla的判定：超时或收敛时间超过sla_ms即违反，强制结束与历史会话不参与
+    static SlaSummary summarize_sla(const std::vector<std::unique_ptr<ConvergenceSession>>& sessions,
+                                    int64_t sla_ms);
+
+    // resume_from_log的恢复逻辑：多路由器的汇总日志只取router_name的会话，只有一个路由器时不要求名称一致
+    static ResumedRun restore_sessions(const ReportData& data, const std::string& router_name);
+
+    // 获取本次运行完成的会话快照(不含--resume恢复的历史会话)，用于JUnit与基线对比
+    std::vector<SessionSummary> get_completed_sessions();
+
+    // 启动以来完成的会话数(reset-stats不清零)，用于--max-sessions
+    int64_t finished_session_count() const { return finished_sessions_.load(); }
+
+    // 结束原因(signal/duration/max_sessions)，记录在monitoring_completed中，须在stop_monitoring之前设置
+    void set_stop_reason(const std::string& reason) { stop_reason_ = reason; }
+
+    const std::string& get_router_name() const { return router_name_; }
+
+    // 打印并记录运行中的状态报告(status_report)，由主循环在收到SIGUSR1后调用
+    void dump_status();
+
+    // 重新加载--config文件中的阈值与过滤条件(SIGHUP或reload命令)，失败时保持当前设置
+    bool reload_config(std::string& error);
+
+    // 读取运行时配置文件，覆盖config中的convergence_threshold_ms与filter：
+    //   threshold_ms: 5000
+    //   filter_interfaces: [eth1, eth2]
+    //   filter_prefixes: [10.0.0.0/8, 2001:db8::/32]
+    static bool load_config_file(const std::string& path, MonitorConfig& config, std::string& error);
+
+    // 设置附加到后续新会话的标签（如故障计划步骤ID），空表恢复为静态标签
+    void set_session_tags(const std::unordered_map<std::string, std::string>& tags);
+
+    // 记录外部组件（如故障注入）产生的事件
+    void log_external_event(const std::string& event_type,
+                            const std::unordered_map<std::string, std::string>& fields);
     
     // 事件处理回调 (由NetlinkMonitor调用)
-    void on_route_event(const void* route_data, const std::string& event_type);
-    void on_qdisc_event(const void* qdisc_data, const std::string& event_type);
+    void on_route_event(const NetlinkEvent& event);
+    void on_qdisc_event(const NetlinkEvent& event);
+    void on_link_event(const NetlinkEvent& event);
+    void on_subscription_restarted(const std::string& reason, const std::string& detail);
+
+    // 记录结构化error事件，severity为warning/error/critical，component为出错的组件(如netlink)
+    void log_error(const std::string& severity, const std::string& component, const std::string& message);
 };
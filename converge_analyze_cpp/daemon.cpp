@@ -0,0 +1,267 @@
+#include "daemon.h"
+#include <cerrno>
+#include <cstddef>
+#include <cstdlib>
+#include <cstring>
+#include <fcntl.h>
+#include <iostream>
+#include <mutex>
+#include <streambuf>
+#include <sys/file.h>
+#include <sys/socket.h>
+#include <sys/un.h>
+#include <sys/wait.h>
+#include <unistd.h>
+
+namespace {
+
+// 后台子进程向原进程报告启动结果的管道写端
+int ready_pipe_fd = -1;
+
+void report_to_parent(char result) {
+    if (ready_pipe_fd < 0) {
+        return;
+    }
+    ssize_t written;
+    do {
+        written = write(ready_pipe_fd, &result, 1);
+    } while (written < 0 && errno == EINTR);
+    close(ready_pipe_fd);
+    ready_pipe_fd = -1;
+}
+
+// 过滤emoji的streambuf：按UTF-8字符解码，丢弃emoji、变体选择符与零宽连接符，
+// 以及行首被丢弃的emoji之后的空格。多个线程共用std::cout，内部加锁
+class PlainStreambuf : public std::streambuf {
+private:
+    std::streambuf* target_;
+    std::mutex mutex_;
+    std::string pending_;        // 尚未收齐的UTF-8多字节字符
+    size_t expected_ = 0;
+    bool skip_spaces_ = false;   // 刚丢弃了emoji，跳过其后的空格
+
+    static bool is_emoji(uint32_t cp) {
+        return (cp >= 0x1F000 && cp <= 0x1FAFF) || (cp >= 0x2600 && cp <= 0x27BF) ||
+               (cp >= 0x2300 && cp <= 0x23FF) || (cp >= 0x2B00 && cp <= 0x2BFF) ||
+               cp == 0xFE0F || cp == 0x200D;
+    }
+
+    static uint32_t decode(const std::string& bytes) {
+        unsigned char lead = static_cast<unsigned char>(bytes[0]);
+        uint32_t cp = bytes.size() == 2 ? (lead & 0x1F) : bytes.size() == 3 ? (lead & 0x0F) : (lead & 0x07);
+        for (size_t i = 1; i < bytes.size(); ++i) {
+            cp = (cp << 6) | (static_cast<unsigned char>(bytes[i]) & 0x3F);
+        }
+        return cp;
+    }
+
+    void put(char c) {
+        unsigned char byte = static_cast<unsigned char>(c);
+        if (!pending_.empty()) {
+            pending_ += c;
+            if (pending_.size() < expected_) {
+                return;
+            }
+            std::string bytes;
+            bytes.swap(pending_);
+            if (is_emoji(decode(bytes))) {
+                skip_spaces_ = true;
+                return;
+            }
+            skip_spaces_ = false;
+            target_->sputn(bytes.data(), static_cast<std::streamsize>(bytes.size()));
+            return;
+        }
+
+        if (byte >= 0xC0) {
+            expected_ = byte >= 0xF0 ? 4 : byte >= 0xE0 ? 3 : 2;
+            pending_ = c;
+            return;
+        }
+        if (c == ' ' && skip_spaces_) {
+            return;
+        }
+        skip_spaces_ = false;
+        target_->sputc(c);
+    }
+
+protected:
+    int_type overflow(int_type ch) override {
+        if (traits_type::eq_int_type(ch, traits_type::eof())) {
+            return traits_type::not_eof(ch);
+        }
+        std::lock_guard<std::mutex> lock(mutex_);
+        put(traits_type::to_char_type(ch));
+        return ch;
+    }
+
+    std::streamsize xsputn(const char* s, std::streamsize n) override {
+        std::lock_guard<std::mutex> lock(mutex_);
+        for (std::streamsize i = 0; i < n; ++i) {
+            put(s[i]);
+        }
+        return n;
+    }
+
+    int sync() override {
+        std::lock_guard<std::mutex> lock(mutex_);
+        return target_->pubsync();
+    }
+
+public:
+    explicit PlainStreambuf(std::streambuf* target) : target_(target) {}
+};
+
+} // namespace
+
+bool under_systemd() {
+    const char* socket_path = getenv("NOTIFY_SOCKET");
+    return socket_path && socket_path[0] != '\0';
+}
+
+bool daemonize(std::string& error) {
+    int fds[2];
+    if (pipe2(fds, O_CLOEXEC) < 0) {
+        error = std::string("pipe: ") + strerror(errno);
+        return false;
+    }
+
+    std::cout.flush();
+    std::cerr.flush();
+
+    pid_t pid = fork();
+    if (pid < 0) {
+        error = std::string("fork: ") + strerror(errno);
+        close(fds[0]);
+        close(fds[1]);
+        return false;
+    }
+    if (pid > 0) {
+        // 原进程：等待后台进程报告启动结果
+        close(fds[1]);
+        char result = 0;
+        ssize_t n;
+        do {
+            n = read(fds[0], &result, 1);
+        } while (n < 0 && errno == EINTR);
+        waitpid(pid, nullptr, 0);
+        _exit(n == 1 && result == '1' ? 0 : 1);
+    }
+
+    // 第一个子进程：新建会话脱离控制终端，再fork一次确保不会重新获得终端
+    close(fds[0]);
+    if (setsid() < 0) {
+        _exit(1);
+    }
+    pid = fork();
+    if (pid < 0) {
+        _exit(1);
+    }
+    if (pid > 0) {
+        _exit(0);
+    }
+
+    ready_pipe_fd = fds[1];
+
+    int null_fd = open("/dev/null", O_RDWR | O_CLOEXEC);
+    if (null_fd >= 0) {
+        dup2(null_fd, STDIN_FILENO);
+        dup2(null_fd, STDOUT_FILENO);
+        dup2(null_fd, STDERR_FILENO);
+        close(null_fd);
+    }
+    return true;
+}
+
+void daemon_ready() {
+    report_to_parent('1');
+    sd_notify_state("READY=1\nMAINPID=" + std::to_string(getpid()));
+}
+
+void daemon_failed() {
+    report_to_parent('0');
+}
+
+void sd_notify_state(const std::string& state) {
+    const char* socket_path = getenv("NOTIFY_SOCKET");
+    if (!socket_path || socket_path[0] == '\0') {
+        return;
+    }
+
+    struct sockaddr_un addr{};
+    addr.sun_family = AF_UNIX;
+    size_t path_len = strlen(socket_path);
+    if (path_len >= sizeof(addr.sun_path)) {
+        return;
+    }
+    memcpy(addr.sun_path, socket_path, path_len);
+    // '@'开头为抽象命名空间
+    if (addr.sun_path[0] == '@') {
+        addr.sun_path[0] = '\0';
+    }
+
+    int fd = socket(AF_UNIX, SOCK_DGRAM | SOCK_CLOEXEC, 0);
+    if (fd < 0) {
+        return;
+    }
+    sendto(fd, state.data(), state.size(), MSG_NOSIGNAL, reinterpret_cast<struct sockaddr*>(&addr),
+           static_cast<socklen_t>(offsetof(struct sockaddr_un, sun_path) + path_len));
+    close(fd);
+}
+
+void install_plain_console() {
+    // 与进程同生命周期，不释放
+    static PlainStreambuf* out_buf = new PlainStreambuf(std::cout.rdbuf());
+    static PlainStreambuf* err_buf = new PlainStreambuf(std::cerr.rdbuf());
+    std::cout.rdbuf(out_buf);
+    std::cerr.rdbuf(err_buf);
+}
+
+PidFile::~PidFile() {
+    release();
+}
+
+bool PidFile::acquire(const std::string& path, std::string& error) {
+    int fd = open(path.c_str(), O_RDWR | O_CREAT | O_CLOEXEC, 0644);
+    if (fd < 0) {
+        error = "无法创建pid文件 " + path + ": " + strerror(errno);
+        return false;
+    }
+    if (flock(fd, LOCK_EX | LOCK_NB) < 0) {
+        char buffer[32] = {0};
+        ssize_t n = read(fd, buffer, sizeof(buffer) - 1);
+        std::string pid = n > 0 ? std::string(buffer, static_cast<size_t>(n)) : "";
+        while (!pid.empty() && (pid.back() == '\n' || pid.back() == ' ')) {
+            pid.pop_back();
+        }
+        error = "pid文件 " + path + " 已被运行中的实例持有" + (pid.empty() ? "" : " (pid " + pid + ")");
+        close(fd);
+        return false;
+    }
+
+    path_ = path;
+    fd_ = fd;
+    if (!write_pid(error)) {
+        release();
+        return false;
+    }
+    return true;
+}
+
+bool PidFile::write_pid(std::string& error) {
+    std::string content = std::to_string(getpid()) + "\n";
+    if (ftruncate(fd_, 0) < 0 || pwrite(fd_, content.data(), content.size(), 0) < 0) {
+        error = "无法写入pid文件 " + path_ + ": " + strerror(errno);
+        return false;
+    }
+    return true;
+}
+
+void PidFile::release() {
+    if (fd_ < 0) {
+        return;
+    }
+    unlink(path_.c_str());
+    close(fd_);
+    fd_ = -1;
+}
@@ -0,0 +1,48 @@
+#pragma once
+
+#include <string>
+
+// 常驻运行支持(--daemon / --pidfile)：
+// - 由systemd启动(存在NOTIFY_SOCKET)时保持前台运行，通过sd_notify报告READY/RELOADING/STOPPING，
+//   适合Type=notify的unit；
+// - 否则两次fork脱离终端，原进程等待监控启动成功后以0退出，启动失败时以1退出；
+// - 控制台输出去掉emoji，便于journald与日志系统处理。
+
+// 当前是否由systemd以notify方式启动
+bool under_systemd();
+
+// 脱离终端转入后台，必须在创建任何线程之前调用。返回时已处于后台子进程中，
+// stdin/stdout/stderr重定向到/dev/null；失败返回false并设置error
+bool daemonize(std::string& error);
+
+// 监控启动完成：通知等待中的原进程以0退出，并向systemd发送READY=1
+void daemon_ready();
+
+// 启动失败时通知等待中的原进程以1退出
+void daemon_failed();
+
+// 向systemd发送通知(如 "STOPPING=1")，未在systemd下运行时什么也不做
+void sd_notify_state(const std::string& state);
+
+// 去掉此后std::cout/std::cerr输出中的emoji及其后的空格
+void install_plain_console();
+
+// pid文件：以flock加锁，防止同一pid文件上启动多个实例；析构时删除
+class PidFile {
+private:
+    std::string path_;
+    int fd_ = -1;
+
+public:
+    PidFile() = default;
+    ~PidFile();
+
+    PidFile(const PidFile&) = delete;
+    PidFile& operator=(const PidFile&) = delete;
+
+    // 创建并写入当前pid；已被其他运行中的实例持有时返回false。
+    // 锁随文件描述符在fork后由子进程继承，转入后台后调用write_pid()更新为子进程的pid
+    bool acquire(const std::string& path, std::string& error);
+    bool write_pid(std::string& error);
+    void release();
+};
@@ -0,0 +1,393 @@
+#include "dataplane_probe.h"
+#include <arpa/inet.h>
+#include <cerrno>
+#include <cstddef>
+#include <cstring>
+#include <linux/if_ether.h>
+#include <linux/netlink.h>
+#include <linux/pkt_cls.h>
+#include <linux/pkt_sched.h>
+#include <linux/rtnetlink.h>
+#include <net/if.h>
+#include <netinet/in.h>
+#include <sys/socket.h>
+#include <unistd.h>
+
+namespace {
+
+constexpr size_t REQUEST_BUFFER_SIZE = 512;
+
+// 程序写入ringbuf的记录，与下面的指令中的栈布局一致
+struct RawPacket {
+    uint64_t ktime_ns;
+    uint32_t ifindex;
+    uint32_t reserved;
+};
+static_assert(sizeof(RawPacket) == 16, "RawPacket layout");
+
+// tc egress分类器：以太网+IPv4(+TCP/UDP端口)匹配探测流，已布防且不是排除接口时撤防并写入一条记录。
+// 布防标记存于单元素数组，值为排除接口的ifindex+1，0表示未布防；多个CPU同时命中时可能写入多条，由用户态取最早的
+std::vector<bpf_insn> build_program(int ringbuf_fd, int armed_fd, uint32_t dst, uint16_t port) {
+    std::vector<bpf_insn> insns;
+    std::vector<size_t> exits;
+    auto emit = [&insns](bpf_insn insn) { insns.push_back(insn); };
+    auto jump_exit = [&](uint8_t code, uint8_t dst_reg, uint8_t src_reg, int32_t imm) {
+        exits.push_back(insns.size());
+        emit(bpf_make_insn(code, dst_reg, src_reg, 0, imm));
+    };
+
+    emit(bpf_make_insn(BPF_ALU64 | BPF_MOV | BPF_X, BPF_REG_6, BPF_REG_1, 0, 0));
+    emit(bpf_make_insn(BPF_LDX | BPF_MEM | BPF_W, BPF_REG_7, BPF_REG_6, offsetof(struct __sk_buff, ifindex), 0));
+    emit(bpf_make_insn(BPF_LDX | BPF_MEM | BPF_W, BPF_REG_2, BPF_REG_6, offsetof(struct __sk_buff, data), 0));
+    emit(bpf_make_insn(BPF_LDX | BPF_MEM | BPF_W, BPF_REG_3, BPF_REG_6, offsetof(struct __sk_buff, data_end), 0));
+    // 以太网头14字节 + IPv4头20字节 + TCP/UDP端口4字节
+    emit(bpf_make_insn(BPF_ALU64 | BPF_MOV | BPF_X, BPF_REG_4, BPF_REG_2, 0, 0));
+    emit(bpf_make_insn(BPF_ALU64 | BPF_ADD | BPF_K, BPF_REG_4, 0, 0, 38));
+    jump_exit(BPF_JMP | BPF_JGT | BPF_X, BPF_REG_4, BPF_REG_3, 0);
+    emit(bpf_make_insn(BPF_LDX | BPF_MEM | BPF_H, BPF_REG_4, BPF_REG_2, 12, 0));
+    jump_exit(BPF_JMP32 | BPF_JNE | BPF_K, BPF_REG_4, 0, htons(ETH_P_IP));
+    emit(bpf_make_insn(BPF_LDX | BPF_MEM | BPF_W, BPF_REG_4, BPF_REG_2, 30, 0));
+    jump_exit(BPF_JMP32 | BPF_JNE | BPF_K, BPF_REG_4, 0, static_cast<int32_t>(dst));
+    if (port != 0) {
+        // 只匹配不带选项的IPv4头，端口位于固定偏移
+        emit(bpf_make_insn(BPF_LDX | BPF_MEM | BPF_B, BPF_REG_4, BPF_REG_2, 14, 0));
+        jump_exit(BPF_JMP32 | BPF_JNE | BPF_K, BPF_REG_4, 0, 0x45);
+        emit(bpf_make_insn(BPF_LDX | BPF_MEM | BPF_B, BPF_REG_4, BPF_REG_2, 23, 0));
+        emit(bpf_make_insn(BPF_JMP32 | BPF_JEQ | BPF_K, BPF_REG_4, 0, 1, IPPROTO_TCP));
+        jump_exit(BPF_JMP32 | BPF_JNE | BPF_K, BPF_REG_4, 0, IPPROTO_UDP);
+        emit(bpf_make_insn(BPF_LDX | BPF_MEM | BPF_H, BPF_REG_4, BPF_REG_2, 36, 0));
+        jump_exit(BPF_JMP32 | BPF_JNE | BPF_K, BPF_REG_4, 0, htons(port));
+    }
+
+    emit(bpf_make_insn(BPF_ST | BPF_MEM | BPF_W, BPF_REG_10, 0, -4, 0));
+    emit(bpf_make_insn(BPF_LD | BPF_DW | BPF_IMM, BPF_REG_1, BPF_PSEUDO_MAP_FD, 0, armed_fd));
+    emit(bpf_make_insn(0, 0, 0, 0, 0));
+    emit(bpf_make_insn(BPF_ALU64 | BPF_MOV | BPF_X, BPF_REG_2, BPF_REG_10, 0, 0));
+    emit(bpf_make_insn(BPF_ALU64 | BPF_ADD | BPF_K, BPF_REG_2, 0, 0, -4));
+    emit(bpf_make_insn(BPF_JMP | BPF_CALL, 0, 0, 0, BPF_FUNC_map_lookup_elem));
+    jump_exit(BPF_JMP | BPF_JEQ | BPF_K, BPF_REG_0, 0, 0);
+    emit(bpf_make_insn(BPF_LDX | BPF_MEM | BPF_DW, BPF_REG_1, BPF_REG_0, 0, 0));
+    jump_exit(BPF_JMP | BPF_JEQ | BPF_K, BPF_REG_1, 0, 0);
+    emit(bpf_make_insn(BPF_ALU64 | BPF_MOV | BPF_X, BPF_REG_2, BPF_REG_7, 0, 0));
+    emit(bpf_make_insn(BPF_ALU64 | BPF_ADD | BPF_K, BPF_REG_2, 0, 0, 1));
+    jump_exit(BPF_JMP | BPF_JEQ | BPF_X, BPF_REG_1, BPF_REG_2, 0);
+    emit(bpf_make_insn(BPF_ST | BPF_MEM | BPF_DW, BPF_REG_0, 0, 0, 0));
+
+    emit(bpf_make_insn(BPF_JMP | BPF_CALL, 0, 0, 0, BPF_FUNC_ktime_get_ns));
+    emit(bpf_make_insn(BPF_STX | BPF_MEM | BPF_DW, BPF_REG_10, BPF_REG_0, -16, 0));
+    emit(bpf_make_insn(BPF_STX | BPF_MEM | BPF_W, BPF_REG_10, BPF_REG_7, -8, 0));
+    emit(bpf_make_insn(BPF_ST | BPF_MEM | BPF_W, BPF_REG_10, 0, -4, 0));
+    emit(bpf_make_insn(BPF_LD | BPF_DW | BPF_IMM, BPF_REG_1, BPF_PSEUDO_MAP_FD, 0, ringbuf_fd));
+    emit(bpf_make_insn(0, 0, 0, 0, 0));
+    emit(bpf_make_insn(BPF_ALU64 | BPF_MOV | BPF_X, BPF_REG_2, BPF_REG_10, 0, 0));
+    emit(bpf_make_insn(BPF_ALU64 | BPF_ADD | BPF_K, BPF_REG_2, 0, 0, -16));
+    emit(bpf_make_insn(BPF_ALU64 | BPF_MOV | BPF_K, BPF_REG_3, 0, 0, sizeof(RawPacket)));
+    emit(bpf_make_insn(BPF_ALU64 | BPF_MOV | BPF_K, BPF_REG_4, 0, 0, 0));
+    emit(bpf_make_insn(BPF_JMP | BPF_CALL, 0, 0, 0, BPF_FUNC_ringbuf_output));
+
+    // 不影响报文的去向，交给后续filter与qdisc
+    size_t exit_index = insns.size();
+    emit(bpf_make_insn(BPF_ALU64 | BPF_MOV | BPF_K, BPF_REG_0, 0, 0, TC_ACT_UNSPEC));
+    emit(bpf_make_insn(BPF_JMP | BPF_EXIT, 0, 0, 0, 0));
+    for (size_t index : exits) {
+        insns[index].off = static_cast<int16_t>(exit_index - index - 1);
+    }
+    return insns;
+}
+
+void add_attr(struct nlmsghdr* nlh, size_t max_len, int type, const void* data, size_t data_len) {
+    size_t len = RTA_LENGTH(data_len);
+    struct rtattr* rta = reinterpret_cast<struct rtattr*>(
+        reinterpret_cast<char*>(nlh) + NLMSG_ALIGN(nlh->nlmsg_len));
+    if (NLMSG_ALIGN(nlh->nlmsg_len) + RTA_ALIGN(len) > max_len) {
+        return;
+    }
+    rta->rta_type = type;
+    rta->rta_len = len;
+    if (data_len > 0) {
+        memcpy(RTA_DATA(rta), data, data_len);
+    }
+    nlh->nlmsg_len = NLMSG_ALIGN(nlh->nlmsg_len) + RTA_ALIGN(len);
+}
+
+struct rtattr* begin_nested(struct nlmsghdr* nlh, size_t max_len, int type) {
+    struct rtattr* nest = reinterpret_cast<struct rtattr*>(
+        reinterpret_cast<char*>(nlh) + NLMSG_ALIGN(nlh->nlmsg_len));
+    add_attr(nlh, max_len, type, nullptr, 0);
+    return nest;
+}
+
+void end_nested(struct nlmsghdr* nlh, struct rtattr* nest) {
+    nest->rta_len = reinterpret_cast<char*>(nlh) + nlh->nlmsg_len - reinterpret_cast<char*>(nest);
+}
+
+struct nlmsghdr* init_request(char* buffer, uint16_t type, uint16_t flags, int ifindex,
+                              uint32_t parent, uint32_t handle, uint32_t info) {
+    memset(buffer, 0, REQUEST_BUFFER_SIZE);
+    struct nlmsghdr* nlh = reinterpret_cast<struct nlmsghdr*>(buffer);
+    nlh->nlmsg_len = NLMSG_LENGTH(sizeof(struct tcmsg));
+    nlh->nlmsg_type = type;
+    nlh->nlmsg_flags = NLM_F_REQUEST | NLM_F_ACK | flags;
+    nlh->nlmsg_seq = 1;
+
+    struct tcmsg* tcm = static_cast<struct tcmsg*>(NLMSG_DATA(nlh));
+    tcm->tcm_family = AF_UNSPEC;
+    tcm->tcm_ifindex = ifindex;
+    tcm->tcm_parent = parent;
+    tcm->tcm_handle = handle;
+    tcm->tcm_info = info;
+    return nlh;
+}
+
+// 发送一条请求并等待ACK，返回0或负的errno；与路由表采样一样使用独立的短连接套接字
+int send_and_ack(struct nlmsghdr* nlh) {
+    int fd = socket(AF_NETLINK, SOCK_RAW | SOCK_CLOEXEC, NETLINK_ROUTE);
+    if (fd < 0) {
+        return -errno;
+    }
+    if (send(fd, nlh, nlh->nlmsg_len, 0) < 0) {
+        int saved = errno;
+        close(fd);
+        return -saved;
+    }
+
+    char buffer[4096];
+    ssize_t len = recv(fd, buffer, sizeof(buffer), 0);
+    int result = len < 0 ? -errno : 0;
+    for (struct nlmsghdr* reply = reinterpret_cast<struct nlmsghdr*>(buffer);
+         len > 0 && NLMSG_OK(reply, len); reply = NLMSG_NEXT(reply, len)) {
+        if (reply->nlmsg_type == NLMSG_ERROR) {
+            result = static_cast<struct nlmsgerr*>(NLMSG_DATA(reply))->error;
+            break;
+        }
+    }
+    close(fd);
+    return result;
+}
+
+uint32_t filter_info() {
+    return TC_H_MAKE(static_cast<uint32_t>(DataplaneProbe::FILTER_PRIORITY) << 16, htons(ETH_P_ALL));
+}
+
+} // namespace
+
+DataplaneProbe::DataplaneProbe(DataplaneFlow flow, std::vector<std::string> interfaces, Callback callback)
+    : flow_(std::move(flow)), interfaces_(std::move(interfaces)), callback_(std::move(callback)) {
+}
+
+DataplaneProbe::~DataplaneProbe() {
+    stop();
+}
+
+void DataplaneProbe::add_session_fields(JsonObject& obj, const std::optional<DataplanePacket>& packet,
+                                        int64_t trigger_ms) {
+    obj["dataplane_restored"] = packet.has_value();
+    if (packet.has_value()) {
+        obj["dataplane_restoration_time_ms"] = packet->restoration_ms(trigger_ms);
+        obj["dataplane_first_packet_ns"] = packet->timestamp_ns;
+        obj["dataplane_interface"] = packet->interface;
+    }
+}
+
+bool DataplaneProbe::parse_flow(const std::string& text, DataplaneFlow& flow, std::string& error) {
+    std::string address = text;
+    flow.port = 0;
+    auto colon = text.rfind(':');
+    if (colon != std::string::npos) {
+        address = text.substr(0, colon);
+        std::string port = text.substr(colon + 1);
+        char* end = nullptr;
+        long value = strtol(port.c_str(), &end, 10);
+        if (port.empty() || *end != '\0' || value < 1 || value > 65535) {
+            error = "invalid port: " + port;
+            return false;
+        }
+        flow.port = static_cast<uint16_t>(value);
+    }
+    struct in_addr addr;
+    if (inet_pton(AF_INET, address.c_str(), &addr) != 1) {
+        error = "invalid IPv4 address: " + address;
+        return false;
+    }
+    flow.dst = address;
+    return true;
+}
+
+bool DataplaneProbe::attach(const std::string& interface, std::string& error) {
+    Attachment attachment;
+    attachment.interface = interface;
+    attachment.ifindex = static_cast<int>(if_nametoindex(interface.c_str()));
+    if (attachment.ifindex == 0) {
+        error = "unknown interface " + interface;
+        return false;
+    }
+
+    // 接口上已有clsact(如其他eBPF程序创建)时沿用，停止时只删除自己的filter
+    alignas(struct nlmsghdr) char buffer[REQUEST_BUFFER_SIZE];
+    struct nlmsghdr* nlh = init_request(buffer, RTM_NEWQDISC, NLM_F_CREATE | NLM_F_EXCL, attachment.ifindex,
+                                        TC_H_CLSACT, TC_H_MAKE(TC_H_CLSACT, 0), 0);
+    const char clsact[] = "clsact";
+    add_attr(nlh, sizeof(buffer), TCA_KIND, clsact, sizeof(clsact));
+    int result = send_and_ack(nlh);
+    if (result != 0 && result != -EEXIST) {
+        error = interface + ": add clsact: " + strerror(-result);
+        return false;
+    }
+    attachment.created_clsact = result == 0;
+
+    nlh = init_request(buffer, RTM_NEWTFILTER, NLM_F_CREATE | NLM_F_EXCL, attachment.ifindex,
+                       TC_H_MAKE(TC_H_CLSACT, TC_H_MIN_EGRESS), FILTER_HANDLE, filter_info());
+    const char kind[] = "bpf";
+    add_attr(nlh, sizeof(buffer), TCA_KIND, kind, sizeof(kind));
+    struct rtattr* options = begin_nested(nlh, sizeof(buffer), TCA_OPTIONS);
+    uint32_t prog_fd = static_cast<uint32_t>(prog_fd_);
+    add_attr(nlh, sizeof(buffer), TCA_BPF_FD, &prog_fd, sizeof(prog_fd));
+    const char name[] = "converge_dataplane";
+    add_attr(nlh, sizeof(buffer), TCA_BPF_NAME, name, sizeof(name));
+    uint32_t flags = TCA_BPF_FLAG_ACT_DIRECT;
+    add_attr(nlh, sizeof(buffer), TCA_BPF_FLAGS, &flags, sizeof(flags));
+    end_nested(nlh, options);
+    result = send_and_ack(nlh);
+    if (result != 0) {
+        error = interface + ": add bpf filter: " + strerror(-result);
+        detach(attachment);
+        return false;
+    }
+
+    attachments_.push_back(attachment);
+    return true;
+}
+
+void DataplaneProbe::detach(const Attachment& attachment) {
+    alignas(struct nlmsghdr) char buffer[REQUEST_BUFFER_SIZE];
+    struct nlmsghdr* nlh;
+    if (attachment.created_clsact) {
+        // 删除clsact时其下的filter一并删除
+        nlh = init_request(buffer, RTM_DELQDISC, 0, attachment.ifindex,
+                           TC_H_CLSACT, TC_H_MAKE(TC_H_CLSACT, 0), 0);
+    } else {
+        nlh = init_request(buffer, RTM_DELTFILTER, 0, attachment.ifindex,
+                           TC_H_MAKE(TC_H_CLSACT, TC_H_MIN_EGRESS), FILTER_HANDLE, filter_info());
+        const char kind[] = "bpf";
+        add_attr(nlh, sizeof(buffer), TCA_KIND, kind, sizeof(kind));
+    }
+    // 接口已被删除时filter随之消失，失败无需处理
+    send_and_ack(nlh);
+}
+
+bool DataplaneProbe::start(std::string& error) {
+    if (running_.load()) {
+        return true;
+    }
+    struct in_addr dst;
+    if (inet_pton(AF_INET, flow_.dst.c_str(), &dst) != 1) {
+        error = "invalid IPv4 address: " + flow_.dst;
+        return false;
+    }
+
+    union bpf_attr attr;
+    memset(&attr, 0, sizeof(attr));
+    attr.map_type = BPF_MAP_TYPE_ARRAY;
+    attr.key_size = sizeof(uint32_t);
+    attr.value_size = sizeof(uint64_t);
+    attr.max_entries = 1;
+    armed_map_fd_ = bpf_syscall(BPF_MAP_CREATE, attr);
+    if (armed_map_fd_ < 0) {
+        error = std::string("BPF_MAP_CREATE(array): ") + strerror(errno);
+        return false;
+    }
+    if (!ringbuf_.open(RINGBUF_SIZE, error)) {
+        release();
+        return false;
+    }
+
+    prog_fd_ = bpf_load_program(BPF_PROG_TYPE_SCHED_CLS,
+                                build_program(ringbuf_.fd(), armed_map_fd_, dst.s_addr, flow_.port), error);
+    if (prog_fd_ < 0) {
+        release();
+        return false;
+    }
+
+    for (const auto& interface : interfaces_) {
+        if (!attach(interface, error)) {
+            release();
+            return false;
+        }
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&DataplaneProbe::worker_loop, this);
+    return true;
+}
+
+void DataplaneProbe::stop() {
+    if (running_.load()) {
+        running_.store(false);
+        if (worker_thread_.joinable()) {
+            worker_thread_.join();
+        }
+    }
+    release();
+}
+
+void DataplaneProbe::release() {
+    // 先从接口上卸下filter，再释放程序与map
+    for (const auto& attachment : attachments_) {
+        detach(attachment);
+    }
+    attachments_.clear();
+    if (prog_fd_ >= 0) {
+        close(prog_fd_);
+        prog_fd_ = -1;
+    }
+    ringbuf_.close();
+    if (armed_map_fd_ >= 0) {
+        close(armed_map_fd_);
+        armed_map_fd_ = -1;
+    }
+}
+
+bool DataplaneProbe::write_armed(uint64_t value) {
+    if (armed_map_fd_ < 0) {
+        return false;
+    }
+    uint32_t key = 0;
+    union bpf_attr attr;
+    memset(&attr, 0, sizeof(attr));
+    attr.map_fd = static_cast<uint32_t>(armed_map_fd_);
+    attr.key = reinterpret_cast<uint64_t>(&key);
+    attr.value = reinterpret_cast<uint64_t>(&value);
+    attr.flags = BPF_ANY;
+    return bpf_syscall(BPF_MAP_UPDATE_ELEM, attr) == 0;
+}
+
+void DataplaneProbe::arm(const std::string& exclude_interface) {
+    uint64_t exclude = exclude_interface.empty() ? 0 : if_nametoindex(exclude_interface.c_str());
+    write_armed(exclude + 1);
+}
+
+void DataplaneProbe::disarm() {
+    write_armed(0);
+}
+
+void DataplaneProbe::worker_loop() {
+    while (running_.load()) {
+        // 内核时间为CLOCK_MONOTONIC，按当前两个时钟的差换算到系统时钟
+        int64_t realtime_offset_ns = bpf_realtime_offset_ns();
+        ringbuf_.poll(100, [this, realtime_offset_ns](const char* data, uint32_t len) {
+            if (len < sizeof(RawPacket)) {
+                return;
+            }
+            RawPacket raw;
+            memcpy(&raw, data, sizeof(raw));
+            DataplanePacket packet;
+            packet.timestamp_ns = static_cast<int64_t>(raw.ktime_ns) + realtime_offset_ns;
+            for (const auto& attachment : attachments_) {
+                if (attachment.ifindex == static_cast<int>(raw.ifindex)) {
+                    packet.interface = attachment.interface;
+                }
+            }
+            callback_(packet);
+        });
+    }
+}
@@ -0,0 +1,84 @@
+#pragma once
+
+#include "bpf_util.h"
+#include "logger.h"
+#include <atomic>
+#include <cstdint>
+#include <functional>
+#include <optional>
+#include <string>
+#include <thread>
+#include <vector>
+
+// 探测流匹配条件(--dataplane-probe DST[:PORT])：IPv4目的地址，可选TCP/UDP目的端口
+struct DataplaneFlow {
+    std::string dst;
+    uint16_t port = 0;  // 0表示不匹配端口
+};
+
+// 布防后第一个匹配探测流并从某个接口转发出去的报文，时间为内核打点时间
+struct DataplanePacket {
+    int64_t timestamp_ns = 0;  // bpf_ktime_get_ns换算到系统时钟
+    std::string interface;
+
+    // 相对触发的恢复时间，触发时间只有毫秒精度
+    double restoration_ms(int64_t trigger_ms) const { return (timestamp_ns - trigger_ms * 1000000) / 1e6; }
+};
+
+// 在恢复路径的接口上挂载tc egress(clsact)eBPF分类器(--dataplane-probe/--dataplane-ifaces)，
+// 会话触发时布防，布防后第一个匹配探测流的转发报文即为数据面恢复时间；程序只在布防时写入一条记录，
+// 之后自行撤防，平时对转发路径的开销只有一次报文头比较。直接使用bpf()与rtnetlink，不依赖libbpf/tc；
+// 需要root(CAP_BPF与CAP_NET_ADMIN)与5.8以上内核(ringbuf)
+class DataplaneProbe {
+public:
+    using Callback = std::function<void(const DataplanePacket&)>;
+
+    static constexpr uint32_t RINGBUF_SIZE = 64 * 1024;
+    // 挂载的filter优先级与handle，停止时按此删除
+    static constexpr uint16_t FILTER_PRIORITY = 1;
+    static constexpr uint32_t FILTER_HANDLE = 1;
+
+private:
+    struct Attachment {
+        std::string interface;
+        int ifindex = 0;
+        bool created_clsact = false;  // clsact由本进程创建时停止时一并删除
+    };
+
+    DataplaneFlow flow_;
+    std::vector<std::string> interfaces_;
+    Callback callback_;
+    BpfRingbuf ringbuf_;
+    int armed_map_fd_ = -1;
+    int prog_fd_ = -1;
+    std::vector<Attachment> attachments_;
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+
+    bool attach(const std::string& interface, std::string& error);
+    void detach(const Attachment& attachment);
+    bool write_armed(uint64_t value);
+    void worker_loop();
+    void release();
+
+public:
+    DataplaneProbe(DataplaneFlow flow, std::vector<std::string> interfaces, Callback callback);
+    ~DataplaneProbe();
+
+    DataplaneProbe(const DataplaneProbe&) = delete;
+    DataplaneProbe& operator=(const DataplaneProbe&) = delete;
+
+    // 加载程序并挂载到全部接口，任一步失败时卸下已挂载的部分并返回false
+    bool start(std::string& error);
+    void stop();
+
+    // 布防：之后第一个匹配的转发报文回调一次；exclude_interface(通常为触发接口)上的报文不算恢复
+    void arm(const std::string& exclude_interface);
+    void disarm();
+
+    // 写入session_completed的dataplane_*字段，packet为空表示会话期间没有恢复
+    static void add_session_fields(JsonObject& obj, const std::optional<DataplanePacket>& packet, int64_t trigger_ms);
+
+    // 解析 DST[:PORT]，DST须为IPv4地址
+    static bool parse_flow(const std::string& text, DataplaneFlow& flow, std::string& error);
+};
@@ -0,0 +1,147 @@
+#include "debug_log.h"
+#include "timestamp_format.h"
+#include <chrono>
+#include <iostream>
+
+namespace {
+
+std::atomic<LogLevel> current_level{LogLevel::INFO};
+
+// 丢弃全部写入；与ostream(nullptr)不同，流状态保持正常，可再被其它streambuf包装
+class DiscardStreambuf : public std::streambuf {
+protected:
+    int overflow(int c) override { return traits_type::not_eof(c); }
+    std::streamsize xsputn(const char*, std::streamsize n) override { return n; }
+};
+
+std::ostream* machine_stream = nullptr;
+
+} // namespace
+
+bool parse_log_level(const std::string& text, LogLevel& level) {
+    if (text == "debug") {
+        level = LogLevel::DBG;
+        return true;
+    }
+    if (text == "info") {
+        level = LogLevel::INFO;
+        return true;
+    }
+    if (text == "warn" || text == "warning") {
+        level = LogLevel::WARN;
+        return true;
+    }
+    return false;
+}
+
+void set_log_level(LogLevel level) {
+    current_level.store(level);
+}
+
+LogLevel get_log_level() {
+    return current_level.load();
+}
+
+bool log_enabled(LogLevel level) {
+    return static_cast<int>(level) >= static_cast<int>(current_level.load());
+}
+
+std::ostream& info_out() {
+    // 没有streambuf的流会置badbit并忽略所有写入
+    static std::ostream discard(nullptr);
+    return log_enabled(LogLevel::INFO) ? std::cout : discard;
+}
+
+void silence_console() {
+    if (machine_stream) {
+        return;
+    }
+    // 与进程同生命周期，不释放
+    static DiscardStreambuf* discard = new DiscardStreambuf();
+    machine_stream = new std::ostream(std::cout.rdbuf());
+    std::cout.rdbuf(discard);
+}
+
+void redirect_console_to_stderr() {
+    if (machine_stream) {
+        return;
+    }
+    machine_stream = new std::ostream(std::cout.rdbuf());
+    std::cout.rdbuf(std::cerr.rdbuf());
+}
+
+bool console_silenced() {
+    return machine_stream != nullptr;
+}
+
+std::ostream& machine_out() {
+    return machine_stream ? *machine_stream : std::cout;
+}
+
+DebugChannel::DebugChannel(const std::string& path, int max_per_second)
+    : path_(path), max_per_second_(max_per_second) {}
+
+bool DebugChannel::open(std::string& error) {
+    std::lock_guard<std::mutex> lock(mutex_);
+    file_.open(path_, std::ios::out | std::ios::app);
+    if (!file_.is_open()) {
+        error = "无法打开调试日志文件: " + path_;
+        return false;
+    }
+    return true;
+}
+
+void DebugChannel::close() {
+    std::lock_guard<std::mutex> lock(mutex_);
+    if (file_.is_open()) {
+        if (window_suppressed_ > 0) {
+            JsonObject record;
+            record["category"] = "rate_limited";
+            record["suppressed"] = window_suppressed_;
+            file_ << Logger::json_to_string(record) << "\n";
+            window_suppressed_ = 0;
+        }
+        file_.close();
+    }
+}
+
+void DebugChannel::write(const std::string& category, JsonObject record) {
+    auto now = std::chrono::system_clock::now();
+    int64_t now_ms = std::chrono::duration_cast<std::chrono::milliseconds>(
+        now.time_since_epoch()).count();
+
+    std::lock_guard<std::mutex> lock(mutex_);
+    if (!file_.is_open()) {
+        return;
+    }
+
+    // 固定1秒窗口计数，进入新窗口时先说明上一窗口丢弃了多少条
+    if (now_ms - window_start_ms_ >= 1000) {
+        if (window_suppressed_ > 0) {
+            JsonObject notice;
+            notice["category"] = "rate_limited";
+            notice["timestamp_ms"] = now_ms;
+            notice["suppressed"] = window_suppressed_;
+            notice["max_per_second"] = max_per_second_;
+            file_ << Logger::json_to_string(notice) << "\n";
+        }
+        window_start_ms_ = now_ms;
+        window_count_ = 0;
+        window_suppressed_ = 0;
+    }
+    if (window_count_ >= max_per_second_) {
+        ++window_suppressed_;
+        suppressed_count_.fetch_add(1);
+        return;
+    }
+    ++window_count_;
+
+    record["timestamp"] = timestamp_json_value(
+        std::chrono::duration_cast<std::chrono::nanoseconds>(now.time_since_epoch()).count());
+    record["timestamp_ms"] = now_ms;
+    record["category"] = category;
+
+    // 逐行刷新，便于tail -f跟踪
+    file_ << Logger::json_to_string(record) << std::endl;
+    written_count_.fetch_add(1);
+}
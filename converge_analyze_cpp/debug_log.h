@@ -0,0 +1,71 @@
+#pragma once
+
+#include <atomic>
+#include <cstdint>
+#include <fstream>
+#include <mutex>
+#include <ostream>
+#include <string>
+#include "logger.h"
+
+// 控制台日志级别(--log-level)：warn只输出警告、错误和最终统计；
+// debug在info的基础上把每条原始netlink/tc消息和事件取舍原因写入调试通道。
+// Debug构建定义了DEBUG宏，因此调试级别命名为DBG
+enum class LogLevel { DBG, INFO, WARN };
+
+// 解析 debug/info/warn
+bool parse_log_level(const std::string& text, LogLevel& level);
+
+void set_log_level(LogLevel level);
+LogLevel get_log_level();
+bool log_enabled(LogLevel level);
+
+// INFO级别的控制台输出流，当前级别高于INFO时写入的内容被丢弃
+std::ostream& info_out();
+
+// --quiet/--console-format json：丢弃此后写入std::cout的全部内容(stderr不受影响)，
+// 须在启动任何线程之前调用
+void silence_console();
+bool console_silenced();
+
+// --output -：此后写入std::cout的控制台提示改写到stderr，stdout只留给机器可读输出，
+// 须在启动任何线程之前调用；已调用silence_console时不起作用
+void redirect_console_to_stderr();
+
+// 机器可读输出(--output stdout等)使用的标准输出，silence_console之后仍写入真正的stdout
+std::ostream& machine_out();
+
+// 调试通道：与JSON日志分开的NDJSON文件，每行一条原始消息或判定说明。
+// 每秒最多写入max_per_second条，超出的丢弃并在下一秒写一条rate_limited记录说明丢弃数量。
+class DebugChannel {
+private:
+    std::string path_;
+    int max_per_second_;
+
+    std::mutex mutex_;
+    std::ofstream file_;
+    int64_t window_start_ms_ = 0;
+    int window_count_ = 0;
+    int64_t window_suppressed_ = 0;
+
+    std::atomic<int64_t> written_count_{0};
+    std::atomic<int64_t> suppressed_count_{0};
+
+public:
+    static constexpr int DEFAULT_MAX_PER_SECOND = 200;
+
+    explicit DebugChannel(const std::string& path, int max_per_second = DEFAULT_MAX_PER_SECOND);
+
+    DebugChannel(const DebugChannel&) = delete;
+    DebugChannel& operator=(const DebugChannel&) = delete;
+
+    bool open(std::string& error);
+    void close();
+
+    // 写入一条记录，自动加上timestamp与category字段；可从任意线程调用
+    void write(const std::string& category, JsonObject record);
+
+    const std::string& path() const { return path_; }
+    int64_t written_count() const { return written_count_.load(); }
+    int64_t suppressed_count() const { return suppressed_count_.load(); }
+};
@@ -0,0 +1,178 @@
+#include "event_filter.h"
+#include "route_tables.h"
+#include <algorithm>
+#include <arpa/inet.h>
+#include <cstdlib>
+#include <cstring>
+#include <sstream>
+
+namespace {
+
+std::vector<std::string> split_list(const std::string& list) {
+    std::vector<std::string> items;
+    std::istringstream iss(list);
+    std::string item;
+    while (std::getline(iss, item, ',')) {
+        size_t start = item.find_first_not_of(" \t");
+        size_t end = item.find_last_not_of(" \t");
+        if (start != std::string::npos) {
+            items.push_back(item.substr(start, end - start + 1));
+        }
+    }
+    return items;
+}
+
+} // namespace
+
+bool EventFilter::parse_prefix(const std::string& text, Prefix& prefix) {
+    std::string address = text;
+    int length = -1;
+    size_t slash = text.find('/');
+    if (slash != std::string::npos) {
+        address = text.substr(0, slash);
+        std::string length_text = text.substr(slash + 1);
+        if (length_text.empty() || length_text.find_first_not_of("0123456789") != std::string::npos ||
+            length_text.size() > 3) {
+            return false;
+        }
+        length = std::stoi(length_text);
+    }
+
+    memset(prefix.address, 0, sizeof(prefix.address));
+    if (inet_pton(AF_INET, address.c_str(), prefix.address) == 1) {
+        prefix.family = AF_INET;
+        if (length < 0) length = 32;
+        if (length > 32) return false;
+    } else if (inet_pton(AF_INET6, address.c_str(), prefix.address) == 1) {
+        prefix.family = AF_INET6;
+        if (length < 0) length = 128;
+        if (length > 128) return false;
+    } else {
+        return false;
+    }
+    prefix.length = length;
+    prefix.text = text;
+    return true;
+}
+
+bool EventFilter::matches(const std::unordered_map<std::string, std::string>& info, bool is_route) const {
+    if (!interfaces.empty()) {
+        auto iface_it = info.find("interface");
+        if (iface_it == info.end()) {
+            return false;
+        }
+        bool found = false;
+        for (const auto& name : interfaces) {
+            if (name == iface_it->second) {
+                found = true;
+                break;
+            }
+        }
+        if (!found) {
+            return false;
+        }
+    }
+
+    if (is_route && !tables.empty()) {
+        auto table_it = info.find("table");
+        if (table_it != info.end()) {
+            uint32_t table = static_cast<uint32_t>(strtoul(table_it->second.c_str(), nullptr, 10));
+            if (std::find(tables.begin(), tables.end(), table) == tables.end()) {
+                return false;
+            }
+        }
+    }
+
+    if (!is_route || prefixes.empty()) {
+        return true;
+    }
+
+    // dst为路由目的地址(不含长度)，落在任一前缀内即匹配
+    auto dst_it = info.find("dst");
+    if (dst_it == info.end()) {
+        return false;
+    }
+    uint8_t dst[16] = {};
+    int family;
+    if (inet_pton(AF_INET, dst_it->second.c_str(), dst) == 1) {
+        family = AF_INET;
+    } else if (inet_pton(AF_INET6, dst_it->second.c_str(), dst) == 1) {
+        family = AF_INET6;
+    } else {
+        return false;
+    }
+    for (const auto& prefix : prefixes) {
+        if (prefix.family != family) {
+            continue;
+        }
+        int full_bytes = prefix.length / 8;
+        int remaining_bits = prefix.length % 8;
+        if (memcmp(dst, prefix.address, static_cast<size_t>(full_bytes)) != 0) {
+            continue;
+        }
+        if (remaining_bits > 0) {
+            uint8_t mask = static_cast<uint8_t>(0xff << (8 - remaining_bits));
+            if ((dst[full_bytes] & mask) != (prefix.address[full_bytes] & mask)) {
+                continue;
+            }
+        }
+        return true;
+    }
+    return false;
+}
+
+void EventFilter::set_interfaces(const std::string& list) {
+    interfaces = split_list(list);
+}
+
+bool EventFilter::set_prefixes(const std::string& list, std::string& error) {
+    std::vector<Prefix> parsed;
+    for (const auto& item : split_list(list)) {
+        Prefix prefix;
+        if (!parse_prefix(item, prefix)) {
+            error = "invalid prefix " + item;
+            return false;
+        }
+        parsed.push_back(prefix);
+    }
+    prefixes = std::move(parsed);
+    return true;
+}
+
+bool EventFilter::set_tables(const std::string& list, std::string& error) {
+    std::vector<uint32_t> parsed;
+    for (const auto& item : split_list(list)) {
+        uint32_t table;
+        if (!parse_route_table(item, table)) {
+            error = "unknown route table " + item;
+            return false;
+        }
+        parsed.push_back(table);
+    }
+    tables = std::move(parsed);
+    return true;
+}
+
+std::string EventFilter::interfaces_text() const {
+    std::string text;
+    for (const auto& name : interfaces) {
+        text += (text.empty() ? "" : ",") + name;
+    }
+    return text;
+}
+
+std::string EventFilter::tables_text() const {
+    std::string text;
+    for (uint32_t table : tables) {
+        text += (text.empty() ? "" : ",") + route_table_name(table);
+    }
+    return text;
+}
+
+std::string EventFilter::prefixes_text() const {
+    std::string text;
+    for (const auto& prefix : prefixes) {
+        text += (text.empty() ? "" : ",") + prefix.text;
+    }
+    return text;
+}
@@ -0,0 +1,40 @@
+#pragma once
+
+#include <cstdint>
+#include <string>
+#include <unordered_map>
+#include <vector>
+
+// netlink事件过滤(--filter-interface/--filter-prefix/--tables，可在运行中通过控制套接字或--config重新加载修改)。
+// 接口条件同时作用于路由与qdisc事件，前缀与路由表条件只作用于路由事件；条件为空表示不限。
+class EventFilter {
+public:
+    struct Prefix {
+        int family = 0;
+        uint8_t address[16] = {};
+        int length = 0;
+        std::string text;
+    };
+
+    std::vector<std::string> interfaces;
+    std::vector<Prefix> prefixes;
+    std::vector<uint32_t> tables;  // 路由表ID；策略路由规则按其查找的表匹配，没有表的事件(如FDB)不受限制
+
+    bool empty() const { return interfaces.empty() && prefixes.empty() && tables.empty(); }
+
+    // info为解析后的路由/qdisc字段(interface、dst)，is_route区分事件类型
+    bool matches(const std::unordered_map<std::string, std::string>& info, bool is_route) const;
+
+    // 逗号分隔的接口名/前缀列表，空字符串清空对应条件
+    void set_interfaces(const std::string& list);
+    bool set_prefixes(const std::string& list, std::string& error);
+    // 表名(/etc/iproute2/rt_tables或VRF设备名)或数字ID
+    bool set_tables(const std::string& list, std::string& error);
+
+    std::string interfaces_text() const;
+    std::string prefixes_text() const;
+    std::string tables_text() const;
+
+    // 解析 "10.0.0.0/8"、"2001:db8::/32"，不带长度时为主机前缀
+    static bool parse_prefix(const std::string& text, Prefix& prefix);
+};
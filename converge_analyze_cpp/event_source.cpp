@@ -0,0 +1,50 @@
+#include "event_source.h"
+#include <map>
+
+namespace {
+
+struct Registration {
+    std::string description;
+    EventSourceRegistry::Factory factory;
+};
+
+// 函数内静态变量，避免与各插件文件中的注册语句之间的初始化顺序问题
+std::map<std::string, Registration>& registry() {
+    static std::map<std::string, Registration> sources;
+    return sources;
+}
+
+} // namespace
+
+bool EventSourceRegistry::add(const std::string& scheme, const std::string& description, Factory factory) {
+    return registry().emplace(scheme, Registration{description, std::move(factory)}).second;
+}
+
+std::unique_ptr<EventSource> EventSourceRegistry::create(const std::string& spec, EventSource::Callback callback,
+                                                         std::string& error) {
+    size_t colon = spec.find(':');
+    std::string scheme = spec.substr(0, colon);
+    auto it = registry().find(scheme);
+    if (it == registry().end()) {
+        error = "unknown event source: " + scheme;
+        return nullptr;
+    }
+    std::string args = colon == std::string::npos ? "" : spec.substr(colon + 1);
+    auto source = it->second.factory(args, std::move(callback), error);
+    if (!source && error.empty()) {
+        error = "invalid event source: " + spec;
+    }
+    return source;
+}
+
+bool EventSourceRegistry::is_supported(const std::string& spec) {
+    return registry().count(spec.substr(0, spec.find(':'))) > 0;
+}
+
+std::vector<std::pair<std::string, std::string>> EventSourceRegistry::list() {
+    std::vector<std::pair<std::string, std::string>> result;
+    for (const auto& entry : registry()) {
+        result.emplace_back(entry.first, entry.second.description);
+    }
+    return result;
+}
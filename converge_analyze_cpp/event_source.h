@@ -0,0 +1,61 @@
+#pragma once
+
+#include <cstdint>
+#include <functional>
+#include <memory>
+#include <string>
+#include <unordered_map>
+#include <utility>
+#include <vector>
+
+// 事件源插件产生的一条事件，字段均为字符串
+struct SourceEvent {
+    std::string kind;            // 事件类型，如 bfd_down、link_flap
+    int64_t timestamp_ms = 0;    // 为0时使用接收时间
+    bool trigger = false;        // 空闲时开始新会话，会话进行中时计入该会话
+    std::unordered_map<std::string, std::string> fields;  // interface、peer等，interface参与--threshold-override匹配
+};
+
+// 事件源插件(--source)：start()后在自己的线程中产生事件，通过创建时传入的回调交给监控核心。
+// 监控核心只看到SourceEvent，新增遥测输入不需要修改ConvergenceMonitor。
+// 生命周期: start() -> 回调... -> stop()，stop()返回后不再回调
+class EventSource {
+public:
+    using Callback = std::function<void(const std::string& source, const SourceEvent& event)>;
+
+    virtual ~EventSource() = default;
+
+    // 写入记录的source字段，也作为触发源(trigger_source)
+    virtual std::string name() const = 0;
+    virtual bool start(std::string& error) = 0;
+    virtual void stop() = 0;
+    // 退出时打印的统计，如 "exec: 已接收 12 条事件"
+    virtual std::string summary() const = 0;
+};
+
+// 事件源注册表。插件在自己的源文件中用REGISTER_EVENT_SOURCE注册，
+// 是否编入程序只取决于该文件是否在CMakeLists.txt的SOURCES中
+class EventSourceRegistry {
+public:
+    // args为SPEC中冒号之后的部分
+    using Factory = std::function<std::unique_ptr<EventSource>(const std::string& args,
+                                                               EventSource::Callback callback,
+                                                               std::string& error)>;
+
+    static bool add(const std::string& scheme, const std::string& description, Factory factory);
+
+    // 按 "SCHEME:ARGS" 创建，未注册的SCHEME或参数错误时返回nullptr并设置error
+    static std::unique_ptr<EventSource> create(const std::string& spec, EventSource::Callback callback,
+                                               std::string& error);
+    // 只检查SCHEME是否已注册(命令行参数校验)
+    static bool is_supported(const std::string& spec);
+
+    // (SCHEME, 说明)，按SCHEME排序
+    static std::vector<std::pair<std::string, std::string>> list();
+};
+
+#define EVENT_SOURCE_CONCAT_(a, b) a##b
+#define EVENT_SOURCE_CONCAT(a, b) EVENT_SOURCE_CONCAT_(a, b)
+#define REGISTER_EVENT_SOURCE(scheme, description, factory) \
+    static const bool EVENT_SOURCE_CONCAT(event_source_registered_, __LINE__) = \
+        EventSourceRegistry::add(scheme, description, factory)
@@ -0,0 +1,159 @@
+#include "exec_source.h"
+#include "log_reader.h"
+#include "subprocess.h"
+#include <cerrno>
+#include <chrono>
+#include <csignal>
+#include <iostream>
+#include <poll.h>
+#include <sys/wait.h>
+#include <unistd.h>
+
+REGISTER_EVENT_SOURCE("exec", "运行命令，读取其输出的每行JSON事件 (exec:COMMAND)",
+    [](const std::string& args, EventSource::Callback callback, std::string& error) -> std::unique_ptr<EventSource> {
+        if (args.empty()) {
+            error = "exec: missing command";
+            return nullptr;
+        }
+        return std::make_unique<ExecEventSource>(args, std::move(callback));
+    });
+
+ExecEventSource::ExecEventSource(const std::string& command, Callback callback)
+    : command_(command), callback_(std::move(callback)) {
+}
+
+ExecEventSource::~ExecEventSource() {
+    stop();
+}
+
+bool ExecEventSource::start(std::string&) {
+    if (running_.load()) {
+        return true;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&ExecEventSource::worker_loop, this);
+    return true;
+}
+
+void ExecEventSource::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+}
+
+void ExecEventSource::worker_loop() {
+    while (running_.load()) {
+        int fd = -1;
+        std::string error;
+        pid_t pid = spawn_command({"/bin/sh", "-c", command_}, fd, error);
+        if (pid < 0) {
+            std::cerr << "⚠️  exec事件源启动失败: " << error << "\n";
+            return;
+        }
+
+        std::string buffer;
+        bool eof = false;
+        while (running_.load() && !eof) {
+            struct pollfd pfd = {fd, POLLIN, 0};
+            if (poll(&pfd, 1, 100) <= 0) {
+                continue;
+            }
+            char chunk[4096];
+            ssize_t len = read(fd, chunk, sizeof(chunk));
+            if (len > 0) {
+                buffer.append(chunk, static_cast<size_t>(len));
+                size_t newline;
+                while ((newline = buffer.find('\n')) != std::string::npos) {
+                    handle_line(buffer.substr(0, newline));
+                    buffer.erase(0, newline + 1);
+                }
+            } else if (len == 0 || (errno != EAGAIN && errno != EINTR)) {
+                eof = true;
+            }
+        }
+        if (eof && !buffer.empty()) {
+            handle_line(buffer);
+        }
+
+        if (!eof) {
+            kill(pid, SIGTERM);
+        }
+        close(fd);
+        int status = 0;
+        waitpid(pid, &status, 0);
+
+        if (!running_.load()) {
+            break;
+        }
+        if (WIFEXITED(status) && WEXITSTATUS(status) == 127) {
+            std::cerr << "⚠️  exec事件源启动失败: " << command_ << ": command not found\n";
+            return;
+        }
+
+        std::cerr << "⚠️  exec事件源命令已退出，2秒后重新启动: " << command_ << "\n";
+        restart_count_.fetch_add(1);
+        for (int i = 0; i < 20 && running_.load(); ++i) {
+            std::this_thread::sleep_for(std::chrono::milliseconds(100));
+        }
+    }
+}
+
+void ExecEventSource::handle_line(const std::string& line) {
+    if (line.find_first_not_of(" \t\r") == std::string::npos) {
+        return;
+    }
+    std::string source;
+    SourceEvent event;
+    if (!parse_line(line, source, event)) {
+        invalid_count_.fetch_add(1);
+        std::cerr << "   exec: " << line << "\n";
+        return;
+    }
+    event_count_.fetch_add(1);
+    callback_(source.empty() ? name() : source, event);
+}
+
+bool ExecEventSource::parse_line(const std::string& line, std::string& source, SourceEvent& event) {
+    JsonObject obj;
+    if (!LogReader::parse_object(line, obj)) {
+        return false;
+    }
+    event.kind = LogReader::get_string(obj, "kind");
+    if (event.kind.empty()) {
+        return false;
+    }
+    event.trigger = LogReader::get_bool(obj, "trigger");
+    source = LogReader::get_string(obj, "source");
+    std::string timestamp = LogReader::get_timestamp(obj, "timestamp");
+    if (!timestamp.empty()) {
+        int64_t timestamp_ms = LogReader::parse_timestamp_ms(timestamp);
+        if (timestamp_ms < 0) {
+            return false;
+        }
+        event.timestamp_ms = timestamp_ms;
+    }
+    for (const auto& field : obj) {
+        if (field.first != "kind" && field.first != "trigger" && field.first != "source" &&
+            field.first != "timestamp") {
+            event.fields[field.first] = LogReader::get_string(obj, field.first);
+        }
+    }
+    return true;
+}
+
+std::string ExecEventSource::summary() const {
+    std::string text = "exec: 已接收 " + std::to_string(event_count_.load()) + " 条事件";
+    if (invalid_count_.load() > 0) {
+        text += "，无法解析 " + std::to_string(invalid_count_.load()) + " 行";
+    }
+    if (restart_count_.load() > 0) {
+        text += "，命令重启 " + std::to_string(restart_count_.load()) + " 次";
+    }
+    return text;
+}
@@ -0,0 +1,39 @@
+#pragma once
+
+#include <atomic>
+#include <string>
+#include <thread>
+#include "event_source.h"
+
+// 运行外部命令并把其标准输出的每行JSON对象作为事件: exec:COMMAND (经/bin/sh -c执行)
+//   {"kind":"bfd_down","trigger":true,"interface":"eth1","peer":"10.0.0.2"}
+// kind必填；trigger默认为false；timestamp可选(RFC3339或纪元毫秒)；source可选，覆盖记录中的来源名；
+// 其余字段转为字符串。非JSON行原样打印到stderr。命令退出后2秒重新启动
+class ExecEventSource : public EventSource {
+private:
+    std::string command_;
+    Callback callback_;
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+    std::atomic<int64_t> event_count_{0};
+    std::atomic<int64_t> invalid_count_{0};
+    std::atomic<int64_t> restart_count_{0};
+
+    void worker_loop();
+    void handle_line(const std::string& line);
+
+public:
+    ExecEventSource(const std::string& command, Callback callback);
+    ~ExecEventSource() override;
+
+    ExecEventSource(const ExecEventSource&) = delete;
+    ExecEventSource& operator=(const ExecEventSource&) = delete;
+
+    std::string name() const override { return "exec"; }
+    bool start(std::string& error) override;
+    void stop() override;
+    std::string summary() const override;
+
+    // 解析一行输出，不是带kind的JSON对象时返回false
+    static bool parse_line(const std::string& line, std::string& source, SourceEvent& event);
+};
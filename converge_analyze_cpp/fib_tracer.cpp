@@ -0,0 +1,221 @@
+#include "fib_tracer.h"
+#include <algorithm>
+#include <arpa/inet.h>
+#include <cerrno>
+#include <cstring>
+#include <fstream>
+#include <linux/perf_event.h>
+#include <sys/ioctl.h>
+#include <sys/syscall.h>
+#include <unistd.h>
+
+namespace {
+
+// kprobe上下文(struct pt_regs)中第三个参数寄存器的偏移：x86_64为rdx，aarch64为regs[2]
+#if defined(__x86_64__)
+constexpr int16_t PARM3_OFFSET = 12 * 8;
+#elif defined(__aarch64__)
+constexpr int16_t PARM3_OFFSET = 2 * 8;
+#else
+constexpr int16_t PARM3_OFFSET = -1;
+#endif
+
+// 程序写入ringbuf的记录，与下面的指令中的栈布局一致
+struct RawEvent {
+    uint64_t ktime_ns;
+    uint32_t insert;
+    uint32_t table;
+    uint32_t dst;  // 网络字节序
+    uint32_t dst_len;
+};
+static_assert(sizeof(RawEvent) == 24, "RawEvent layout");
+
+// fib_table_insert/fib_table_delete(net, tb, cfg, extack)：读取cfg开头16字节，
+// 其中fc_dst_len在偏移0、fc_table在8、fc_dst在12(自4.x以来未变)，连同ktime写入ringbuf
+std::vector<bpf_insn> build_program(int ringbuf_fd, bool insert) {
+    return {
+        bpf_make_insn(BPF_ALU64 | BPF_MOV | BPF_X, BPF_REG_6, BPF_REG_1, 0, 0),
+        bpf_make_insn(BPF_LDX | BPF_MEM | BPF_DW, BPF_REG_7, BPF_REG_6, PARM3_OFFSET, 0),
+        bpf_make_insn(BPF_JMP | BPF_CALL, 0, 0, 0, BPF_FUNC_ktime_get_ns),
+        bpf_make_insn(BPF_STX | BPF_MEM | BPF_DW, BPF_REG_10, BPF_REG_0, -24, 0),
+        bpf_make_insn(BPF_ST | BPF_MEM | BPF_W, BPF_REG_10, 0, -16, insert ? 1 : 0),
+        bpf_make_insn(BPF_ALU64 | BPF_MOV | BPF_X, BPF_REG_1, BPF_REG_10, 0, 0),
+        bpf_make_insn(BPF_ALU64 | BPF_ADD | BPF_K, BPF_REG_1, 0, 0, -40),
+        bpf_make_insn(BPF_ALU64 | BPF_MOV | BPF_K, BPF_REG_2, 0, 0, 16),
+        bpf_make_insn(BPF_ALU64 | BPF_MOV | BPF_X, BPF_REG_3, BPF_REG_7, 0, 0),
+        bpf_make_insn(BPF_JMP | BPF_CALL, 0, 0, 0, BPF_FUNC_probe_read_kernel),
+        bpf_make_insn(BPF_LDX | BPF_MEM | BPF_B, BPF_REG_1, BPF_REG_10, -40, 0),
+        bpf_make_insn(BPF_STX | BPF_MEM | BPF_W, BPF_REG_10, BPF_REG_1, -4, 0),
+        bpf_make_insn(BPF_LDX | BPF_MEM | BPF_W, BPF_REG_1, BPF_REG_10, -32, 0),
+        bpf_make_insn(BPF_STX | BPF_MEM | BPF_W, BPF_REG_10, BPF_REG_1, -12, 0),
+        bpf_make_insn(BPF_LDX | BPF_MEM | BPF_W, BPF_REG_1, BPF_REG_10, -28, 0),
+        bpf_make_insn(BPF_STX | BPF_MEM | BPF_W, BPF_REG_10, BPF_REG_1, -8, 0),
+        bpf_make_insn(BPF_LD | BPF_DW | BPF_IMM, BPF_REG_1, BPF_PSEUDO_MAP_FD, 0, ringbuf_fd),
+        bpf_make_insn(0, 0, 0, 0, 0),
+        bpf_make_insn(BPF_ALU64 | BPF_MOV | BPF_X, BPF_REG_2, BPF_REG_10, 0, 0),
+        bpf_make_insn(BPF_ALU64 | BPF_ADD | BPF_K, BPF_REG_2, 0, 0, -24),
+        bpf_make_insn(BPF_ALU64 | BPF_MOV | BPF_K, BPF_REG_3, 0, 0, sizeof(RawEvent)),
+        bpf_make_insn(BPF_ALU64 | BPF_MOV | BPF_K, BPF_REG_4, 0, 0, 0),
+        bpf_make_insn(BPF_JMP | BPF_CALL, 0, 0, 0, BPF_FUNC_ringbuf_output),
+        bpf_make_insn(BPF_ALU64 | BPF_MOV | BPF_K, BPF_REG_0, 0, 0, 0),
+        bpf_make_insn(BPF_JMP | BPF_EXIT, 0, 0, 0, 0),
+    };
+}
+
+} // namespace
+
+FibTracer::FibTracer(Callback callback) : callback_(std::move(callback)) {
+}
+
+FibTracer::~FibTracer() {
+    stop();
+}
+
+bool FibTracer::attach(const char* function, bool insert, int kprobe_type, std::string& error) {
+    int prog_fd = bpf_load_program(BPF_PROG_TYPE_KPROBE, build_program(ringbuf_.fd(), insert), error);
+    if (prog_fd < 0) {
+        return false;
+    }
+    prog_fds_.push_back(prog_fd);
+
+    struct perf_event_attr pattr;
+    memset(&pattr, 0, sizeof(pattr));
+    pattr.size = sizeof(pattr);
+    pattr.type = static_cast<uint32_t>(kprobe_type);
+    pattr.config1 = reinterpret_cast<uint64_t>(function);
+    pattr.config2 = 0;
+    int perf_fd = static_cast<int>(syscall(__NR_perf_event_open, &pattr, -1, 0, -1, PERF_FLAG_FD_CLOEXEC));
+    if (perf_fd < 0) {
+        error = std::string("perf_event_open(") + function + "): " + strerror(errno);
+        return false;
+    }
+    perf_fds_.push_back(perf_fd);
+
+    if (ioctl(perf_fd, PERF_EVENT_IOC_SET_BPF, prog_fd) < 0 || ioctl(perf_fd, PERF_EVENT_IOC_ENABLE, 0) < 0) {
+        error = std::string("attach ") + function + ": " + strerror(errno);
+        return false;
+    }
+    return true;
+}
+
+bool FibTracer::start(std::string& error) {
+    if (running_.load()) {
+        return true;
+    }
+    if (PARM3_OFFSET < 0) {
+        error = "unsupported architecture";
+        return false;
+    }
+
+    // kprobe PMU(4.17以上)，没有时说明内核未开启CONFIG_KPROBES
+    int kprobe_type = -1;
+    std::ifstream type_file("/sys/bus/event_source/devices/kprobe/type");
+    if (!(type_file >> kprobe_type)) {
+        error = "kprobe PMU not available (kernel without CONFIG_KPROBES?)";
+        return false;
+    }
+
+    if (!ringbuf_.open(RINGBUF_SIZE, error)) {
+        return false;
+    }
+
+    if (!attach("fib_table_insert", true, kprobe_type, error) ||
+        !attach("fib_table_delete", false, kprobe_type, error)) {
+        release();
+        return false;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&FibTracer::worker_loop, this);
+    return true;
+}
+
+void FibTracer::stop() {
+    if (running_.load()) {
+        running_.store(false);
+        if (worker_thread_.joinable()) {
+            worker_thread_.join();
+        }
+    }
+    release();
+}
+
+void FibTracer::release() {
+    // 先关闭perf事件卸下程序，再释放程序与ringbuf
+    for (int fd : perf_fds_) {
+        close(fd);
+    }
+    perf_fds_.clear();
+    for (int fd : prog_fds_) {
+        close(fd);
+    }
+    prog_fds_.clear();
+    ringbuf_.close();
+}
+
+void FibTracer::worker_loop() {
+    while (running_.load()) {
+        // 内核时间为CLOCK_MONOTONIC，按当前两个时钟的差换算到系统时钟
+        int64_t realtime_offset_ns = bpf_realtime_offset_ns();
+        ringbuf_.poll(100, [this, realtime_offset_ns](const char* data, uint32_t len) {
+            if (len < sizeof(RawEvent)) {
+                return;
+            }
+            RawEvent raw;
+            memcpy(&raw, data, sizeof(raw));
+            FibTraceEvent event;
+            event.timestamp_us = (static_cast<int64_t>(raw.ktime_ns) + realtime_offset_ns) / 1000;
+            event.insert = raw.insert != 0;
+            event.table = raw.table;
+            event.dst_len = static_cast<int>(raw.dst_len);
+            char buffer[INET_ADDRSTRLEN];
+            struct in_addr addr;
+            addr.s_addr = raw.dst;
+            event.dst = inet_ntop(AF_INET, &addr, buffer, sizeof(buffer)) ? buffer : "";
+            callback_(event);
+        });
+    }
+}
+
+FibTraceSummary FibTraceSummary::summarize(const std::vector<FibTraceEvent>& events, int64_t trigger_ms,
+                                           const std::string& trigger_dst, const std::string& trigger_dst_len,
+                                           std::optional<int64_t> last_route_event_ms) {
+    FibTraceSummary summary;
+    int64_t start_us = trigger_ms * 1000;
+    if (!trigger_dst.empty()) {
+        for (const auto& event : events) {
+            // 毫秒时间戳向下取整，同一毫秒内的内核时间也不晚于收到消息的时间
+            if (event.timestamp_us > trigger_ms * 1000 + 999) {
+                break;
+            }
+            if (!event.insert && event.dst == trigger_dst && std::to_string(event.dst_len) == trigger_dst_len) {
+                start_us = event.timestamp_us;
+                summary.kernel_trigger = true;
+            }
+        }
+    }
+    std::optional<int64_t> last_us;
+    int64_t last_route_us = last_route_event_ms.has_value() ? last_route_event_ms.value() * 1000 + 999 : 0;
+    for (const auto& event : events) {
+        if (summary.kernel_trigger ? event.timestamp_us <= start_us : event.timestamp_us < start_us) {
+            continue;
+        }
+        (event.insert ? summary.inserts : summary.deletes)++;
+        if (event.timestamp_us <= last_route_us) {
+            last_us = event.timestamp_us;
+        }
+    }
+    if (last_us.has_value()) {
+        summary.convergence_us = std::max<int64_t>(0, last_us.value() - start_us);
+    }
+    return summary;
+}
+
+void FibTraceSummary::add_to_json(JsonObject& obj) const {
+    obj["fib_trace_inserts"] = inserts;
+    obj["fib_trace_deletes"] = deletes;
+    obj["kernel_trigger"] = kernel_trigger;
+    if (convergence_us.has_value()) {
+        obj["kernel_convergence_time_ms"] = convergence_us.value() / 1000.0;
+    }
+}
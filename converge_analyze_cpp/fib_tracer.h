@@ -0,0 +1,70 @@
+#pragma once
+
+#include "bpf_util.h"
+#include "logger.h"
+#include <atomic>
+#include <cstdint>
+#include <functional>
+#include <optional>
+#include <string>
+#include <thread>
+#include <vector>
+
+// 内核中的一次IPv4 FIB表项插入或删除(fib_table_insert/fib_table_delete)，时间为内核打点时间
+struct FibTraceEvent {
+    int64_t timestamp_us = 0;  // bpf_ktime_get_ns换算到系统时钟的微秒
+    bool insert = true;
+    uint32_t table = 0;
+    std::string dst;           // 如 "10.0.0.0"
+    int dst_len = 0;
+};
+
+// 一个会话的内核FIB变化：收敛终点为最后一条路由事件对应的内核写入时间；路由触发时起点为触发路由的
+// 内核删除时间，netem触发没有对应的FIB变化，起点仍为收到qdisc消息的时间
+struct FibTraceSummary {
+    int64_t inserts = 0;
+    int64_t deletes = 0;
+    bool kernel_trigger = false;  // 起点取自触发路由的内核删除时间
+    std::optional<int64_t> convergence_us;
+
+    // trigger_dst/trigger_dst_len为触发路由的前缀，非路由触发时传空
+    static FibTraceSummary summarize(const std::vector<FibTraceEvent>& events, int64_t trigger_ms,
+                                     const std::string& trigger_dst, const std::string& trigger_dst_len,
+                                     std::optional<int64_t> last_route_event_ms);
+    void add_to_json(JsonObject& obj) const;
+};
+
+// 用eBPF kprobe在fib_table_insert/fib_table_delete入口打点(--fib-trace)，得到路由写入FIB的内核时间，
+// 收敛时间不再受netlink消息投递到本进程的延迟影响。直接使用bpf()与perf_event_open，不依赖libbpf；
+// 需要root(CAP_BPF与CAP_PERFMON)、CONFIG_KPROBES与5.8以上内核(ringbuf)，只支持x86_64与aarch64
+class FibTracer {
+public:
+    using Callback = std::function<void(const FibTraceEvent&)>;
+
+    static constexpr uint32_t RINGBUF_SIZE = 256 * 1024;
+    // 一个会话最多保留的FIB变化数，全表重算时超出部分不参与计算
+    static constexpr size_t MAX_SESSION_EVENTS = 100000;
+
+private:
+    Callback callback_;
+    BpfRingbuf ringbuf_;
+    std::vector<int> prog_fds_;
+    std::vector<int> perf_fds_;
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+
+    bool attach(const char* function, bool insert, int kprobe_type, std::string& error);
+    void worker_loop();
+    void release();
+
+public:
+    explicit FibTracer(Callback callback);
+    ~FibTracer();
+
+    FibTracer(const FibTracer&) = delete;
+    FibTracer& operator=(const FibTracer&) = delete;
+
+    // 加载程序并挂载到两个函数，任一步失败时释放已创建的资源并返回false
+    bool start(std::string& error);
+    void stop();
+};
@@ -0,0 +1,159 @@
+#include "frr_log.h"
+#include <chrono>
+#include <ctime>
+#include <fstream>
+#include <regex>
+#include <sys/stat.h>
+
+namespace {
+
+int64_t now_ms() {
+    return std::chrono::duration_cast<std::chrono::milliseconds>(
+        std::chrono::system_clock::now().time_since_epoch()).count();
+}
+
+// 不同版本/守护进程的措辞差异较大，按关键词宽松匹配
+const std::regex& spf_end_pattern() {
+    static const std::regex re("SPF.*\\b(complete|finish|done|processing time|end\\b|ended)", std::regex::icase);
+    return re;
+}
+
+const std::regex& spf_start_pattern() {
+    static const std::regex re("SPF.*\\b(schedul|start|begin|need|calculat|run)", std::regex::icase);
+    return re;
+}
+
+const std::regex& bestpath_pattern() {
+    static const std::regex re("best[ _-]?path|best_selection|best route", std::regex::icase);
+    return re;
+}
+
+} // namespace
+
+FrrLogTailer::FrrLogTailer(const std::string& path, Callback callback)
+    : path_(path), callback_(std::move(callback)) {
+}
+
+FrrLogTailer::~FrrLogTailer() {
+    stop();
+}
+
+void FrrLogTailer::start() {
+    if (running_.load()) {
+        return;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&FrrLogTailer::worker_loop, this);
+}
+
+void FrrLogTailer::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+}
+
+void FrrLogTailer::worker_loop() {
+    std::ifstream file;
+    std::streamoff offset = 0;
+    ino_t inode = 0;
+    bool first_open = true;
+    std::string partial;
+
+    while (running_.load()) {
+        struct stat st;
+        bool exists = stat(path_.c_str(), &st) == 0;
+
+        // 文件被轮转(inode变化)或截断时从头重新读取
+        if (exists && (!file.is_open() || st.st_ino != inode || st.st_size < offset)) {
+            file.close();
+            file.clear();
+            file.open(path_);
+            if (file.is_open()) {
+                inode = st.st_ino;
+                offset = first_open ? static_cast<std::streamoff>(st.st_size) : 0;
+                first_open = false;
+                partial.clear();
+            }
+        }
+
+        if (file.is_open() && exists && st.st_size > offset) {
+            file.clear();
+            file.seekg(offset);
+            std::string chunk(static_cast<size_t>(st.st_size - offset), '\0');
+            file.read(&chunk[0], static_cast<std::streamsize>(chunk.size()));
+            chunk.resize(static_cast<size_t>(file.gcount()));
+            offset += static_cast<std::streamoff>(chunk.size());
+
+            partial += chunk;
+            size_t start = 0;
+            size_t newline;
+            while ((newline = partial.find('\n', start)) != std::string::npos) {
+                FrrLogEvent event;
+                if (parse_line(partial.substr(start, newline - start), event)) {
+                    callback_(event);
+                }
+                start = newline + 1;
+            }
+            partial.erase(0, start);
+        }
+
+        std::this_thread::sleep_for(std::chrono::milliseconds(100));
+    }
+}
+
+int64_t FrrLogTailer::parse_timestamp_ms(const std::string& text) {
+    struct tm tm_value = {};
+    int millis = 0;
+    int matched = sscanf(text.c_str(), "%d/%d/%d %d:%d:%d.%3d",
+                         &tm_value.tm_year, &tm_value.tm_mon, &tm_value.tm_mday,
+                         &tm_value.tm_hour, &tm_value.tm_min, &tm_value.tm_sec, &millis);
+    if (matched < 6) {
+        return -1;
+    }
+    tm_value.tm_year -= 1900;
+    tm_value.tm_mon -= 1;
+    tm_value.tm_isdst = -1;
+    time_t seconds = mktime(&tm_value);
+    if (seconds < 0) {
+        return -1;
+    }
+    return static_cast<int64_t>(seconds) * 1000 + (matched == 7 ? millis : 0);
+}
+
+bool FrrLogTailer::parse_line(const std::string& line, FrrLogEvent& event) {
+    if (std::regex_search(line, spf_end_pattern())) {
+        event.category = "spf_end";
+    } else if (std::regex_search(line, spf_start_pattern())) {
+        event.category = "spf_start";
+    } else if (std::regex_search(line, bestpath_pattern())) {
+        event.category = "bestpath";
+    } else {
+        return false;
+    }
+
+    // FRR文件日志格式: "2024/08/04 10:30:15.123 OSPF: [XXXXX-XXXXX] message"
+    event.timestamp_ms = parse_timestamp_ms(line);
+    std::string rest = line;
+    if (event.timestamp_ms >= 0) {
+        size_t after_date = line.find(' ');
+        size_t after_time = after_date == std::string::npos ? std::string::npos : line.find(' ', after_date + 1);
+        rest = after_time == std::string::npos ? "" : line.substr(after_time + 1);
+    } else {
+        event.timestamp_ms = now_ms();
+    }
+
+    size_t colon = rest.find(": ");
+    if (colon != std::string::npos && colon < 16 && rest.find(' ') >= colon) {
+        event.daemon = rest.substr(0, colon);
+        event.message = rest.substr(colon + 2);
+    } else {
+        event.message = rest;
+    }
+    return true;
+}
@@ -0,0 +1,45 @@
+#pragma once
+
+#include <atomic>
+#include <functional>
+#include <string>
+#include <thread>
+
+// 从FRR日志中识别出的一行控制面事件
+struct FrrLogEvent {
+    int64_t timestamp_ms = 0;  // 日志行自带的时间戳，无法解析时为读取时间
+    std::string category;      // spf_start | spf_end | bestpath
+    std::string daemon;        // 如 OSPF、BGP、ISIS
+    std::string message;
+};
+
+// 跟踪(tail -F)FRR日志文件，把SPF/最优路径相关行交给回调
+class FrrLogTailer {
+public:
+    using Callback = std::function<void(const FrrLogEvent&)>;
+
+private:
+    std::string path_;
+    Callback callback_;
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+
+    void worker_loop();
+
+public:
+    FrrLogTailer(const std::string& path, Callback callback);
+    ~FrrLogTailer();
+
+    FrrLogTailer(const FrrLogTailer&) = delete;
+    FrrLogTailer& operator=(const FrrLogTailer&) = delete;
+
+    // 从文件当前末尾开始跟踪
+    void start();
+    void stop();
+
+    // 解析一行日志，不相关的行返回false
+    static bool parse_line(const std::string& line, FrrLogEvent& event);
+
+    // 解析FRR日志时间戳 "2024/08/04 10:30:15.123"(本地时间)，失败返回-1
+    static int64_t parse_timestamp_ms(const std::string& text);
+};
@@ -0,0 +1,191 @@
+#include "frr_state.h"
+#include "log_reader.h"
+#include "subprocess.h"
+#include <chrono>
+#include <iostream>
+
+namespace {
+
+int64_t now_ms() {
+    return std::chrono::duration_cast<std::chrono::milliseconds>(
+        std::chrono::system_clock::now().time_since_epoch()).count();
+}
+
+} // namespace
+
+JsonObject FrrSnapshot::to_json() const {
+    JsonObject obj;
+    obj["collect_duration_ms"] = collect_duration_ms;
+
+    if (route_ok) {
+        obj["rib_total"] = rib_total;
+        obj["fib_total"] = fib_total;
+        JsonObject protocols;
+        for (const auto& pair : rib_by_protocol) {
+            protocols[pair.first] = pair.second;
+        }
+        obj["rib_by_protocol"] = Logger::json_to_string(protocols);
+    }
+
+    if (bgp_ok) {
+        obj["bgp_peers"] = static_cast<int64_t>(bgp_peers);
+        obj["bgp_established"] = static_cast<int64_t>(bgp_established);
+        JsonObject states;
+        for (const auto& pair : bgp_peer_states) {
+            states[pair.first] = pair.second;
+        }
+        obj["bgp_peer_states"] = Logger::json_to_string(states);
+    }
+
+    if (!error.empty()) {
+        obj["error"] = error;
+    }
+    return obj;
+}
+
+FrrStatePoller::FrrStatePoller(const std::string& vtysh_path, Callback callback)
+    : vtysh_path_(vtysh_path), callback_(std::move(callback)) {
+}
+
+FrrStatePoller::~FrrStatePoller() {
+    stop();
+}
+
+void FrrStatePoller::start() {
+    if (running_.load()) {
+        return;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&FrrStatePoller::worker_loop, this);
+}
+
+void FrrStatePoller::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    queue_cv_.notify_all();
+
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+}
+
+void FrrStatePoller::request(int session_id, const std::string& phase) {
+    std::unique_lock<std::mutex> lock(queue_mutex_);
+
+    // vtysh卡死时避免无限堆积
+    if (pending_.size() >= MAX_PENDING_REQUESTS) {
+        pending_.pop();
+    }
+
+    pending_.push({session_id, phase});
+    lock.unlock();
+
+    queue_cv_.notify_one();
+}
+
+void FrrStatePoller::worker_loop() {
+    std::unique_lock<std::mutex> lock(queue_mutex_);
+
+    while (running_.load() || !pending_.empty()) {
+        queue_cv_.wait(lock, [this] {
+            return !pending_.empty() || !running_.load();
+        });
+
+        while (!pending_.empty()) {
+            Request req = std::move(pending_.front());
+            pending_.pop();
+            lock.unlock();
+
+            FrrSnapshot snapshot = collect(vtysh_path_);
+            if (!snapshot.error.empty()) {
+                std::cerr << "⚠️  FRR状态采集失败: " << snapshot.error << "\n";
+            }
+            callback_(req.session_id, req.phase, snapshot);
+
+            lock.lock();
+        }
+    }
+}
+
+FrrSnapshot FrrStatePoller::collect(const std::string& vtysh_path) {
+    FrrSnapshot snapshot;
+    snapshot.timestamp_ms = now_ms();
+
+    std::string output, error;
+    int code = run_command({vtysh_path, "-c", "show ip route summary json"}, output, error);
+    if (code == 0 && parse_route_summary(output, snapshot)) {
+        snapshot.route_ok = true;
+    } else {
+        snapshot.error = "show ip route summary json: " + (error.empty() ? output.substr(0, 200) : error);
+    }
+
+    error.clear();
+    code = run_command({vtysh_path, "-c", "show bgp summary json"}, output, error);
+    if (code == 0 && parse_bgp_summary(output, snapshot)) {
+        snapshot.bgp_ok = true;
+    } else if (snapshot.error.empty()) {
+        // 未启用BGP时vtysh同样会失败，只在路由采集成功时记录
+        snapshot.error = "show bgp summary json: " + (error.empty() ? output.substr(0, 200) : error);
+    }
+
+    snapshot.collect_duration_ms = now_ms() - snapshot.timestamp_ms;
+    return snapshot;
+}
+
+bool FrrStatePoller::parse_route_summary(const std::string& text, FrrSnapshot& snapshot) {
+    // {"routes":[{"fib":2,"rib":2,"type":"connected"},...],"routesTotal":5,"routesTotalFib":5}
+    JsonObject root;
+    if (!LogReader::parse_object(text, root) || !LogReader::has(root, "routesTotal")) {
+        return false;
+    }
+    snapshot.rib_total = LogReader::get_int(root, "routesTotal");
+    snapshot.fib_total = LogReader::get_int(root, "routesTotalFib");
+
+    std::vector<JsonValue> routes;
+    if (LogReader::parse_array(LogReader::get_string(root, "routes"), routes)) {
+        for (const auto& value : routes) {
+            JsonObject entry;
+            if (LogReader::parse_object(value.as_string(), entry)) {
+                snapshot.rib_by_protocol[LogReader::get_string(entry, "type", "unknown")] +=
+                    LogReader::get_int(entry, "rib");
+            }
+        }
+    }
+    return true;
+}
+
+bool FrrStatePoller::parse_bgp_summary(const std::string& text, FrrSnapshot& snapshot) {
+    // {"ipv4Unicast":{"peers":{"10.0.0.2":{"state":"Established",...}},...},"ipv6Unicast":{...}}
+    JsonObject root;
+    if (!LogReader::parse_object(text, root)) {
+        return false;
+    }
+
+    for (const auto& afi : root) {
+        JsonObject afi_obj;
+        if (!LogReader::parse_object(afi.second.as_string(), afi_obj)) {
+            continue;
+        }
+        JsonObject peers;
+        if (!LogReader::parse_object(LogReader::get_string(afi_obj, "peers"), peers)) {
+            continue;
+        }
+        for (const auto& peer : peers) {
+            JsonObject peer_obj;
+            if (!LogReader::parse_object(peer.second.as_string(), peer_obj)) {
+                continue;
+            }
+            std::string state = LogReader::get_string(peer_obj, "state", "unknown");
+            snapshot.bgp_peer_states[afi.first + "/" + peer.first] = state;
+            snapshot.bgp_peers++;
+            if (state == "Established") {
+                snapshot.bgp_established++;
+            }
+        }
+    }
+    return true;
+}
@@ -0,0 +1,79 @@
+#pragma once
+
+#include "logger.h"
+#include <atomic>
+#include <condition_variable>
+#include <functional>
+#include <map>
+#include <mutex>
+#include <queue>
+#include <string>
+#include <thread>
+
+// 某一时刻的FRR控制面状态快照
+struct FrrSnapshot {
+    int64_t timestamp_ms = 0;
+    int64_t collect_duration_ms = 0;
+
+    // show ip route summary json
+    bool route_ok = false;
+    int64_t rib_total = 0;
+    int64_t fib_total = 0;
+    std::map<std::string, int64_t> rib_by_protocol;
+
+    // show bgp summary json
+    bool bgp_ok = false;
+    int bgp_peers = 0;
+    int bgp_established = 0;
+    std::map<std::string, std::string> bgp_peer_states;  // "afi/peer" -> state
+
+    std::string error;
+
+    // 转为日志字段(嵌套部分序列化为JSON字符串)
+    JsonObject to_json() const;
+};
+
+// FRR状态采集器：独立线程调用vtysh，避免阻塞netlink事件处理与收敛检查
+class FrrStatePoller {
+public:
+    using Callback = std::function<void(int session_id, const std::string& phase, const FrrSnapshot&)>;
+
+private:
+    std::string vtysh_path_;
+    Callback callback_;
+
+    struct Request {
+        int session_id;
+        std::string phase;
+    };
+    std::queue<Request> pending_;
+    std::mutex queue_mutex_;
+    std::condition_variable queue_cv_;
+
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+
+    static constexpr size_t MAX_PENDING_REQUESTS = 16;
+
+    void worker_loop();
+
+public:
+    FrrStatePoller(const std::string& vtysh_path, Callback callback);
+    ~FrrStatePoller();
+
+    FrrStatePoller(const FrrStatePoller&) = delete;
+    FrrStatePoller& operator=(const FrrStatePoller&) = delete;
+
+    void start();
+    // 停止前会处理完队列中的请求
+    void stop();
+
+    // 请求一次采集，phase如 "trigger"/"converged"
+    void request(int session_id, const std::string& phase);
+
+    // 同步采集一次
+    static FrrSnapshot collect(const std::string& vtysh_path);
+
+    static bool parse_route_summary(const std::string& text, FrrSnapshot& snapshot);
+    static bool parse_bgp_summary(const std::string& text, FrrSnapshot& snapshot);
+};
@@ -0,0 +1,231 @@
+#include "gnmi_subscriber.h"
+#include "log_reader.h"
+#include "subprocess.h"
+#include <cerrno>
+#include <chrono>
+#include <csignal>
+#include <iostream>
+#include <poll.h>
+#include <sys/wait.h>
+#include <unistd.h>
+
+const std::vector<std::string> GnmiSubscriber::DEFAULT_PATHS = {
+    "/network-instances/network-instance/afts",
+    "/interfaces/interface/state/oper-status",
+};
+
+std::string GnmiUpdate::prefix() const {
+    for (const auto& tag : tags) {
+        const std::string& key = tag.first;
+        if (key.size() >= 6 && key.compare(key.size() - 6, 6, "prefix") == 0) {
+            return tag.second;
+        }
+    }
+    return "";
+}
+
+GnmiSubscriber::GnmiSubscriber(const GnmiOptions& options, Callback callback)
+    : options_(options), callback_(std::move(callback)) {
+    if (options_.paths.empty()) {
+        options_.paths = DEFAULT_PATHS;
+    }
+}
+
+GnmiSubscriber::~GnmiSubscriber() {
+    stop();
+}
+
+void GnmiSubscriber::start() {
+    if (running_.load()) {
+        return;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&GnmiSubscriber::worker_loop, this);
+}
+
+void GnmiSubscriber::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+}
+
+std::vector<std::string> GnmiSubscriber::build_command() const {
+    std::vector<std::string> args = {options_.gnmic_path, "-a", options_.target};
+    if (!options_.username.empty()) {
+        args.insert(args.end(), {"-u", options_.username});
+    }
+    if (!options_.password.empty()) {
+        args.insert(args.end(), {"-p", options_.password});
+    }
+    args.push_back(options_.insecure ? "--insecure" : "--skip-verify");
+    args.insert(args.end(), {"--encoding", options_.encoding, "--format", "event",
+                             "subscribe", "--mode", "stream", "--stream-mode", "on-change"});
+    for (const auto& path : options_.paths) {
+        args.insert(args.end(), {"--path", path});
+    }
+    return args;
+}
+
+void GnmiSubscriber::worker_loop() {
+    while (running_.load()) {
+        int fd = -1;
+        std::string error;
+        pid_t pid = spawn_command(build_command(), fd, error);
+        if (pid < 0) {
+            std::cerr << "⚠️  gNMI订阅启动失败: " << error << "\n";
+            return;
+        }
+
+        std::string buffer;
+        bool eof = false;
+        while (running_.load() && !eof) {
+            struct pollfd pfd = {fd, POLLIN, 0};
+            if (poll(&pfd, 1, 100) <= 0) {
+                continue;
+            }
+            char chunk[4096];
+            ssize_t len = read(fd, chunk, sizeof(chunk));
+            if (len > 0) {
+                buffer.append(chunk, static_cast<size_t>(len));
+                buffer.erase(0, consume(buffer));
+            } else if (len == 0 || (errno != EAGAIN && errno != EINTR)) {
+                eof = true;
+            }
+        }
+
+        if (!eof) {
+            kill(pid, SIGTERM);
+        }
+        close(fd);
+        int status = 0;
+        waitpid(pid, &status, 0);
+
+        if (!running_.load()) {
+            break;
+        }
+        if (WIFEXITED(status) && WEXITSTATUS(status) == 127) {
+            std::cerr << "⚠️  gNMI订阅启动失败: " << options_.gnmic_path << ": command not found\n";
+            return;
+        }
+
+        // 连接中断，稍后重连
+        std::cerr << "⚠️  gnmic已退出，2秒后重新订阅 " << options_.target << "\n";
+        for (int i = 0; i < 20 && running_.load(); ++i) {
+            std::this_thread::sleep_for(std::chrono::milliseconds(100));
+        }
+    }
+}
+
+size_t GnmiSubscriber::consume(const std::string& buffer) {
+    size_t consumed = 0;
+    size_t pos = 0;
+
+    while (pos < buffer.size()) {
+        // 跳到下一个顶层JSON值，期间的非JSON文本(gnmic的错误输出)按行打印
+        size_t start = buffer.find_first_of("[{", pos);
+        size_t newline = buffer.find('\n', pos);
+        if (newline != std::string::npos && (start == std::string::npos || newline < start)) {
+            std::string line = buffer.substr(pos, newline - pos);
+            if (line.find_first_not_of(" \t\r") != std::string::npos) {
+                std::cerr << "   gnmic: " << line << "\n";
+            }
+            pos = newline + 1;
+            consumed = pos;
+            continue;
+        }
+        if (start == std::string::npos) {
+            break;
+        }
+
+        int depth = 0;
+        bool in_string = false;
+        bool escaped = false;
+        size_t end = std::string::npos;
+        for (size_t i = start; i < buffer.size(); ++i) {
+            char c = buffer[i];
+            if (in_string) {
+                if (escaped) escaped = false;
+                else if (c == '\\') escaped = true;
+                else if (c == '"') in_string = false;
+            } else if (c == '"') {
+                in_string = true;
+            } else if (c == '[' || c == '{') {
+                depth++;
+            } else if (c == ']' || c == '}') {
+                if (--depth == 0) {
+                    end = i;
+                    break;
+                }
+            }
+        }
+        if (end == std::string::npos) {
+            break;  // 不完整，等待更多数据
+        }
+
+        std::vector<GnmiUpdate> updates;
+        if (parse_events(buffer.substr(start, end - start + 1), updates)) {
+            for (const auto& update : updates) {
+                callback_(update);
+            }
+        }
+        pos = end + 1;
+        consumed = pos;
+    }
+
+    return consumed;
+}
+
+bool GnmiSubscriber::parse_events(const std::string& text, std::vector<GnmiUpdate>& updates) {
+    std::vector<std::string> objects;
+    if (!text.empty() && text[0] == '[') {
+        std::vector<JsonValue> items;
+        if (!LogReader::parse_array(text, items)) {
+            return false;
+        }
+        for (const auto& item : items) {
+            objects.push_back(item.as_string());
+        }
+    } else {
+        objects.push_back(text);
+    }
+
+    for (const auto& object_text : objects) {
+        JsonObject event;
+        if (!LogReader::parse_object(object_text, event)) {
+            continue;
+        }
+
+        GnmiUpdate update;
+        update.timestamp_ms = LogReader::get_int(event, "timestamp") / 1000000;  // ns -> ms
+        for (const auto& tag : LogReader::parse_string_map(LogReader::get_string(event, "tags"))) {
+            update.tags[tag.first] = tag.second;
+        }
+        auto source_it = update.tags.find("source");
+        if (source_it != update.tags.end()) {
+            update.source = source_it->second;
+            update.tags.erase(source_it);
+        }
+        update.tags.erase("subscription-name");
+
+        for (const auto& value : LogReader::parse_string_map(LogReader::get_string(event, "values"))) {
+            update.values[value.first] = value.second;
+        }
+        std::vector<JsonValue> deletes;
+        if (LogReader::parse_array(LogReader::get_string(event, "deletes"), deletes)) {
+            for (const auto& path : deletes) {
+                update.deletes.push_back(path.as_string());
+            }
+        }
+
+        if (!update.values.empty() || !update.deletes.empty()) {
+            updates.push_back(std::move(update));
+        }
+    }
+    return true;
+}
@@ -0,0 +1,67 @@
+#pragma once
+
+#include <atomic>
+#include <functional>
+#include <map>
+#include <string>
+#include <sys/types.h>
+#include <thread>
+#include <vector>
+
+// gNMI订阅参数(--gnmi*)
+struct GnmiOptions {
+    std::string target;                 // 地址:端口，如 clab-lab-srl1:57400，为空表示不启用
+    std::vector<std::string> paths;     // 订阅路径，为空时使用默认路径
+    std::string username;
+    std::string password;
+    bool insecure = false;              // 明文gRPC，否则使用TLS并跳过证书校验
+    std::string encoding = "json_ietf";
+    std::string gnmic_path = "gnmic";
+};
+
+// 一条gNMI通知中同一组标签下的更新
+struct GnmiUpdate {
+    int64_t timestamp_ms = 0;                    // 设备时间戳
+    std::string source;                          // 产生通知的设备
+    std::map<std::string, std::string> tags;     // 路径中的列表键，如 interface_name、ipv4-entry_prefix
+    std::map<std::string, std::string> values;   // 路径 -> 值
+    std::vector<std::string> deletes;            // 被删除的路径
+
+    // 路由表项的前缀(标签名以"prefix"结尾)，非路由更新返回空
+    std::string prefix() const;
+};
+
+// 通过gnmic以on-change模式订阅，解析其事件格式输出
+// gnmic退出(设备重启、连接断开)后自动重连
+class GnmiSubscriber {
+public:
+    using Callback = std::function<void(const GnmiUpdate&)>;
+
+    // 未指定--gnmi-path时订阅的路径：OpenConfig AFT与接口运行状态
+    static const std::vector<std::string> DEFAULT_PATHS;
+
+private:
+    GnmiOptions options_;
+    Callback callback_;
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+
+    void worker_loop();
+    // 从缓冲区中取出完整的顶层JSON值并分发，返回消费的字节数
+    size_t consume(const std::string& buffer);
+
+public:
+    GnmiSubscriber(const GnmiOptions& options, Callback callback);
+    ~GnmiSubscriber();
+
+    GnmiSubscriber(const GnmiSubscriber&) = delete;
+    GnmiSubscriber& operator=(const GnmiSubscriber&) = delete;
+
+    void start();
+    void stop();
+
+    std::vector<std::string> build_command() const;
+
+    // 解析gnmic --format event 输出的一个通知(JSON数组或对象)
+    static bool parse_events(const std::string& text, std::vector<GnmiUpdate>& updates);
+};
@@ -0,0 +1,414 @@
+#include "grafana_api.h"
+#include "bmp_collector.h"
+#include "listen_socket.h"
+#include "log_reader.h"
+#include <algorithm>
+#include <cerrno>
+#include <poll.h>
+#include <sstream>
+#include <sys/socket.h>
+#include <unistd.h>
+
+namespace {
+
+const std::string SCHEME = "grafana://";
+
+// JSON数据源/search返回的指标名，与/query的target对应
+const std::vector<std::string> METRICS = {"convergence_time_ms", "route_events", "session_duration_ms"};
+
+std::string url_decode(const std::string& text) {
+    std::string decoded;
+    for (size_t i = 0; i < text.size(); ++i) {
+        if (text[i] == '%' && i + 2 < text.size() && isxdigit(static_cast<unsigned char>(text[i + 1])) &&
+            isxdigit(static_cast<unsigned char>(text[i + 2]))) {
+            decoded += static_cast<char>(std::stoi(text.substr(i + 1, 2), nullptr, 16));
+            i += 2;
+        } else if (text[i] == '+') {
+            decoded += ' ';
+        } else {
+            decoded += text[i];
+        }
+    }
+    return decoded;
+}
+
+std::map<std::string, std::string> parse_query(const std::string& query) {
+    std::map<std::string, std::string> params;
+    std::istringstream stream(query);
+    std::string pair;
+    while (std::getline(stream, pair, '&')) {
+        size_t eq = pair.find('=');
+        if (eq == std::string::npos) {
+            params[url_decode(pair)] = "";
+        } else {
+            params[url_decode(pair.substr(0, eq))] = url_decode(pair.substr(eq + 1));
+        }
+    }
+    return params;
+}
+
+// from/to 接受纪元毫秒(Infinity的${__from}/${__to})或RFC3339时间
+int64_t parse_time_param(const std::map<std::string, std::string>& params, const std::string& key) {
+    auto it = params.find(key);
+    if (it == params.end() || it->second.empty()) {
+        return -1;
+    }
+    return LogReader::parse_timestamp_ms(it->second);
+}
+
+std::string quote(const std::string& text) {
+    return "\"" + Logger::escape_json_string(text) + "\"";
+}
+
+std::string metric_value(const GrafanaSession& session, const std::string& metric) {
+    if (metric == "route_events") {
+        return std::to_string(session.route_events);
+    }
+    if (metric == "session_duration_ms") {
+        return std::to_string(session.duration_ms);
+    }
+    return session.convergence_ms ? std::to_string(session.convergence_ms.value()) : "null";
+}
+
+std::string session_to_json(const GrafanaSession& session) {
+    std::ostringstream out;
+    out << "{\"time\":" << session.time_ms
+        << ",\"router\":" << quote(session.router)
+        << ",\"session_id\":" << session.session_id
+        << ",\"trigger_source\":" << quote(session.trigger_source)
+        << ",\"interface\":" << quote(session.interface)
+        << ",\"convergence_time_ms\":" << metric_value(session, "convergence_time_ms")
+        << ",\"route_events\":" << session.route_events
+        << ",\"session_duration_ms\":" << session.duration_ms
+        << ",\"timed_out\":" << (session.timed_out ? "true" : "false") << "}";
+    return out.str();
+}
+
+std::string sessions_table(const std::vector<GrafanaSession>& sessions) {
+    std::ostringstream out;
+    out << "{\"type\":\"table\",\"columns\":["
+        << "{\"text\":\"time\",\"type\":\"time\"},{\"text\":\"router\",\"type\":\"string\"},"
+        << "{\"text\":\"session_id\",\"type\":\"number\"},{\"text\":\"trigger_source\",\"type\":\"string\"},"
+        << "{\"text\":\"interface\",\"type\":\"string\"},{\"text\":\"convergence_time_ms\",\"type\":\"number\"},"
+        << "{\"text\":\"route_events\",\"type\":\"number\"},{\"text\":\"session_duration_ms\",\"type\":\"number\"},"
+        << "{\"text\":\"timed_out\",\"type\":\"string\"}],\"rows\":[";
+    for (size_t i = 0; i < sessions.size(); ++i) {
+        const auto& s = sessions[i];
+        out << (i ? "," : "") << "[" << s.time_ms << "," << quote(s.router) << "," << s.session_id << ","
+            << quote(s.trigger_source) << "," << quote(s.interface) << ","
+            << metric_value(s, "convergence_time_ms") << "," << s.route_events << "," << s.duration_ms << ","
+            << quote(s.timed_out ? "true" : "false") << "]";
+    }
+    out << "]}";
+    return out.str();
+}
+
+// 每个路由器一条序列
+std::string metric_series(const std::vector<GrafanaSession>& sessions, const std::string& metric) {
+    std::map<std::string, std::vector<const GrafanaSession*>> by_router;
+    for (const auto& session : sessions) {
+        if (metric == "convergence_time_ms" && !session.convergence_ms) {
+            continue;
+        }
+        by_router[session.router].push_back(&session);
+    }
+    std::ostringstream out;
+    bool first = true;
+    for (const auto& entry : by_router) {
+        out << (first ? "" : ",") << "{\"target\":" << quote(metric + " " + entry.first) << ",\"datapoints\":[";
+        for (size_t i = 0; i < entry.second.size(); ++i) {
+            out << (i ? "," : "") << "[" << metric_value(*entry.second[i], metric) << ","
+                << entry.second[i]->time_ms << "]";
+        }
+        out << "]}";
+        first = false;
+    }
+    return out.str();
+}
+
+} // namespace
+
+bool GrafanaApi::parse_url(const std::string& url, std::string& address, int& port) {
+    if (url.compare(0, SCHEME.size(), SCHEME) != 0) {
+        return false;
+    }
+    return BmpCollector::parse_listen_spec(url.substr(SCHEME.size()), address, port);
+}
+
+GrafanaApi::GrafanaApi(const std::string& listen_address, int port)
+    : listen_address_(listen_address), port_(port) {
+}
+
+GrafanaApi::~GrafanaApi() {
+    stop();
+}
+
+bool GrafanaApi::start(std::string& error) {
+    if (running_.load()) {
+        return true;
+    }
+
+    listen_fd_ = open_listen_socket(listen_address_, port_, SOCK_STREAM, error);
+    if (listen_fd_ < 0) {
+        return false;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&GrafanaApi::worker_loop, this);
+    return true;
+}
+
+void GrafanaApi::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+    if (listen_fd_ >= 0) {
+        close(listen_fd_);
+        listen_fd_ = -1;
+    }
+}
+
+void GrafanaApi::worker_loop() {
+    while (running_.load()) {
+        struct pollfd pfd = {listen_fd_, POLLIN, 0};
+        if (poll(&pfd, 1, 200) <= 0 || !(pfd.revents & POLLIN)) {
+            continue;
+        }
+        int fd = accept4(listen_fd_, nullptr, nullptr, SOCK_CLOEXEC);
+        if (fd >= 0) {
+            serve(fd);
+            close(fd);
+        }
+    }
+}
+
+void GrafanaApi::serve(int fd) {
+    struct timeval timeout = {1, 0};
+    setsockopt(fd, SOL_SOCKET, SO_RCVTIMEO, &timeout, sizeof(timeout));
+    setsockopt(fd, SOL_SOCKET, SO_SNDTIMEO, &timeout, sizeof(timeout));
+
+    // 读取头部，POST请求再按Content-Length读取请求体
+    std::string request;
+    char buffer[4096];
+    size_t header_end;
+    while ((header_end = request.find("\r\n\r\n")) == std::string::npos && request.size() < 16384) {
+        ssize_t len = read(fd, buffer, sizeof(buffer));
+        if (len <= 0) {
+            return;
+        }
+        request.append(buffer, static_cast<size_t>(len));
+    }
+    if (header_end == std::string::npos) {
+        return;
+    }
+
+    size_t content_length = 0;
+    std::string headers = request.substr(0, header_end);
+    std::string lower = headers;
+    std::transform(lower.begin(), lower.end(), lower.begin(), ::tolower);
+    size_t length_pos = lower.find("\r\ncontent-length:");
+    if (length_pos != std::string::npos) {
+        content_length = std::strtoul(headers.c_str() + length_pos + 17, nullptr, 10);
+    }
+    std::string body = request.substr(header_end + 4);
+    while (body.size() < content_length && body.size() < 1024 * 1024) {
+        ssize_t len = read(fd, buffer, sizeof(buffer));
+        if (len <= 0) {
+            break;
+        }
+        body.append(buffer, static_cast<size_t>(len));
+    }
+
+    std::istringstream request_line(headers.substr(0, headers.find("\r\n")));
+    std::string method, target;
+    request_line >> method >> target;
+
+    std::string response_body;
+    std::string status = handle(method, target, body, response_body);
+    request_count_.fetch_add(1);
+
+    // Grafana在浏览器模式下直接访问时需要CORS
+    std::string response = "HTTP/1.1 " + status + "\r\n"
+        "Content-Type: application/json\r\n"
+        "Access-Control-Allow-Origin: *\r\n"
+        "Access-Control-Allow-Headers: accept, content-type\r\n"
+        "Access-Control-Allow-Methods: GET, POST, OPTIONS\r\n"
+        "Content-Length: " + std::to_string(response_body.size()) + "\r\n"
+        "Connection: close\r\n\r\n" + response_body;
+    size_t sent = 0;
+    while (sent < response.size()) {
+        ssize_t len = send(fd, response.data() + sent, response.size() - sent, MSG_NOSIGNAL);
+        if (len <= 0) {
+            break;
+        }
+        sent += static_cast<size_t>(len);
+    }
+}
+
+std::string GrafanaApi::handle(const std::string& method, const std::string& target,
+                               const std::string& body, std::string& response) const {
+    size_t question = target.find('?');
+    std::string path = target.substr(0, question);
+    auto params = parse_query(question == std::string::npos ? "" : target.substr(question + 1));
+
+    if (method == "OPTIONS") {
+        response = "";
+        return "204 No Content";
+    }
+
+    if (method == "GET" && path == "/") {
+        response = "{\"status\":\"ok\"}";
+        return "200 OK";
+    }
+
+    if (method == "GET" && (path == "/sessions" || path == "/timeseries")) {
+        auto sessions = select(params["router"], parse_time_param(params, "from"), parse_time_param(params, "to"));
+        if (params.count("limit")) {
+            size_t limit = std::strtoul(params["limit"].c_str(), nullptr, 10);
+            if (sessions.size() > limit) {
+                sessions.erase(sessions.begin(), sessions.end() - static_cast<std::ptrdiff_t>(limit));
+            }
+        }
+        std::ostringstream out;
+        out << "[";
+        bool first = true;
+        for (const auto& session : sessions) {
+            if (path == "/timeseries") {
+                if (!session.convergence_ms) {
+                    continue;
+                }
+                out << (first ? "" : ",") << "{\"time\":" << session.time_ms << ",\"router\":"
+                    << quote(session.router) << ",\"convergence_time_ms\":" << session.convergence_ms.value() << "}";
+            } else {
+                out << (first ? "" : ",") << session_to_json(session);
+            }
+            first = false;
+        }
+        out << "]";
+        response = out.str();
+        return "200 OK";
+    }
+
+    if (method == "POST" && path == "/search") {
+        std::ostringstream out;
+        out << "[";
+        for (size_t i = 0; i < METRICS.size(); ++i) {
+            out << (i ? "," : "") << quote(METRICS[i]);
+        }
+        out << ",\"sessions\"]";
+        response = out.str();
+        return "200 OK";
+    }
+
+    if (method == "POST" && path == "/query") {
+        JsonObject query;
+        if (!LogReader::parse_object(body, query)) {
+            response = "{\"error\":\"invalid JSON body\"}";
+            return "400 Bad Request";
+        }
+        // 嵌套对象以原始JSON文本保存
+        JsonObject range;
+        LogReader::parse_object(LogReader::get_string(query, "range"), range);
+        int64_t from_ms = LogReader::parse_timestamp_ms(LogReader::get_string(range, "from"));
+        int64_t to_ms = LogReader::parse_timestamp_ms(LogReader::get_string(range, "to"));
+        auto sessions = select("", from_ms, to_ms);
+
+        std::vector<JsonValue> targets;
+        LogReader::parse_array(LogReader::get_string(query, "targets"), targets);
+        std::ostringstream out;
+        out << "[";
+        bool first = true;
+        for (const auto& value : targets) {
+            JsonObject item;
+            if (!LogReader::parse_object(value.as_string(), item)) {
+                continue;
+            }
+            std::string metric = LogReader::get_string(item, "target");
+            std::string part;
+            if (metric == "sessions" || LogReader::get_string(item, "type") == "table") {
+                part = sessions_table(sessions);
+            } else if (std::find(METRICS.begin(), METRICS.end(), metric) != METRICS.end()) {
+                part = metric_series(sessions, metric);
+            }
+            if (!part.empty()) {
+                out << (first ? "" : ",") << part;
+                first = false;
+            }
+        }
+        out << "]";
+        response = out.str();
+        return "200 OK";
+    }
+
+    response = "{\"error\":\"not found\"}";
+    return "404 Not Found";
+}
+
+std::vector<GrafanaSession> GrafanaApi::select(const std::string& router, int64_t from_ms, int64_t to_ms) const {
+    std::vector<GrafanaSession> selected;
+    std::lock_guard<std::mutex> lock(sessions_mutex_);
+    for (const auto& session : sessions_) {
+        if (!router.empty() && session.router != router) {
+            continue;
+        }
+        if ((from_ms >= 0 && session.time_ms < from_ms) || (to_ms >= 0 && session.time_ms > to_ms)) {
+            continue;
+        }
+        selected.push_back(session);
+    }
+    std::sort(selected.begin(), selected.end(),
+              [](const GrafanaSession& a, const GrafanaSession& b) { return a.time_ms < b.time_ms; });
+    return selected;
+}
+
+void GrafanaApi::write_record(const JsonObject& record, const std::string&) {
+    std::string event_type = LogReader::get_string(record, "event_type");
+    if (event_type != "session_started" && event_type != "session_completed") {
+        return;
+    }
+    auto key = std::make_pair(LogReader::get_string(record, "router_name"),
+                              LogReader::get_int(record, "session_id"));
+
+    std::lock_guard<std::mutex> lock(sessions_mutex_);
+    if (event_type == "session_started") {
+        auto& pending = pending_[key];
+        pending.time_ms = LogReader::parse_timestamp_ms(LogReader::get_timestamp(record, "timestamp"));
+        pending.trigger_source = LogReader::get_string(record, "trigger_source");
+        auto info = LogReader::parse_string_map(LogReader::get_string(record, "trigger_info"));
+        pending.interface = info["interface"];
+        return;
+    }
+
+    GrafanaSession session;
+    session.router = key.first;
+    session.session_id = key.second;
+    auto it = pending_.find(key);
+    if (it != pending_.end()) {
+        session.time_ms = it->second.time_ms;
+        session.trigger_source = it->second.trigger_source;
+        session.interface = it->second.interface;
+        pending_.erase(it);
+    } else {
+        session.time_ms = LogReader::parse_timestamp_ms(LogReader::get_timestamp(record, "timestamp"));
+    }
+    if (LogReader::has(record, "convergence_time_ms")) {
+        session.convergence_ms = LogReader::get_int(record, "convergence_time_ms");
+    }
+    session.route_events = LogReader::get_int(record, "route_events_count");
+    session.duration_ms = LogReader::get_int(record, "session_duration_ms");
+    session.timed_out = LogReader::get_bool(record, "timed_out");
+
+    sessions_.push_back(std::move(session));
+    if (sessions_.size() > MAX_SESSIONS) {
+        sessions_.pop_front();
+    }
+}
+
+std::string GrafanaApi::summary() const {
+    return "Grafana: 端口 " + std::to_string(port_) + " 处理请求 " + std::to_string(request_count_.load()) + " 次";
+}
@@ -0,0 +1,77 @@
+#pragma once
+
+#include <atomic>
+#include <cstdint>
+#include <deque>
+#include <map>
+#include <mutex>
+#include <optional>
+#include <string>
+#include <thread>
+#include <vector>
+#include "record_sink.h"
+
+// 一个已完成的会话，作为表格的一行和时间序列的一个点
+struct GrafanaSession {
+    int64_t time_ms = 0;          // 触发时间(纪元毫秒)
+    std::string router;
+    int64_t session_id = 0;
+    std::string trigger_source;
+    std::string interface;
+    std::optional<int64_t> convergence_ms;
+    int64_t route_events = 0;
+    int64_t duration_ms = 0;
+    bool timed_out = false;
+};
+
+// 为Grafana提供JSON数据接口，无需中间数据库即可搭建实时实验看板: grafana://[ADDR:]PORT
+//   GET  /sessions    会话表格(Infinity数据源)，支持 ?router=&from=&to=&limit=
+//   GET  /timeseries  每个会话一个收敛时间点(Infinity数据源)，参数同上
+//   GET  /            连通性检查(JSON数据源)
+//   POST /search      可查询的指标名(JSON数据源)
+//   POST /query       按range/targets返回时间序列或表格(JSON数据源)
+class GrafanaApi : public RecordSink {
+private:
+    struct PendingSession {
+        int64_t time_ms = 0;
+        std::string trigger_source;
+        std::string interface;
+    };
+
+    std::string listen_address_;
+    int port_;
+    int listen_fd_ = -1;
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+    std::atomic<int64_t> request_count_{0};
+
+    mutable std::mutex sessions_mutex_;
+    std::map<std::pair<std::string, int64_t>, PendingSession> pending_;  // (router, session_id)
+    std::deque<GrafanaSession> sessions_;  // 按完成顺序，受sessions_mutex_保护
+
+    static constexpr size_t MAX_SESSIONS = 10000;
+
+    void worker_loop();
+    void serve(int fd);
+
+    // 按路由器与时间范围(毫秒，<0表示不限)筛选
+    std::vector<GrafanaSession> select(const std::string& router, int64_t from_ms, int64_t to_ms) const;
+
+public:
+    GrafanaApi(const std::string& listen_address, int port);
+    ~GrafanaApi() override;
+
+    GrafanaApi(const GrafanaApi&) = delete;
+    GrafanaApi& operator=(const GrafanaApi&) = delete;
+
+    bool start(std::string& error) override;
+    void write_record(const JsonObject& record, const std::string& line) override;
+    void stop() override;
+    std::string summary() const override;
+
+    // 处理一个请求，返回状态行(如 "200 OK")并填充响应体
+    std::string handle(const std::string& method, const std::string& target,
+                       const std::string& body, std::string& response) const;
+
+    static bool parse_url(const std::string& url, std::string& address, int& port);
+};
@@ -0,0 +1,626 @@
+#include "grpc_server.h"
+#include "bmp_collector.h"
+#include "listen_socket.h"
+#include "log_reader.h"
+#include <algorithm>
+#include <cerrno>
+#include <chrono>
+#include <netinet/in.h>
+#include <netinet/tcp.h>
+#include <poll.h>
+#include <set>
+#include <sys/eventfd.h>
+#include <sys/socket.h>
+#include <unistd.h>
+
+namespace {
+
+const std::string SCHEME = "grpc://";
+const std::string CLIENT_PREFACE = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n";
+
+// HTTP/2帧类型与标志 (RFC 9113)
+constexpr uint8_t FRAME_DATA = 0x0;
+constexpr uint8_t FRAME_HEADERS = 0x1;
+constexpr uint8_t FRAME_RST_STREAM = 0x3;
+constexpr uint8_t FRAME_SETTINGS = 0x4;
+constexpr uint8_t FRAME_PING = 0x6;
+constexpr uint8_t FRAME_GOAWAY = 0x7;
+constexpr uint8_t FRAME_WINDOW_UPDATE = 0x8;
+
+constexpr uint8_t FLAG_END_STREAM = 0x1;
+constexpr uint8_t FLAG_ACK = 0x1;
+constexpr uint8_t FLAG_END_HEADERS = 0x4;
+constexpr uint8_t FLAG_PADDED = 0x8;
+
+constexpr uint16_t SETTINGS_INITIAL_WINDOW_SIZE = 0x4;
+constexpr uint16_t SETTINGS_MAX_FRAME_SIZE = 0x5;
+
+// 连接上积压超过该字节数时暂停从队列取记录，由队列上限丢弃最旧的记录
+constexpr size_t MAX_OUTBOUND_BYTES = 1 << 20;
+
+// HPACK: :status 200为静态表第8项；content-type(第31项)与grpc-status均以不索引的字面量发送
+const std::string RESPONSE_HEADERS = std::string("\x88\x0f\x10\x10", 4) + "application/grpc";
+const std::string OK_TRAILERS = std::string("\x00\x0b", 2) + "grpc-status" + "\x01" + "0";
+
+std::string frame(uint8_t type, uint8_t flags, uint32_t stream_id, const std::string& payload) {
+    std::string data;
+    data += static_cast<char>((payload.size() >> 16) & 0xff);
+    data += static_cast<char>((payload.size() >> 8) & 0xff);
+    data += static_cast<char>(payload.size() & 0xff);
+    data += static_cast<char>(type);
+    data += static_cast<char>(flags);
+    for (int shift = 24; shift >= 0; shift -= 8) {
+        data += static_cast<char>((stream_id >> shift) & 0xff);
+    }
+    return data + payload;
+}
+
+uint32_t read_u32(const std::string& data, size_t offset) {
+    return (static_cast<uint32_t>(static_cast<uint8_t>(data[offset])) << 24) |
+           (static_cast<uint32_t>(static_cast<uint8_t>(data[offset + 1])) << 16) |
+           (static_cast<uint32_t>(static_cast<uint8_t>(data[offset + 2])) << 8) |
+           static_cast<uint32_t>(static_cast<uint8_t>(data[offset + 3]));
+}
+
+std::string window_update(uint32_t stream_id, uint32_t increment) {
+    std::string payload;
+    for (int shift = 24; shift >= 0; shift -= 8) {
+        payload += static_cast<char>((increment >> shift) & 0xff);
+    }
+    return frame(FRAME_WINDOW_UPDATE, 0, stream_id, payload);
+}
+
+// protobuf编码 (proto3，默认值不写出)
+class ProtoWriter {
+private:
+    std::string data_;
+
+    void varint(uint64_t value) {
+        while (value >= 0x80) {
+            data_ += static_cast<char>((value & 0x7f) | 0x80);
+            value >>= 7;
+        }
+        data_ += static_cast<char>(value);
+    }
+
+    void tag(int field, int wire_type) {
+        varint(static_cast<uint64_t>(field) << 3 | static_cast<uint64_t>(wire_type));
+    }
+
+public:
+    void string_field(int field, const std::string& value) {
+        if (value.empty()) {
+            return;
+        }
+        bytes_field(field, value);
+    }
+
+    void bytes_field(int field, const std::string& value) {
+        tag(field, 2);
+        varint(value.size());
+        data_ += value;
+    }
+
+    void int_field(int field, int64_t value, bool always = false) {
+        if (value == 0 && !always) {
+            return;
+        }
+        tag(field, 0);
+        varint(static_cast<uint64_t>(value));
+    }
+
+    void bool_field(int field, bool value) {
+        if (value) {
+            tag(field, 0);
+            varint(1);
+        }
+    }
+
+    void map_field(int field, const std::map<std::string, std::string>& entries) {
+        for (const auto& entry : entries) {
+            ProtoWriter item;
+            item.string_field(1, entry.first);
+            item.string_field(2, entry.second);
+            bytes_field(field, item.data());
+        }
+    }
+
+    const std::string& data() const { return data_; }
+};
+
+std::map<std::string, std::string> sorted_map(const std::string& text) {
+    auto parsed = LogReader::parse_string_map(text);
+    return std::map<std::string, std::string>(parsed.begin(), parsed.end());
+}
+
+bool read_varint(const std::string& data, size_t& pos, uint64_t& value) {
+    value = 0;
+    for (int shift = 0; shift < 64 && pos < data.size(); shift += 7) {
+        uint8_t byte = static_cast<uint8_t>(data[pos++]);
+        value |= static_cast<uint64_t>(byte & 0x7f) << shift;
+        if (!(byte & 0x80)) {
+            return true;
+        }
+    }
+    return false;
+}
+
+// 解析带5字节gRPC前缀的SubscribeRequest，只关心event_types
+std::vector<std::string> parse_subscribe_request(const std::string& message) {
+    std::vector<std::string> event_types;
+    if (message.size() < 5 || message[0] != 0) {
+        return event_types;  // 空请求或压缩消息按订阅全部处理
+    }
+
+    std::string body = message.substr(5, read_u32(message, 1));
+    size_t pos = 0;
+    uint64_t key = 0, value = 0;
+    while (pos < body.size() && read_varint(body, pos, key)) {
+        switch (key & 0x7) {
+            case 0:
+                if (!read_varint(body, pos, value)) {
+                    return event_types;
+                }
+                break;
+            case 1: pos += 8; break;
+            case 5: pos += 4; break;
+            case 2:
+                if (!read_varint(body, pos, value) || value > body.size() - pos) {
+                    return event_types;
+                }
+                if ((key >> 3) == 1) {
+                    event_types.push_back(body.substr(pos, value));
+                }
+                pos += value;
+                break;
+            default:
+                return event_types;
+        }
+    }
+    return event_types;
+}
+
+} // namespace
+
+bool GrpcStreamServer::parse_url(const std::string& url, std::string& address, int& port) {
+    if (url.compare(0, SCHEME.size(), SCHEME) != 0) {
+        return false;
+    }
+    return BmpCollector::parse_listen_spec(url.substr(SCHEME.size()), address, port);
+}
+
+std::string GrpcStreamServer::encode_record(const JsonObject& record, const std::string& line,
+                                            const std::string& monitor_id) {
+    std::string event_type = LogReader::get_string(record, "event_type");
+    std::string timestamp = LogReader::get_timestamp(record, "timestamp");
+    std::set<std::string> consumed = {"event_type", "timestamp", "router_name", "user", "monitor_id",
+                                      "session_id", "offset_from_trigger_ms"};
+
+    ProtoWriter message;
+    message.string_field(1, event_type);
+    message.string_field(2, timestamp);
+    message.int_field(3, LogReader::parse_timestamp_ms(timestamp));
+    message.string_field(4, LogReader::get_string(record, "router_name"));
+    message.string_field(5, LogReader::get_string(record, "user"));
+    message.string_field(6, LogReader::get_string(record, "monitor_id", monitor_id));
+    if (LogReader::has(record, "session_id")) {
+        message.int_field(7, LogReader::get_int(record, "session_id"), true);
+    }
+    if (LogReader::has(record, "offset_from_trigger_ms")) {
+        message.int_field(8, LogReader::get_int(record, "offset_from_trigger_ms"), true);
+    }
+
+    ProtoWriter detail;
+    if (event_type == "session_started") {
+        detail.string_field(1, LogReader::get_string(record, "trigger_source"));
+        detail.string_field(2, LogReader::get_string(record, "trigger_event_type"));
+        detail.map_field(3, sorted_map(LogReader::get_string(record, "trigger_info")));
+        detail.string_field(4, LogReader::get_string(record, "link"));
+        consumed.insert({"trigger_source", "trigger_event_type", "trigger_info", "link"});
+        message.bytes_field(10, detail.data());
+    } else if (event_type == "route_event") {
+        detail.string_field(1, LogReader::get_string(record, "route_event_type"));
+        detail.int_field(2, LogReader::get_int(record, "route_event_number"));
+        detail.int_field(3, LogReader::get_int(record, "session_event_number"));
+        detail.map_field(4, sorted_map(LogReader::get_string(record, "route_info")));
+        consumed.insert({"route_event_type", "route_event_number", "session_event_number", "route_info"});
+        message.bytes_field(11, detail.data());
+    } else if (event_type == "session_completed") {
+        if (LogReader::has(record, "convergence_time_ms")) {
+            detail.int_field(1, LogReader::get_int(record, "convergence_time_ms"), true);
+        }
+        detail.int_field(2, LogReader::get_int(record, "route_events_count"));
+        detail.int_field(3, LogReader::get_int(record, "session_duration_ms"));
+        detail.int_field(4, LogReader::get_int(record, "convergence_threshold_ms"));
+        detail.bool_field(5, LogReader::get_bool(record, "timed_out"));
+        detail.map_field(6, sorted_map(LogReader::get_string(record, "netem_info")));
+        detail.string_field(7, LogReader::get_string(record, "link"));
+        consumed.insert({"convergence_time_ms", "route_events_count", "session_duration_ms",
+                         "convergence_threshold_ms", "timed_out", "netem_info", "link"});
+        message.bytes_field(12, detail.data());
+    }
+
+    std::map<std::string, std::string> fields;
+    for (const auto& field : record) {
+        if (!consumed.count(field.first)) {
+            fields[field.first] = LogReader::get_string(record, field.first);
+        }
+    }
+    message.map_field(15, fields);
+    message.string_field(16, line);
+
+    // gRPC长度前缀: 1字节压缩标志 + 4字节大端长度
+    const std::string& body = message.data();
+    std::string framed(1, '\0');
+    for (int shift = 24; shift >= 0; shift -= 8) {
+        framed += static_cast<char>((body.size() >> shift) & 0xff);
+    }
+    return framed + body;
+}
+
+GrpcStreamServer::GrpcStreamServer(const std::string& listen_address, int port, const std::string& monitor_id)
+    : listen_address_(listen_address), port_(port), monitor_id_(monitor_id) {
+}
+
+GrpcStreamServer::~GrpcStreamServer() {
+    stop();
+}
+
+bool GrpcStreamServer::start(std::string& error) {
+    if (running_.load()) {
+        return true;
+    }
+
+    listen_fd_ = open_listen_socket(listen_address_, port_, SOCK_STREAM, error);
+    if (listen_fd_ < 0) {
+        return false;
+    }
+
+    running_.store(true);
+    accept_thread_ = std::thread(&GrpcStreamServer::accept_loop, this);
+    return true;
+}
+
+void GrpcStreamServer::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    // 连接线程看到running_为false后推送剩余记录并以grpc-status 0结束各个流
+    running_.store(false);
+    if (accept_thread_.joinable()) {
+        accept_thread_.join();
+    }
+    reap_clients(true);
+    if (listen_fd_ >= 0) {
+        close(listen_fd_);
+        listen_fd_ = -1;
+    }
+}
+
+void GrpcStreamServer::accept_loop() {
+    while (running_.load()) {
+        reap_clients(false);
+
+        struct pollfd pfd = {listen_fd_, POLLIN, 0};
+        if (poll(&pfd, 1, 200) <= 0 || !(pfd.revents & POLLIN)) {
+            continue;
+        }
+        int fd = accept4(listen_fd_, nullptr, nullptr, SOCK_CLOEXEC);
+        if (fd < 0) {
+            continue;
+        }
+        int on = 1;
+        setsockopt(fd, IPPROTO_TCP, TCP_NODELAY, &on, sizeof(on));
+
+        auto client = std::make_unique<Client>();
+        client->fd = fd;
+        client->wake_fd = eventfd(0, EFD_CLOEXEC | EFD_NONBLOCK);
+        Client* raw = client.get();
+        {
+            std::lock_guard<std::mutex> lock(clients_mutex_);
+            clients_.push_back(std::move(client));
+        }
+        raw->thread = std::thread([this, raw] {
+            serve(*raw);
+            close(raw->fd);
+            close(raw->wake_fd);
+            raw->done.store(true);
+        });
+    }
+}
+
+void GrpcStreamServer::reap_clients(bool all) {
+    std::list<std::unique_ptr<Client>> finished;
+    {
+        std::lock_guard<std::mutex> lock(clients_mutex_);
+        for (auto it = clients_.begin(); it != clients_.end();) {
+            if (all || (*it)->done.load()) {
+                finished.push_back(std::move(*it));
+                it = clients_.erase(it);
+            } else {
+                ++it;
+            }
+        }
+    }
+    for (auto& client : finished) {
+        if (client->thread.joinable()) {
+            client->thread.join();
+        }
+    }
+}
+
+bool GrpcStreamServer::send_all(Client& client, const std::string& data) {
+    size_t sent = 0;
+    while (sent < data.size()) {
+        ssize_t len = send(client.fd, data.data() + sent, data.size() - sent, MSG_NOSIGNAL);
+        if (len < 0 && errno == EINTR) {
+            continue;
+        }
+        if (len <= 0) {
+            return false;
+        }
+        sent += static_cast<size_t>(len);
+    }
+    return true;
+}
+
+bool GrpcStreamServer::handle_frame(Client& client, uint8_t type, uint8_t flags, uint32_t stream_id,
+                                    const std::string& payload) {
+    switch (type) {
+        case FRAME_SETTINGS:
+            if (flags & FLAG_ACK) {
+                return true;
+            }
+            for (size_t i = 0; i + 6 <= payload.size(); i += 6) {
+                uint16_t id = static_cast<uint16_t>((static_cast<uint8_t>(payload[i]) << 8) |
+                                                    static_cast<uint8_t>(payload[i + 1]));
+                uint32_t value = read_u32(payload, i + 2);
+                if (id == SETTINGS_INITIAL_WINDOW_SIZE) {
+                    int64_t delta = static_cast<int64_t>(value) - client.initial_window;
+                    for (auto& entry : client.streams) {
+                        entry.second.send_window += delta;
+                    }
+                    client.initial_window = value;
+                } else if (id == SETTINGS_MAX_FRAME_SIZE) {
+                    client.max_frame_size = value;
+                }
+            }
+            return send_all(client, frame(FRAME_SETTINGS, FLAG_ACK, 0, ""));
+
+        case FRAME_PING:
+            if (flags & FLAG_ACK) {
+                return true;
+            }
+            return send_all(client, frame(FRAME_PING, FLAG_ACK, 0, payload));
+
+        case FRAME_GOAWAY:
+            return false;
+
+        case FRAME_WINDOW_UPDATE:
+            if (payload.size() == 4) {
+                int64_t increment = read_u32(payload, 0) & 0x7fffffff;
+                if (stream_id == 0) {
+                    client.connection_window += increment;
+                } else if (client.streams.count(stream_id)) {
+                    client.streams[stream_id].send_window += increment;
+                }
+            }
+            return true;
+
+        case FRAME_RST_STREAM:
+            client.streams.erase(stream_id);
+            return true;
+
+        case FRAME_HEADERS:
+        case FRAME_DATA: {
+            if (stream_id == 0) {
+                return false;
+            }
+            bool created = !client.streams.count(stream_id);
+            Stream& stream = client.streams[stream_id];
+            if (created) {
+                stream.send_window = client.initial_window;
+            }
+
+            if (type == FRAME_DATA && !payload.empty()) {
+                size_t padding = (flags & FLAG_PADDED) ? static_cast<uint8_t>(payload[0]) + 1 : 0;
+                if (padding <= payload.size()) {
+                    stream.request += payload.substr(padding ? 1 : 0, payload.size() - padding);
+                }
+                // 归还接收窗口
+                uint32_t size = static_cast<uint32_t>(payload.size());
+                if (!send_all(client, window_update(0, size))) {
+                    return false;
+                }
+            }
+
+            if ((flags & FLAG_END_STREAM) && !stream.subscribed) {
+                stream.subscribed = true;
+                stream.event_types = parse_subscribe_request(stream.request);
+                stream.request.clear();
+                subscriber_count_.fetch_add(1);
+                return send_all(client, frame(FRAME_HEADERS, FLAG_END_HEADERS, stream_id, RESPONSE_HEADERS));
+            }
+            return true;
+        }
+
+        default:
+            // PRIORITY、CONTINUATION等帧不影响推送
+            return true;
+    }
+}
+
+bool GrpcStreamServer::flush_streams(Client& client) {
+    for (auto& entry : client.streams) {
+        Stream& stream = entry.second;
+        while (!stream.outbound.empty() && stream.send_window > 0 && client.connection_window > 0) {
+            size_t chunk = std::min<size_t>(stream.outbound.size(), client.max_frame_size);
+            chunk = std::min<size_t>(chunk, static_cast<size_t>(std::min(stream.send_window, client.connection_window)));
+            if (!send_all(client, frame(FRAME_DATA, 0, entry.first, stream.outbound.substr(0, chunk)))) {
+                return false;
+            }
+            stream.outbound.erase(0, chunk);
+            stream.send_window -= static_cast<int64_t>(chunk);
+            client.connection_window -= static_cast<int64_t>(chunk);
+        }
+    }
+    return true;
+}
+
+void GrpcStreamServer::serve(Client& client) {
+    // 服务端连接前言为一个SETTINGS帧
+    if (!send_all(client, frame(FRAME_SETTINGS, 0, 0, ""))) {
+        return;
+    }
+
+    bool preface_received = false;
+    std::chrono::steady_clock::time_point shutdown_deadline;
+    bool shutting_down = false;
+    char buffer[16384];
+
+    while (true) {
+        if (!running_.load() && !shutting_down) {
+            shutting_down = true;
+            shutdown_deadline = std::chrono::steady_clock::now() + std::chrono::seconds(2);
+        }
+
+        // 把队列中的记录分发给已订阅的流
+        size_t backlog = 0;
+        for (const auto& entry : client.streams) {
+            backlog = std::max(backlog, entry.second.outbound.size());
+        }
+        if (backlog < MAX_OUTBOUND_BYTES || shutting_down) {
+            std::deque<std::pair<std::string, std::string>> messages;
+            {
+                std::lock_guard<std::mutex> lock(client.queue_mutex);
+                messages.swap(client.queue);
+            }
+            for (const auto& message : messages) {
+                for (auto& entry : client.streams) {
+                    Stream& stream = entry.second;
+                    if (!stream.subscribed) {
+                        continue;
+                    }
+                    if (!stream.event_types.empty() &&
+                        std::find(stream.event_types.begin(), stream.event_types.end(), message.first) ==
+                            stream.event_types.end()) {
+                        continue;
+                    }
+                    stream.outbound += message.second;
+                    sent_count_.fetch_add(1);
+                }
+            }
+        }
+        if (!flush_streams(client)) {
+            return;
+        }
+
+        if (shutting_down) {
+            bool drained = true;
+            for (const auto& entry : client.streams) {
+                drained = drained && entry.second.outbound.empty();
+            }
+            if (drained || std::chrono::steady_clock::now() >= shutdown_deadline) {
+                for (const auto& entry : client.streams) {
+                    if (entry.second.subscribed) {
+                        send_all(client, frame(FRAME_HEADERS, FLAG_END_HEADERS | FLAG_END_STREAM,
+                                               entry.first, OK_TRAILERS));
+                    }
+                }
+                // GOAWAY: 最后处理的流ID + NO_ERROR
+                uint32_t last_stream = client.streams.empty() ? 0 : client.streams.rbegin()->first;
+                std::string goaway;
+                for (int shift = 24; shift >= 0; shift -= 8) {
+                    goaway += static_cast<char>((last_stream >> shift) & 0xff);
+                }
+                goaway += std::string(4, '\0');
+                send_all(client, frame(FRAME_GOAWAY, 0, 0, goaway));
+                return;
+            }
+        }
+
+        struct pollfd fds[2] = {{client.fd, POLLIN, 0}, {client.wake_fd, POLLIN, 0}};
+        if (poll(fds, 2, 200) <= 0) {
+            continue;
+        }
+        if (fds[1].revents & POLLIN) {
+            uint64_t counter;
+            ssize_t ignored = read(client.wake_fd, &counter, sizeof(counter));
+            (void)ignored;
+        }
+        if (!(fds[0].revents & (POLLIN | POLLHUP | POLLERR))) {
+            continue;
+        }
+
+        ssize_t len = read(client.fd, buffer, sizeof(buffer));
+        if (len <= 0) {
+            return;
+        }
+        client.inbound.append(buffer, static_cast<size_t>(len));
+
+        if (!preface_received) {
+            if (client.inbound.size() < CLIENT_PREFACE.size()) {
+                continue;
+            }
+            if (client.inbound.compare(0, CLIENT_PREFACE.size(), CLIENT_PREFACE) != 0) {
+                return;  // 不是HTTP/2 prior knowledge连接
+            }
+            client.inbound.erase(0, CLIENT_PREFACE.size());
+            preface_received = true;
+        }
+
+        size_t offset = 0;
+        while (client.inbound.size() - offset >= 9) {
+            size_t length = (static_cast<size_t>(static_cast<uint8_t>(client.inbound[offset])) << 16) |
+                            (static_cast<size_t>(static_cast<uint8_t>(client.inbound[offset + 1])) << 8) |
+                            static_cast<size_t>(static_cast<uint8_t>(client.inbound[offset + 2]));
+            if (client.inbound.size() - offset < 9 + length) {
+                break;
+            }
+            uint8_t type = static_cast<uint8_t>(client.inbound[offset + 3]);
+            uint8_t flags = static_cast<uint8_t>(client.inbound[offset + 4]);
+            uint32_t stream_id = read_u32(client.inbound, offset + 5) & 0x7fffffff;
+            if (!handle_frame(client, type, flags, stream_id, client.inbound.substr(offset + 9, length))) {
+                return;
+            }
+            offset += 9 + length;
+        }
+        client.inbound.erase(0, offset);
+    }
+}
+
+void GrpcStreamServer::write_record(const JsonObject& record, const std::string& line) {
+    std::lock_guard<std::mutex> lock(clients_mutex_);
+    if (clients_.empty()) {
+        return;
+    }
+
+    std::string event_type = LogReader::get_string(record, "event_type");
+    std::string message = encode_record(record, line, monitor_id_);
+    for (auto& client : clients_) {
+        if (client->done.load()) {
+            continue;
+        }
+        {
+            std::lock_guard<std::mutex> queue_lock(client->queue_mutex);
+            if (client->queue.size() >= MAX_PENDING_MESSAGES) {
+                client->queue.pop_front();
+                dropped_count_.fetch_add(1);
+            }
+            client->queue.emplace_back(event_type, message);
+        }
+        uint64_t one = 1;
+        ssize_t ignored = write(client->wake_fd, &one, sizeof(one));
+        (void)ignored;
+    }
+}
+
+std::string GrpcStreamServer::summary() const {
+    std::string text = "gRPC: 端口 " + std::to_string(port_) + " 共 " + std::to_string(subscriber_count_.load()) +
+                       " 个订阅，已推送 " + std::to_string(sent_count_.load()) + " 条记录";
+    if (dropped_count_.load() > 0) {
+        text += "，丢弃 " + std::to_string(dropped_count_.load()) + " 条";
+    }
+    return text;
+}
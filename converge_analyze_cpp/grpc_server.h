@@ -0,0 +1,88 @@
+#pragma once
+
+#include <atomic>
+#include <cstdint>
+#include <deque>
+#include <list>
+#include <map>
+#include <memory>
+#include <mutex>
+#include <string>
+#include <thread>
+#include <vector>
+#include "record_sink.h"
+
+// 以gRPC服务端流的形式推送记录: grpc://[ADDR:]PORT
+// 服务定义见convergence.proto(convergence.v1.ConvergenceStream/Subscribe)。
+// 内置的HTTP/2实现只支持明文(h2c prior knowledge)，不解析请求头部，
+// 连接上的每个请求流都按Subscribe处理。
+class GrpcStreamServer : public RecordSink {
+private:
+    struct Stream {
+        bool subscribed = false;          // 已收到完整的SubscribeRequest
+        std::string request;              // 尚未收完的请求DATA
+        std::vector<std::string> event_types;
+        std::string outbound;             // 等待流控窗口的DATA内容
+        int64_t send_window = 65535;
+    };
+
+    // 一个HTTP/2连接，由独立线程处理
+    struct Client {
+        int fd = -1;
+        int wake_fd = -1;                 // eventfd，有新记录时唤醒连接线程
+        std::thread thread;
+        std::atomic<bool> done{false};
+
+        std::mutex queue_mutex;
+        std::deque<std::pair<std::string, std::string>> queue;  // (event_type, gRPC消息)
+
+        // 以下只由连接线程访问
+        std::string inbound;
+        std::map<uint32_t, Stream> streams;
+        int64_t connection_window = 65535;
+        int64_t initial_window = 65535;
+        size_t max_frame_size = 16384;
+    };
+
+    std::string listen_address_;
+    int port_;
+    std::string monitor_id_;
+    int listen_fd_ = -1;
+    std::thread accept_thread_;
+    std::atomic<bool> running_{false};
+
+    std::mutex clients_mutex_;
+    std::list<std::unique_ptr<Client>> clients_;  // 受clients_mutex_保护
+
+    std::atomic<int64_t> subscriber_count_{0};
+    std::atomic<int64_t> sent_count_{0};
+    std::atomic<int64_t> dropped_count_{0};
+
+    static constexpr size_t MAX_PENDING_MESSAGES = 10000;
+
+    void accept_loop();
+    void serve(Client& client);
+    bool handle_frame(Client& client, uint8_t type, uint8_t flags, uint32_t stream_id,
+                      const std::string& payload);
+    bool flush_streams(Client& client);
+    bool send_all(Client& client, const std::string& data);
+    void reap_clients(bool all);
+
+public:
+    GrpcStreamServer(const std::string& listen_address, int port, const std::string& monitor_id);
+    ~GrpcStreamServer() override;
+
+    GrpcStreamServer(const GrpcStreamServer&) = delete;
+    GrpcStreamServer& operator=(const GrpcStreamServer&) = delete;
+
+    bool start(std::string& error) override;
+    void write_record(const JsonObject& record, const std::string& line) override;
+    void stop() override;
+    std::string summary() const override;
+
+    // 将一条记录编码为convergence.v1.Record
+    static std::string encode_record(const JsonObject& record, const std::string& line,
+                                     const std::string& monitor_id);
+
+    static bool parse_url(const std::string& url, std::string& address, int& port);
+};
@@ -0,0 +1,128 @@
+#include "health_server.h"
+#include "listen_socket.h"
+#include <cerrno>
+#include <poll.h>
+#include <sys/socket.h>
+#include <unistd.h>
+
+namespace {
+
+std::string status_text(int status) {
+    switch (status) {
+    case 200:
+        return "200 OK";
+    case 404:
+        return "404 Not Found";
+    case 405:
+        return "405 Method Not Allowed";
+    case 503:
+        return "503 Service Unavailable";
+    default:
+        return std::to_string(status) + " Unknown";
+    }
+}
+
+} // namespace
+
+HealthServer::HealthServer(const std::string& listen_address, int port, Handler handler)
+    : listen_address_(listen_address), port_(port), handler_(std::move(handler)) {
+}
+
+HealthServer::~HealthServer() {
+    stop();
+}
+
+bool HealthServer::start(std::string& error) {
+    if (running_.load()) {
+        return true;
+    }
+
+    listen_fd_ = open_listen_socket(listen_address_, port_, SOCK_STREAM, error);
+    if (listen_fd_ < 0) {
+        return false;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&HealthServer::worker_loop, this);
+    return true;
+}
+
+void HealthServer::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+    if (listen_fd_ >= 0) {
+        close(listen_fd_);
+        listen_fd_ = -1;
+    }
+}
+
+void HealthServer::worker_loop() {
+    while (running_.load()) {
+        struct pollfd pfd = {listen_fd_, POLLIN, 0};
+        if (poll(&pfd, 1, 200) <= 0 || !(pfd.revents & POLLIN)) {
+            continue;
+        }
+        int fd = accept4(listen_fd_, nullptr, nullptr, SOCK_CLOEXEC);
+        if (fd >= 0) {
+            serve(fd);
+            close(fd);
+        }
+    }
+}
+
+void HealthServer::serve(int fd) {
+    // 探针请求很小，读到头部结束或超时即可
+    struct timeval timeout = {1, 0};
+    setsockopt(fd, SOL_SOCKET, SO_RCVTIMEO, &timeout, sizeof(timeout));
+    setsockopt(fd, SOL_SOCKET, SO_SNDTIMEO, &timeout, sizeof(timeout));
+
+    std::string request;
+    char buffer[4096];
+    while (request.find("\r\n\r\n") == std::string::npos && request.size() < 16384) {
+        ssize_t len = read(fd, buffer, sizeof(buffer));
+        if (len <= 0) {
+            break;
+        }
+        request.append(buffer, static_cast<size_t>(len));
+    }
+
+    // 请求行: METHOD PATH VERSION
+    std::string request_line = request.substr(0, request.find("\r\n"));
+    size_t method_end = request_line.find(' ');
+    size_t path_end = method_end == std::string::npos ? std::string::npos : request_line.find(' ', method_end + 1);
+    std::string method = request_line.substr(0, method_end);
+    std::string path = path_end == std::string::npos ? ""
+                                                     : request_line.substr(method_end + 1, path_end - method_end - 1);
+    path = path.substr(0, path.find('?'));
+
+    int status;
+    std::string body;
+    if (method != "GET" && method != "HEAD") {
+        status = 405;
+        body = "{\"status\":\"method_not_allowed\"}";
+    } else {
+        status = handler_(path, body);
+        request_count_.fetch_add(1);
+    }
+    body += "\n";
+
+    std::string response = "HTTP/1.1 " + status_text(status) + "\r\n"
+        "Content-Type: application/json\r\n"
+        "Cache-Control: no-store\r\n"
+        "Content-Length: " + std::to_string(body.size()) + "\r\n"
+        "Connection: close\r\n\r\n" + (method == "HEAD" ? "" : body);
+    size_t sent = 0;
+    while (sent < response.size()) {
+        ssize_t len = send(fd, response.data() + sent, response.size() - sent, MSG_NOSIGNAL);
+        if (len <= 0) {
+            break;
+        }
+        sent += static_cast<size_t>(len);
+    }
+}
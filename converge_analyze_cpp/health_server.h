@@ -0,0 +1,41 @@
+#pragma once
+
+#include <atomic>
+#include <cstdint>
+#include <functional>
+#include <string>
+#include <thread>
+
+// 健康检查端点(--health-listen [ADDR:]PORT)：供Kubernetes等编排系统的存活/就绪探针使用。
+// 请求路径交给回调处理，回调返回HTTP状态码并填写JSON响应体，在监听线程中调用。
+//   curl -s http://127.0.0.1:8086/healthz
+class HealthServer {
+public:
+    // 参数为请求路径(不含查询串)，返回状态码(200/503/404)
+    using Handler = std::function<int(const std::string& path, std::string& body)>;
+
+private:
+    std::string listen_address_;
+    int port_;
+    Handler handler_;
+    int listen_fd_ = -1;
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+    std::atomic<int64_t> request_count_{0};
+
+    void worker_loop();
+    void serve(int fd);
+
+public:
+    HealthServer(const std::string& listen_address, int port, Handler handler);
+    ~HealthServer();
+
+    HealthServer(const HealthServer&) = delete;
+    HealthServer& operator=(const HealthServer&) = delete;
+
+    bool start(std::string& error);
+    void stop();
+
+    int port() const { return port_; }
+    int64_t request_count() const { return request_count_.load(); }
+};
@@ -0,0 +1,140 @@
+#include "http_client.h"
+#include <cstdlib>
+#include <cstring>
+#include <cerrno>
+#include <sstream>
+#include <netdb.h>
+#include <sys/socket.h>
+#include <sys/time.h>
+#include <unistd.h>
+
+bool HttpClient::parse_url(const std::string& url, std::string& host,
+                           std::string& port, std::string& path) {
+    const std::string scheme = "http://";
+    if (url.compare(0, scheme.size(), scheme) != 0) {
+        return false;
+    }
+
+    std::string rest = url.substr(scheme.size());
+    size_t slash = rest.find('/');
+    std::string authority = (slash == std::string::npos) ? rest : rest.substr(0, slash);
+    path = (slash == std::string::npos) ? "/" : rest.substr(slash);
+
+    if (authority.empty()) {
+        return false;
+    }
+
+    // 支持 [v6addr]:port 形式
+    if (authority.front() == '[') {
+        size_t close = authority.find(']');
+        if (close == std::string::npos) {
+            return false;
+        }
+        host = authority.substr(1, close - 1);
+        port = (close + 1 < authority.size() && authority[close + 1] == ':')
+                   ? authority.substr(close + 2) : "80";
+    } else {
+        size_t colon = authority.rfind(':');
+        host = (colon == std::string::npos) ? authority : authority.substr(0, colon);
+        port = (colon == std::string::npos) ? "80" : authority.substr(colon + 1);
+    }
+
+    return !host.empty() && !port.empty();
+}
+
+HttpClient::Response HttpClient::post(const std::string& url,
+                                      const std::string& body,
+                                      const std::string& content_type,
+                                      int timeout_ms,
+                                      const std::vector<std::string>& extra_headers) {
+    Response response;
+
+    std::string host, port, path;
+    if (!parse_url(url, host, port, path)) {
+        response.error = "unsupported url (only http:// is supported): " + url;
+        return response;
+    }
+
+    struct addrinfo hints;
+    memset(&hints, 0, sizeof(hints));
+    hints.ai_family = AF_UNSPEC;
+    hints.ai_socktype = SOCK_STREAM;
+
+    struct addrinfo* result = nullptr;
+    int rc = getaddrinfo(host.c_str(), port.c_str(), &hints, &result);
+    if (rc != 0) {
+        response.error = "resolve " + host + ": " + gai_strerror(rc);
+        return response;
+    }
+
+    struct timeval tv;
+    tv.tv_sec = timeout_ms / 1000;
+    tv.tv_usec = (timeout_ms % 1000) * 1000;
+
+    int fd = -1;
+    for (struct addrinfo* ai = result; ai != nullptr; ai = ai->ai_next) {
+        fd = socket(ai->ai_family, ai->ai_socktype | SOCK_CLOEXEC, ai->ai_protocol);
+        if (fd < 0) {
+            continue;
+        }
+        setsockopt(fd, SOL_SOCKET, SO_RCVTIMEO, &tv, sizeof(tv));
+        setsockopt(fd, SOL_SOCKET, SO_SNDTIMEO, &tv, sizeof(tv));
+        if (connect(fd, ai->ai_addr, ai->ai_addrlen) == 0) {
+            break;
+        }
+        close(fd);
+        fd = -1;
+    }
+    freeaddrinfo(result);
+
+    if (fd < 0) {
+        response.error = "connect " + host + ":" + port + ": " + strerror(errno);
+        return response;
+    }
+
+    std::ostringstream request;
+    request << "POST " << path << " HTTP/1.1\r\n"
+            << "Host: " << host << "\r\n"
+            << "Content-Type: " << content_type << "\r\n"
+            << "Content-Length: " << body.size() << "\r\n";
+    for (const auto& header : extra_headers) {
+        request << header << "\r\n";
+    }
+    request << "Connection: close\r\n\r\n"
+            << body;
+    std::string data = request.str();
+
+    size_t sent = 0;
+    while (sent < data.size()) {
+        ssize_t n = send(fd, data.data() + sent, data.size() - sent, MSG_NOSIGNAL);
+        if (n <= 0) {
+            response.error = "send: " + std::string(strerror(errno));
+            close(fd);
+            return response;
+        }
+        sent += static_cast<size_t>(n);
+    }
+
+    // 只需要状态行
+    char buffer[512];
+    ssize_t n = recv(fd, buffer, sizeof(buffer) - 1, 0);
+    close(fd);
+    if (n <= 0) {
+        response.error = "recv: " + std::string(n == 0 ? "connection closed" : strerror(errno));
+        return response;
+    }
+    buffer[n] = '\0';
+
+    // HTTP/1.1 200 OK
+    const char* sp = strchr(buffer, ' ');
+    if (sp == nullptr) {
+        response.error = "malformed http response";
+        return response;
+    }
+    response.status_code = atoi(sp + 1);
+    if (response.status_code < 200 || response.status_code >= 300) {
+        response.error = "http status " + std::to_string(response.status_code);
+    }
+
+    return response;
+}
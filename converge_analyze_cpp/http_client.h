@@ -0,0 +1,27 @@
+#pragma once
+
+#include <string>
+#include <vector>
+
+// 极简HTTP客户端，仅支持 http:// 明文POST（无外部依赖）
+class HttpClient {
+public:
+    struct Response {
+        int status_code = 0;
+        std::string error;
+
+        bool ok() const { return error.empty() && status_code >= 200 && status_code < 300; }
+    };
+
+    // 发送POST请求，timeout_ms同时作用于连接和读写
+    // extra_headers为完整的头部行，如 "Authorization: Token xxx"
+    static Response post(const std::string& url,
+                         const std::string& body,
+                         const std::string& content_type = "application/json",
+                         int timeout_ms = 3000,
+                         const std::vector<std::string>& extra_headers = {});
+
+    // 解析URL为 host/port/path，失败返回false
+    static bool parse_url(const std::string& url, std::string& host,
+                          std::string& port, std::string& path);
+};
@@ -0,0 +1,140 @@
+#include "i18n.h"
+#include <atomic>
+#include <cstdlib>
+
+namespace {
+
+std::atomic<Language> current_language{Language::ZH};
+
+std::string to_upper(std::string text) {
+    for (auto& c : text) {
+        if (c >= 'a' && c <= 'z') {
+            c = static_cast<char>(c - 'a' + 'A');
+        }
+    }
+    return text;
+}
+
+} // namespace
+
+bool parse_language(const std::string& text, Language& language) {
+    if (text == "zh" || text == "zh_CN" || text == "cn") {
+        language = Language::ZH;
+        return true;
+    }
+    if (text == "en" || text == "en_US") {
+        language = Language::EN;
+        return true;
+    }
+    if (text == "auto") {
+        // 与setlocale相同的优先级
+        for (const char* name : {"LC_ALL", "LC_MESSAGES", "LANG"}) {
+            const char* value = getenv(name);
+            if (value && *value) {
+                language = std::string(value).compare(0, 2, "zh") == 0 ? Language::ZH : Language::EN;
+                return true;
+            }
+        }
+        language = Language::EN;
+        return true;
+    }
+    return false;
+}
+
+void set_language(Language language) {
+    current_language.store(language);
+}
+
+Language get_language() {
+    return current_language.load();
+}
+
+const char* tr(const char* zh, const char* en) {
+    return current_language.load() == Language::EN ? en : zh;
+}
+
+std::string event_type_label(const std::string& event_type) {
+    if (event_type == "route_add") {
+        return tr("路由添加", "route add");
+    }
+    if (event_type == "route_del") {
+        return tr("路由删除", "route delete");
+    }
+    if (event_type == "route_replace") {
+        return tr("路由替换", "route replace");
+    }
+    if (event_type == "rule_add") {
+        return tr("策略规则添加", "rule add");
+    }
+    if (event_type == "rule_del") {
+        return tr("策略规则删除", "rule delete");
+    }
+    if (event_type == "mroute_add") {
+        return tr("组播路由添加", "mroute add");
+    }
+    if (event_type == "mroute_del") {
+        return tr("组播路由删除", "mroute delete");
+    }
+    if (event_type == "fdb_add") {
+        return tr("MAC表项添加", "FDB add");
+    }
+    if (event_type == "fdb_del") {
+        return tr("MAC表项删除", "FDB delete");
+    }
+    if (event_type == "fdb_move") {
+        return tr("MAC迁移", "MAC move");
+    }
+    if (event_type == "nexthop_changed") {
+        return tr("下一跳切换", "nexthop change");
+    }
+    if (event_type == "fast_reroute") {
+        return tr("快速重路由", "fast reroute");
+    }
+    if (event_type == "tunnel_add") {
+        return tr("隧道创建", "tunnel add");
+    }
+    if (event_type == "tunnel_del") {
+        return tr("隧道删除", "tunnel delete");
+    }
+    if (event_type == "tunnel_up") {
+        return tr("隧道UP", "tunnel up");
+    }
+    if (event_type == "tunnel_down") {
+        return tr("隧道DOWN", "tunnel down");
+    }
+    if (event_type == "tunnel_endpoint_change") {
+        return tr("隧道端点变化", "tunnel endpoint change");
+    }
+    if (event_type == "lag_failover") {
+        return tr("聚合切换", "LAG failover");
+    }
+    if (event_type == "lag_member_change") {
+        return tr("聚合成员变化", "LAG member change");
+    }
+    if (event_type == "lacp_state_change") {
+        return tr("LACP状态变化", "LACP state change");
+    }
+    if (event_type == "link_mtu_change") {
+        return tr("MTU变化", "MTU change");
+    }
+    if (event_type == "link_promisc_change") {
+        return tr("混杂模式变化", "promisc change");
+    }
+    if (event_type == "link_master_change") {
+        return tr("主接口变化", "master change");
+    }
+    if (event_type == "gnmi_update") {
+        return tr("gNMI更新", "gNMI update");
+    }
+    if (event_type == "gnmi_delete") {
+        return tr("gNMI删除", "gNMI delete");
+    }
+    if (event_type.compare(0, 6, "netem_") == 0) {
+        std::string qdisc_event = to_upper(event_type.substr(6));
+        return std::string(tr("Netem事件(", "netem event (")) + qdisc_event + ")";
+    }
+    if (event_type.compare(0, 5, "snmp_") == 0) {
+        return "SNMP " + event_type.substr(5);
+    }
+    return event_type;
+}
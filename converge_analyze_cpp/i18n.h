@@ -0,0 +1,19 @@
+#pragma once
+
+#include <string>
+
+// 控制台文本语言(--lang)。JSON记录使用与语言无关的键(如route_add)，只有控制台输出会本地化。
+enum class Language { ZH, EN };
+
+// 解析 zh/en/auto，auto按LC_ALL、LC_MESSAGES、LANG选择(zh*为中文，其余为英文)
+bool parse_language(const std::string& text, Language& language);
+
+void set_language(Language language);
+Language get_language();
+
+// 按当前语言选择文本
+const char* tr(const char* zh, const char* en);
+
+// 事件类型键(route_add、gnmi_update、netem_qdisc_add、snmp_linkDown等)的显示名称，
+// 旧日志中的中文事件类型原样返回
+std::string event_type_label(const std::string& event_type);
@@ -0,0 +1,194 @@
+#include "igp_adjacency.h"
+#include "log_reader.h"
+#include "subprocess.h"
+#include <chrono>
+#include <vector>
+
+namespace {
+
+int64_t now_ms() {
+    return std::chrono::duration_cast<std::chrono::milliseconds>(
+        std::chrono::system_clock::now().time_since_epoch()).count();
+}
+
+std::string make_key(const std::string& protocol, const std::string& neighbor, const std::string& interface) {
+    return protocol + "|" + neighbor + "|" + interface;
+}
+
+void split_key(const std::string& key, IgpAdjacencyEvent& event) {
+    size_t first = key.find('|');
+    size_t second = key.find('|', first + 1);
+    event.protocol = key.substr(0, first);
+    event.neighbor = key.substr(first + 1, second - first - 1);
+    event.interface = key.substr(second + 1);
+}
+
+} // namespace
+
+IgpAdjacencyTracker::IgpAdjacencyTracker(const std::string& vtysh_path, int64_t poll_interval_ms,
+                                         Callback callback)
+    : vtysh_path_(vtysh_path), poll_interval_ms_(poll_interval_ms), callback_(std::move(callback)) {
+}
+
+IgpAdjacencyTracker::~IgpAdjacencyTracker() {
+    stop();
+}
+
+void IgpAdjacencyTracker::start() {
+    if (running_.load()) {
+        return;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&IgpAdjacencyTracker::worker_loop, this);
+}
+
+void IgpAdjacencyTracker::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+}
+
+void IgpAdjacencyTracker::worker_loop() {
+    StateMap previous;
+    bool has_baseline = false;
+
+    while (running_.load()) {
+        auto poll_start = std::chrono::steady_clock::now();
+
+        StateMap current;
+        std::string output, error;
+        bool ok = false;
+        if (run_command({vtysh_path_, "-c", "show ip ospf neighbor json"}, output, error) == 0) {
+            ok |= parse_ospf_neighbors(output, current);
+        }
+        if (run_command({vtysh_path_, "-c", "show isis neighbor json"}, output, error) == 0) {
+            ok |= parse_isis_neighbors(output, current);
+        }
+
+        // vtysh失败时不更新基线，避免把采集失败误判为邻接全部Down
+        if (ok) {
+            if (has_baseline) {
+                for (const auto& event : diff(previous, current, now_ms())) {
+                    callback_(event);
+                }
+            }
+            previous = std::move(current);
+            has_baseline = true;
+        }
+
+        auto deadline = poll_start + std::chrono::milliseconds(poll_interval_ms_);
+        while (running_.load() && std::chrono::steady_clock::now() < deadline) {
+            std::this_thread::sleep_for(std::chrono::milliseconds(20));
+        }
+    }
+}
+
+bool IgpAdjacencyTracker::parse_ospf_neighbors(const std::string& text, StateMap& states) {
+    // {"neighbors":{"2.2.2.2":[{"nbrState":"Full/DR","ifaceName":"eth1:10.0.0.1",...}]}}
+    // 旧版本没有外层"neighbors"
+    JsonObject root;
+    if (!LogReader::parse_object(text, root)) {
+        return false;
+    }
+    JsonObject neighbors;
+    if (LogReader::has(root, "neighbors")) {
+        if (!LogReader::parse_object(LogReader::get_string(root, "neighbors"), neighbors)) {
+            return false;
+        }
+    } else {
+        neighbors = root;
+    }
+
+    for (const auto& neighbor : neighbors) {
+        std::vector<JsonValue> entries;
+        if (!LogReader::parse_array(neighbor.second.as_string(), entries)) {
+            continue;
+        }
+        for (const auto& value : entries) {
+            JsonObject entry;
+            if (!LogReader::parse_object(value.as_string(), entry)) {
+                continue;
+            }
+            std::string state = LogReader::get_string(entry, "nbrState",
+                                                      LogReader::get_string(entry, "state"));
+            std::string iface = LogReader::get_string(entry, "ifaceName");
+            iface = iface.substr(0, iface.find(':'));
+            states[make_key("ospf", neighbor.first, iface)] = state.substr(0, state.find('/'));
+        }
+    }
+    return true;
+}
+
+bool IgpAdjacencyTracker::parse_isis_neighbors(const std::string& text, StateMap& states) {
+    // {"areas":[{"area":"1","circuits":[{"adj":"r2","interface":"eth1","level":2,"state":"Up"}]}]}
+    JsonObject root;
+    if (!LogReader::parse_object(text, root)) {
+        return false;
+    }
+    std::vector<JsonValue> areas;
+    if (!LogReader::parse_array(LogReader::get_string(root, "areas"), areas)) {
+        return false;
+    }
+
+    for (const auto& area_value : areas) {
+        JsonObject area;
+        std::vector<JsonValue> circuits;
+        if (!LogReader::parse_object(area_value.as_string(), area) ||
+            !LogReader::parse_array(LogReader::get_string(area, "circuits"), circuits)) {
+            continue;
+        }
+        for (const auto& circuit_value : circuits) {
+            JsonObject circuit;
+            if (!LogReader::parse_object(circuit_value.as_string(), circuit) ||
+                !LogReader::has(circuit, "adj")) {
+                continue;
+            }
+            std::string neighbor = LogReader::get_string(circuit, "adj");
+            if (LogReader::has(circuit, "level")) {
+                neighbor += "/L" + std::to_string(LogReader::get_int(circuit, "level"));
+            }
+            states[make_key("isis", neighbor, LogReader::get_string(circuit, "interface"))] =
+                LogReader::get_string(circuit, "state", "unknown");
+        }
+    }
+    return true;
+}
+
+std::vector<IgpAdjacencyEvent> IgpAdjacencyTracker::diff(const StateMap& before, const StateMap& after,
+                                                         int64_t timestamp_ms) {
+    std::vector<IgpAdjacencyEvent> events;
+
+    for (const auto& pair : after) {
+        auto it = before.find(pair.first);
+        std::string old_state = it == before.end() ? "Down" : it->second;
+        if (old_state == pair.second) {
+            continue;
+        }
+        IgpAdjacencyEvent event;
+        split_key(pair.first, event);
+        event.timestamp_ms = timestamp_ms;
+        event.old_state = old_state;
+        event.new_state = pair.second;
+        events.push_back(event);
+    }
+
+    for (const auto& pair : before) {
+        if (after.count(pair.first) || pair.second == "Down") {
+            continue;
+        }
+        IgpAdjacencyEvent event;
+        split_key(pair.first, event);
+        event.timestamp_ms = timestamp_ms;
+        event.old_state = pair.second;
+        event.new_state = "Down";
+        events.push_back(event);
+    }
+
+    return events;
+}
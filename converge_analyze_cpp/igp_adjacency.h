@@ -0,0 +1,53 @@
+#pragma once
+
+#include <atomic>
+#include <functional>
+#include <map>
+#include <string>
+#include <thread>
+#include <vector>
+
+// IGP邻接状态变化
+struct IgpAdjacencyEvent {
+    int64_t timestamp_ms = 0;  // 检测到变化的时间(轮询精度)
+    std::string protocol;      // ospf | isis
+    std::string neighbor;      // OSPF Router ID / IS-IS系统名
+    std::string interface;
+    std::string old_state;     // 首次出现时为"Down"
+    std::string new_state;     // 消失时为"Down"
+};
+
+// 通过周期性调用vtysh跟踪OSPF/IS-IS邻接状态
+class IgpAdjacencyTracker {
+public:
+    using Callback = std::function<void(const IgpAdjacencyEvent&)>;
+    // 键为 "协议|邻居|接口"，值为状态
+    using StateMap = std::map<std::string, std::string>;
+
+private:
+    std::string vtysh_path_;
+    int64_t poll_interval_ms_;
+    Callback callback_;
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+
+    void worker_loop();
+
+public:
+    IgpAdjacencyTracker(const std::string& vtysh_path, int64_t poll_interval_ms, Callback callback);
+    ~IgpAdjacencyTracker();
+
+    IgpAdjacencyTracker(const IgpAdjacencyTracker&) = delete;
+    IgpAdjacencyTracker& operator=(const IgpAdjacencyTracker&) = delete;
+
+    void start();
+    void stop();
+
+    // 解析 show ip ospf neighbor json / show isis neighbor json，追加到states
+    static bool parse_ospf_neighbors(const std::string& text, StateMap& states);
+    static bool parse_isis_neighbors(const std::string& text, StateMap& states);
+
+    // 比较两次快照，返回状态变化
+    static std::vector<IgpAdjacencyEvent> diff(const StateMap& before, const StateMap& after,
+                                               int64_t timestamp_ms);
+};
@@ -0,0 +1,233 @@
+#include "influx_writer.h"
+#include "http_client.h"
+#include "log_reader.h"
+#include <cstdlib>
+#include <iostream>
+
+namespace {
+
+const std::string HTTP_SCHEME = "influx+http://";
+const std::string FILE_SCHEME = "influx+file://";
+
+void append_tag(std::string& line, const std::string& key, const std::string& value) {
+    // 行协议不允许空的tag值
+    if (!value.empty() && value != "N/A") {
+        line += "," + InfluxWriter::escape_key(key) + "=" + InfluxWriter::escape_key(value);
+    }
+}
+
+} // namespace
+
+bool InfluxWriter::is_influx_url(const std::string& url) {
+    if (url.compare(0, FILE_SCHEME.size(), FILE_SCHEME) == 0) {
+        return url.size() > FILE_SCHEME.size();
+    }
+    std::string host, port, path;
+    return url.compare(0, HTTP_SCHEME.size(), HTTP_SCHEME) == 0 &&
+           HttpClient::parse_url(url.substr(7), host, port, path);
+}
+
+std::string InfluxWriter::escape_key(const std::string& text) {
+    std::string escaped;
+    for (char c : text) {
+        if (c == ',' || c == '=' || c == ' ') {
+            escaped += '\\';
+        }
+        escaped += c;
+    }
+    return escaped;
+}
+
+std::string InfluxWriter::escape_field_string(const std::string& text) {
+    std::string escaped = "\"";
+    for (char c : text) {
+        if (c == '"' || c == '\\') {
+            escaped += '\\';
+        }
+        escaped += c;
+    }
+    return escaped + "\"";
+}
+
+InfluxWriter::InfluxWriter(const std::string& url, const std::vector<std::string>& tag_keys)
+    : tag_keys_(tag_keys) {
+    if (url.compare(0, FILE_SCHEME.size(), FILE_SCHEME) == 0) {
+        file_path_ = url.substr(FILE_SCHEME.size());
+    } else {
+        // 去掉"influx+"前缀
+        http_url_ = url.substr(7);
+        http_url_ += (http_url_.find('?') == std::string::npos ? "?" : "&") + std::string("precision=ms");
+        const char* token = getenv("INFLUX_TOKEN");
+        if (token && *token) {
+            headers_.push_back("Authorization: Token " + std::string(token));
+        }
+    }
+}
+
+InfluxWriter::~InfluxWriter() {
+    stop();
+}
+
+bool InfluxWriter::start(std::string& error) {
+    if (running_.load()) {
+        return true;
+    }
+
+    if (!file_path_.empty()) {
+        file_.open(file_path_, std::ios::out | std::ios::app);
+        if (!file_.is_open()) {
+            error = "无法打开文件: " + file_path_;
+            return false;
+        }
+    }
+
+    running_.store(true);
+    if (!http_url_.empty()) {
+        worker_thread_ = std::thread(&InfluxWriter::worker_loop, this);
+    }
+    return true;
+}
+
+void InfluxWriter::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    queue_cv_.notify_all();
+
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+    if (file_.is_open()) {
+        file_.close();
+    }
+}
+
+void InfluxWriter::write_record(const JsonObject& record, const std::string&) {
+    std::lock_guard<std::mutex> lock(record_mutex_);
+    std::string event_type = LogReader::get_string(record, "event_type");
+    std::string router = LogReader::get_string(record, "router_name");
+    int64_t timestamp_ms = LogReader::parse_timestamp_ms(LogReader::get_timestamp(record, "timestamp"));
+
+    if (event_type == "session_started") {
+        InfluxSessionTags tags;
+        tags.trigger_source = LogReader::get_string(record, "trigger_source");
+        auto trigger_info = LogReader::parse_string_map(LogReader::get_string(record, "trigger_info"));
+        // 优先使用route_add/route_del等英文类型，便于查询
+        auto type_it = trigger_info.find("type");
+        tags.trigger_type = type_it != trigger_info.end() ? type_it->second
+                                                          : LogReader::get_string(record, "trigger_event_type");
+        auto iface_it = trigger_info.find("interface");
+        if (iface_it != trigger_info.end()) {
+            tags.interface = iface_it->second;
+        }
+        tags.start_time_ms = timestamp_ms;
+        sessions_[LogReader::get_int(record, "session_id")] = tags;
+        return;
+    }
+
+    if (event_type == "session_completed") {
+        int64_t session_id = LogReader::get_int(record, "session_id");
+        InfluxSessionTags tags;
+        auto it = sessions_.find(session_id);
+        if (it != sessions_.end()) {
+            tags = it->second;
+            sessions_.erase(it);
+        }
+
+        std::string line = "convergence";
+        append_tag(line, "router", router);
+        append_tag(line, "interface", tags.interface);
+        append_tag(line, "trigger_source", tags.trigger_source);
+        append_tag(line, "trigger_type", tags.trigger_type);
+        append_tag(line, "link", LogReader::get_string(record, "link"));
+        for (const auto& key : tag_keys_) {
+            append_tag(line, key, LogReader::get_string(record, key));
+        }
+
+        line += " session_id=" + std::to_string(session_id) + "i";
+        if (LogReader::has(record, "convergence_time_ms")) {
+            line += ",convergence_ms=" + std::to_string(LogReader::get_int(record, "convergence_time_ms")) + "i";
+        }
+        line += ",route_events=" + std::to_string(LogReader::get_int(record, "route_events_count")) + "i";
+        line += ",duration_ms=" + std::to_string(LogReader::get_int(record, "session_duration_ms")) + "i";
+        line += std::string(",timed_out=") + (LogReader::get_bool(record, "timed_out") ? "true" : "false");
+        line += std::string(",forced=") + (LogReader::get_bool(record, "forced") ? "true" : "false");
+
+        // 以触发时间作为数据点时间
+        line += " " + std::to_string(tags.start_time_ms > 0 ? tags.start_time_ms : timestamp_ms);
+        emit(line);
+        return;
+    }
+
+    if (event_type == "monitoring_completed") {
+        std::string line = "convergence_monitor";
+        append_tag(line, "router", router);
+        line += " listen_duration_ms=" + std::to_string(LogReader::get_int(record, "total_listen_duration_ms")) + "i";
+        line += ",trigger_events=" + std::to_string(LogReader::get_int(record, "total_trigger_events")) + "i";
+        line += ",route_events=" + std::to_string(LogReader::get_int(record, "total_route_events")) + "i";
+        line += ",completed_sessions=" + std::to_string(LogReader::get_int(record, "completed_sessions_count")) + "i";
+        line += " " + std::to_string(timestamp_ms);
+        emit(line);
+    }
+}
+
+std::string InfluxWriter::summary() const {
+    std::string text = "InfluxDB: 已写入 " + std::to_string(written_count_.load()) + " 个数据点";
+    if (failed_count_.load() > 0) {
+        text += "，失败 " + std::to_string(failed_count_.load()) + " 个";
+    }
+    return text;
+}
+
+void InfluxWriter::emit(const std::string& line) {
+    if (file_.is_open()) {
+        // 文件中使用纳秒时间戳(行协议默认精度)
+        file_ << line << "000000\n";
+        file_.flush();
+        written_count_.fetch_add(1);
+        return;
+    }
+
+    std::unique_lock<std::mutex> lock(queue_mutex_);
+    if (pending_.size() >= MAX_PENDING_LINES) {
+        pending_.pop();
+        failed_count_.fetch_add(1);
+    }
+    pending_.push(line);
+    lock.unlock();
+
+    queue_cv_.notify_one();
+}
+
+void InfluxWriter::worker_loop() {
+    std::unique_lock<std::mutex> lock(queue_mutex_);
+
+    while (running_.load() || !pending_.empty()) {
+        queue_cv_.wait(lock, [this] {
+            return !pending_.empty() || !running_.load();
+        });
+
+        while (!pending_.empty()) {
+            std::string body;
+            size_t lines = 0;
+            while (!pending_.empty() && lines < MAX_BATCH_LINES) {
+                body += pending_.front() + "\n";
+                pending_.pop();
+                lines++;
+            }
+            lock.unlock();
+
+            auto response = HttpClient::post(http_url_, body, "text/plain; charset=utf-8", 3000, headers_);
+            if (response.ok()) {
+                written_count_.fetch_add(static_cast<int64_t>(lines));
+            } else {
+                failed_count_.fetch_add(static_cast<int64_t>(lines));
+                std::cerr << "⚠️  InfluxDB写入失败: " << response.error << "\n";
+            }
+
+            lock.lock();
+        }
+    }
+}
@@ -0,0 +1,75 @@
+#pragma once
+
+#include <atomic>
+#include <condition_variable>
+#include <fstream>
+#include <map>
+#include <mutex>
+#include <queue>
+#include <string>
+#include <thread>
+#include <vector>
+#include "record_sink.h"
+
+// 会话开始时记录的标签，会话完成时写出
+struct InfluxSessionTags {
+    std::string trigger_source;
+    std::string trigger_type;
+    std::string interface;
+    int64_t start_time_ms = 0;
+};
+
+// 将会话结果转换为InfluxDB行协议，写入文件或通过HTTP写入
+// influx+http://HOST:PORT/write?db=DB              (1.x)
+// influx+http://HOST:PORT/api/v2/write?org=O&bucket=B (2.x，令牌取自INFLUX_TOKEN环境变量)
+// influx+file:///PATH
+class InfluxWriter : public RecordSink {
+private:
+    std::string http_url_;   // 已附加precision=ms
+    std::string file_path_;
+    std::ofstream file_;
+    std::vector<std::string> tag_keys_;  // 额外作为tag输出的记录字段(如--tag的键)
+    std::vector<std::string> headers_;
+
+    std::mutex record_mutex_;                         // 日志线程与log_sync可能并发调用write_record
+    std::map<int64_t, InfluxSessionTags> sessions_;  // 受record_mutex_保护
+
+    std::queue<std::string> pending_;
+    std::mutex queue_mutex_;
+    std::condition_variable queue_cv_;
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+
+    std::atomic<int64_t> written_count_{0};
+    std::atomic<int64_t> failed_count_{0};
+
+    static constexpr size_t MAX_PENDING_LINES = 10000;
+    static constexpr size_t MAX_BATCH_LINES = 500;
+
+    void worker_loop();
+    void emit(const std::string& line);
+
+public:
+    InfluxWriter(const std::string& url, const std::vector<std::string>& tag_keys);
+    ~InfluxWriter() override;
+
+    InfluxWriter(const InfluxWriter&) = delete;
+    InfluxWriter& operator=(const InfluxWriter&) = delete;
+
+    bool start(std::string& error) override;
+    // 停止前会尽量写完队列中的数据
+    void stop() override;
+
+    // 处理一条日志记录，只有session_started/session_completed/monitoring_completed会产生输出
+    void write_record(const JsonObject& record, const std::string& line) override;
+    std::string summary() const override;
+
+    int64_t written_count() const { return written_count_.load(); }
+    int64_t failed_count() const { return failed_count_.load(); }
+
+    static bool is_influx_url(const std::string& url);
+
+    // 行协议转义
+    static std::string escape_key(const std::string& text);
+    static std::string escape_field_string(const std::string& text);
+};
@@ -0,0 +1,389 @@
+#include "inject.h"
+#include "cli_utils.h"
+#include "convergence_monitor.h"
+#include <cerrno>
+#include <climits>
+#include <cstdio>
+#include <cstring>
+#include <getopt.h>
+#include <iostream>
+#include <memory>
+#include <net/if.h>
+#include <linux/netlink.h>
+#include <linux/rtnetlink.h>
+#include <linux/pkt_sched.h>
+#include <sys/socket.h>
+#include <unistd.h>
+
+namespace {
+
+constexpr size_t REQUEST_BUFFER_SIZE = 512;
+
+void add_attr(struct nlmsghdr* nlh, size_t max_len, int type, const void* data, size_t data_len) {
+    size_t len = RTA_LENGTH(data_len);
+    struct rtattr* rta = reinterpret_cast<struct rtattr*>(
+        reinterpret_cast<char*>(nlh) + NLMSG_ALIGN(nlh->nlmsg_len));
+    if (NLMSG_ALIGN(nlh->nlmsg_len) + RTA_ALIGN(len) > max_len) {
+        return;
+    }
+    rta->rta_type = type;
+    rta->rta_len = len;
+    if (data_len > 0) {
+        memcpy(RTA_DATA(rta), data, data_len);
+    }
+    nlh->nlmsg_len = NLMSG_ALIGN(nlh->nlmsg_len) + RTA_ALIGN(len);
+}
+
+struct rtattr* begin_nested(struct nlmsghdr* nlh, size_t max_len, int type) {
+    struct rtattr* nest = reinterpret_cast<struct rtattr*>(
+        reinterpret_cast<char*>(nlh) + NLMSG_ALIGN(nlh->nlmsg_len));
+    add_attr(nlh, max_len, type, nullptr, 0);
+    return nest;
+}
+
+void end_nested(struct nlmsghdr* nlh, struct rtattr* nest) {
+    nest->rta_len = reinterpret_cast<char*>(nlh) + nlh->nlmsg_len - reinterpret_cast<char*>(nest);
+}
+
+} // namespace
+
+NetemInjector::NetemInjector() = default;
+
+NetemInjector::~NetemInjector() {
+    if (fd_ >= 0) {
+        close(fd_);
+    }
+}
+
+bool NetemInjector::load_psched_clock() {
+    // 与iproute2的tc_core_init一致
+    FILE* fp = fopen("/proc/net/psched", "r");
+    if (fp == nullptr) {
+        return false;
+    }
+
+    unsigned int t2us = 0, us2t = 0, clock_res = 0;
+    int matched = fscanf(fp, "%08x%08x%08x", &t2us, &us2t, &clock_res);
+    fclose(fp);
+    if (matched != 3 || us2t == 0) {
+        return false;
+    }
+
+    if (clock_res == 1000000000) {
+        t2us = us2t;
+    }
+    double clock_factor = static_cast<double>(clock_res) / 1000000.0;
+    tick_in_usec_ = static_cast<double>(t2us) / us2t * clock_factor;
+    return true;
+}
+
+bool NetemInjector::open(std::string& error) {
+    fd_ = socket(AF_NETLINK, SOCK_RAW | SOCK_CLOEXEC, NETLINK_ROUTE);
+    if (fd_ < 0) {
+        error = "Failed to create netlink socket: " + std::string(strerror(errno));
+        return false;
+    }
+
+    struct sockaddr_nl addr;
+    memset(&addr, 0, sizeof(addr));
+    addr.nl_family = AF_NETLINK;
+    if (bind(fd_, reinterpret_cast<struct sockaddr*>(&addr), sizeof(addr)) < 0) {
+        error = "Failed to bind netlink socket: " + std::string(strerror(errno));
+        return false;
+    }
+
+    if (!load_psched_clock()) {
+        std::cerr << "⚠️  无法读取 /proc/net/psched，按1tick=1us计算延迟\n";
+    }
+    return true;
+}
+
+bool NetemInjector::send_and_ack(void* message, size_t length, std::string& error) {
+    if (send(fd_, message, length, 0) < 0) {
+        error = "netlink send: " + std::string(strerror(errno));
+        return false;
+    }
+
+    char buffer[4096];
+    ssize_t len = recv(fd_, buffer, sizeof(buffer), 0);
+    if (len < 0) {
+        error = "netlink recv: " + std::string(strerror(errno));
+        return false;
+    }
+
+    for (struct nlmsghdr* nlh = reinterpret_cast<struct nlmsghdr*>(buffer);
+         NLMSG_OK(nlh, len); nlh = NLMSG_NEXT(nlh, len)) {
+        if (nlh->nlmsg_type == NLMSG_ERROR) {
+            struct nlmsgerr* err = static_cast<struct nlmsgerr*>(NLMSG_DATA(nlh));
+            if (err->error != 0) {
+                error = strerror(-err->error);
+                return false;
+            }
+            return true;
+        }
+    }
+    return true;
+}
+
+bool NetemInjector::apply(const NetemSpec& spec, std::string& error) {
+    unsigned int ifindex = if_nametoindex(spec.interface.c_str());
+    if (ifindex == 0) {
+        error = "unknown interface " + spec.interface;
+        return false;
+    }
+
+    alignas(struct nlmsghdr) char buffer[REQUEST_BUFFER_SIZE];
+    memset(buffer, 0, sizeof(buffer));
+
+    struct nlmsghdr* nlh = reinterpret_cast<struct nlmsghdr*>(buffer);
+    nlh->nlmsg_len = NLMSG_LENGTH(sizeof(struct tcmsg));
+    nlh->nlmsg_type = RTM_NEWQDISC;
+    nlh->nlmsg_flags = NLM_F_REQUEST | NLM_F_ACK | NLM_F_CREATE | NLM_F_REPLACE;
+    nlh->nlmsg_seq = ++seq_;
+
+    struct tcmsg* tcm = static_cast<struct tcmsg*>(NLMSG_DATA(nlh));
+    tcm->tcm_family = AF_UNSPEC;
+    tcm->tcm_ifindex = static_cast<int>(ifindex);
+    tcm->tcm_parent = TC_H_ROOT;
+    tcm->tcm_handle = 0;
+
+    const char kind[] = "netem";
+    add_attr(nlh, sizeof(buffer), TCA_KIND, kind, sizeof(kind));
+
+    // netem的TCA_OPTIONS以tc_netem_qopt开头，后面跟随嵌套属性
+    struct tc_netem_qopt qopt;
+    memset(&qopt, 0, sizeof(qopt));
+    qopt.latency = static_cast<uint32_t>(spec.delay_us * tick_in_usec_);
+    qopt.jitter = static_cast<uint32_t>(spec.jitter_us * tick_in_usec_);
+    qopt.limit = spec.limit;
+    qopt.loss = static_cast<uint32_t>(spec.loss_percent / 100.0 * UINT32_MAX);
+
+    struct rtattr* options = begin_nested(nlh, sizeof(buffer), TCA_OPTIONS);
+    memcpy(reinterpret_cast<char*>(nlh) + nlh->nlmsg_len, &qopt, sizeof(qopt));
+    nlh->nlmsg_len += NLMSG_ALIGN(sizeof(qopt));
+
+    int64_t latency_ns = spec.delay_us * 1000;
+    int64_t jitter_ns = spec.jitter_us * 1000;
+    add_attr(nlh, sizeof(buffer), TCA_NETEM_LATENCY64, &latency_ns, sizeof(latency_ns));
+    add_attr(nlh, sizeof(buffer), TCA_NETEM_JITTER64, &jitter_ns, sizeof(jitter_ns));
+    end_nested(nlh, options);
+
+    return send_and_ack(buffer, nlh->nlmsg_len, error);
+}
+
+bool NetemInjector::clear(const std::string& interface, std::string& error) {
+    unsigned int ifindex = if_nametoindex(interface.c_str());
+    if (ifindex == 0) {
+        error = "unknown interface " + interface;
+        return false;
+    }
+
+    alignas(struct nlmsghdr) char buffer[REQUEST_BUFFER_SIZE];
+    memset(buffer, 0, sizeof(buffer));
+
+    struct nlmsghdr* nlh = reinterpret_cast<struct nlmsghdr*>(buffer);
+    nlh->nlmsg_len = NLMSG_LENGTH(sizeof(struct tcmsg));
+    nlh->nlmsg_type = RTM_DELQDISC;
+    nlh->nlmsg_flags = NLM_F_REQUEST | NLM_F_ACK;
+    nlh->nlmsg_seq = ++seq_;
+
+    struct tcmsg* tcm = static_cast<struct tcmsg*>(NLMSG_DATA(nlh));
+    tcm->tcm_family = AF_UNSPEC;
+    tcm->tcm_ifindex = static_cast<int>(ifindex);
+    tcm->tcm_parent = TC_H_ROOT;
+
+    return send_and_ack(buffer, nlh->nlmsg_len, error);
+}
+
+bool NetemInjector::set_link_state(const std::string& interface, bool up, std::string& error) {
+    unsigned int ifindex = if_nametoindex(interface.c_str());
+    if (ifindex == 0) {
+        error = "unknown interface " + interface;
+        return false;
+    }
+
+    alignas(struct nlmsghdr) char buffer[REQUEST_BUFFER_SIZE];
+    memset(buffer, 0, sizeof(buffer));
+
+    struct nlmsghdr* nlh = reinterpret_cast<struct nlmsghdr*>(buffer);
+    nlh->nlmsg_len = NLMSG_LENGTH(sizeof(struct ifinfomsg));
+    nlh->nlmsg_type = RTM_NEWLINK;
+    nlh->nlmsg_flags = NLM_F_REQUEST | NLM_F_ACK;
+    nlh->nlmsg_seq = ++seq_;
+
+    struct ifinfomsg* ifi = static_cast<struct ifinfomsg*>(NLMSG_DATA(nlh));
+    ifi->ifi_family = AF_UNSPEC;
+    ifi->ifi_index = static_cast<int>(ifindex);
+    ifi->ifi_flags = up ? IFF_UP : 0;
+    ifi->ifi_change = IFF_UP;
+
+    return send_and_ack(buffer, nlh->nlmsg_len, error);
+}
+
+namespace {
+
+void print_inject_usage(const char* program_name) {
+    std::cout << "用法: " << program_name << " inject --iface IF [netem参数] [选项]\n\n";
+    std::cout << "在本机接口上周期性地施加/移除netem故障，并在同一进程内测量收敛时间\n\n";
+    std::cout << "选项:\n";
+    std::cout << "      --iface NAME          目标接口\n";
+    std::cout << "      --delay DURATION      时延(如 50ms)\n";
+    std::cout << "      --jitter DURATION     抖动(如 5ms)\n";
+    std::cout << "      --loss PERCENT        丢包率(如 10%)\n";
+    std::cout << "      --hold DURATION       每次故障保持时间(默认30s)\n";
+    std::cout << "      --interval DURATION   故障移除后到下一次注入的间隔(默认同--hold)\n";
+    std::cout << "      --repeat N            注入次数(默认1)\n";
+    std::cout << "      --no-monitor          只注入故障，不启动收敛监控\n";
+    std::cout << "  -t, --threshold MS        收敛判断阈值(默认3000ms)\n";
+    std::cout << "  -r, --router-name NAME    路由器名称\n";
+    std::cout << "  -l, --log-path PATH       日志文件路径\n";
+    std::cout << "  -h, --help                显示此帮助信息\n";
+}
+
+enum InjectOption {
+    OPT_IFACE = 1000,
+    OPT_DELAY,
+    OPT_JITTER,
+    OPT_LOSS,
+    OPT_HOLD,
+    OPT_INTERVAL,
+    OPT_REPEAT,
+    OPT_NO_MONITOR,
+};
+
+std::string format_spec(const NetemSpec& spec) {
+    std::string text = "delay " + std::to_string(spec.delay_us / 1000) + "ms";
+    if (spec.jitter_us > 0) {
+        text += " " + std::to_string(spec.jitter_us / 1000) + "ms";
+    }
+    if (spec.loss_percent > 0) {
+        text += " loss " + std::to_string(spec.loss_percent) + "%";
+    }
+    return text;
+}
+
+} // namespace
+
+int inject_main(int argc, char* argv[]) {
+    NetemSpec spec;
+    int64_t hold_ms = 30000;
+    int64_t interval_ms = -1;
+    int repeat = 1;
+    bool with_monitor = true;
+    MonitorConfig config;
+
+    static struct option long_options[] = {
+        {"iface", required_argument, 0, OPT_IFACE},
+        {"delay", required_argument, 0, OPT_DELAY},
+        {"jitter", required_argument, 0, OPT_JITTER},
+        {"loss", required_argument, 0, OPT_LOSS},
+        {"hold", required_argument, 0, OPT_HOLD},
+        {"interval", required_argument, 0, OPT_INTERVAL},
+        {"repeat", required_argument, 0, OPT_REPEAT},
+        {"no-monitor", no_argument, 0, OPT_NO_MONITOR},
+        {"threshold", required_argument, 0, 't'},
+        {"router-name", required_argument, 0, 'r'},
+        {"log-path", required_argument, 0, 'l'},
+        {"help", no_argument, 0, 'h'},
+        {0, 0, 0, 0}
+    };
+
+    optind = 1;
+    int c;
+    while ((c = getopt_long(argc, argv, "t:r:l:h", long_options, nullptr)) != -1) {
+        switch (c) {
+            case OPT_IFACE: spec.interface = optarg; break;
+            case OPT_DELAY: spec.delay_us = parse_duration_ms(optarg) * 1000; break;
+            case OPT_JITTER: spec.jitter_us = parse_duration_ms(optarg) * 1000; break;
+            case OPT_LOSS: spec.loss_percent = parse_percent(optarg); break;
+            case OPT_HOLD: hold_ms = parse_duration_ms(optarg); break;
+            case OPT_INTERVAL: interval_ms = parse_duration_ms(optarg); break;
+            case OPT_REPEAT: repeat = std::stoi(optarg); break;
+            case OPT_NO_MONITOR: with_monitor = false; break;
+            case 't': config.convergence_threshold_ms = std::stoll(optarg); break;
+            case 'r': config.router_name = optarg; break;
+            case 'l': config.log_path = optarg; break;
+            case 'h': print_inject_usage(argv[0]); return 0;
+            default:  print_inject_usage(argv[0]); return 1;
+        }
+    }
+
+    if (spec.interface.empty()) {
+        std::cerr << "❌ 错误: 必须指定 --iface\n";
+        return 1;
+    }
+    if (spec.delay_us < 0 || spec.jitter_us < 0 || spec.loss_percent < 0 || hold_ms <= 0 || repeat <= 0) {
+        std::cerr << "❌ 错误: 无效的故障参数\n";
+        return 1;
+    }
+    if (interval_ms < 0) {
+        interval_ms = hold_ms;
+    }
+    if (config.router_name.empty()) {
+        config.router_name = "injector_" + spec.interface;
+    }
+
+    NetemInjector injector;
+    std::string error;
+    if (!injector.open(error)) {
+        std::cerr << "❌ " << error << "\n";
+        return 1;
+    }
+
+    install_stop_signal_handlers();
+
+    std::unique_ptr<ConvergenceMonitor> monitor;
+    if (with_monitor) {
+        monitor = std::make_unique<ConvergenceMonitor>(config);
+        monitor->start_monitoring();
+    }
+
+    std::cout << "💉 故障注入: dev " << spec.interface << " root netem " << format_spec(spec)
+              << ", 保持" << hold_ms << "ms, 间隔" << interval_ms << "ms, 共" << repeat << "次\n";
+
+    int exit_code = 0;
+    for (int i = 1; i <= repeat && !stop_requested(); ++i) {
+        if (!injector.apply(spec, error)) {
+            std::cerr << "❌ 施加netem失败: " << error << "\n";
+            exit_code = 1;
+            break;
+        }
+        std::cout << "💉 [" << i << "/" << repeat << "] 已施加netem\n";
+        if (monitor) {
+            monitor->log_external_event("fault_injected", {
+                {"interface", spec.interface},
+                {"netem", format_spec(spec)},
+                {"iteration", std::to_string(i)},
+            });
+        }
+
+        bool keep_going = interruptible_sleep_ms(hold_ms);
+
+        if (!injector.clear(spec.interface, error)) {
+            std::cerr << "❌ 移除netem失败: " << error << "\n";
+            exit_code = 1;
+            break;
+        }
+        std::cout << "🧹 [" << i << "/" << repeat << "] 已移除netem\n";
+        if (monitor) {
+            monitor->log_external_event("fault_cleared", {
+                {"interface", spec.interface},
+                {"iteration", std::to_string(i)},
+            });
+        }
+
+        if (!keep_going || (i < repeat && !interruptible_sleep_ms(interval_ms))) {
+            break;
+        }
+    }
+
+    if (monitor) {
+        // 等待最后一次移除故障后的收敛
+        if (exit_code == 0 && !stop_requested()) {
+            interruptible_sleep_ms(config.convergence_threshold_ms + 1000);
+        }
+        monitor->stop_monitoring();
+    }
+
+    return exit_code;
+}
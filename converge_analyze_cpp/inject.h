@@ -0,0 +1,41 @@
+#pragma once
+
+#include <cstdint>
+#include <string>
+
+// netem故障参数
+struct NetemSpec {
+    std::string interface;
+    int64_t delay_us = 0;
+    int64_t jitter_us = 0;
+    double loss_percent = 0.0;
+    uint32_t limit = 1000;
+};
+
+// 通过rtnetlink直接下发/删除root netem qdisc（等价于 tc qdisc replace/del dev IF root netem ...）
+class NetemInjector {
+private:
+    int fd_ = -1;
+    uint32_t seq_ = 0;
+    double tick_in_usec_ = 1.0;
+
+    bool load_psched_clock();
+    bool send_and_ack(void* message, size_t length, std::string& error);
+
+public:
+    NetemInjector();
+    ~NetemInjector();
+
+    NetemInjector(const NetemInjector&) = delete;
+    NetemInjector& operator=(const NetemInjector&) = delete;
+
+    bool open(std::string& error);
+    bool apply(const NetemSpec& spec, std::string& error);
+    bool clear(const std::string& interface, std::string& error);
+
+    // 设置接口管理状态（等价于 ip link set dev IF up/down），用于链路中断故障
+    bool set_link_state(const std::string& interface, bool up, std::string& error);
+};
+
+// inject 子命令入口
+int inject_main(int argc, char* argv[]);
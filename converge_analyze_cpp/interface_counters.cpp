@@ -0,0 +1,131 @@
+#include "interface_counters.h"
+#include <cerrno>
+#include <cstring>
+#include <fstream>
+#include <sstream>
+
+bool InterfaceCounters::zero() const {
+    return rx_bytes == 0 && rx_packets == 0 && rx_errors == 0 && rx_dropped == 0 &&
+           tx_bytes == 0 && tx_packets == 0 && tx_errors == 0 && tx_dropped == 0;
+}
+
+void InterfaceCounters::add_to_json(JsonObject& obj, const std::string& prefix, const std::string& suffix) const {
+    obj[prefix + "rx_bytes" + suffix] = rx_bytes;
+    obj[prefix + "rx_packets" + suffix] = rx_packets;
+    obj[prefix + "rx_errors" + suffix] = rx_errors;
+    obj[prefix + "rx_dropped" + suffix] = rx_dropped;
+    obj[prefix + "tx_bytes" + suffix] = tx_bytes;
+    obj[prefix + "tx_packets" + suffix] = tx_packets;
+    obj[prefix + "tx_errors" + suffix] = tx_errors;
+    obj[prefix + "tx_dropped" + suffix] = tx_dropped;
+}
+
+InterfaceCounters InterfaceCounterDelta::find(const std::string& interface) const {
+    for (const auto& pair : changed) {
+        if (pair.first == interface) {
+            return pair.second;
+        }
+    }
+    return InterfaceCounters();
+}
+
+void InterfaceCounterDelta::add_to_json(JsonObject& obj, const std::string& trigger_interface) const {
+    obj["counter_interval_ms"] = interval_ms;
+    if (!trigger_interface.empty()) {
+        obj["trigger_interface"] = trigger_interface;
+        find(trigger_interface).add_to_json(obj, "trigger_interface_", "_delta");
+    }
+}
+
+bool InterfaceCounterReader::read(InterfaceCounterSnapshot& snapshot, std::string& error) {
+    std::ifstream file("/proc/net/dev");
+    if (!file) {
+        error = std::string("/proc/net/dev: ") + strerror(errno);
+        return false;
+    }
+    std::stringstream buffer;
+    buffer << file.rdbuf();
+    if (!parse_proc_net_dev(buffer.str(), snapshot)) {
+        error = "/proc/net/dev: unexpected format";
+        return false;
+    }
+    return true;
+}
+
+bool InterfaceCounterReader::parse_proc_net_dev(const std::string& text, InterfaceCounterSnapshot& snapshot) {
+    // 前两行为表头；每行 "  eth0: rx_bytes rx_packets errs drop fifo frame compressed multicast tx_bytes ..."
+    std::istringstream lines(text);
+    std::string line;
+    bool parsed = false;
+    while (std::getline(lines, line)) {
+        size_t colon = line.find(':');
+        if (colon == std::string::npos) {
+            continue;
+        }
+        std::string name = line.substr(0, colon);
+        name.erase(0, name.find_first_not_of(' '));
+
+        std::istringstream fields(line.substr(colon + 1));
+        int64_t values[16];
+        int count = 0;
+        while (count < 16 && fields >> values[count]) {
+            count++;
+        }
+        if (count < 16) {
+            continue;
+        }
+
+        InterfaceCounters counters;
+        counters.rx_bytes = values[0];
+        counters.rx_packets = values[1];
+        counters.rx_errors = values[2];
+        counters.rx_dropped = values[3];
+        counters.tx_bytes = values[8];
+        counters.tx_packets = values[9];
+        counters.tx_errors = values[10];
+        counters.tx_dropped = values[11];
+        snapshot[name] = counters;
+        parsed = true;
+    }
+    return parsed;
+}
+
+InterfaceCounterSnapshot InterfaceCounterReader::delta(const InterfaceCounterSnapshot& before,
+                                                       const InterfaceCounterSnapshot& after) {
+    InterfaceCounterSnapshot deltas;
+    for (const auto& pair : after) {
+        auto it = before.find(pair.first);
+        InterfaceCounters base = it != before.end() ? it->second : InterfaceCounters();
+        const InterfaceCounters& now = pair.second;
+        if (now.rx_bytes < base.rx_bytes || now.tx_bytes < base.tx_bytes) {
+            base = InterfaceCounters();
+        }
+        InterfaceCounters diff;
+        diff.rx_bytes = now.rx_bytes - base.rx_bytes;
+        diff.rx_packets = now.rx_packets - base.rx_packets;
+        diff.rx_errors = now.rx_errors - base.rx_errors;
+        diff.rx_dropped = now.rx_dropped - base.rx_dropped;
+        diff.tx_bytes = now.tx_bytes - base.tx_bytes;
+        diff.tx_packets = now.tx_packets - base.tx_packets;
+        diff.tx_errors = now.tx_errors - base.tx_errors;
+        diff.tx_dropped = now.tx_dropped - base.tx_dropped;
+        deltas[pair.first] = diff;
+    }
+    return deltas;
+}
+
+bool InterfaceCounterReader::measure(const InterfaceCounterSnapshot& before, int64_t interval_ms,
+                                     InterfaceCounterDelta& result, std::string& error) {
+    InterfaceCounterSnapshot after;
+    if (!read(after, error)) {
+        return false;
+    }
+    result.interval_ms = interval_ms;
+    result.changed.clear();
+    for (const auto& pair : delta(before, after)) {
+        if (!pair.second.zero()) {
+            result.changed.push_back(pair);
+        }
+    }
+    return true;
+}
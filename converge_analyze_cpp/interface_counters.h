@@ -0,0 +1,55 @@
+#pragma once
+
+#include "logger.h"
+#include <cstdint>
+#include <map>
+#include <string>
+#include <utility>
+#include <vector>
+
+// 一个接口的收发计数(/proc/net/dev)
+struct InterfaceCounters {
+    int64_t rx_bytes = 0;
+    int64_t rx_packets = 0;
+    int64_t rx_errors = 0;
+    int64_t rx_dropped = 0;
+    int64_t tx_bytes = 0;
+    int64_t tx_packets = 0;
+    int64_t tx_errors = 0;
+    int64_t tx_dropped = 0;
+
+    bool zero() const;
+    // 字段名加上前缀与后缀，如 trigger_interface_rx_dropped_delta
+    void add_to_json(JsonObject& obj, const std::string& prefix, const std::string& suffix) const;
+};
+
+// 接口名 -> 计数
+using InterfaceCounterSnapshot = std::map<std::string, InterfaceCounters>;
+
+// 会话期间有变化的接口的计数差值
+struct InterfaceCounterDelta {
+    int64_t interval_ms = 0;
+    std::vector<std::pair<std::string, InterfaceCounters>> changed;
+
+    // 期间没有变化的接口为全0
+    InterfaceCounters find(const std::string& interface) const;
+    // counter_interval_ms，trigger_interface非空时附上该接口的trigger_interface_*_delta
+    void add_to_json(JsonObject& obj, const std::string& trigger_interface) const;
+};
+
+// 会话触发与收敛时的接口计数快照(--interface-counters)，读取当前网络命名空间的/proc/net/dev
+class InterfaceCounterReader {
+public:
+    static bool read(InterfaceCounterSnapshot& snapshot, std::string& error);
+
+    static bool parse_proc_net_dev(const std::string& text, InterfaceCounterSnapshot& snapshot);
+
+    // 逐接口计算after - before；期间新建的接口从0算起，被删除的接口不列出，
+    // 计数变小(接口被删除后同名重建)时以after为准
+    static InterfaceCounterSnapshot delta(const InterfaceCounterSnapshot& before,
+                                          const InterfaceCounterSnapshot& after);
+
+    // 读取当前计数并与before比较，只保留有变化的接口
+    static bool measure(const InterfaceCounterSnapshot& before, int64_t interval_ms, InterfaceCounterDelta& result,
+                        std::string& error);
+};
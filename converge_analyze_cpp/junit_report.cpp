@@ -0,0 +1,92 @@
+#include "junit_report.h"
+#include <fstream>
+#include <iomanip>
+#include <sstream>
+
+std::string JUnitReport::escape_xml(const std::string& str) {
+    std::string escaped;
+    escaped.reserve(str.size());
+
+    for (char c : str) {
+        switch (c) {
+            case '&':  escaped += "&amp;"; break;
+            case '<':  escaped += "&lt;"; break;
+            case '>':  escaped += "&gt;"; break;
+            case '"':  escaped += "&quot;"; break;
+            case '\'': escaped += "&apos;"; break;
+            default:   escaped += c; break;
+        }
+    }
+
+    return escaped;
+}
+
+bool JUnitReport::write(const std::string& path,
+                        const std::string& suite_name,
+                        const std::vector<SessionSummary>& sessions,
+                        int64_t sla_ms,
+                        std::string& error) {
+    std::ostringstream cases;
+    int failures = 0;
+    int skipped = 0;
+    double total_seconds = 0.0;
+
+    for (const auto& session : sessions) {
+        int64_t convergence = session.convergence_time_ms.value_or(0);
+        double seconds = convergence / 1000.0;
+        total_seconds += seconds;
+
+        cases << "    <testcase classname=\"" << escape_xml(suite_name)
+              << "\" name=\"session_" << session.session_id
+              << "\" time=\"" << std::fixed << std::setprecision(3) << seconds << "\">\n";
+
+        std::string failure;
+        if (session.forced) {
+            // 监听结束时仍未收敛的会话没有结果，记为skipped而非失败
+            skipped++;
+            cases << "      <skipped message=\"session still open when monitoring stopped\"/>\n";
+        } else if (session.timed_out) {
+            failure = "session timed out before convergence";
+        } else if (sla_ms > 0 && convergence > sla_ms) {
+            failure = "convergence " + std::to_string(convergence) +
+                      "ms exceeds SLA " + std::to_string(sla_ms) + "ms";
+        }
+
+        if (!failure.empty()) {
+            failures++;
+            cases << "      <failure message=\"" << escape_xml(failure)
+                  << "\" type=\"ConvergenceSLA\"/>\n";
+        }
+
+        // 触发信息作为system-out附带，便于在CI界面中查看
+        cases << "      <system-out>";
+        for (const auto& pair : session.trigger_info) {
+            cases << escape_xml(pair.first) << "=" << escape_xml(pair.second) << " ";
+        }
+        cases << "route_events=" << session.route_events
+              << " duration_ms=" << session.duration_ms << "</system-out>\n";
+        cases << "    </testcase>\n";
+    }
+
+    std::ofstream out(path, std::ios::out | std::ios::trunc);
+    if (!out.is_open()) {
+        error = "无法写入JUnit报告: " + path;
+        return false;
+    }
+
+    out << "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n";
+    out << "<testsuites>\n";
+    out << "  <testsuite name=\"" << escape_xml(suite_name)
+        << "\" tests=\"" << sessions.size()
+        << "\" failures=\"" << failures
+        << "\" skipped=\"" << skipped
+        << "\" errors=\"0\" time=\"" << std::fixed << std::setprecision(3) << total_seconds << "\">\n";
+    out << "    <properties>\n";
+    out << "      <property name=\"sla_ms\" value=\"" << sla_ms << "\"/>\n";
+    out << "    </properties>\n";
+    out << cases.str();
+    out << "  </testsuite>\n";
+    out << "</testsuites>\n";
+
+    return out.good();
+}
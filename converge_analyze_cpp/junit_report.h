@@ -0,0 +1,19 @@
+#pragma once
+
+#include <string>
+#include <vector>
+#include "convergence_monitor.h"
+
+// JUnit风格XML报告：每个完成的会话对应一个testcase
+class JUnitReport {
+public:
+    // sla_ms为0时仅超时会话判定失败；监听结束时被强制结束的会话记为skipped
+    static bool write(const std::string& path,
+                      const std::string& suite_name,
+                      const std::vector<SessionSummary>& sessions,
+                      int64_t sla_ms,
+                      std::string& error);
+
+private:
+    static std::string escape_xml(const std::string& str);
+};
@@ -0,0 +1,654 @@
+#include "kafka_producer.h"
+#include <algorithm>
+#include <chrono>
+#include <cstring>
+#include <cerrno>
+#include <iostream>
+#include <netdb.h>
+#include <sys/socket.h>
+#include <sys/time.h>
+#include <unistd.h>
+
+namespace {
+
+constexpr int16_t API_PRODUCE = 0;
+constexpr int16_t API_METADATA = 3;
+constexpr int CONNECT_TIMEOUT_MS = 3000;
+const std::string CLIENT_ID = "convergence-analyzer";
+
+// 大端序编码
+class Encoder {
+public:
+    std::string buf;
+
+    void int8(int8_t v) { buf.push_back(static_cast<char>(v)); }
+    void int16(int16_t v) { put(static_cast<uint64_t>(static_cast<uint16_t>(v)), 2); }
+    void int32(int32_t v) { put(static_cast<uint64_t>(static_cast<uint32_t>(v)), 4); }
+    void int64(int64_t v) { put(static_cast<uint64_t>(v), 8); }
+    void string(const std::string& s) {
+        int16(static_cast<int16_t>(s.size()));
+        buf += s;
+    }
+    void null_string() { int16(-1); }
+    void bytes(const std::string& s) {
+        int32(static_cast<int32_t>(s.size()));
+        buf += s;
+    }
+    // zigzag + LEB128
+    void varint(int64_t v) {
+        uint64_t z = (static_cast<uint64_t>(v) << 1) ^ static_cast<uint64_t>(v >> 63);
+        while (z >= 0x80) {
+            buf.push_back(static_cast<char>((z & 0x7f) | 0x80));
+            z >>= 7;
+        }
+        buf.push_back(static_cast<char>(z));
+    }
+
+private:
+    void put(uint64_t v, int size) {
+        for (int i = size - 1; i >= 0; --i) {
+            buf.push_back(static_cast<char>((v >> (i * 8)) & 0xff));
+        }
+    }
+};
+
+class Decoder {
+    const std::string& data_;
+    size_t pos_ = 0;
+
+public:
+    bool ok = true;
+
+    explicit Decoder(const std::string& data) : data_(data) {}
+
+    int64_t read(int size) {
+        if (pos_ + size > data_.size()) {
+            ok = false;
+            return 0;
+        }
+        uint64_t v = 0;
+        for (int i = 0; i < size; ++i) {
+            v = (v << 8) | static_cast<uint8_t>(data_[pos_++]);
+        }
+        // 符号扩展
+        if (size < 8 && (v & (1ULL << (size * 8 - 1)))) {
+            v |= ~0ULL << (size * 8);
+        }
+        return static_cast<int64_t>(v);
+    }
+    int16_t int16() { return static_cast<int16_t>(read(2)); }
+    int32_t int32() { return static_cast<int32_t>(read(4)); }
+    int64_t int64() { return read(8); }
+    std::string string() {
+        int16_t len = int16();
+        if (len < 0 || !ok) {
+            return "";
+        }
+        if (pos_ + len > data_.size()) {
+            ok = false;
+            return "";
+        }
+        std::string s = data_.substr(pos_, len);
+        pos_ += len;
+        return s;
+    }
+    void skip_int32_array() {
+        int32_t n = int32();
+        for (int32_t i = 0; i < n && ok; ++i) {
+            int32();
+        }
+    }
+};
+
+uint32_t crc32c(const std::string& data) {
+    static uint32_t table[256];
+    static bool initialized = false;
+    if (!initialized) {
+        for (uint32_t i = 0; i < 256; ++i) {
+            uint32_t c = i;
+            for (int k = 0; k < 8; ++k) {
+                c = (c & 1) ? (c >> 1) ^ 0x82f63b78 : c >> 1;
+            }
+            table[i] = c;
+        }
+        initialized = true;
+    }
+
+    uint32_t crc = 0xffffffff;
+    for (unsigned char ch : data) {
+        crc = table[(crc ^ ch) & 0xff] ^ (crc >> 8);
+    }
+    return crc ^ 0xffffffff;
+}
+
+std::string kafka_error_name(int16_t code) {
+    switch (code) {
+        case 3: return "UNKNOWN_TOPIC_OR_PARTITION";
+        case 5: return "LEADER_NOT_AVAILABLE";
+        case 6: return "NOT_LEADER_FOR_PARTITION";
+        case 7: return "REQUEST_TIMED_OUT";
+        case 10: return "MESSAGE_TOO_LARGE";
+        case 29: return "TOPIC_AUTHORIZATION_FAILED";
+        case 35: return "UNSUPPORTED_VERSION";
+        default: return "error " + std::to_string(code);
+    }
+}
+
+bool send_all(int fd, const std::string& data) {
+    size_t sent = 0;
+    while (sent < data.size()) {
+        ssize_t n = send(fd, data.data() + sent, data.size() - sent, MSG_NOSIGNAL);
+        if (n <= 0) {
+            return false;
+        }
+        sent += static_cast<size_t>(n);
+    }
+    return true;
+}
+
+bool recv_exact(int fd, char* buffer, size_t length) {
+    size_t received = 0;
+    while (received < length) {
+        ssize_t n = recv(fd, buffer + received, length - received, 0);
+        if (n <= 0) {
+            return false;
+        }
+        received += static_cast<size_t>(n);
+    }
+    return true;
+}
+
+int connect_to(const std::string& host, const std::string& port, std::string& error) {
+    struct addrinfo hints;
+    memset(&hints, 0, sizeof(hints));
+    hints.ai_family = AF_UNSPEC;
+    hints.ai_socktype = SOCK_STREAM;
+
+    struct addrinfo* result = nullptr;
+    int rc = getaddrinfo(host.c_str(), port.c_str(), &hints, &result);
+    if (rc != 0) {
+        error = "resolve " + host + ": " + gai_strerror(rc);
+        return -1;
+    }
+
+    struct timeval tv;
+    tv.tv_sec = CONNECT_TIMEOUT_MS / 1000;
+    tv.tv_usec = (CONNECT_TIMEOUT_MS % 1000) * 1000;
+
+    int fd = -1;
+    for (struct addrinfo* ai = result; ai != nullptr; ai = ai->ai_next) {
+        fd = socket(ai->ai_family, ai->ai_socktype | SOCK_CLOEXEC, ai->ai_protocol);
+        if (fd < 0) {
+            continue;
+        }
+        setsockopt(fd, SOL_SOCKET, SO_RCVTIMEO, &tv, sizeof(tv));
+        setsockopt(fd, SOL_SOCKET, SO_SNDTIMEO, &tv, sizeof(tv));
+        if (connect(fd, ai->ai_addr, ai->ai_addrlen) == 0) {
+            break;
+        }
+        close(fd);
+        fd = -1;
+    }
+    freeaddrinfo(result);
+
+    if (fd < 0) {
+        error = "connect " + host + ":" + port + ": " + strerror(errno);
+    }
+    return fd;
+}
+
+} // namespace
+
+bool KafkaProducer::parse_url(const std::string& url,
+                              std::vector<std::pair<std::string, std::string>>& bootstrap,
+                              std::string& topic) {
+    const std::string scheme = "kafka://";
+    if (url.compare(0, scheme.size(), scheme) != 0) {
+        return false;
+    }
+
+    std::string rest = url.substr(scheme.size());
+    size_t slash = rest.find('/');
+    if (slash == std::string::npos) {
+        return false;
+    }
+    topic = rest.substr(slash + 1);
+    if (topic.empty() || topic.find('/') != std::string::npos) {
+        return false;
+    }
+
+    bootstrap.clear();
+    std::string brokers = rest.substr(0, slash);
+    size_t start = 0;
+    while (start <= brokers.size()) {
+        size_t comma = brokers.find(',', start);
+        std::string broker = brokers.substr(start, comma == std::string::npos ? std::string::npos : comma - start);
+        if (broker.empty()) {
+            return false;
+        }
+        size_t colon = broker.rfind(':');
+        if (colon == std::string::npos) {
+            bootstrap.emplace_back(broker, "9092");
+        } else {
+            bootstrap.emplace_back(broker.substr(0, colon), broker.substr(colon + 1));
+        }
+        if (comma == std::string::npos) {
+            break;
+        }
+        start = comma + 1;
+    }
+    return !bootstrap.empty();
+}
+
+int32_t KafkaProducer::murmur2(const std::string& data) {
+    const uint32_t seed = 0x9747b28c;
+    const uint32_t m = 0x5bd1e995;
+    const int r = 24;
+    const auto* bytes = reinterpret_cast<const uint8_t*>(data.data());
+    size_t length = data.size();
+
+    uint32_t h = seed ^ static_cast<uint32_t>(length);
+    size_t length4 = length / 4;
+    for (size_t i = 0; i < length4; ++i) {
+        size_t i4 = i * 4;
+        uint32_t k = bytes[i4] | (bytes[i4 + 1] << 8) | (bytes[i4 + 2] << 16) |
+                     (static_cast<uint32_t>(bytes[i4 + 3]) << 24);
+        k *= m;
+        k ^= k >> r;
+        k *= m;
+        h *= m;
+        h ^= k;
+    }
+
+    size_t tail = length & ~static_cast<size_t>(3);
+    switch (length % 4) {
+        case 3: h ^= static_cast<uint32_t>(bytes[tail + 2]) << 16; [[fallthrough]];
+        case 2: h ^= static_cast<uint32_t>(bytes[tail + 1]) << 8; [[fallthrough]];
+        case 1: h ^= bytes[tail]; h *= m;
+    }
+
+    h ^= h >> 13;
+    h *= m;
+    h ^= h >> 15;
+    return static_cast<int32_t>(h);
+}
+
+std::string KafkaProducer::encode_record_batch(const std::vector<Record>& records) {
+    int64_t first_timestamp = records.empty() ? 0 : records.front().timestamp_ms;
+    int64_t max_timestamp = first_timestamp;
+    for (const auto& record : records) {
+        max_timestamp = std::max(max_timestamp, record.timestamp_ms);
+    }
+
+    // CRC覆盖从attributes开始的部分
+    Encoder body;
+    body.int16(0);                                          // attributes: 无压缩
+    body.int32(static_cast<int32_t>(records.size()) - 1);   // last offset delta
+    body.int64(first_timestamp);
+    body.int64(max_timestamp);
+    body.int64(-1);                                         // producer id
+    body.int16(-1);                                         // producer epoch
+    body.int32(-1);                                         // base sequence
+    body.int32(static_cast<int32_t>(records.size()));
+
+    for (size_t i = 0; i < records.size(); ++i) {
+        const auto& record = records[i];
+        Encoder r;
+        r.int8(0);                                          // attributes
+        r.varint(record.timestamp_ms - first_timestamp);
+        r.varint(static_cast<int64_t>(i));                  // offset delta
+        if (record.key.empty()) {
+            r.varint(-1);
+        } else {
+            r.varint(static_cast<int64_t>(record.key.size()));
+            r.buf += record.key;
+        }
+        r.varint(static_cast<int64_t>(record.value.size()));
+        r.buf += record.value;
+        r.varint(0);                                        // headers
+
+        body.varint(static_cast<int64_t>(r.buf.size()));
+        body.buf += r.buf;
+    }
+
+    Encoder batch;
+    batch.int64(0);                                         // base offset
+    // batch length: leader epoch(4) + magic(1) + crc(4) + body
+    batch.int32(static_cast<int32_t>(4 + 1 + 4 + body.buf.size()));
+    batch.int32(-1);                                        // partition leader epoch
+    batch.int8(2);                                          // magic
+    batch.int32(static_cast<int32_t>(crc32c(body.buf)));
+    batch.buf += body.buf;
+    return batch.buf;
+}
+
+KafkaProducer::KafkaProducer(const std::vector<std::pair<std::string, std::string>>& bootstrap,
+                             const std::string& topic, const std::string& key_field)
+    : bootstrap_(bootstrap), topic_(topic), key_field_(key_field) {
+}
+
+KafkaProducer::~KafkaProducer() {
+    stop();
+}
+
+bool KafkaProducer::start(std::string&) {
+    if (running_.load()) {
+        return true;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&KafkaProducer::worker_loop, this);
+    return true;
+}
+
+void KafkaProducer::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    queue_cv_.notify_all();
+
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+    close_connections();
+}
+
+void KafkaProducer::write_record(const JsonObject& record, const std::string& line) {
+    std::string key;
+    if (!key_field_.empty()) {
+        auto it = record.find(key_field_);
+        if (it != record.end()) {
+            key = it->second.get_type() == JsonValue::INT64 ? std::to_string(it->second.as_int64())
+                                                            : it->second.as_string();
+        }
+    }
+    send(key, line);
+}
+
+std::string KafkaProducer::summary() const {
+    std::string text = "Kafka: 已发送 " + std::to_string(sent_count_.load()) + " 条记录";
+    if (failed_count_.load() > 0) {
+        text += "，失败 " + std::to_string(failed_count_.load()) + " 条";
+    }
+    return text;
+}
+
+void KafkaProducer::send(const std::string& key, const std::string& value) {
+    Record record;
+    record.key = key;
+    record.value = value;
+    record.timestamp_ms = std::chrono::duration_cast<std::chrono::milliseconds>(
+        std::chrono::system_clock::now().time_since_epoch()).count();
+
+    std::unique_lock<std::mutex> lock(queue_mutex_);
+
+    // broker不可达时避免无限堆积
+    if (pending_.size() >= MAX_PENDING_RECORDS) {
+        pending_.pop();
+        failed_count_.fetch_add(1);
+    }
+
+    pending_.push(std::move(record));
+    lock.unlock();
+
+    queue_cv_.notify_one();
+}
+
+void KafkaProducer::worker_loop() {
+    std::unique_lock<std::mutex> lock(queue_mutex_);
+
+    while (running_.load() || !pending_.empty()) {
+        queue_cv_.wait(lock, [this] {
+            return !pending_.empty() || !running_.load();
+        });
+
+        while (!pending_.empty()) {
+            std::vector<Record> batch;
+            while (!pending_.empty() && batch.size() < MAX_BATCH_RECORDS) {
+                batch.push_back(std::move(pending_.front()));
+                pending_.pop();
+            }
+            lock.unlock();
+
+            // 失败时刷新元数据(leader可能已切换)后重试一次
+            std::string error;
+            bool ok = deliver(batch, error);
+            if (!ok) {
+                close_connections();
+                partition_leaders_.clear();
+                ok = deliver(batch, error);
+            }
+            if (ok) {
+                sent_count_.fetch_add(static_cast<int64_t>(batch.size()));
+                last_error_.clear();
+            } else {
+                failed_count_.fetch_add(static_cast<int64_t>(batch.size()));
+                report_error(error);
+            }
+
+            lock.lock();
+        }
+    }
+}
+
+void KafkaProducer::report_error(const std::string& error) {
+    // 同一错误只提示一次，避免broker宕机时刷屏
+    if (error != last_error_) {
+        std::cerr << "⚠️  Kafka发送失败: " << error << "\n";
+        last_error_ = error;
+    }
+}
+
+void KafkaProducer::close_connections() {
+    for (const auto& connection : connections_) {
+        close(connection.second);
+    }
+    connections_.clear();
+}
+
+bool KafkaProducer::request(int fd, int16_t api_key, int16_t api_version, const std::string& body,
+                            std::string& response, std::string& error) {
+    int32_t correlation_id = ++correlation_id_;
+
+    Encoder header;
+    header.int16(api_key);
+    header.int16(api_version);
+    header.int32(correlation_id);
+    header.string(CLIENT_ID);
+
+    Encoder frame;
+    frame.int32(static_cast<int32_t>(header.buf.size() + body.size()));
+    frame.buf += header.buf;
+    frame.buf += body;
+
+    if (!send_all(fd, frame.buf)) {
+        error = "send: " + std::string(strerror(errno));
+        return false;
+    }
+
+    char size_bytes[4];
+    if (!recv_exact(fd, size_bytes, sizeof(size_bytes))) {
+        error = "recv: connection closed";
+        return false;
+    }
+    std::string size_text(size_bytes, sizeof(size_bytes));
+    Decoder size_decoder(size_text);
+    int32_t size = size_decoder.int32();
+    if (size < 4 || size > 64 * 1024 * 1024) {
+        error = "invalid response size " + std::to_string(size);
+        return false;
+    }
+
+    std::string payload(static_cast<size_t>(size), '\0');
+    if (!recv_exact(fd, &payload[0], payload.size())) {
+        error = "recv: connection closed";
+        return false;
+    }
+
+    Decoder decoder(payload);
+    if (decoder.int32() != correlation_id) {
+        error = "correlation id mismatch";
+        return false;
+    }
+    response = payload.substr(4);
+    return true;
+}
+
+bool KafkaProducer::refresh_metadata(std::string& error) {
+    Encoder body;
+    body.int32(1);
+    body.string(topic_);
+    body.int8(1);  // allow_auto_topic_creation
+
+    for (const auto& broker : bootstrap_) {
+        int fd = connect_to(broker.first, broker.second, error);
+        if (fd < 0) {
+            continue;
+        }
+        std::string response;
+        bool ok = request(fd, API_METADATA, 4, body.buf, response, error);
+        close(fd);
+        if (!ok) {
+            continue;
+        }
+
+        Decoder d(response);
+        d.int32();  // throttle_time_ms
+        std::map<int32_t, std::pair<std::string, std::string>> brokers;
+        int32_t broker_count = d.int32();
+        for (int32_t i = 0; i < broker_count && d.ok; ++i) {
+            int32_t node_id = d.int32();
+            std::string host = d.string();
+            int32_t port = d.int32();
+            d.string();  // rack
+            brokers[node_id] = {host, std::to_string(port)};
+        }
+        d.string();  // cluster_id
+        d.int32();   // controller_id
+
+        std::vector<int32_t> leaders;
+        int16_t topic_error = 0;
+        int32_t topic_count = d.int32();
+        for (int32_t t = 0; t < topic_count && d.ok; ++t) {
+            int16_t error_code = d.int16();
+            std::string name = d.string();
+            d.read(1);  // is_internal
+            int32_t partition_count = d.int32();
+            std::vector<int32_t> topic_leaders(partition_count > 0 ? partition_count : 0, -1);
+            for (int32_t p = 0; p < partition_count && d.ok; ++p) {
+                d.int16();  // partition error
+                int32_t index = d.int32();
+                int32_t leader = d.int32();
+                d.skip_int32_array();  // replicas
+                d.skip_int32_array();  // isr
+                if (index >= 0 && index < partition_count) {
+                    topic_leaders[index] = leader;
+                }
+            }
+            if (name == topic_) {
+                topic_error = error_code;
+                leaders = std::move(topic_leaders);
+            }
+        }
+
+        if (!d.ok) {
+            error = "malformed metadata response";
+            continue;
+        }
+        if (topic_error != 0 || leaders.empty()) {
+            error = "topic " + topic_ + ": " + kafka_error_name(topic_error != 0 ? topic_error : 5);
+            return false;
+        }
+
+        brokers_ = std::move(brokers);
+        partition_leaders_ = std::move(leaders);
+        return true;
+    }
+    return false;
+}
+
+int KafkaProducer::connection_for(int32_t node_id, std::string& error) {
+    auto it = connections_.find(node_id);
+    if (it != connections_.end()) {
+        return it->second;
+    }
+    auto broker = brokers_.find(node_id);
+    if (broker == brokers_.end()) {
+        error = "no broker for node " + std::to_string(node_id);
+        return -1;
+    }
+    int fd = connect_to(broker->second.first, broker->second.second, error);
+    if (fd >= 0) {
+        connections_[node_id] = fd;
+    }
+    return fd;
+}
+
+bool KafkaProducer::produce(int32_t partition, const std::vector<Record>& records, std::string& error) {
+    int fd = connection_for(partition_leaders_[partition], error);
+    if (fd < 0) {
+        return false;
+    }
+
+    Encoder body;
+    body.null_string();   // transactional_id
+    body.int16(1);        // acks
+    body.int32(5000);     // timeout_ms
+    body.int32(1);
+    body.string(topic_);
+    body.int32(1);
+    body.int32(partition);
+    body.bytes(encode_record_batch(records));
+
+    std::string response;
+    if (!request(fd, API_PRODUCE, 3, body.buf, response, error)) {
+        return false;
+    }
+
+    Decoder d(response);
+    int32_t topic_count = d.int32();
+    for (int32_t t = 0; t < topic_count && d.ok; ++t) {
+        d.string();
+        int32_t partition_count = d.int32();
+        for (int32_t p = 0; p < partition_count && d.ok; ++p) {
+            d.int32();
+            int16_t error_code = d.int16();
+            d.int64();  // base_offset
+            d.int64();  // log_append_time
+            if (error_code != 0) {
+                error = "partition " + std::to_string(partition) + ": " + kafka_error_name(error_code);
+                return false;
+            }
+        }
+    }
+    if (!d.ok) {
+        error = "malformed produce response";
+        return false;
+    }
+    return true;
+}
+
+bool KafkaProducer::deliver(const std::vector<Record>& records, std::string& error) {
+    if (partition_leaders_.empty() && !refresh_metadata(error)) {
+        return false;
+    }
+
+    // 同一key始终进入同一分区，保证同一路由器的记录有序
+    std::map<int32_t, std::vector<Record>> by_partition;
+    int32_t partitions = static_cast<int32_t>(partition_leaders_.size());
+    for (const auto& record : records) {
+        int32_t partition = record.key.empty()
+            ? static_cast<int32_t>(round_robin_++ % partitions)
+            : (murmur2(record.key) & 0x7fffffff) % partitions;
+        by_partition[partition].push_back(record);
+    }
+
+    for (const auto& pair : by_partition) {
+        if (!produce(pair.first, pair.second, error)) {
+            return false;
+        }
+    }
+    return true;
+}
@@ -0,0 +1,92 @@
+#pragma once
+
+#include <atomic>
+#include <condition_variable>
+#include <cstdint>
+#include <map>
+#include <mutex>
+#include <queue>
+#include <string>
+#include <thread>
+#include <utility>
+#include <vector>
+#include "record_sink.h"
+
+// 极简Kafka生产者(无外部依赖)：Metadata v4 + Produce v3(RecordBatch v2)，acks=1
+// 不支持TLS/SASL与压缩，适用于实验环境中的明文broker(Kafka 0.11及以上)
+class KafkaProducer : public RecordSink {
+public:
+    struct Record {
+        std::string key;    // 为空时不设置key，按轮询分区
+        std::string value;
+        int64_t timestamp_ms = 0;
+    };
+
+private:
+    std::vector<std::pair<std::string, std::string>> bootstrap_;  // host, port
+    std::string topic_;
+    std::string key_field_;  // 作为消息key的记录字段，为空则不设置key
+
+    std::queue<Record> pending_;
+    std::mutex queue_mutex_;
+    std::condition_variable queue_cv_;
+
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+
+    std::atomic<int64_t> sent_count_{0};
+    std::atomic<int64_t> failed_count_{0};
+
+    // 以下仅由工作线程访问
+    std::map<int32_t, std::pair<std::string, std::string>> brokers_;  // node_id -> host, port
+    std::vector<int32_t> partition_leaders_;                          // 分区号 -> leader node_id
+    std::map<int32_t, int> connections_;                               // node_id -> socket
+    int32_t correlation_id_ = 0;
+    uint32_t round_robin_ = 0;
+    std::string last_error_;
+
+    static constexpr size_t MAX_PENDING_RECORDS = 10000;
+    static constexpr size_t MAX_BATCH_RECORDS = 500;
+
+    void worker_loop();
+    void report_error(const std::string& error);
+    void close_connections();
+    bool refresh_metadata(std::string& error);
+    int connection_for(int32_t node_id, std::string& error);
+    bool request(int fd, int16_t api_key, int16_t api_version, const std::string& body,
+                 std::string& response, std::string& error);
+    bool produce(int32_t partition, const std::vector<Record>& records, std::string& error);
+    bool deliver(const std::vector<Record>& records, std::string& error);
+
+public:
+    KafkaProducer(const std::vector<std::pair<std::string, std::string>>& bootstrap, const std::string& topic,
+                  const std::string& key_field = "router_name");
+    ~KafkaProducer() override;
+
+    KafkaProducer(const KafkaProducer&) = delete;
+    KafkaProducer& operator=(const KafkaProducer&) = delete;
+
+    bool start(std::string& error) override;
+    // 停止前会尽量发送完队列中的记录
+    void stop() override;
+
+    // 以key_field_对应的字段为key发送记录的JSON行
+    void write_record(const JsonObject& record, const std::string& line) override;
+    std::string summary() const override;
+
+    void send(const std::string& key, const std::string& value);
+
+    int64_t sent_count() const { return sent_count_.load(); }
+    int64_t failed_count() const { return failed_count_.load(); }
+
+    // 解析 kafka://host[:port][,host[:port]...]/topic，默认端口9092
+    static bool parse_url(const std::string& url,
+                          std::vector<std::pair<std::string, std::string>>& bootstrap,
+                          std::string& topic);
+
+    // 与Java客户端默认分区器一致的murmur2哈希
+    static int32_t murmur2(const std::string& data);
+
+    // 编码RecordBatch v2(不含外层bytes长度)
+    static std::string encode_record_batch(const std::vector<Record>& records);
+};
@@ -0,0 +1,248 @@
+#include "link_tracker.h"
+#include "netlink_monitor.h"
+#include <cerrno>
+#include <cstring>
+#include <initializer_list>
+#include <unordered_set>
+
+namespace {
+
+std::string field(const LinkTracker::LinkInfo& info, const char* name) {
+    auto it = info.find(name);
+    return it != info.end() ? it->second : "";
+}
+
+LinkChange make_change(const std::string& type, const LinkTracker::LinkInfo& info) {
+    LinkChange change{type, info};
+    change.info["link_state"] = LinkTracker::link_state(info);
+    return change;
+}
+
+// 比较一组字段，有变化时生成一个事件并为每个字段附带previous_*
+void diff_fields(const std::string& type, const LinkTracker::LinkInfo& previous,
+                 const LinkTracker::LinkInfo& current, std::initializer_list<const char*> names,
+                 std::vector<LinkChange>& changes) {
+    bool changed = false;
+    for (const char* name : names) {
+        changed = changed || field(previous, name) != field(current, name);
+    }
+    if (!changed) {
+        return;
+    }
+    LinkChange change = make_change(type, current);
+    for (const char* name : names) {
+        change.info[std::string("previous_") + name] = field(previous, name);
+    }
+    changes.push_back(std::move(change));
+}
+
+} // namespace
+
+bool LinkTracker::is_tunnel_kind(const std::string& kind) {
+    static const std::unordered_set<std::string> kinds = {
+        "gre", "gretap", "ip6gre", "ip6gretap", "erspan", "ip6erspan",
+        "ipip", "sit", "ip6tnl", "vti", "vti6", "vxlan", "geneve", "wireguard",
+    };
+    return kinds.count(kind) > 0;
+}
+
+std::string LinkTracker::link_state(const LinkInfo& info) {
+    if (field(info, "admin_state") != "up") {
+        return "down";
+    }
+    std::string operstate = field(info, "operstate");
+    if (operstate == "up") {
+        return "up";
+    }
+    if (operstate == "unknown" || operstate.empty()) {
+        return field(info, "carrier") == "on" ? "up" : "down";
+    }
+    return "down";
+}
+
+std::string LinkTracker::seed() {
+    std::string error;
+    auto links = dump_links(error);
+    std::lock_guard<std::mutex> lock(mutex_);
+    for (auto& info : links) {
+        std::string ifindex = field(info, "ifindex");
+        links_[ifindex] = std::move(info);
+    }
+    return error;
+}
+
+std::vector<LinkChange> LinkTracker::update(const std::string& netlink_type, const LinkInfo& info) {
+    std::vector<LinkChange> changes;
+    std::string ifindex = field(info, "ifindex");
+
+    std::lock_guard<std::mutex> lock(mutex_);
+    auto it = links_.find(ifindex);
+
+    if (netlink_type == "link_del") {
+        // 删除通知有时不带完整属性，以保存的信息为准
+        const LinkInfo& last = it != links_.end() ? it->second : info;
+        if (tunnels_ && is_tunnel_kind(field(last, "kind"))) {
+            changes.push_back(make_change("tunnel_del", last));
+        }
+        if (it != links_.end()) {
+            links_.erase(it);
+        }
+        return changes;
+    }
+
+    if (it == links_.end()) {
+        // 新接口；基线之外第一次出现的已有接口也按新接口记录，但只有真正的创建才产生事件
+        if (tunnels_ && netlink_type == "link_add" && is_tunnel_kind(field(info, "kind"))) {
+            changes.push_back(make_change("tunnel_add", info));
+        }
+        links_.emplace(ifindex, info);
+        return changes;
+    }
+
+    LinkInfo previous = std::move(it->second);
+    it->second = info;
+    if (tunnels_ && is_tunnel_kind(field(info, "kind"))) {
+        diff_tunnel(previous, info, changes);
+    }
+    if (lag_) {
+        diff_lag(previous, info, changes);
+    }
+    if (attributes_) {
+        diff_attributes(previous, info, changes);
+    }
+    return changes;
+}
+
+void LinkTracker::diff_tunnel(const LinkInfo& previous, const LinkInfo& current,
+                              std::vector<LinkChange>& changes) const {
+    std::string old_state = link_state(previous);
+    std::string new_state = link_state(current);
+    if (old_state != new_state) {
+        changes.push_back(make_change(new_state == "up" ? "tunnel_up" : "tunnel_down", current));
+    }
+
+    std::string old_local = field(previous, "tunnel_local");
+    std::string old_remote = field(previous, "tunnel_remote");
+    if (old_local != field(current, "tunnel_local") || old_remote != field(current, "tunnel_remote")) {
+        LinkChange change = make_change("tunnel_endpoint_change", current);
+        change.info["previous_local"] = old_local;
+        change.info["previous_remote"] = old_remote;
+        changes.push_back(std::move(change));
+    }
+}
+
+void LinkTracker::diff_lag(const LinkInfo& previous, const LinkInfo& current,
+                           std::vector<LinkChange>& changes) const {
+    std::string kind = field(current, "kind");
+    if (kind == "bond") {
+        // 活动成员切换(active-backup等)或802.3ad活动聚合组切换
+        diff_fields("lag_failover", previous, current, {"active_slave", "ad_aggregator"}, changes);
+    }
+
+    // 加入或离开bond/team本身不在这里比较，成员状态只与同一从属关系下的上一次比较
+    std::string slave_kind = field(current, "slave_kind");
+    if (slave_kind != field(previous, "slave_kind")) {
+        return;
+    }
+    if (slave_kind == "bond") {
+        diff_fields("lag_member_change", previous, current, {"slave_state", "mii_status"}, changes);
+        diff_fields("lacp_state_change", previous, current,
+                    {"ad_actor_state", "ad_partner_state", "ad_aggregator_id"}, changes);
+    } else if (slave_kind == "team") {
+        // team的活动端口由teamd管理，内核通知中只有端口自身的链路状态
+        if (link_state(previous) != link_state(current)) {
+            LinkChange change = make_change("lag_member_change", current);
+            change.info["previous_link_state"] = link_state(previous);
+            changes.push_back(std::move(change));
+        }
+    }
+}
+
+void LinkTracker::diff_attributes(const LinkInfo& previous, const LinkInfo& current,
+                                  std::vector<LinkChange>& changes) const {
+    diff_fields("link_mtu_change", previous, current, {"mtu"}, changes);
+    diff_fields("link_promisc_change", previous, current, {"promisc"}, changes);
+    // 加入bond/bridge/VRF等时master从空变为主接口，离开时相反
+    diff_fields("link_master_change", previous, current, {"master"}, changes);
+}
+
+std::vector<std::string> LinkTracker::interfaces_of_kind(const std::string& kind) {
+    std::vector<std::string> names;
+    std::lock_guard<std::mutex> lock(mutex_);
+    for (const auto& pair : links_) {
+        if (field(pair.second, "kind") == kind) {
+            names.push_back(field(pair.second, "interface"));
+        }
+    }
+    return names;
+}
+
+std::vector<LinkTracker::LinkInfo> LinkTracker::dump_links(std::string& error) {
+    std::vector<LinkInfo> links;
+
+    // 与路由表采样一样使用独立的短连接套接字
+    int fd = socket(AF_NETLINK, SOCK_RAW | SOCK_CLOEXEC, NETLINK_ROUTE);
+    if (fd < 0) {
+        error = std::string("socket: ") + strerror(errno);
+        return links;
+    }
+
+    struct {
+        struct nlmsghdr nlh;
+        struct ifinfomsg ifi;
+    } request{};
+    request.nlh.nlmsg_len = NLMSG_LENGTH(sizeof(struct ifinfomsg));
+    request.nlh.nlmsg_type = RTM_GETLINK;
+    request.nlh.nlmsg_flags = NLM_F_REQUEST | NLM_F_DUMP;
+    request.nlh.nlmsg_seq = 1;
+    request.ifi.ifi_family = AF_UNSPEC;
+
+    if (send(fd, &request, request.nlh.nlmsg_len, 0) < 0) {
+        error = std::string("send: ") + strerror(errno);
+        close(fd);
+        return links;
+    }
+
+    char buffer[65536];
+    bool done = false;
+    while (!done) {
+        ssize_t len = recv(fd, buffer, sizeof(buffer), 0);
+        if (len < 0) {
+            if (errno == EINTR) {
+                continue;
+            }
+            error = std::string("recv: ") + strerror(errno);
+            break;
+        }
+        if (len == 0) {
+            break;
+        }
+
+        int remaining = static_cast<int>(len);
+        for (struct nlmsghdr* nlh = reinterpret_cast<struct nlmsghdr*>(buffer);
+             NLMSG_OK(nlh, remaining); nlh = NLMSG_NEXT(nlh, remaining)) {
+            if (nlh->nlmsg_type == NLMSG_DONE) {
+                done = true;
+                break;
+            }
+            if (nlh->nlmsg_type == NLMSG_ERROR) {
+                auto* err = static_cast<struct nlmsgerr*>(NLMSG_DATA(nlh));
+                error = std::string("dump: ") + strerror(-err->error);
+                done = true;
+                break;
+            }
+            if (nlh->nlmsg_type != RTM_NEWLINK) {
+                continue;
+            }
+
+            const auto* ifi = static_cast<const struct ifinfomsg*>(NLMSG_DATA(nlh));
+            int attrlen = nlh->nlmsg_len - NLMSG_LENGTH(sizeof(*ifi));
+            const auto* rta = reinterpret_cast<const struct rtattr*>(
+                reinterpret_cast<const char*>(ifi) + NLMSG_ALIGN(sizeof(*ifi)));
+            links.push_back(NetlinkMessageParser::parse_link_message(ifi, rta, attrlen));
+        }
+    }
+
+    close(fd);
+    return links;
+}
@@ -0,0 +1,54 @@
+#pragma once
+
+#include <mutex>
+#include <string>
+#include <unordered_map>
+#include <vector>
+
+// 由接口消息得出的一次有意义的变化，type即会话中的事件类型(如tunnel_down)
+struct LinkChange {
+    std::string type;
+    std::unordered_map<std::string, std::string> info;
+};
+
+// 接口状态跟踪：内核对同一接口会反复发送RTM_NEWLINK(统计、标志等任何变化)，
+// 这里保存每个接口上一次的解析结果，只把关心的状态变化转换为事件
+class LinkTracker {
+public:
+    using LinkInfo = std::unordered_map<std::string, std::string>;
+
+private:
+    std::unordered_map<std::string, LinkInfo> links_;  // ifindex -> 最近一次的接口信息
+    std::mutex mutex_;
+    bool tunnels_ = false;  // 隧道的创建删除、端点与链路状态
+    bool lag_ = false;      // bond/team的活动成员、成员状态与LACP状态
+    bool attributes_ = false;  // 任意接口的MTU、混杂模式与主从关系
+
+    void diff_tunnel(const LinkInfo& previous, const LinkInfo& current, std::vector<LinkChange>& changes) const;
+    void diff_lag(const LinkInfo& previous, const LinkInfo& current, std::vector<LinkChange>& changes) const;
+    void diff_attributes(const LinkInfo& previous, const LinkInfo& current, std::vector<LinkChange>& changes) const;
+
+public:
+    void set_tunnel_tracking(bool enabled) { tunnels_ = enabled; }
+    void set_lag_tracking(bool enabled) { lag_ = enabled; }
+    // 属性变化的事件类型以link_开头(link_mtu_change等)，由调用方作为接口事件记录而非路由事件
+    void set_attribute_tracking(bool enabled) { attributes_ = enabled; }
+
+    // 用当前的接口列表建立基线，之后已有接口的变化也能与之比较；失败时返回错误信息
+    std::string seed();
+
+    // 处理一条link_add/link_change/link_del，返回由此得出的事件(可能为空)
+    std::vector<LinkChange> update(const std::string& netlink_type, const LinkInfo& info);
+
+    // 当前某一类型的接口名称(如wireguard)
+    std::vector<std::string> interfaces_of_kind(const std::string& kind);
+
+    // 隧道类接口：gre/ipip/vti/vxlan/geneve/wireguard等
+    static bool is_tunnel_kind(const std::string& kind);
+
+    // 运行状态：管理关闭为down；operstate为unknown(多数隧道如此)时按载波判断
+    static std::string link_state(const LinkInfo& info);
+
+    // 同步dump全部接口(RTM_GETLINK)并解析
+    static std::vector<LinkInfo> dump_links(std::string& error);
+};
@@ -0,0 +1,62 @@
+#include "listen_socket.h"
+#include <arpa/inet.h>
+#include <cerrno>
+#include <cstdint>
+#include <cstring>
+#include <netinet/in.h>
+#include <sys/socket.h>
+#include <unistd.h>
+
+int open_listen_socket(const std::string& address, int port, int type, std::string& error) {
+    // 未指定地址时监听双栈通配地址
+    struct sockaddr_storage addr;
+    memset(&addr, 0, sizeof(addr));
+    socklen_t addr_length;
+    int family;
+
+    struct sockaddr_in* v4 = reinterpret_cast<struct sockaddr_in*>(&addr);
+    struct sockaddr_in6* v6 = reinterpret_cast<struct sockaddr_in6*>(&addr);
+    if (!address.empty() && inet_pton(AF_INET, address.c_str(), &v4->sin_addr) == 1) {
+        family = AF_INET;
+        v4->sin_family = AF_INET;
+        v4->sin_port = htons(static_cast<uint16_t>(port));
+        addr_length = sizeof(*v4);
+    } else {
+        family = AF_INET6;
+        v6->sin6_family = AF_INET6;
+        v6->sin6_port = htons(static_cast<uint16_t>(port));
+        v6->sin6_addr = in6addr_any;
+        if (!address.empty() && inet_pton(AF_INET6, address.c_str(), &v6->sin6_addr) != 1) {
+            error = "invalid listen address " + address;
+            return -1;
+        }
+        addr_length = sizeof(*v6);
+    }
+
+    int fd = socket(family, type | SOCK_CLOEXEC, 0);
+    if (fd < 0) {
+        error = "socket: " + std::string(strerror(errno));
+        return -1;
+    }
+
+    int on = 1, off = 0;
+    if (type == SOCK_STREAM) {
+        setsockopt(fd, SOL_SOCKET, SO_REUSEADDR, &on, sizeof(on));
+    }
+    if (family == AF_INET6) {
+        setsockopt(fd, IPPROTO_IPV6, IPV6_V6ONLY, &off, sizeof(off));
+    }
+
+    if (bind(fd, reinterpret_cast<struct sockaddr*>(&addr), addr_length) < 0) {
+        error = (type == SOCK_STREAM ? "bind/listen port " : "bind udp port ") + std::to_string(port) + ": " +
+                strerror(errno);
+        close(fd);
+        return -1;
+    }
+    if (type == SOCK_STREAM && listen(fd, 16) < 0) {
+        error = "bind/listen port " + std::to_string(port) + ": " + strerror(errno);
+        close(fd);
+        return -1;
+    }
+    return fd;
+}
@@ -0,0 +1,8 @@
+#pragma once
+
+#include <string>
+
+// 创建并绑定内嵌服务(健康检查、Prometheus、BMP、SNMP Trap等)的监听套接字。
+// address为空时监听双栈通配地址；type为SOCK_STREAM时设置SO_REUSEADDR并开始listen，SOCK_DGRAM只绑定。
+// 返回套接字描述符(带SOCK_CLOEXEC)，失败返回-1并设置error
+int open_listen_socket(const std::string& address, int port, int type, std::string& error);
@@ -0,0 +1,400 @@
+#include "log_reader.h"
+#include <algorithm>
+#include <cctype>
+#include <cstdio>
+#include <cstdlib>
+#include <ctime>
+#include <fstream>
+
+namespace {
+
+// 递归下降JSON解析器，只覆盖日志中会出现的语法
+class JsonParser {
+private:
+    const std::string& text_;
+    size_t pos_ = 0;
+
+    void skip_ws() {
+        while (pos_ < text_.size() &&
+               (text_[pos_] == ' ' || text_[pos_] == '\t' ||
+                text_[pos_] == '\n' || text_[pos_] == '\r')) {
+            pos_++;
+        }
+    }
+
+    bool consume(char c) {
+        skip_ws();
+        if (pos_ < text_.size() && text_[pos_] == c) {
+            pos_++;
+            return true;
+        }
+        return false;
+    }
+
+    static void append_utf8(std::string& out, unsigned int cp) {
+        if (cp < 0x80) {
+            out += static_cast<char>(cp);
+        } else if (cp < 0x800) {
+            out += static_cast<char>(0xC0 | (cp >> 6));
+            out += static_cast<char>(0x80 | (cp & 0x3F));
+        } else {
+            out += static_cast<char>(0xE0 | (cp >> 12));
+            out += static_cast<char>(0x80 | ((cp >> 6) & 0x3F));
+            out += static_cast<char>(0x80 | (cp & 0x3F));
+        }
+    }
+
+    bool parse_string(std::string& out) {
+        skip_ws();
+        if (pos_ >= text_.size() || text_[pos_] != '"') {
+            return false;
+        }
+        pos_++;
+
+        while (pos_ < text_.size()) {
+            char c = text_[pos_++];
+            if (c == '"') {
+                return true;
+            }
+            if (c != '\\') {
+                out += c;
+                continue;
+            }
+            if (pos_ >= text_.size()) {
+                return false;
+            }
+            char esc = text_[pos_++];
+            switch (esc) {
+                case '"':  out += '"'; break;
+                case '\\': out += '\\'; break;
+                case '/':  out += '/'; break;
+                case 'b':  out += '\b'; break;
+                case 'f':  out += '\f'; break;
+                case 'n':  out += '\n'; break;
+                case 'r':  out += '\r'; break;
+                case 't':  out += '\t'; break;
+                case 'u': {
+                    if (pos_ + 4 > text_.size()) {
+                        return false;
+                    }
+                    unsigned int cp = std::strtoul(text_.substr(pos_, 4).c_str(), nullptr, 16);
+                    pos_ += 4;
+                    append_utf8(out, cp);
+                    break;
+                }
+                default:
+                    return false;
+            }
+        }
+        return false;
+    }
+
+    // 跳过一个嵌套的对象或数组，返回其原始文本
+    bool capture_nested(std::string& raw) {
+        size_t start = pos_;
+        int depth = 0;
+        bool in_string = false;
+
+        while (pos_ < text_.size()) {
+            char c = text_[pos_++];
+            if (in_string) {
+                if (c == '\\') {
+                    pos_++;
+                } else if (c == '"') {
+                    in_string = false;
+                }
+                continue;
+            }
+            if (c == '"') {
+                in_string = true;
+            } else if (c == '{' || c == '[') {
+                depth++;
+            } else if (c == '}' || c == ']') {
+                depth--;
+                if (depth == 0) {
+                    raw = text_.substr(start, pos_ - start);
+                    return true;
+                }
+            }
+        }
+        return false;
+    }
+
+    bool parse_value(JsonValue& out) {
+        skip_ws();
+        if (pos_ >= text_.size()) {
+            return false;
+        }
+
+        char c = text_[pos_];
+        if (c == '"') {
+            std::string s;
+            if (!parse_string(s)) {
+                return false;
+            }
+            out = JsonValue(s);
+            return true;
+        }
+        if (c == '{' || c == '[') {
+            std::string raw;
+            if (!capture_nested(raw)) {
+                return false;
+            }
+            out = JsonValue(raw);
+            return true;
+        }
+        if (text_.compare(pos_, 4, "true") == 0) {
+            pos_ += 4;
+            out = JsonValue(true);
+            return true;
+        }
+        if (text_.compare(pos_, 5, "false") == 0) {
+            pos_ += 5;
+            out = JsonValue(false);
+            return true;
+        }
+        if (text_.compare(pos_, 4, "null") == 0) {
+            pos_ += 4;
+            out = JsonValue("");
+            return true;
+        }
+
+        // 数字
+        size_t start = pos_;
+        bool is_float = false;
+        while (pos_ < text_.size()) {
+            char d = text_[pos_];
+            if (d == '.' || d == 'e' || d == 'E') {
+                is_float = true;
+            } else if (!(d == '-' || d == '+' || (d >= '0' && d <= '9'))) {
+                break;
+            }
+            pos_++;
+        }
+        if (pos_ == start) {
+            return false;
+        }
+        std::string number = text_.substr(start, pos_ - start);
+        if (is_float) {
+            out = JsonValue(std::strtod(number.c_str(), nullptr));
+        } else {
+            out = JsonValue(static_cast<int64_t>(std::strtoll(number.c_str(), nullptr, 10)));
+        }
+        return true;
+    }
+
+public:
+    explicit JsonParser(const std::string& text) : text_(text) {}
+
+    bool parse_object(JsonObject& out) {
+        if (!consume('{')) {
+            return false;
+        }
+        if (consume('}')) {
+            return true;
+        }
+
+        do {
+            std::string key;
+            if (!parse_string(key) || !consume(':')) {
+                return false;
+            }
+            JsonValue value;
+            if (!parse_value(value)) {
+                return false;
+            }
+            out[key] = value;
+        } while (consume(','));
+
+        return consume('}');
+    }
+
+    // 数组元素以JsonValue返回，嵌套对象/数组同样保留为原始文本
+    bool parse_array(std::vector<JsonValue>& out) {
+        if (!consume('[')) {
+            return false;
+        }
+        if (consume(']')) {
+            return true;
+        }
+
+        do {
+            JsonValue value;
+            if (!parse_value(value)) {
+                return false;
+            }
+            out.push_back(value);
+        } while (consume(','));
+
+        return consume(']');
+    }
+};
+
+} // namespace
+
+bool LogReader::parse_object(const std::string& text, JsonObject& out) {
+    JsonParser parser(text);
+    return parser.parse_object(out);
+}
+
+bool LogReader::parse_array(const std::string& text, std::vector<JsonValue>& out) {
+    JsonParser parser(text);
+    return parser.parse_array(out);
+}
+
+bool LogReader::read_file(const std::string& path,
+                          std::vector<JsonObject>& records,
+                          int& malformed_lines,
+                          std::string& error) {
+    std::ifstream in(path);
+    if (!in.is_open()) {
+        error = "无法打开日志文件: " + path;
+        return false;
+    }
+
+    malformed_lines = 0;
+    std::string line;
+    while (std::getline(in, line)) {
+        if (line.find_first_not_of(" \t\r") == std::string::npos) {
+            continue;
+        }
+        JsonObject record;
+        if (parse_object(line, record)) {
+            records.push_back(std::move(record));
+        } else {
+            malformed_lines++;
+        }
+    }
+
+    return true;
+}
+
+std::unordered_map<std::string, std::string> LogReader::parse_string_map(const std::string& text) {
+    std::unordered_map<std::string, std::string> result;
+
+    JsonObject obj;
+    if (!parse_object(text, obj)) {
+        return result;
+    }
+
+    for (const auto& pair : obj) {
+        switch (pair.second.get_type()) {
+            case JsonValue::STRING: result[pair.first] = pair.second.as_string(); break;
+            case JsonValue::INT64:  result[pair.first] = std::to_string(pair.second.as_int64()); break;
+            case JsonValue::DOUBLE: result[pair.first] = std::to_string(pair.second.as_double()); break;
+            case JsonValue::BOOL:   result[pair.first] = pair.second.as_bool() ? "true" : "false"; break;
+        }
+    }
+
+    return result;
+}
+
+int64_t LogReader::parse_timestamp_ms(const std::string& text) {
+    // --timestamp-format epoch-ms/epoch-ns，按数量级区分毫秒和纳秒
+    if (!text.empty() && std::all_of(text.begin(), text.end(),
+                                     [](char c) { return isdigit(static_cast<unsigned char>(c)); })) {
+        int64_t value = std::stoll(text);
+        return value >= 1000000000000000LL ? value / 1000000 : value;
+    }
+
+    struct tm tm_value = {};
+    int millis = 0;
+    int consumed = 0;
+
+    if (sscanf(text.c_str(), "%d-%d-%dT%d:%d:%d%n",
+               &tm_value.tm_year, &tm_value.tm_mon, &tm_value.tm_mday,
+               &tm_value.tm_hour, &tm_value.tm_min, &tm_value.tm_sec, &consumed) != 6) {
+        return -1;
+    }
+
+    // 可选的小数秒部分，超过毫秒的精度截断
+    size_t pos = static_cast<size_t>(consumed);
+    if (pos < text.size() && text[pos] == '.') {
+        std::string fraction;
+        for (++pos; pos < text.size() && isdigit(static_cast<unsigned char>(text[pos])); ++pos) {
+            fraction += text[pos];
+        }
+        fraction = (fraction + "000").substr(0, 3);
+        millis = atoi(fraction.c_str());
+    }
+
+    // 可选的时区偏移(--timezone)，Z或缺省视为UTC
+    int64_t offset_seconds = 0;
+    if (pos < text.size() && (text[pos] == '+' || text[pos] == '-')) {
+        int hours = 0;
+        int minutes = 0;
+        if (sscanf(text.c_str() + pos + 1, "%2d:%2d", &hours, &minutes) == 2) {
+            offset_seconds = (hours * 3600 + minutes * 60) * (text[pos] == '-' ? -1 : 1);
+        }
+    }
+
+    tm_value.tm_year -= 1900;
+    tm_value.tm_mon -= 1;
+    return (static_cast<int64_t>(timegm(&tm_value)) - offset_seconds) * 1000 + millis;
+}
+
+std::string LogReader::get_timestamp(const JsonObject& obj, const std::string& key) {
+    auto it = obj.find(key);
+    if (it != obj.end() && it->second.get_type() == JsonValue::INT64) {
+        return std::to_string(it->second.as_int64());
+    }
+    return get_string(obj, key);
+}
+
+std::string LogReader::get_string(const JsonObject& obj, const std::string& key,
+                                  const std::string& fallback) {
+    auto it = obj.find(key);
+    if (it == obj.end()) {
+        return fallback;
+    }
+    switch (it->second.get_type()) {
+        case JsonValue::STRING: return it->second.as_string();
+        case JsonValue::INT64:  return std::to_string(it->second.as_int64());
+        case JsonValue::DOUBLE: return std::to_string(it->second.as_double());
+        case JsonValue::BOOL:   return it->second.as_bool() ? "true" : "false";
+    }
+    return fallback;
+}
+
+int64_t LogReader::get_int(const JsonObject& obj, const std::string& key, int64_t fallback) {
+    auto it = obj.find(key);
+    if (it == obj.end()) {
+        return fallback;
+    }
+    switch (it->second.get_type()) {
+        case JsonValue::INT64:  return it->second.as_int64();
+        case JsonValue::DOUBLE: return static_cast<int64_t>(it->second.as_double());
+        case JsonValue::STRING: {
+            char* end = nullptr;
+            const std::string& s = it->second.as_string();
+            int64_t v = std::strtoll(s.c_str(), &end, 10);
+            return (end != s.c_str()) ? v : fallback;
+        }
+        default:
+            return fallback;
+    }
+}
+
+double LogReader::get_double(const JsonObject& obj, const std::string& key, double fallback) {
+    auto it = obj.find(key);
+    if (it == obj.end()) {
+        return fallback;
+    }
+    switch (it->second.get_type()) {
+        case JsonValue::INT64:  return static_cast<double>(it->second.as_int64());
+        case JsonValue::DOUBLE: return it->second.as_double();
+        default:                return fallback;
+    }
+}
+
+bool LogReader::get_bool(const JsonObject& obj, const std::string& key, bool fallback) {
+    auto it = obj.find(key);
+    if (it == obj.end() || it->second.get_type() != JsonValue::BOOL) {
+        return fallback;
+    }
+    return it->second.as_bool();
+}
+
+bool LogReader::has(const JsonObject& obj, const std::string& key) {
+    return obj.find(key) != obj.end();
+}
@@ -0,0 +1,40 @@
+#pragma once
+
+#include <string>
+#include <vector>
+#include "logger.h"
+
+// 结构化日志(NDJSON)读取器
+// 嵌套的对象/数组以原始JSON文本的形式保存为字符串值
+class LogReader {
+public:
+    // 解析单行JSON对象，失败返回false
+    static bool parse_object(const std::string& text, JsonObject& out);
+
+    // 解析JSON数组，失败返回false
+    static bool parse_array(const std::string& text, std::vector<JsonValue>& out);
+
+    // 读取整个日志文件，malformed_lines返回无法解析的行数
+    static bool read_file(const std::string& path,
+                          std::vector<JsonObject>& records,
+                          int& malformed_lines,
+                          std::string& error);
+
+    // 将 trigger_info/route_info 等字符串化的对象展开为键值表
+    static std::unordered_map<std::string, std::string> parse_string_map(const std::string& text);
+
+    // 解析时间戳为毫秒，失败返回-1。支持 2024-08-04T10:30:15.123Z、带±HH:MM偏移或纳秒小数的
+    // RFC3339，以及 --timestamp-format epoch-ms/epoch-ns 写出的十进制数字
+    static int64_t parse_timestamp_ms(const std::string& text);
+
+    // 读取时间戳字段的文本形式，纪元格式的整数值转为十进制字符串
+    static std::string get_timestamp(const JsonObject& obj, const std::string& key);
+
+    // 便捷访问
+    static std::string get_string(const JsonObject& obj, const std::string& key,
+                                  const std::string& fallback = "");
+    static int64_t get_int(const JsonObject& obj, const std::string& key, int64_t fallback = 0);
+    static double get_double(const JsonObject& obj, const std::string& key, double fallback = 0.0);
+    static bool get_bool(const JsonObject& obj, const std::string& key, bool fallback = false);
+    static bool has(const JsonObject& obj, const std::string& key);
+};
@@ -1,4 +1,8 @@
 #include "logger.h"
+#include "i18n.h"
+#include "record_sink.h"
+#include "debug_log.h"
+#include "timestamp_format.h"
 #include <iostream>
 #include <iomanip>
 #include <sstream>
@@ -27,7 +31,7 @@ Logger::Logger(const std::string& log_path) {
 
         if (!ensure_log_directory(resolved_path)) {
             // 如果无法创建目录，回退到当前目录
-            std::cout << "⚠️  无法创建日志目录，回退到当前执行路径\n";
+            std::cout << "⚠️  " << tr("无法创建日志目录，回退到当前执行路径", "Cannot create log directory, falling back to the working directory") << "\n";
 
             // 提取文件名
             const char* filename = strrchr(resolved_path.c_str(), '/');
@@ -39,12 +43,14 @@ Logger::Logger(const std::string& log_path) {
 
             // 验证回退路径是否可用
             if (!test_file_creation(log_file_path_)) {
-                std::cerr << "❌ 错误: 无法在当前目录创建日志文件 " << log_file_path_ << "\n";
-                std::cerr << "   请检查当前目录的写权限或指定其他日志路径\n";
+                std::cerr << "❌ " << tr("错误: 无法在当前目录创建日志文件 ", "Error: cannot create log file in the working directory ")
+                          << log_file_path_ << "\n";
+                std::cerr << "   " << tr("请检查当前目录的写权限或指定其他日志路径",
+                                         "Check write permission or pass another --log-path") << "\n";
                 throw std::runtime_error("无法创建日志文件，程序退出");
             }
 
-            std::cout << "✅ 日志文件将创建在: " << log_file_path_ << "\n";
+            info_out() << "✅ " << tr("日志文件将创建在: ", "Log file will be created at: ") << log_file_path_ << "\n";
         }
     }
 }
@@ -66,12 +72,12 @@ void Logger::start() {
     // 尝试打开日志文件
     log_file_.open(log_file_path_, std::ios::out | std::ios::app);
     if (!log_file_.is_open()) {
-        std::cerr << "❌ 错误: 无法打开日志文件 " << log_file_path_ << "\n";
-        std::cerr << "   请检查文件路径和权限，程序退出\n";
+        std::cerr << "❌ " << tr("错误: 无法打开日志文件 ", "Error: cannot open log file ") << log_file_path_ << "\n";
+        std::cerr << "   " << tr("请检查文件路径和权限，程序退出", "Check the path and permissions; exiting") << "\n";
         running_.store(false);
         throw std::runtime_error("无法打开日志文件，程序退出");
     } else {
-        std::cout << "✅ JSON结构化日志文件已配置: " << log_file_path_ << "\n";
+        info_out() << "✅ " << tr("JSON结构化日志文件已配置: ", "JSON log file: ") << log_file_path_ << "\n";
     }
 
     // 启动日志处理线程
@@ -87,6 +93,7 @@ void Logger::stop() {
     
     // 通知日志处理线程
     queue_cv_.notify_all();
+    drained_cv_.notify_all();
     
     // 等待线程结束
     if (log_thread_.joinable()) {
@@ -94,6 +101,7 @@ void Logger::stop() {
     }
     
     // 关闭文件
+    std::lock_guard<std::mutex> lock(file_mutex_);
     if (log_file_.is_open()) {
         log_file_.close();
     }
@@ -105,7 +113,8 @@ void Logger::log_async(const JsonObject& data) {
     // 如果队列满了，丢弃最旧的条目
     if (log_queue_.size() >= MAX_QUEUE_SIZE) {
         log_queue_.pop();
-        std::cout << "⚠️  日志队列满，丢弃一条日志\n";
+        records_dropped_++;
+        std::cout << "⚠️  " << tr("日志队列满，丢弃一条日志", "Log queue full, dropping a record") << "\n";
     }
     
     log_queue_.emplace(data);
@@ -114,14 +123,71 @@ void Logger::log_async(const JsonObject& data) {
     queue_cv_.notify_one();
 }
 
-void Logger::log_sync(const JsonObject& data) {
+void Logger::log_sync(const JsonObject& record) {
+    JsonObject data = record;
+    apply_labels(data);
     std::string json_str = json_to_string(data);
-    
+    write_line(json_str);
+
+    for (auto* sink : sinks_) {
+        sink->write_record(data, json_str);
+    }
+}
+
+void Logger::write_line(const std::string& json_str) {
+    std::lock_guard<std::mutex> lock(file_mutex_);
+    if (abandoned_) {
+        return;
+    }
     if (log_file_.is_open()) {
         log_file_ << json_str << "\n";
         log_file_.flush();
+        bytes_written_ += static_cast<int64_t>(json_str.size()) + 1;
     } else {
-        std::cout << json_str << "\n";
+        machine_out() << json_str << "\n";
+    }
+    records_written_++;
+}
+
+size_t Logger::abandon(const JsonObject* final_record) {
+    std::queue<LogEntry> pending;
+    {
+        std::lock_guard<std::mutex> lock(queue_mutex_);
+        std::swap(pending, log_queue_);
+    }
+
+    // 处理线程可能正阻塞在某个sink中，这里只写文件，不再分发给sink
+    size_t written = pending.size();
+    while (!pending.empty()) {
+        JsonObject data = std::move(pending.front().data);
+        pending.pop();
+        apply_labels(data);
+        write_line(json_to_string(data));
+    }
+    if (final_record) {
+        JsonObject data = *final_record;
+        apply_labels(data);
+        write_line(json_to_string(data));
+    }
+
+    std::lock_guard<std::mutex> lock(file_mutex_);
+    abandoned_ = true;
+    if (log_file_.is_open()) {
+        log_file_.close();
+    }
+    return written;
+}
+
+void Logger::flush() {
+    std::unique_lock<std::mutex> lock(queue_mutex_);
+    drained_cv_.wait(lock, [this] {
+        return (log_queue_.empty() && !writing_) || !running_.load();
+    });
+}
+
+void Logger::apply_labels(JsonObject& data) const {
+    for (const auto& label : labels_) {
+        data.emplace(label.first, label.second);
     }
 }
 
@@ -138,24 +204,26 @@ void Logger::log_processor_loop() {
         while (!log_queue_.empty()) {
             LogEntry entry = std::move(log_queue_.front());
             log_queue_.pop();
+            writing_ = true;
             lock.unlock();
             
             // 生成JSON字符串并写入
+            apply_labels(entry.data);
             std::string json_str = json_to_string(entry.data);
-            
-            if (log_file_.is_open()) {
-                log_file_ << json_str << "\n";
-                log_file_.flush();
-            } else {
-                std::cout << json_str << "\n";
+            write_line(json_str);
+
+            for (auto* sink : sinks_) {
+                sink->write_record(entry.data, json_str);
             }
             
             lock.lock();
+            writing_ = false;
         }
+        drained_cv_.notify_all();
     }
 }
 
-std::string Logger::json_to_string(const JsonObject& json) const {
+std::string Logger::json_to_string(const JsonObject& json) {
     std::ostringstream oss;
     oss << "{";
 
@@ -174,7 +242,7 @@ std::string Logger::json_to_string(const JsonObject& json) const {
     return oss.str();
 }
 
-std::string Logger::json_value_to_string(const JsonValue& value) const {
+std::string Logger::json_value_to_string(const JsonValue& value) {
     switch (value.get_type()) {
         case JsonValue::STRING:
             return "\"" + escape_json_string(value.as_string()) + "\"";
@@ -192,7 +260,7 @@ std::string Logger::json_value_to_string(const JsonValue& value) const {
     }
 }
 
-std::string Logger::escape_json_string(const std::string& str) const {
+std::string Logger::escape_json_string(const std::string& str) {
     std::string escaped;
     escaped.reserve(str.length() + 10); // 预留一些空间给转义字符
     
@@ -206,8 +274,8 @@ std::string Logger::escape_json_string(const std::string& str) const {
             case '\r': escaped += "\\r"; break;
             case '\t': escaped += "\\t"; break;
             default:
-                if (c < 0x20) {
-                    // 控制字符
+                if (static_cast<unsigned char>(c) < 0x20) {
+                    // 控制字符（UTF-8多字节序列原样输出）
                     std::ostringstream oss;
                     oss << "\\u" << std::hex << std::setw(4) << std::setfill('0') << static_cast<int>(c);
                     escaped += oss.str();
@@ -230,10 +298,10 @@ std::string Logger::setup_default_log_path() const {
     if (stat(log_dir.c_str(), &st) != 0) {
         // 目录不存在，尝试创建
         if (mkdir(log_dir.c_str(), 0755) != 0) {
-            std::cout << "⚠️  无法创建 /var/log/frr 目录，使用当前目录\n";
+            std::cout << "⚠️  " << tr("无法创建 /var/log/frr 目录，使用当前目录", "Cannot create /var/log/frr, using the working directory") << "\n";
             log_dir = ".";
         } else {
-            std::cout << "✅ 创建日志目录: " << log_dir << "\n";
+            info_out() << "✅ " << tr("创建日志目录: ", "Created log directory: ") << log_dir << "\n";
         }
     }
 
@@ -241,8 +309,9 @@ std::string Logger::setup_default_log_path() const {
 
     // 验证日志文件路径是否可用
     if (!test_file_creation(log_file_path)) {
-        std::cerr << "❌ 错误: 无法在 " << log_dir << " 目录创建日志文件\n";
-        std::cerr << "   请检查目录权限或指定其他日志路径\n";
+        std::cerr << "❌ " << tr("错误: 无法在 ", "Error: cannot create log file in ") << log_dir
+                  << tr(" 目录创建日志文件", "") << "\n";
+        std::cerr << "   " << tr("请检查目录权限或指定其他日志路径", "Check directory permissions or pass another --log-path") << "\n";
         throw std::runtime_error("无法创建默认日志文件，程序退出");
     }
 
@@ -261,11 +330,11 @@ std::string Logger::resolve_log_path(const std::string& input_path) const {
                 resolved_path += '/';
             }
             resolved_path += "route_converge.json";
-            std::cout << "📁 检测到目录路径，使用默认文件名: " << resolved_path << "\n";
+            info_out() << "📁 " << tr("检测到目录路径，使用默认文件名: ", "Directory given, using default file name: ") << resolved_path << "\n";
             return resolved_path;
         } else {
             // 是文件，直接使用
-            std::cout << "📄 使用指定的文件路径: " << input_path << "\n";
+            info_out() << "📄 " << tr("使用指定的文件路径: ", "Using log file: ") << input_path << "\n";
             return input_path;
         }
     } else {
@@ -278,11 +347,11 @@ std::string Logger::resolve_log_path(const std::string& input_path) const {
                 resolved_path += '/';
             }
             resolved_path += "route_converge.json";
-            std::cout << "📁 路径看起来像目录，使用默认文件名: " << resolved_path << "\n";
+            info_out() << "📁 " << tr("路径看起来像目录，使用默认文件名: ", "Path looks like a directory, using default file name: ") << resolved_path << "\n";
             return resolved_path;
         } else {
             // 看起来像文件路径
-            std::cout << "📄 使用指定的文件路径: " << input_path << "\n";
+            info_out() << "📄 " << tr("使用指定的文件路径: ", "Using log file: ") << input_path << "\n";
             return input_path;
         }
     }
@@ -342,23 +411,22 @@ void Logger::ensure_log_file_permissions(const std::string& path) const {
 }
 
 // 静态辅助方法实现
+std::string Logger::current_user() {
+    struct passwd* pw = getpwuid(getuid());
+    return pw ? std::string(pw->pw_name) : "unknown";
+}
+
 JsonObject Logger::create_event_log(const std::string& event_type,
                                    const std::string& router_name,
                                    const std::string& user) {
     JsonObject log;
     log["event_type"] = event_type;
+    log["schema_version"] = SCHEMA_VERSION;
     log["router_name"] = router_name;
     log["user"] = user;
 
-    // 添加时间戳
-    auto now = std::chrono::system_clock::now();
-    auto time_t = std::chrono::system_clock::to_time_t(now);
-    std::ostringstream oss;
-    oss << std::put_time(std::gmtime(&time_t), "%Y-%m-%dT%H:%M:%S");
-    auto ms = std::chrono::duration_cast<std::chrono::milliseconds>(
-        now.time_since_epoch()) % 1000;
-    oss << "." << std::setfill('0') << std::setw(3) << ms.count() << "Z";
-    log["timestamp"] = oss.str();
+    // 添加时间戳，格式由 --timestamp-format/--timezone 决定
+    log["timestamp"] = timestamp_json_value(timestamp_now_ns());
 
     return log;
 }
@@ -483,7 +551,7 @@ JsonObject Logger::create_monitoring_start_log(const std::string& router_name,
         now.time_since_epoch()) % 1000;
     oss << "." << std::setfill('0') << std::setw(3) << ms.count() << "Z";
     log["utc_time"] = oss.str();
-    log["listen_start_time"] = oss.str();
+    log["listen_start_time"] = log["timestamp"];
 
     return log;
 }
@@ -520,8 +588,8 @@ JsonObject Logger::create_monitoring_completed_log(const std::string& router_nam
         now.time_since_epoch()) % 1000;
     oss << "." << std::setfill('0') << std::setw(3) << ms.count() << "Z";
     log["utc_time"] = oss.str();
-    log["listen_end_time"] = oss.str();
-    log["extraction_timestamp"] = oss.str();
+    log["listen_end_time"] = log["timestamp"];
+    log["extraction_timestamp"] = log["timestamp"];
     log["extracted_by"] = "async_event_monitor_cpp_v1.0_" + monitor_id;
 
     return log;
@@ -8,7 +8,9 @@
 #include <mutex>
 #include <condition_variable>
 #include <atomic>
+#include <functional>
 #include <unordered_map>
+#include <vector>
 
 // C++17兼容性检查
 #if __cplusplus >= 201703L
@@ -74,6 +76,8 @@ struct LogEntry {
         : data(d), timestamp(std::chrono::system_clock::now()) {}
 };
 
+class RecordSink;
+
 // 异步日志记录器类
 class Logger {
 private:
@@ -84,6 +88,17 @@ private:
     std::queue<LogEntry> log_queue_;
     mutable std::mutex queue_mutex_;
     std::condition_variable queue_cv_;
+    std::condition_variable drained_cv_;
+    bool writing_ = false;  // 处理线程正在写出已取出的条目
+
+    // 保护log_file_的写入，abandon()可能与处理线程同时写文件
+    std::mutex file_mutex_;
+    bool abandoned_ = false;  // abandon()已关闭文件，之后的记录不再写出
+    
+    // 写出统计：写入日志文件的字节数与记录数，以及队列满时丢弃的记录数
+    std::atomic<int64_t> bytes_written_{0};
+    std::atomic<int64_t> records_written_{0};
+    std::atomic<int64_t> records_dropped_{0};
     
     // 日志处理线程
     std::thread log_thread_;
@@ -91,14 +106,23 @@ private:
     
     // 队列大小限制
     static constexpr size_t MAX_QUEUE_SIZE = 1000;
+
+    // 每条记录写入文件后依次分发给各sink(不持有所有权)，须在start()之前注册
+    std::vector<RecordSink*> sinks_;
+
+    // 附加到每条记录的固定字段(--label)，记录中已有同名字段时不覆盖，须在start()之前设置
+    JsonObject labels_;
     
     // 内部方法
     void log_processor_loop();
-    std::string json_to_string(const JsonObject& json) const;
-    std::string json_value_to_string(const JsonValue& value) const;
-    std::string escape_json_string(const std::string& str) const;
+    void apply_labels(JsonObject& data) const;
+    void write_line(const std::string& json_str);
+    static std::string json_value_to_string(const JsonValue& value);
 
 public:
+    // 记录格式版本，删除字段或修改字段类型/含义时递增，新增可选字段不递增
+    static constexpr int64_t SCHEMA_VERSION = 1;
+
     Logger(const std::string& log_path = "");
     ~Logger();
     
@@ -116,10 +140,37 @@ public:
     
     // 同步记录日志（用于程序退出时的最终统计）
     void log_sync(const JsonObject& data);
+
+    // 等待队列中已有的记录全部写出
+    void flush();
+
+    // 强制退出(第二次Ctrl+C)：不等待处理线程与各sink，在调用线程写出队列中剩余的记录
+    // 和final_record(可为空)后关闭文件，返回写出的排队记录数
+    size_t abandon(const JsonObject* final_record = nullptr);
+
+    int64_t bytes_written() const { return bytes_written_.load(); }
+    int64_t records_written() const { return records_written_.load(); }
+    int64_t records_dropped() const { return records_dropped_.load(); }
     
     // 获取日志文件路径
     const std::string& get_log_file_path() const { return log_file_path_; }
+
+    // 注册额外的记录输出，sink须在stop()之后才能销毁
+    void add_sink(RecordSink* sink) {
+        sinks_.push_back(sink);
+    }
+
+    void set_labels(const JsonObject& labels) {
+        labels_ = labels;
+    }
+
+    // 序列化为单行JSON字符串
+    static std::string json_to_string(const JsonObject& json);
+    static std::string escape_json_string(const std::string& str);
     
+    // 当前进程的用户名，写入各记录的user字段；取不到时为unknown
+    static std::string current_user();
+
     // 辅助方法：创建常用的JSON对象
     static JsonObject create_event_log(const std::string& event_type, 
                                       const std::string& router_name,
@@ -0,0 +1,266 @@
+#include "loop_prober.h"
+#include <algorithm>
+#include <arpa/inet.h>
+#include <cerrno>
+#include <chrono>
+#include <cstring>
+#include <iterator>
+#include <linux/errqueue.h>
+#include <netinet/icmp6.h>
+#include <netinet/in.h>
+#include <netinet/ip_icmp.h>
+#include <poll.h>
+#include <sys/socket.h>
+#include <unistd.h>
+
+namespace {
+
+int64_t now_ms() {
+    return std::chrono::duration_cast<std::chrono::milliseconds>(
+        std::chrono::system_clock::now().time_since_epoch()).count();
+}
+
+std::string address_to_string(const struct sockaddr* addr) {
+    char buffer[INET6_ADDRSTRLEN] = {0};
+    if (addr->sa_family == AF_INET) {
+        inet_ntop(AF_INET, &reinterpret_cast<const struct sockaddr_in*>(addr)->sin_addr, buffer, sizeof(buffer));
+    } else if (addr->sa_family == AF_INET6) {
+        inet_ntop(AF_INET6, &reinterpret_cast<const struct sockaddr_in6*>(addr)->sin6_addr, buffer, sizeof(buffer));
+    }
+    return buffer;
+}
+
+// 每个TTL一个已连接的UDP套接字，ICMP差错进入各自的错误队列，无需按端口区分应答
+struct Probe {
+    int fd = -1;
+    bool answered = false;
+};
+
+} // namespace
+
+LoopProber::LoopProber(const std::string& target, int64_t interval_ms, int max_ttl,
+                       ActiveSource active, Callback callback)
+    : target_(target), interval_ms_(interval_ms), max_ttl_(max_ttl),
+      active_(std::move(active)), callback_(std::move(callback)) {
+}
+
+LoopProber::~LoopProber() {
+    stop();
+}
+
+void LoopProber::start() {
+    if (running_.load()) {
+        return;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&LoopProber::worker_loop, this);
+}
+
+void LoopProber::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+}
+
+bool LoopProber::valid_target(const std::string& target) {
+    uint8_t buffer[16];
+    return inet_pton(AF_INET, target.c_str(), buffer) == 1 || inet_pton(AF_INET6, target.c_str(), buffer) == 1;
+}
+
+void LoopProber::worker_loop() {
+    while (running_.load()) {
+        auto round_start = std::chrono::steady_clock::now();
+
+        if (active_()) {
+            callback_(probe_round());
+        }
+
+        // 探测本身最多等待一个间隔，应答提前收齐时补足剩余时间
+        auto deadline = round_start + std::chrono::milliseconds(interval_ms_);
+        while (running_.load() && std::chrono::steady_clock::now() < deadline) {
+            std::this_thread::sleep_for(std::chrono::milliseconds(5));
+        }
+    }
+}
+
+LoopProbeResult LoopProber::probe_round() const {
+    LoopProbeResult result;
+    result.timestamp_ms = now_ms();
+    result.hops.assign(static_cast<size_t>(max_ttl_), "");
+
+    struct sockaddr_storage dest{};
+    socklen_t dest_len;
+    int family;
+    auto* sin = reinterpret_cast<struct sockaddr_in*>(&dest);
+    auto* sin6 = reinterpret_cast<struct sockaddr_in6*>(&dest);
+    if (inet_pton(AF_INET, target_.c_str(), &sin->sin_addr) == 1) {
+        family = AF_INET;
+        sin->sin_family = AF_INET;
+        dest_len = sizeof(*sin);
+    } else if (inet_pton(AF_INET6, target_.c_str(), &sin6->sin6_addr) == 1) {
+        family = AF_INET6;
+        sin6->sin6_family = AF_INET6;
+        dest_len = sizeof(*sin6);
+    } else {
+        result.error = "invalid target " + target_;
+        return result;
+    }
+
+    std::vector<Probe> probes(static_cast<size_t>(max_ttl_));
+    const char payload[] = "converge-loop-probe";
+    for (int ttl = 1; ttl <= max_ttl_; ++ttl) {
+        Probe& probe = probes[ttl - 1];
+        probe.fd = socket(family, SOCK_DGRAM | SOCK_CLOEXEC | SOCK_NONBLOCK, IPPROTO_UDP);
+        if (probe.fd < 0) {
+            result.error = std::string("socket: ") + strerror(errno);
+            break;
+        }
+        int on = 1;
+        if (family == AF_INET) {
+            setsockopt(probe.fd, SOL_IP, IP_RECVERR, &on, sizeof(on));
+            setsockopt(probe.fd, SOL_IP, IP_TTL, &ttl, sizeof(ttl));
+            sin->sin_port = htons(static_cast<uint16_t>(BASE_PORT + ttl));
+        } else {
+            setsockopt(probe.fd, SOL_IPV6, IPV6_RECVERR, &on, sizeof(on));
+            setsockopt(probe.fd, SOL_IPV6, IPV6_UNICAST_HOPS, &ttl, sizeof(ttl));
+            sin6->sin6_port = htons(static_cast<uint16_t>(BASE_PORT + ttl));
+        }
+        // 没有到目标的路由(收敛过程中的黑洞期)时connect即失败，本轮没有任何一跳
+        if (connect(probe.fd, reinterpret_cast<struct sockaddr*>(&dest), dest_len) < 0 ||
+            send(probe.fd, payload, sizeof(payload), 0) < 0) {
+            if (result.error.empty()) {
+                result.error = strerror(errno);
+            }
+            probe.answered = true;
+        }
+    }
+
+    int terminal_ttl = max_ttl_ + 1;
+    auto deadline = std::chrono::steady_clock::now() + std::chrono::milliseconds(interval_ms_);
+    while (true) {
+        std::vector<struct pollfd> fds;
+        std::vector<int> ttls;
+        for (int ttl = 1; ttl <= max_ttl_ && ttl < terminal_ttl; ++ttl) {
+            const Probe& probe = probes[ttl - 1];
+            if (probe.fd >= 0 && !probe.answered) {
+                fds.push_back({probe.fd, 0, 0});  // POLLERR总是会报告
+                ttls.push_back(ttl);
+            }
+        }
+        auto remaining = std::chrono::duration_cast<std::chrono::milliseconds>(
+            deadline - std::chrono::steady_clock::now()).count();
+        if (fds.empty() || remaining <= 0) {
+            break;
+        }
+        if (poll(fds.data(), fds.size(), static_cast<int>(remaining)) <= 0) {
+            continue;
+        }
+
+        for (size_t i = 0; i < fds.size(); ++i) {
+            if (!(fds[i].revents & POLLERR)) {
+                continue;
+            }
+            int ttl = ttls[i];
+            probes[ttl - 1].answered = true;
+
+            char data[64];
+            char control[512];
+            struct sockaddr_storage from{};
+            struct iovec iov{data, sizeof(data)};
+            struct msghdr msg{};
+            msg.msg_name = &from;
+            msg.msg_namelen = sizeof(from);
+            msg.msg_iov = &iov;
+            msg.msg_iovlen = 1;
+            msg.msg_control = control;
+            msg.msg_controllen = sizeof(control);
+            if (recvmsg(fds[i].fd, &msg, MSG_ERRQUEUE) < 0) {
+                continue;
+            }
+
+            for (struct cmsghdr* cmsg = CMSG_FIRSTHDR(&msg); cmsg; cmsg = CMSG_NXTHDR(&msg, cmsg)) {
+                bool is_v4 = cmsg->cmsg_level == SOL_IP && cmsg->cmsg_type == IP_RECVERR;
+                bool is_v6 = cmsg->cmsg_level == SOL_IPV6 && cmsg->cmsg_type == IPV6_RECVERR;
+                if (!is_v4 && !is_v6) {
+                    continue;
+                }
+                const auto* ee = reinterpret_cast<const struct sock_extended_err*>(CMSG_DATA(cmsg));
+                if (ee->ee_origin != SO_EE_ORIGIN_ICMP && ee->ee_origin != SO_EE_ORIGIN_ICMP6) {
+                    continue;  // 本地错误(如邻居不可达)没有应答地址
+                }
+                result.hops[ttl - 1] = address_to_string(SO_EE_OFFENDER(ee));
+                bool time_exceeded = is_v4 ? ee->ee_type == ICMP_TIME_EXCEEDED : ee->ee_type == ICMP6_TIME_EXCEEDED;
+                if (!time_exceeded) {
+                    // 目标的端口不可达表示到达；其他不可达由中间路由器发出，路径同样在此结束
+                    terminal_ttl = std::min(terminal_ttl, ttl);
+                    bool port_unreachable = is_v4
+                        ? ee->ee_type == ICMP_DEST_UNREACH && ee->ee_code == ICMP_PORT_UNREACH
+                        : ee->ee_type == ICMP6_DST_UNREACH && ee->ee_code == ICMP6_DST_UNREACH_NOPORT;
+                    if (port_unreachable) {
+                        result.reached = true;
+                    }
+                }
+            }
+        }
+    }
+
+    for (auto& probe : probes) {
+        if (probe.fd >= 0) {
+            close(probe.fd);
+        }
+    }
+
+    // 终点之后的TTL也会到达终点，不属于路径
+    if (terminal_ttl <= max_ttl_) {
+        result.hops.resize(static_cast<size_t>(terminal_ttl));
+    }
+    result.loop = detect_loop(result.hops);
+    return result;
+}
+
+std::vector<std::string> LoopProber::detect_loop(const std::vector<std::string>& hops) {
+    for (size_t i = 0; i < hops.size(); ++i) {
+        if (hops[i].empty()) {
+            continue;
+        }
+        for (size_t j = i + 1; j < hops.size(); ++j) {
+            if (hops[j] == hops[i]) {
+                // 环上未应答(被限速等)的跳不列出
+                std::vector<std::string> loop;
+                std::copy_if(hops.begin() + i, hops.begin() + j, std::back_inserter(loop),
+                             [](const std::string& hop) { return !hop.empty(); });
+                return loop;
+            }
+        }
+    }
+    return {};
+}
+
+void MicroLoopState::finish() {
+    if (start_time.has_value()) {
+        duration_ms += last_probe_time - start_time.value();
+        start_time.reset();
+        unresolved = true;
+    }
+}
+
+void MicroLoopState::add_to_json(JsonObject& obj) const {
+    obj["loop_probe_rounds"] = static_cast<int64_t>(probe_rounds);
+    obj["micro_loop_detected"] = events > 0;
+    if (events > 0) {
+        obj["micro_loop_onset_ms"] = onset_offset.value();
+        obj["micro_loop_duration_ms"] = duration_ms;
+        obj["micro_loop_rounds"] = static_cast<int64_t>(rounds);
+        obj["micro_loop_events"] = static_cast<int64_t>(events);
+        obj["micro_loop_members"] = members;
+        if (unresolved) {
+            obj["micro_loop_unresolved"] = true;
+        }
+    }
+}
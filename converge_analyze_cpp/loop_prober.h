@@ -0,0 +1,77 @@
+#pragma once
+
+#include "logger.h"
+#include <atomic>
+#include <functional>
+#include <optional>
+#include <string>
+#include <thread>
+#include <vector>
+
+// 一轮traceroute的结果：hops[i]为TTL=i+1的应答地址，无应答为空
+struct LoopProbeResult {
+    int64_t timestamp_ms = 0;  // 本轮开始的时间
+    std::vector<std::string> hops;
+    bool reached = false;      // 是否收到目标本身的应答(端口不可达)
+    std::vector<std::string> loop;  // 检测到环路时环上的地址(按跳数顺序)，否则为空
+    std::string error;         // 本轮无法发出探测的原因(如没有到目标的路由)
+};
+
+// 一个会话内的微环路：最早出现的时间(相对触发)、累计时长与探测轮数
+struct MicroLoopState {
+    int probe_rounds = 0;
+    int rounds = 0;    // 检测到环路的轮数
+    int events = 0;    // 环路出现的次数，消失后再次出现另计一次
+    std::optional<int64_t> onset_offset;
+    std::optional<int64_t> start_time;  // 当前环路开始的时间，未处于环路时为空
+    int64_t last_probe_time = 0;
+    int64_t duration_ms = 0;
+    std::string members;  // 最近一次出现时环上的地址
+    bool unresolved = false;  // 会话结束时环路仍未消失
+
+    // 会话结束：仍在环路中的按最后一轮探测计算时长
+    void finish();
+    // 写入session_completed的loop_probe_rounds与micro_loop_*字段
+    void add_to_json(JsonObject& obj) const;
+};
+
+// 会话期间以traceroute方式周期性探测目标(--loop-probe)：同一地址在两个不同的TTL上出现即为转发环路。
+// 微环路发生在FIB更新不同步的路由器之间，本机路由表事件中看不到，只能从数据面观察。
+// 使用UDP探测与IP_RECVERR读取ICMP差错，不需要原始套接字
+class LoopProber {
+public:
+    using Callback = std::function<void(const LoopProbeResult&)>;
+    // 是否有进行中的会话，没有时不发送探测
+    using ActiveSource = std::function<bool()>;
+
+    static constexpr int BASE_PORT = 33434;  // 与traceroute相同的起始目的端口
+
+private:
+    std::string target_;
+    int64_t interval_ms_;
+    int max_ttl_;
+    ActiveSource active_;
+    Callback callback_;
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+
+    void worker_loop();
+    LoopProbeResult probe_round() const;
+
+public:
+    LoopProber(const std::string& target, int64_t interval_ms, int max_ttl,
+               ActiveSource active, Callback callback);
+    ~LoopProber();
+
+    LoopProber(const LoopProber&) = delete;
+    LoopProber& operator=(const LoopProber&) = delete;
+
+    void start();
+    void stop();
+
+    // 目标须为IPv4/IPv6地址
+    static bool valid_target(const std::string& target);
+
+    // 同一地址出现在两个TTL上时返回从第一次出现到再次出现之前的各跳(环上的地址)，否则为空
+    static std::vector<std::string> detect_loop(const std::vector<std::string>& hops);
+};
@@ -1,30 +1,76 @@
+#include <algorithm>
 #include <iomanip>
 #include <iostream>
 #include <memory>
 #include <signal.h>
 #include <getopt.h>
 #include <unistd.h>
-#include <pwd.h>
 #include <chrono>
 #include <thread>
 #include <atomic>
 #include <csignal>
+#include <sys/stat.h>
+#include <climits>
 
 #include "convergence_monitor.h"
 #include "logger.h"
+#include "http_client.h"
+#include "cli_utils.h"
+#include "junit_report.h"
+#include "report.h"
+#include "compare.h"
+#include "query.h"
+#include "analyze.h"
+#include "schema.h"
+#include "merge.h"
+#include "inject.h"
+#include "campaign.h"
+#include "clab_inject.h"
+#include "netns.h"
+#include "container_discovery.h"
+#include "i18n.h"
+#include "timestamp_format.h"
+#include "debug_log.h"
+#include "daemon.h"
+#include "preflight.h"
 
 // Global shutdown flag
 std::atomic<bool> shutdown_requested{false};
+// 关闭过程中再次收到SIGINT/SIGTERM：由强制退出线程写完日志后立即退出
+std::atomic<bool> force_exit_requested{false};
+// SIGUSR1：由主循环输出状态报告
+std::atomic<bool> status_requested{false};
+// SIGHUP：由主循环重新加载--config
+std::atomic<bool> reload_requested{false};
+// --output -：下游关闭了管道(如 | head)，按正常结束处理，日志文件仍写完最终统计
+std::atomic<bool> stdout_closed{false};
 std::unique_ptr<ConvergenceMonitor> global_monitor;
 
 void signal_handler(int signal) {
-    std::cout << "\n🛑 接收到信号 " << signal << "，正在优雅关闭...\n";
+    // 第二次信号：正常关闭可能卡在挂起的netlink读取等操作上，不再等待
+    if (shutdown_requested.exchange(true)) {
+        force_exit_requested.store(true);
+        return;
+    }
+    std::cout << "\n🛑 " << tr("接收到信号 ", "Received signal ") << signal
+              << tr("，正在优雅关闭... (再按一次Ctrl+C立即退出)",
+                    ", shutting down gracefully... (press Ctrl+C again to exit immediately)") << "\n";
+
+    // 只设置标志，由主循环停止监控器：信号可能投递到工作线程，
+    // 在处理函数中join线程会导致自我join(EDEADLK)
+}
+
+void pipe_signal_handler(int) {
+    stdout_closed.store(true);
     shutdown_requested.store(true);
+}
 
-    // 立即停止监控器以中断阻塞的系统调用
-    if (global_monitor) {
-        global_monitor->stop_monitoring();
-    }
+void status_signal_handler(int) {
+    status_requested.store(true);
+}
+
+void reload_signal_handler(int) {
+    reload_requested.store(true);
 }
 
 void print_usage(const char* program_name) {
@@ -39,6 +85,7 @@ void print_usage(const char* program_name) {
     std::cout << "         * Netem命令: tc qdisc add dev eth0 root netem delay 10ms\n";
     std::cout << "         * 路由添加: ip route add 192.168.1.0/24 via 10.0.0.1\n";
     std::cout << "         * 路由删除: ip route del 192.168.1.0/24\n";
+    std::cout << "         * 策略路由规则: ip rule add from 10.0.0.0/8 table 100\n";
     std::cout << "    3. 观察路由收敛过程和时间测量\n\n";
     std::cout << "  C++多线程特性:\n";
     std::cout << "    - 多线程并发处理netlink事件\n";
@@ -50,53 +97,1138 @@ void print_usage(const char* program_name) {
     std::cout << "示例:\n";
     std::cout << "  " << program_name << " --threshold 3000 --router-name spine1\n";
     std::cout << "  " << program_name << " --threshold 5000 --router-name leaf2 --log-path /tmp/my_convergence.json\n";
-    std::cout << "  " << program_name << " --log-path ./logs/convergence_cpp.json\n\n";
+    std::cout << "  " << program_name << " --log-path ./logs/convergence_cpp.json\n";
+    std::cout << "  " << program_name << " --alert-webhook http://10.0.0.100:8080/alert --alert-threshold 2000\n";
+    std::cout << "  " << program_name << " --sla-ms 1500 --duration 10m --junit ./convergence-junit.xml\n";
+    std::cout << "  " << program_name << " --netns-prefix clab-dc1- --log-dir ./logs\n";
+    std::cout << "  " << program_name << " --container-prefix clab-dc1- --log-dir ./logs\n\n";
+    std::cout << "子命令:\n";
+    std::cout << "  report     根据已有日志生成报告 (" << program_name << " report --help)\n";
+    std::cout << "  compare    对比两次运行的收敛指标 (" << program_name << " compare --help)\n";
+    std::cout << "  query      按条件筛选会话并重新汇总 (" << program_name << " query --help)\n";
+    std::cout << "  merge      合并多节点日志并按故障对齐 (" << program_name << " merge --help)\n";
+    std::cout << "  analyze    按新的阈值/过滤条件重放已有日志，输出what-if对比 (" << program_name << " analyze --help)\n";
+    std::cout << "  schema     输出日志记录的JSON Schema或校验日志 (" << program_name << " schema --help)\n";
+    std::cout << "  inject     施加netem故障并同时测量收敛 (" << program_name << " inject --help)\n";
+    std::cout << "  campaign   按YAML故障计划批量注入并标记会话 (" << program_name << " campaign --help)\n";
+    std::cout << "  clab       通过clab tools netem在containerlab节点上注入故障 (" << program_name << " clab --help)\n\n";
     std::cout << "选项:\n";
     std::cout << "  -t, --threshold MILLISECONDS  收敛判断阈值(毫秒，默认3000ms)\n";
-    std::cout << "  -r, --router-name NAME        路由器名称标识，用于日志记录(默认自动生成)\n";
+    std::cout << "      --threshold-override RULES 按接口或触发类型覆盖阈值，如 eth0=1000,eth*=3000,type:qdisc=5000 (可重复，第一条命中的生效)\n";
+    std::cout << "  -r, --router-name NAME        路由器名称标识，用于日志记录(默认: 环境变量CONVERGE_ROUTER_NAME，否则为主机名)\n";
+    std::cout << "      --router-name-prefix PREFIX 以主机名作为默认名称时加上的前缀(如 dc1-)\n";
     std::cout << "  -l, --log-path PATH           日志文件路径(默认: /var/log/frr/async_route_convergence_cpp.json)\n";
+    std::cout << "      --alert-webhook URL       会话收敛过慢或超时时POST会话JSON到该地址(仅支持http://)\n";
+    std::cout << "      --alert-threshold MS      告警阈值(毫秒)，收敛时间超过该值即告警(默认仅超时告警)\n";
+    std::cout << "      --on-session-complete CMD 每个会话结束后执行命令(/bin/sh -c)，stdin为会话JSON，并设置CONVERGE_SESSION_*环境变量\n";
+    std::cout << "      --hook-timeout DURATION   钩子命令超时 (默认: 60s)，超时后结束其进程组\n";
+    std::cout << "      --sla-ms MILLISECONDS     SLA阈值，任一会话超过该值或超时则以退出码2结束\n";
+    std::cout << "      --max-session-duration DURATION 单个会话的最长时长(如 60s)，超过仍未收敛按超时结束 (默认: 不限)\n";
+    std::cout << "      --baseline PATH           已知正常运行的日志，退出时对比收敛时间等指标的均值/P95，回归则以退出码4结束\n";
+    std::cout << "      --baseline-tolerance PCT  均值或P95恶化超过该百分比且显著时判定为回归 (默认: 10)\n";
+    std::cout << "      --baseline-alpha P        与基线对比的显著性水平 (默认: 0.05)\n";
+    std::cout << "      --duration DURATION       监听时长(如 90s、10m、1h)，到期后自动输出报告并退出\n";
+    std::cout << "      --max-sessions N          完成N个收敛会话后自动输出报告并退出\n";
+    std::cout << "      --warmup DURATION         启动后的预热时长(如 10s)，期间的事件不触发会话\n";
+    std::cout << "      --resume                  从--log-path指向的已有日志恢复会话编号与累计统计，继续追加写入\n";
+    std::cout << "      --dry-run                 只检查配置、netlink/tc订阅权限与日志路径并列出将监控的接口，不开始监控\n";
+    std::cout << "      --route-only              只监控路由，不订阅qdisc变化(netem不再触发会话)；tc监控不被允许时自动进入此模式\n";
+    std::cout << "      --junit PATH              退出时写入JUnit XML报告(每个会话一个testcase，按--sla-ms判定)\n";
+    std::cout << "      --tag KEY=VALUE           为每个会话附加标签字段(可重复，如 --tag clab_node=leaf1)\n";
+    std::cout << "      --label KEY=VALUE         为每条记录附加label_KEY字段(可重复，如 --label experiment=exp42 --label frr=9.1)\n";
+    std::cout << "      --topology PATH           containerlab拓扑文件，为事件标注逻辑链路(如 spine1:e1-1 <-> leaf2:e1-1)\n";
+    std::cout << "      --clab-node NAME          本机在拓扑中的节点名(默认同--router-name)\n";
+    std::cout << "      --netns NAME|PATH         在指定网络命名空间内监听(名称对应/var/run/netns/NAME)\n";
+    std::cout << "      --netns-all               为/var/run/netns下每个命名空间启动一个监控器子进程\n";
+    std::cout << "      --netns-prefix PREFIX     同--netns-all，仅匹配前缀(如 clab-dc1-)\n";
+    std::cout << "      --log-dir DIR             多命名空间模式下的日志目录(每个命名空间一个NAME.json，默认当前目录)\n";
+    std::cout << "      --container-prefix PREFIX 发现名称以PREFIX开头的运行中容器，进入其命名空间各启动一个监控器\n";
+    std::cout << "      --container-runtime CLI   容器CLI(docker/nerdctl/podman，默认docker)\n";
+    std::cout << "      --frr-state               在触发与收敛时通过vtysh采集RIB计数与BGP邻居状态\n";
+    std::cout << "      --vtysh PATH              vtysh可执行文件(隐含--frr-state，默认vtysh)\n";
+    std::cout << "      --frr-log PATH            跟踪FRR日志，将SPF/最优路径行按时间关联到当前会话\n";
+    std::cout << "      --bmp-listen [ADDR:]PORT  启动内嵌BMP采集器，接收BGP通告/撤销并关联到当前会话\n";
+    std::cout << "      --igp-adjacency           轮询OSPF/IS-IS邻接状态，记录变化并用于收敛阶段分解\n";
+    std::cout << "      --igp-poll-ms MS          邻接状态轮询间隔 (默认: 200ms)\n";
+    std::cout << "      --gnmi ADDR:PORT          通过gnmic订阅非Linux设备(SR Linux/cEOS)的路由与接口变化\n";
+    std::cout << "      --gnmi-path PATH          订阅路径，可重复 (默认: OpenConfig AFT与接口oper-status)\n";
+    std::cout << "      --gnmi-username USER      gNMI用户名\n";
+    std::cout << "      --gnmi-password PASS      gNMI密码\n";
+    std::cout << "      --gnmi-insecure           使用明文gRPC (默认TLS并跳过证书校验)\n";
+    std::cout << "      --gnmi-encoding ENC       gNMI编码 (默认: json_ietf)\n";
+    std::cout << "      --gnmic PATH              gnmic可执行文件 (默认: gnmic)\n";
+    std::cout << "      --snmp-trap-listen [ADDR:]PORT  接收SNMP v1/v2c Trap，链路与路由协议trap作为触发/路由事件\n";
+    std::cout << "      --snmp-community STR      只接受该community的trap (默认全部接受)\n";
+    std::cout << "      --clock-reference HOST[:PORT] 用SNTP测量相对该参考(NTP服务器或--clock-listen的监控器)的时钟偏差，\n";
+    std::cout << "                                写出clock_offset记录供merge校正跨节点对齐\n";
+    std::cout << "      --clock-interval DURATION 时钟偏差测量间隔 (默认: 10s)\n";
+    std::cout << "      --clock-listen [ADDR:]PORT 以本机时钟回答SNTP请求，作为其他监控器的时钟参考\n";
+    std::cout << "      --source SPEC             加载事件源插件(可重复)，已注册:";
+    for (const auto& source : EventSourceRegistry::list()) {
+        std::cout << " " << source.first;
+    }
+    std::cout << "\n";
+    for (const auto& source : EventSourceRegistry::list()) {
+        std::cout << "                                  " << source.first << ": " << source.second << "\n";
+    }
+    std::cout << "      --output URL              额外输出，可重复: stdout、file:///PATH、http://HOST:PORT/PATH、\n";
+    std::cout << "                                -(stdout只输出NDJSON记录，控制台提示改到stderr)、\n";
+    std::cout << "                                kafka://BROKER[:PORT][,...]/TOPIC、influx+http://HOST:PORT/write?db=DB、\n";
+    std::cout << "                                influx+file:///PATH、otlp://HOST[:PORT] (OTLP/HTTP trace，默认端口4318)、\n";
+    std::cout << "                                parquet:///DIR、prometheus://[ADDR:]PORT、grafana://[ADDR:]PORT、\n";
+    std::cout << "                                syslog://HOST[:PORT]、syslog+tcp://HOST[:PORT]、syslog:///dev/log、\n";
+    std::cout << "                                grpc://[ADDR:]PORT (gRPC流式推送，见convergence.proto)\n";
+    std::cout << "      --output-key FIELD        作为Kafka消息key的记录字段 (默认: router_name，none表示不设置)\n";
+    std::cout << "      --store sqlite:PATH       将运行信息、会话和事件写入SQLite数据库，多次运行追加\n";
+    std::cout << "      --tui                     终端仪表盘: 当前状态、事件速率与最近会话，代替逐行输出\n";
+    std::cout << "      --lang zh|en|auto         控制台输出语言 (默认: zh，auto按LANG选择)；JSON记录不受影响\n";
+    std::cout << "      --timestamp-format FORMAT 时间戳格式 rfc3339|rfc3339nano|epoch-ms|epoch-ns (默认: rfc3339，毫秒精度)，同时用于控制台和JSON记录\n";
+    std::cout << "      --timezone ZONE           时间戳时区 UTC|local|±HH:MM|IANA名称如Asia/Shanghai (默认: JSON为UTC，控制台为本地时间)\n";
+    std::cout << "      --log-level LEVEL         控制台日志级别 debug|info|warn (默认: info)；debug将原始netlink/tc消息写入调试日志\n";
+    std::cout << "      --debug-log PATH          调试日志路径 (默认: JSON日志路径加.debug后缀)\n";
+    std::cout << "      --control-socket PATH     Unix控制套接字: status、force-finish、reset-stats、set-threshold MS等\n";
+    std::cout << "      --health-listen [ADDR:]PORT  HTTP健康检查端点: /healthz(netlink订阅存活)、/readyz(可以测量)，失败时返回503\n";
+    std::cout << "      --health-max-idle DURATION 超过该时长没有收到路由消息时/healthz失败(如 10m，默认不检查)\n";
+    std::cout << "      --filter-interface NAME   只处理该接口上的路由/qdisc事件(可重复)\n";
+    std::cout << "      --filter-prefix CIDR      只处理目的地址落在该前缀内的路由事件(可重复)\n";
+    std::cout << "      --tables LIST             只处理这些路由表(名称或ID，逗号分隔，可重复)的路由/规则事件，\n";
+    std::cout << "                                名称取自/etc/iproute2/rt_tables与VRF设备\n";
+    std::cout << "      --trigger-rule EXPR       触发规则表达式(可重复，任一命中即触发)，如 'qdisc.kind == \"netem\" && iface =~ \"eth[1-4]\"'，\n";
+    std::cout << "                                代替默认的netem/路由事件判定；default表示默认规则\n";
+    std::cout << "      --ignore-rule EXPR        命中的qdisc/路由事件既不触发也不计入会话(可重复)\n";
+    std::cout << "      --config PATH             运行时配置文件(YAML: threshold_ms、filter_interfaces、filter_prefixes、filter_tables)，SIGHUP重新加载\n";
+    std::cout << "      --netlink-buffer N        netlink接收队列容量(消息条数，默认: 4096)，积压超过时丢弃并计入统计\n";
+    std::cout << "      --netlink-workers N       并行解析netlink消息的线程数 (默认: 2)，事件仍按接收顺序处理\n";
+    std::cout << "      --mroute                  同时监听组播转发缓存(MFC)变化，用于测量PIM收敛\n";
+    std::cout << "      --fdb                     同时监听网桥/VXLAN转发表(MAC表项增删与迁移)，用于测量EVPN收敛\n";
+    std::cout << "      --tunnels                 跟踪GRE/IPIP/VXLAN/WireGuard等隧道的创建删除、端点变化与链路状态\n";
+    std::cout << "      --wireguard-poll-ms MS    WireGuard对端端点轮询间隔 (默认: 1000ms，隐含--tunnels)\n";
+    std::cout << "      --bonding                 跟踪bond/team活动成员切换、成员状态与LACP状态，用于对比网卡级切换与路由收敛\n";
+    std::cout << "      --link-events             记录接口MTU、混杂模式与主从关系变化(link_event)，标注所在会话\n";
+    std::cout << "      --watch-default           单独跟踪默认路由的丢失与恢复，每个会话报告默认路由恢复用时与下一跳变化\n";
+    std::cout << "      --nexthop-cache N         前缀到下一跳缓存的容量(默认: 100000，0表示关闭)，前缀改指时写出nexthop_changed\n";
+    std::cout << "      --frr-window-ms MS        触发后该时间内的下一跳切换视为快速重路由(LFA/TI-LFA)生效 (默认: 10，0表示不区分)\n";
+    std::cout << "      --converged-when-prefix CIDR 该前缀被安装的时刻即为收敛，会话立即结束；未出现时仍按静默期判定\n";
+    std::cout << "      --via IFACE|GW            与--converged-when-prefix同用，只认出接口或网关为该值的安装\n";
+    std::cout << "      --loop-probe ADDR         会话期间以traceroute方式探测该地址，检测并记录微环路的出现与持续时间\n";
+    std::cout << "      --loop-probe-ms MS        微环路探测间隔，也是每轮等待应答的上限 (默认: 50ms)\n";
+    std::cout << "      --loop-probe-max-ttl N    微环路探测的最大TTL (默认: 16)\n";
+    std::cout << "      --pcap-dir DIR            每个会话从触发到收敛用tcpdump抓取协议报文，pcap文件保存在该目录\n";
+    std::cout << "      --pcap-interface IFACE    抓包接口，可重复 (默认: any)\n";
+    std::cout << "      --pcap-filter EXPR        BPF过滤表达式 (默认: BGP/OSPF/BFD)\n";
+    std::cout << "      --pcap-max-packets N      每个pcap文件的包数上限 (默认: 100000)\n";
+    std::cout << "      --tcpdump PATH            tcpdump可执行文件 (默认: tcpdump)\n";
+    std::cout << "      --interface-counters      在触发与收敛时读取接口收发/丢弃/错误计数，记录每个会话的差值\n";
+    std::cout << "      --qdisc-stats-ms MS       会话期间每隔MS读取触发接口上netem(或根qdisc)的积压/丢包/重新入队统计 (默认: 0不读取)\n";
+    std::cout << "      --fib-trace               用eBPF kprobe记录IPv4路由写入内核FIB的时间，额外按内核时间计算收敛时间\n";
+    std::cout << "      --dataplane-probe DST[:PORT] 记录触发后第一个发往DST(可选TCP/UDP端口)的报文被转发的内核时间，即数据面恢复时间\n";
+    std::cout << "      --dataplane-interface IFACE 挂载数据面探测的接口(恢复路径的出接口)，可重复，--dataplane-probe时必需\n";
+    std::cout << "      --anomaly-sigma N         收敛时间偏离滚动基线超过N倍标准差(或CUSUM检测到持续偏移)时记录anomaly_detected\n";
+    std::cout << "      --anomaly-window N        滚动基线包含的最近会话数 (默认: 30)\n";
+    std::cout << "      --event-detail MODE       路由事件明细 full|summary (默认: full)；summary只按秒记录事件数，适合超大路由表\n";
+    std::cout << "      --console-detail MODE     控制台逐事件输出 off|summary|full (默认: summary)；summary时明细行限速并按秒汇总路由事件数，JSON日志不受影响\n";
+    std::cout << "  -q, --quiet                   不输出控制台信息(警告与错误仍写到stderr)，便于在自动化脚本中使用\n";
+    std::cout << "      --console-format FORMAT   控制台格式 text|json (默认: text)；json时stdout只输出与日志相同的JSON行，其余信息不输出\n";
+    std::cout << "      --trigger-debounce-ms MS  netem触发后该时间内同一接口的qdisc事件并入触发(如先del后add) (默认: 50，0关闭)\n";
+    std::cout << "      --qdisc-cache-size N      按接口+handle缓存的qdisc条数，用于推断netem删除 (默认: 256)\n";
+    std::cout << "      --qdisc-cache-ttl DURATION qdisc缓存条目的存活时间 (默认: 5m)\n";
+    std::cout << "      --coalesce-ms MS          合并窗口内类型/前缀/下一跳相同的路由事件日志，记录coalesced_count (默认: 0不合并)\n";
+    std::cout << "      --max-events-in-memory N  内存中最多保留的详细路由事件数 (默认: 0不限)，超出后只保留会话摘要\n";
+    std::cout << "      --churn-rate              持续记录每秒路由变化数(route_churn_rate)，不限于收敛会话期间\n";
+    std::cout << "      --route-table-sample DURATION 每隔该时长记录一次内核路由表规模(按协议族/路由表计数，如 30s)\n";
+    std::cout << "      --daemon                  常驻运行: systemd下发送sd_notify READY/STOPPING，否则转入后台；控制台输出不含emoji\n";
+    std::cout << "      --pidfile PATH            写入pid文件并加锁，防止重复启动，退出时删除\n";
     std::cout << "  -h, --help                    显示此帮助信息\n";
+    std::cout << "\n每个长选项都可以用环境变量CONVERGE_<选项名>设置(大写，'-'换成'_'，如 CONVERGE_LOG_PATH)，命令行优先\n";
 }
 
-std::string get_current_user() {
-    struct passwd* pw = getpwuid(getuid());
-    return pw ? std::string(pw->pw_name) : "unknown";
-}
-
-std::string generate_router_name() {
+// 默认以主机名(容器中即containerlab节点的容器名)作为路由器名称；取不到主机名时退回 router_<用户>_<时间戳>
+std::string generate_router_name(const std::string& prefix) {
+    char hostname[HOST_NAME_MAX + 1] = "";
+    if (gethostname(hostname, sizeof(hostname)) == 0 && hostname[0] != '\0') {
+        hostname[HOST_NAME_MAX] = '\0';
+        return prefix + hostname;
+    }
     auto now = std::chrono::system_clock::now();
     auto time_t = std::chrono::system_clock::to_time_t(now);
-    return "router_" + get_current_user() + "_" + std::to_string(time_t);
+    return "router_" + Logger::current_user() + "_" + std::to_string(time_t);
+}
+
+// 仅有长选项的参数编号
+enum LongOnlyOption {
+    OPT_ALERT_WEBHOOK = 1000,
+    OPT_THRESHOLD_OVERRIDE,
+    OPT_ALERT_THRESHOLD,
+    OPT_ON_SESSION_COMPLETE,
+    OPT_HOOK_TIMEOUT,
+    OPT_SLA_MS,
+    OPT_BASELINE,
+    OPT_BASELINE_TOLERANCE,
+    OPT_BASELINE_ALPHA,
+    OPT_DURATION,
+    OPT_JUNIT,
+    OPT_TAG,
+    OPT_LABEL,
+    OPT_ROUTER_NAME_PREFIX,
+    OPT_TOPOLOGY,
+    OPT_CLAB_NODE,
+    OPT_NETNS,
+    OPT_NETNS_ALL,
+    OPT_NETNS_PREFIX,
+    OPT_LOG_DIR,
+    OPT_CONTAINER_PREFIX,
+    OPT_CONTAINER_RUNTIME,
+    OPT_FRR_STATE,
+    OPT_VTYSH,
+    OPT_FRR_LOG,
+    OPT_BMP_LISTEN,
+    OPT_IGP_ADJACENCY,
+    OPT_IGP_POLL_MS,
+    OPT_GNMI,
+    OPT_GNMI_PATH,
+    OPT_GNMI_USERNAME,
+    OPT_GNMI_PASSWORD,
+    OPT_GNMI_INSECURE,
+    OPT_GNMI_ENCODING,
+    OPT_GNMIC,
+    OPT_SNMP_TRAP_LISTEN,
+    OPT_SNMP_COMMUNITY,
+    OPT_SOURCE,
+    OPT_OUTPUT,
+    OPT_OUTPUT_KEY,
+    OPT_STORE,
+    OPT_TUI,
+    OPT_LANG,
+    OPT_TIMESTAMP_FORMAT,
+    OPT_TIMEZONE,
+    OPT_LOG_LEVEL,
+    OPT_DEBUG_LOG,
+    OPT_CONTROL_SOCKET,
+    OPT_FILTER_INTERFACE,
+    OPT_FILTER_PREFIX,
+    OPT_TABLES,
+    OPT_TRIGGER_RULE,
+    OPT_IGNORE_RULE,
+    OPT_CONFIG,
+    OPT_NETLINK_BUFFER,
+    OPT_NETLINK_WORKERS,
+    OPT_MAX_EVENTS_IN_MEMORY,
+    OPT_COALESCE_MS,
+    OPT_EVENT_DETAIL,
+    OPT_CONSOLE_DETAIL,
+    OPT_CONSOLE_FORMAT,
+    OPT_CHURN_RATE,
+    OPT_ROUTE_TABLE_SAMPLE,
+    OPT_DAEMON,
+    OPT_MAX_SESSIONS,
+    OPT_MAX_SESSION_DURATION,
+    OPT_WARMUP,
+    OPT_RESUME,
+    OPT_DRY_RUN,
+    OPT_ROUTE_ONLY,
+    OPT_TRIGGER_DEBOUNCE_MS,
+    OPT_QDISC_CACHE_SIZE,
+    OPT_QDISC_CACHE_TTL,
+    OPT_MROUTE,
+    OPT_FDB,
+    OPT_TUNNELS,
+    OPT_WIREGUARD_POLL_MS,
+    OPT_BONDING,
+    OPT_LINK_EVENTS,
+    OPT_WATCH_DEFAULT,
+    OPT_NEXTHOP_CACHE,
+    OPT_FRR_WINDOW_MS,
+    OPT_CONVERGED_WHEN_PREFIX,
+    OPT_VIA,
+    OPT_LOOP_PROBE,
+    OPT_LOOP_PROBE_MS,
+    OPT_LOOP_PROBE_MAX_TTL,
+    OPT_PCAP_DIR,
+    OPT_PCAP_INTERFACE,
+    OPT_PCAP_FILTER,
+    OPT_PCAP_MAX_PACKETS,
+    OPT_TCPDUMP,
+    OPT_INTERFACE_COUNTERS,
+    OPT_QDISC_STATS_MS,
+    OPT_FIB_TRACE,
+    OPT_DATAPLANE_PROBE,
+    OPT_DATAPLANE_INTERFACE,
+    OPT_PIDFILE,
+    OPT_ANOMALY_SIGMA,
+    OPT_ANOMALY_WINDOW,
+    OPT_HEALTH_LISTEN,
+    OPT_HEALTH_MAX_IDLE,
+    OPT_CLOCK_REFERENCE,
+    OPT_CLOCK_INTERVAL,
+    OPT_CLOCK_LISTEN,
+};
+
+// 退出码：SLA未达标
+constexpr int EXIT_SLA_VIOLATED = 2;
+// 退出码：相对--baseline回归，与SLA未达标区分；两者同时发生时返回EXIT_SLA_VIOLATED
+constexpr int EXIT_REGRESSION = 4;
+// 退出码：第二次Ctrl+C强制退出，与shell对SIGINT的约定一致
+constexpr int EXIT_FORCED = 130;
+// 退出码：启动前检查发现无法开始监控的问题(无法订阅路由、日志不可写)
+constexpr int EXIT_PREFLIGHT = 3;
+
+// 失败的检查输出到stderr：错误码、详情与修正建议，--console-format json时为单行JSON
+void print_preflight_failure(const PreflightCheck& check, bool json) {
+    if (json) {
+        std::cerr << check.to_json() << "\n";
+        return;
+    }
+    std::cerr << (check.severity == "error" ? "❌ " : "⚠️  ") << "[" << check.code << "] " << check.name << ": "
+              << check.detail << "\n";
+    if (!check.hint.empty()) {
+        std::cerr << "   💡 " << check.hint << "\n";
+    }
+}
+
+// 正式运行前的检查：error级失败时返回false；tc监控不被允许时降级为只监控路由，其余warning写入日志
+bool apply_preflight(MonitorConfig& config, bool json) {
+    bool passed = true;
+    for (const auto& check : run_preflight(config)) {
+        if (check.ok) {
+            continue;
+        }
+        print_preflight_failure(check, json);
+        if (check.severity == "error") {
+            passed = false;
+            continue;
+        }
+        if (check.route_only && !config.route_only) {
+            config.route_only = true;
+            std::cerr << "⚠️  " << tr("tc监控不可用，降级为只监控路由(netem变化不再触发会话)",
+                                      "tc monitoring unavailable, falling back to route-only monitoring "
+                                      "(netem changes no longer trigger sessions)") << "\n";
+        }
+        config.preflight_warnings.push_back(check.code + ": " + check.name + ": " + check.detail);
+    }
+    return passed;
+}
+
+// --dry-run：逐项输出检查结果与将监控的接口，没有error级失败时返回0
+int run_dry_run(const MonitorConfig& config) {
+    info_out() << "\n🧪 " << tr("预检 (--dry-run)，不开始监控", "Pre-flight checks (--dry-run), not monitoring") << "\n";
+    bool passed = true;
+    for (const auto& check : run_preflight(config)) {
+        const char* mark = check.ok ? "✅ " : (check.severity == "error" ? "❌ " : "⚠️  ");
+        passed = passed && (check.ok || check.severity != "error");
+        info_out() << "   " << mark << std::left << std::setw(16) << check.name << check.detail;
+        if (!check.ok) {
+            info_out() << " [" << check.code << "]";
+        }
+        info_out() << "\n";
+        if (!check.ok && !check.hint.empty()) {
+            info_out() << "      💡 " << check.hint << "\n";
+        }
+    }
+
+    auto interfaces = list_monitored_interfaces(config);
+    size_t monitored = 0;
+    for (const auto& iface : interfaces) {
+        monitored += iface.filtered ? 0 : 1;
+    }
+    info_out() << "\n🔎 " << tr("将监控的接口: ", "Monitored interfaces: ") << monitored << "/" << interfaces.size() << "\n";
+    for (const auto& iface : interfaces) {
+        info_out() << "   " << (iface.filtered ? "  " : "• ") << std::left << std::setw(16) << iface.name
+                   << std::setw(11) << iface.state;
+        if (iface.filtered) {
+            info_out() << tr(" (被--filter-interface排除)", " (excluded by --filter-interface)");
+        }
+        if (!iface.link.empty()) {
+            info_out() << tr(" 链路=", " link=") << iface.link;
+        }
+        if (!iface.threshold.empty()) {
+            info_out() << tr(" 阈值=", " threshold=") << iface.threshold;
+        }
+        info_out() << "\n";
+    }
+
+    if (!passed) {
+        std::cerr << "❌ " << tr("预检未通过，请按上面的提示修正后再开始实验",
+                                "Pre-flight checks failed, fix the issues above before starting") << "\n";
+        return EXIT_PREFLIGHT;
+    }
+    info_out() << "\n✅ " << tr("预检通过", "Pre-flight checks passed") << "\n";
+    return 0;
 }
 
 int main(int argc, char* argv[]) {
+    // 离线子命令
+    if (argc > 1) {
+        std::string command = argv[1];
+        if (command == "report") {
+            return report_main(argc - 1, argv + 1);
+        }
+        if (command == "compare") {
+            return compare_main(argc - 1, argv + 1);
+        }
+        if (command == "query") {
+            return query_main(argc - 1, argv + 1);
+        }
+        if (command == "merge") {
+            return merge_main(argc - 1, argv + 1);
+        }
+        if (command == "analyze") {
+            return analyze_main(argc - 1, argv + 1);
+        }
+        if (command == "schema") {
+            return schema_main(argc - 1, argv + 1);
+        }
+        if (command == "inject") {
+            return inject_main(argc - 1, argv + 1);
+        }
+        if (command == "campaign") {
+            return campaign_main(argc - 1, argv + 1);
+        }
+        if (command == "clab") {
+            return clab_main(argc - 1, argv + 1);
+        }
+    }
+
     // 默认参数
-    int64_t threshold = 3000;
-    std::string router_name;
-    std::string log_path;
+    MonitorConfig config;
+    int64_t& threshold = config.convergence_threshold_ms;
+    std::string& router_name = config.router_name;
+    std::string& log_path = config.log_path;
+    int64_t duration_ms = 0;
+    int64_t max_sessions = 0;
+    std::string junit_path;
+    std::string baseline_path;
+    double baseline_tolerance = 10.0;
+    double baseline_alpha = 0.05;
+    std::string topology_path;
+    std::string clab_node;
+    std::string netns;
+    bool multi_netns = false;
+    std::string netns_prefix;
+    std::string log_dir = ".";
+    bool discover_containers_mode = false;
+    std::string container_prefix;
+    std::string router_name_prefix;
+    std::string container_runtime = "docker";
+    bool daemon_mode = false;
+    bool quiet = false;
+    bool dry_run = false;
+    std::string console_format = "text";
+    std::string pidfile_path;
 
     // 解析命令行参数
     static struct option long_options[] = {
         {"threshold", required_argument, 0, 't'},
+        {"threshold-override", required_argument, 0, OPT_THRESHOLD_OVERRIDE},
         {"router-name", required_argument, 0, 'r'},
         {"log-path", required_argument, 0, 'l'},
+        {"alert-webhook", required_argument, 0, OPT_ALERT_WEBHOOK},
+        {"alert-threshold", required_argument, 0, OPT_ALERT_THRESHOLD},
+        {"on-session-complete", required_argument, 0, OPT_ON_SESSION_COMPLETE},
+        {"hook-timeout", required_argument, 0, OPT_HOOK_TIMEOUT},
+        {"sla-ms", required_argument, 0, OPT_SLA_MS},
+        {"baseline", required_argument, 0, OPT_BASELINE},
+        {"baseline-tolerance", required_argument, 0, OPT_BASELINE_TOLERANCE},
+        {"baseline-alpha", required_argument, 0, OPT_BASELINE_ALPHA},
+        {"duration", required_argument, 0, OPT_DURATION},
+        {"junit", required_argument, 0, OPT_JUNIT},
+        {"tag", required_argument, 0, OPT_TAG},
+        {"label", required_argument, 0, OPT_LABEL},
+        {"router-name-prefix", required_argument, 0, OPT_ROUTER_NAME_PREFIX},
+        {"topology", required_argument, 0, OPT_TOPOLOGY},
+        {"clab-node", required_argument, 0, OPT_CLAB_NODE},
+        {"netns", required_argument, 0, OPT_NETNS},
+        {"netns-all", no_argument, 0, OPT_NETNS_ALL},
+        {"netns-prefix", required_argument, 0, OPT_NETNS_PREFIX},
+        {"log-dir", required_argument, 0, OPT_LOG_DIR},
+        {"container-prefix", required_argument, 0, OPT_CONTAINER_PREFIX},
+        {"container-runtime", required_argument, 0, OPT_CONTAINER_RUNTIME},
+        {"frr-state", no_argument, 0, OPT_FRR_STATE},
+        {"vtysh", required_argument, 0, OPT_VTYSH},
+        {"frr-log", required_argument, 0, OPT_FRR_LOG},
+        {"bmp-listen", required_argument, 0, OPT_BMP_LISTEN},
+        {"igp-adjacency", no_argument, 0, OPT_IGP_ADJACENCY},
+        {"igp-poll-ms", required_argument, 0, OPT_IGP_POLL_MS},
+        {"gnmi", required_argument, 0, OPT_GNMI},
+        {"gnmi-path", required_argument, 0, OPT_GNMI_PATH},
+        {"gnmi-username", required_argument, 0, OPT_GNMI_USERNAME},
+        {"gnmi-password", required_argument, 0, OPT_GNMI_PASSWORD},
+        {"gnmi-insecure", no_argument, 0, OPT_GNMI_INSECURE},
+        {"gnmi-encoding", required_argument, 0, OPT_GNMI_ENCODING},
+        {"gnmic", required_argument, 0, OPT_GNMIC},
+        {"snmp-trap-listen", required_argument, 0, OPT_SNMP_TRAP_LISTEN},
+        {"snmp-community", required_argument, 0, OPT_SNMP_COMMUNITY},
+        {"clock-reference", required_argument, 0, OPT_CLOCK_REFERENCE},
+        {"clock-interval", required_argument, 0, OPT_CLOCK_INTERVAL},
+        {"clock-listen", required_argument, 0, OPT_CLOCK_LISTEN},
+        {"source", required_argument, 0, OPT_SOURCE},
+        {"output", required_argument, 0, OPT_OUTPUT},
+        {"output-key", required_argument, 0, OPT_OUTPUT_KEY},
+        {"store", required_argument, 0, OPT_STORE},
+        {"tui", no_argument, 0, OPT_TUI},
+        {"lang", required_argument, 0, OPT_LANG},
+        {"timestamp-format", required_argument, 0, OPT_TIMESTAMP_FORMAT},
+        {"timezone", required_argument, 0, OPT_TIMEZONE},
+        {"log-level", required_argument, 0, OPT_LOG_LEVEL},
+        {"debug-log", required_argument, 0, OPT_DEBUG_LOG},
+        {"control-socket", required_argument, 0, OPT_CONTROL_SOCKET},
+        {"filter-interface", required_argument, 0, OPT_FILTER_INTERFACE},
+        {"filter-prefix", required_argument, 0, OPT_FILTER_PREFIX},
+        {"tables", required_argument, 0, OPT_TABLES},
+        {"trigger-rule", required_argument, 0, OPT_TRIGGER_RULE},
+        {"ignore-rule", required_argument, 0, OPT_IGNORE_RULE},
+        {"config", required_argument, 0, OPT_CONFIG},
+        {"netlink-buffer", required_argument, 0, OPT_NETLINK_BUFFER},
+        {"netlink-workers", required_argument, 0, OPT_NETLINK_WORKERS},
+        {"max-events-in-memory", required_argument, 0, OPT_MAX_EVENTS_IN_MEMORY},
+        {"coalesce-ms", required_argument, 0, OPT_COALESCE_MS},
+        {"event-detail", required_argument, 0, OPT_EVENT_DETAIL},
+        {"console-detail", required_argument, 0, OPT_CONSOLE_DETAIL},
+        {"quiet", no_argument, 0, 'q'},
+        {"console-format", required_argument, 0, OPT_CONSOLE_FORMAT},
+        {"churn-rate", no_argument, 0, OPT_CHURN_RATE},
+        {"route-table-sample", required_argument, 0, OPT_ROUTE_TABLE_SAMPLE},
+        {"daemon", no_argument, 0, OPT_DAEMON},
+        {"max-sessions", required_argument, 0, OPT_MAX_SESSIONS},
+        {"max-session-duration", required_argument, 0, OPT_MAX_SESSION_DURATION},
+        {"warmup", required_argument, 0, OPT_WARMUP},
+        {"resume", no_argument, 0, OPT_RESUME},
+        {"dry-run", no_argument, 0, OPT_DRY_RUN},
+        {"route-only", no_argument, 0, OPT_ROUTE_ONLY},
+        {"trigger-debounce-ms", required_argument, 0, OPT_TRIGGER_DEBOUNCE_MS},
+        {"qdisc-cache-size", required_argument, 0, OPT_QDISC_CACHE_SIZE},
+        {"qdisc-cache-ttl", required_argument, 0, OPT_QDISC_CACHE_TTL},
+        {"mroute", no_argument, 0, OPT_MROUTE},
+        {"fdb", no_argument, 0, OPT_FDB},
+        {"tunnels", no_argument, 0, OPT_TUNNELS},
+        {"wireguard-poll-ms", required_argument, 0, OPT_WIREGUARD_POLL_MS},
+        {"bonding", no_argument, 0, OPT_BONDING},
+        {"link-events", no_argument, 0, OPT_LINK_EVENTS},
+        {"watch-default", no_argument, 0, OPT_WATCH_DEFAULT},
+        {"nexthop-cache", required_argument, 0, OPT_NEXTHOP_CACHE},
+        {"frr-window-ms", required_argument, 0, OPT_FRR_WINDOW_MS},
+        {"converged-when-prefix", required_argument, 0, OPT_CONVERGED_WHEN_PREFIX},
+        {"via", required_argument, 0, OPT_VIA},
+        {"loop-probe", required_argument, 0, OPT_LOOP_PROBE},
+        {"loop-probe-ms", required_argument, 0, OPT_LOOP_PROBE_MS},
+        {"loop-probe-max-ttl", required_argument, 0, OPT_LOOP_PROBE_MAX_TTL},
+        {"pcap-dir", required_argument, 0, OPT_PCAP_DIR},
+        {"pcap-interface", required_argument, 0, OPT_PCAP_INTERFACE},
+        {"pcap-filter", required_argument, 0, OPT_PCAP_FILTER},
+        {"pcap-max-packets", required_argument, 0, OPT_PCAP_MAX_PACKETS},
+        {"tcpdump", required_argument, 0, OPT_TCPDUMP},
+        {"interface-counters", no_argument, 0, OPT_INTERFACE_COUNTERS},
+        {"qdisc-stats-ms", required_argument, 0, OPT_QDISC_STATS_MS},
+        {"fib-trace", no_argument, 0, OPT_FIB_TRACE},
+        {"dataplane-probe", required_argument, 0, OPT_DATAPLANE_PROBE},
+        {"dataplane-interface", required_argument, 0, OPT_DATAPLANE_INTERFACE},
+        {"anomaly-sigma", required_argument, 0, OPT_ANOMALY_SIGMA},
+        {"anomaly-window", required_argument, 0, OPT_ANOMALY_WINDOW},
+        {"pidfile", required_argument, 0, OPT_PIDFILE},
+        {"health-listen", required_argument, 0, OPT_HEALTH_LISTEN},
+        {"health-max-idle", required_argument, 0, OPT_HEALTH_MAX_IDLE},
         {"help", no_argument, 0, 'h'},
         {0, 0, 0, 0}
     };
 
+    // CONVERGE_*环境变量转换成的参数放在命令行参数之前；转发给子进程时仍用原始argv，环境变量由子进程继承
+    std::vector<std::string> env_args;
+    std::vector<std::string> env_variables;
+    std::string env_error;
+    if (!options_from_env(long_options,
+                          {"tag", "label", "gnmi-path", "output", "filter-interface", "filter-prefix",
+                           "tables", "threshold-override", "source", "pcap-interface", "dataplane-interface"},
+                          env_args, env_variables, env_error)) {
+        std::cerr << "❌ 错误: " << env_error << "\n";
+        return 1;
+    }
+    std::vector<char*> parse_argv = {argv[0]};
+    for (auto& arg : env_args) {
+        parse_argv.push_back(arg.data());
+    }
+    for (int i = 1; i < argc; ++i) {
+        parse_argv.push_back(argv[i]);
+    }
+    int parse_argc = static_cast<int>(parse_argv.size());
+    parse_argv.push_back(nullptr);
+
+    config.env_variables = env_variables;
+    for (int i = 0; i < argc; ++i) {
+        config.command_line += (i ? " " : "") + std::string(argv[i]);
+    }
+
     int option_index = 0;
     int c;
-    while ((c = getopt_long(argc, argv, "t:r:l:h", long_options, &option_index)) != -1) {
+    while ((c = getopt_long(parse_argc, parse_argv.data(), "t:r:l:hq", long_options, &option_index)) != -1) {
+        // 来自CONVERGE_*环境变量的选项(位于命令行参数之前)出错时，错误信息指明变量名
+        std::string env_hint;
+        for (const struct option* opt = long_options; opt->name != nullptr; ++opt) {
+            if (opt->val == c) {
+                config.run_options.emplace_back(opt->name, optarg ? optarg : "");
+                if (optind - 1 <= static_cast<int>(env_args.size())) {
+                    env_hint = env_variable_name(opt->name) + ": ";
+                }
+                break;
+            }
+        }
         switch (c) {
             case 't':
-                threshold = std::stoll(optarg);
+                if (!parse_int64(optarg, threshold) || threshold <= 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的收敛阈值 " << optarg << " (需为正整数毫秒)\n";
+                    return 1;
+                }
+                break;
+            case OPT_THRESHOLD_OVERRIDE: {
+                std::string error;
+                if (!config.threshold_overrides.parse(optarg, error)) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的阈值覆盖 " << optarg << " (" << error << ")\n";
+                    return 1;
+                }
                 break;
+            }
             case 'r':
                 router_name = optarg;
                 break;
+            case OPT_ROUTER_NAME_PREFIX:
+                router_name_prefix = optarg;
+                break;
             case 'l':
                 log_path = optarg;
                 break;
+            case OPT_ALERT_WEBHOOK:
+                config.alert_webhook_url = optarg;
+                break;
+            case OPT_ALERT_THRESHOLD:
+                if (!parse_int64(optarg, config.alert_threshold_ms) || config.alert_threshold_ms <= 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的告警阈值 " << optarg << " (需为正整数毫秒)\n";
+                    return 1;
+                }
+                break;
+            case OPT_ON_SESSION_COMPLETE:
+                config.on_session_complete = optarg;
+                break;
+            case OPT_HOOK_TIMEOUT:
+                config.hook_timeout_ms = parse_duration_ms(optarg);
+                if (config.hook_timeout_ms <= 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的钩子超时 " << optarg << "\n";
+                    return 1;
+                }
+                break;
+            case OPT_SLA_MS:
+                if (!parse_int64(optarg, config.sla_ms) || config.sla_ms <= 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的SLA阈值 " << optarg << " (需为正整数毫秒)\n";
+                    return 1;
+                }
+                break;
+            case OPT_BASELINE:
+                baseline_path = optarg;
+                break;
+            case OPT_BASELINE_TOLERANCE:
+                if (!parse_double(optarg, baseline_tolerance) || baseline_tolerance < 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的基线容差 " << optarg << " (需为非负百分比，如 10)\n";
+                    return 1;
+                }
+                break;
+            case OPT_BASELINE_ALPHA:
+                if (!parse_double(optarg, baseline_alpha) || baseline_alpha <= 0 || baseline_alpha >= 1) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的显著性水平 " << optarg << " (需在0与1之间，如 0.05)\n";
+                    return 1;
+                }
+                break;
+            case OPT_DURATION:
+                duration_ms = parse_duration_ms(optarg);
+                if (duration_ms <= 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的监听时长 " << optarg << "\n";
+                    return 1;
+                }
+                break;
+            case OPT_JUNIT:
+                junit_path = optarg;
+                break;
+            case OPT_TAG: {
+                std::string tag = optarg;
+                size_t eq = tag.find('=');
+                if (eq == std::string::npos || eq == 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的标签 " << tag << " (应为KEY=VALUE)\n";
+                    return 1;
+                }
+                config.session_tags[tag.substr(0, eq)] = tag.substr(eq + 1);
+                break;
+            }
+            case OPT_LABEL: {
+                std::string label = optarg;
+                size_t eq = label.find('=');
+                if (eq == std::string::npos || eq == 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的标签 " << label << " (应为KEY=VALUE)\n";
+                    return 1;
+                }
+                config.labels[label.substr(0, eq)] = label.substr(eq + 1);
+                break;
+            }
+            case OPT_TOPOLOGY:
+                topology_path = optarg;
+                break;
+            case OPT_CLAB_NODE:
+                clab_node = optarg;
+                break;
+            case OPT_NETNS:
+                netns = optarg;
+                break;
+            case OPT_NETNS_ALL:
+                multi_netns = true;
+                break;
+            case OPT_NETNS_PREFIX:
+                multi_netns = true;
+                netns_prefix = optarg;
+                break;
+            case OPT_LOG_DIR:
+                log_dir = optarg;
+                break;
+            case OPT_CONTAINER_PREFIX:
+                discover_containers_mode = true;
+                container_prefix = optarg;
+                break;
+            case OPT_CONTAINER_RUNTIME:
+                container_runtime = optarg;
+                break;
+            case OPT_FRR_STATE:
+                config.frr_state = true;
+                break;
+            case OPT_VTYSH:
+                config.frr_state = true;
+                config.vtysh_path = optarg;
+                break;
+            case OPT_FRR_LOG:
+                config.frr_log_path = optarg;
+                break;
+            case OPT_BMP_LISTEN:
+                config.bmp_listen = optarg;
+                break;
+            case OPT_IGP_ADJACENCY:
+                config.igp_adjacency = true;
+                break;
+            case OPT_IGP_POLL_MS:
+                config.igp_adjacency = true;
+                if (!parse_int64(optarg, config.igp_poll_ms) || config.igp_poll_ms <= 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的邻接轮询间隔 " << optarg << "\n";
+                    return 1;
+                }
+                break;
+            case OPT_GNMI:
+                config.gnmi.target = optarg;
+                break;
+            case OPT_GNMI_PATH:
+                config.gnmi.paths.push_back(optarg);
+                break;
+            case OPT_GNMI_USERNAME:
+                config.gnmi.username = optarg;
+                break;
+            case OPT_GNMI_PASSWORD:
+                config.gnmi.password = optarg;
+                break;
+            case OPT_GNMI_INSECURE:
+                config.gnmi.insecure = true;
+                break;
+            case OPT_GNMI_ENCODING:
+                config.gnmi.encoding = optarg;
+                break;
+            case OPT_GNMIC:
+                config.gnmi.gnmic_path = optarg;
+                break;
+            case OPT_SNMP_TRAP_LISTEN:
+                config.snmp_trap_listen = optarg;
+                break;
+            case OPT_SNMP_COMMUNITY:
+                config.snmp_community = optarg;
+                break;
+            case OPT_CLOCK_REFERENCE:
+                config.clock_reference = optarg;
+                break;
+            case OPT_CLOCK_INTERVAL:
+                config.clock_interval_ms = parse_duration_ms(optarg);
+                if (config.clock_interval_ms < 1000) {
+                    std::cerr << "❌ 错误: " << env_hint << "时钟偏差测量间隔至少为1s: " << optarg << "\n";
+                    return 1;
+                }
+                break;
+            case OPT_CLOCK_LISTEN:
+                config.clock_listen = optarg;
+                break;
+            case OPT_SOURCE:
+                if (!EventSourceRegistry::is_supported(optarg)) {
+                    std::cerr << "❌ 错误: " << env_hint << "未注册的事件源 " << optarg << "\n";
+                    return 1;
+                }
+                config.sources.push_back(optarg);
+                break;
+            case OPT_OUTPUT:
+                if (!RecordSink::is_supported(optarg)) {
+                    std::cerr << "❌ 错误: " << env_hint << "不支持的输出 " << optarg
+                              << " (支持 stdout、file:///PATH、http://HOST:PORT/PATH、kafka://BROKER/TOPIC、"
+                              << "influx+http://HOST:PORT/PATH、influx+file:///PATH、otlp://HOST[:PORT]、"
+                              << "parquet:///DIR、prometheus://[ADDR:]PORT、grafana://[ADDR:]PORT、syslog://HOST[:PORT]、"
+                              << "grpc://[ADDR:]PORT)\n";
+                    return 1;
+                }
+                config.outputs.push_back(optarg);
+                break;
+            case OPT_OUTPUT_KEY:
+                config.output_key_field = std::string(optarg) == "none" ? "" : optarg;
+                break;
+            case OPT_STORE: {
+                std::string db_path;
+                if (!SqliteStore::parse_spec(optarg, db_path)) {
+                    std::cerr << "❌ 错误: " << env_hint << "不支持的存储 " << optarg << " (支持 sqlite:PATH)\n";
+                    return 1;
+                }
+                config.store = optarg;
+                break;
+            }
+            case OPT_TUI:
+                if (!isatty(STDOUT_FILENO)) {
+                    std::cerr << "❌ 错误: " << env_hint << "--tui 需要在终端中运行\n";
+                    return 1;
+                }
+                config.tui = true;
+                break;
+            case OPT_LANG: {
+                Language language;
+                if (!parse_language(optarg, language)) {
+                    std::cerr << "❌ 错误: " << env_hint << "不支持的语言 " << optarg << " (支持 zh、en、auto)\n";
+                    return 1;
+                }
+                set_language(language);
+                break;
+            }
+            case OPT_TIMESTAMP_FORMAT: {
+                TimestampFormat format;
+                if (!parse_timestamp_format(optarg, format)) {
+                    std::cerr << "❌ 错误: " << env_hint << "不支持的时间戳格式 " << optarg
+                              << " (支持 rfc3339、rfc3339nano、epoch-ms、epoch-ns)\n";
+                    return 1;
+                }
+                set_timestamp_format(format);
+                break;
+            }
+            case OPT_TIMEZONE: {
+                std::string error;
+                if (!set_timestamp_timezone(optarg, error)) {
+                    std::cerr << "❌ 错误: " << env_hint << "--timezone " << error << "\n";
+                    return 1;
+                }
+                break;
+            }
+            case OPT_LOG_LEVEL: {
+                LogLevel level;
+                if (!parse_log_level(optarg, level)) {
+                    std::cerr << "❌ 错误: " << env_hint << "不支持的日志级别 " << optarg << " (支持 debug、info、warn)\n";
+                    return 1;
+                }
+                set_log_level(level);
+                break;
+            }
+            case OPT_DEBUG_LOG:
+                config.debug_log_path = optarg;
+                break;
+            case OPT_CONTROL_SOCKET:
+                config.control_socket = optarg;
+                break;
+            case OPT_HEALTH_LISTEN:
+                config.health_listen = optarg;
+                break;
+            case OPT_HEALTH_MAX_IDLE:
+                config.health_max_idle_ms = parse_duration_ms(optarg);
+                if (config.health_max_idle_ms <= 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的健康检查空闲时长 " << optarg << "\n";
+                    return 1;
+                }
+                break;
+            case OPT_FILTER_INTERFACE:
+                config.filter.interfaces.push_back(optarg);
+                break;
+            case OPT_FILTER_PREFIX: {
+                EventFilter::Prefix prefix;
+                if (!EventFilter::parse_prefix(optarg, prefix)) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的前缀 " << optarg << "\n";
+                    return 1;
+                }
+                config.filter.prefixes.push_back(prefix);
+                break;
+            }
+            case OPT_TABLES: {
+                EventFilter parsed;
+                std::string error;
+                if (!parsed.set_tables(optarg, error)) {
+                    std::cerr << "❌ 错误: " << env_hint << error << "\n";
+                    return 1;
+                }
+                config.filter.tables.insert(config.filter.tables.end(), parsed.tables.begin(), parsed.tables.end());
+                break;
+            }
+            case OPT_TRIGGER_RULE:
+            case OPT_IGNORE_RULE: {
+                std::string error;
+                bool ok = c == OPT_TRIGGER_RULE ? config.trigger_rules.add_trigger(optarg, error)
+                                                : config.trigger_rules.add_ignore(optarg, error);
+                if (!ok) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的规则表达式 " << optarg << " (" << error << ")\n";
+                    return 1;
+                }
+                break;
+            }
+            case OPT_CONFIG:
+                config.config_path = optarg;
+                break;
+            case OPT_NETLINK_BUFFER: {
+                int64_t size = 0;
+                if (!parse_int64(optarg, size) || size <= 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的netlink队列容量 " << optarg << "\n";
+                    return 1;
+                }
+                config.netlink_queue_size = static_cast<size_t>(size);
+                break;
+            }
+            case OPT_NETLINK_WORKERS: {
+                int64_t workers = 0;
+                if (!parse_int64(optarg, workers) || workers <= 0 || workers > 64) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的netlink工作线程数 " << optarg << " (1-64)\n";
+                    return 1;
+                }
+                config.netlink_workers = static_cast<size_t>(workers);
+                break;
+            }
+            case OPT_COALESCE_MS:
+                if (!parse_int64(optarg, config.coalesce_ms) || config.coalesce_ms < 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的合并窗口 " << optarg << "\n";
+                    return 1;
+                }
+                break;
+            case OPT_EVENT_DETAIL:
+                if (std::string(optarg) == "summary") {
+                    config.event_detail_summary = true;
+                } else if (std::string(optarg) == "full") {
+                    config.event_detail_summary = false;
+                } else {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的事件明细模式 " << optarg << " (可选: full、summary)\n";
+                    return 1;
+                }
+                break;
+            case OPT_CONSOLE_DETAIL:
+                if (!parse_console_detail(optarg, config.console_detail)) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的控制台明细模式 " << optarg << " (可选: off、summary、full)\n";
+                    return 1;
+                }
+                break;
+            case 'q':
+                quiet = true;
+                break;
+            case OPT_CONSOLE_FORMAT:
+                console_format = optarg;
+                if (console_format != "text" && console_format != "json") {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的控制台格式 " << optarg << " (可选: text、json)\n";
+                    return 1;
+                }
+                break;
+            case OPT_CHURN_RATE:
+                config.churn_rate = true;
+                break;
+            case OPT_MAX_SESSIONS:
+                if (!parse_int64(optarg, max_sessions) || max_sessions <= 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的会话数 " << optarg << "\n";
+                    return 1;
+                }
+                break;
+            case OPT_TRIGGER_DEBOUNCE_MS:
+                if (!parse_int64(optarg, config.trigger_debounce_ms) || config.trigger_debounce_ms < 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的触发防抖窗口 " << optarg << "\n";
+                    return 1;
+                }
+                break;
+            case OPT_QDISC_CACHE_SIZE:
+                if (!parse_int64(optarg, config.qdisc_cache_size) || config.qdisc_cache_size <= 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的qdisc缓存条数 " << optarg << "\n";
+                    return 1;
+                }
+                break;
+            case OPT_QDISC_CACHE_TTL:
+                config.qdisc_cache_ttl_ms = parse_duration_ms(optarg);
+                if (config.qdisc_cache_ttl_ms <= 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的qdisc缓存存活时间 " << optarg << "\n";
+                    return 1;
+                }
+                break;
+            case OPT_MROUTE:
+                config.mroute = true;
+                break;
+            case OPT_FDB:
+                config.fdb = true;
+                break;
+            case OPT_TUNNELS:
+                config.tunnels = true;
+                break;
+            case OPT_WIREGUARD_POLL_MS:
+                config.tunnels = true;
+                if (!parse_int64(optarg, config.wireguard_poll_ms) || config.wireguard_poll_ms <= 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的WireGuard轮询间隔 " << optarg << "\n";
+                    return 1;
+                }
+                break;
+            case OPT_BONDING:
+                config.bonding = true;
+                break;
+            case OPT_LINK_EVENTS:
+                config.link_events = true;
+                break;
+            case OPT_WATCH_DEFAULT:
+                config.watch_default = true;
+                break;
+            case OPT_NEXTHOP_CACHE: {
+                int64_t size = 0;
+                if (!parse_int64(optarg, size) || size < 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的下一跳缓存容量 " << optarg << "\n";
+                    return 1;
+                }
+                config.nexthop_cache = static_cast<size_t>(size);
+                break;
+            }
+            case OPT_FRR_WINDOW_MS:
+                if (!parse_int64(optarg, config.frr_window_ms) || config.frr_window_ms < 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的快速重路由窗口 " << optarg << "\n";
+                    return 1;
+                }
+                break;
+            case OPT_CONVERGED_WHEN_PREFIX:
+                if (!EventFilter::parse_prefix(optarg, config.converged_prefix)) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的前缀 " << optarg << "\n";
+                    return 1;
+                }
+                break;
+            case OPT_VIA:
+                config.converged_via = optarg;
+                break;
+            case OPT_LOOP_PROBE:
+                if (!LoopProber::valid_target(optarg)) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的探测地址 " << optarg << "\n";
+                    return 1;
+                }
+                config.loop_probe_target = optarg;
+                break;
+            case OPT_LOOP_PROBE_MS:
+                if (!parse_int64(optarg, config.loop_probe_ms) || config.loop_probe_ms <= 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的微环路探测间隔 " << optarg << "\n";
+                    return 1;
+                }
+                break;
+            case OPT_LOOP_PROBE_MAX_TTL: {
+                int64_t ttl = 0;
+                if (!parse_int64(optarg, ttl) || ttl < 2 || ttl > 64) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的最大TTL " << optarg << " (2-64)\n";
+                    return 1;
+                }
+                config.loop_probe_max_ttl = static_cast<int>(ttl);
+                break;
+            }
+            case OPT_PCAP_DIR: {
+                struct stat st;
+                if (stat(optarg, &st) != 0 || !S_ISDIR(st.st_mode)) {
+                    std::cerr << "❌ 错误: " << env_hint << "抓包目录不存在 " << optarg << "\n";
+                    return 1;
+                }
+                config.pcap.directory = optarg;
+                break;
+            }
+            case OPT_PCAP_INTERFACE:
+                config.pcap.interfaces.push_back(optarg);
+                break;
+            case OPT_PCAP_FILTER:
+                config.pcap.filter = optarg;
+                break;
+            case OPT_PCAP_MAX_PACKETS:
+                if (!parse_int64(optarg, config.pcap.max_packets) || config.pcap.max_packets <= 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的抓包包数上限 " << optarg << "\n";
+                    return 1;
+                }
+                break;
+            case OPT_TCPDUMP:
+                config.pcap.tcpdump_path = optarg;
+                break;
+            case OPT_INTERFACE_COUNTERS:
+                config.interface_counters = true;
+                break;
+            case OPT_QDISC_STATS_MS:
+                if (!parse_int64(optarg, config.qdisc_stats_ms) || config.qdisc_stats_ms < 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的qdisc统计间隔 " << optarg << "\n";
+                    return 1;
+                }
+                break;
+            case OPT_FIB_TRACE:
+                config.fib_trace = true;
+                break;
+            case OPT_DATAPLANE_PROBE: {
+                std::string error;
+                if (!DataplaneProbe::parse_flow(optarg, config.dataplane_flow, error)) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的探测流 " << optarg << ": " << error << "\n";
+                    return 1;
+                }
+                break;
+            }
+            case OPT_DATAPLANE_INTERFACE:
+                config.dataplane_interfaces.push_back(optarg);
+                break;
+            case OPT_ANOMALY_SIGMA:
+                if (!parse_double(optarg, config.anomaly_sigma) || config.anomaly_sigma <= 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的异常阈值 " << optarg << " (需为正数，如 3)\n";
+                    return 1;
+                }
+                break;
+            case OPT_ANOMALY_WINDOW:
+                if (!parse_int64(optarg, config.anomaly_window) ||
+                    config.anomaly_window < static_cast<int64_t>(AnomalyDetector::MIN_BASELINE)) {
+                    std::cerr << "❌ 错误: " << env_hint << "--anomaly-window 至少为 " << AnomalyDetector::MIN_BASELINE << "\n";
+                    return 1;
+                }
+                break;
+            case OPT_MAX_SESSION_DURATION:
+                config.max_session_ms = parse_duration_ms(optarg);
+                if (config.max_session_ms <= 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的会话最长时长 " << optarg << "\n";
+                    return 1;
+                }
+                break;
+            case OPT_WARMUP:
+                config.warmup_ms = parse_duration_ms(optarg);
+                if (config.warmup_ms <= 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的预热时长 " << optarg << "\n";
+                    return 1;
+                }
+                break;
+            case OPT_RESUME:
+                config.resume = true;
+                break;
+            case OPT_DRY_RUN:
+                dry_run = true;
+                break;
+            case OPT_ROUTE_ONLY:
+                config.route_only = true;
+                break;
+            case OPT_DAEMON:
+                daemon_mode = true;
+                break;
+            case OPT_PIDFILE:
+                pidfile_path = optarg;
+                break;
+            case OPT_ROUTE_TABLE_SAMPLE:
+                config.route_table_sample_ms = parse_duration_ms(optarg);
+                if (config.route_table_sample_ms <= 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的路由表采样间隔 " << optarg << "\n";
+                    return 1;
+                }
+                break;
+            case OPT_MAX_EVENTS_IN_MEMORY:
+                if (!parse_int64(optarg, config.max_events_in_memory) || config.max_events_in_memory < 0) {
+                    std::cerr << "❌ 错误: " << env_hint << "无效的内存事件上限 " << optarg << "\n";
+                    return 1;
+                }
+                break;
             case 'h':
                 print_usage(argv[0]);
                 return 0;
@@ -108,55 +1240,543 @@ int main(int argc, char* argv[]) {
         }
     }
 
+    // 配置文件中的阈值与过滤条件覆盖命令行
+    if (!config.config_path.empty()) {
+        std::string error;
+        if (!ConvergenceMonitor::load_config_file(config.config_path, config, error)) {
+            std::cerr << "❌ 错误: " << error << "\n";
+            return 1;
+        }
+    }
+
     // 参数验证
     if (threshold <= 0) {
         std::cerr << "❌ 错误: 收敛阈值必须大于0\n";
         return 1;
     }
 
+    if (!config.alert_webhook_url.empty()) {
+        std::string host, port, path;
+        if (!HttpClient::parse_url(config.alert_webhook_url, host, port, path)) {
+            std::cerr << "❌ 错误: 无效的告警地址 " << config.alert_webhook_url << " (仅支持http://)\n";
+            return 1;
+        }
+    }
+
+    // 多命名空间模式：每个命名空间一个子进程监控器
+    if (multi_netns || discover_containers_mode) {
+        std::vector<NetnsTarget> targets;
+        if (discover_containers_mode) {
+            std::string error;
+            if (!discover_containers(container_runtime, container_prefix, targets, error)) {
+                std::cerr << "❌ 错误: 容器发现失败: " << error << "\n";
+                return 1;
+            }
+            std::cout << "🔍 发现 " << targets.size() << " 个前缀为 " << container_prefix << " 的容器\n";
+        } else {
+            targets = list_named_netns(netns_prefix);
+        }
+        auto forwarded = strip_options(argc, argv, {"--netns-all"},
+                                       {"--netns-prefix", "--log-dir", "--netns",
+                                        "--container-prefix", "--container-runtime"});
+        return run_per_netns_monitors(targets, forwarded, log_dir);
+    }
+
+    // 在创建任何netlink套接字和线程之前切换命名空间
+    if (!netns.empty()) {
+        std::string error;
+        if (!enter_netns(netns, error)) {
+            std::cerr << "❌ 错误: 无法进入网络命名空间: " << error << "\n";
+            return 1;
+        }
+        if (router_name.empty() && clab_node.empty() && netns.find('/') == std::string::npos) {
+            router_name = netns;
+        }
+    }
+
     // 生成默认路由器名称
     if (router_name.empty()) {
-        router_name = generate_router_name();
+        router_name = clab_node.empty() ? generate_router_name(router_name_prefix) : clab_node;
+    }
+
+    // 根据拓扑文件建立本地接口到逻辑链路的映射
+    if (!topology_path.empty()) {
+        ClabTopology topology;
+        std::string error;
+        if (!ClabTopology::load(topology_path, topology, error)) {
+            std::cerr << "❌ 错误: 加载拓扑失败: " << error << "\n";
+            return 1;
+        }
+        if (clab_node.empty()) {
+            clab_node = router_name;
+        }
+        if (!topology.has_node(clab_node)) {
+            std::cerr << "❌ 错误: 拓扑 " << topology.lab_name << " 中不存在节点 " << clab_node
+                      << " (使用 --clab-node 指定)\n";
+            return 1;
+        }
+        for (const ClabLink* link : topology.links_of(clab_node)) {
+            bool local_is_a = link->a.node == clab_node;
+            const ClabEndpoint& local = local_is_a ? link->a : link->b;
+            const ClabEndpoint& peer = local_is_a ? link->b : link->a;
+            config.interface_links[local.interface] = {link->name(), peer.to_string()};
+        }
     }
 
     // 设置信号处理
     signal(SIGINT, signal_handler);
     signal(SIGTERM, signal_handler);
+    signal(SIGUSR1, status_signal_handler);
+    signal(SIGHUP, reload_signal_handler);
+
+    // 安静模式与JSON控制台格式：stdout只保留机器可读的记录(或什么都不输出)
+    if (quiet || console_format == "json") {
+        if (config.tui) {
+            std::cerr << "❌ 错误: --quiet/--console-format json 不能与 --tui 同时使用\n";
+            return 1;
+        }
+        if (console_format == "json" &&
+            std::find(config.outputs.begin(), config.outputs.end(), "stdout") == config.outputs.end() &&
+            std::find(config.outputs.begin(), config.outputs.end(), "-") == config.outputs.end()) {
+            config.outputs.push_back("stdout");
+        }
+        silence_console();
+    }
+
+    // --output -：stdout只输出NDJSON记录，控制台提示改写到stderr，便于 | jq 或经ssh远程采集
+    if (std::find(config.outputs.begin(), config.outputs.end(), "-") != config.outputs.end()) {
+        if (config.tui) {
+            std::cerr << "❌ 错误: --output - 不能与 --tui 同时使用\n";
+            return 1;
+        }
+        redirect_console_to_stderr();
+        signal(SIGPIPE, pipe_signal_handler);
+    }
+
+    // 常驻运行时控制台输出交给journald等日志系统，不输出emoji
+    if (daemon_mode) {
+        if (config.tui) {
+            std::cerr << "❌ 错误: --daemon 不能与 --tui 同时使用\n";
+            return 1;
+        }
+        install_plain_console();
+    }
 
     // 打印启动信息
-    auto now = std::chrono::system_clock::now();
-    auto time_t = std::chrono::system_clock::to_time_t(now);
-    std::cout << "异步路由收敛监控工具启动 (C++多线程版) - " 
-              << std::put_time(std::localtime(&time_t), "%Y-%m-%d %H:%M:%S") << "\n";
-    std::cout << "参数: 收敛阈值=" << threshold << "ms\n";
-    std::cout << "路由器名称: " << router_name << "\n";
-    std::cout << "触发策略: 仅在IDLE状态时触发新会话，监控中作为路由事件\n";
-    std::cout << "性能优化: C++多线程 + 原子操作 + 无锁数据结构\n";
+    info_out() << tr("异步路由收敛监控工具启动 (C++多线程版) - ", "Async route convergence monitor (C++) started - ")
+               << console_timestamp(timestamp_now_ns()) << "\n";
+    info_out() << tr("参数: 收敛阈值=", "Parameters: convergence threshold=") << threshold << "ms\n";
+    info_out() << tr("路由器名称: ", "Router name: ") << router_name << "\n";
+    if (!env_variables.empty()) {
+        std::string variables;
+        for (const auto& variable : env_variables) {
+            variables += (variables.empty() ? "" : ", ") + variable;
+        }
+        info_out() << tr("环境变量配置: ", "Configured from environment: ") << variables << "\n";
+    }
+    info_out() << tr("触发策略: 仅在IDLE状态时触发新会话，监控中作为路由事件\n",
+                     "Trigger policy: new sessions start only when IDLE; events during a session count as route events\n");
+    info_out() << tr("性能优化: C++多线程 + 原子操作 + 无锁数据结构\n",
+                     "Performance: C++ threads + atomics + lock-free structures\n");
     
-    std::string actual_log_path = log_path.empty() ? "默认路径" : log_path;
-    std::cout << "日志路径: " << actual_log_path << "\n";
-    std::cout << "使用 Ctrl+C 停止监听\n\n";
+    std::string actual_log_path = log_path.empty() ? tr("默认路径", "default path") : log_path;
+    info_out() << tr("日志路径: ", "Log path: ") << actual_log_path << "\n";
+    if (duration_ms > 0) {
+        info_out() << tr("监听时长: ", "Duration: ") << (duration_ms / 1000.0) << tr("秒", "s") << "\n";
+    }
+    if (max_sessions > 0) {
+        info_out() << tr("会话数上限: ", "Session limit: ") << max_sessions << "\n";
+    }
+    if (config.max_session_ms > 0) {
+        info_out() << tr("会话最长时长: ", "Max session duration: ") << (config.max_session_ms / 1000.0)
+                   << tr("秒", "s") << "\n";
+    }
+    if (config.warmup_ms > 0) {
+        info_out() << tr("预热时长: ", "Warm-up: ") << (config.warmup_ms / 1000.0) << tr("秒", "s") << "\n";
+    }
+    if (config.sla_ms > 0) {
+        info_out() << tr("SLA阈值: ", "SLA threshold: ") << config.sla_ms << "ms\n";
+    }
+    // 启动前加载基线，文件有误时不必等到运行结束才发现
+    ReportData baseline_data;
+    if (!baseline_path.empty()) {
+        std::string error;
+        if (!ConvergenceReport::load(baseline_path, baseline_data, error)) {
+            std::cerr << "❌ 错误: 无法加载基线 " << error << "\n";
+            return 1;
+        }
+        if (ConvergenceReport::convergence_times(baseline_data.sessions).empty()) {
+            std::cerr << "❌ 错误: 基线 " << baseline_path << " 中没有已收敛的会话\n";
+            return 1;
+        }
+        info_out() << tr("回归基线: ", "Regression baseline: ") << baseline_path << " ("
+                   << baseline_data.sessions.size() << tr(" 个会话, 容差 ", " sessions, tolerance ")
+                   << baseline_tolerance << "%)\n";
+    }
+    if (!config.config_path.empty()) {
+        info_out() << tr("配置文件: ", "Config file: ") << config.config_path << tr(" (SIGHUP重新加载)", " (reload with SIGHUP)") << "\n";
+    }
+    if (!config.filter.empty()) {
+        info_out() << tr("事件过滤: 接口=", "Event filter: interfaces=")
+                   << (config.filter.interfaces.empty() ? tr("全部", "all") : config.filter.interfaces_text())
+                   << tr(", 前缀=", ", prefixes=")
+                   << (config.filter.prefixes.empty() ? tr("全部", "all") : config.filter.prefixes_text())
+                   << tr(", 路由表=", ", tables=")
+                   << (config.filter.tables.empty() ? tr("全部", "all") : config.filter.tables_text()) << "\n";
+    }
+    if (config.event_detail_summary) {
+        if (config.coalesce_ms > 0) {
+            std::cerr << "❌ 错误: --coalesce-ms 不能与 --event-detail summary 同时使用\n";
+            return 1;
+        }
+        info_out() << tr("事件明细: 每秒汇总", "Event detail: per-second summary") << "\n";
+    }
+    if (config.coalesce_ms > 0) {
+        info_out() << tr("路由事件合并窗口: ", "Route event coalescing window: ") << config.coalesce_ms << "ms\n";
+    }
+    if (config.max_events_in_memory > 0) {
+        info_out() << tr("内存事件上限: ", "In-memory event limit: ") << config.max_events_in_memory
+                   << tr(" 条路由事件", " route events") << "\n";
+    }
+    if (config.route_table_sample_ms > 0) {
+        info_out() << tr("路由表采样间隔: ", "Route table sample interval: ")
+                   << (config.route_table_sample_ms / 1000.0) << tr("秒", "s") << "\n";
+    }
+    if (config.churn_rate) {
+        info_out() << tr("持续记录每秒路由变化数", "Logging per-second route churn rate continuously") << "\n";
+    }
+    if (config.mroute) {
+        info_out() << tr("组播路由: 监听IPv4/IPv6组播转发缓存变化", "Multicast routes: watching IPv4/IPv6 MFC changes") << "\n";
+    }
+    if (config.fdb) {
+        info_out() << tr("二层转发表: 监听网桥/VXLAN的MAC表项变化", "Bridge FDB: watching bridge/VXLAN MAC entries") << "\n";
+    }
+    if (config.tunnels) {
+        info_out() << tr("隧道: 跟踪隧道接口的创建删除、端点与链路状态", "Tunnels: tracking tunnel creation, endpoints and link state") << "\n";
+    }
+    if (config.bonding) {
+        info_out() << tr("链路聚合: 跟踪bond/team活动成员、成员状态与LACP状态", "Link aggregation: tracking bond/team active members, member state and LACP") << "\n";
+    }
+    if (config.link_events) {
+        info_out() << tr("接口属性: 记录MTU、混杂模式与主从关系变化", "Link attributes: logging MTU, promisc and master changes") << "\n";
+    }
+    if (config.watch_default) {
+        info_out() << tr("默认路由: 跟踪main表默认路由的丢失与恢复", "Default route: tracking loss and restoration in the main table") << "\n";
+    }
+    if (!config.converged_prefix.text.empty()) {
+        info_out() << tr("收敛判定: 目标前缀 ", "Convergence: target prefix ") << config.converged_prefix.text
+                   << (config.converged_via.empty() ? "" : " via " + config.converged_via)
+                   << tr(" 安装即收敛", " installed") << "\n";
+    } else if (!config.converged_via.empty()) {
+        std::cerr << "❌ 错误: --via 需要同时指定 --converged-when-prefix\n";
+        return 1;
+    }
+    if (!config.loop_probe_target.empty()) {
+        info_out() << tr("微环路探测: 会话期间每 ", "Micro-loop probing: every ") << config.loop_probe_ms
+                   << tr("ms traceroute ", "ms traceroute to ") << config.loop_probe_target
+                   << " (max TTL " << config.loop_probe_max_ttl << ")\n";
+    }
+    if (config.interface_counters) {
+        info_out() << tr("接口计数: 记录每个会话触发到收敛之间的计数差值", "Interface counters: recording per-session deltas") << "\n";
+    }
+    if (config.qdisc_stats_ms > 0) {
+        info_out() << tr("qdisc统计: 会话期间每 ", "Qdisc stats: every ") << config.qdisc_stats_ms
+                   << tr("ms 读取触发接口的qdisc统计", "ms on the trigger interface during sessions") << "\n";
+    }
+    if (!config.dataplane_flow.dst.empty()) {
+        if (config.dataplane_interfaces.empty()) {
+            std::cerr << "❌ 错误: --dataplane-probe 需要同时指定 --dataplane-interface\n";
+            return 1;
+        }
+        std::string interfaces;
+        for (const auto& interface : config.dataplane_interfaces) {
+            interfaces += (interfaces.empty() ? "" : ",") + interface;
+        }
+        info_out() << tr("数据面探测: ", "Dataplane probe: ") << config.dataplane_flow.dst
+                   << (config.dataplane_flow.port ? ":" + std::to_string(config.dataplane_flow.port) : "")
+                   << tr(" 经 ", " via ") << interfaces << "\n";
+    } else if (!config.dataplane_interfaces.empty()) {
+        std::cerr << "❌ 错误: --dataplane-interface 需要同时指定 --dataplane-probe\n";
+        return 1;
+    }
+    if (config.anomaly_sigma > 0) {
+        info_out() << tr("异常检测: 偏离基线 ", "Anomaly detection: deviation beyond ") << config.anomaly_sigma
+                   << tr(" 倍标准差, 基线 ", " sigma, baseline of ") << config.anomaly_window
+                   << tr(" 个会话", " sessions") << "\n";
+    }
+    if (!config.pcap.directory.empty()) {
+        std::string interfaces;
+        for (const auto& interface : config.pcap.interfaces) {
+            interfaces += (interfaces.empty() ? "" : ",") + interface;
+        }
+        info_out() << tr("会话抓包: ", "Session capture: ") << config.pcap.directory << " ("
+                   << (interfaces.empty() ? "any" : interfaces) << ", "
+                   << (config.pcap.filter.empty() ? PcapOptions::DEFAULT_FILTER : config.pcap.filter) << ")\n";
+    } else if (!config.pcap.interfaces.empty() || !config.pcap.filter.empty()) {
+        std::cerr << "❌ 错误: --pcap-interface/--pcap-filter 需要同时指定 --pcap-dir\n";
+        return 1;
+    }
+    if (!config.interface_links.empty()) {
+        info_out() << tr("拓扑链路: ", "Topology links: ") << config.interface_links.size()
+                   << tr(" 条 (节点 ", " (node ") << clab_node << ")\n";
+    }
+    if (!config.bmp_listen.empty()) {
+        std::string address;
+        int port = 0;
+        if (!BmpCollector::parse_listen_spec(config.bmp_listen, address, port)) {
+            std::cerr << "❌ 错误: 无效的BMP监听地址 " << config.bmp_listen << "\n";
+            return 1;
+        }
+    }
+    if (!config.health_listen.empty()) {
+        std::string address;
+        int port = 0;
+        if (!BmpCollector::parse_listen_spec(config.health_listen, address, port)) {
+            std::cerr << "❌ 错误: 无效的健康检查监听地址 " << config.health_listen << "\n";
+            return 1;
+        }
+    } else if (config.health_max_idle_ms > 0) {
+        std::cerr << "❌ 错误: --health-max-idle 需要同时指定 --health-listen\n";
+        return 1;
+    }
+    if (!config.clock_reference.empty()) {
+        std::string host;
+        std::string port;
+        if (!ClockProbe::parse_reference(config.clock_reference, host, port)) {
+            std::cerr << "❌ 错误: 无效的时钟参考 " << config.clock_reference << "\n";
+            return 1;
+        }
+        info_out() << tr("时钟参考: ", "Clock reference: ") << config.clock_reference << "\n";
+    }
+    if (!config.clock_listen.empty()) {
+        std::string address;
+        int port = 0;
+        if (!BmpCollector::parse_listen_spec(config.clock_listen, address, port)) {
+            std::cerr << "❌ 错误: 无效的时钟参考监听地址 " << config.clock_listen << "\n";
+            return 1;
+        }
+    }
+    if (!config.snmp_trap_listen.empty()) {
+        std::string address;
+        int port = 0;
+        if (!BmpCollector::parse_listen_spec(config.snmp_trap_listen, address, port)) {
+            std::cerr << "❌ 错误: 无效的SNMP Trap监听地址 " << config.snmp_trap_listen << "\n";
+            return 1;
+        }
+    }
+    if (!config.frr_log_path.empty()) {
+        info_out() << tr("FRR日志: ", "FRR log: ") << config.frr_log_path << "\n";
+    }
+    if (config.frr_state) {
+        info_out() << tr("FRR状态采集: ", "FRR state collection: ") << config.vtysh_path << "\n";
+    }
+    if (config.igp_adjacency) {
+        info_out() << tr("IGP邻接跟踪: 每 ", "IGP adjacency tracking: every ") << config.igp_poll_ms << "ms\n";
+    }
+    if (!config.gnmi.target.empty()) {
+        info_out() << tr("gNMI目标: ", "gNMI target: ") << config.gnmi.target << " ("
+                   << (config.gnmi.paths.empty() ? GnmiSubscriber::DEFAULT_PATHS.size() : config.gnmi.paths.size())
+                   << tr(" 条订阅路径)", " subscription paths)") << "\n";
+    } else if (!config.gnmi.paths.empty()) {
+        std::cerr << "❌ 错误: --gnmi-path 需要同时指定 --gnmi\n";
+        return 1;
+    }
+    for (const auto& output : config.outputs) {
+        info_out() << tr("输出: ", "Output: ") << output << " (key="
+                   << (config.output_key_field.empty() ? "none" : config.output_key_field) << ")\n";
+    }
+    if (!config.store.empty()) {
+        info_out() << tr("存储: ", "Store: ") << config.store << "\n";
+    }
+    if (!config.alert_webhook_url.empty()) {
+        info_out() << tr("告警地址: ", "Alert webhook: ") << config.alert_webhook_url
+                   << tr(" (阈值=", " (threshold=") << config.alert_threshold_ms << "ms)\n";
+    }
+    if (config.route_only) {
+        info_out() << tr("监控范围: 只监控路由，不订阅qdisc变化", "Scope: routes only, qdisc changes not subscribed") << "\n";
+    }
+    if (!config.on_session_complete.empty()) {
+        info_out() << tr("会话完成钩子: ", "Session completion hook: ") << config.on_session_complete
+                   << tr(" (超时=", " (timeout=") << config.hook_timeout_ms << "ms)\n";
+    }
+    if (dry_run) {
+        return run_dry_run(config);
+    }
+    // 启动前检查：无法订阅路由或写日志时直接退出，不要等实验开始后才发现
+    if (!apply_preflight(config, console_format == "json")) {
+        return EXIT_PREFLIGHT;
+    }
+    if (!daemon_mode) {
+        info_out() << tr("使用 Ctrl+C 停止监听", "Press Ctrl+C to stop") << "\n\n";
+    }
+
+    // 在转入后台之前加锁，重复启动时错误仍能输出到终端
+    PidFile pidfile;
+    if (!pidfile_path.empty()) {
+        std::string error;
+        if (!pidfile.acquire(pidfile_path, error)) {
+            std::cerr << "❌ 错误: " << error << "\n";
+            return 1;
+        }
+    }
+
+    // systemd下保持前台运行(Type=notify)，否则在创建任何线程之前转入后台
+    if (daemon_mode && !under_systemd()) {
+        std::string error;
+        if (!daemonize(error)) {
+            std::cerr << "❌ 错误: 无法转入后台: " << error << "\n";
+            return 1;
+        }
+        if (!pidfile_path.empty() && !pidfile.write_pid(error)) {
+            daemon_failed();
+            return 1;
+        }
+    }
+
+    // 强制退出：主线程可能卡在stop_monitoring中join挂起的线程，由独立线程写出日志后_exit
+    std::atomic<bool> monitor_finished{false};
+    std::thread force_exit_thread;
+    auto finish_force_exit_thread = [&]() {
+        monitor_finished.store(true);
+        if (force_exit_thread.joinable()) {
+            force_exit_thread.join();
+        }
+    };
 
     try {
         // 创建监控器
-        global_monitor = std::make_unique<ConvergenceMonitor>(threshold, router_name, log_path);
+        global_monitor = std::make_unique<ConvergenceMonitor>(config);
 
         // 开始监控
         global_monitor->start_monitoring();
+        daemon_ready();
+
+        force_exit_thread = std::thread([&]() {
+            while (!force_exit_requested.load()) {
+                if (monitor_finished.load()) {
+                    return;
+                }
+                std::this_thread::sleep_for(std::chrono::milliseconds(50));
+            }
+            std::cerr << "\n🛑 " << tr("再次收到信号，不再等待各线程，立即写出日志并退出",
+                                      "Signal received again, writing the log and exiting immediately") << "\n";
+            global_monitor->emergency_stop();
+            pidfile.release();
+            std::cout.flush();
+            _exit(EXIT_FORCED);
+        });
 
-        // 等待关闭信号
+        // 等待关闭信号或监听时长到期
+        auto deadline = std::chrono::steady_clock::now() + std::chrono::milliseconds(duration_ms);
         while (!shutdown_requested.load()) {
+            if (duration_ms > 0 && std::chrono::steady_clock::now() >= deadline) {
+                info_out() << "\n⏱️  " << tr("监听时长已到，正在结束监控...", "Duration reached, stopping...") << "\n";
+                global_monitor->set_stop_reason("duration");
+                break;
+            }
+            if (max_sessions > 0 && global_monitor->finished_session_count() >= max_sessions) {
+                info_out() << "\n🏁 " << tr("已完成 ", "Completed ") << max_sessions
+                           << tr(" 个会话，正在结束监控...", " sessions, stopping...") << "\n";
+                global_monitor->set_stop_reason("max_sessions");
+                break;
+            }
+            if (status_requested.exchange(false)) {
+                global_monitor->dump_status();
+            }
+            if (reload_requested.exchange(false)) {
+                std::string error;
+                sd_notify_state("RELOADING=1");
+                bool reloaded = global_monitor->reload_config(error);
+                sd_notify_state("READY=1");
+                if (reloaded) {
+                    info_out() << "🔄 " << tr("已重新加载配置: ", "Reloaded config: ") << config.config_path << "\n";
+                } else {
+                    std::cerr << "⚠️  " << tr("重新加载配置失败，保持当前设置: ", "Config reload failed, keeping current settings: ")
+                              << error << "\n";
+                }
+            }
             std::this_thread::sleep_for(std::chrono::milliseconds(100));
         }
 
         // 停止监控
+        if (stdout_closed.load()) {
+            global_monitor->set_stop_reason("stdout_closed");
+        } else if (shutdown_requested.load()) {
+            global_monitor->set_stop_reason("signal");
+        }
+        sd_notify_state("STOPPING=1");
         global_monitor->stop_monitoring();
+        finish_force_exit_thread();
+        SlaSummary sla = global_monitor->evaluate_sla();
+
+        // 本次运行的会话直接取自内存，日志文件可能包含之前追加的运行
+        bool regression = false;
+        if (!baseline_path.empty()) {
+            ReportData current;
+            for (const auto& summary : global_monitor->get_completed_sessions()) {
+                if (summary.forced) {
+                    continue;
+                }
+                ReportSession session;
+                session.router_name = router_name;
+                session.session_id = summary.session_id;
+                session.convergence_time_ms = summary.convergence_time_ms;
+                session.route_events = summary.route_events;
+                session.duration_ms = summary.duration_ms;
+                session.timed_out = summary.timed_out;
+                session.completed = true;
+                current.sessions.push_back(session);
+            }
+            if (ConvergenceReport::convergence_times(current.sessions).empty()) {
+                std::cout << "\n⚠️  " << tr("本次运行没有已收敛的会话，未与基线对比",
+                                             "No converged sessions in this run, baseline comparison skipped") << "\n";
+            } else {
+                auto results = RunComparison::compare(baseline_data, current, baseline_tolerance, baseline_alpha);
+                for (const auto& result : results) {
+                    regression = regression || result.regression;
+                }
+                std::cout << "\n" << tr("与基线对比: ", "Compared with baseline: ") << baseline_path << "\n"
+                          << RunComparison::render_table(results)
+                          << (regression ? tr("❌ 检测到收敛回归", "❌ Convergence regression detected")
+                                         : tr("✅ 未检测到显著回归", "✅ No significant regression")) << "\n";
+            }
+        }
+
+        if (!junit_path.empty()) {
+            std::string error;
+            if (JUnitReport::write(junit_path, router_name, global_monitor->get_completed_sessions(),
+                                   config.sla_ms, error)) {
+                std::cout << tr("JUnit报告已保存到: ", "JUnit report saved to: ") << junit_path << "\n";
+            } else {
+                std::cerr << "❌ " << error << "\n";
+            }
+        }
+
         global_monitor.reset();
 
-        std::cout << "\n程序正常退出\n";
-        
+        info_out() << "\n" << tr("程序正常退出", "Exited normally") << "\n";
+
+        // SLA模式：最后一行输出紧凑的机器可读摘要，--quiet/--console-format json时同样写入stdout
+        // SLA未达标优先于基线回归：绝对阈值被突破时不再区分是否同时变慢
+        if (config.sla_ms > 0) {
+            machine_out() << "{\"sla_ms\":" << sla.sla_ms
+                          << ",\"sessions\":" << sla.sessions
+                          << ",\"violations\":" << sla.violations
+                          << ",\"max_convergence_ms\":" << sla.max_convergence_ms
+                          << ",\"result\":\"" << (sla.passed() ? "pass" : "fail") << "\"}\n";
+            machine_out().flush();
+            if (!sla.passed()) {
+                return EXIT_SLA_VIOLATED;
+            }
+        }
+        if (regression) {
+            return EXIT_REGRESSION;
+        }
+
     } catch (const std::exception& e) {
-        std::cerr << "❌ 程序运行出错: " << e.what() << "\n";
+        std::cerr << "❌ " << tr("程序运行出错: ", "Error: ") << e.what() << "\n";
+        finish_force_exit_thread();
+        daemon_failed();
         return 1;
     }
 
@@ -0,0 +1,418 @@
+#include "merge.h"
+#include <algorithm>
+#include <cmath>
+#include <getopt.h>
+#include <iomanip>
+#include <iostream>
+#include <map>
+
+int LogMerger::apply_clock_offsets(std::vector<ReportSession>& sessions,
+                                   const std::vector<ReportClockOffset>& offsets) {
+    int corrected = 0;
+    for (auto& session : sessions) {
+        if (session.start_time_ms < 0) {
+            continue;
+        }
+        const ReportClockOffset* nearest = nullptr;
+        for (const auto& offset : offsets) {
+            if (offset.router_name == session.router_name &&
+                (nearest == nullptr || std::llabs(offset.time_ms - session.start_time_ms) <
+                                           std::llabs(nearest->time_ms - session.start_time_ms))) {
+                nearest = &offset;
+            }
+        }
+        if (nearest == nullptr) {
+            continue;
+        }
+        int64_t correction = std::llround(nearest->offset_ms);
+        session.start_time_ms += correction;
+        session.clock_correction_ms = correction;
+        corrected++;
+    }
+    return corrected;
+}
+
+std::vector<RouterClockOffset> LogMerger::summarize_clock_offsets(const std::vector<ReportClockOffset>& offsets) {
+    std::map<std::string, std::vector<const ReportClockOffset*>> by_router;
+    for (const auto& offset : offsets) {
+        by_router[offset.router_name].push_back(&offset);
+    }
+
+    std::vector<RouterClockOffset> summaries;
+    for (const auto& entry : by_router) {
+        std::vector<double> values;
+        RouterClockOffset summary;
+        summary.router_name = entry.first;
+        summary.samples = entry.second.size();
+        summary.min_rtt_ms = entry.second.front()->rtt_ms;
+        for (const auto* offset : entry.second) {
+            values.push_back(offset->offset_ms);
+            summary.min_rtt_ms = std::min(summary.min_rtt_ms, offset->rtt_ms);
+        }
+        std::sort(values.begin(), values.end());
+        summary.min_offset_ms = values.front();
+        summary.max_offset_ms = values.back();
+        summary.median_offset_ms = values.size() % 2 == 1
+            ? values[values.size() / 2]
+            : (values[values.size() / 2 - 1] + values[values.size() / 2]) / 2.0;
+        summaries.push_back(summary);
+    }
+    return summaries;
+}
+
+namespace {
+
+std::string trigger_field(const ReportSession& session, const char* key) {
+    auto it = session.trigger_info.find(key);
+    return it != session.trigger_info.end() ? it->second : std::string();
+}
+
+// 路由触发的会话反映的是收敛过程，只有故障直接触发的会话才带有故障特征
+bool has_signature(const ReportSession& session) {
+    return session.trigger_source != "route" &&
+           (!trigger_field(session, "link").empty() || !trigger_field(session, "netem").empty());
+}
+
+// 双方都已知的特征分量必须相同
+bool signature_compatible(const FaultGroup& fault, const ReportSession& session) {
+    std::string link = trigger_field(session, "link");
+    std::string netem = trigger_field(session, "netem");
+    return (fault.link.empty() || link.empty() || fault.link == link) &&
+           (fault.netem.empty() || netem.empty() || fault.netem == netem);
+}
+
+} // namespace
+
+std::vector<FaultGroup> LogMerger::align(const std::vector<ReportSession>& sessions,
+                                         int64_t tolerance_ms, bool use_signature) {
+    std::vector<ReportSession> ordered;
+    for (const auto& session : sessions) {
+        if (session.completed && session.start_time_ms >= 0) {
+            ordered.push_back(session);
+        }
+    }
+    std::sort(ordered.begin(), ordered.end(), [](const ReportSession& a, const ReportSession& b) {
+        return a.start_time_ms < b.start_time_ms;
+    });
+
+    std::vector<FaultGroup> faults;
+    for (const auto& session : ordered) {
+        // 窗口以故障的最早触发时间为起点，避免链式漂移；从最近开始的故障往前找
+        std::vector<size_t> open;
+        for (size_t i = faults.size(); i > 0 && session.start_time_ms - faults[i - 1].start_time_ms <= tolerance_ms;
+             --i) {
+            open.push_back(i - 1);
+        }
+
+        FaultGroup* target = nullptr;
+        bool ambiguous = false;
+        if (!use_signature) {
+            if (!open.empty()) {
+                target = &faults[open.front()];
+            }
+        } else if (has_signature(session)) {
+            // 特征已知且相同的故障优先，其次是还没有特征的故障
+            for (size_t index : open) {
+                FaultGroup& candidate = faults[index];
+                if (!signature_compatible(candidate, session)) {
+                    continue;
+                }
+                if (!candidate.link.empty() || !candidate.netem.empty()) {
+                    target = &candidate;
+                    break;
+                }
+                if (target == nullptr) {
+                    target = &candidate;
+                }
+            }
+        } else {
+            std::string link = trigger_field(session, "link");
+            for (size_t index : open) {
+                if (!link.empty() && faults[index].link == link) {
+                    target = &faults[index];
+                    break;
+                }
+            }
+            if (target == nullptr && !open.empty()) {
+                target = &faults[open.front()];
+                ambiguous = open.size() > 1;
+            }
+        }
+
+        if (target == nullptr) {
+            FaultGroup fault;
+            fault.fault_id = static_cast<int>(faults.size()) + 1;
+            fault.start_time_ms = session.start_time_ms;
+            faults.push_back(std::move(fault));
+            target = &faults.back();
+        }
+
+        FaultGroup& fault = *target;
+        if (use_signature && has_signature(session)) {
+            if (fault.link.empty()) {
+                fault.link = trigger_field(session, "link");
+            }
+            if (fault.netem.empty()) {
+                fault.netem = trigger_field(session, "netem");
+            }
+        }
+        bool seen = std::any_of(fault.sessions.begin(), fault.sessions.end(),
+                                [&](const ReportSession& s) { return s.router_name == session.router_name; });
+        if (seen) {
+            fault.duplicate_sessions++;
+            continue;
+        }
+        if (ambiguous) {
+            fault.ambiguous_sessions++;
+        }
+        fault.sessions.push_back(session);
+    }
+
+    for (auto& fault : faults) {
+        std::map<std::string, int64_t> prefixes;  // 前缀 -> 任一路由器上最后一次变化的时刻
+        for (const auto& session : fault.sessions) {
+            int64_t trigger_offset = session.start_time_ms - fault.start_time_ms;
+            int64_t global = trigger_offset + session.convergence_time_ms.value_or(0);
+            if (session.forced) {
+                fault.forced_routers++;
+            } else if (fault.slowest_router.empty() || global > fault.global_convergence_ms) {
+                fault.global_convergence_ms = global;
+                fault.slowest_router = session.router_name;
+                fault.slowest_router_convergence_ms = session.convergence_time_ms.value_or(0);
+            }
+            if (session.timed_out) {
+                fault.timed_out_routers++;
+            }
+
+            // 只有路由事件带目的前缀，FRR/BGP/邻接等事件不参与
+            for (const auto& event : session.events) {
+                auto dst = event.info.find("dst");
+                if (dst == event.info.end() || dst->second.empty()) {
+                    continue;
+                }
+                auto dst_len = event.info.find("dst_len");
+                std::string prefix = dst->second == "default" || dst_len == event.info.end()
+                    ? dst->second : dst->second + "/" + dst_len->second;
+                int64_t offset = trigger_offset + event.offset_ms;
+                auto it = prefixes.find(prefix);
+                if (it == prefixes.end()) {
+                    prefixes.emplace(prefix, offset);
+                } else {
+                    it->second = std::max(it->second, offset);
+                }
+                if (fault.slowest_prefix.empty() || offset > fault.slowest_prefix_ms) {
+                    fault.slowest_prefix = prefix;
+                    fault.slowest_prefix_router = session.router_name;
+                    fault.slowest_prefix_ms = offset;
+                }
+            }
+        }
+        fault.prefixes_count = static_cast<int>(prefixes.size());
+    }
+
+    return faults;
+}
+
+std::string FaultGroup::routers() const {
+    std::string text;
+    for (const auto& session : sessions) {
+        text += (text.empty() ? "" : ",") + session.router_name;
+    }
+    return text;
+}
+
+namespace {
+
+void print_merge_usage(const char* program_name) {
+    std::cout << "用法: " << program_name << " merge [选项] LOG1 LOG2 ...\n\n";
+    std::cout << "合并多个路由器的日志，按触发时间对齐会话，输出每次故障的全网收敛视图\n\n";
+    std::cout << "选项:\n";
+    std::cout << "  -w, --window MS       对齐窗口(默认1000ms)\n";
+    std::cout << "  -f, --format FORMAT   输出格式: markdown(默认)、ndjson\n";
+    std::cout << "      --no-clock-correction  不按clock_offset记录校正时钟偏差，直接使用各节点的时间戳\n";
+    std::cout << "      --time-only       只按时间窗口对齐，不按故障特征(链路、netem参数)区分并发故障\n";
+    std::cout << "  -h, --help            显示此帮助信息\n";
+}
+
+} // namespace
+
+int merge_main(int argc, char* argv[]) {
+    int64_t tolerance_ms = 1000;
+    std::string format = "markdown";
+    bool clock_correction = true;
+    bool use_signature = true;
+
+    static struct option long_options[] = {
+        {"window", required_argument, 0, 'w'},
+        {"format", required_argument, 0, 'f'},
+        {"no-clock-correction", no_argument, 0, 'C'},
+        {"time-only", no_argument, 0, 'T'},
+        {"help", no_argument, 0, 'h'},
+        {0, 0, 0, 0}
+    };
+
+    optind = 1;
+    int c;
+    while ((c = getopt_long(argc, argv, "w:f:h", long_options, nullptr)) != -1) {
+        switch (c) {
+            case 'w': tolerance_ms = std::stoll(optarg); break;
+            case 'f': format = optarg; break;
+            case 'C': clock_correction = false; break;
+            case 'T': use_signature = false; break;
+            case 'h': print_merge_usage(argv[0]); return 0;
+            default:  print_merge_usage(argv[0]); return 1;
+        }
+    }
+
+    if (optind >= argc) {
+        print_merge_usage(argv[0]);
+        return 1;
+    }
+
+    std::vector<ReportSession> sessions;
+    std::vector<ReportClockOffset> clock_offsets;
+    for (int i = optind; i < argc; ++i) {
+        ReportData data;
+        std::string error;
+        if (!ConvergenceReport::load(argv[i], data, error)) {
+            std::cerr << "❌ " << error << "\n";
+            return 1;
+        }
+        sessions.insert(sessions.end(), data.sessions.begin(), data.sessions.end());
+        clock_offsets.insert(clock_offsets.end(), data.clock_offsets.begin(), data.clock_offsets.end());
+    }
+
+    int corrected = clock_correction ? LogMerger::apply_clock_offsets(sessions, clock_offsets) : 0;
+    auto clock_summaries = LogMerger::summarize_clock_offsets(clock_offsets);
+    auto faults = LogMerger::align(sessions, tolerance_ms, use_signature);
+
+    if (format == "ndjson") {
+        for (const auto& fault : faults) {
+            JsonObject record;
+            record["event_type"] = "fault_summary";
+            record["schema_version"] = Logger::SCHEMA_VERSION;
+            record["fault_id"] = static_cast<int64_t>(fault.fault_id);
+            record["start_time_ms"] = fault.start_time_ms;
+            record["routers_count"] = static_cast<int64_t>(fault.sessions.size());
+            record["routers"] = fault.routers();
+            record["global_convergence_ms"] = fault.global_convergence_ms;
+            record["slowest_router"] = fault.slowest_router;
+            record["slowest_router_convergence_ms"] = fault.slowest_router_convergence_ms;
+            record["timed_out_routers"] = static_cast<int64_t>(fault.timed_out_routers);
+            if (fault.forced_routers > 0) {
+                record["forced_routers"] = static_cast<int64_t>(fault.forced_routers);
+            }
+            record["prefixes_count"] = static_cast<int64_t>(fault.prefixes_count);
+            if (!fault.slowest_prefix.empty()) {
+                record["slowest_prefix"] = fault.slowest_prefix;
+                record["slowest_prefix_router"] = fault.slowest_prefix_router;
+                record["slowest_prefix_ms"] = fault.slowest_prefix_ms;
+            }
+            record["duplicate_sessions"] = static_cast<int64_t>(fault.duplicate_sessions);
+            if (!fault.link.empty()) {
+                record["link"] = fault.link;
+            }
+            if (!fault.netem.empty()) {
+                record["netem"] = fault.netem;
+            }
+            if (fault.ambiguous_sessions > 0) {
+                record["ambiguous_sessions"] = static_cast<int64_t>(fault.ambiguous_sessions);
+            }
+            std::cout << Logger::json_to_string(record) << "\n";
+
+            for (const auto& session : fault.sessions) {
+                JsonObject entry = ConvergenceReport::session_to_json(session);
+                entry["event_type"] = "fault_router_session";
+                entry["schema_version"] = Logger::SCHEMA_VERSION;
+                entry["fault_id"] = static_cast<int64_t>(fault.fault_id);
+                entry["trigger_offset_ms"] = session.start_time_ms - fault.start_time_ms;
+                if (session.clock_correction_ms) {
+                    entry["clock_correction_ms"] = *session.clock_correction_ms;
+                }
+                std::cout << Logger::json_to_string(entry) << "\n";
+            }
+        }
+    } else if (format == "markdown" || format == "md") {
+        std::cout << "## Merged convergence view\n\n";
+        std::cout << "- Logs: " << (argc - optind) << ", alignment window: " << tolerance_ms << " ms\n";
+        std::cout << "- Faults: " << faults.size() << "\n";
+        if (clock_summaries.empty()) {
+            std::cout << "- Clock correction: none (no clock_offset records, assuming synchronized clocks)\n\n";
+        } else {
+            std::cout << "- Clock correction: " << (clock_correction ? "applied to " + std::to_string(corrected) +
+                                                                           " session(s)" : "disabled")
+                      << "\n\n";
+            std::cout << "| Router | Samples | Median offset (ms) | Range (ms) | Min RTT (ms) |\n";
+            std::cout << "|---|---:|---:|---|---:|\n";
+            double lowest = clock_summaries.front().median_offset_ms;
+            double highest = lowest;
+            // 没有clock_offset的路由器(如作为参考的节点)视为与参考时钟一致，偏差为0，不列出
+            for (const auto& session : sessions) {
+                if (std::none_of(clock_summaries.begin(), clock_summaries.end(), [&](const RouterClockOffset& c) {
+                        return c.router_name == session.router_name;
+                    })) {
+                    lowest = std::min(lowest, 0.0);
+                    highest = std::max(highest, 0.0);
+                }
+            }
+            std::cout << std::fixed << std::setprecision(3);
+            for (const auto& summary : clock_summaries) {
+                std::cout << "| " << summary.router_name << " | " << summary.samples << " | "
+                          << summary.median_offset_ms << " | " << summary.min_offset_ms << " .. "
+                          << summary.max_offset_ms << " | " << summary.min_rtt_ms << " |\n";
+                lowest = std::min(lowest, summary.median_offset_ms);
+                highest = std::max(highest, summary.median_offset_ms);
+            }
+            std::cout << "\nLargest pairwise clock skew: " << (highest - lowest) << " ms\n\n";
+            std::cout.unsetf(std::ios::floatfield);
+        }
+        for (const auto& fault : faults) {
+            std::cout << "### Fault #" << fault.fault_id << "\n\n";
+            std::cout << "Global convergence: **" << fault.global_convergence_ms << " ms** (slowest: "
+                      << fault.slowest_router << ", routers: " << fault.sessions.size() << ")\n\n";
+            if (!fault.link.empty() || !fault.netem.empty()) {
+                std::cout << "Signature: " << (fault.link.empty() ? "" : "link `" + fault.link + "`")
+                          << (fault.link.empty() || fault.netem.empty() ? "" : ", ")
+                          << (fault.netem.empty() ? "" : "netem `" + fault.netem + "`") << "\n\n";
+            }
+            if (!fault.slowest_prefix.empty()) {
+                std::cout << "Slowest prefix: `" << fault.slowest_prefix << "` on " << fault.slowest_prefix_router
+                          << " at " << fault.slowest_prefix_ms << " ms (" << fault.prefixes_count
+                          << " prefixes changed)\n\n";
+            }
+            if (fault.timed_out_routers > 0) {
+                std::cout << fault.timed_out_routers
+                          << " router(s) timed out; their convergence time is a lower bound.\n\n";
+            }
+            if (fault.forced_routers > 0) {
+                std::cout << fault.forced_routers
+                          << " router(s) were still converging when monitoring stopped and are left out of "
+                             "the global convergence time.\n\n";
+            }
+            std::cout << "| Router | Session | Trigger offset (ms) | Interface | Local convergence (ms) | Route events |\n";
+            std::cout << "|---|---:|---:|---|---:|---:|\n";
+            for (const auto& session : fault.sessions) {
+                std::cout << "| " << session.router_name << " | " << session.session_id << " | "
+                          << (session.start_time_ms - fault.start_time_ms) << " | " << session.interface()
+                          << " | " << (session.convergence_time_ms.has_value()
+                                           ? std::to_string(session.convergence_time_ms.value()) : "-")
+                          << (session.timed_out ? " (timeout)" : session.forced ? " (forced)" : "") << " | "
+                          << session.route_events << " |\n";
+            }
+            if (fault.ambiguous_sessions > 0) {
+                std::cout << "\n" << fault.ambiguous_sessions
+                          << " route-triggered session(s) overlapped concurrent faults and were assigned by time.\n";
+            }
+            if (fault.duplicate_sessions > 0) {
+                std::cout << "\n" << fault.duplicate_sessions
+                          << " additional session(s) from the same router inside the window were ignored.\n";
+            }
+            std::cout << "\n";
+        }
+    } else {
+        std::cerr << "❌ 错误: 不支持的输出格式 " << format << "\n";
+        return 1;
+    }
+
+    return 0;
+}
@@ -0,0 +1,64 @@
+#pragma once
+
+#include <string>
+#include <vector>
+#include "report.h"
+
+// 一次故障在各路由器上对应的会话
+struct FaultGroup {
+    int fault_id = 0;
+    int64_t start_time_ms = 0;          // 最早触发时间
+    std::vector<ReportSession> sessions; // 每个路由器一个会话
+    int duplicate_sessions = 0;          // 同一路由器在窗口内的额外会话
+
+    // 故障特征：由故障直接触发的会话(netem等非路由触发)所在的拓扑链路与netem参数，未知时为空
+    std::string link;
+    std::string netem;
+    int ambiguous_sessions = 0;          // 窗口内有多个并发故障、无法按特征区分而按时间归入的路由触发会话
+
+    // 相对最早触发时间的全局收敛时间(各路由器"触发偏移+本地收敛时间"的最大值)及最慢路由器
+    int64_t global_convergence_ms = 0;
+    std::string slowest_router;
+    int64_t slowest_router_convergence_ms = 0;  // 最慢路由器的本地收敛时间
+    int timed_out_routers = 0;
+    int forced_routers = 0;  // 监听结束时仍未收敛的路由器，不参与全局收敛时间
+
+    // 最后一次变化相对最早触发时间最晚的前缀，没有带前缀的路由事件时为空
+    std::string slowest_prefix;
+    std::string slowest_prefix_router;
+    int64_t slowest_prefix_ms = 0;
+    int prefixes_count = 0;  // 各路由器上发生变化的不同前缀数
+
+    // 参与的路由器，逗号分隔
+    std::string routers() const;
+};
+
+// 一个路由器相对时钟参考的偏差汇总
+struct RouterClockOffset {
+    std::string router_name;
+    size_t samples = 0;
+    double median_offset_ms = 0.0;
+    double min_offset_ms = 0.0;
+    double max_offset_ms = 0.0;
+    double min_rtt_ms = 0.0;
+};
+
+class LogMerger {
+public:
+    // 用各路由器在会话开始时刻最近的一次clock_offset把触发时间换算到参考时钟，
+    // 没有clock_offset记录的路由器(如作为参考的节点)保持不变；返回校正的会话数
+    static int apply_clock_offsets(std::vector<ReportSession>& sessions,
+                                   const std::vector<ReportClockOffset>& offsets);
+
+    // 按路由器汇总偏差，两个路由器的中位偏差之差即为它们之间的时钟偏差
+    static std::vector<RouterClockOffset> summarize_clock_offsets(const std::vector<ReportClockOffset>& offsets);
+
+    // 按触发时间在tolerance_ms窗口内对齐多个路由器的会话。use_signature时窗口内特征(链路、netem参数)
+    // 不同的直接触发会话分属不同故障，几乎同时发生的两个独立故障不会被合并；路由触发的会话归入触发接口
+    // 所在链路相同的故障，否则归入窗口内最近开始的故障
+    static std::vector<FaultGroup> align(const std::vector<ReportSession>& sessions,
+                                         int64_t tolerance_ms, bool use_signature = true);
+};
+
+// merge 子命令入口
+int merge_main(int argc, char* argv[]);
@@ -1,4 +1,6 @@
 #include "netlink_monitor.h"
+#include "route_tables.h"
+#include <algorithm>
 #include <iostream>
 #include <cstring>
 #include <cerrno>
@@ -11,6 +13,20 @@
 #include <chrono>
 #include <sys/epoll.h>
 #include <unistd.h>
+#include <linux/lwtunnel.h>
+#include <linux/seg6_iptunnel.h>
+#include <linux/seg6_local.h>
+#include <linux/if_link.h>
+#include <linux/if_tunnel.h>
+#include <linux/if_bonding.h>
+
+namespace {
+
+// 订阅的多播组；IPv6规则组没有RTMGRP_*宏，按组号换算成位掩码
+constexpr uint32_t SUBSCRIBED_GROUPS = RTMGRP_IPV4_ROUTE | RTMGRP_IPV6_ROUTE |
+                                       RTMGRP_IPV4_RULE | (1u << (RTNLGRP_IPV6_RULE - 1));
+
+} // namespace
 
 // NetlinkSocket 实现
 NetlinkSocket::NetlinkSocket(int protocol, uint32_t groups) : fd_(-1) {
@@ -79,10 +95,82 @@ void NetlinkMonitor::set_qdisc_callback(QdiscEventCallback callback) {
     qdisc_callback_ = std::move(callback);
 }
 
+void NetlinkMonitor::set_link_callback(LinkEventCallback callback) {
+    link_callback_ = std::move(callback);
+}
+
 void NetlinkMonitor::set_unified_callback(NetlinkEventCallback callback) {
     unified_callback_ = std::move(callback);
 }
 
+void NetlinkMonitor::set_raw_callback(NetlinkRawCallback callback) {
+    raw_callback_ = std::move(callback);
+}
+
+void NetlinkMonitor::set_restart_callback(NetlinkRestartCallback callback) {
+    restart_callback_ = std::move(callback);
+}
+
+void NetlinkMonitor::set_error_callback(NetlinkErrorCallback callback) {
+    error_callback_ = std::move(callback);
+}
+
+void NetlinkMonitor::report_error(const std::string& severity, const std::string& message) {
+    std::cerr << message << "\n";
+    if (error_callback_) {
+        error_callback_(severity, message);
+    }
+}
+
+void NetlinkMonitor::set_queue_capacity(size_t capacity) {
+    queue_capacity_ = capacity > 0 ? capacity : 1;
+}
+
+void NetlinkMonitor::set_worker_count(size_t count) {
+    worker_count_ = count > 0 ? count : 1;
+}
+
+void NetlinkMonitor::set_mroute_monitoring(bool enabled) {
+    mroute_monitoring_ = enabled;
+}
+
+void NetlinkMonitor::set_fdb_monitoring(bool enabled) {
+    fdb_monitoring_ = enabled;
+}
+
+void NetlinkMonitor::set_link_monitoring(bool enabled) {
+    link_monitoring_ = enabled;
+}
+
+void NetlinkMonitor::set_tc_monitoring(bool enabled) {
+    tc_monitoring_ = enabled;
+}
+
+uint32_t NetlinkMonitor::subscribed_groups() const {
+    uint32_t groups = SUBSCRIBED_GROUPS;
+    if (tc_monitoring_) {
+        groups |= RTMGRP_TC;
+    }
+    if (mroute_monitoring_) {
+        groups |= RTMGRP_IPV4_MROUTE | RTMGRP_IPV6_MROUTE;
+    }
+    if (fdb_monitoring_) {
+        groups |= RTMGRP_NEIGH;
+    }
+    if (link_monitoring_) {
+        groups |= RTMGRP_LINK;
+    }
+    return groups;
+}
+
+NetlinkQueueStats NetlinkMonitor::queue_stats() {
+    std::lock_guard<std::mutex> lock(queue_mutex_);
+    NetlinkQueueStats stats = queue_stats_;
+    stats.backlog = static_cast<int64_t>(backlog_locked());
+    stats.capacity = static_cast<int64_t>(queue_capacity_);
+    return stats;
+}
+
 bool NetlinkMonitor::start_monitoring() {
     if (running_.load()) {
         return true;
@@ -92,13 +180,13 @@ bool NetlinkMonitor::start_monitoring() {
         // 创建统一的netlink套接字
         netlink_socket_fd_ = create_unified_netlink_socket();
         if (netlink_socket_fd_ < 0) {
-            std::cerr << "Failed to create unified netlink socket\n";
+            report_error("critical", std::string("Failed to create unified netlink socket: ") + strerror(errno));
             return false;
         }
 
         // 创建用于优雅关闭的管道
         if (pipe2(shutdown_pipe_, O_CLOEXEC | O_NONBLOCK) < 0) {
-            std::cerr << "Failed to create shutdown pipe\n";
+            report_error("critical", std::string("Failed to create shutdown pipe: ") + strerror(errno));
             close(netlink_socket_fd_);
             netlink_socket_fd_ = -1;
             return false;
@@ -107,7 +195,7 @@ bool NetlinkMonitor::start_monitoring() {
         // 创建epoll实例
         epoll_fd_ = epoll_create1(EPOLL_CLOEXEC);
         if (epoll_fd_ < 0) {
-            std::cerr << "Failed to create epoll instance\n";
+            report_error("critical", std::string("Failed to create epoll instance: ") + strerror(errno));
             close(netlink_socket_fd_);
             close(shutdown_pipe_[0]);
             close(shutdown_pipe_[1]);
@@ -121,7 +209,7 @@ bool NetlinkMonitor::start_monitoring() {
         ev.events = EPOLLIN;
         ev.data.fd = netlink_socket_fd_;
         if (epoll_ctl(epoll_fd_, EPOLL_CTL_ADD, netlink_socket_fd_, &ev) < 0) {
-            std::cerr << "Failed to add netlink socket to epoll\n";
+            report_error("critical", std::string("Failed to add netlink socket to epoll: ") + strerror(errno));
             close(epoll_fd_);
             close(netlink_socket_fd_);
             close(shutdown_pipe_[0]);
@@ -136,7 +224,7 @@ bool NetlinkMonitor::start_monitoring() {
         ev.events = EPOLLIN;
         ev.data.fd = shutdown_pipe_[0];
         if (epoll_ctl(epoll_fd_, EPOLL_CTL_ADD, shutdown_pipe_[0], &ev) < 0) {
-            std::cerr << "Failed to add shutdown pipe to epoll\n";
+            report_error("critical", std::string("Failed to add shutdown pipe to epoll: ") + strerror(errno));
             close(epoll_fd_);
             close(netlink_socket_fd_);
             close(shutdown_pipe_[0]);
@@ -147,15 +235,38 @@ bool NetlinkMonitor::start_monitoring() {
             return false;
         }
 
+        {
+            std::lock_guard<std::mutex> lock(queue_mutex_);
+            queue_.clear();
+            ready_.clear();
+            in_flight_ = 0;
+            next_seq_ = 0;
+            next_deliver_seq_ = 0;
+            delivering_ = false;
+            queue_stats_ = NetlinkQueueStats();
+            reader_done_ = false;
+        }
+        {
+            std::lock_guard<std::mutex> lock(health_mutex_);
+            subscription_problem_.clear();
+        }
+        last_route_message_ms_.store(0);
+        last_tc_message_ms_.store(0);
+        loop_heartbeat_ms_.store(std::chrono::duration_cast<std::chrono::milliseconds>(
+            std::chrono::steady_clock::now().time_since_epoch()).count());
+
         running_.store(true);
 
-        // 启动统一监控线程
+        // 启动统一监控线程与解析工作线程
         monitor_thread_ = std::thread(&NetlinkMonitor::unified_monitor_loop, this);
+        for (size_t i = 0; i < worker_count_; ++i) {
+            worker_threads_.emplace_back(&NetlinkMonitor::worker_loop, this);
+        }
 
         return true;
 
     } catch (const std::exception& e) {
-        std::cerr << "Failed to start netlink monitoring: " << e.what() << "\n";
+        report_error("critical", std::string("Failed to start netlink monitoring: ") + e.what());
         return false;
     }
 }
@@ -187,10 +298,16 @@ void NetlinkMonitor::stop_monitoring() {
         write(shutdown_pipe_[1], &dummy, 1);
     }
 
-    // 等待线程结束
+    // 等待线程结束，工作线程在读取线程退出后处理完队列中剩余的消息
     if (monitor_thread_.joinable()) {
         monitor_thread_.join();
     }
+    for (auto& worker : worker_threads_) {
+        if (worker.joinable()) {
+            worker.join();
+        }
+    }
+    worker_threads_.clear();
 
     // 关闭所有文件描述符
     if (netlink_socket_fd_ >= 0) {
@@ -223,8 +340,8 @@ int NetlinkMonitor::create_unified_netlink_socket() {
     struct sockaddr_nl addr;
     memset(&addr, 0, sizeof(addr));
     addr.nl_family = AF_NETLINK;
-    // 同时监听路由和TC事件
-    addr.nl_groups = RTMGRP_IPV4_ROUTE | RTMGRP_IPV6_ROUTE | RTMGRP_TC;
+    // 同时监听路由、策略路由规则和TC事件(以及可选的组播路由)
+    addr.nl_groups = subscribed_groups();
     addr.nl_pid = 0;
 
     if (bind(fd, reinterpret_cast<struct sockaddr*>(&addr), sizeof(addr)) < 0) {
@@ -235,11 +352,86 @@ int NetlinkMonitor::create_unified_netlink_socket() {
     return fd;
 }
 
+bool NetlinkMonitor::resubscribe(const std::string& reason, const std::string& detail) {
+    if (netlink_socket_fd_ >= 0) {
+        epoll_ctl(epoll_fd_, EPOLL_CTL_DEL, netlink_socket_fd_, nullptr);
+        close(netlink_socket_fd_);
+        netlink_socket_fd_ = -1;
+    }
+
+    int fd = create_unified_netlink_socket();
+    if (fd >= 0) {
+        struct epoll_event ev;
+        ev.events = EPOLLIN;
+        ev.data.fd = fd;
+        if (epoll_ctl(epoll_fd_, EPOLL_CTL_ADD, fd, &ev) < 0) {
+            close(fd);
+            fd = -1;
+        }
+    }
+    if (fd < 0) {
+        // 第一次失败时提示，之后每个epoll周期静默重试
+        if (pending_restart_reason_.empty()) {
+            report_error("error", std::string("Failed to resubscribe netlink socket: ") + strerror(errno));
+        }
+        pending_restart_reason_ = reason;
+        pending_restart_detail_ = detail;
+        std::lock_guard<std::mutex> lock(health_mutex_);
+        subscription_problem_ = reason + ": " + (detail.empty() ? "resubscribe failed" : detail);
+        return false;
+    }
+
+    netlink_socket_fd_ = fd;
+    pending_restart_reason_.clear();
+    pending_restart_detail_.clear();
+    {
+        std::lock_guard<std::mutex> lock(health_mutex_);
+        subscription_problem_.clear();
+    }
+    restart_count_.fetch_add(1);
+    if (restart_callback_) {
+        restart_callback_(reason, detail);
+    }
+    return true;
+}
+
+std::string NetlinkMonitor::check_subscription_health() {
+    int so_error = 0;
+    socklen_t optlen = sizeof(so_error);
+    if (getsockopt(netlink_socket_fd_, SOL_SOCKET, SO_ERROR, &so_error, &optlen) < 0) {
+        return "getsockopt: " + std::string(strerror(errno));
+    }
+    if (so_error != 0 && so_error != ENOBUFS) {
+        return strerror(so_error);
+    }
+
+    struct sockaddr_nl addr;
+    socklen_t addrlen = sizeof(addr);
+    memset(&addr, 0, sizeof(addr));
+    if (getsockname(netlink_socket_fd_, reinterpret_cast<struct sockaddr*>(&addr), &addrlen) < 0) {
+        return "getsockname: " + std::string(strerror(errno));
+    }
+    uint32_t groups = subscribed_groups();
+    if ((addr.nl_groups & groups) != groups) {
+        return "multicast groups lost";
+    }
+    return "";
+}
+
 void NetlinkMonitor::unified_monitor_loop() {
     char buffer[NETLINK_BUFFER_SIZE];
     struct epoll_event events[MAX_EPOLL_EVENTS];
+    auto last_activity = std::chrono::steady_clock::now();
 
     while (running_.load()) {
+        loop_heartbeat_ms_.store(std::chrono::duration_cast<std::chrono::milliseconds>(
+            std::chrono::steady_clock::now().time_since_epoch()).count());
+
+        // 上次重建失败，继续重试直到套接字恢复
+        if (netlink_socket_fd_ < 0 && !pending_restart_reason_.empty()) {
+            resubscribe(pending_restart_reason_, pending_restart_detail_);
+        }
+
         // 使用epoll等待事件，超时时间1000ms
         int nfds = epoll_wait(epoll_fd_, events, MAX_EPOLL_EVENTS, 1000);
 
@@ -252,13 +444,23 @@ void NetlinkMonitor::unified_monitor_loop() {
                 continue; // 继续等待
             }
             if (running_.load()) {
-                std::cerr << "Epoll wait error: " << strerror(errno) << "\n";
+                // 监听循环就此结束，之后不会再收到任何事件
+                report_error("critical", std::string("Epoll wait error: ") + strerror(errno));
             }
             break;
         }
 
         if (nfds == 0) {
-            // 超时，继续循环检查running状态
+            // 超时：长时间没有消息时确认订阅仍然有效，再继续检查running状态
+            auto now = std::chrono::steady_clock::now();
+            if (netlink_socket_fd_ >= 0 &&
+                now - last_activity >= std::chrono::milliseconds(WATCHDOG_IDLE_MS)) {
+                last_activity = now;
+                std::string problem = check_subscription_health();
+                if (!problem.empty()) {
+                    resubscribe("unhealthy", problem);
+                }
+            }
             continue;
         }
 
@@ -271,20 +473,60 @@ void NetlinkMonitor::unified_monitor_loop() {
                     if (errno == EINTR || errno == EAGAIN || errno == EWOULDBLOCK) {
                         continue;
                     }
-                    if (running_.load()) {
-                        std::cerr << "Netlink recv error: " << strerror(errno) << "\n";
+                    if (!running_.load()) {
+                        break;
+                    }
+                    int recv_errno = errno;
+                    // ENOBUFS只表示接收队列溢出丢了消息，套接字本身仍然可用
+                    report_error(recv_errno == ENOBUFS ? "warning" : "error",
+                                 std::string("Netlink recv error: ") + strerror(recv_errno));
+                    if (recv_errno == ENOBUFS) {
+                        std::lock_guard<std::mutex> lock(queue_mutex_);
+                        queue_stats_.overruns++;
+                    } else {
+                        resubscribe("recv_error", strerror(recv_errno));
                     }
                     break;
                 }
 
                 if (len == 0) {
+                    if (running_.load()) {
+                        resubscribe("closed", "netlink socket returned EOF");
+                    }
                     break;
                 }
 
-                // 处理netlink消息
+                last_activity = std::chrono::steady_clock::now();
+                int64_t received_ms = std::chrono::duration_cast<std::chrono::milliseconds>(
+                    std::chrono::system_clock::now().time_since_epoch()).count();
+
+                // 拆分netlink消息并交给分发线程
                 struct nlmsghdr* nlh = reinterpret_cast<struct nlmsghdr*>(buffer);
                 while (NLMSG_OK(nlh, len)) {
-                    process_netlink_message(nlh);
+                    switch (nlh->nlmsg_type) {
+                    case RTM_NEWROUTE:
+                    case RTM_DELROUTE:
+                    case RTM_NEWRULE:
+                    case RTM_DELRULE:
+                        last_route_message_ms_.store(received_ms);
+                        break;
+                    case RTM_NEWQDISC:
+                    case RTM_DELQDISC:
+                        last_tc_message_ms_.store(received_ms);
+                        break;
+                    default:
+                        break;
+                    }
+                    ssize_t next_len = len;
+                    struct nlmsghdr* next = NLMSG_NEXT(nlh, next_len);
+                    if (NLMSG_OK(next, next_len) && is_qdisc_replacement(nlh, next)) {
+                        // 替换产生的删除与添加合并为一条QDISC_REPLACE
+                        enqueue_message(next, received_ms, nlh);
+                        nlh = NLMSG_NEXT(next, next_len);
+                        len = next_len;
+                        continue;
+                    }
+                    enqueue_message(nlh, received_ms);
                     nlh = NLMSG_NEXT(nlh, len);
                 }
             } else if (events[i].data.fd == shutdown_pipe_[0]) {
@@ -295,36 +537,291 @@ void NetlinkMonitor::unified_monitor_loop() {
             }
         }
     }
+
+    {
+        std::lock_guard<std::mutex> lock(queue_mutex_);
+        reader_done_ = true;
+    }
+    queue_cv_.notify_all();
+}
+
+NetlinkHealth NetlinkMonitor::health() const {
+    NetlinkHealth health;
+    health.running = running_.load();
+    {
+        std::lock_guard<std::mutex> lock(health_mutex_);
+        health.problem = subscription_problem_;
+    }
+    health.subscribed = health.running && health.problem.empty();
+    if (health.running) {
+        int64_t now = std::chrono::duration_cast<std::chrono::milliseconds>(
+            std::chrono::steady_clock::now().time_since_epoch()).count();
+        health.loop_age_ms = now - loop_heartbeat_ms_.load();
+    }
+    health.last_route_message_ms = last_route_message_ms_.load();
+    health.last_tc_message_ms = last_tc_message_ms_.load();
+    health.tc_monitoring = tc_monitoring_;
+    health.restart_count = restart_count_.load();
+    return health;
+}
+
+bool NetlinkMonitor::is_qdisc_replacement(const struct nlmsghdr* del, const struct nlmsghdr* add) {
+    if (del->nlmsg_type != RTM_DELQDISC || add->nlmsg_type != RTM_NEWQDISC ||
+        !(add->nlmsg_flags & NLM_F_REPLACE)) {
+        return false;
+    }
+    const struct tcmsg* old_tcm = static_cast<const struct tcmsg*>(NLMSG_DATA(del));
+    const struct tcmsg* new_tcm = static_cast<const struct tcmsg*>(NLMSG_DATA(add));
+    // 添加qdisc时被替换的是系统默认qdisc(noqueue、pfifo_fast等，句柄为0)，仍视为添加
+    return old_tcm->tcm_ifindex == new_tcm->tcm_ifindex && old_tcm->tcm_parent == new_tcm->tcm_parent &&
+           old_tcm->tcm_handle != 0;
+}
+
+void NetlinkMonitor::enqueue_message(const struct nlmsghdr* nlh, int64_t received_ms,
+                                     const struct nlmsghdr* replaced) {
+    bool first_drop = false;
+    {
+        std::lock_guard<std::mutex> lock(queue_mutex_);
+        queue_stats_.received += replaced ? 2 : 1;
+        if (backlog_locked() >= queue_capacity_) {
+            first_drop = queue_stats_.dropped == 0;
+            queue_stats_.dropped += replaced ? 2 : 1;
+        } else {
+            const char* data = reinterpret_cast<const char*>(nlh);
+            QueuedMessage message{next_seq_++, received_ms, std::vector<char>(data, data + nlh->nlmsg_len), {}};
+            if (replaced) {
+                const char* old_data = reinterpret_cast<const char*>(replaced);
+                message.replaced.assign(old_data, old_data + replaced->nlmsg_len);
+            }
+            queue_.push_back(std::move(message));
+            queue_stats_.max_backlog = std::max(queue_stats_.max_backlog,
+                                                static_cast<int64_t>(backlog_locked()));
+        }
+    }
+    if (first_drop) {
+        // 只提示第一次，丢弃总数见最终统计
+        report_error("warning", "Netlink queue full (" + std::to_string(queue_capacity_) + "), dropping messages");
+        return;
+    }
+    queue_cv_.notify_one();
+}
+
+void NetlinkMonitor::worker_loop() {
+    while (true) {
+        QueuedMessage message;
+        {
+            std::unique_lock<std::mutex> lock(queue_mutex_);
+            queue_cv_.wait(lock, [this] { return !queue_.empty() || reader_done_; });
+            if (queue_.empty()) {
+                break;
+            }
+            message = std::move(queue_.front());
+            queue_.pop_front();
+            in_flight_++;
+        }
+
+        ParsedMessage parsed = parse_message(std::move(message));
+
+        std::unique_lock<std::mutex> lock(queue_mutex_);
+        in_flight_--;
+        uint64_t seq = parsed.message.seq;
+        ready_.emplace(seq, std::move(parsed));
+        if (delivering_) {
+            // 正在交付的线程会在处理完手头的消息后取走这条
+            continue;
+        }
+
+        // 按序号连续交付，遇到仍在解析的消息即停止，由解析它的线程接着交付
+        delivering_ = true;
+        auto it = ready_.find(next_deliver_seq_);
+        while (it != ready_.end()) {
+            ParsedMessage next = std::move(it->second);
+            ready_.erase(it);
+            next_deliver_seq_++;
+            lock.unlock();
+            deliver_message(std::move(next));
+            lock.lock();
+            it = ready_.find(next_deliver_seq_);
+        }
+        delivering_ = false;
+    }
+}
+
+NetlinkMonitor::ParsedMessage NetlinkMonitor::parse_message(QueuedMessage message) {
+    ParsedMessage parsed;
+    parsed.message = std::move(message);
+    const struct nlmsghdr* nlh = reinterpret_cast<const struct nlmsghdr*>(parsed.message.data.data());
+    parsed.type = get_message_type(nlh);
+
+    if (parsed.type == NetlinkMessageType::ROUTE_ADD ||
+        parsed.type == NetlinkMessageType::ROUTE_DEL ||
+        parsed.type == NetlinkMessageType::ROUTE_REPLACE) {
+        const struct rtmsg* rtm = static_cast<const struct rtmsg*>(NLMSG_DATA(nlh));
+        int attrlen = nlh->nlmsg_len - NLMSG_LENGTH(sizeof(*rtm));
+        const struct rtattr* rta = reinterpret_cast<const struct rtattr*>(
+            reinterpret_cast<const char*>(rtm) + NLMSG_ALIGN(sizeof(*rtm)));
+        parsed.info = NetlinkMessageParser::parse_route_message(rtm, rta, attrlen);
+        parsed.notify = true;
+    } else if (parsed.type == NetlinkMessageType::MROUTE_ADD ||
+               parsed.type == NetlinkMessageType::MROUTE_DEL) {
+        const struct rtmsg* rtm = static_cast<const struct rtmsg*>(NLMSG_DATA(nlh));
+        int attrlen = nlh->nlmsg_len - NLMSG_LENGTH(sizeof(*rtm));
+        const struct rtattr* rta = reinterpret_cast<const struct rtattr*>(
+            reinterpret_cast<const char*>(rtm) + NLMSG_ALIGN(sizeof(*rtm)));
+        parsed.info = NetlinkMessageParser::parse_mroute_message(rtm, rta, attrlen);
+        parsed.notify = true;
+    } else if (parsed.type == NetlinkMessageType::FDB_ADD ||
+               parsed.type == NetlinkMessageType::FDB_DEL) {
+        const struct ndmsg* ndm = static_cast<const struct ndmsg*>(NLMSG_DATA(nlh));
+        int attrlen = nlh->nlmsg_len - NLMSG_LENGTH(sizeof(*ndm));
+        const struct rtattr* rta = reinterpret_cast<const struct rtattr*>(
+            reinterpret_cast<const char*>(ndm) + NLMSG_ALIGN(sizeof(*ndm)));
+        parsed.info = NetlinkMessageParser::parse_fdb_message(ndm, rta, attrlen);
+        parsed.notify = true;
+    } else if (parsed.type == NetlinkMessageType::LINK_ADD ||
+               parsed.type == NetlinkMessageType::LINK_CHANGE ||
+               parsed.type == NetlinkMessageType::LINK_DEL) {
+        const struct ifinfomsg* ifi = static_cast<const struct ifinfomsg*>(NLMSG_DATA(nlh));
+        int attrlen = nlh->nlmsg_len - NLMSG_LENGTH(sizeof(*ifi));
+        const struct rtattr* rta = reinterpret_cast<const struct rtattr*>(
+            reinterpret_cast<const char*>(ifi) + NLMSG_ALIGN(sizeof(*ifi)));
+        parsed.info = NetlinkMessageParser::parse_link_message(ifi, rta, attrlen);
+        parsed.notify = true;
+    } else if (parsed.type == NetlinkMessageType::RULE_ADD ||
+               parsed.type == NetlinkMessageType::RULE_DEL) {
+        const struct fib_rule_hdr* frh = static_cast<const struct fib_rule_hdr*>(NLMSG_DATA(nlh));
+        int attrlen = nlh->nlmsg_len - NLMSG_LENGTH(sizeof(*frh));
+        const struct rtattr* rta = reinterpret_cast<const struct rtattr*>(
+            reinterpret_cast<const char*>(frh) + NLMSG_ALIGN(sizeof(*frh)));
+        parsed.info = NetlinkMessageParser::parse_rule_message(frh, rta, attrlen);
+        parsed.notify = true;
+    } else if (parsed.type == NetlinkMessageType::QDISC_ADD ||
+               parsed.type == NetlinkMessageType::QDISC_DEL ||
+               parsed.type == NetlinkMessageType::QDISC_GET ||
+               parsed.type == NetlinkMessageType::QDISC_CHANGE) {
+        const struct tcmsg* tcm = static_cast<const struct tcmsg*>(NLMSG_DATA(nlh));
+        int attrlen = nlh->nlmsg_len - NLMSG_LENGTH(sizeof(*tcm));
+        const struct rtattr* rta = reinterpret_cast<const struct rtattr*>(
+            reinterpret_cast<const char*>(tcm) + NLMSG_ALIGN(sizeof(*tcm)));
+        parsed.info = NetlinkMessageParser::parse_qdisc_message(tcm, rta, attrlen);
+
+        if (!parsed.message.replaced.empty()) {
+            parsed.type = NetlinkMessageType::QDISC_REPLACE;
+            const struct nlmsghdr* old_nlh =
+                reinterpret_cast<const struct nlmsghdr*>(parsed.message.replaced.data());
+            const struct tcmsg* old_tcm = static_cast<const struct tcmsg*>(NLMSG_DATA(old_nlh));
+            int old_attrlen = old_nlh->nlmsg_len - NLMSG_LENGTH(sizeof(*old_tcm));
+            const struct rtattr* old_rta = reinterpret_cast<const struct rtattr*>(
+                reinterpret_cast<const char*>(old_tcm) + NLMSG_ALIGN(sizeof(*old_tcm)));
+            auto old_info = NetlinkMessageParser::parse_qdisc_message(old_tcm, old_rta, old_attrlen);
+            parsed.info["replaced_kind"] = old_info["kind"];
+            parsed.info["replaced_handle"] = old_info["handle"];
+        }
+
+        // 忽略 noqueue 类型的 qdisc
+        auto kind_it = parsed.info.find("kind");
+        parsed.notify = kind_it == parsed.info.end() || kind_it->second != "noqueue";
+    }
+    return parsed;
 }
 
-void NetlinkMonitor::process_netlink_message(const struct nlmsghdr* nlh) {
-    NetlinkMessageType msg_type = get_message_type(nlh);
+void NetlinkMonitor::deliver_message(ParsedMessage parsed) {
+    const struct nlmsghdr* nlh = reinterpret_cast<const struct nlmsghdr*>(parsed.message.data.data());
+    const struct nlmsghdr* replaced_nlh = parsed.message.replaced.empty() ? nullptr :
+        reinterpret_cast<const struct nlmsghdr*>(parsed.message.replaced.data());
+    if (raw_callback_) {
+        if (replaced_nlh) {
+            raw_callback_(replaced_nlh);
+        }
+        raw_callback_(nlh);
+    }
 
-    // 根据消息类型调用相应的处理函数
-    if (msg_type == NetlinkMessageType::ROUTE_ADD ||
-        msg_type == NetlinkMessageType::ROUTE_DEL) {
-        handle_route_message(nlh);
-    } else if (msg_type == NetlinkMessageType::QDISC_ADD ||
-               msg_type == NetlinkMessageType::QDISC_DEL ||
-               msg_type == NetlinkMessageType::QDISC_GET ||
-               msg_type == NetlinkMessageType::QDISC_CHANGE) {
-        handle_qdisc_message(nlh);
+    if (parsed.notify) {
+        NetlinkEvent event{nlh, message_type_to_string(parsed.type), parsed.message.received_ms,
+                           std::move(parsed.info)};
+        bool is_route = parsed.type == NetlinkMessageType::ROUTE_ADD ||
+                        parsed.type == NetlinkMessageType::ROUTE_DEL ||
+                        parsed.type == NetlinkMessageType::ROUTE_REPLACE ||
+                        parsed.type == NetlinkMessageType::RULE_ADD ||
+                        parsed.type == NetlinkMessageType::RULE_DEL ||
+                        parsed.type == NetlinkMessageType::MROUTE_ADD ||
+                        parsed.type == NetlinkMessageType::MROUTE_DEL ||
+                        parsed.type == NetlinkMessageType::FDB_ADD ||
+                        parsed.type == NetlinkMessageType::FDB_DEL;
+        bool is_link = parsed.type == NetlinkMessageType::LINK_ADD ||
+                       parsed.type == NetlinkMessageType::LINK_CHANGE ||
+                       parsed.type == NetlinkMessageType::LINK_DEL;
+        if (is_route && route_callback_) {
+            route_callback_(event);
+        } else if (is_link) {
+            if (link_callback_) {
+                link_callback_(event);
+            }
+        } else if (!is_route && qdisc_callback_) {
+            qdisc_callback_(event);
+        }
     }
 
     // 如果设置了统一回调，也调用它
+    if (unified_callback_ && replaced_nlh) {
+        unified_callback_(replaced_nlh, message_type_to_string(NetlinkMessageType::QDISC_DEL),
+                          NetlinkMessageType::QDISC_DEL);
+    }
     if (unified_callback_) {
-        unified_callback_(nlh, message_type_to_string(msg_type), msg_type);
+        unified_callback_(nlh, message_type_to_string(parsed.type), parsed.type);
     }
 }
 
 NetlinkMessageType NetlinkMonitor::get_message_type(const struct nlmsghdr* nlh) {
+    // 组播路由复用RTM_NEWROUTE/RTM_DELROUTE，按地址族区分
+    if ((nlh->nlmsg_type == RTM_NEWROUTE || nlh->nlmsg_type == RTM_DELROUTE) &&
+        nlh->nlmsg_len >= NLMSG_LENGTH(sizeof(struct rtmsg))) {
+        const struct rtmsg* rtm = static_cast<const struct rtmsg*>(NLMSG_DATA(nlh));
+        if (rtm->rtm_family == RTNL_FAMILY_IPMR || rtm->rtm_family == RTNL_FAMILY_IP6MR) {
+            return nlh->nlmsg_type == RTM_NEWROUTE ? NetlinkMessageType::MROUTE_ADD
+                                                   : NetlinkMessageType::MROUTE_DEL;
+        }
+    }
+
+    // 内核注册新接口时通知的ifi_change为全1，之后的状态变化只置变化的标志位；
+    // 网桥以AF_BRIDGE另发端口状态通知(端口离开网桥时为RTM_DELLINK)，不是接口本身的变化
+    if ((nlh->nlmsg_type == RTM_NEWLINK || nlh->nlmsg_type == RTM_DELLINK) &&
+        nlh->nlmsg_len >= NLMSG_LENGTH(sizeof(struct ifinfomsg))) {
+        const auto* ifi = static_cast<const struct ifinfomsg*>(NLMSG_DATA(nlh));
+        if (ifi->ifi_family == AF_BRIDGE) {
+            return NetlinkMessageType::UNKNOWN;
+        }
+        if (nlh->nlmsg_type == RTM_DELLINK) {
+            return NetlinkMessageType::LINK_DEL;
+        }
+        return ifi->ifi_change == 0xFFFFFFFFu ? NetlinkMessageType::LINK_ADD : NetlinkMessageType::LINK_CHANGE;
+    }
+
+    // 邻居组中只关心转发表，ARP/ND邻居不作为事件
+    if (nlh->nlmsg_type == RTM_NEWNEIGH || nlh->nlmsg_type == RTM_DELNEIGH) {
+        if (nlh->nlmsg_len >= NLMSG_LENGTH(sizeof(struct ndmsg)) &&
+            static_cast<const struct ndmsg*>(NLMSG_DATA(nlh))->ndm_family == AF_BRIDGE) {
+            return nlh->nlmsg_type == RTM_NEWNEIGH ? NetlinkMessageType::FDB_ADD
+                                                   : NetlinkMessageType::FDB_DEL;
+        }
+        return NetlinkMessageType::UNKNOWN;
+    }
+
     switch (nlh->nlmsg_type) {
         case RTM_NEWROUTE:
-            return NetlinkMessageType::ROUTE_ADD;
+            // 只有替换已存在的路由时通知才带NLM_F_REPLACE；新增路由(包括ip route replace新建的)带NLM_F_CREATE|NLM_F_EXCL
+            return (nlh->nlmsg_flags & NLM_F_REPLACE) ? NetlinkMessageType::ROUTE_REPLACE
+                                                      : NetlinkMessageType::ROUTE_ADD;
         case RTM_DELROUTE:
             return NetlinkMessageType::ROUTE_DEL;
+        case RTM_NEWRULE:
+            return NetlinkMessageType::RULE_ADD;
+        case RTM_DELRULE:
+            return NetlinkMessageType::RULE_DEL;
         case RTM_NEWQDISC:
-            return NetlinkMessageType::QDISC_ADD;
+            // 内核在挂上新的qdisc实例时带NLM_F_REPLACE(包括替换系统默认qdisc的添加)，修改参数时不带
+            return (nlh->nlmsg_flags & NLM_F_REPLACE) ? NetlinkMessageType::QDISC_ADD
+                                                      : NetlinkMessageType::QDISC_CHANGE;
         case RTM_DELQDISC:
             return NetlinkMessageType::QDISC_DEL;
         case RTM_GETQDISC:
@@ -337,9 +834,29 @@ NetlinkMessageType NetlinkMonitor::get_message_type(const struct nlmsghdr* nlh)
 std::string NetlinkMonitor::message_type_to_string(NetlinkMessageType type) {
     switch (type) {
         case NetlinkMessageType::ROUTE_ADD:
-            return "路由添加";
+            return "route_add";
         case NetlinkMessageType::ROUTE_DEL:
-            return "路由删除";
+            return "route_del";
+        case NetlinkMessageType::ROUTE_REPLACE:
+            return "route_replace";
+        case NetlinkMessageType::RULE_ADD:
+            return "rule_add";
+        case NetlinkMessageType::RULE_DEL:
+            return "rule_del";
+        case NetlinkMessageType::MROUTE_ADD:
+            return "mroute_add";
+        case NetlinkMessageType::MROUTE_DEL:
+            return "mroute_del";
+        case NetlinkMessageType::FDB_ADD:
+            return "fdb_add";
+        case NetlinkMessageType::FDB_DEL:
+            return "fdb_del";
+        case NetlinkMessageType::LINK_ADD:
+            return "link_add";
+        case NetlinkMessageType::LINK_CHANGE:
+            return "link_change";
+        case NetlinkMessageType::LINK_DEL:
+            return "link_del";
         case NetlinkMessageType::QDISC_ADD:
             return "QDISC_ADD";
         case NetlinkMessageType::QDISC_DEL:
@@ -348,56 +865,16 @@ std::string NetlinkMonitor::message_type_to_string(NetlinkMessageType type) {
             return "QDISC_GET";
         case NetlinkMessageType::QDISC_CHANGE:
             return "QDISC_CHANGE";
+        case NetlinkMessageType::QDISC_REPLACE:
+            return "QDISC_REPLACE";
         default:
             return "UNKNOWN";
     }
 }
 
-void NetlinkMonitor::handle_route_message(const struct nlmsghdr* nlh) {
-    NetlinkMessageType msg_type = get_message_type(nlh);
-    
-    if (msg_type == NetlinkMessageType::ROUTE_ADD || 
-        msg_type == NetlinkMessageType::ROUTE_DEL) {
-        
-        if (route_callback_) {
-            route_callback_(nlh, message_type_to_string(msg_type));
-        }
-    }
-}
-
-void NetlinkMonitor::handle_qdisc_message(const struct nlmsghdr* nlh) {
-    NetlinkMessageType msg_type = get_message_type(nlh);
-
-    // 只处理特定的 qdisc 消息类型
-    if (msg_type != NetlinkMessageType::QDISC_ADD &&
-        msg_type != NetlinkMessageType::QDISC_DEL &&
-        msg_type != NetlinkMessageType::QDISC_GET) {
-        return; // 忽略其他类型的消息
-    }
-
-    // 解析 qdisc 信息以检查类型
-    const struct tcmsg* tcm = static_cast<const struct tcmsg*>(NLMSG_DATA(nlh));
-    int attrlen = nlh->nlmsg_len - NLMSG_LENGTH(sizeof(*tcm));
-    const struct rtattr* rta = reinterpret_cast<const struct rtattr*>(
-        reinterpret_cast<const char*>(tcm) + NLMSG_ALIGN(sizeof(*tcm)));
-
-    auto qdisc_info = NetlinkMessageParser::parse_qdisc_message(tcm, rta, attrlen);
-
-    // 检查是否为 "noqueue" 类型，如果是则忽略
-    auto kind_it = qdisc_info.find("kind");
-    if (kind_it != qdisc_info.end() && kind_it->second == "noqueue") {
-        return; // 忽略 noqueue 类型的 qdisc
-    }
-
-    // 调用回调函数
-    if (qdisc_callback_) {
-        qdisc_callback_(nlh, message_type_to_string(msg_type));
-    }
-}
-
 void NetlinkMonitor::handle_netlink_error(const struct nlmsghdr* nlh) {
     struct nlmsgerr* err = static_cast<struct nlmsgerr*>(NLMSG_DATA(nlh));
-    std::cerr << "Netlink error: " << strerror(-err->error) << "\n";
+    report_error("error", std::string("Netlink error: ") + strerror(-err->error));
 }
 
 // NetlinkMessageParser 实现
@@ -408,10 +885,20 @@ std::unordered_map<std::string, std::string> NetlinkMessageParser::parse_route_m
 
     // 基本路由信息
     result["family"] = std::to_string(rtm->rtm_family);
+    result["dst_len"] = std::to_string(rtm->rtm_dst_len);
     result["table"] = std::to_string(rtm->rtm_table);
     result["protocol"] = get_route_protocol_name(rtm->rtm_protocol);
     result["scope"] = get_route_scope_name(rtm->rtm_scope);
     result["type"] = get_route_type_name(rtm->rtm_type);
+    result["origin"] = classify_route_origin(rtm);
+    std::string flags = get_route_flags_name(rtm->rtm_flags);
+    if (!flags.empty()) {
+        result["flags"] = flags;
+    }
+    // 源地址路由(IPv6 from ... 或源地址特定路由)
+    if (rtm->rtm_src_len > 0) {
+        result["src_len"] = std::to_string(rtm->rtm_src_len);
+    }
 
     // 解析路由属性
     parse_route_attributes(rta, len, result);
@@ -437,8 +924,564 @@ std::unordered_map<std::string, std::string> NetlinkMessageParser::parse_qdisc_m
     return result;
 }
 
+std::unordered_map<std::string, std::string> NetlinkMessageParser::parse_mroute_message(
+    const struct rtmsg* rtm, const struct rtattr* rta, int len) {
+
+    std::unordered_map<std::string, std::string> result;
+    int family = rtm->rtm_family == RTNL_FAMILY_IP6MR ? AF_INET6 : AF_INET;
+
+    result["family"] = std::to_string(rtm->rtm_family);
+    result["table"] = std::to_string(rtm->rtm_table);
+    result["protocol"] = get_route_protocol_name(rtm->rtm_protocol);
+
+    while (rta_ok(rta, len)) {
+        switch (rta->rta_type) {
+            case RTA_DST:
+                result["group"] = ip_to_string(rta_data(rta), family);
+                break;
+            case RTA_SRC:
+                result["source"] = ip_to_string(rta_data(rta), family);
+                break;
+            case RTA_IIF: {
+                int ifindex = *static_cast<int*>(rta_data(rta));
+                result["ifindex"] = std::to_string(ifindex);
+                result["interface"] = get_interface_name(ifindex);
+                break;
+            }
+            case RTA_TABLE:
+                result["table"] = std::to_string(*static_cast<uint32_t*>(rta_data(rta)));
+                break;
+            case RTA_MULTIPATH: {
+                // 每个rtnexthop对应一个出接口，rtnh_hops为TTL阈值
+                std::string oifs;
+                int nh_len = rta_len(rta);
+                const auto* rtnh = static_cast<const struct rtnexthop*>(rta_data(rta));
+                while (nh_len >= static_cast<int>(sizeof(*rtnh)) && rtnh->rtnh_len >= sizeof(*rtnh) &&
+                       rtnh->rtnh_len <= nh_len) {
+                    if (!oifs.empty()) {
+                        oifs += ",";
+                    }
+                    oifs += get_interface_name(rtnh->rtnh_ifindex);
+                    nh_len -= RTNH_ALIGN(rtnh->rtnh_len);
+                    rtnh = reinterpret_cast<const struct rtnexthop*>(
+                        reinterpret_cast<const char*>(rtnh) + RTNH_ALIGN(rtnh->rtnh_len));
+                }
+                result["oifs"] = oifs;
+                break;
+            }
+            default:
+                break;
+        }
+        rta = rta_next(rta, len);
+    }
+
+    // 组地址作为dst，使--filter-prefix可以按组过滤
+    if (result.find("source") == result.end()) {
+        result["source"] = "*";
+    }
+    if (result.find("group") != result.end()) {
+        result["dst"] = result["group"];
+    }
+    if (result.find("interface") == result.end()) {
+        result["interface"] = "N/A";
+    }
+    if (result.find("oifs") == result.end()) {
+        result["oifs"] = "";
+    }
+
+    return result;
+}
+
+std::unordered_map<std::string, std::string> NetlinkMessageParser::parse_fdb_message(
+    const struct ndmsg* ndm, const struct rtattr* rta, int len) {
+
+    std::unordered_map<std::string, std::string> result;
+
+    result["ifindex"] = std::to_string(ndm->ndm_ifindex);
+    result["interface"] = get_interface_name(ndm->ndm_ifindex);
+
+    // 状态与标志按iproute2 bridge fdb的写法输出
+    std::string state;
+    if (ndm->ndm_state & NUD_PERMANENT) {
+        state = "permanent";
+    } else if (ndm->ndm_state & NUD_NOARP) {
+        state = "static";
+    } else if (ndm->ndm_state & NUD_STALE) {
+        state = "stale";
+    } else {
+        state = "dynamic";
+    }
+    result["state"] = state;
+    std::string flags;
+    auto add_flag = [&flags](const char* name) {
+        flags += flags.empty() ? name : std::string(",") + name;
+    };
+    if (ndm->ndm_flags & NTF_SELF) add_flag("self");
+    if (ndm->ndm_flags & NTF_MASTER) add_flag("master");
+    if (ndm->ndm_flags & NTF_EXT_LEARNED) add_flag("extern_learn");
+    if (ndm->ndm_flags & NTF_OFFLOADED) add_flag("offload");
+    if (ndm->ndm_flags & NTF_STICKY) add_flag("sticky");
+    result["flags"] = flags;
+
+    while (rta_ok(rta, len)) {
+        switch (rta->rta_type) {
+            case NDA_LLADDR:
+                if (rta_len(rta) == 6) {
+                    const auto* mac = static_cast<const unsigned char*>(rta_data(rta));
+                    char text[18];
+                    snprintf(text, sizeof(text), "%02x:%02x:%02x:%02x:%02x:%02x",
+                             mac[0], mac[1], mac[2], mac[3], mac[4], mac[5]);
+                    result["mac"] = text;
+                }
+                break;
+            case NDA_DST:
+                // VXLAN表项的远端VTEP，长度区分IPv4/IPv6
+                result["remote"] = ip_to_string(rta_data(rta), rta_len(rta) == 16 ? AF_INET6 : AF_INET);
+                break;
+            case NDA_VLAN:
+                result["vlan"] = std::to_string(*static_cast<uint16_t*>(rta_data(rta)));
+                break;
+            case NDA_VNI:
+                result["vni"] = std::to_string(*static_cast<uint32_t*>(rta_data(rta)));
+                break;
+            case NDA_SRC_VNI:
+                result["src_vni"] = std::to_string(*static_cast<uint32_t*>(rta_data(rta)));
+                break;
+            case NDA_PORT:
+                result["remote_port"] = std::to_string(ntohs(*static_cast<uint16_t*>(rta_data(rta))));
+                break;
+            case NDA_MASTER:
+                result["master"] = get_interface_name(*static_cast<int*>(rta_data(rta)));
+                break;
+            default:
+                break;
+        }
+        rta = rta_next(rta, len);
+    }
+
+    if (result.find("mac") == result.end()) {
+        result["mac"] = "N/A";
+    }
+
+    return result;
+}
+
+namespace {
+
+// 隧道端点属性按长度区分IPv4/IPv6；全零地址(未指定)视为空
+std::string tunnel_address(const struct rtattr* rta) {
+    int family = RTA_PAYLOAD(rta) == 16 ? AF_INET6 : AF_INET;
+    size_t size = family == AF_INET6 ? 16 : 4;
+    if (RTA_PAYLOAD(rta) < size) {
+        return "";
+    }
+    const auto* bytes = static_cast<const unsigned char*>(RTA_DATA(rta));
+    bool any = false;
+    for (size_t i = 0; i < size; ++i) {
+        any = any || bytes[i] != 0;
+    }
+    return any ? NetlinkMessageParser::ip_to_string(bytes, family) : "";
+}
+
+// IFLA_INFO_DATA中隧道两端地址与密钥/VNI所在的属性号，按类型区分
+void parse_tunnel_data(const std::string& kind, const struct rtattr* data,
+                       std::unordered_map<std::string, std::string>& result) {
+    int local_attr = -1, remote_attr = -1, remote6_attr = -1, local6_attr = -1, key_attr = -1;
+    if (kind == "gre" || kind == "gretap" || kind == "ip6gre" || kind == "ip6gretap" ||
+        kind == "erspan" || kind == "ip6erspan") {
+        local_attr = IFLA_GRE_LOCAL;
+        remote_attr = IFLA_GRE_REMOTE;
+        key_attr = IFLA_GRE_OKEY;
+    } else if (kind == "ipip" || kind == "sit" || kind == "ip6tnl") {
+        local_attr = IFLA_IPTUN_LOCAL;
+        remote_attr = IFLA_IPTUN_REMOTE;
+    } else if (kind == "vti" || kind == "vti6") {
+        local_attr = IFLA_VTI_LOCAL;
+        remote_attr = IFLA_VTI_REMOTE;
+        key_attr = IFLA_VTI_OKEY;
+    } else if (kind == "vxlan") {
+        local_attr = IFLA_VXLAN_LOCAL;
+        local6_attr = IFLA_VXLAN_LOCAL6;
+        remote_attr = IFLA_VXLAN_GROUP;
+        remote6_attr = IFLA_VXLAN_GROUP6;
+        key_attr = IFLA_VXLAN_ID;
+    } else if (kind == "geneve") {
+        remote_attr = IFLA_GENEVE_REMOTE;
+        remote6_attr = IFLA_GENEVE_REMOTE6;
+        key_attr = IFLA_GENEVE_ID;
+    } else {
+        return;
+    }
+
+    int len = RTA_PAYLOAD(data);
+    for (const struct rtattr* rta = static_cast<const struct rtattr*>(RTA_DATA(data)); RTA_OK(rta, len);
+         rta = RTA_NEXT(rta, len)) {
+        int type = rta->rta_type;
+        if (type == local_attr || type == local6_attr) {
+            std::string address = tunnel_address(rta);
+            if (!address.empty()) {
+                result["tunnel_local"] = address;
+            }
+        } else if (type == remote_attr || type == remote6_attr) {
+            std::string address = tunnel_address(rta);
+            if (!address.empty()) {
+                result["tunnel_remote"] = address;
+            }
+        } else if (type == key_attr && RTA_PAYLOAD(rta) >= sizeof(uint32_t)) {
+            // GRE/VTI的key为网络字节序，VXLAN/Geneve的VNI为主机字节序
+            uint32_t key = *static_cast<const uint32_t*>(RTA_DATA(rta));
+            bool network_order = kind != "vxlan" && kind != "geneve";
+            result["tunnel_key"] = std::to_string(network_order ? ntohl(key) : key);
+        }
+    }
+}
+
+std::string bond_mode_name(int mode) {
+    switch (mode) {
+        case BOND_MODE_ROUNDROBIN: return "balance-rr";
+        case BOND_MODE_ACTIVEBACKUP: return "active-backup";
+        case BOND_MODE_XOR: return "balance-xor";
+        case BOND_MODE_BROADCAST: return "broadcast";
+        case BOND_MODE_8023AD: return "802.3ad";
+        case BOND_MODE_TLB: return "balance-tlb";
+        case BOND_MODE_ALB: return "balance-alb";
+        default: return std::to_string(mode);
+    }
+}
+
+std::string bond_mii_status_name(int status) {
+    switch (status) {
+        case BOND_LINK_UP: return "up";
+        case BOND_LINK_FAIL: return "going_down";
+        case BOND_LINK_DOWN: return "down";
+        case BOND_LINK_BACK: return "going_back";
+        default: return std::to_string(status);
+    }
+}
+
+// LACP端口状态位(IEEE 802.1AX)，按位从低到高
+std::string lacp_state_name(unsigned state) {
+    static const char* const bits[] = {
+        "activity", "timeout", "aggregation", "synchronization",
+        "collecting", "distributing", "defaulted", "expired",
+    };
+    std::string names;
+    for (int i = 0; i < 8; ++i) {
+        if (state & (1u << i)) {
+            if (!names.empty()) names += ",";
+            names += bits[i];
+        }
+    }
+    return names.empty() ? "none" : names;
+}
+
+// bond主接口的IFLA_INFO_DATA：模式、当前活动成员与802.3ad活动聚合组
+void parse_bond_data(const struct rtattr* data, std::unordered_map<std::string, std::string>& result) {
+    int len = RTA_PAYLOAD(data);
+    for (const struct rtattr* rta = static_cast<const struct rtattr*>(RTA_DATA(data)); RTA_OK(rta, len);
+         rta = RTA_NEXT(rta, len)) {
+        if (rta->rta_type == IFLA_BOND_MODE && RTA_PAYLOAD(rta) >= sizeof(uint8_t)) {
+            result["bond_mode"] = bond_mode_name(*static_cast<const uint8_t*>(RTA_DATA(rta)));
+        } else if (rta->rta_type == IFLA_BOND_ACTIVE_SLAVE && RTA_PAYLOAD(rta) >= sizeof(uint32_t)) {
+            result["active_slave"] = NetlinkMessageParser::get_interface_name(
+                *static_cast<const uint32_t*>(RTA_DATA(rta)));
+        } else if (rta->rta_type == IFLA_BOND_AD_INFO) {
+            int ad_len = RTA_PAYLOAD(rta);
+            for (const struct rtattr* ad = static_cast<const struct rtattr*>(RTA_DATA(rta)); RTA_OK(ad, ad_len);
+                 ad = RTA_NEXT(ad, ad_len)) {
+                if (ad->rta_type == IFLA_BOND_AD_INFO_AGGREGATOR && RTA_PAYLOAD(ad) >= sizeof(uint16_t)) {
+                    result["ad_aggregator"] = std::to_string(*static_cast<const uint16_t*>(RTA_DATA(ad)));
+                } else if (ad->rta_type == IFLA_BOND_AD_INFO_NUM_PORTS && RTA_PAYLOAD(ad) >= sizeof(uint16_t)) {
+                    result["ad_num_ports"] = std::to_string(*static_cast<const uint16_t*>(RTA_DATA(ad)));
+                } else if (ad->rta_type == IFLA_BOND_AD_INFO_PARTNER_MAC && RTA_PAYLOAD(ad) == 6) {
+                    const auto* mac = static_cast<const unsigned char*>(RTA_DATA(ad));
+                    char text[18];
+                    snprintf(text, sizeof(text), "%02x:%02x:%02x:%02x:%02x:%02x",
+                             mac[0], mac[1], mac[2], mac[3], mac[4], mac[5]);
+                    result["ad_partner_mac"] = text;
+                }
+            }
+        }
+    }
+}
+
+// bond成员的IFLA_INFO_SLAVE_DATA：主备状态、MII状态与LACP协商状态
+void parse_bond_slave_data(const struct rtattr* data, std::unordered_map<std::string, std::string>& result) {
+    int len = RTA_PAYLOAD(data);
+    for (const struct rtattr* rta = static_cast<const struct rtattr*>(RTA_DATA(data)); RTA_OK(rta, len);
+         rta = RTA_NEXT(rta, len)) {
+        switch (rta->rta_type) {
+            case IFLA_BOND_SLAVE_STATE:
+                result["slave_state"] = *static_cast<const uint8_t*>(RTA_DATA(rta)) == BOND_STATE_ACTIVE
+                    ? "active" : "backup";
+                break;
+            case IFLA_BOND_SLAVE_MII_STATUS:
+                result["mii_status"] = bond_mii_status_name(*static_cast<const uint8_t*>(RTA_DATA(rta)));
+                break;
+            case IFLA_BOND_SLAVE_LINK_FAILURE_COUNT:
+                result["link_failure_count"] = std::to_string(*static_cast<const uint32_t*>(RTA_DATA(rta)));
+                break;
+            case IFLA_BOND_SLAVE_AD_AGGREGATOR_ID:
+                result["ad_aggregator_id"] = std::to_string(*static_cast<const uint16_t*>(RTA_DATA(rta)));
+                break;
+            case IFLA_BOND_SLAVE_AD_ACTOR_OPER_PORT_STATE:
+                result["ad_actor_state"] = lacp_state_name(*static_cast<const uint8_t*>(RTA_DATA(rta)));
+                break;
+            case IFLA_BOND_SLAVE_AD_PARTNER_OPER_PORT_STATE:
+                result["ad_partner_state"] = lacp_state_name(*static_cast<const uint16_t*>(RTA_DATA(rta)));
+                break;
+            default:
+                break;
+        }
+    }
+}
+
+std::string link_event_name(uint32_t event) {
+    switch (event) {
+        case IFLA_EVENT_REBOOT: return "reboot";
+        case IFLA_EVENT_FEATURES: return "features";
+        case IFLA_EVENT_BONDING_FAILOVER: return "bonding_failover";
+        case IFLA_EVENT_NOTIFY_PEERS: return "notify_peers";
+        case IFLA_EVENT_IGMP_RESEND: return "igmp_resend";
+        case IFLA_EVENT_BONDING_OPTIONS: return "bonding_options";
+        default: return std::to_string(event);
+    }
+}
+
+} // namespace
+
+std::unordered_map<std::string, std::string> NetlinkMessageParser::parse_link_message(
+    const struct ifinfomsg* ifi, const struct rtattr* rta, int len) {
+
+    std::unordered_map<std::string, std::string> result;
+
+    result["ifindex"] = std::to_string(ifi->ifi_index);
+    result["admin_state"] = (ifi->ifi_flags & IFF_UP) ? "up" : "down";
+    result["carrier"] = (ifi->ifi_flags & IFF_LOWER_UP) ? "on" : "off";
+    result["promisc"] = (ifi->ifi_flags & IFF_PROMISC) ? "on" : "off";
+
+    const struct rtattr* linkinfo = nullptr;
+    while (rta_ok(rta, len)) {
+        switch (rta->rta_type) {
+            case IFLA_IFNAME:
+                result["interface"] = static_cast<const char*>(rta_data(rta));
+                break;
+            case IFLA_OPERSTATE:
+                result["operstate"] = get_operstate_name(*static_cast<uint8_t*>(rta_data(rta)));
+                break;
+            case IFLA_MTU:
+                result["mtu"] = std::to_string(*static_cast<uint32_t*>(rta_data(rta)));
+                break;
+            case IFLA_MASTER:
+                result["master"] = get_interface_name(*static_cast<int*>(rta_data(rta)));
+                break;
+            case IFLA_LINKINFO:
+                linkinfo = rta;
+                break;
+            case IFLA_EVENT:
+                // 内核附带的通知原因，如bond切换活动成员时为bonding_failover
+                result["link_event"] = link_event_name(*static_cast<uint32_t*>(rta_data(rta)));
+                break;
+            default:
+                break;
+        }
+        rta = rta_next(rta, len);
+    }
+
+    if (linkinfo) {
+        int info_len = rta_len(linkinfo);
+        const struct rtattr* data = nullptr;
+        const struct rtattr* slave_data = nullptr;
+        for (const struct rtattr* info = static_cast<const struct rtattr*>(rta_data(linkinfo));
+             rta_ok(info, info_len); info = rta_next(info, info_len)) {
+            if (info->rta_type == IFLA_INFO_KIND) {
+                result["kind"] = static_cast<const char*>(rta_data(info));
+            } else if (info->rta_type == IFLA_INFO_SLAVE_KIND) {
+                result["slave_kind"] = static_cast<const char*>(rta_data(info));
+            } else if (info->rta_type == IFLA_INFO_DATA) {
+                data = info;
+            } else if (info->rta_type == IFLA_INFO_SLAVE_DATA) {
+                slave_data = info;
+            }
+        }
+        if (data && result.find("kind") != result.end()) {
+            if (result["kind"] == "bond") {
+                parse_bond_data(data, result);
+            } else {
+                parse_tunnel_data(result["kind"], data, result);
+            }
+        }
+        auto slave_kind = result.find("slave_kind");
+        if (slave_data && slave_kind != result.end() && slave_kind->second == "bond") {
+            parse_bond_slave_data(slave_data, result);
+        }
+    }
+
+    if (result.find("interface") == result.end()) {
+        result["interface"] = get_interface_name(ifi->ifi_index);
+    }
+    if (result.find("kind") == result.end()) {
+        result["kind"] = "";
+    }
+
+    return result;
+}
+
+std::unordered_map<std::string, std::string> NetlinkMessageParser::parse_rule_message(
+    const struct fib_rule_hdr* frh, const struct rtattr* rta, int len) {
+
+    std::unordered_map<std::string, std::string> result;
+
+    // 基本规则信息；表号超过255时由FRA_TABLE给出
+    result["family"] = std::to_string(frh->family);
+    result["table"] = std::to_string(frh->table);
+    result["action"] = get_rule_action_name(frh->action);
+    result["src_len"] = std::to_string(frh->src_len);
+    result["dst_len"] = std::to_string(frh->dst_len);
+
+    while (rta_ok(rta, len)) {
+        switch (rta->rta_type) {
+            case FRA_SRC:
+                result["src"] = ip_to_string(rta_data(rta), frh->family);
+                break;
+            case FRA_DST:
+                result["dst"] = ip_to_string(rta_data(rta), frh->family);
+                break;
+            case FRA_IIFNAME:
+                result["iif"] = static_cast<const char*>(rta_data(rta));
+                break;
+            case FRA_OIFNAME:
+                result["oif"] = static_cast<const char*>(rta_data(rta));
+                break;
+            case FRA_PRIORITY:
+                result["priority"] = std::to_string(*static_cast<uint32_t*>(rta_data(rta)));
+                break;
+            case FRA_TABLE:
+                result["table"] = std::to_string(*static_cast<uint32_t*>(rta_data(rta)));
+                break;
+            case FRA_FWMARK:
+                result["fwmark"] = std::to_string(*static_cast<uint32_t*>(rta_data(rta)));
+                break;
+            case FRA_GOTO:
+                result["goto"] = std::to_string(*static_cast<uint32_t*>(rta_data(rta)));
+                break;
+            case FRA_PROTOCOL:
+                result["protocol"] = get_route_protocol_name(*static_cast<uint8_t*>(rta_data(rta)));
+                break;
+            default:
+                break;
+        }
+        rta = rta_next(rta, len);
+    }
+
+    // 没有选择器时匹配全部；iif/oif作为接口供过滤和按接口统计使用
+    if (result.find("src") == result.end()) {
+        result["src"] = "all";
+    }
+    if (result.find("dst") == result.end()) {
+        result["dst"] = "all";
+    }
+    auto iif_it = result.find("iif");
+    auto oif_it = result.find("oif");
+    result["interface"] = iif_it != result.end() ? iif_it->second :
+                          oif_it != result.end() ? oif_it->second : "N/A";
+
+    return result;
+}
+
+std::unordered_map<std::string, std::string> NetlinkMessageParser::describe_message(
+    const struct nlmsghdr* nlh) {
+
+    static const std::unordered_map<int, const char*> type_names = {
+        {NLMSG_NOOP, "NLMSG_NOOP"}, {NLMSG_ERROR, "NLMSG_ERROR"}, {NLMSG_DONE, "NLMSG_DONE"},
+        {RTM_NEWLINK, "RTM_NEWLINK"}, {RTM_DELLINK, "RTM_DELLINK"},
+        {RTM_NEWADDR, "RTM_NEWADDR"}, {RTM_DELADDR, "RTM_DELADDR"},
+        {RTM_NEWROUTE, "RTM_NEWROUTE"}, {RTM_DELROUTE, "RTM_DELROUTE"},
+        {RTM_NEWNEIGH, "RTM_NEWNEIGH"}, {RTM_DELNEIGH, "RTM_DELNEIGH"},
+        {RTM_NEWRULE, "RTM_NEWRULE"}, {RTM_DELRULE, "RTM_DELRULE"},
+        {RTM_NEWQDISC, "RTM_NEWQDISC"}, {RTM_DELQDISC, "RTM_DELQDISC"}, {RTM_GETQDISC, "RTM_GETQDISC"},
+        {RTM_NEWTCLASS, "RTM_NEWTCLASS"}, {RTM_DELTCLASS, "RTM_DELTCLASS"},
+        {RTM_NEWTFILTER, "RTM_NEWTFILTER"}, {RTM_DELTFILTER, "RTM_DELTFILTER"},
+    };
+
+    std::unordered_map<std::string, std::string> result;
+    auto name_it = type_names.find(nlh->nlmsg_type);
+    result["nlmsg_type"] = name_it != type_names.end() ? name_it->second : std::to_string(nlh->nlmsg_type);
+    result["nlmsg_len"] = std::to_string(nlh->nlmsg_len);
+    char flags[16];
+    snprintf(flags, sizeof(flags), "0x%04x", nlh->nlmsg_flags);
+    result["nlmsg_flags"] = flags;
+    result["nlmsg_seq"] = std::to_string(nlh->nlmsg_seq);
+    result["nlmsg_pid"] = std::to_string(nlh->nlmsg_pid);
+
+    if ((nlh->nlmsg_type == RTM_NEWROUTE || nlh->nlmsg_type == RTM_DELROUTE) &&
+        nlh->nlmsg_len >= NLMSG_LENGTH(sizeof(struct rtmsg))) {
+        const struct rtmsg* rtm = static_cast<const struct rtmsg*>(NLMSG_DATA(nlh));
+        int attrlen = nlh->nlmsg_len - NLMSG_LENGTH(sizeof(*rtm));
+        const struct rtattr* rta = reinterpret_cast<const struct rtattr*>(
+            reinterpret_cast<const char*>(rtm) + NLMSG_ALIGN(sizeof(*rtm)));
+        if (rtm->rtm_family == RTNL_FAMILY_IPMR || rtm->rtm_family == RTNL_FAMILY_IP6MR) {
+            result.merge(parse_mroute_message(rtm, rta, attrlen));
+        } else {
+            result.merge(parse_route_message(rtm, rta, attrlen));
+        }
+        result["dst_len"] = std::to_string(rtm->rtm_dst_len);
+    } else if ((nlh->nlmsg_type == RTM_NEWNEIGH || nlh->nlmsg_type == RTM_DELNEIGH) &&
+               nlh->nlmsg_len >= NLMSG_LENGTH(sizeof(struct ndmsg)) &&
+               static_cast<const struct ndmsg*>(NLMSG_DATA(nlh))->ndm_family == AF_BRIDGE) {
+        const struct ndmsg* ndm = static_cast<const struct ndmsg*>(NLMSG_DATA(nlh));
+        int attrlen = nlh->nlmsg_len - NLMSG_LENGTH(sizeof(*ndm));
+        const struct rtattr* rta = reinterpret_cast<const struct rtattr*>(
+            reinterpret_cast<const char*>(ndm) + NLMSG_ALIGN(sizeof(*ndm)));
+        result.merge(parse_fdb_message(ndm, rta, attrlen));
+    } else if ((nlh->nlmsg_type == RTM_NEWLINK || nlh->nlmsg_type == RTM_DELLINK) &&
+               nlh->nlmsg_len >= NLMSG_LENGTH(sizeof(struct ifinfomsg))) {
+        const struct ifinfomsg* ifi = static_cast<const struct ifinfomsg*>(NLMSG_DATA(nlh));
+        int attrlen = nlh->nlmsg_len - NLMSG_LENGTH(sizeof(*ifi));
+        const struct rtattr* rta = reinterpret_cast<const struct rtattr*>(
+            reinterpret_cast<const char*>(ifi) + NLMSG_ALIGN(sizeof(*ifi)));
+        result.merge(parse_link_message(ifi, rta, attrlen));
+    } else if ((nlh->nlmsg_type == RTM_NEWRULE || nlh->nlmsg_type == RTM_DELRULE) &&
+               nlh->nlmsg_len >= NLMSG_LENGTH(sizeof(struct fib_rule_hdr))) {
+        const struct fib_rule_hdr* frh = static_cast<const struct fib_rule_hdr*>(NLMSG_DATA(nlh));
+        int attrlen = nlh->nlmsg_len - NLMSG_LENGTH(sizeof(*frh));
+        const struct rtattr* rta = reinterpret_cast<const struct rtattr*>(
+            reinterpret_cast<const char*>(frh) + NLMSG_ALIGN(sizeof(*frh)));
+        result.merge(parse_rule_message(frh, rta, attrlen));
+    } else if ((nlh->nlmsg_type == RTM_NEWQDISC || nlh->nlmsg_type == RTM_DELQDISC ||
+                nlh->nlmsg_type == RTM_GETQDISC) &&
+               nlh->nlmsg_len >= NLMSG_LENGTH(sizeof(struct tcmsg))) {
+        const struct tcmsg* tcm = static_cast<const struct tcmsg*>(NLMSG_DATA(nlh));
+        int attrlen = nlh->nlmsg_len - NLMSG_LENGTH(sizeof(*tcm));
+        const struct rtattr* rta = reinterpret_cast<const struct rtattr*>(
+            reinterpret_cast<const char*>(tcm) + NLMSG_ALIGN(sizeof(*tcm)));
+        result.merge(parse_qdisc_message(tcm, rta, attrlen));
+    }
+
+    // 原始字节只保留前256字节，足以覆盖消息头与常见属性
+    static constexpr size_t MAX_HEX_BYTES = 256;
+    static const char digits[] = "0123456789abcdef";
+    const auto* bytes = reinterpret_cast<const unsigned char*>(nlh);
+    size_t count = std::min<size_t>(nlh->nlmsg_len, MAX_HEX_BYTES);
+    std::string hex;
+    hex.reserve(count * 2);
+    for (size_t i = 0; i < count; ++i) {
+        hex += digits[bytes[i] >> 4];
+        hex += digits[bytes[i] & 0x0f];
+    }
+    result["raw_hex"] = hex;
+    if (nlh->nlmsg_len > MAX_HEX_BYTES) {
+        result["raw_truncated"] = "true";
+    }
+
+    return result;
+}
+
 void NetlinkMessageParser::parse_route_attributes(const struct rtattr* rta, int len,
                                                  std::unordered_map<std::string, std::string>& result) {
+    // 内核先写RTA_ENCAP再写RTA_ENCAP_TYPE，两者都拿到后再解析封装
+    const struct rtattr* encap = nullptr;
+    int encap_type = LWTUNNEL_ENCAP_NONE;
     while (rta_ok(rta, len)) {
         switch (rta->rta_type) {
             case RTA_DST: {
@@ -462,17 +1505,87 @@ void NetlinkMessageParser::parse_route_attributes(const struct rtattr* rta, int
                 result["prefsrc"] = ip_to_string(rta_data(rta), family);
                 break;
             }
+            case RTA_SRC: {
+                int family = std::stoi(result["family"]);
+                result["src"] = ip_to_string(rta_data(rta), family);
+                break;
+            }
             case RTA_PRIORITY: {
-                int priority = *static_cast<int*>(rta_data(rta));
+                // 即ip route中的metric
+                uint32_t priority = *static_cast<uint32_t*>(rta_data(rta));
                 result["priority"] = std::to_string(priority);
                 break;
             }
+            case RTA_MULTIPATH: {
+                // ECMP路由：每个rtnexthop写作"网关 dev 接口"(直连时只有dev)，逗号分隔
+                int family = std::stoi(result["family"]);
+                std::string nexthops;
+                int nh_len = rta_len(rta);
+                const auto* rtnh = static_cast<const struct rtnexthop*>(rta_data(rta));
+                while (nh_len >= static_cast<int>(sizeof(*rtnh)) && rtnh->rtnh_len >= sizeof(*rtnh) &&
+                       rtnh->rtnh_len <= nh_len) {
+                    std::string gateway;
+                    int attr_len = rtnh->rtnh_len - static_cast<int>(RTNH_LENGTH(0));
+                    for (const struct rtattr* attr = RTNH_DATA(rtnh); rta_ok(attr, attr_len);
+                         attr = rta_next(attr, attr_len)) {
+                        if (attr->rta_type == RTA_GATEWAY) {
+                            gateway = ip_to_string(rta_data(attr), family);
+                        }
+                    }
+                    nexthops += (nexthops.empty() ? "" : ",") + (gateway.empty() ? "" : gateway + " ") + "dev " +
+                                get_interface_name(rtnh->rtnh_ifindex);
+                    nh_len -= RTNH_ALIGN(rtnh->rtnh_len);
+                    rtnh = reinterpret_cast<const struct rtnexthop*>(
+                        reinterpret_cast<const char*>(rtnh) + RTNH_ALIGN(rtnh->rtnh_len));
+                }
+                result["nexthops"] = nexthops;
+                break;
+            }
+            case RTA_NH_ID:
+                // 引用nexthop对象(ip nexthop)的路由，下一跳本身的变化不会产生路由通知
+                result["nhid"] = std::to_string(*static_cast<uint32_t*>(rta_data(rta)));
+                break;
+            case RTA_TABLE:
+                // rtm_table只有8位，ID超过255的表(常见于VRF)以此为准
+                result["table"] = std::to_string(*static_cast<uint32_t*>(rta_data(rta)));
+                break;
+            case RTA_METRICS: {
+                int metrics_len = rta_len(rta);
+                for (const struct rtattr* metric = static_cast<const struct rtattr*>(rta_data(rta));
+                     rta_ok(metric, metrics_len); metric = rta_next(metric, metrics_len)) {
+                    if (rta_len(metric) < static_cast<int>(sizeof(uint32_t))) {
+                        continue;
+                    }
+                    uint32_t value = *static_cast<uint32_t*>(rta_data(metric));
+                    switch (metric->rta_type) {
+                        case RTAX_MTU: result["mtu"] = std::to_string(value); break;
+                        case RTAX_ADVMSS: result["advmss"] = std::to_string(value); break;
+                        case RTAX_HOPLIMIT: result["hoplimit"] = std::to_string(value); break;
+                        case RTAX_INITCWND: result["initcwnd"] = std::to_string(value); break;
+                        default: break;
+                    }
+                }
+                break;
+            }
+            case RTA_ENCAP:
+                encap = rta;
+                break;
+            case RTA_ENCAP_TYPE:
+                encap_type = *static_cast<uint16_t*>(rta_data(rta));
+                break;
             default:
                 break;
         }
         rta = rta_next(rta, len);
     }
 
+    if (encap_type != LWTUNNEL_ENCAP_NONE) {
+        result["encap"] = get_encap_type_name(encap_type);
+        if (encap) {
+            parse_route_encap(encap_type, encap, result);
+        }
+    }
+
     // 设置默认值
     if (result.find("dst") == result.end()) {
         result["dst"] = "default";
@@ -485,8 +1598,93 @@ void NetlinkMessageParser::parse_route_attributes(const struct rtattr* rta, int
     }
 }
 
+namespace {
+
+// SRH中的段按逆序存放(segments[0]为最后一段)，按转发顺序输出，逗号分隔
+std::string srh_segment_list(const struct ipv6_sr_hdr* srh, size_t available) {
+    std::string list;
+    size_t count = static_cast<size_t>(srh->first_segment) + 1;
+    if (sizeof(*srh) + count * sizeof(struct in6_addr) > available) {
+        return list;
+    }
+    for (size_t i = count; i-- > 0;) {
+        if (!list.empty()) {
+            list += ",";
+        }
+        list += NetlinkMessageParser::ip_to_string(&srh->segments[i], AF_INET6);
+    }
+    return list;
+}
+
+const char* seg6_mode_name(int mode) {
+    switch (mode) {
+        case SEG6_IPTUN_MODE_INLINE: return "inline";
+        case SEG6_IPTUN_MODE_ENCAP: return "encap";
+        case SEG6_IPTUN_MODE_L2ENCAP: return "l2encap";
+        case SEG6_IPTUN_MODE_ENCAP_RED: return "encap.red";
+        case SEG6_IPTUN_MODE_L2ENCAP_RED: return "l2encap.red";
+        default: return "unknown";
+    }
+}
+
+} // namespace
+
+void NetlinkMessageParser::parse_route_encap(int encap_type, const struct rtattr* encap,
+                                             std::unordered_map<std::string, std::string>& result) {
+    int len = rta_len(encap);
+    const struct rtattr* rta = static_cast<const struct rtattr*>(rta_data(encap));
+
+    if (encap_type == LWTUNNEL_ENCAP_SEG6) {
+        while (rta_ok(rta, len)) {
+            if (rta->rta_type == SEG6_IPTUNNEL_SRH &&
+                static_cast<size_t>(rta_len(rta)) >= sizeof(struct seg6_iptunnel_encap) + sizeof(struct ipv6_sr_hdr)) {
+                const auto* tuninfo = static_cast<const struct seg6_iptunnel_encap*>(rta_data(rta));
+                result["seg6_mode"] = seg6_mode_name(tuninfo->mode);
+                result["sid_list"] = srh_segment_list(tuninfo->srh, rta_len(rta) - sizeof(*tuninfo));
+            }
+            rta = rta_next(rta, len);
+        }
+    } else if (encap_type == LWTUNNEL_ENCAP_SEG6_LOCAL) {
+        while (rta_ok(rta, len)) {
+            switch (rta->rta_type) {
+                case SEG6_LOCAL_ACTION:
+                    result["seg6local_action"] = get_seg6local_action_name(*static_cast<uint32_t*>(rta_data(rta)));
+                    break;
+                case SEG6_LOCAL_SRH:
+                    if (static_cast<size_t>(rta_len(rta)) >= sizeof(struct ipv6_sr_hdr)) {
+                        result["sid_list"] = srh_segment_list(
+                            static_cast<const struct ipv6_sr_hdr*>(rta_data(rta)), rta_len(rta));
+                    }
+                    break;
+                case SEG6_LOCAL_TABLE:
+                    result["seg6local_table"] = std::to_string(*static_cast<uint32_t*>(rta_data(rta)));
+                    break;
+                case SEG6_LOCAL_VRFTABLE:
+                    result["seg6local_vrftable"] = std::to_string(*static_cast<uint32_t*>(rta_data(rta)));
+                    break;
+                case SEG6_LOCAL_NH4:
+                    result["seg6local_nh4"] = ip_to_string(rta_data(rta), AF_INET);
+                    break;
+                case SEG6_LOCAL_NH6:
+                    result["seg6local_nh6"] = ip_to_string(rta_data(rta), AF_INET6);
+                    break;
+                case SEG6_LOCAL_IIF:
+                    result["seg6local_iif"] = get_interface_name(*static_cast<int*>(rta_data(rta)));
+                    break;
+                case SEG6_LOCAL_OIF:
+                    result["seg6local_oif"] = get_interface_name(*static_cast<int*>(rta_data(rta)));
+                    break;
+                default:
+                    break;
+            }
+            rta = rta_next(rta, len);
+        }
+    }
+}
+
 void NetlinkMessageParser::parse_qdisc_attributes(const struct rtattr* rta, int len,
                                                  std::unordered_map<std::string, std::string>& result) {
+    const struct rtattr* options = nullptr;
     while (rta_ok(rta, len)) {
         switch (rta->rta_type) {
             case TCA_KIND: {
@@ -496,7 +1694,8 @@ void NetlinkMessageParser::parse_qdisc_attributes(const struct rtattr* rta, int
                 break;
             }
             case TCA_OPTIONS:
-                // 这里可以进一步解析QDisc选项
+                // 选项格式取决于kind，而kind不一定先出现
+                options = rta;
                 break;
             default:
                 break;
@@ -504,6 +1703,10 @@ void NetlinkMessageParser::parse_qdisc_attributes(const struct rtattr* rta, int
         rta = rta_next(rta, len);
     }
 
+    if (options && result["kind"] == "netem") {
+        parse_netem_options(options, result);
+    }
+
     // 设置默认值
     if (result.find("kind") == result.end()) {
         result["kind"] = "unknown";
@@ -511,6 +1714,69 @@ void NetlinkMessageParser::parse_qdisc_attributes(const struct rtattr* rta, int
     }
 }
 
+void NetlinkMessageParser::parse_netem_options(const struct rtattr* options,
+                                               std::unordered_map<std::string, std::string>& result) {
+    int len = rta_len(options);
+    if (len < static_cast<int>(sizeof(struct tc_netem_qopt))) {
+        return;
+    }
+    const auto* qopt = static_cast<const struct tc_netem_qopt*>(rta_data(options));
+
+    // 旧内核只有qopt中以psched tick(64ns)为单位的时延，4.15起另有纳秒精度的64位属性
+    int64_t latency_ns = static_cast<int64_t>(qopt->latency) * 64;
+    int64_t jitter_ns = static_cast<int64_t>(qopt->jitter) * 64;
+    int offset = NLMSG_ALIGN(sizeof(struct tc_netem_qopt));
+    const auto* nested = reinterpret_cast<const struct rtattr*>(
+        static_cast<const char*>(rta_data(options)) + offset);
+    int nested_len = len - offset;
+    while (rta_ok(nested, nested_len)) {
+        if (nested->rta_type == TCA_NETEM_LATENCY64 && rta_len(nested) >= static_cast<int>(sizeof(int64_t))) {
+            memcpy(&latency_ns, rta_data(nested), sizeof(int64_t));
+        } else if (nested->rta_type == TCA_NETEM_JITTER64 && rta_len(nested) >= static_cast<int>(sizeof(int64_t))) {
+            memcpy(&jitter_ns, rta_data(nested), sizeof(int64_t));
+        }
+        nested = rta_next(nested, nested_len);
+    }
+
+    auto duration = [](int64_t ns) {
+        if (ns % 1000000 == 0) {
+            return std::to_string(ns / 1000000) + "ms";
+        }
+        if (ns % 1000 == 0) {
+            return std::to_string(ns / 1000) + "us";
+        }
+        return std::to_string(ns) + "ns";
+    };
+    // 丢包/重复概率以UINT32_MAX为100%
+    auto percent = [](uint32_t value) {
+        char text[32];
+        snprintf(text, sizeof(text), "%.4g%%", 100.0 * value / UINT32_MAX);
+        return std::string(text);
+    };
+
+    std::string text;
+    auto append = [&text](const std::string& part) {
+        text += (text.empty() ? "" : " ") + part;
+    };
+    if (latency_ns > 0) {
+        append("delay " + duration(latency_ns));
+        if (jitter_ns > 0) {
+            append(duration(jitter_ns));
+        }
+    }
+    if (qopt->loss > 0) {
+        append("loss " + percent(qopt->loss));
+    }
+    if (qopt->duplicate > 0) {
+        append("duplicate " + percent(qopt->duplicate));
+    }
+    if (qopt->gap > 0) {
+        append("gap " + std::to_string(qopt->gap));
+    }
+    result["netem"] = text;
+    result["limit"] = std::to_string(qopt->limit);
+}
+
 std::string NetlinkMessageParser::ip_to_string(const void* addr, int family) {
     char str[INET6_ADDRSTRLEN];
 
@@ -536,14 +1802,7 @@ std::string NetlinkMessageParser::get_interface_name(int ifindex) {
 }
 
 std::string NetlinkMessageParser::get_route_table_name(int table) {
-    switch (table) {
-        case RT_TABLE_UNSPEC: return "unspec";
-        case RT_TABLE_COMPAT: return "compat";
-        case RT_TABLE_DEFAULT: return "default";
-        case RT_TABLE_MAIN: return "main";
-        case RT_TABLE_LOCAL: return "local";
-        default: return std::to_string(table);
-    }
+    return route_table_name(static_cast<uint32_t>(table));
 }
 
 std::string NetlinkMessageParser::get_route_protocol_name(int protocol) {
@@ -553,10 +1812,74 @@ std::string NetlinkMessageParser::get_route_protocol_name(int protocol) {
         case RTPROT_KERNEL: return "kernel";
         case RTPROT_BOOT: return "boot";
         case RTPROT_STATIC: return "static";
+        case RTPROT_GATED: return "gated";
+        case RTPROT_RA: return "ra";
+        case RTPROT_MRT: return "mrt";
+        case RTPROT_ZEBRA: return "zebra";
+        case RTPROT_BIRD: return "bird";
+        case RTPROT_DNROUTED: return "dnrouted";
+        case RTPROT_XORP: return "xorp";
+        case RTPROT_NTK: return "ntk";
+        case RTPROT_DHCP: return "dhcp";
+        case RTPROT_MROUTED: return "mrouted";
+        case RTPROT_KEEPALIVED: return "keepalived";
+        case RTPROT_BABEL: return "babel";
+        case RTPROT_OPENR: return "openr";
+        case RTPROT_BGP: return "bgp";
+        case RTPROT_ISIS: return "isis";
+        case RTPROT_OSPF: return "ospf";
+        case RTPROT_RIP: return "rip";
+        case RTPROT_EIGRP: return "eigrp";
         default: return std::to_string(protocol);
     }
 }
 
+std::string NetlinkMessageParser::get_route_flags_name(unsigned flags) {
+    static const std::pair<unsigned, const char*> names[] = {
+        {RTNH_F_DEAD, "dead"},
+        {RTNH_F_PERVASIVE, "pervasive"},
+        {RTNH_F_ONLINK, "onlink"},
+        {RTNH_F_OFFLOAD, "offload"},
+        {RTNH_F_LINKDOWN, "linkdown"},
+        {RTNH_F_UNRESOLVED, "unresolved"},
+        {RTNH_F_TRAP, "trap"},
+        {RTM_F_NOTIFY, "notify"},
+        {RTM_F_CLONED, "cloned"},
+        {RTM_F_EQUALIZE, "equalize"},
+        {RTM_F_PREFIX, "prefix"},
+        {RTM_F_LOOKUP_TABLE, "lookup_table"},
+        {RTM_F_FIB_MATCH, "fib_match"},
+        {RTM_F_OFFLOAD, "rt_offload"},
+        {RTM_F_TRAP, "rt_trap"},
+        {RTM_F_OFFLOAD_FAILED, "rt_offload_failed"},
+    };
+    std::string text;
+    for (const auto& entry : names) {
+        if (flags & entry.first) {
+            text += (text.empty() ? "" : ",") + std::string(entry.second);
+        }
+    }
+    return text;
+}
+
+std::string NetlinkMessageParser::classify_route_origin(const struct rtmsg* rtm) {
+    if (rtm->rtm_flags & RTM_F_CLONED) {
+        return "cache";
+    }
+    if (rtm->rtm_type == RTN_LOCAL || rtm->rtm_type == RTN_BROADCAST || rtm->rtm_type == RTN_ANYCAST) {
+        return "local";
+    }
+    switch (rtm->rtm_protocol) {
+        // 配置接口地址时内核安装的前缀路由；IPv6的这类路由scope为universe，只能按协议判断
+        case RTPROT_KERNEL: return "connected";
+        case RTPROT_BOOT:
+        case RTPROT_STATIC: return "static";
+        case RTPROT_RA: return "ra";
+        case RTPROT_REDIRECT: return "redirect";
+        default: return "protocol";
+    }
+}
+
 std::string NetlinkMessageParser::get_route_scope_name(int scope) {
     switch (scope) {
         case RT_SCOPE_UNIVERSE: return "universe";
@@ -583,6 +1906,68 @@ std::string NetlinkMessageParser::get_route_type_name(int type) {
     }
 }
 
+std::string NetlinkMessageParser::get_encap_type_name(int encap_type) {
+    switch (encap_type) {
+        case LWTUNNEL_ENCAP_MPLS: return "mpls";
+        case LWTUNNEL_ENCAP_IP: return "ip";
+        case LWTUNNEL_ENCAP_ILA: return "ila";
+        case LWTUNNEL_ENCAP_IP6: return "ip6";
+        case LWTUNNEL_ENCAP_SEG6: return "seg6";
+        case LWTUNNEL_ENCAP_BPF: return "bpf";
+        case LWTUNNEL_ENCAP_SEG6_LOCAL: return "seg6local";
+        case LWTUNNEL_ENCAP_RPL: return "rpl";
+        default: return std::to_string(encap_type);
+    }
+}
+
+// 行为名称与iproute2一致
+std::string NetlinkMessageParser::get_seg6local_action_name(int action) {
+    switch (action) {
+        case SEG6_LOCAL_ACTION_END: return "End";
+        case SEG6_LOCAL_ACTION_END_X: return "End.X";
+        case SEG6_LOCAL_ACTION_END_T: return "End.T";
+        case SEG6_LOCAL_ACTION_END_DX2: return "End.DX2";
+        case SEG6_LOCAL_ACTION_END_DX6: return "End.DX6";
+        case SEG6_LOCAL_ACTION_END_DX4: return "End.DX4";
+        case SEG6_LOCAL_ACTION_END_DT6: return "End.DT6";
+        case SEG6_LOCAL_ACTION_END_DT4: return "End.DT4";
+        case SEG6_LOCAL_ACTION_END_B6: return "End.B6";
+        case SEG6_LOCAL_ACTION_END_B6_ENCAP: return "End.B6.Encaps";
+        case SEG6_LOCAL_ACTION_END_BM: return "End.BM";
+        case SEG6_LOCAL_ACTION_END_S: return "End.S";
+        case SEG6_LOCAL_ACTION_END_AS: return "End.AS";
+        case SEG6_LOCAL_ACTION_END_AM: return "End.AM";
+        case SEG6_LOCAL_ACTION_END_BPF: return "End.BPF";
+        case SEG6_LOCAL_ACTION_END_DT46: return "End.DT46";
+        default: return std::to_string(action);
+    }
+}
+
+std::string NetlinkMessageParser::get_operstate_name(int operstate) {
+    switch (operstate) {
+        case IF_OPER_UNKNOWN: return "unknown";
+        case IF_OPER_NOTPRESENT: return "notpresent";
+        case IF_OPER_DOWN: return "down";
+        case IF_OPER_LOWERLAYERDOWN: return "lowerlayerdown";
+        case IF_OPER_TESTING: return "testing";
+        case IF_OPER_DORMANT: return "dormant";
+        case IF_OPER_UP: return "up";
+        default: return std::to_string(operstate);
+    }
+}
+
+std::string NetlinkMessageParser::get_rule_action_name(int action) {
+    switch (action) {
+        case FR_ACT_TO_TBL: return "lookup";
+        case FR_ACT_GOTO: return "goto";
+        case FR_ACT_NOP: return "nop";
+        case FR_ACT_BLACKHOLE: return "blackhole";
+        case FR_ACT_UNREACHABLE: return "unreachable";
+        case FR_ACT_PROHIBIT: return "prohibit";
+        default: return std::to_string(action);
+    }
+}
+
 // RTA遍历辅助函数
 const struct rtattr* NetlinkMessageParser::rta_next(const struct rtattr* rta, int& len) {
     int rta_len = RTA_ALIGN(rta->rta_len);
@@ -3,7 +3,11 @@
 #include <functional>
 #include <thread>
 #include <atomic>
+#include <condition_variable>
+#include <deque>
+#include <map>
 #include <memory>
+#include <mutex>
 #include <vector>
 #include <unordered_map>
 #include <string>
@@ -12,6 +16,8 @@
 // Linux netlink headers
 #include <linux/netlink.h>
 #include <linux/rtnetlink.h>
+#include <linux/fib_rules.h>
+#include <linux/neighbour.h>
 #include <linux/pkt_sched.h>
 #include <sys/socket.h>
 #include <unistd.h>
@@ -23,20 +29,73 @@ class ConvergenceMonitor;
 enum class NetlinkMessageType {
     ROUTE_ADD,
     ROUTE_DEL,
+    ROUTE_REPLACE,  // RTM_NEWROUTE带NLM_F_REPLACE：已有路由被替换(如下一跳改指)，而非新增可达性
+    RULE_ADD,       // 策略路由规则(ip rule)，与路由事件走同一回调
+    RULE_DEL,
+    MROUTE_ADD,     // 组播转发缓存(MFC)表项，rtm_family为RTNL_FAMILY_IPMR/IP6MR，需set_mroute_monitoring开启
+    MROUTE_DEL,
+    FDB_ADD,        // 网桥/VXLAN转发表项(RTM_NEWNEIGH且ndm_family为AF_BRIDGE)，需set_fdb_monitoring开启
+    FDB_DEL,
+    LINK_ADD,       // 接口创建(RTM_NEWLINK且ifi_change为全1)，需set_link_monitoring开启
+    LINK_CHANGE,    // 接口属性或状态变化，由回调方与上一次状态比较得出具体变化
+    LINK_DEL,
     QDISC_ADD,
     QDISC_DEL,
     QDISC_GET,
-    QDISC_CHANGE,
+    QDISC_CHANGE,   // RTM_NEWQDISC未带NLM_F_REPLACE：原有qdisc的参数被修改(tc qdisc change)
+    QDISC_REPLACE,  // 同一条通知中先删除用户qdisc再添加新qdisc(tc qdisc replace更换了qdisc)
     UNKNOWN
 };
 
+// 已由工作线程解析的路由/qdisc事件
+struct NetlinkEvent {
+    const struct nlmsghdr* nlh;  // 原始消息，仅在回调期间有效
+    std::string type;            // route_add/route_del/route_replace/rule_add/mroute_add/fdb_add/QDISC_ADD等
+    int64_t received_ms;         // recv时间(毫秒时间戳)，队列积压时早于回调时间
+    std::unordered_map<std::string, std::string> info;  // parse_route_message/parse_qdisc_message的结果
+};
+
 // Netlink事件回调函数类型
-using RouteEventCallback = std::function<void(const void*, const std::string&)>;
-using QdiscEventCallback = std::function<void(const void*, const std::string&)>;
+using RouteEventCallback = std::function<void(const NetlinkEvent&)>;
+using QdiscEventCallback = std::function<void(const NetlinkEvent&)>;
+using LinkEventCallback = std::function<void(const NetlinkEvent&)>;
 
 // 统一的netlink事件回调函数类型
 using NetlinkEventCallback = std::function<void(const void*, const std::string&, NetlinkMessageType)>;
 
+// 原始消息回调：收到的每条netlink消息(包括随后被忽略的)在分发前调用，用于调试通道
+using NetlinkRawCallback = std::function<void(const struct nlmsghdr*)>;
+
+// 订阅重建回调：reason为重建原因(recv_error/closed/unhealthy)，detail为错误描述
+using NetlinkRestartCallback = std::function<void(const std::string&, const std::string&)>;
+
+// 错误回调：severity为warning/error/critical，message为错误描述；错误同时输出到stderr
+using NetlinkErrorCallback = std::function<void(const std::string&, const std::string&)>;
+
+// 接收队列统计：received为收到的消息数，dropped为队列满时丢弃的消息数，
+// overruns为内核接收缓冲区溢出(ENOBUFS)的次数，每次溢出丢失的消息数未知
+struct NetlinkQueueStats {
+    int64_t received = 0;
+    int64_t dropped = 0;
+    int64_t overruns = 0;
+    int64_t backlog = 0;
+    int64_t max_backlog = 0;
+    int64_t capacity = 0;
+};
+
+// 订阅健康状态(--health-listen)：读取线程是否仍在循环、套接字是否仍订阅着所需的多播组，
+// 以及路由/qdisc两类消息最近一次收到的时间
+struct NetlinkHealth {
+    bool running = false;
+    bool subscribed = false;          // 套接字已打开；重建失败、等待重试时为false
+    std::string problem;              // subscribed为false时的原因
+    int64_t loop_age_ms = -1;         // 读取线程距上次完成一个epoll周期的时长，正常不超过1秒
+    int64_t last_route_message_ms = 0;  // 毫秒时间戳，0表示启动后尚未收到
+    int64_t last_tc_message_ms = 0;
+    bool tc_monitoring = false;
+    int64_t restart_count = 0;
+};
+
 // Netlink监控器类
 class NetlinkMonitor {
 private:
@@ -47,38 +106,98 @@ private:
     // 用于优雅关闭的管道
     int shutdown_pipe_[2];
 
-    // 线程管理
+    // 线程管理：读取线程只负责recv并入队，避免处理慢时内核接收缓冲区溢出；
+    // 工作线程并行解析消息，解析结果按接收顺序交付给回调(同一时刻只有一个线程在交付)
     std::atomic<bool> running_{false};
     std::thread monitor_thread_;
+    std::vector<std::thread> worker_threads_;
+    size_t worker_count_ = DEFAULT_WORKER_COUNT;
+    bool mroute_monitoring_ = false;
+    bool fdb_monitoring_ = false;
+    bool link_monitoring_ = false;
+    bool tc_monitoring_ = true;
+
+    struct QueuedMessage {
+        uint64_t seq;
+        int64_t received_ms;
+        std::vector<char> data;
+        std::vector<char> replaced;  // QDISC_REPLACE时被替换的旧qdisc的RTM_DELQDISC消息
+    };
+    struct ParsedMessage {
+        QueuedMessage message;
+        NetlinkMessageType type = NetlinkMessageType::UNKNOWN;
+        bool notify = false;  // 是否调用路由/qdisc回调(noqueue等qdisc消息只交给原始/统一回调)
+        std::unordered_map<std::string, std::string> info;
+    };
+
+    // 以下成员受queue_mutex_保护。积压=待解析+解析中+等待按序交付，超过容量时丢弃新消息并计数
+    std::deque<QueuedMessage> queue_;
+    std::map<uint64_t, ParsedMessage> ready_;
+    size_t in_flight_ = 0;
+    uint64_t next_seq_ = 0;
+    uint64_t next_deliver_seq_ = 0;
+    bool delivering_ = false;
+    bool reader_done_ = false;  // 读取线程退出后工作线程处理完剩余消息再退出
+    NetlinkQueueStats queue_stats_;
+    std::mutex queue_mutex_;
+    std::condition_variable queue_cv_;
+    size_t queue_capacity_ = DEFAULT_QUEUE_CAPACITY;
 
     // 事件回调
     RouteEventCallback route_callback_;
     QdiscEventCallback qdisc_callback_;
+    LinkEventCallback link_callback_;
     NetlinkEventCallback unified_callback_;
+    NetlinkRawCallback raw_callback_;
+    NetlinkRestartCallback restart_callback_;
+    NetlinkErrorCallback error_callback_;
+
+    // 订阅看门狗：套接字出错或被关闭时重新订阅；长时间没有消息时检查套接字状态
+    std::atomic<int64_t> restart_count_{0};
+    std::string pending_restart_reason_;  // 重建失败时保留原因，下个周期重试
+    std::string pending_restart_detail_;
+
+    // 健康状态：读取线程每个epoll周期刷新心跳(单调时钟)，收到消息时按类别记录接收时间
+    std::atomic<int64_t> loop_heartbeat_ms_{0};
+    std::atomic<int64_t> last_route_message_ms_{0};
+    std::atomic<int64_t> last_tc_message_ms_{0};
+    mutable std::mutex health_mutex_;
+    std::string subscription_problem_;  // 受health_mutex_保护，订阅正常时为空
 
     // 缓冲区大小
     static constexpr size_t NETLINK_BUFFER_SIZE = 8192;
     static constexpr int MAX_EPOLL_EVENTS = 10;
+    static constexpr int64_t WATCHDOG_IDLE_MS = 5000;
 
     // 内部方法
     int create_unified_netlink_socket();
+    uint32_t subscribed_groups() const;
     void unified_monitor_loop();
+    void worker_loop();
+    void enqueue_message(const struct nlmsghdr* nlh, int64_t received_ms,
+                         const struct nlmsghdr* replaced = nullptr);
+    // del与add是否为同一次qdisc替换：同一接口、同一父节点，且被删除的是用户创建的qdisc(句柄非0)
+    static bool is_qdisc_replacement(const struct nlmsghdr* del, const struct nlmsghdr* add);
+    size_t backlog_locked() const { return queue_.size() + in_flight_ + ready_.size(); }
+
+    // 关闭旧套接字并重新创建、加入epoll，成功后调用restart_callback_
+    bool resubscribe(const std::string& reason, const std::string& detail);
+    // 检查套接字是否仍然有效并绑定到所需的多播组，正常时返回空字符串
+    std::string check_subscription_health();
     
-    void process_netlink_message(const struct nlmsghdr* nlh);
-    
-    NetlinkMessageType get_message_type(const struct nlmsghdr* nlh);
-    std::string message_type_to_string(NetlinkMessageType type);
-    
-    // 路由消息处理
-    void handle_route_message(const struct nlmsghdr* nlh);
-    
-    // QDisc消息处理  
-    void handle_qdisc_message(const struct nlmsghdr* nlh);
+    // 工作线程中调用，可并行
+    ParsedMessage parse_message(QueuedMessage message);
+    // 按接收顺序调用
+    void deliver_message(ParsedMessage parsed);
     
     // 错误处理
     void handle_netlink_error(const struct nlmsghdr* nlh);
+    void report_error(const std::string& severity, const std::string& message);
 
 public:
+    static constexpr size_t DEFAULT_QUEUE_CAPACITY = 4096;
+    static constexpr size_t DEFAULT_WORKER_COUNT = 2;
+
     NetlinkMonitor();
     ~NetlinkMonitor();
     
@@ -91,7 +210,23 @@ public:
     // 设置事件回调
     void set_route_callback(RouteEventCallback callback);
     void set_qdisc_callback(QdiscEventCallback callback);
+    void set_link_callback(LinkEventCallback callback);
     void set_unified_callback(NetlinkEventCallback callback);
+    void set_raw_callback(NetlinkRawCallback callback);
+    void set_restart_callback(NetlinkRestartCallback callback);
+    void set_error_callback(NetlinkErrorCallback callback);
+
+    // 接收队列容量(消息条数)与解析线程数，需在start_monitoring之前设置
+    void set_queue_capacity(size_t capacity);
+    void set_worker_count(size_t count);
+    // 同时订阅IPv4/IPv6组播路由(MFC)变化，需在start_monitoring之前设置
+    void set_mroute_monitoring(bool enabled);
+    // 同时订阅邻居组，只处理其中的网桥/VXLAN转发表(FDB)变化，需在start_monitoring之前设置
+    void set_fdb_monitoring(bool enabled);
+    // 同时订阅接口变化(RTM_NEWLINK/RTM_DELLINK)，交给link回调，需在start_monitoring之前设置
+    void set_link_monitoring(bool enabled);
+    // 订阅qdisc变化(默认开启)；关闭时只监控路由(--route-only)，需在start_monitoring之前设置
+    void set_tc_monitoring(bool enabled);
     
     // 启动和停止监控
     bool start_monitoring();
@@ -100,6 +235,15 @@ public:
 
     // 检查是否正在运行
     bool is_running() const { return running_.load(); }
+    int64_t restart_count() const { return restart_count_.load(); }
+    NetlinkQueueStats queue_stats();
+    size_t worker_count() const { return worker_count_; }
+    // 可在任意线程调用
+    NetlinkHealth health() const;
+
+    // 按消息类型、标志与地址族分类，route_replace与route_add靠NLM_F_REPLACE区分
+    static NetlinkMessageType get_message_type(const struct nlmsghdr* nlh);
+    static std::string message_type_to_string(NetlinkMessageType type);
 };
 
 // Netlink消息解析辅助类
@@ -115,13 +259,44 @@ public:
                                                                            const struct rtattr* rta, 
                                                                            int len);
     
-    // 解析路由属性
+    // 解析组播转发缓存消息：源、组、入接口与出接口列表
+    static std::unordered_map<std::string, std::string> parse_mroute_message(const struct rtmsg* rtm,
+                                                                            const struct rtattr* rta,
+                                                                            int len);
+
+    // 解析网桥/VXLAN转发表消息：MAC、端口、VLAN/VNI与远端VTEP
+    static std::unordered_map<std::string, std::string> parse_fdb_message(const struct ndmsg* ndm,
+                                                                         const struct rtattr* rta,
+                                                                         int len);
+
+    // 解析接口消息：名称、类型、管理/运行状态、MTU、所属master，以及隧道两端地址
+    static std::unordered_map<std::string, std::string> parse_link_message(const struct ifinfomsg* ifi,
+                                                                          const struct rtattr* rta,
+                                                                          int len);
+
+    // 解析策略路由规则消息(RTM_NEWRULE/RTM_DELRULE)
+    static std::unordered_map<std::string, std::string> parse_rule_message(const struct fib_rule_hdr* frh,
+                                                                          const struct rtattr* rta,
+                                                                          int len);
+
+    // 将任意netlink消息描述为字段表：消息头、路由/qdisc的解码结果和截断的十六进制内容
+    static std::unordered_map<std::string, std::string> describe_message(const struct nlmsghdr* nlh);
+
+    // 解析路由属性：目的/源前缀、网关、出接口、首选源地址、metric、32位表ID、RTA_METRICS与封装
     static void parse_route_attributes(const struct rtattr* rta, int len, 
                                      std::unordered_map<std::string, std::string>& result);
     
+    // 解析轻量隧道封装(RTA_ENCAP)：seg6的SID列表与模式、seg6local的行为与参数
+    static void parse_route_encap(int encap_type, const struct rtattr* encap,
+                                  std::unordered_map<std::string, std::string>& result);
+
     // 解析QDisc属性
     static void parse_qdisc_attributes(const struct rtattr* rta, int len, 
                                      std::unordered_map<std::string, std::string>& result);
+
+    // 解析netem的TCA_OPTIONS，netem字段为tc风格的参数描述，如 "delay 50ms 5ms loss 10%"(时延 抖动)
+    static void parse_netem_options(const struct rtattr* options,
+                                    std::unordered_map<std::string, std::string>& result);
     
     // 辅助函数
     static std::string ip_to_string(const void* addr, int family);
@@ -130,6 +305,15 @@ public:
     static std::string get_route_protocol_name(int protocol);
     static std::string get_route_scope_name(int scope);
     static std::string get_route_type_name(int type);
+    // rtm_flags中的RTM_F_*与RTNH_F_*标志，逗号分隔，没有标志时为空
+    static std::string get_route_flags_name(unsigned flags);
+    // 路由来源：connected(内核为接口地址安装)、local(本机/广播地址)、static(boot/static)、
+    // ra、redirect、cache(内核克隆)，其余路由协议安装的为protocol
+    static std::string classify_route_origin(const struct rtmsg* rtm);
+    static std::string get_rule_action_name(int action);
+    static std::string get_encap_type_name(int encap_type);
+    static std::string get_seg6local_action_name(int action);
+    static std::string get_operstate_name(int operstate);
     
 private:
     // RTA遍历宏的C++版本
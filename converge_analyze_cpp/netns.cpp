@@ -0,0 +1,246 @@
+#include "netns.h"
+#include "cli_utils.h"
+#include <algorithm>
+#include <atomic>
+#include <cerrno>
+#include <csignal>
+#include <cstring>
+#include <dirent.h>
+#include <fcntl.h>
+#include <iostream>
+#include <poll.h>
+#include <sched.h>
+#include <sys/stat.h>
+#include <sys/wait.h>
+#include <unistd.h>
+
+namespace {
+
+constexpr const char* NETNS_RUN_DIR = "/var/run/netns";
+
+std::atomic<bool> status_requested{false};
+std::atomic<bool> reload_requested{false};
+
+void status_signal_handler(int) {
+    status_requested.store(true);
+}
+
+void reload_signal_handler(int) {
+    reload_requested.store(true);
+}
+
+struct ChildMonitor {
+    NetnsTarget target;
+    pid_t pid = -1;
+    int output_fd = -1;
+    std::string pending;  // 尚未遇到换行的输出
+};
+
+void flush_lines(ChildMonitor& child, bool final) {
+    size_t start = 0;
+    size_t newline;
+    while ((newline = child.pending.find('\n', start)) != std::string::npos) {
+        std::cout << "[" << child.target.name << "] " << child.pending.substr(start, newline - start) << "\n";
+        start = newline + 1;
+    }
+    child.pending.erase(0, start);
+    if (final && !child.pending.empty()) {
+        std::cout << "[" << child.target.name << "] " << child.pending << "\n";
+        child.pending.clear();
+    }
+    std::cout.flush();
+}
+
+} // namespace
+
+std::string resolve_netns_path(const std::string& name_or_path) {
+    if (name_or_path.find('/') != std::string::npos) {
+        return name_or_path;
+    }
+    return std::string(NETNS_RUN_DIR) + "/" + name_or_path;
+}
+
+bool enter_netns(const std::string& name_or_path, std::string& error) {
+    std::string path = resolve_netns_path(name_or_path);
+    int fd = open(path.c_str(), O_RDONLY | O_CLOEXEC);
+    if (fd < 0) {
+        error = "open " + path + ": " + strerror(errno);
+        return false;
+    }
+    if (setns(fd, CLONE_NEWNET) < 0) {
+        error = "setns " + path + ": " + strerror(errno);
+        close(fd);
+        return false;
+    }
+    close(fd);
+    return true;
+}
+
+std::vector<NetnsTarget> list_named_netns(const std::string& prefix) {
+    std::vector<NetnsTarget> targets;
+    DIR* dir = opendir(NETNS_RUN_DIR);
+    if (dir == nullptr) {
+        return targets;
+    }
+    while (struct dirent* entry = readdir(dir)) {
+        std::string name = entry->d_name;
+        if (name == "." || name == "..") {
+            continue;
+        }
+        if (!prefix.empty() && name.compare(0, prefix.size(), prefix) != 0) {
+            continue;
+        }
+        targets.push_back({name, std::string(NETNS_RUN_DIR) + "/" + name});
+    }
+    closedir(dir);
+
+    std::sort(targets.begin(), targets.end(),
+              [](const NetnsTarget& a, const NetnsTarget& b) { return a.name < b.name; });
+    return targets;
+}
+
+int run_per_netns_monitors(const std::vector<NetnsTarget>& targets,
+                           const std::vector<std::string>& forwarded_args,
+                           const std::string& log_dir) {
+    if (targets.empty()) {
+        std::cerr << "❌ 没有可监控的网络命名空间\n";
+        return 1;
+    }
+
+    mkdir(log_dir.c_str(), 0755);
+    install_stop_signal_handlers();
+
+    // 各子进程不能共用同一个控制套接字，改为在路径后追加.名称
+    std::string control_socket;
+    for (size_t i = 0; i < forwarded_args.size(); ++i) {
+        if (forwarded_args[i] == "--control-socket" && i + 1 < forwarded_args.size()) {
+            control_socket = forwarded_args[i + 1];
+        } else if (forwarded_args[i].rfind("--control-socket=", 0) == 0) {
+            control_socket = forwarded_args[i].substr(strlen("--control-socket="));
+        }
+    }
+
+    std::vector<ChildMonitor> children;
+    for (const auto& target : targets) {
+        std::vector<std::string> args = {"/proc/self/exe"};
+        args.insert(args.end(), forwarded_args.begin(), forwarded_args.end());
+        args.push_back("--netns");
+        args.push_back(target.path);
+        args.push_back("--router-name");
+        args.push_back(target.name);
+        args.push_back("--log-path");
+        args.push_back(log_dir + "/" + target.name + ".json");
+        if (!control_socket.empty()) {
+            args.push_back("--control-socket");
+            args.push_back(control_socket + "." + target.name);
+        }
+
+        int pipe_fds[2];
+        if (pipe(pipe_fds) < 0) {
+            std::cerr << "❌ pipe: " << strerror(errno) << "\n";
+            continue;
+        }
+
+        pid_t pid = fork();
+        if (pid < 0) {
+            std::cerr << "❌ fork: " << strerror(errno) << "\n";
+            close(pipe_fds[0]);
+            close(pipe_fds[1]);
+            continue;
+        }
+        if (pid == 0) {
+            dup2(pipe_fds[1], STDOUT_FILENO);
+            dup2(pipe_fds[1], STDERR_FILENO);
+            close(pipe_fds[0]);
+            close(pipe_fds[1]);
+            std::vector<char*> argv;
+            for (auto& arg : args) {
+                argv.push_back(const_cast<char*>(arg.c_str()));
+            }
+            argv.push_back(nullptr);
+            execv(argv[0], argv.data());
+            _exit(127);
+        }
+
+        close(pipe_fds[1]);
+        ChildMonitor child;
+        child.target = target;
+        child.pid = pid;
+        child.output_fd = pipe_fds[0];
+        children.push_back(std::move(child));
+        std::cout << "🚀 已启动监控器 " << target.name << " (pid " << pid << ", netns " << target.path << ")\n";
+    }
+
+    // SIGUSR1/SIGHUP转发给所有子进程，各自输出状态报告或重新加载配置
+    signal(SIGUSR1, status_signal_handler);
+    signal(SIGHUP, reload_signal_handler);
+
+    bool stop_forwarded = false;
+    size_t open_outputs = children.size();
+    while (open_outputs > 0) {
+        if (stop_requested() && !stop_forwarded) {
+            // 终端Ctrl+C会同时送达子进程，这里保证SIGTERM等信号也能传递
+            for (const auto& child : children) {
+                kill(child.pid, SIGTERM);
+            }
+            stop_forwarded = true;
+        }
+        if (status_requested.exchange(false)) {
+            for (const auto& child : children) {
+                kill(child.pid, SIGUSR1);
+            }
+        }
+        if (reload_requested.exchange(false)) {
+            for (const auto& child : children) {
+                kill(child.pid, SIGHUP);
+            }
+        }
+
+        std::vector<struct pollfd> fds;
+        std::vector<ChildMonitor*> owners;
+        for (auto& child : children) {
+            if (child.output_fd >= 0) {
+                fds.push_back({child.output_fd, POLLIN, 0});
+                owners.push_back(&child);
+            }
+        }
+
+        int ready = poll(fds.data(), fds.size(), 200);
+        if (ready < 0) {
+            if (errno == EINTR) continue;
+            break;
+        }
+
+        for (size_t i = 0; i < fds.size(); ++i) {
+            if (!(fds[i].revents & (POLLIN | POLLHUP | POLLERR))) {
+                continue;
+            }
+            ChildMonitor& child = *owners[i];
+            char buffer[4096];
+            ssize_t len = read(child.output_fd, buffer, sizeof(buffer));
+            if (len > 0) {
+                child.pending.append(buffer, static_cast<size_t>(len));
+                flush_lines(child, false);
+            } else if (len == 0 || errno != EINTR) {
+                flush_lines(child, true);
+                close(child.output_fd);
+                child.output_fd = -1;
+                open_outputs--;
+            }
+        }
+    }
+
+    int exit_code = 0;
+    for (const auto& child : children) {
+        int status = 0;
+        while (waitpid(child.pid, &status, 0) < 0 && errno == EINTR) {
+        }
+        int code = WIFEXITED(status) ? WEXITSTATUS(status) : 1;
+        std::cout << "🏁 监控器 " << child.target.name << " 退出, 退出码 " << code << "\n";
+        exit_code = std::max(exit_code, code);
+    }
+    if (children.size() != targets.size()) {
+        exit_code = std::max(exit_code, 1);
+    }
+    return exit_code;
+}
@@ -0,0 +1,26 @@
+#pragma once
+
+#include <string>
+#include <vector>
+
+// 监控目标网络命名空间
+struct NetnsTarget {
+    std::string name;  // 用作router_name与日志文件名
+    std::string path;  // netns文件，如 /var/run/netns/NAME 或 /proc/PID/ns/net
+};
+
+// 名称含'/'时视为路径，否则解析为 /var/run/netns/NAME
+std::string resolve_netns_path(const std::string& name_or_path);
+
+// 将当前线程切换到指定网络命名空间，必须在创建任何netlink套接字/线程之前调用
+bool enter_netns(const std::string& name_or_path, std::string& error);
+
+// 列出 /var/run/netns 下以prefix开头的命名空间(ip netns / containerlab创建)
+std::vector<NetnsTarget> list_named_netns(const std::string& prefix);
+
+// 每个命名空间以子进程方式运行一个监控器(重新执行当前程序并追加
+// --netns/--router-name/--log-path，指定了--control-socket时追加PATH.名称)，子进程输出按行加上[名称]前缀
+// 返回各子进程中最大的退出码
+int run_per_netns_monitors(const std::vector<NetnsTarget>& targets,
+                           const std::vector<std::string>& forwarded_args,
+                           const std::string& log_dir);
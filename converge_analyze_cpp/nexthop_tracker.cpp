@@ -0,0 +1,187 @@
+#include "nexthop_tracker.h"
+#include "convergence_monitor.h"
+#include "netlink_monitor.h"
+#include "route_table_sampler.h"
+#include <sys/socket.h>
+
+namespace {
+
+std::string field(const NexthopTracker::RouteInfo& info, const char* name) {
+    auto it = info.find(name);
+    return it != info.end() ? it->second : std::string();
+}
+
+} // namespace
+
+bool NexthopTracker::trackable(const RouteInfo& info) {
+    std::string family = field(info, "family");
+    if (family != std::to_string(AF_INET) && family != std::to_string(AF_INET6)) {
+        return false;
+    }
+    std::string origin = field(info, "origin");
+    return origin != "local" && origin != "cache";
+}
+
+std::string NexthopTracker::route_key(const RouteInfo& info) {
+    return field(info, "family") + "|" + field(info, "table") + "|" + field(info, "dst") + "/" +
+           field(info, "dst_len") + "|" + field(info, "src") + "/" + field(info, "src_len") + "|" +
+           field(info, "priority");
+}
+
+std::string NexthopTracker::nexthop_text(const RouteInfo& info) {
+    std::string route_class = RouteEvent::classify(info);
+    if (!route_class.empty()) {
+        return route_class;
+    }
+    if (!field(info, "nhid").empty()) {
+        return "nhid " + field(info, "nhid");
+    }
+    if (!field(info, "nexthops").empty()) {
+        return field(info, "nexthops");
+    }
+    std::string gateway = field(info, "gateway");
+    return (gateway.empty() || gateway == "N/A" ? "" : gateway + " ") + "dev " + field(info, "interface");
+}
+
+std::string NexthopTracker::seed() {
+    std::string error;
+    std::lock_guard<std::mutex> lock(mutex_);
+    RouteTableSampler::dump_routes([this](const struct rtmsg* rtm, const struct rtattr* rta, int attr_len) {
+        auto info = NetlinkMessageParser::parse_route_message(rtm, rta, attr_len);
+        if (trackable(info)) {
+            Entry entry;
+            entry.nexthop = nexthop_text(info);
+            entry.gateway = field(info, "nexthops").empty() ? field(info, "gateway") : "N/A";
+            insert_locked(route_key(info), std::move(entry));
+        }
+    }, error);
+    return error;
+}
+
+bool NexthopTracker::insert_locked(const std::string& key, Entry entry) {
+    auto it = routes_.find(key);
+    if (it != routes_.end()) {
+        it->second = std::move(entry);
+        return true;
+    }
+    if (routes_.size() >= capacity_) {
+        overflow_++;
+        return false;
+    }
+    routes_.emplace(key, std::move(entry));
+    return true;
+}
+
+void NexthopTracker::expire_locked(int64_t now, int64_t window_ms) {
+    while (!deleted_.empty() && now - deleted_.front().first > window_ms) {
+        auto it = routes_.find(deleted_.front().second);
+        // 删除后又被添加(可能再次删除)的表项不在这里清理
+        if (it != routes_.end() && it->second.deleted_at == deleted_.front().first) {
+            routes_.erase(it);
+        }
+        deleted_.pop_front();
+    }
+}
+
+bool NexthopTracker::update(int64_t timestamp, const std::string& event_type, const RouteInfo& info,
+                            int64_t window_ms, NexthopChange& change) {
+    if (!trackable(info)) {
+        return false;
+    }
+    std::string key = route_key(info);
+    std::string nexthop = nexthop_text(info);
+    std::string gateway = field(info, "nexthops").empty() ? field(info, "gateway") : "N/A";
+
+    std::lock_guard<std::mutex> lock(mutex_);
+    expire_locked(timestamp, window_ms);
+    auto it = routes_.find(key);
+
+    if (event_type == "route_del") {
+        Entry entry;
+        entry.nexthop = nexthop;
+        entry.gateway = gateway;
+        if (it != routes_.end() && it->second.deleted_at < 0) {
+            // 缓存中的下一跳就是被删除的那个
+            entry = it->second;
+        }
+        entry.deleted_at = timestamp;
+        if (insert_locked(key, std::move(entry))) {
+            deleted_.emplace_back(timestamp, key);
+        }
+        return false;
+    }
+    if (event_type != "route_add" && event_type != "route_replace") {
+        return false;
+    }
+
+    Entry entry;
+    entry.nexthop = nexthop;
+    entry.gateway = gateway;
+    if (it == routes_.end()) {
+        insert_locked(key, std::move(entry));
+        return false;
+    }
+
+    Entry previous = it->second;
+    it->second = std::move(entry);
+    if (previous.nexthop == nexthop) {
+        return false;
+    }
+    if (previous.deleted_at >= 0) {
+        if (timestamp - previous.deleted_at > window_ms) {
+            return false;
+        }
+        change.kind = "del_add";
+        change.withdrawn_ms = timestamp - previous.deleted_at;
+    } else {
+        change.kind = "replace";
+        change.withdrawn_ms = 0;
+    }
+    change.family = field(info, "family");
+    change.table = field(info, "table");
+    change.prefix = field(info, "dst") == "default" ? "default" : field(info, "dst") + "/" + field(info, "dst_len");
+    change.old_nexthop = previous.nexthop;
+    change.new_nexthop = nexthop;
+    change.old_gateway = previous.gateway.empty() ? "N/A" : previous.gateway;
+    change.new_gateway = gateway.empty() ? "N/A" : gateway;
+    return true;
+}
+
+size_t NexthopTracker::size() const {
+    std::lock_guard<std::mutex> lock(mutex_);
+    return routes_.size();
+}
+
+int64_t NexthopTracker::overflow() const {
+    std::lock_guard<std::mutex> lock(mutex_);
+    return overflow_;
+}
+
+bool SessionNexthopChanges::record(int64_t offset, int64_t frr_window_ms) {
+    changes++;
+    last_change_offset = offset;
+    // 备份下一跳是预先计算好的，保护路径在触发后几毫秒内就位；SPF重算后的切换明显更晚
+    bool fast_reroute = frr_window_ms > 0 && offset <= frr_window_ms;
+    if (fast_reroute) {
+        frr_changes++;
+        frr_activation_offset = offset;
+    }
+    return fast_reroute;
+}
+
+void SessionNexthopChanges::add_to_json(JsonObject& obj, int64_t frr_window_ms) const {
+    if (changes == 0) {
+        return;
+    }
+    obj["nexthop_changes"] = static_cast<int64_t>(changes);
+    obj["last_nexthop_change_ms"] = last_change_offset.value_or(0);
+    // 快速重路由生效时间与之后SPF重算的切换分开给出，最终收敛时间仍为convergence_time_ms
+    if (frr_window_ms > 0) {
+        obj["frr_activated"] = frr_changes > 0;
+        if (frr_changes > 0) {
+            obj["frr_activation_ms"] = frr_activation_offset.value();
+            obj["frr_nexthop_changes"] = static_cast<int64_t>(frr_changes);
+            obj["spf_nexthop_changes"] = static_cast<int64_t>(changes - frr_changes);
+        }
+    }
+}
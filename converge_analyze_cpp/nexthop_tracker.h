@@ -0,0 +1,80 @@
+#pragma once
+
+#include "logger.h"
+#include <cstdint>
+#include <deque>
+#include <mutex>
+#include <optional>
+#include <string>
+#include <unordered_map>
+#include <utility>
+
+// 一次前缀下一跳切换
+struct NexthopChange {
+    std::string family;
+    std::string table;
+    std::string prefix;        // 目的前缀，如 10.0.0.0/24
+    std::string old_nexthop;   // "网关 dev 接口"，ECMP为逗号分隔的多个下一跳，丢弃类路由为路由类型
+    std::string new_nexthop;
+    std::string old_gateway;   // 单下一跳的网关，直连或ECMP时为N/A
+    std::string new_gateway;
+    std::string kind;          // replace: 一条通知中直接改指；del_add: 先删除再在窗口内重新添加
+    int64_t withdrawn_ms = 0;  // del_add时前缀不可达的时长
+};
+
+// 一个会话内的下一跳切换：次数与最后一次切换的时间(相对触发)；--frr-window-ms时
+// 另计快速重路由窗口内的切换与最后一次窗口内切换的时间
+struct SessionNexthopChanges {
+    int changes = 0;
+    int frr_changes = 0;
+    std::optional<int64_t> frr_activation_offset;
+    std::optional<int64_t> last_change_offset;
+
+    // 记录一次切换，返回是否落在快速重路由窗口内；frr_window_ms为0表示不区分
+    bool record(int64_t offset, int64_t frr_window_ms);
+    // 写入session_completed的nexthop_changes与frr_*字段，没有切换时不写
+    void add_to_json(JsonObject& obj, int64_t frr_window_ms) const;
+};
+
+// 前缀到下一跳的缓存(--nexthop-cache)：内核对下一跳改指要么发一条替换通知，要么拆成删除加添加，
+// 两种情况在事件流里都看不出"同一前缀换了下一跳"。这里保存每个前缀最近的下一跳，
+// 替换或在窗口内删除后重新添加且下一跳不同时得出一次切换
+class NexthopTracker {
+public:
+    using RouteInfo = std::unordered_map<std::string, std::string>;
+
+private:
+    struct Entry {
+        std::string nexthop;
+        std::string gateway;
+        int64_t deleted_at = -1;  // 已删除、等待重新添加时为删除时间
+    };
+
+    std::unordered_map<std::string, Entry> routes_;  // 地址族|表|前缀|源前缀|metric -> 下一跳
+    std::deque<std::pair<int64_t, std::string>> deleted_;  // 按删除时间排列，用于清理超出窗口的表项
+    size_t capacity_;
+    int64_t overflow_ = 0;  // 缓存已满而未记录的前缀数
+    mutable std::mutex mutex_;
+
+    void expire_locked(int64_t now, int64_t window_ms);
+    bool insert_locked(const std::string& key, Entry entry);
+
+public:
+    explicit NexthopTracker(size_t capacity) : capacity_(capacity) {}
+
+    // 用当前的路由表建立基线，已有前缀第一次改指时即可给出原下一跳；失败时返回错误信息
+    std::string seed();
+
+    // 处理一条route_add/route_del/route_replace；下一跳发生切换时填写change并返回true。
+    // window_ms为删除后重新添加仍算作切换的最长间隔
+    bool update(int64_t timestamp, const std::string& event_type, const RouteInfo& info, int64_t window_ms,
+                NexthopChange& change);
+
+    size_t size() const;
+    int64_t overflow() const;
+
+    // 是否参与跟踪：IPv4/IPv6单播、丢弃类路由，不含本机/广播地址与路由缓存
+    static bool trackable(const RouteInfo& info);
+    static std::string route_key(const RouteInfo& info);
+    static std::string nexthop_text(const RouteInfo& info);
+};
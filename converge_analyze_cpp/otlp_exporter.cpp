@@ -0,0 +1,270 @@
+#include "otlp_exporter.h"
+#include "http_client.h"
+#include "log_reader.h"
+#include <iostream>
+#include <sstream>
+
+namespace {
+
+const std::string SERVICE_NAME = "convergence-analyzer";
+
+std::string quote(const std::string& text) {
+    return "\"" + Logger::escape_json_string(text) + "\"";
+}
+
+std::string nanos(int64_t time_ms) {
+    return quote(std::to_string(time_ms) + "000000");
+}
+
+void write_attributes(std::ostringstream& out, const std::unordered_map<std::string, std::string>& attributes) {
+    out << "[";
+    bool first = true;
+    for (const auto& pair : attributes) {
+        if (pair.second.empty()) {
+            continue;
+        }
+        out << (first ? "" : ",") << "{\"key\":" << quote(pair.first)
+            << ",\"value\":{\"stringValue\":" << quote(pair.second) << "}}";
+        first = false;
+    }
+    out << "]";
+}
+
+} // namespace
+
+bool OtlpTraceExporter::parse_url(const std::string& url, std::string& endpoint) {
+    const std::string scheme = "otlp://";
+    if (url.compare(0, scheme.size(), scheme) != 0) {
+        return false;
+    }
+
+    std::string host, port, path;
+    std::string http_url = "http://" + url.substr(scheme.size());
+    if (!HttpClient::parse_url(http_url, host, port, path)) {
+        return false;
+    }
+
+    // 未写端口时使用OTLP/HTTP默认端口
+    std::string authority = url.substr(scheme.size());
+    authority = authority.substr(0, authority.find('/'));
+    bool has_port = authority.front() == '['
+        ? authority.find("]:") != std::string::npos
+        : authority.find(':') != std::string::npos;
+    if (!has_port) {
+        authority += ":4318";
+    }
+    endpoint = "http://" + authority + (path == "/" ? "/v1/traces" : path);
+    return true;
+}
+
+OtlpTraceExporter::OtlpTraceExporter(const std::string& url, const std::vector<std::string>& tag_keys)
+    : tag_keys_(tag_keys), random_(std::random_device{}()) {
+    parse_url(url, endpoint_);
+}
+
+OtlpTraceExporter::~OtlpTraceExporter() {
+    stop();
+}
+
+bool OtlpTraceExporter::start(std::string&) {
+    if (running_.load()) {
+        return true;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&OtlpTraceExporter::worker_loop, this);
+    return true;
+}
+
+void OtlpTraceExporter::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    queue_cv_.notify_all();
+
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+}
+
+std::string OtlpTraceExporter::random_hex(size_t bytes) {
+    static const char* hex = "0123456789abcdef";
+    std::string text;
+    while (text.size() < bytes * 2) {
+        uint64_t value = random_();
+        for (int i = 0; i < 16 && text.size() < bytes * 2; ++i) {
+            text += hex[(value >> (i * 4)) & 0xf];
+        }
+    }
+    return text;
+}
+
+std::string OtlpTraceExporter::summary() const {
+    std::string text = "OTLP: 已导出 " + std::to_string(exported_count_.load()) + " 个trace";
+    if (failed_count_.load() > 0) {
+        text += "，失败 " + std::to_string(failed_count_.load()) + " 个";
+    }
+    return text;
+}
+
+void OtlpTraceExporter::write_record(const JsonObject& record, const std::string&) {
+    std::string event_type = LogReader::get_string(record, "event_type");
+    if (!LogReader::has(record, "session_id")) {
+        return;
+    }
+    int64_t session_id = LogReader::get_int(record, "session_id");
+    int64_t timestamp_ms = LogReader::parse_timestamp_ms(LogReader::get_timestamp(record, "timestamp"));
+
+    std::lock_guard<std::mutex> lock(record_mutex_);
+
+    if (event_type == "session_started") {
+        OtlpSessionTrace trace;
+        trace.trace_id = random_hex(16);
+        trace.root_span_id = random_hex(8);
+        trace.start_time_ms = timestamp_ms;
+        trace.attributes["router.name"] = LogReader::get_string(record, "router_name");
+        trace.attributes["session.id"] = std::to_string(session_id);
+        trace.attributes["trigger.source"] = LogReader::get_string(record, "trigger_source");
+        trace.attributes["trigger.event"] = LogReader::get_string(record, "trigger_event_type");
+        for (const auto& pair : LogReader::parse_string_map(LogReader::get_string(record, "trigger_info"))) {
+            if (pair.second != "N/A") {
+                trace.attributes["trigger." + pair.first] = pair.second;
+            }
+        }
+        for (const auto& key : tag_keys_) {
+            trace.attributes[key] = LogReader::get_string(record, key);
+        }
+        sessions_[session_id] = std::move(trace);
+        return;
+    }
+
+    auto it = sessions_.find(session_id);
+    if (it == sessions_.end()) {
+        return;
+    }
+    OtlpSessionTrace& trace = it->second;
+
+    if (event_type == "session_completed") {
+        std::string payload = build_payload(trace, record);
+        sessions_.erase(it);
+
+        std::unique_lock<std::mutex> queue_lock(queue_mutex_);
+        if (pending_.size() >= MAX_PENDING_TRACES) {
+            pending_.pop();
+            failed_count_.fetch_add(1);
+        }
+        pending_.push(std::move(payload));
+        queue_lock.unlock();
+        queue_cv_.notify_one();
+        return;
+    }
+
+    if (trace.events.size() >= MAX_SPAN_EVENTS) {
+        return;
+    }
+
+    // 会话内的各类事件按触发偏移量定位
+    OtlpSpanEvent event;
+    event.time_ms = trace.start_time_ms + LogReader::get_int(record, "offset_from_trigger_ms");
+    if (event_type == "route_event") {
+        event.name = LogReader::get_string(record, "route_event_type");
+        event.attributes = LogReader::parse_string_map(LogReader::get_string(record, "route_info"));
+    } else if (event_type == "frr_log_event") {
+        event.name = "FRR " + LogReader::get_string(record, "category");
+        event.attributes["daemon"] = LogReader::get_string(record, "daemon");
+        event.attributes["message"] = LogReader::get_string(record, "message");
+    } else if (event_type == "bgp_event") {
+        event.name = "BGP " + LogReader::get_string(record, "bmp_message_type");
+        event.attributes["peer"] = LogReader::get_string(record, "peer_address");
+        event.attributes["announced"] = std::to_string(LogReader::get_int(record, "announced_count"));
+        event.attributes["withdrawn"] = std::to_string(LogReader::get_int(record, "withdrawn_count"));
+    } else if (event_type == "igp_adjacency_event") {
+        event.name = "IGP " + LogReader::get_string(record, "protocol") + " " +
+                     LogReader::get_string(record, "old_state") + "->" + LogReader::get_string(record, "new_state");
+        event.attributes["neighbor"] = LogReader::get_string(record, "neighbor");
+        event.attributes["interface"] = LogReader::get_string(record, "interface");
+    } else {
+        return;
+    }
+    trace.events.push_back(std::move(event));
+}
+
+std::string OtlpTraceExporter::build_payload(const OtlpSessionTrace& trace, const JsonObject& completed) {
+    int64_t start = trace.start_time_ms;
+    int64_t end = start + LogReader::get_int(completed, "session_duration_ms");
+    bool timed_out = LogReader::get_bool(completed, "timed_out");
+    bool forced = LogReader::get_bool(completed, "forced");
+
+    auto root_attributes = trace.attributes;
+    root_attributes["route_events"] = std::to_string(LogReader::get_int(completed, "route_events_count"));
+    root_attributes["timed_out"] = timed_out ? "true" : "false";
+    if (forced) {
+        root_attributes["forced"] = "true";
+    }
+    if (LogReader::has(completed, "convergence_time_ms")) {
+        root_attributes["convergence_ms"] = std::to_string(LogReader::get_int(completed, "convergence_time_ms"));
+    }
+
+    std::ostringstream out;
+    out << "{\"resourceSpans\":[{\"resource\":{\"attributes\":";
+    write_attributes(out, {{"service.name", SERVICE_NAME},
+                           {"host.name", LogReader::get_string(completed, "router_name")}});
+    out << "},\"scopeSpans\":[{\"scope\":{\"name\":" << quote(SERVICE_NAME) << "},\"spans\":[";
+
+    // 根span: 整个会话
+    out << "{\"traceId\":" << quote(trace.trace_id) << ",\"spanId\":" << quote(trace.root_span_id)
+        << ",\"name\":" << quote("convergence session #" + trace.attributes.at("session.id"))
+        << ",\"kind\":1,\"startTimeUnixNano\":" << nanos(start) << ",\"endTimeUnixNano\":" << nanos(end)
+        << ",\"attributes\":";
+    write_attributes(out, root_attributes);
+    out << ",\"events\":[";
+    for (size_t i = 0; i < trace.events.size(); ++i) {
+        const auto& event = trace.events[i];
+        out << (i ? "," : "") << "{\"timeUnixNano\":" << nanos(event.time_ms)
+            << ",\"name\":" << quote(event.name) << ",\"attributes\":";
+        write_attributes(out, event.attributes);
+        out << "}";
+    }
+    // 强制结束的会话没有结果，状态为UNSET
+    out << "],\"status\":{\"code\":" << (timed_out ? 2 : forced ? 0 : 1) << "}}";
+
+    // 子span: 触发到收敛
+    if (LogReader::has(completed, "convergence_time_ms")) {
+        int64_t converged = start + LogReader::get_int(completed, "convergence_time_ms");
+        out << ",{\"traceId\":" << quote(trace.trace_id) << ",\"spanId\":" << quote(random_hex(8))
+            << ",\"parentSpanId\":" << quote(trace.root_span_id)
+            << ",\"name\":\"convergence\",\"kind\":1,\"startTimeUnixNano\":" << nanos(start)
+            << ",\"endTimeUnixNano\":" << nanos(converged) << ",\"attributes\":[],\"status\":{\"code\":1}}";
+    }
+
+    out << "]}]}]}";
+    return out.str();
+}
+
+void OtlpTraceExporter::worker_loop() {
+    std::unique_lock<std::mutex> lock(queue_mutex_);
+
+    while (running_.load() || !pending_.empty()) {
+        queue_cv_.wait(lock, [this] {
+            return !pending_.empty() || !running_.load();
+        });
+
+        while (!pending_.empty()) {
+            std::string payload = std::move(pending_.front());
+            pending_.pop();
+            lock.unlock();
+
+            auto response = HttpClient::post(endpoint_, payload);
+            if (response.ok()) {
+                exported_count_.fetch_add(1);
+            } else {
+                failed_count_.fetch_add(1);
+                std::cerr << "⚠️  OTLP trace导出失败: " << response.error << "\n";
+            }
+
+            lock.lock();
+        }
+    }
+}
@@ -0,0 +1,78 @@
+#pragma once
+
+#include <atomic>
+#include <condition_variable>
+#include <map>
+#include <mutex>
+#include <queue>
+#include <random>
+#include <string>
+#include <thread>
+#include <unordered_map>
+#include <vector>
+#include "record_sink.h"
+
+// 会话内的一个span事件(路由事件、FRR日志、BGP消息等)
+struct OtlpSpanEvent {
+    int64_t time_ms = 0;
+    std::string name;
+    std::unordered_map<std::string, std::string> attributes;
+};
+
+// 进行中的会话，完成时导出为一个trace
+struct OtlpSessionTrace {
+    std::string trace_id;
+    std::string root_span_id;
+    int64_t start_time_ms = 0;
+    std::unordered_map<std::string, std::string> attributes;
+    std::vector<OtlpSpanEvent> events;
+};
+
+// 将每个收敛会话导出为OpenTelemetry trace(OTLP/HTTP JSON)
+// 根span覆盖整个会话，子span "convergence" 覆盖触发到收敛，各事件作为根span的span event
+class OtlpTraceExporter : public RecordSink {
+private:
+    std::string endpoint_;  // http://HOST:4318/v1/traces
+    std::vector<std::string> tag_keys_;
+
+    std::mutex record_mutex_;
+    std::map<int64_t, OtlpSessionTrace> sessions_;  // 受record_mutex_保护
+    std::mt19937_64 random_;                          // 受record_mutex_保护
+
+    std::queue<std::string> pending_;
+    std::mutex queue_mutex_;
+    std::condition_variable queue_cv_;
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+
+    std::atomic<int64_t> exported_count_{0};
+    std::atomic<int64_t> failed_count_{0};
+
+    static constexpr size_t MAX_PENDING_TRACES = 1000;
+    static constexpr size_t MAX_SPAN_EVENTS = 1000;
+
+    void worker_loop();
+    std::string random_hex(size_t bytes);
+    std::string build_payload(const OtlpSessionTrace& trace, const JsonObject& completed);
+
+public:
+    OtlpTraceExporter(const std::string& url, const std::vector<std::string>& tag_keys);
+    ~OtlpTraceExporter() override;
+
+    OtlpTraceExporter(const OtlpTraceExporter&) = delete;
+    OtlpTraceExporter& operator=(const OtlpTraceExporter&) = delete;
+
+    bool start(std::string& error) override;
+    // 停止前会尽量发送完队列中的trace
+    void stop() override;
+
+    // 处理一条日志记录，session_completed时生成trace
+    void write_record(const JsonObject& record, const std::string& line) override;
+    std::string summary() const override;
+
+    int64_t exported_count() const { return exported_count_.load(); }
+    int64_t failed_count() const { return failed_count_.load(); }
+
+    // otlp://HOST[:PORT][/PATH]，默认端口4318、路径/v1/traces
+    static bool parse_url(const std::string& url, std::string& endpoint);
+};
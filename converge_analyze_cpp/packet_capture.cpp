@@ -0,0 +1,156 @@
+#include "packet_capture.h"
+#include "subprocess.h"
+#include <algorithm>
+#include <cerrno>
+#include <chrono>
+#include <csignal>
+#include <sys/wait.h>
+#include <thread>
+#include <unistd.h>
+
+const char* PcapOptions::DEFAULT_FILTER =
+    "tcp port 179 or ip proto 89 or ip6 proto 89 or udp port 3784 or udp port 4784";
+
+SessionCapture::SessionCapture(const PcapOptions& options) : options_(options) {
+}
+
+SessionCapture::~SessionCapture() {
+    stop();
+}
+
+std::vector<std::string> SessionCapture::build_command(const std::string& interface,
+                                                       const std::string& path) const {
+    // -U逐包写出，收敛时即使被强制结束文件也是完整的；-Z root避免降权后无法写入目录
+    std::vector<std::string> args = {options_.tcpdump_path, "-i", interface, "-n", "-U", "-Z", "root",
+                                     "-c", std::to_string(options_.max_packets), "-w", path};
+    args.push_back(options_.filter.empty() ? PcapOptions::DEFAULT_FILTER : options_.filter);
+    return args;
+}
+
+void SessionCapture::start(const std::string& file_prefix) {
+    std::vector<std::string> interfaces = options_.interfaces;
+    if (interfaces.empty()) {
+        interfaces.push_back("any");
+    }
+
+    for (const auto& interface : interfaces) {
+        Process process;
+        process.file.interface = interface;
+        process.file.path = file_prefix + "-" + interface + ".pcap";
+        std::string error;
+        process.pid = spawn_command(build_command(interface, process.file.path), process.output_fd, error);
+        if (process.pid < 0) {
+            process.file.error = error;
+        }
+        processes_.push_back(std::move(process));
+    }
+}
+
+void SessionCapture::drain(Process& process) {
+    if (process.output_fd < 0) {
+        return;
+    }
+    char chunk[4096];
+    ssize_t len;
+    while ((len = read(process.output_fd, chunk, sizeof(chunk))) > 0) {
+        process.output.append(chunk, static_cast<size_t>(len));
+    }
+}
+
+std::vector<PcapFile> SessionCapture::stop() {
+    // 先全部发出SIGTERM，再统一等待，多个接口时总等待时间不叠加
+    for (auto& process : processes_) {
+        if (process.pid > 0) {
+            kill(process.pid, SIGTERM);
+        }
+    }
+
+    auto deadline = std::chrono::steady_clock::now() + std::chrono::seconds(2);
+    for (auto& process : processes_) {
+        if (process.pid <= 0) {
+            continue;
+        }
+        int status = 0;
+        pid_t done;
+        while ((done = waitpid(process.pid, &status, WNOHANG)) == 0 &&
+               std::chrono::steady_clock::now() < deadline) {
+            drain(process);
+            std::this_thread::sleep_for(std::chrono::milliseconds(10));
+        }
+        if (done == 0) {
+            kill(process.pid, SIGKILL);
+            waitpid(process.pid, &status, 0);
+            process.file.error = "tcpdump did not exit, killed";
+        }
+        drain(process);
+        close(process.output_fd);
+        process.output_fd = -1;
+        process.pid = -1;
+
+        parse_summary(process.output, process.file.packets, process.file.dropped);
+        if (WIFEXITED(status) && WEXITSTATUS(status) == 127) {
+            process.file.error = options_.tcpdump_path + ": command not found";
+        } else if (process.file.packets < 0 && process.file.error.empty()) {
+            // 没有统计输出说明tcpdump提前退出(过滤表达式错误、接口不存在等)，保留其最后一行输出
+            std::string output = process.output;
+            while (!output.empty() && (output.back() == '\n' || output.back() == '\r')) {
+                output.pop_back();
+            }
+            size_t newline = output.rfind('\n');
+            process.file.error = newline == std::string::npos ? output : output.substr(newline + 1);
+            if (process.file.error.empty()) {
+                process.file.error = "tcpdump exited without statistics";
+            }
+        }
+    }
+
+    std::vector<PcapFile> files;
+    for (const auto& process : processes_) {
+        files.push_back(process.file);
+    }
+    return files;
+}
+
+void SessionCapture::parse_summary(const std::string& output, int64_t& packets, int64_t& dropped) {
+    auto number_before = [&output](const std::string& suffix, int64_t& value) {
+        size_t pos = output.find(suffix);
+        if (pos == std::string::npos) {
+            return;
+        }
+        size_t start = output.rfind('\n', pos);
+        start = start == std::string::npos ? 0 : start + 1;
+        try {
+            value = std::stoll(output.substr(start, pos - start));
+        } catch (const std::exception&) {
+        }
+    };
+    number_before(" packets captured", packets);
+    number_before(" packets dropped by kernel", dropped);
+    // 只有一个包时tcpdump写作单数
+    if (packets < 0) {
+        number_before(" packet captured", packets);
+    }
+    if (dropped < 0) {
+        number_before(" packet dropped by kernel", dropped);
+    }
+}
+
+void SessionCapture::add_to_json(JsonObject& obj, const std::vector<PcapFile>& files) {
+    std::string paths, errors;
+    int64_t packets = 0, dropped = 0;
+    for (const auto& file : files) {
+        if (!file.error.empty()) {
+            errors += (errors.empty() ? "" : "; ") + file.interface + ": " + file.error;
+            continue;
+        }
+        paths += (paths.empty() ? "" : ",") + file.path;
+        packets += std::max<int64_t>(0, file.packets);
+        dropped += std::max<int64_t>(0, file.dropped);
+    }
+    obj["pcap_files"] = paths;
+    obj["pcap_packets"] = packets;
+    obj["pcap_dropped"] = dropped;
+    if (!errors.empty()) {
+        obj["pcap_error"] = errors;
+    }
+}
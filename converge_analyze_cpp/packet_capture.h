@@ -0,0 +1,67 @@
+#pragma once
+
+#include "logger.h"
+#include <cstdint>
+#include <string>
+#include <sys/types.h>
+#include <vector>
+
+// 会话抓包参数(--pcap-*)
+struct PcapOptions {
+    std::string directory;                // pcap文件目录，为空表示不启用
+    std::vector<std::string> interfaces;  // 抓包接口，为空时为any
+    std::string filter;                   // BPF过滤表达式，为空时使用DEFAULT_FILTER
+    int64_t max_packets = 100000;         // 每个文件的包数上限(tcpdump -c)
+    std::string tcpdump_path = "tcpdump";
+
+    // BGP、OSPF与BFD(单跳3784/多跳4784)
+    static const char* DEFAULT_FILTER;
+};
+
+// 一个接口上的抓包结果
+struct PcapFile {
+    std::string interface;
+    std::string path;
+    int64_t packets = -1;  // tcpdump退出时报告的包数，未知为-1
+    int64_t dropped = -1;  // 内核丢弃的包数
+    std::string error;     // tcpdump未能正常抓包时的输出
+};
+
+// 一个会话的抓包：触发时为每个接口启动一个tcpdump，收敛时结束。
+// 抓包从触发之后开始，触发前的报文(如BFD Down之前的最后几个包)不在其中
+class SessionCapture {
+private:
+    struct Process {
+        PcapFile file;
+        pid_t pid = -1;
+        int output_fd = -1;
+        std::string output;
+    };
+
+    PcapOptions options_;
+    std::vector<Process> processes_;
+
+    static void drain(Process& process);
+
+public:
+    explicit SessionCapture(const PcapOptions& options);
+    ~SessionCapture();
+
+    SessionCapture(const SessionCapture&) = delete;
+    SessionCapture& operator=(const SessionCapture&) = delete;
+
+    // 以file_prefix为文件名前缀启动抓包，如 "<目录>/r1-20240101-120000-session3"；
+    // 部分接口启动失败时其余接口照常抓包，失败的在结果中带error
+    void start(const std::string& file_prefix);
+
+    // 结束所有tcpdump并返回各文件的结果，可重复调用
+    std::vector<PcapFile> stop();
+
+    std::vector<std::string> build_command(const std::string& interface, const std::string& path) const;
+
+    // 解析tcpdump退出时的统计("N packets captured"、"N packets dropped by kernel")
+    static void parse_summary(const std::string& output, int64_t& packets, int64_t& dropped);
+
+    // 写入session_completed的pcap_*字段，多个接口的文件以逗号分隔
+    static void add_to_json(JsonObject& obj, const std::vector<PcapFile>& files);
+};
@@ -0,0 +1,421 @@
+#include "parquet_exporter.h"
+#include "log_reader.h"
+#include <fstream>
+#include <sys/stat.h>
+
+namespace {
+
+// Thrift compact protocol编码，只实现FileMetaData/PageHeader用到的部分
+class ThriftWriter {
+public:
+    enum : uint8_t { I32 = 5, I64 = 6, BINARY = 8, LIST = 9, STRUCT = 12 };
+
+    std::string out;
+
+    void varint(uint64_t value) {
+        while (value >= 0x80) {
+            out += static_cast<char>((value & 0x7F) | 0x80);
+            value >>= 7;
+        }
+        out += static_cast<char>(value);
+    }
+
+    void zigzag(int64_t value) {
+        varint((static_cast<uint64_t>(value) << 1) ^ static_cast<uint64_t>(value >> 63));
+    }
+
+    void field(int16_t id, uint8_t type) {
+        int delta = id - last_ids_.back();
+        if (delta > 0 && delta <= 15) {
+            out += static_cast<char>((delta << 4) | type);
+        } else {
+            out += static_cast<char>(type);
+            zigzag(id);
+        }
+        last_ids_.back() = id;
+    }
+
+    void i32(int16_t id, int32_t value) { field(id, I32); zigzag(value); }
+    void i64(int16_t id, int64_t value) { field(id, I64); zigzag(value); }
+    void string(int16_t id, const std::string& value) { field(id, BINARY); raw_string(value); }
+
+    void raw_string(const std::string& value) {
+        varint(value.size());
+        out += value;
+    }
+
+    void list(int16_t id, uint8_t element_type, size_t size) {
+        field(id, LIST);
+        if (size < 15) {
+            out += static_cast<char>((size << 4) | element_type);
+        } else {
+            out += static_cast<char>(0xF0 | element_type);
+            varint(size);
+        }
+    }
+
+    // 结构体字段或列表中的结构体元素
+    void begin_struct(int16_t id) { field(id, STRUCT); last_ids_.push_back(0); }
+    void begin_struct() { last_ids_.push_back(0); }
+    void end_struct() { out += '\0'; last_ids_.pop_back(); }
+
+private:
+    std::vector<int16_t> last_ids_{0};
+};
+
+// parquet.thrift中的枚举值
+constexpr int32_t TYPE_BOOLEAN = 0;
+constexpr int32_t TYPE_INT64 = 2;
+constexpr int32_t TYPE_BYTE_ARRAY = 6;
+constexpr int32_t REPETITION_OPTIONAL = 1;
+constexpr int32_t CONVERTED_UTF8 = 0;
+constexpr int32_t CONVERTED_TIMESTAMP_MILLIS = 9;
+constexpr int32_t ENCODING_PLAIN = 0;
+constexpr int32_t ENCODING_RLE = 3;
+constexpr int32_t CODEC_UNCOMPRESSED = 0;
+constexpr int32_t PAGE_DATA = 0;
+
+void append_le(std::string& out, uint64_t value, size_t bytes) {
+    for (size_t i = 0; i < bytes; ++i) {
+        out += static_cast<char>((value >> (8 * i)) & 0xFF);
+    }
+}
+
+int32_t physical_type(ParquetTable::ColumnType type) {
+    switch (type) {
+        case ParquetTable::ColumnType::BOOLEAN: return TYPE_BOOLEAN;
+        case ParquetTable::ColumnType::STRING:  return TYPE_BYTE_ARRAY;
+        default:                                return TYPE_INT64;
+    }
+}
+
+const std::string SCHEME = "parquet://";
+
+// sessions表的列
+enum SessionColumn : size_t {
+    S_MONITOR_ID, S_ROUTER, S_SESSION_ID, S_TRIGGER_TIME, S_TRIGGER_SOURCE, S_TRIGGER_EVENT,
+    S_TRIGGER_TYPE, S_INTERFACE, S_LINK, S_CONVERGENCE_MS, S_ROUTE_EVENTS, S_DURATION_MS,
+    S_TIMED_OUT, S_TRIGGER_INFO, S_FIRST_TAG
+};
+
+// events表的列
+enum EventColumn : size_t {
+    E_MONITOR_ID, E_ROUTER, E_SESSION_ID, E_EVENT_TYPE, E_TIMESTAMP, E_OFFSET_MS,
+    E_ROUTE_EVENT_TYPE, E_DST, E_INTERFACE, E_RECORD
+};
+
+} // namespace
+
+void ParquetTable::add_column(const std::string& name, ColumnType type) {
+    Column column;
+    column.name = name;
+    column.type = type;
+    columns_.push_back(column);
+}
+
+size_t ParquetTable::add_row() {
+    for (auto& column : columns_) {
+        column.defined.push_back(false);
+        if (column.type == ColumnType::STRING) {
+            column.strings.emplace_back();
+        } else {
+            column.ints.push_back(0);
+        }
+    }
+    return rows_++;
+}
+
+void ParquetTable::set_int(size_t row, size_t column, int64_t value) {
+    columns_[column].defined[row] = true;
+    columns_[column].ints[row] = value;
+}
+
+void ParquetTable::set_bool(size_t row, size_t column, bool value) {
+    set_int(row, column, value ? 1 : 0);
+}
+
+void ParquetTable::set_string(size_t row, size_t column, const std::string& value) {
+    if (value.empty() || value == "N/A") {
+        return;
+    }
+    columns_[column].defined[row] = true;
+    columns_[column].strings[row] = value;
+}
+
+std::string ParquetTable::encode_page(const Column& column, size_t rows) {
+    // 定义级别：最大级别为1，用RLE游程编码，前置4字节长度
+    std::string levels;
+    ThriftWriter rle;
+    for (size_t i = 0; i < rows;) {
+        size_t run = 1;
+        while (i + run < rows && column.defined[i + run] == column.defined[i]) {
+            ++run;
+        }
+        rle.varint(run << 1);
+        rle.out += static_cast<char>(column.defined[i] ? 1 : 0);
+        i += run;
+    }
+    append_le(levels, rle.out.size(), 4);
+    levels += rle.out;
+
+    // PLAIN编码的非空值
+    std::string values;
+    if (column.type == ColumnType::BOOLEAN) {
+        size_t bit = 0;
+        for (size_t i = 0; i < rows; ++i) {
+            if (!column.defined[i]) {
+                continue;
+            }
+            if (bit % 8 == 0) {
+                values += '\0';
+            }
+            if (column.ints[i]) {
+                values.back() = static_cast<char>(values.back() | (1 << (bit % 8)));
+            }
+            ++bit;
+        }
+    } else {
+        for (size_t i = 0; i < rows; ++i) {
+            if (!column.defined[i]) {
+                continue;
+            }
+            if (column.type == ColumnType::STRING) {
+                append_le(values, column.strings[i].size(), 4);
+                values += column.strings[i];
+            } else {
+                append_le(values, static_cast<uint64_t>(column.ints[i]), 8);
+            }
+        }
+    }
+    return levels + values;
+}
+
+bool ParquetTable::write(const std::string& path, std::string& error) const {
+    std::string body = "PAR1";
+    std::vector<std::pair<int64_t, int64_t>> chunks;  // (data_page_offset, 总大小)
+
+    for (const auto& column : columns_) {
+        std::string page = encode_page(column, rows_);
+
+        ThriftWriter header;
+        header.i32(1, PAGE_DATA);
+        header.i32(2, static_cast<int32_t>(page.size()));
+        header.i32(3, static_cast<int32_t>(page.size()));
+        header.begin_struct(5);
+        header.i32(1, static_cast<int32_t>(rows_));
+        header.i32(2, ENCODING_PLAIN);
+        header.i32(3, ENCODING_RLE);
+        header.i32(4, ENCODING_RLE);
+        header.end_struct();
+        header.out += '\0';
+
+        chunks.emplace_back(static_cast<int64_t>(body.size()),
+                            static_cast<int64_t>(header.out.size() + page.size()));
+        body += header.out;
+        body += page;
+    }
+
+    int64_t total_size = 0;
+    for (const auto& chunk : chunks) {
+        total_size += chunk.second;
+    }
+
+    ThriftWriter meta;
+    meta.i32(1, 1);
+    meta.list(2, ThriftWriter::STRUCT, columns_.size() + 1);
+    meta.begin_struct();
+    meta.string(4, "schema");
+    meta.i32(5, static_cast<int32_t>(columns_.size()));
+    meta.end_struct();
+    for (const auto& column : columns_) {
+        meta.begin_struct();
+        meta.i32(1, physical_type(column.type));
+        meta.i32(3, REPETITION_OPTIONAL);
+        meta.string(4, column.name);
+        if (column.type == ColumnType::STRING) {
+            meta.i32(6, CONVERTED_UTF8);
+        } else if (column.type == ColumnType::TIMESTAMP_MS) {
+            meta.i32(6, CONVERTED_TIMESTAMP_MILLIS);
+        }
+        meta.end_struct();
+    }
+    meta.i64(3, static_cast<int64_t>(rows_));
+    meta.list(4, ThriftWriter::STRUCT, 1);
+    meta.begin_struct();
+    meta.list(1, ThriftWriter::STRUCT, columns_.size());
+    for (size_t i = 0; i < columns_.size(); ++i) {
+        meta.begin_struct();
+        meta.i64(2, chunks[i].first);
+        meta.begin_struct(3);
+        meta.i32(1, physical_type(columns_[i].type));
+        meta.list(2, ThriftWriter::I32, 2);
+        meta.zigzag(ENCODING_PLAIN);
+        meta.zigzag(ENCODING_RLE);
+        meta.list(3, ThriftWriter::BINARY, 1);
+        meta.raw_string(columns_[i].name);
+        meta.i32(4, CODEC_UNCOMPRESSED);
+        meta.i64(5, static_cast<int64_t>(rows_));
+        meta.i64(6, chunks[i].second);
+        meta.i64(7, chunks[i].second);
+        meta.i64(9, chunks[i].first);
+        meta.end_struct();
+        meta.end_struct();
+    }
+    meta.i64(2, total_size);
+    meta.i64(3, static_cast<int64_t>(rows_));
+    meta.end_struct();
+    meta.string(6, "convergence-analyzer");
+    meta.out += '\0';
+
+    body += meta.out;
+    append_le(body, meta.out.size(), 4);
+    body += "PAR1";
+
+    std::ofstream file(path, std::ios::binary | std::ios::trunc);
+    if (!file.is_open()) {
+        error = "无法创建文件: " + path;
+        return false;
+    }
+    file.write(body.data(), static_cast<std::streamsize>(body.size()));
+    if (!file.good()) {
+        error = "写入失败: " + path;
+        return false;
+    }
+    return true;
+}
+
+bool ParquetExporter::parse_url(const std::string& url, std::string& directory) {
+    if (url.compare(0, SCHEME.size(), SCHEME) != 0 || url.size() == SCHEME.size()) {
+        return false;
+    }
+    directory = url.substr(SCHEME.size());
+    return true;
+}
+
+ParquetExporter::ParquetExporter(const std::string& url, const std::string& monitor_id,
+                                 const std::vector<std::string>& tag_keys)
+    : monitor_id_(monitor_id), tag_keys_(tag_keys) {
+    parse_url(url, directory_);
+
+    using Type = ParquetTable::ColumnType;
+    sessions_.add_column("monitor_id", Type::STRING);
+    sessions_.add_column("router_name", Type::STRING);
+    sessions_.add_column("session_id", Type::INT64);
+    sessions_.add_column("trigger_time", Type::TIMESTAMP_MS);
+    sessions_.add_column("trigger_source", Type::STRING);
+    sessions_.add_column("trigger_event_type", Type::STRING);
+    sessions_.add_column("trigger_type", Type::STRING);
+    sessions_.add_column("interface", Type::STRING);
+    sessions_.add_column("link", Type::STRING);
+    sessions_.add_column("convergence_ms", Type::INT64);
+    sessions_.add_column("route_events", Type::INT64);
+    sessions_.add_column("duration_ms", Type::INT64);
+    sessions_.add_column("timed_out", Type::BOOLEAN);
+    sessions_.add_column("trigger_info", Type::STRING);
+    for (const auto& key : tag_keys_) {
+        sessions_.add_column("tag_" + key, Type::STRING);
+    }
+
+    events_.add_column("monitor_id", Type::STRING);
+    events_.add_column("router_name", Type::STRING);
+    events_.add_column("session_id", Type::INT64);
+    events_.add_column("event_type", Type::STRING);
+    events_.add_column("timestamp", Type::TIMESTAMP_MS);
+    events_.add_column("offset_ms", Type::INT64);
+    events_.add_column("route_event_type", Type::STRING);
+    events_.add_column("dst", Type::STRING);
+    events_.add_column("interface", Type::STRING);
+    events_.add_column("record", Type::STRING);
+}
+
+void ParquetExporter::write_record(const JsonObject& record, const std::string& line) {
+    std::lock_guard<std::mutex> lock(record_mutex_);
+    std::string event_type = LogReader::get_string(record, "event_type");
+    std::string router = LogReader::get_string(record, "router_name");
+    if (router_name_.empty()) {
+        router_name_ = router;
+    }
+
+    if (event_type == "monitoring_started" || event_type == "monitoring_completed") {
+        return;
+    }
+
+    if (event_type == "session_started") {
+        int64_t session_id = LogReader::get_int(record, "session_id");
+        std::string trigger_info = LogReader::get_string(record, "trigger_info");
+        auto info = LogReader::parse_string_map(trigger_info);
+
+        size_t row = sessions_.add_row();
+        sessions_.set_string(row, S_MONITOR_ID, monitor_id_);
+        sessions_.set_string(row, S_ROUTER, router);
+        sessions_.set_int(row, S_SESSION_ID, session_id);
+        sessions_.set_int(row, S_TRIGGER_TIME,
+                          LogReader::parse_timestamp_ms(LogReader::get_timestamp(record, "timestamp")));
+        sessions_.set_string(row, S_TRIGGER_SOURCE, LogReader::get_string(record, "trigger_source"));
+        sessions_.set_string(row, S_TRIGGER_EVENT, LogReader::get_string(record, "trigger_event_type"));
+        sessions_.set_string(row, S_TRIGGER_TYPE, info["type"]);
+        sessions_.set_string(row, S_INTERFACE, info["interface"]);
+        sessions_.set_string(row, S_LINK, LogReader::get_string(record, "link"));
+        sessions_.set_string(row, S_TRIGGER_INFO, trigger_info);
+        for (size_t i = 0; i < tag_keys_.size(); ++i) {
+            sessions_.set_string(row, S_FIRST_TAG + i, LogReader::get_string(record, tag_keys_[i]));
+        }
+        session_rows_[session_id] = row;
+        return;
+    }
+
+    if (event_type == "session_completed") {
+        auto it = session_rows_.find(LogReader::get_int(record, "session_id"));
+        if (it == session_rows_.end()) {
+            return;
+        }
+        size_t row = it->second;
+        if (LogReader::has(record, "convergence_time_ms")) {
+            sessions_.set_int(row, S_CONVERGENCE_MS, LogReader::get_int(record, "convergence_time_ms"));
+        }
+        sessions_.set_int(row, S_ROUTE_EVENTS, LogReader::get_int(record, "route_events_count"));
+        sessions_.set_int(row, S_DURATION_MS, LogReader::get_int(record, "session_duration_ms"));
+        sessions_.set_bool(row, S_TIMED_OUT, LogReader::get_bool(record, "timed_out"));
+        session_count_++;
+        return;
+    }
+
+    size_t row = events_.add_row();
+    events_.set_string(row, E_MONITOR_ID, monitor_id_);
+    events_.set_string(row, E_ROUTER, router);
+    if (LogReader::has(record, "session_id")) {
+        events_.set_int(row, E_SESSION_ID, LogReader::get_int(record, "session_id"));
+    }
+    events_.set_string(row, E_EVENT_TYPE, event_type);
+    events_.set_int(row, E_TIMESTAMP, LogReader::parse_timestamp_ms(LogReader::get_timestamp(record, "timestamp")));
+    if (LogReader::has(record, "offset_from_trigger_ms")) {
+        events_.set_int(row, E_OFFSET_MS, LogReader::get_int(record, "offset_from_trigger_ms"));
+    }
+    events_.set_string(row, E_ROUTE_EVENT_TYPE, LogReader::get_string(record, "route_event_type"));
+    auto route_info = LogReader::parse_string_map(LogReader::get_string(record, "route_info"));
+    events_.set_string(row, E_DST, route_info["dst"]);
+    events_.set_string(row, E_INTERFACE, route_info["interface"]);
+    events_.set_string(row, E_RECORD, line);
+    event_count_++;
+}
+
+void ParquetExporter::stop() {
+    std::lock_guard<std::mutex> lock(record_mutex_);
+    if (flushed_) {
+        return;
+    }
+    flushed_ = true;
+    mkdir(directory_.c_str(), 0755);
+    std::string prefix = directory_ + "/" + (router_name_.empty() ? "" : router_name_ + "-") + monitor_id_;
+    if (sessions_.write(prefix + "-sessions.parquet", flush_error_)) {
+        events_.write(prefix + "-events.parquet", flush_error_);
+    }
+}
+
+std::string ParquetExporter::summary() const {
+    if (!flush_error_.empty()) {
+        return "Parquet: 导出失败: " + flush_error_;
+    }
+    return "Parquet: 已写出 " + std::to_string(session_count_.load()) + " 个会话、" +
+           std::to_string(event_count_.load()) + " 个事件到 " + directory_;
+}
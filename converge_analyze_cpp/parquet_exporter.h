@@ -0,0 +1,78 @@
+#pragma once
+
+#include <atomic>
+#include <cstdint>
+#include <map>
+#include <mutex>
+#include <string>
+#include <vector>
+#include "record_sink.h"
+
+// 内存中的列式表，写出为单个row group、未压缩、PLAIN编码的Parquet文件
+// 所有列均为OPTIONAL，未设置的值为null
+class ParquetTable {
+public:
+    enum class ColumnType { INT64, TIMESTAMP_MS, BOOLEAN, STRING };
+
+    void add_column(const std::string& name, ColumnType type);
+
+    // 追加一行(全部为null)，返回行号
+    size_t add_row();
+    void set_int(size_t row, size_t column, int64_t value);
+    void set_bool(size_t row, size_t column, bool value);
+    void set_string(size_t row, size_t column, const std::string& value);
+
+    size_t row_count() const { return rows_; }
+
+    bool write(const std::string& path, std::string& error) const;
+
+private:
+    struct Column {
+        std::string name;
+        ColumnType type;
+        std::vector<bool> defined;
+        std::vector<int64_t> ints;        // INT64/TIMESTAMP_MS/BOOLEAN
+        std::vector<std::string> strings; // STRING
+    };
+
+    std::vector<Column> columns_;
+    size_t rows_ = 0;
+
+    static std::string encode_page(const Column& column, size_t rows);
+};
+
+// 将每次运行的会话与事件导出为Parquet文件(--output parquet:///DIR)
+// 运行结束时写出 DIR/<router>-<monitor_id>-sessions.parquet 与 -events.parquet
+class ParquetExporter : public RecordSink {
+private:
+    std::string directory_;
+    std::string monitor_id_;
+    std::vector<std::string> tag_keys_;
+
+    std::mutex record_mutex_;
+    ParquetTable sessions_;                   // 受record_mutex_保护
+    ParquetTable events_;                     // 受record_mutex_保护
+    std::map<int64_t, size_t> session_rows_;  // session_id -> sessions_中的行号
+    std::string router_name_;
+
+    std::atomic<int64_t> session_count_{0};
+    std::atomic<int64_t> event_count_{0};
+    bool flushed_ = false;
+    std::string flush_error_;
+
+public:
+    ParquetExporter(const std::string& url, const std::string& monitor_id,
+                    const std::vector<std::string>& tag_keys);
+
+    ParquetExporter(const ParquetExporter&) = delete;
+    ParquetExporter& operator=(const ParquetExporter&) = delete;
+
+    bool start(std::string&) override { return true; }
+    void write_record(const JsonObject& record, const std::string& line) override;
+    // 写出文件
+    void stop() override;
+    std::string summary() const override;
+
+    // parquet:///DIR
+    static bool parse_url(const std::string& url, std::string& directory);
+};
@@ -0,0 +1,347 @@
+#include "preflight.h"
+#include "convergence_monitor.h"
+#include "i18n.h"
+#include "debug_log.h"
+#include <cerrno>
+#include <cstring>
+#include <fstream>
+#include <libgen.h>
+#include <linux/capability.h>
+#include <linux/netlink.h>
+#include <linux/rtnetlink.h>
+#include <net/if.h>
+#include <sys/ioctl.h>
+#include <sys/socket.h>
+#include <sys/stat.h>
+#include <sys/statvfs.h>
+#include <unistd.h>
+
+// 旧内核头文件中没有的能力编号
+#ifndef CAP_PERFMON
+#define CAP_PERFMON 38
+#endif
+#ifndef CAP_BPF
+#define CAP_BPF 39
+#endif
+
+namespace {
+
+const char* SETCAP_HINT = "sudo setcap cap_net_admin,cap_net_raw,cap_bpf,cap_perfmon+ep ./ConvergenceAnalyzer";
+
+// 绑定一个订阅指定多播组的rtnetlink套接字，失败时返回原因
+std::string probe_groups(uint32_t groups) {
+    int fd = socket(AF_NETLINK, SOCK_RAW | SOCK_CLOEXEC, NETLINK_ROUTE);
+    if (fd < 0) {
+        return std::string("socket: ") + strerror(errno);
+    }
+    struct sockaddr_nl addr;
+    memset(&addr, 0, sizeof(addr));
+    addr.nl_family = AF_NETLINK;
+    addr.nl_groups = groups;
+    std::string error;
+    if (bind(fd, reinterpret_cast<struct sockaddr*>(&addr), sizeof(addr)) < 0) {
+        error = std::string("bind: ") + strerror(errno);
+    }
+    close(fd);
+    return error;
+}
+
+// 转储所有qdisc，返回条数；失败时返回-1并设置error
+int64_t dump_qdiscs(std::string& error) {
+    int fd = socket(AF_NETLINK, SOCK_RAW | SOCK_CLOEXEC, NETLINK_ROUTE);
+    if (fd < 0) {
+        error = std::string("socket: ") + strerror(errno);
+        return -1;
+    }
+    struct timeval timeout = {2, 0};
+    setsockopt(fd, SOL_SOCKET, SO_RCVTIMEO, &timeout, sizeof(timeout));
+
+    struct {
+        struct nlmsghdr header;
+        struct tcmsg tcm;
+    } request;
+    memset(&request, 0, sizeof(request));
+    request.header.nlmsg_len = NLMSG_LENGTH(sizeof(struct tcmsg));
+    request.header.nlmsg_type = RTM_GETQDISC;
+    request.header.nlmsg_flags = NLM_F_REQUEST | NLM_F_DUMP;
+    request.header.nlmsg_seq = 1;
+    request.tcm.tcm_family = AF_UNSPEC;
+    if (send(fd, &request, request.header.nlmsg_len, 0) < 0) {
+        error = std::string("send: ") + strerror(errno);
+        close(fd);
+        return -1;
+    }
+
+    int64_t count = 0;
+    char buffer[16384];
+    while (true) {
+        ssize_t len = recv(fd, buffer, sizeof(buffer), 0);
+        if (len < 0) {
+            error = std::string("recv: ") + strerror(errno);
+            count = -1;
+            break;
+        }
+        bool done = false;
+        for (auto* nlh = reinterpret_cast<struct nlmsghdr*>(buffer); NLMSG_OK(nlh, static_cast<unsigned>(len));
+             nlh = NLMSG_NEXT(nlh, len)) {
+            if (nlh->nlmsg_type == NLMSG_DONE) {
+                done = true;
+                break;
+            }
+            if (nlh->nlmsg_type == NLMSG_ERROR) {
+                auto* err = static_cast<struct nlmsgerr*>(NLMSG_DATA(nlh));
+                error = strerror(-err->error);
+                count = -1;
+                done = true;
+                break;
+            }
+            if (nlh->nlmsg_type == RTM_NEWQDISC) {
+                count++;
+            }
+        }
+        if (done) {
+            break;
+        }
+    }
+    close(fd);
+    return count;
+}
+
+PreflightCheck subscription_check(const std::string& name, uint32_t groups, const std::string& covers) {
+    PreflightCheck check;
+    check.name = name;
+    std::string error = probe_groups(groups);
+    check.ok = error.empty();
+    check.detail = check.ok ? covers : error;
+    return check;
+}
+
+// 路径所在(最近的已存在的)目录位于只读文件系统时返回true
+bool on_read_only_filesystem(std::string path) {
+    while (!path.empty()) {
+        struct statvfs fs;
+        if (statvfs(path.c_str(), &fs) == 0) {
+            return (fs.f_flag & ST_RDONLY) != 0;
+        }
+        std::string buffer = path;
+        std::string parent = dirname(&buffer[0]);
+        if (parent == path) {
+            break;
+        }
+        path = parent;
+    }
+    return false;
+}
+
+void mark_log_path_failed(PreflightCheck& check, const std::string& path) {
+    check.ok = false;
+    if (on_read_only_filesystem(path)) {
+        check.code = "log_path_read_only";
+        check.hint = tr("日志目录位于只读文件系统(如容器中只读挂载的/var/log)，用--log-path指向可写的卷",
+                        "The log directory is on a read-only filesystem (e.g. /var/log mounted read-only in a container); "
+                        "point --log-path at a writable volume");
+    } else {
+        check.code = "log_path_not_writable";
+        check.hint = tr("用--log-path指定当前用户可写的目录，或以sudo运行(默认目录/var/log/frr需要root)",
+                        "Pass --log-path with a directory writable by the current user, or run with sudo "
+                        "(the default /var/log/frr requires root)");
+    }
+}
+
+// 日志文件已存在时检查文件本身，否则检查所在目录，均不创建文件
+PreflightCheck log_path_check(const MonitorConfig& config) {
+    PreflightCheck check;
+    check.name = "log_path";
+    std::string path;
+    // 与正式运行相同的路径解析(目录、默认路径与回退到当前目录)；解析过程的提示在监控器创建日志时再输出
+    LogLevel level = get_log_level();
+    set_log_level(LogLevel::WARN);
+    try {
+        Logger logger(config.log_path);
+        path = logger.get_log_file_path();
+        set_log_level(level);
+    } catch (const std::exception& e) {
+        set_log_level(level);
+        check.detail = e.what();
+        mark_log_path_failed(check, config.log_path.empty() ? "/var/log/frr" : config.log_path);
+        return check;
+    }
+
+    struct stat st;
+    if (stat(path.c_str(), &st) == 0) {
+        check.ok = S_ISREG(st.st_mode) && access(path.c_str(), W_OK) == 0;
+        check.detail = path + (check.ok ? " (追加写入)" : " (不可写)");
+        if (!check.ok) {
+            mark_log_path_failed(check, path);
+        }
+        // 无法创建指定目录时Logger回退到当前目录，日志不在预期的位置
+        if (!config.log_path.empty() && path.rfind("./", 0) == 0 && config.log_path.rfind("./", 0) != 0) {
+            check.detail += " (无法使用 " + config.log_path + "，已回退到当前目录)";
+        }
+        return check;
+    }
+    std::string directory_buffer = path;
+    std::string directory = dirname(&directory_buffer[0]);
+    check.ok = access(directory.c_str(), W_OK | X_OK) == 0;
+    check.detail = path + (check.ok ? " (将新建)" : " (目录 " + directory + " 不可写: " + strerror(errno) + ")");
+    if (!check.ok) {
+        mark_log_path_failed(check, directory);
+    }
+    return check;
+}
+
+// 已启用的功能所需的能力；缺少时这些功能在运行中会自行降级，这里只提前给出警告
+void capability_checks(const MonitorConfig& config, std::vector<PreflightCheck>& checks) {
+    bool net_admin = has_capability(CAP_NET_ADMIN);
+    bool net_raw = has_capability(CAP_NET_RAW);
+    bool sys_admin = has_capability(CAP_SYS_ADMIN);
+    bool bpf = sys_admin || (has_capability(CAP_BPF) && has_capability(CAP_PERFMON));
+
+    PreflightCheck summary;
+    summary.name = "capabilities";
+    summary.detail = std::string("CAP_NET_ADMIN=") + (net_admin ? "yes" : "no") +
+                     " CAP_NET_RAW=" + (net_raw ? "yes" : "no") +
+                     " CAP_BPF+CAP_PERFMON=" + (bpf ? "yes" : "no");
+    checks.push_back(summary);
+
+    auto require = [&](const std::string& feature, bool present, const std::string& capabilities) {
+        if (present) {
+            return;
+        }
+        PreflightCheck check;
+        check.name = "capabilities";
+        check.ok = false;
+        check.severity = "warning";
+        check.code = "cap_missing";
+        check.detail = feature + tr(" 需要 ", " requires ") + capabilities;
+        check.hint = std::string(tr("以root运行，或授予所需能力: ", "Run as root or grant the capabilities: ")) + SETCAP_HINT;
+        checks.push_back(check);
+    };
+    if (!config.dataplane_flow.dst.empty()) {
+        require("--dataplane-probe", net_admin && bpf, "CAP_NET_ADMIN, CAP_BPF+CAP_PERFMON");
+    }
+    if (config.fib_trace) {
+        require("--fib-trace", bpf, "CAP_BPF+CAP_PERFMON");
+    }
+    if (!config.pcap.directory.empty()) {
+        require("--pcap-dir", net_raw, "CAP_NET_RAW");
+    }
+}
+
+} // namespace
+
+std::string PreflightCheck::to_json() const {
+    JsonObject record;
+    record["event_type"] = "preflight_failed";
+    record["check"] = name;
+    record["code"] = code;
+    record["severity"] = severity;
+    record["detail"] = detail;
+    record["hint"] = hint;
+    if (route_only) {
+        record["route_only"] = true;
+    }
+    return Logger::json_to_string(record);
+}
+
+bool has_capability(int cap) {
+    std::ifstream status("/proc/self/status");
+    std::string line;
+    while (std::getline(status, line)) {
+        if (line.rfind("CapEff:", 0) == 0) {
+            uint64_t mask = std::stoull(line.substr(7), nullptr, 16);
+            return (mask >> cap) & 1;
+        }
+    }
+    return geteuid() == 0;
+}
+
+std::vector<PreflightCheck> run_preflight(const MonitorConfig& config) {
+    std::vector<PreflightCheck> checks;
+    PreflightCheck route = subscription_check("netlink_route",
+        RTMGRP_IPV4_ROUTE | RTMGRP_IPV6_ROUTE | RTMGRP_IPV4_RULE | (1u << (RTNLGRP_IPV6_RULE - 1)),
+        "IPv4/IPv6路由与策略路由");
+    if (!route.ok) {
+        route.code = "netlink_route_denied";
+        route.hint = tr("无法订阅路由变化：确认在目标网络命名空间中运行(或使用--netns)，容器中运行时需要--cap-add NET_ADMIN",
+                        "Cannot subscribe to route changes: run inside the target network namespace (or use --netns); "
+                        "containers need --cap-add NET_ADMIN");
+    }
+    checks.push_back(route);
+
+    // tc不可用时可降级为只监控路由，netem触发不再可用
+    if (!config.route_only) {
+        PreflightCheck tc = subscription_check("netlink_tc", RTMGRP_TC, "qdisc变化");
+        PreflightCheck qdisc;
+        qdisc.name = "qdisc_dump";
+        std::string error;
+        int64_t count = dump_qdiscs(error);
+        qdisc.ok = count >= 0;
+        qdisc.detail = qdisc.ok ? std::to_string(count) + " 条qdisc" : error;
+        for (PreflightCheck* check : {&tc, &qdisc}) {
+            if (check->ok) {
+                checks.push_back(*check);
+                continue;
+            }
+            check->code = "tc_monitoring_denied";
+            check->severity = "warning";
+            check->route_only = true;
+            check->hint = (has_capability(CAP_NET_ADMIN)
+                               ? std::string()
+                               : tr("当前进程没有CAP_NET_ADMIN；", "The process lacks CAP_NET_ADMIN; ")) +
+                          tr("以root运行或授予CAP_NET_ADMIN以监控netem变化，否则只监控路由(等同--route-only)",
+                             "run as root or grant CAP_NET_ADMIN to monitor netem changes, otherwise only routes "
+                             "are monitored (same as --route-only)");
+            checks.push_back(*check);
+        }
+    }
+    if (config.mroute) {
+        checks.push_back(subscription_check("netlink_mroute", RTMGRP_IPV4_MROUTE | RTMGRP_IPV6_MROUTE, "组播路由"));
+    }
+    if (config.fdb) {
+        checks.push_back(subscription_check("netlink_neigh", RTMGRP_NEIGH, "FDB"));
+    }
+    if (config.tunnels || config.bonding || config.link_events) {
+        checks.push_back(subscription_check("netlink_link", RTMGRP_LINK, "链路属性"));
+    }
+
+    checks.push_back(log_path_check(config));
+    capability_checks(config, checks);
+    return checks;
+}
+
+std::vector<PreflightInterface> list_monitored_interfaces(const MonitorConfig& config) {
+    std::vector<PreflightInterface> result;
+    struct if_nameindex* interfaces = if_nameindex();
+    if (interfaces == nullptr) {
+        return result;
+    }
+    int fd = socket(AF_INET, SOCK_DGRAM | SOCK_CLOEXEC, 0);
+    for (struct if_nameindex* it = interfaces; it->if_index != 0 && it->if_name != nullptr; ++it) {
+        PreflightInterface iface;
+        iface.name = it->if_name;
+        iface.state = "unknown";
+        struct ifreq ifr;
+        memset(&ifr, 0, sizeof(ifr));
+        strncpy(ifr.ifr_name, it->if_name, IFNAMSIZ - 1);
+        if (fd >= 0 && ioctl(fd, SIOCGIFFLAGS, &ifr) == 0) {
+            iface.state = (ifr.ifr_flags & IFF_UP) ? ((ifr.ifr_flags & IFF_RUNNING) ? "up" : "no-carrier") : "down";
+        }
+        iface.filtered = !config.filter.matches({{"interface", iface.name}}, false);
+        auto link_it = config.interface_links.find(iface.name);
+        if (link_it != config.interface_links.end()) {
+            iface.link = link_it->second.link;
+        }
+        std::string rule;
+        auto threshold = config.threshold_overrides.match(iface.name, "", "", rule);
+        if (threshold.has_value()) {
+            iface.threshold = std::to_string(threshold.value()) + "ms (" + rule + ")";
+        }
+        result.push_back(iface);
+    }
+    if (fd >= 0) {
+        close(fd);
+    }
+    if_freenameindex(interfaces);
+    return result;
+}
@@ -0,0 +1,40 @@
+#pragma once
+
+#include <string>
+#include <vector>
+
+struct MonitorConfig;
+
+// 一项启动前检查的结果
+struct PreflightCheck {
+    std::string name;    // netlink_route、netlink_tc、qdisc_dump、log_path、capabilities等
+    bool ok = true;
+    std::string detail;  // 成功时为摘要，失败时为原因
+    // 以下只在失败时有意义
+    std::string code;               // 稳定的错误码，如 tc_monitoring_denied、log_path_read_only
+    std::string severity = "error";  // error: 无法开始监控；warning: 相关功能不可用，其余照常
+    std::string hint;               // 可操作的修正建议
+    bool route_only = false;        // 可以只监控路由(--route-only)继续运行
+
+    // 单行JSON，--console-format json时输出到stderr
+    std::string to_json() const;
+};
+
+// 将被监控的一个接口
+struct PreflightInterface {
+    std::string name;
+    std::string state;      // up/no-carrier/down/unknown
+    bool filtered = false;  // 被--filter-interface排除
+    std::string link;       // --topology中的逻辑链路
+    std::string threshold;  // 命中--threshold-override时的阈值与规则
+};
+
+// 逐项检查netlink订阅、qdisc读取、日志路径与已启用功能所需的能力(capability)，权限问题在实验开始前暴露。
+// --dry-run时输出全部结果；正式运行时error级失败直接退出，warning级失败照常运行并写入日志
+std::vector<PreflightCheck> run_preflight(const MonitorConfig& config);
+
+// 当前进程是否拥有某项能力(CapEff)，cap为CAP_*编号
+bool has_capability(int cap);
+
+// 当前网络命名空间内的接口，按ifindex排序
+std::vector<PreflightInterface> list_monitored_interfaces(const MonitorConfig& config);
@@ -0,0 +1,238 @@
+#include "prometheus_exporter.h"
+#include "bmp_collector.h"
+#include "listen_socket.h"
+#include "log_reader.h"
+#include <algorithm>
+#include <cerrno>
+#include <poll.h>
+#include <sstream>
+#include <sys/socket.h>
+#include <unistd.h>
+
+namespace {
+
+const std::string SCHEME = "prometheus://";
+
+std::string escape_label(const std::string& value) {
+    std::string escaped;
+    for (char c : value) {
+        if (c == '\\' || c == '"') {
+            escaped += '\\';
+            escaped += c;
+        } else if (c == '\n') {
+            escaped += "\\n";
+        } else {
+            escaped += c;
+        }
+    }
+    return escaped;
+}
+
+} // namespace
+
+const std::vector<int64_t> PrometheusExporter::BUCKETS_MS = {10, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000};
+
+bool PrometheusExporter::parse_url(const std::string& url, std::string& address, int& port) {
+    if (url.compare(0, SCHEME.size(), SCHEME) != 0) {
+        return false;
+    }
+    return BmpCollector::parse_listen_spec(url.substr(SCHEME.size()), address, port);
+}
+
+PrometheusExporter::PrometheusExporter(const std::string& listen_address, int port)
+    : listen_address_(listen_address), port_(port) {
+}
+
+PrometheusExporter::~PrometheusExporter() {
+    stop();
+}
+
+bool PrometheusExporter::start(std::string& error) {
+    if (running_.load()) {
+        return true;
+    }
+
+    listen_fd_ = open_listen_socket(listen_address_, port_, SOCK_STREAM, error);
+    if (listen_fd_ < 0) {
+        return false;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&PrometheusExporter::worker_loop, this);
+    return true;
+}
+
+void PrometheusExporter::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+    if (listen_fd_ >= 0) {
+        close(listen_fd_);
+        listen_fd_ = -1;
+    }
+}
+
+void PrometheusExporter::worker_loop() {
+    while (running_.load()) {
+        struct pollfd pfd = {listen_fd_, POLLIN, 0};
+        if (poll(&pfd, 1, 200) <= 0 || !(pfd.revents & POLLIN)) {
+            continue;
+        }
+        int fd = accept4(listen_fd_, nullptr, nullptr, SOCK_CLOEXEC);
+        if (fd >= 0) {
+            serve(fd);
+            close(fd);
+        }
+    }
+}
+
+void PrometheusExporter::serve(int fd) {
+    // 抓取请求很小，读到头部结束或超时即可
+    struct timeval timeout = {1, 0};
+    setsockopt(fd, SOL_SOCKET, SO_RCVTIMEO, &timeout, sizeof(timeout));
+    setsockopt(fd, SOL_SOCKET, SO_SNDTIMEO, &timeout, sizeof(timeout));
+
+    std::string request;
+    char buffer[4096];
+    while (request.find("\r\n\r\n") == std::string::npos && request.size() < 16384) {
+        ssize_t len = read(fd, buffer, sizeof(buffer));
+        if (len <= 0) {
+            break;
+        }
+        request.append(buffer, static_cast<size_t>(len));
+    }
+
+    std::string request_line = request.substr(0, request.find("\r\n"));
+    std::string status = "200 OK";
+    std::string body;
+    if (request_line.compare(0, 13, "GET /metrics ") == 0 || request_line.compare(0, 6, "GET / ") == 0) {
+        body = render();
+        scrape_count_.fetch_add(1);
+    } else {
+        status = "404 Not Found";
+        body = "not found\n";
+    }
+
+    std::string response = "HTTP/1.1 " + status + "\r\n"
+        "Content-Type: text/plain; version=0.0.4; charset=utf-8\r\n"
+        "Content-Length: " + std::to_string(body.size()) + "\r\n"
+        "Connection: close\r\n\r\n" + body;
+    size_t sent = 0;
+    while (sent < response.size()) {
+        ssize_t len = send(fd, response.data() + sent, response.size() - sent, MSG_NOSIGNAL);
+        if (len <= 0) {
+            break;
+        }
+        sent += static_cast<size_t>(len);
+    }
+}
+
+void PrometheusExporter::write_record(const JsonObject& record, const std::string&) {
+    std::string event_type = LogReader::get_string(record, "event_type");
+    std::string router = LogReader::get_string(record, "router_name");
+
+    std::lock_guard<std::mutex> lock(metrics_mutex_);
+    auto& metrics = routers_[router];
+    if (metrics.buckets.empty()) {
+        metrics.buckets.assign(BUCKETS_MS.size(), 0);
+    }
+    metrics.records[event_type]++;
+
+    if (event_type == "session_started") {
+        metrics.active_sessions++;
+    } else if (event_type == "route_event") {
+        // --coalesce-ms合并的记录代表多条事件
+        metrics.route_events += LogReader::get_int(record, "coalesced_count", 1);
+    } else if (event_type == "route_event_summary") {
+        metrics.route_events += LogReader::get_int(record, "route_events");
+    } else if (event_type == "session_completed") {
+        metrics.active_sessions = std::max<int64_t>(0, metrics.active_sessions - 1);
+        metrics.sessions++;
+        if (LogReader::get_bool(record, "timed_out")) {
+            metrics.timed_out++;
+        }
+        // 强制结束的会话不带convergence_time_ms，不进入直方图
+        if (LogReader::get_bool(record, "forced")) {
+            metrics.forced++;
+        }
+        if (LogReader::has(record, "convergence_time_ms")) {
+            int64_t convergence_ms = LogReader::get_int(record, "convergence_time_ms");
+            metrics.last_convergence_ms = convergence_ms;
+            metrics.convergence_count++;
+            metrics.convergence_sum_ms += convergence_ms;
+            for (size_t i = 0; i < BUCKETS_MS.size(); ++i) {
+                if (convergence_ms <= BUCKETS_MS[i]) {
+                    metrics.buckets[i]++;
+                }
+            }
+        }
+    }
+}
+
+std::string PrometheusExporter::render() const {
+    std::lock_guard<std::mutex> lock(metrics_mutex_);
+    std::ostringstream out;
+
+    auto counter = [&](const std::string& name, const std::string& help, const std::string& type,
+                       int64_t PrometheusRouterMetrics::*field) {
+        out << "# HELP " << name << " " << help << "\n";
+        out << "# TYPE " << name << " " << type << "\n";
+        for (const auto& entry : routers_) {
+            out << name << "{router=\"" << escape_label(entry.first) << "\"} " << entry.second.*field << "\n";
+        }
+    };
+
+    counter("convergence_sessions_total", "Completed convergence sessions.", "counter",
+            &PrometheusRouterMetrics::sessions);
+    counter("convergence_sessions_timed_out_total", "Sessions that hit the listen timeout.", "counter",
+            &PrometheusRouterMetrics::timed_out);
+    counter("convergence_sessions_forced_total", "Sessions still open when monitoring stopped.", "counter",
+            &PrometheusRouterMetrics::forced);
+    counter("convergence_route_events_total", "Route events recorded inside sessions.", "counter",
+            &PrometheusRouterMetrics::route_events);
+    counter("convergence_active_sessions", "Sessions currently being measured.", "gauge",
+            &PrometheusRouterMetrics::active_sessions);
+
+    out << "# HELP convergence_last_time_ms Convergence time of the most recent session.\n";
+    out << "# TYPE convergence_last_time_ms gauge\n";
+    for (const auto& entry : routers_) {
+        if (entry.second.last_convergence_ms >= 0) {
+            out << "convergence_last_time_ms{router=\"" << escape_label(entry.first) << "\"} "
+                << entry.second.last_convergence_ms << "\n";
+        }
+    }
+
+    out << "# HELP convergence_time_ms Convergence time per session.\n";
+    out << "# TYPE convergence_time_ms histogram\n";
+    for (const auto& entry : routers_) {
+        std::string router = escape_label(entry.first);
+        const auto& metrics = entry.second;
+        for (size_t i = 0; i < BUCKETS_MS.size(); ++i) {
+            out << "convergence_time_ms_bucket{router=\"" << router << "\",le=\"" << BUCKETS_MS[i] << "\"} "
+                << metrics.buckets[i] << "\n";
+        }
+        out << "convergence_time_ms_bucket{router=\"" << router << "\",le=\"+Inf\"} "
+            << metrics.convergence_count << "\n";
+        out << "convergence_time_ms_sum{router=\"" << router << "\"} " << metrics.convergence_sum_ms << "\n";
+        out << "convergence_time_ms_count{router=\"" << router << "\"} " << metrics.convergence_count << "\n";
+    }
+
+    out << "# HELP convergence_records_total Structured log records by event type.\n";
+    out << "# TYPE convergence_records_total counter\n";
+    for (const auto& entry : routers_) {
+        for (const auto& record : entry.second.records) {
+            out << "convergence_records_total{router=\"" << escape_label(entry.first) << "\",event_type=\""
+                << escape_label(record.first) << "\"} " << record.second << "\n";
+        }
+    }
+    return out.str();
+}
+
+std::string PrometheusExporter::summary() const {
+    return "Prometheus: 端口 " + std::to_string(port_) + " 被抓取 " + std::to_string(scrape_count_.load()) + " 次";
+}
@@ -0,0 +1,60 @@
+#pragma once
+
+#include <atomic>
+#include <cstdint>
+#include <map>
+#include <mutex>
+#include <string>
+#include <thread>
+#include <vector>
+#include "record_sink.h"
+
+// 单个路由器的累计指标
+struct PrometheusRouterMetrics {
+    int64_t sessions = 0;
+    int64_t timed_out = 0;
+    int64_t forced = 0;
+    int64_t route_events = 0;
+    int64_t active_sessions = 0;
+    int64_t last_convergence_ms = -1;
+    std::vector<int64_t> buckets;  // 与BUCKETS_MS对应的累计计数
+    int64_t convergence_count = 0;
+    int64_t convergence_sum_ms = 0;
+    std::map<std::string, int64_t> records;  // event_type -> 记录数
+};
+
+// 在HTTP端口上以Prometheus文本格式暴露收敛指标: prometheus://[ADDR:]PORT
+class PrometheusExporter : public RecordSink {
+private:
+    std::string listen_address_;
+    int port_;
+    int listen_fd_ = -1;
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+    std::atomic<int64_t> scrape_count_{0};
+
+    mutable std::mutex metrics_mutex_;
+    std::map<std::string, PrometheusRouterMetrics> routers_;  // 受metrics_mutex_保护
+
+    void worker_loop();
+    void serve(int fd);
+
+public:
+    static const std::vector<int64_t> BUCKETS_MS;
+
+    PrometheusExporter(const std::string& listen_address, int port);
+    ~PrometheusExporter() override;
+
+    PrometheusExporter(const PrometheusExporter&) = delete;
+    PrometheusExporter& operator=(const PrometheusExporter&) = delete;
+
+    bool start(std::string& error) override;
+    void write_record(const JsonObject& record, const std::string& line) override;
+    void stop() override;
+    std::string summary() const override;
+
+    // 生成/metrics响应内容
+    std::string render() const;
+
+    static bool parse_url(const std::string& url, std::string& address, int& port);
+};
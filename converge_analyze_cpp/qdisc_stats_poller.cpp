@@ -0,0 +1,250 @@
+#include "qdisc_stats_poller.h"
+#include <algorithm>
+#include <cerrno>
+#include <chrono>
+#include <cstdio>
+#include <cstring>
+#include <linux/gen_stats.h>
+#include <linux/netlink.h>
+#include <linux/pkt_sched.h>
+#include <linux/rtnetlink.h>
+#include <net/if.h>
+#include <sys/socket.h>
+#include <unistd.h>
+
+namespace {
+
+int64_t now_ms() {
+    return std::chrono::duration_cast<std::chrono::milliseconds>(
+        std::chrono::system_clock::now().time_since_epoch()).count();
+}
+
+std::string format_handle(uint32_t handle) {
+    char buffer[16];
+    snprintf(buffer, sizeof(buffer), "%x:", TC_H_MAJ(handle) >> 16);
+    return buffer;
+}
+
+void parse_stats2(const struct rtattr* rta, int len, QdiscStats& stats) {
+    for (; RTA_OK(rta, len); rta = RTA_NEXT(rta, len)) {
+        if (rta->rta_type == TCA_STATS_BASIC && RTA_PAYLOAD(rta) >= sizeof(struct gnet_stats_basic)) {
+            struct gnet_stats_basic basic;
+            memcpy(&basic, RTA_DATA(rta), sizeof(basic));
+            stats.bytes = static_cast<int64_t>(basic.bytes);
+            stats.packets = basic.packets;
+        } else if (rta->rta_type == TCA_STATS_QUEUE && RTA_PAYLOAD(rta) >= sizeof(struct gnet_stats_queue)) {
+            struct gnet_stats_queue queue;
+            memcpy(&queue, RTA_DATA(rta), sizeof(queue));
+            stats.qlen = queue.qlen;
+            stats.backlog = queue.backlog;
+            stats.drops = queue.drops;
+            stats.requeues = queue.requeues;
+            stats.overlimits = queue.overlimits;
+        }
+    }
+}
+
+} // namespace
+
+QdiscStatsPoller::QdiscStatsPoller(int64_t interval_ms, InterfaceSource interface, Callback callback)
+    : interval_ms_(interval_ms), interface_(std::move(interface)), callback_(std::move(callback)) {
+}
+
+QdiscStatsPoller::~QdiscStatsPoller() {
+    stop();
+}
+
+void QdiscStatsPoller::start() {
+    if (running_.load()) {
+        return;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&QdiscStatsPoller::worker_loop, this);
+}
+
+void QdiscStatsPoller::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+}
+
+void QdiscStatsPoller::worker_loop() {
+    while (running_.load()) {
+        auto poll_start = std::chrono::steady_clock::now();
+
+        std::string interface = interface_();
+        if (!interface.empty()) {
+            // 接口在会话中被删除等读取失败的情况直接跳过本次
+            std::string error;
+            auto qdiscs = read(interface, error);
+            if (const QdiscStats* stats = select(qdiscs)) {
+                callback_(*stats);
+            }
+        }
+
+        auto deadline = poll_start + std::chrono::milliseconds(interval_ms_);
+        while (running_.load() && std::chrono::steady_clock::now() < deadline) {
+            std::this_thread::sleep_for(std::chrono::milliseconds(5));
+        }
+    }
+}
+
+std::vector<QdiscStats> QdiscStatsPoller::read(const std::string& interface, std::string& error) {
+    std::vector<QdiscStats> qdiscs;
+    int ifindex = static_cast<int>(if_nametoindex(interface.c_str()));
+    if (ifindex == 0) {
+        error = interface + ": " + strerror(errno);
+        return qdiscs;
+    }
+
+    // 与路由表采样一样使用独立的短连接套接字
+    int fd = socket(AF_NETLINK, SOCK_RAW | SOCK_CLOEXEC, NETLINK_ROUTE);
+    if (fd < 0) {
+        error = std::string("socket: ") + strerror(errno);
+        return qdiscs;
+    }
+
+    struct {
+        struct nlmsghdr nlh;
+        struct tcmsg tcm;
+    } request{};
+    request.nlh.nlmsg_len = NLMSG_LENGTH(sizeof(struct tcmsg));
+    request.nlh.nlmsg_type = RTM_GETQDISC;
+    request.nlh.nlmsg_flags = NLM_F_REQUEST | NLM_F_DUMP;
+    request.nlh.nlmsg_seq = 1;
+    request.tcm.tcm_family = AF_UNSPEC;
+    request.tcm.tcm_ifindex = ifindex;
+
+    if (send(fd, &request, request.nlh.nlmsg_len, 0) < 0) {
+        error = std::string("send: ") + strerror(errno);
+        close(fd);
+        return qdiscs;
+    }
+
+    int64_t timestamp = now_ms();
+    char buffer[32768];
+    bool done = false;
+    while (!done) {
+        ssize_t len = recv(fd, buffer, sizeof(buffer), 0);
+        if (len < 0) {
+            if (errno == EINTR) {
+                continue;
+            }
+            error = std::string("recv: ") + strerror(errno);
+            break;
+        }
+        if (len == 0) {
+            break;
+        }
+
+        int remaining = static_cast<int>(len);
+        for (struct nlmsghdr* nlh = reinterpret_cast<struct nlmsghdr*>(buffer);
+             NLMSG_OK(nlh, remaining); nlh = NLMSG_NEXT(nlh, remaining)) {
+            if (nlh->nlmsg_type == NLMSG_DONE) {
+                done = true;
+                break;
+            }
+            if (nlh->nlmsg_type == NLMSG_ERROR) {
+                auto* err = static_cast<struct nlmsgerr*>(NLMSG_DATA(nlh));
+                error = std::string("dump: ") + strerror(-err->error);
+                done = true;
+                break;
+            }
+            if (nlh->nlmsg_type != RTM_NEWQDISC) {
+                continue;
+            }
+
+            const auto* tcm = static_cast<const struct tcmsg*>(NLMSG_DATA(nlh));
+            // 较早的内核忽略请求中的ifindex，返回全部接口的qdisc
+            if (tcm->tcm_ifindex != ifindex) {
+                continue;
+            }
+            QdiscStats stats;
+            stats.timestamp_ms = timestamp;
+            stats.interface = interface;
+            stats.handle = format_handle(tcm->tcm_handle);
+            stats.root = tcm->tcm_parent == TC_H_ROOT;
+
+            int attrlen = nlh->nlmsg_len - NLMSG_LENGTH(sizeof(*tcm));
+            const auto* rta = reinterpret_cast<const struct rtattr*>(
+                reinterpret_cast<const char*>(tcm) + NLMSG_ALIGN(sizeof(*tcm)));
+            for (; RTA_OK(rta, attrlen); rta = RTA_NEXT(rta, attrlen)) {
+                if (rta->rta_type == TCA_KIND) {
+                    stats.kind = static_cast<const char*>(RTA_DATA(rta));
+                } else if (rta->rta_type == TCA_STATS2) {
+                    parse_stats2(static_cast<const struct rtattr*>(RTA_DATA(rta)),
+                                 static_cast<int>(RTA_PAYLOAD(rta)), stats);
+                }
+            }
+            qdiscs.push_back(std::move(stats));
+        }
+    }
+
+    close(fd);
+    return qdiscs;
+}
+
+const QdiscStats* QdiscStatsPoller::select(const std::vector<QdiscStats>& qdiscs) {
+    const QdiscStats* root = nullptr;
+    for (const auto& stats : qdiscs) {
+        if (stats.kind == "netem") {
+            return &stats;
+        }
+        if (stats.root) {
+            root = &stats;
+        }
+    }
+    return root;
+}
+
+QdiscStatsSummary QdiscStatsSummary::summarize(const std::vector<QdiscStats>& samples, int64_t trigger_ms) {
+    QdiscStatsSummary summary;
+    summary.samples = static_cast<int64_t>(samples.size());
+    for (size_t i = 0; i < samples.size(); ++i) {
+        const QdiscStats& sample = samples[i];
+        if (i > 0) {
+            const QdiscStats& previous = samples[i - 1];
+            bool same = previous.handle == sample.handle && previous.kind == sample.kind;
+            summary.drops += same ? std::max<int64_t>(0, sample.drops - previous.drops) : sample.drops;
+            summary.requeues += same ? std::max<int64_t>(0, sample.requeues - previous.requeues) : sample.requeues;
+            summary.sent_packets += same ? std::max<int64_t>(0, sample.packets - previous.packets) : sample.packets;
+        }
+        summary.max_backlog = std::max(summary.max_backlog, sample.backlog);
+        std::string separator = i ? "," : "";
+        summary.offset_series += separator + std::to_string(sample.timestamp_ms - trigger_ms);
+        summary.backlog_series += separator + std::to_string(sample.backlog);
+        summary.qlen_series += separator + std::to_string(sample.qlen);
+        summary.drops_series += separator + std::to_string(summary.drops);
+        summary.requeues_series += separator + std::to_string(summary.requeues);
+    }
+    if (!samples.empty()) {
+        summary.kind = samples.back().kind;
+        summary.handle = samples.back().handle;
+    }
+    return summary;
+}
+
+void QdiscStatsSummary::add_to_json(JsonObject& obj, int64_t interval_ms) const {
+    if (samples == 0) {
+        return;
+    }
+    obj["qdisc_stats_interval_ms"] = interval_ms;
+    obj["qdisc_stats_samples"] = samples;
+    obj["qdisc_kind"] = kind;
+    obj["qdisc_handle"] = handle;
+    obj["qdisc_offset_series"] = offset_series;
+    obj["qdisc_backlog_series"] = backlog_series;
+    obj["qdisc_qlen_series"] = qlen_series;
+    obj["qdisc_drops_series"] = drops_series;
+    obj["qdisc_requeues_series"] = requeues_series;
+    obj["qdisc_drops_delta"] = drops;
+    obj["qdisc_requeues_delta"] = requeues;
+    obj["qdisc_sent_packets_delta"] = sent_packets;
+    obj["qdisc_max_backlog"] = max_backlog;
+}
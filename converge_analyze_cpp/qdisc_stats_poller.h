@@ -0,0 +1,81 @@
+#pragma once
+
+#include "logger.h"
+#include <atomic>
+#include <functional>
+#include <string>
+#include <thread>
+#include <vector>
+
+// 某一时刻一个qdisc的统计(TCA_STATS2)，计数为qdisc创建以来的累计值
+struct QdiscStats {
+    int64_t timestamp_ms = 0;
+    std::string interface;
+    std::string kind;
+    std::string handle;  // 如 "8001:"
+    bool root = false;
+    int64_t qlen = 0;
+    int64_t backlog = 0;  // 字节
+    int64_t drops = 0;
+    int64_t requeues = 0;
+    int64_t overlimits = 0;
+    int64_t bytes = 0;
+    int64_t packets = 0;
+};
+
+// 一个会话的qdisc统计时间序列：偏移相对触发，丢包与重新入队为会话内的累计值；
+// 会话中qdisc被替换(handle变化)时新qdisc的计数从其创建时算起
+struct QdiscStatsSummary {
+    std::string kind;    // 最后一次采样的qdisc
+    std::string handle;
+    int64_t samples = 0;
+    int64_t drops = 0;
+    int64_t requeues = 0;
+    int64_t sent_packets = 0;
+    int64_t max_backlog = 0;
+    std::string offset_series;
+    std::string backlog_series;
+    std::string qlen_series;
+    std::string drops_series;
+    std::string requeues_series;
+
+    static QdiscStatsSummary summarize(const std::vector<QdiscStats>& samples, int64_t trigger_ms);
+    // 写入session_completed的qdisc_*字段，没有采样时不写
+    void add_to_json(JsonObject& obj, int64_t interval_ms) const;
+};
+
+// 会话期间按固定间隔读取触发接口上qdisc的统计(--qdisc-stats-ms)，把损伤的实际效果
+// (排队、丢包)与收敛时间放在同一时间轴上；只在有进行中的会话时读取
+class QdiscStatsPoller {
+public:
+    using Callback = std::function<void(const QdiscStats&)>;
+    // 当前会话的触发接口，为空时不读取
+    using InterfaceSource = std::function<std::string()>;
+    // 一个会话最多保留的采样数，长时间不收敛的会话只保留前面部分
+    static constexpr size_t MAX_SESSION_SAMPLES = 1000;
+
+private:
+    int64_t interval_ms_;
+    InterfaceSource interface_;
+    Callback callback_;
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+
+    void worker_loop();
+
+public:
+    QdiscStatsPoller(int64_t interval_ms, InterfaceSource interface, Callback callback);
+    ~QdiscStatsPoller();
+
+    QdiscStatsPoller(const QdiscStatsPoller&) = delete;
+    QdiscStatsPoller& operator=(const QdiscStatsPoller&) = delete;
+
+    void start();
+    void stop();
+
+    // dump接口上的全部qdisc(RTM_GETQDISC)
+    static std::vector<QdiscStats> read(const std::string& interface, std::string& error);
+
+    // 优先取netem，接口上没有netem时取根qdisc；都没有时返回nullptr
+    static const QdiscStats* select(const std::vector<QdiscStats>& qdiscs);
+};
@@ -0,0 +1,144 @@
+#include "query.h"
+#include <getopt.h>
+#include <iostream>
+
+bool SessionFilter::matches(const ReportSession& session) const {
+    if (!session.completed) {
+        return false;
+    }
+    if (!interface.empty() && session.interface() != interface) {
+        return false;
+    }
+    if (!router_name.empty() && session.router_name != router_name) {
+        return false;
+    }
+    if (!trigger_source.empty() && session.trigger_source != trigger_source) {
+        return false;
+    }
+
+    // 强制结束的会话没有收敛时间，不匹配收敛时间范围
+    if ((min_convergence_ms.has_value() || max_convergence_ms.has_value()) &&
+        !session.convergence_time_ms.has_value()) {
+        return false;
+    }
+    int64_t convergence = session.convergence_time_ms.value_or(0);
+    if (min_convergence_ms.has_value() && convergence < min_convergence_ms.value()) {
+        return false;
+    }
+    if (max_convergence_ms.has_value() && convergence > max_convergence_ms.value()) {
+        return false;
+    }
+    if (timed_out.has_value() && session.timed_out != timed_out.value()) {
+        return false;
+    }
+    if (!campaign_step.empty() && session.campaign_step != campaign_step) {
+        return false;
+    }
+    return true;
+}
+
+ReportData filter_sessions(const ReportData& data, const SessionFilter& filter) {
+    ReportData filtered = data;
+    filtered.sessions.clear();
+    for (const auto& session : data.sessions) {
+        if (filter.matches(session)) {
+            filtered.sessions.push_back(session);
+        }
+    }
+    return filtered;
+}
+
+namespace {
+
+void print_query_usage(const char* program_name) {
+    std::cout << "用法: " << program_name << " query --input LOG [过滤条件] [--format markdown|ndjson]\n\n";
+    std::cout << "按条件筛选已有日志中的会话并重新汇总\n\n";
+    std::cout << "选项:\n";
+    std::cout << "  -i, --input PATH            输入日志文件(NDJSON)\n";
+    std::cout << "      --interface NAME        触发接口\n";
+    std::cout << "      --router NAME           路由器名称\n";
+    std::cout << "      --trigger-source SRC    触发来源(netem/route)\n";
+    std::cout << "      --min-convergence MS    收敛时间下限\n";
+    std::cout << "      --max-convergence MS    收敛时间上限\n";
+    std::cout << "      --timed-out             仅超时会话\n";
+    std::cout << "      --campaign-step ID      仅指定故障计划步骤产生的会话\n";
+    std::cout << "  -f, --format FORMAT         输出格式: markdown(默认)、ndjson(每行一个会话)\n";
+    std::cout << "  -h, --help                  显示此帮助信息\n";
+}
+
+enum QueryOption {
+    OPT_INTERFACE = 1000,
+    OPT_ROUTER,
+    OPT_TRIGGER_SOURCE,
+    OPT_MIN_CONVERGENCE,
+    OPT_MAX_CONVERGENCE,
+    OPT_TIMED_OUT,
+    OPT_CAMPAIGN_STEP,
+};
+
+} // namespace
+
+int query_main(int argc, char* argv[]) {
+    std::string input_path;
+    std::string format = "markdown";
+    SessionFilter filter;
+
+    static struct option long_options[] = {
+        {"input", required_argument, 0, 'i'},
+        {"interface", required_argument, 0, OPT_INTERFACE},
+        {"router", required_argument, 0, OPT_ROUTER},
+        {"trigger-source", required_argument, 0, OPT_TRIGGER_SOURCE},
+        {"min-convergence", required_argument, 0, OPT_MIN_CONVERGENCE},
+        {"max-convergence", required_argument, 0, OPT_MAX_CONVERGENCE},
+        {"timed-out", no_argument, 0, OPT_TIMED_OUT},
+        {"campaign-step", required_argument, 0, OPT_CAMPAIGN_STEP},
+        {"format", required_argument, 0, 'f'},
+        {"help", no_argument, 0, 'h'},
+        {0, 0, 0, 0}
+    };
+
+    optind = 1;
+    int c;
+    while ((c = getopt_long(argc, argv, "i:f:h", long_options, nullptr)) != -1) {
+        switch (c) {
+            case 'i': input_path = optarg; break;
+            case 'f': format = optarg; break;
+            case OPT_INTERFACE: filter.interface = optarg; break;
+            case OPT_ROUTER: filter.router_name = optarg; break;
+            case OPT_TRIGGER_SOURCE: filter.trigger_source = optarg; break;
+            case OPT_MIN_CONVERGENCE: filter.min_convergence_ms = std::stoll(optarg); break;
+            case OPT_MAX_CONVERGENCE: filter.max_convergence_ms = std::stoll(optarg); break;
+            case OPT_TIMED_OUT: filter.timed_out = true; break;
+            case OPT_CAMPAIGN_STEP: filter.campaign_step = optarg; break;
+            case 'h': print_query_usage(argv[0]); return 0;
+            default:  print_query_usage(argv[0]); return 1;
+        }
+    }
+
+    if (input_path.empty()) {
+        std::cerr << "❌ 错误: 必须指定 --input\n";
+        return 1;
+    }
+
+    ReportData data;
+    std::string error;
+    if (!ConvergenceReport::load(input_path, data, error)) {
+        std::cerr << "❌ " << error << "\n";
+        return 1;
+    }
+
+    ReportData filtered = filter_sessions(data, filter);
+
+    if (format == "ndjson") {
+        for (const auto& session : filtered.sessions) {
+            std::cout << Logger::json_to_string(ConvergenceReport::session_to_json(session)) << "\n";
+        }
+    } else if (format == "markdown" || format == "md") {
+        std::cout << ConvergenceReport::render_markdown(filtered);
+    } else {
+        std::cerr << "❌ 错误: 不支持的输出格式 " << format << "\n";
+        return 1;
+    }
+
+    return 0;
+}
@@ -0,0 +1,24 @@
+#pragma once
+
+#include <optional>
+#include <string>
+#include "report.h"
+
+// 会话过滤条件，未设置的条件不参与过滤
+struct SessionFilter {
+    std::string interface;
+    std::string router_name;
+    std::string trigger_source;
+    std::optional<int64_t> min_convergence_ms;
+    std::optional<int64_t> max_convergence_ms;
+    std::optional<bool> timed_out;
+    std::string campaign_step;
+
+    bool matches(const ReportSession& session) const;
+};
+
+// 返回只包含匹配会话的新数据集
+ReportData filter_sessions(const ReportData& data, const SessionFilter& filter);
+
+// query 子命令入口
+int query_main(int argc, char* argv[]);
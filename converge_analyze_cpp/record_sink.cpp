@@ -0,0 +1,186 @@
+#include "record_sink.h"
+#include "debug_log.h"
+#include "grafana_api.h"
+#include "grpc_server.h"
+#include "http_client.h"
+#include "influx_writer.h"
+#include "kafka_producer.h"
+#include "otlp_exporter.h"
+#include "parquet_exporter.h"
+#include "prometheus_exporter.h"
+#include "syslog_sink.h"
+#include <iostream>
+
+namespace {
+
+const std::string FILE_SCHEME = "file://";
+
+} // namespace
+
+std::unique_ptr<RecordSink> RecordSink::create(const std::string& url, const SinkOptions& options) {
+    std::vector<std::pair<std::string, std::string>> bootstrap;
+    std::string topic, endpoint, directory, address;
+    int port = 0;
+    if (url == "stdout" || url == "-") {
+        return std::make_unique<StdoutSink>();
+    }
+    if (url.compare(0, FILE_SCHEME.size(), FILE_SCHEME) == 0 && url.size() > FILE_SCHEME.size()) {
+        return std::make_unique<FileSink>(url.substr(FILE_SCHEME.size()));
+    }
+    if (std::string host, port_text, path; url.compare(0, 7, "http://") == 0 &&
+                                            HttpClient::parse_url(url, host, port_text, path)) {
+        return std::make_unique<HttpSink>(url);
+    }
+    if (KafkaProducer::parse_url(url, bootstrap, topic)) {
+        return std::make_unique<KafkaProducer>(bootstrap, topic, options.key_field);
+    }
+    if (InfluxWriter::is_influx_url(url)) {
+        return std::make_unique<InfluxWriter>(url, options.tag_keys);
+    }
+    if (OtlpTraceExporter::parse_url(url, endpoint)) {
+        return std::make_unique<OtlpTraceExporter>(url, options.tag_keys);
+    }
+    if (ParquetExporter::parse_url(url, directory)) {
+        return std::make_unique<ParquetExporter>(url, options.monitor_id, options.tag_keys);
+    }
+    if (PrometheusExporter::parse_url(url, address, port)) {
+        return std::make_unique<PrometheusExporter>(address, port);
+    }
+    if (GrafanaApi::parse_url(url, address, port)) {
+        return std::make_unique<GrafanaApi>(address, port);
+    }
+    if (GrpcStreamServer::parse_url(url, address, port)) {
+        return std::make_unique<GrpcStreamServer>(address, port, options.monitor_id);
+    }
+    if (SyslogSink::Target target; SyslogSink::parse_url(url, target)) {
+        return std::make_unique<SyslogSink>(target);
+    }
+    return nullptr;
+}
+
+bool RecordSink::is_supported(const std::string& url) {
+    return create(url, SinkOptions{}) != nullptr;
+}
+
+bool FileSink::start(std::string& error) {
+    file_.open(path_, std::ios::out | std::ios::app);
+    if (!file_.is_open()) {
+        error = "无法打开文件: " + path_;
+        return false;
+    }
+    return true;
+}
+
+void FileSink::write_record(const JsonObject&, const std::string& line) {
+    std::lock_guard<std::mutex> lock(file_mutex_);
+    if (file_.is_open()) {
+        file_ << line << "\n";
+        file_.flush();
+        written_count_.fetch_add(1);
+    }
+}
+
+void FileSink::stop() {
+    std::lock_guard<std::mutex> lock(file_mutex_);
+    if (file_.is_open()) {
+        file_.close();
+    }
+}
+
+std::string FileSink::summary() const {
+    return "文件: 已写入 " + std::to_string(written_count_.load()) + " 条记录到 " + path_;
+}
+
+void StdoutSink::write_record(const JsonObject&, const std::string& line) {
+    std::lock_guard<std::mutex> lock(output_mutex_);
+    machine_out() << line << "\n";
+    machine_out().flush();
+    // 下游关闭管道后写入失败，不再计数
+    if (machine_out().good()) {
+        written_count_.fetch_add(1);
+    }
+}
+
+std::string StdoutSink::summary() const {
+    return "stdout: 已输出 " + std::to_string(written_count_.load()) + " 条记录";
+}
+
+HttpSink::~HttpSink() {
+    stop();
+}
+
+bool HttpSink::start(std::string&) {
+    if (running_.load()) {
+        return true;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&HttpSink::worker_loop, this);
+    return true;
+}
+
+void HttpSink::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    queue_cv_.notify_all();
+
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+}
+
+void HttpSink::write_record(const JsonObject&, const std::string& line) {
+    std::unique_lock<std::mutex> lock(queue_mutex_);
+    if (pending_.size() >= MAX_PENDING_LINES) {
+        pending_.pop();
+        failed_count_.fetch_add(1);
+    }
+    pending_.push(line);
+    lock.unlock();
+
+    queue_cv_.notify_one();
+}
+
+void HttpSink::worker_loop() {
+    std::unique_lock<std::mutex> lock(queue_mutex_);
+
+    while (running_.load() || !pending_.empty()) {
+        queue_cv_.wait(lock, [this] {
+            return !pending_.empty() || !running_.load();
+        });
+
+        while (!pending_.empty()) {
+            std::string body;
+            size_t lines = 0;
+            while (!pending_.empty() && lines < MAX_BATCH_LINES) {
+                body += pending_.front() + "\n";
+                pending_.pop();
+                lines++;
+            }
+            lock.unlock();
+
+            auto response = HttpClient::post(url_, body, "application/x-ndjson");
+            if (response.ok()) {
+                sent_count_.fetch_add(static_cast<int64_t>(lines));
+            } else {
+                failed_count_.fetch_add(static_cast<int64_t>(lines));
+                std::cerr << "⚠️  HTTP输出失败: "
+                          << (response.error.empty() ? "HTTP " + std::to_string(response.status_code) : response.error)
+                          << "\n";
+            }
+
+            lock.lock();
+        }
+    }
+}
+
+std::string HttpSink::summary() const {
+    std::string text = "HTTP: 已发送 " + std::to_string(sent_count_.load()) + " 条记录";
+    if (failed_count_.load() > 0) {
+        text += "，失败 " + std::to_string(failed_count_.load()) + " 条";
+    }
+    return text;
+}
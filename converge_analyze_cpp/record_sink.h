@@ -0,0 +1,100 @@
+#pragma once
+
+#include <atomic>
+#include <condition_variable>
+#include <fstream>
+#include <memory>
+#include <mutex>
+#include <queue>
+#include <string>
+#include <thread>
+#include <vector>
+#include "logger.h"
+
+// 创建sink时的公共参数
+struct SinkOptions {
+    std::string monitor_id;
+    std::vector<std::string> tag_keys;      // --tag的键
+    std::string key_field = "router_name";  // Kafka消息key取自的记录字段，为空则不设置key
+};
+
+// 记录输出目标。Logger把每条记录写入本地JSON日志后，按注册顺序分发给所有sink
+// 生命周期: start() -> write_record()... -> stop()，stop()在Logger停止之后调用
+class RecordSink {
+public:
+    virtual ~RecordSink() = default;
+
+    virtual bool start(std::string& error) = 0;
+    // 在日志线程或log_sync调用方中执行，耗时操作应交给sink自己的线程
+    virtual void write_record(const JsonObject& record, const std::string& line) = 0;
+    // 停止前尽量写完缓冲的数据
+    virtual void stop() = 0;
+    // 退出时打印的统计，如 "Kafka: 已发送 10 条记录"
+    virtual std::string summary() const = 0;
+
+    // 根据URL创建sink，不支持的URL返回nullptr
+    static std::unique_ptr<RecordSink> create(const std::string& url, const SinkOptions& options);
+    // 只检查URL是否受支持(命令行参数校验)
+    static bool is_supported(const std::string& url);
+};
+
+// 追加写入另一个NDJSON文件: file:///PATH
+class FileSink : public RecordSink {
+private:
+    std::string path_;
+    std::ofstream file_;
+    std::mutex file_mutex_;
+    std::atomic<int64_t> written_count_{0};
+
+public:
+    explicit FileSink(const std::string& path) : path_(path) {}
+
+    bool start(std::string& error) override;
+    void write_record(const JsonObject& record, const std::string& line) override;
+    void stop() override;
+    std::string summary() const override;
+};
+
+// 将每条记录作为一行JSON打印到标准输出: stdout
+class StdoutSink : public RecordSink {
+private:
+    std::mutex output_mutex_;
+    std::atomic<int64_t> written_count_{0};
+
+public:
+    bool start(std::string&) override { return true; }
+    void write_record(const JsonObject& record, const std::string& line) override;
+    void stop() override {}
+    std::string summary() const override;
+};
+
+// 以NDJSON批量POST到HTTP收集端: http://HOST:PORT/PATH
+class HttpSink : public RecordSink {
+private:
+    std::string url_;
+    std::queue<std::string> pending_;
+    std::mutex queue_mutex_;
+    std::condition_variable queue_cv_;
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+
+    std::atomic<int64_t> sent_count_{0};
+    std::atomic<int64_t> failed_count_{0};
+
+    static constexpr size_t MAX_PENDING_LINES = 10000;
+    static constexpr size_t MAX_BATCH_LINES = 500;
+
+    void worker_loop();
+
+public:
+    explicit HttpSink(const std::string& url) : url_(url) {}
+    ~HttpSink() override;
+
+    HttpSink(const HttpSink&) = delete;
+    HttpSink& operator=(const HttpSink&) = delete;
+
+    bool start(std::string& error) override;
+    void write_record(const JsonObject& record, const std::string& line) override;
+    void stop() override;
+    std::string summary() const override;
+};
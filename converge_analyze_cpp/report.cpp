@@ -0,0 +1,840 @@
+#include "report.h"
+#include "log_reader.h"
+#include <algorithm>
+#include <cmath>
+#include <fstream>
+#include <getopt.h>
+#include <iomanip>
+#include <iostream>
+#include <map>
+#include <numeric>
+#include <sstream>
+#include <sys/stat.h>
+
+std::string ReportSession::interface() const {
+    auto it = trigger_info.find("interface");
+    if (it == trigger_info.end() || it->second.empty()) {
+        return "N/A";
+    }
+    return it->second;
+}
+
+bool ConvergenceReport::load(const std::string& path, ReportData& data, std::string& error) {
+    std::vector<JsonObject> records;
+    int malformed = 0;
+    if (!LogReader::read_file(path, records, malformed, error)) {
+        return false;
+    }
+
+    data.source_path = path;
+    data.malformed_lines = malformed;
+    build(records, data);
+    return true;
+}
+
+void ConvergenceReport::build(const std::vector<JsonObject>& records, ReportData& data) {
+    // 同一日志文件可能追加了多次运行，按 (运行序号, 会话ID) 区分
+    std::map<std::pair<int, int>, size_t> index;
+    int run_index = 0;
+
+    // 未关联到会话的IGP邻接变化(通常早于路由触发)，全部会话建立后按时间回溯关联
+    struct PendingIgpEvent {
+        int run_index;
+        std::string router;
+        int64_t time_ms;
+        ReportEvent event;
+    };
+    std::vector<PendingIgpEvent> pending_igp;
+
+    auto session_for = [&](const JsonObject& record) -> ReportSession& {
+        int session_id = static_cast<int>(LogReader::get_int(record, "session_id"));
+        auto key = std::make_pair(run_index, session_id);
+        auto it = index.find(key);
+        if (it == index.end()) {
+            ReportSession session;
+            session.run_index = run_index;
+            session.session_id = session_id;
+            session.router_name = LogReader::get_string(record, "router_name");
+            data.sessions.push_back(std::move(session));
+            it = index.emplace(key, data.sessions.size() - 1).first;
+        }
+        return data.sessions[it->second];
+    };
+
+    for (const auto& record : records) {
+        std::string event_type = LogReader::get_string(record, "event_type");
+        std::string router = LogReader::get_string(record, "router_name");
+
+        if (event_type == "monitoring_started") {
+            run_index++;
+            if (std::find(data.routers.begin(), data.routers.end(), router) == data.routers.end()) {
+                data.routers.push_back(router);
+            }
+            data.convergence_threshold_ms = LogReader::get_int(record, "convergence_threshold_ms",
+                                                               data.convergence_threshold_ms);
+        } else if (event_type == "clock_offset") {
+            ReportClockOffset offset;
+            offset.router_name = router;
+            offset.time_ms = LogReader::parse_timestamp_ms(LogReader::get_timestamp(record, "timestamp"));
+            offset.offset_ms = LogReader::get_double(record, "offset_ms");
+            offset.rtt_ms = LogReader::get_double(record, "rtt_ms");
+            if (offset.time_ms >= 0) {
+                data.clock_offsets.push_back(offset);
+            }
+        } else if (event_type == "monitoring_completed") {
+            data.total_listen_duration_ms += LogReader::get_int(record, "total_listen_duration_ms");
+        } else if (event_type == "session_started") {
+            auto& session = session_for(record);
+            session.start_timestamp = LogReader::get_timestamp(record, "timestamp");
+            session.start_time_ms = LogReader::parse_timestamp_ms(session.start_timestamp);
+            session.trigger_source = LogReader::get_string(record, "trigger_source");
+            session.trigger_event_type = LogReader::get_string(record, "trigger_event_type");
+            session.trigger_info = LogReader::parse_string_map(
+                LogReader::get_string(record, "trigger_info"));
+            session.campaign_step = LogReader::get_string(record, "campaign_step");
+        } else if (event_type == "route_event") {
+            auto& session = session_for(record);
+            ReportEvent event;
+            event.offset_ms = LogReader::get_int(record, "offset_from_trigger_ms");
+            event.type = LogReader::get_string(record, "route_event_type");
+            if (LogReader::has(record, "coalesced_count")) {
+                event.type += " x" + std::to_string(LogReader::get_int(record, "coalesced_count"));
+            }
+            event.info = LogReader::parse_string_map(LogReader::get_string(record, "route_info"));
+            session.events.push_back(std::move(event));
+        } else if (event_type == "frr_log_event") {
+            if (!LogReader::has(record, "session_id")) {
+                continue;
+            }
+            auto& session = session_for(record);
+            ReportEvent event;
+            event.offset_ms = LogReader::get_int(record, "offset_from_trigger_ms");
+            event.type = "FRR " + LogReader::get_string(record, "category");
+            event.info["daemon"] = LogReader::get_string(record, "daemon");
+            event.info["message"] = LogReader::get_string(record, "message");
+            session.events.push_back(std::move(event));
+        } else if (event_type == "bgp_event") {
+            if (!LogReader::has(record, "session_id")) {
+                continue;
+            }
+            auto& session = session_for(record);
+            ReportEvent event;
+            event.offset_ms = LogReader::get_int(record, "offset_from_trigger_ms");
+            event.type = "BGP " + LogReader::get_string(record, "bmp_message_type");
+            event.info["peer"] = LogReader::get_string(record, "peer_address");
+            event.info["message"] = "peer " + event.info["peer"] +
+                " +" + std::to_string(LogReader::get_int(record, "announced_count")) +
+                " -" + std::to_string(LogReader::get_int(record, "withdrawn_count"));
+            session.events.push_back(std::move(event));
+        } else if (event_type == "link_event") {
+            if (!LogReader::has(record, "session_id")) {
+                continue;
+            }
+            auto& session = session_for(record);
+            ReportEvent event;
+            event.offset_ms = LogReader::get_int(record, "offset_from_trigger_ms");
+            event.type = LogReader::get_string(record, "link_event_type");
+            event.info["interface"] = LogReader::get_string(record, "interface");
+            for (const char* key : {"mtu", "previous_mtu", "promisc", "previous_promisc", "master", "previous_master"}) {
+                if (LogReader::has(record, key)) {
+                    event.info[key] = LogReader::get_string(record, key);
+                }
+            }
+            session.events.push_back(std::move(event));
+        } else if (event_type == "micro_loop") {
+            if (!LogReader::has(record, "session_id")) {
+                continue;
+            }
+            auto& session = session_for(record);
+            ReportEvent event;
+            event.offset_ms = LogReader::get_int(record, "offset_from_trigger_ms");
+            event.type = "micro_loop " + LogReader::get_string(record, "phase");
+            event.info["target"] = LogReader::get_string(record, "target");
+            event.info["loop"] = LogReader::get_string(record, "loop");
+            if (LogReader::has(record, "loop_duration_ms")) {
+                event.info["loop_duration_ms"] = std::to_string(LogReader::get_int(record, "loop_duration_ms"));
+            }
+            session.events.push_back(std::move(event));
+        } else if (event_type == "igp_adjacency_event") {
+            ReportEvent event;
+            event.type = "IGP " + LogReader::get_string(record, "protocol") + " " +
+                LogReader::get_string(record, "old_state") + "->" + LogReader::get_string(record, "new_state");
+            event.info["neighbor"] = LogReader::get_string(record, "neighbor");
+            event.info["interface"] = LogReader::get_string(record, "interface");
+            event.info["message"] = "neighbor " + event.info["neighbor"] + " on " + event.info["interface"];
+            if (LogReader::has(record, "session_id")) {
+                event.offset_ms = LogReader::get_int(record, "offset_from_trigger_ms");
+                session_for(record).events.push_back(std::move(event));
+            } else {
+                int64_t time_ms = LogReader::parse_timestamp_ms(LogReader::get_timestamp(record, "timestamp"));
+                if (time_ms >= 0) {
+                    pending_igp.push_back({run_index, router, time_ms, std::move(event)});
+                }
+            }
+        } else if (event_type == "session_completed") {
+            auto& session = session_for(record);
+            session.completed = true;
+            if (LogReader::has(record, "convergence_time_ms")) {
+                session.convergence_time_ms = LogReader::get_int(record, "convergence_time_ms");
+            }
+            session.route_events = static_cast<int>(LogReader::get_int(record, "route_events_count"));
+            session.duration_ms = LogReader::get_int(record, "session_duration_ms");
+            session.timed_out = LogReader::get_bool(record, "timed_out");
+            session.forced = LogReader::get_bool(record, "forced");
+            session.churn_per_second.clear();
+            std::istringstream series(LogReader::get_string(record, "churn_per_second"));
+            std::string count;
+            while (std::getline(series, count, ',')) {
+                if (!count.empty()) {
+                    session.churn_per_second.push_back(std::stoll(count));
+                }
+            }
+            if (session.trigger_info.empty()) {
+                session.trigger_info = LogReader::parse_string_map(
+                    LogReader::get_string(record, "netem_info"));
+            }
+        }
+    }
+
+    // 触发前一个收敛阈值窗口内的邻接变化视为该会话的故障检测
+    for (auto& pending : pending_igp) {
+        for (auto& session : data.sessions) {
+            if (session.run_index != pending.run_index || session.router_name != pending.router ||
+                session.start_time_ms < 0) {
+                continue;
+            }
+            int64_t offset = pending.time_ms - session.start_time_ms;
+            if (offset <= 0 && offset >= -data.convergence_threshold_ms) {
+                pending.event.offset_ms = offset;
+                session.events.push_back(pending.event);
+                break;
+            }
+        }
+    }
+}
+
+DistributionStats ConvergenceReport::compute_stats(std::vector<double> values) {
+    DistributionStats stats;
+    if (values.empty()) {
+        return stats;
+    }
+
+    std::sort(values.begin(), values.end());
+    stats.count = values.size();
+    stats.min = values.front();
+    stats.max = values.back();
+    stats.mean = std::accumulate(values.begin(), values.end(), 0.0) / values.size();
+
+    size_t mid = values.size() / 2;
+    stats.median = (values.size() % 2 == 0) ? (values[mid - 1] + values[mid]) / 2.0 : values[mid];
+
+    // 最近秩法
+    size_t rank = static_cast<size_t>(std::ceil(0.95 * values.size()));
+    stats.p95 = values[std::max<size_t>(rank, 1) - 1];
+
+    double sq_sum = 0.0;
+    for (double v : values) {
+        sq_sum += (v - stats.mean) * (v - stats.mean);
+    }
+    stats.stddev = std::sqrt(sq_sum / values.size());
+
+    return stats;
+}
+
+std::vector<double> ConvergenceReport::convergence_times(const std::vector<ReportSession>& sessions) {
+    std::vector<double> times;
+    for (const auto& session : sessions) {
+        if (session.completed && !session.forced && session.convergence_time_ms.has_value()) {
+            times.push_back(static_cast<double>(session.convergence_time_ms.value()));
+        }
+    }
+    return times;
+}
+
+ConvergencePhases ConvergenceReport::compute_phases(const ReportSession& session) {
+    ConvergencePhases phases;
+    for (const auto& event : session.events) {
+        if (event.type.compare(0, 4, "IGP ") == 0) {
+            if (!phases.detection_ms.has_value() || event.offset_ms < phases.detection_ms.value()) {
+                phases.detection_ms = event.offset_ms;
+            }
+        } else if (event.type == "FRR spf_end") {
+            if (!phases.spf_done_ms.has_value() || event.offset_ms > phases.spf_done_ms.value()) {
+                phases.spf_done_ms = event.offset_ms;
+            }
+        }
+    }
+    phases.fib_done_ms = session.convergence_time_ms;
+    return phases;
+}
+
+JsonObject ConvergenceReport::session_to_json(const ReportSession& session) {
+    JsonObject obj;
+    obj["router_name"] = session.router_name;
+    obj["session_id"] = static_cast<int64_t>(session.session_id);
+    obj["start_timestamp"] = session.start_timestamp;
+    obj["trigger_source"] = session.trigger_source;
+    obj["trigger_event_type"] = session.trigger_event_type;
+    obj["interface"] = session.interface();
+    auto link_it = session.trigger_info.find("link");
+    if (link_it != session.trigger_info.end()) {
+        obj["link"] = link_it->second;
+    }
+    if (session.convergence_time_ms.has_value()) {
+        obj["convergence_time_ms"] = session.convergence_time_ms.value();
+    }
+    obj["route_events_count"] = static_cast<int64_t>(session.route_events);
+    obj["session_duration_ms"] = session.duration_ms;
+    obj["timed_out"] = session.timed_out;
+    if (session.forced) {
+        obj["forced"] = true;
+    }
+    if (!session.campaign_step.empty()) {
+        obj["campaign_step"] = session.campaign_step;
+    }
+    auto phases = compute_phases(session);
+    if (phases.detection_ms.has_value()) {
+        obj["igp_detection_ms"] = phases.detection_ms.value();
+    }
+    if (phases.spf_done_ms.has_value()) {
+        obj["spf_done_ms"] = phases.spf_done_ms.value();
+    }
+    return obj;
+}
+
+namespace {
+
+std::string escape_html(const std::string& str) {
+    std::string escaped;
+    escaped.reserve(str.size());
+    for (char c : str) {
+        switch (c) {
+            case '&': escaped += "&amp;"; break;
+            case '<': escaped += "&lt;"; break;
+            case '>': escaped += "&gt;"; break;
+            case '"': escaped += "&quot;"; break;
+            default:  escaped += c; break;
+        }
+    }
+    return escaped;
+}
+
+std::string fmt(double value) {
+    std::ostringstream oss;
+    oss << std::fixed << std::setprecision(1) << value;
+    return oss.str();
+}
+
+void render_stats_row(std::ostringstream& html, const std::string& label, const DistributionStats& s) {
+    html << "<tr><td>" << escape_html(label) << "</td><td>" << s.count << "</td><td>"
+         << fmt(s.min) << "</td><td>" << fmt(s.mean) << "</td><td>" << fmt(s.median)
+         << "</td><td>" << fmt(s.p95) << "</td><td>" << fmt(s.max) << "</td><td>"
+         << fmt(s.stddev) << "</td></tr>\n";
+}
+
+// 每个会话收敛时间的柱状图
+void render_bar_chart(std::ostringstream& html, const std::vector<ReportSession>& sessions) {
+    const int width = 900, height = 240, margin = 30;
+    double max_value = 1.0;
+    for (const auto& s : sessions) {
+        max_value = std::max(max_value, static_cast<double>(s.convergence_time_ms.value_or(0)));
+    }
+
+    double bar_width = sessions.empty() ? 0.0 : static_cast<double>(width - 2 * margin) / sessions.size();
+    html << "<svg width=\"" << width << "\" height=\"" << height << "\" class=\"chart\">\n";
+    html << "<line x1=\"" << margin << "\" y1=\"" << height - margin << "\" x2=\"" << width - margin
+         << "\" y2=\"" << height - margin << "\" stroke=\"#888\"/>\n";
+    html << "<text x=\"2\" y=\"" << margin - 10 << "\" font-size=\"11\">" << fmt(max_value) << " ms</text>\n";
+
+    for (size_t i = 0; i < sessions.size(); ++i) {
+        const auto& s = sessions[i];
+        double value = static_cast<double>(s.convergence_time_ms.value_or(0));
+        double h = (height - 2 * margin) * value / max_value;
+        double x = margin + i * bar_width;
+        html << "<rect x=\"" << fmt(x + 1) << "\" y=\"" << fmt(height - margin - h)
+             << "\" width=\"" << fmt(std::max(bar_width - 2, 1.0)) << "\" height=\"" << fmt(h)
+             << "\" fill=\"" << (s.timed_out ? "#d9534f" : "#4a90d9") << "\"><title>#"
+             << s.session_id << ": " << value << " ms</title></rect>\n";
+    }
+    html << "</svg>\n";
+}
+
+// 单个会话的事件时间线
+void render_timeline(std::ostringstream& html, const ReportSession& session) {
+    const int width = 900, height = 40, margin = 10;
+    // IGP邻接变化可能早于触发，时间轴从最早的事件开始
+    int64_t origin = 0;
+    for (const auto& event : session.events) {
+        origin = std::min(origin, event.offset_ms);
+    }
+    double span = std::max<double>(1.0, static_cast<double>(
+        std::max<int64_t>(session.duration_ms, session.convergence_time_ms.value_or(0)) - origin));
+    auto position = [&](int64_t offset) {
+        return margin + (width - 2 * margin) * (offset - origin) / span;
+    };
+
+    html << "<svg width=\"" << width << "\" height=\"" << height << "\" class=\"timeline\">\n";
+    html << "<line x1=\"" << margin << "\" y1=\"20\" x2=\"" << width - margin
+         << "\" y2=\"20\" stroke=\"#bbb\"/>\n";
+    html << "<circle cx=\"" << fmt(position(0)) << "\" cy=\"20\" r=\"5\" fill=\"#d9534f\"><title>trigger</title></circle>\n";
+
+    for (const auto& event : session.events) {
+        double x = position(event.offset_ms);
+        // 内核路由事件为蓝色，FRR日志为橙色，BMP事件为紫色，IGP邻接变化为青色
+        bool frr = event.type.compare(0, 4, "FRR ") == 0;
+        bool bgp = event.type.compare(0, 4, "BGP ") == 0;
+        bool igp = event.type.compare(0, 4, "IGP ") == 0;
+        html << "<circle cx=\"" << fmt(x) << "\" cy=\"20\" r=\"3\" fill=\""
+             << (frr ? "#f0ad4e" : bgp ? "#8e44ad" : igp ? "#17a2b8" : "#4a90d9") << "\"><title>"
+             << (event.offset_ms >= 0 ? "+" : "") << event.offset_ms << "ms " << escape_html(event.type);
+        auto message_it = event.info.find("message");
+        if ((frr || bgp || igp) && message_it != event.info.end()) {
+            html << " " << escape_html(message_it->second);
+        }
+        html << "</title></circle>\n";
+    }
+
+    if (session.convergence_time_ms.has_value()) {
+        double x = position(session.convergence_time_ms.value());
+        html << "<line x1=\"" << fmt(x) << "\" y1=\"5\" x2=\"" << fmt(x)
+             << "\" y2=\"35\" stroke=\"#5cb85c\" stroke-width=\"2\"><title>converged +"
+             << session.convergence_time_ms.value() << "ms</title></line>\n";
+    }
+    html << "</svg>\n";
+}
+
+// 会话内每秒路由事件数，显示收敛的突发与长尾
+void render_churn_chart(std::ostringstream& html, const ReportSession& session) {
+    const int width = 900, height = 60, margin = 10;
+    int64_t peak = *std::max_element(session.churn_per_second.begin(), session.churn_per_second.end());
+    double bar_width = static_cast<double>(width - 2 * margin) / session.churn_per_second.size();
+
+    html << "<svg width=\"" << width << "\" height=\"" << height << "\" class=\"chart\">\n";
+    for (size_t i = 0; i < session.churn_per_second.size(); ++i) {
+        int64_t count = session.churn_per_second[i];
+        double h = peak > 0 ? (height - 2 * margin) * static_cast<double>(count) / peak : 0.0;
+        double x = margin + i * bar_width;
+        html << "<rect x=\"" << fmt(x + 1) << "\" y=\"" << fmt(height - margin - h)
+             << "\" width=\"" << fmt(std::max(bar_width - 2, 1.0)) << "\" height=\"" << fmt(h)
+             << "\" fill=\"#4a90d9\"><title>+" << i << "s: " << count << " events</title></rect>\n";
+    }
+    html << "</svg>\n";
+}
+
+std::string churn_series(const std::vector<int64_t>& counts) {
+    std::string text;
+    for (size_t i = 0; i < counts.size(); ++i) {
+        text += (i ? ", " : "") + std::to_string(counts[i]);
+    }
+    return text;
+}
+
+std::string phase_cell(const std::optional<int64_t>& value) {
+    return value.has_value() ? std::to_string(value.value()) : "-";
+}
+
+// 两个时刻之差，任一缺失时为空
+std::optional<int64_t> phase_delta(const std::optional<int64_t>& from, const std::optional<int64_t>& to) {
+    if (!from.has_value() || !to.has_value()) {
+        return std::nullopt;
+    }
+    return to.value() - from.value();
+}
+
+} // namespace
+
+std::string ConvergenceReport::render_html(const ReportData& data) {
+    std::ostringstream html;
+
+    std::vector<const ReportSession*> completed;
+    for (const auto& s : data.sessions) {
+        if (s.completed) {
+            completed.push_back(&s);
+        }
+    }
+
+    html << "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\">\n";
+    html << "<title>Convergence Report</title>\n";
+    html << "<style>body{font-family:sans-serif;margin:24px;color:#222}"
+            "table{border-collapse:collapse;margin:8px 0 20px}"
+            "td,th{border:1px solid #ccc;padding:4px 8px;text-align:right}"
+            "th{background:#f0f0f0}td:first-child{text-align:left}"
+            ".timeout{color:#d9534f}.chart,.timeline{background:#fafafa;border:1px solid #eee}"
+            "</style></head><body>\n";
+
+    html << "<h1>Convergence Report</h1>\n";
+    html << "<p>Source: <code>" << escape_html(data.source_path) << "</code><br>\n";
+    html << "Routers: ";
+    for (size_t i = 0; i < data.routers.size(); ++i) {
+        html << (i ? ", " : "") << escape_html(data.routers[i]);
+    }
+    html << "<br>\nConvergence threshold: " << data.convergence_threshold_ms << " ms<br>\n";
+    html << "Listen duration: " << fmt(data.total_listen_duration_ms / 1000.0) << " s<br>\n";
+    html << "Sessions: " << completed.size() << " completed";
+    if (data.malformed_lines > 0) {
+        html << ", " << data.malformed_lines << " malformed log lines skipped";
+    }
+    html << "</p>\n";
+
+    // 汇总统计
+    html << "<h2>Summary</h2>\n<table>\n<tr><th>Scope</th><th>Count</th><th>Min</th><th>Mean</th>"
+            "<th>Median</th><th>P95</th><th>Max</th><th>Stddev</th></tr>\n";
+    render_stats_row(html, "all sessions (ms)", compute_stats(convergence_times(data.sessions)));
+
+    std::map<std::string, std::vector<ReportSession>> by_interface;
+    for (const auto* s : completed) {
+        by_interface[s->interface()].push_back(*s);
+    }
+    for (const auto& pair : by_interface) {
+        render_stats_row(html, "interface " + pair.first, compute_stats(convergence_times(pair.second)));
+    }
+    html << "</table>\n";
+
+    // 图表
+    std::vector<ReportSession> completed_copy;
+    for (const auto* s : completed) {
+        completed_copy.push_back(*s);
+    }
+    html << "<h2>Convergence time per session</h2>\n";
+    render_bar_chart(html, completed_copy);
+
+    // 会话明细
+    html << "<h2>Sessions</h2>\n<table>\n<tr><th>Router</th><th>#</th><th>Start</th><th>Trigger</th>"
+            "<th>Interface</th><th>Convergence (ms)</th><th>Route events</th><th>Duration (ms)</th></tr>\n";
+    for (const auto* s : completed) {
+        html << "<tr" << (s->timed_out ? " class=\"timeout\"" : "") << "><td>"
+             << escape_html(s->router_name) << "</td><td>" << s->session_id << "</td><td>"
+             << escape_html(s->start_timestamp) << "</td><td>" << escape_html(s->trigger_source)
+             << "/" << escape_html(s->trigger_event_type) << "</td><td>" << escape_html(s->interface())
+             << "</td><td>"
+             << (s->convergence_time_ms.has_value() ? std::to_string(s->convergence_time_ms.value()) : "-")
+             << (s->timed_out ? " (timeout)" : s->forced ? " (forced)" : "") << "</td><td>"
+             << s->route_events << "</td><td>" << s->duration_ms << "</td></tr>\n";
+    }
+    html << "</table>\n";
+
+    // 收敛阶段分解(仅在采集了IGP邻接或FRR SPF日志时输出)
+    bool has_phases = std::any_of(completed.begin(), completed.end(),
+                                  [](const ReportSession* s) { return !compute_phases(*s).empty(); });
+    if (has_phases) {
+        html << "<h2>Convergence phases</h2>\n<table>\n<tr><th>Router</th><th>#</th><th>IGP detect (ms)</th>"
+                "<th>SPF done (ms)</th><th>FIB converged (ms)</th><th>Flood/SPF (ms)</th>"
+                "<th>FIB install (ms)</th></tr>\n";
+        for (const auto* s : completed) {
+            auto phases = compute_phases(*s);
+            html << "<tr><td>" << escape_html(s->router_name) << "</td><td>" << s->session_id << "</td><td>"
+                 << phase_cell(phases.detection_ms) << "</td><td>" << phase_cell(phases.spf_done_ms)
+                 << "</td><td>" << phase_cell(phases.fib_done_ms) << "</td><td>"
+                 << phase_cell(phase_delta(phases.detection_ms, phases.spf_done_ms)) << "</td><td>"
+                 << phase_cell(phase_delta(phases.spf_done_ms, phases.fib_done_ms)) << "</td></tr>\n";
+        }
+        html << "</table>\n";
+    }
+
+    // 时间线
+    html << "<h2>Timelines</h2>\n";
+    for (const auto* s : completed) {
+        html << "<h3>" << escape_html(s->router_name) << " session #" << s->session_id << "</h3>\n";
+        render_timeline(html, *s);
+        if (!s->churn_per_second.empty()) {
+            render_churn_chart(html, *s);
+        }
+    }
+
+    html << "</body></html>\n";
+    return html.str();
+}
+
+namespace {
+
+void render_stats_md(std::ostringstream& md, const std::string& label, const DistributionStats& s) {
+    md << "| " << label << " | " << s.count << " | " << fmt(s.min) << " | " << fmt(s.mean)
+       << " | " << fmt(s.median) << " | " << fmt(s.p95) << " | " << fmt(s.max)
+       << " | " << fmt(s.stddev) << " |\n";
+}
+
+} // namespace
+
+std::string ConvergenceReport::render_markdown(const ReportData& data) {
+    std::ostringstream md;
+
+    std::vector<ReportSession> completed;
+    for (const auto& s : data.sessions) {
+        if (s.completed) {
+            completed.push_back(s);
+        }
+    }
+
+    md << "## Convergence Report\n\n";
+    md << "- Source: `" << data.source_path << "`\n";
+    md << "- Routers: ";
+    for (size_t i = 0; i < data.routers.size(); ++i) {
+        md << (i ? ", " : "") << data.routers[i];
+    }
+    md << "\n- Convergence threshold: " << data.convergence_threshold_ms << " ms\n";
+    md << "- Listen duration: " << fmt(data.total_listen_duration_ms / 1000.0) << " s\n";
+    md << "- Completed sessions: " << completed.size() << "\n";
+    if (data.malformed_lines > 0) {
+        md << "- Malformed log lines skipped: " << data.malformed_lines << "\n";
+    }
+
+    // 分布摘要
+    md << "\n### Distribution (ms)\n\n";
+    md << "| Scope | Count | Min | Mean | Median | P95 | Max | Stddev |\n";
+    md << "|---|---:|---:|---:|---:|---:|---:|---:|\n";
+    render_stats_md(md, "all sessions", compute_stats(convergence_times(completed)));
+
+    int fast = 0, medium = 0, slow = 0, timed_out = 0, forced = 0;
+    for (const auto& s : completed) {
+        if (s.timed_out) {
+            timed_out++;
+        }
+        if (s.forced) {
+            forced++;
+            continue;
+        }
+        int64_t t = s.convergence_time_ms.value_or(0);
+        if (t < 100) fast++;
+        else if (t < 1000) medium++;
+        else slow++;
+    }
+    md << "\nBuckets: fast (<100ms) = " << fast << ", medium (100-1000ms) = " << medium
+       << ", slow (>1000ms) = " << slow << ", timed out = " << timed_out;
+    if (forced > 0) {
+        md << ", forced (no result) = " << forced;
+    }
+    md << "\n";
+
+    // 按接口统计
+    std::map<std::string, std::vector<ReportSession>> by_interface;
+    for (const auto& s : completed) {
+        by_interface[s.interface()].push_back(s);
+    }
+    md << "\n### Per-interface (ms)\n\n";
+    md << "| Interface | Count | Min | Mean | Median | P95 | Max | Stddev |\n";
+    md << "|---|---:|---:|---:|---:|---:|---:|---:|\n";
+    for (const auto& pair : by_interface) {
+        render_stats_md(md, pair.first, compute_stats(convergence_times(pair.second)));
+    }
+
+    // 会话明细
+    md << "\n### Sessions\n\n";
+    md << "| Router | # | Start | Trigger | Interface | Convergence (ms) | Route events | Duration (ms) |\n";
+    md << "|---|---:|---|---|---|---:|---:|---:|\n";
+    for (const auto& s : completed) {
+        md << "| " << s.router_name << " | " << s.session_id << " | " << s.start_timestamp
+           << " | " << s.trigger_source << "/" << s.trigger_event_type << " | " << s.interface() << " | "
+           << (s.convergence_time_ms.has_value() ? std::to_string(s.convergence_time_ms.value()) : "-")
+           << (s.timed_out ? " (timeout)" : s.forced ? " (forced)" : "") << " | " << s.route_events << " | "
+           << s.duration_ms << " |\n";
+    }
+
+    // 收敛阶段分解
+    bool has_phases = std::any_of(completed.begin(), completed.end(),
+                                  [](const ReportSession& s) { return !compute_phases(s).empty(); });
+    if (has_phases) {
+        md << "\n### Convergence phases\n\n";
+        md << "Offsets from trigger. Flood/SPF = SPF done - IGP detect; FIB install = FIB converged - SPF done.\n\n";
+        md << "| Router | # | IGP detect (ms) | SPF done (ms) | FIB converged (ms) | Flood/SPF (ms) | FIB install (ms) |\n";
+        md << "|---|---:|---:|---:|---:|---:|---:|\n";
+        for (const auto& s : completed) {
+            auto phases = compute_phases(s);
+            md << "| " << s.router_name << " | " << s.session_id << " | " << phase_cell(phases.detection_ms)
+               << " | " << phase_cell(phases.spf_done_ms) << " | " << phase_cell(phases.fib_done_ms)
+               << " | " << phase_cell(phase_delta(phases.detection_ms, phases.spf_done_ms))
+               << " | " << phase_cell(phase_delta(phases.spf_done_ms, phases.fib_done_ms)) << " |\n";
+        }
+    }
+
+    // 每秒路由事件数
+    bool has_churn = std::any_of(completed.begin(), completed.end(),
+                                 [](const ReportSession& s) { return !s.churn_per_second.empty(); });
+    if (has_churn) {
+        md << "\n### Route churn (events/s)\n\n";
+        md << "| Router | # | Peak (/s) | Peak at (s) | Per second |\n";
+        md << "|---|---:|---:|---:|---|\n";
+        for (const auto& s : completed) {
+            if (s.churn_per_second.empty()) {
+                continue;
+            }
+            auto peak = std::max_element(s.churn_per_second.begin(), s.churn_per_second.end());
+            md << "| " << s.router_name << " | " << s.session_id << " | " << *peak << " | "
+               << (peak - s.churn_per_second.begin()) << " | " << churn_series(s.churn_per_second) << " |\n";
+        }
+    }
+
+    return md.str();
+}
+
+namespace {
+
+std::string csv_field(const std::string& text) {
+    if (text.find_first_of(",\"\r\n") == std::string::npos) {
+        return text;
+    }
+    std::string quoted = "\"";
+    for (char c : text) {
+        if (c == '"') {
+            quoted += '"';
+        }
+        quoted += c;
+    }
+    return quoted + "\"";
+}
+
+std::string csv_optional(const std::optional<int64_t>& value) {
+    return value.has_value() ? std::to_string(value.value()) : "";
+}
+
+std::string info_value(const std::unordered_map<std::string, std::string>& info, const std::string& key) {
+    auto it = info.find(key);
+    return it == info.end() || it->second == "N/A" ? "" : it->second;
+}
+
+} // namespace
+
+std::string ConvergenceReport::render_sessions_csv(const ReportData& data) {
+    std::ostringstream csv;
+    csv << "run,router_name,session_id,start_timestamp,trigger_source,trigger_event_type,interface,link,"
+           "convergence_time_ms,route_events,duration_ms,timed_out,forced,completed,campaign_step,"
+           "igp_detection_ms,spf_done_ms\n";
+    for (const auto& session : data.sessions) {
+        auto phases = compute_phases(session);
+        csv << session.run_index << ","
+            << csv_field(session.router_name) << ","
+            << session.session_id << ","
+            << csv_field(session.start_timestamp) << ","
+            << csv_field(session.trigger_source) << ","
+            << csv_field(session.trigger_event_type) << ","
+            << csv_field(info_value(session.trigger_info, "interface")) << ","
+            << csv_field(info_value(session.trigger_info, "link")) << ","
+            << csv_optional(session.convergence_time_ms) << ","
+            << session.route_events << ","
+            << session.duration_ms << ","
+            << (session.timed_out ? "true" : "false") << ","
+            << (session.forced ? "true" : "false") << ","
+            << (session.completed ? "true" : "false") << ","
+            << csv_field(session.campaign_step) << ","
+            << csv_optional(phases.detection_ms) << ","
+            << csv_optional(phases.spf_done_ms) << "\n";
+    }
+    return csv.str();
+}
+
+std::string ConvergenceReport::render_events_csv(const ReportData& data) {
+    std::ostringstream csv;
+    csv << "run,router_name,session_id,offset_ms,type,dst,gateway,interface,message\n";
+    for (const auto& session : data.sessions) {
+        auto events = session.events;
+        std::stable_sort(events.begin(), events.end(), [](const ReportEvent& a, const ReportEvent& b) {
+            return a.offset_ms < b.offset_ms;
+        });
+        for (const auto& event : events) {
+            csv << session.run_index << ","
+                << csv_field(session.router_name) << ","
+                << session.session_id << ","
+                << event.offset_ms << ","
+                << csv_field(event.type) << ","
+                << csv_field(info_value(event.info, "dst")) << ","
+                << csv_field(info_value(event.info, "gateway")) << ","
+                << csv_field(info_value(event.info, "interface")) << ","
+                << csv_field(info_value(event.info, "message")) << "\n";
+        }
+    }
+    return csv.str();
+}
+
+namespace {
+
+void print_report_usage(const char* program_name) {
+    std::cout << "用法: " << program_name << " report --input LOG [--output FILE] [--format html|markdown|csv]\n\n";
+    std::cout << "根据已有的JSON结构化日志生成会话报告\n\n";
+    std::cout << "选项:\n";
+    std::cout << "  -i, --input PATH     输入日志文件(NDJSON)\n";
+    std::cout << "  -o, --output PATH    输出文件(默认输出到stdout)；csv格式时为输出目录(默认当前目录)\n";
+    std::cout << "  -f, --format FORMAT  报告格式: html(默认)、markdown、csv(sessions.csv与events.csv)\n";
+    std::cout << "  -h, --help           显示此帮助信息\n";
+}
+
+} // namespace
+
+int report_main(int argc, char* argv[]) {
+    std::string input_path;
+    std::string output_path;
+    std::string format = "html";
+
+    static struct option long_options[] = {
+        {"input", required_argument, 0, 'i'},
+        {"output", required_argument, 0, 'o'},
+        {"format", required_argument, 0, 'f'},
+        {"help", no_argument, 0, 'h'},
+        {0, 0, 0, 0}
+    };
+
+    optind = 1;
+    int c;
+    while ((c = getopt_long(argc, argv, "i:o:f:h", long_options, nullptr)) != -1) {
+        switch (c) {
+            case 'i': input_path = optarg; break;
+            case 'o': output_path = optarg; break;
+            case 'f': format = optarg; break;
+            case 'h': print_report_usage(argv[0]); return 0;
+            default:  print_report_usage(argv[0]); return 1;
+        }
+    }
+
+    if (input_path.empty()) {
+        std::cerr << "❌ 错误: 必须指定 --input\n";
+        return 1;
+    }
+
+    ReportData data;
+    std::string error;
+    if (!ConvergenceReport::load(input_path, data, error)) {
+        std::cerr << "❌ " << error << "\n";
+        return 1;
+    }
+
+    if (format == "csv") {
+        std::string directory = output_path.empty() ? "." : output_path;
+        mkdir(directory.c_str(), 0755);
+        const std::pair<std::string, std::string> files[] = {
+            {directory + "/sessions.csv", ConvergenceReport::render_sessions_csv(data)},
+            {directory + "/events.csv", ConvergenceReport::render_events_csv(data)},
+        };
+        for (const auto& file : files) {
+            std::ofstream out(file.first, std::ios::out | std::ios::trunc);
+            if (!out.is_open()) {
+                std::cerr << "❌ 错误: 无法写入 " << file.first << "\n";
+                return 1;
+            }
+            out << file.second;
+            std::cerr << "✅ 已生成: " << file.first << "\n";
+        }
+        return 0;
+    }
+
+    std::string content;
+    if (format == "html") {
+        content = ConvergenceReport::render_html(data);
+    } else if (format == "markdown" || format == "md") {
+        content = ConvergenceReport::render_markdown(data);
+    } else {
+        std::cerr << "❌ 错误: 不支持的报告格式 " << format << "\n";
+        return 1;
+    }
+
+    if (output_path.empty()) {
+        std::cout << content;
+        return 0;
+    }
+
+    std::ofstream out(output_path, std::ios::out | std::ios::trunc);
+    if (!out.is_open()) {
+        std::cerr << "❌ 错误: 无法写入 " << output_path << "\n";
+        return 1;
+    }
+    out << content;
+    std::cerr << "✅ 报告已生成: " << output_path << "\n";
+    return 0;
+}
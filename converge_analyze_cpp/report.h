@@ -0,0 +1,108 @@
+#pragma once
+
+#include <string>
+#include <vector>
+#include <optional>
+#include <unordered_map>
+#include "logger.h"
+
+// 会话内的单个路由事件
+struct ReportEvent {
+    int64_t offset_ms = 0;
+    std::string type;
+    std::unordered_map<std::string, std::string> info;
+};
+
+// 从结构化日志还原出的会话
+struct ReportSession {
+    int run_index = 0;
+    std::string router_name;
+    int session_id = 0;
+    std::string start_timestamp;
+    int64_t start_time_ms = -1;
+    std::string trigger_source;
+    std::string trigger_event_type;
+    std::unordered_map<std::string, std::string> trigger_info;
+    std::optional<int64_t> convergence_time_ms;
+    int route_events = 0;
+    int64_t duration_ms = 0;
+    bool timed_out = false;
+    bool forced = false;  // 监听结束时仍未收敛而被强制结束，没有真正的收敛结果
+    bool completed = false;
+    std::string campaign_step;  // campaign子命令标记的计划步骤ID
+    std::vector<int64_t> churn_per_second;  // 相对触发每秒的路由事件数
+    std::vector<ReportEvent> events;
+    std::optional<int64_t> clock_correction_ms;  // merge按clock_offset记录加到start_time_ms上的校正
+
+    // 触发接口，未知时返回"N/A"
+    std::string interface() const;
+};
+
+// clock_offset记录：某一时刻路由器相对时钟参考的偏差(参考时钟减本地时钟)
+struct ReportClockOffset {
+    std::string router_name;
+    int64_t time_ms = 0;
+    double offset_ms = 0.0;
+    double rtt_ms = 0.0;
+};
+
+// 收敛阶段分解，各时刻均为相对触发的偏移(ms)，缺少数据的阶段为空
+// IGP检测 -> 泛洪/SPF完成 -> FIB安装完成(收敛时间)
+struct ConvergencePhases {
+    std::optional<int64_t> detection_ms;  // 最早的IGP邻接变化，可能早于触发(负值)
+    std::optional<int64_t> spf_done_ms;   // 最后一次FRR spf_end
+    std::optional<int64_t> fib_done_ms;   // 收敛时间
+
+    bool empty() const { return !detection_ms.has_value() && !spf_done_ms.has_value(); }
+};
+
+// 数值分布统计
+struct DistributionStats {
+    size_t count = 0;
+    double min = 0.0;
+    double max = 0.0;
+    double mean = 0.0;
+    double median = 0.0;
+    double p95 = 0.0;
+    double stddev = 0.0;
+};
+
+// 报告数据模型
+struct ReportData {
+    std::string source_path;
+    std::vector<std::string> routers;
+    int64_t convergence_threshold_ms = 0;
+    int64_t total_listen_duration_ms = 0;
+    int malformed_lines = 0;
+    std::vector<ReportSession> sessions;
+    std::vector<ReportClockOffset> clock_offsets;
+};
+
+class ConvergenceReport {
+public:
+    // 从NDJSON日志加载会话
+    static bool load(const std::string& path, ReportData& data, std::string& error);
+
+    // 从已解析的记录构建会话（供其他子命令复用）
+    static void build(const std::vector<JsonObject>& records, ReportData& data);
+
+    static DistributionStats compute_stats(std::vector<double> values);
+
+    // 已完成且有收敛时间的会话的收敛时间列表，不含强制结束的会话
+    static std::vector<double> convergence_times(const std::vector<ReportSession>& sessions);
+
+    static ConvergencePhases compute_phases(const ReportSession& session);
+
+    // 会话摘要的扁平JSON表示
+    static JsonObject session_to_json(const ReportSession& session);
+
+    static std::string render_html(const ReportData& data);
+    static std::string render_markdown(const ReportData& data);
+
+    // 扁平CSV：每个会话一行 / 每个会话事件一行(按偏移排序)
+    static std::string render_sessions_csv(const ReportData& data);
+    static std::string render_events_csv(const ReportData& data);
+};
+
+// report 子命令入口
+int report_main(int argc, char* argv[]);
@@ -0,0 +1,201 @@
+#include "route_table_sampler.h"
+#include "netlink_monitor.h"
+#include "route_tables.h"
+#include <chrono>
+#include <cstring>
+#include <iostream>
+#include <linux/netlink.h>
+#include <linux/rtnetlink.h>
+#include <sys/socket.h>
+#include <unistd.h>
+
+namespace {
+
+int64_t now_ms() {
+    return std::chrono::duration_cast<std::chrono::milliseconds>(
+        std::chrono::system_clock::now().time_since_epoch()).count();
+}
+
+} // namespace
+
+JsonObject RouteTableSample::to_json() const {
+    JsonObject obj;
+    obj["collect_duration_ms"] = collect_duration_ms;
+    obj["ipv4_routes"] = ipv4_routes;
+    obj["ipv6_routes"] = ipv6_routes;
+    obj["total_routes"] = total();
+
+    JsonObject tables;
+    for (const auto& pair : by_table) {
+        tables[pair.first] = pair.second;
+    }
+    obj["tables"] = Logger::json_to_string(tables);
+
+    if (!error.empty()) {
+        obj["error"] = error;
+    }
+    return obj;
+}
+
+RouteTableSampler::RouteTableSampler(int64_t interval_ms, Callback callback)
+    : interval_ms_(interval_ms), callback_(std::move(callback)) {
+}
+
+RouteTableSampler::~RouteTableSampler() {
+    stop();
+}
+
+void RouteTableSampler::start() {
+    if (running_.load()) {
+        return;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&RouteTableSampler::worker_loop, this);
+}
+
+void RouteTableSampler::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    {
+        std::lock_guard<std::mutex> lock(wait_mutex_);
+        running_.store(false);
+    }
+    wait_cv_.notify_all();
+
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+}
+
+void RouteTableSampler::worker_loop() {
+    while (running_.load()) {
+        RouteTableSample sample = collect();
+        if (!sample.error.empty()) {
+            std::cerr << "⚠️  路由表采样失败: " << sample.error << "\n";
+        }
+        callback_(sample);
+
+        std::unique_lock<std::mutex> lock(wait_mutex_);
+        wait_cv_.wait_for(lock, std::chrono::milliseconds(interval_ms_), [this] {
+            return !running_.load();
+        });
+    }
+}
+
+std::string RouteTableSampler::table_name(uint32_t table) {
+    return route_table_name(table);
+}
+
+RouteTableSample RouteTableSampler::collect() {
+    RouteTableSample sample;
+    sample.timestamp_ms = now_ms();
+
+    dump_routes([&sample](const struct rtmsg* rtm, const struct rtattr* rta, int attr_len) {
+        uint32_t table = rtm->rtm_table;
+        for (; RTA_OK(rta, attr_len); rta = RTA_NEXT(rta, attr_len)) {
+            if (rta->rta_type == RTA_TABLE) {
+                table = *static_cast<const uint32_t*>(RTA_DATA(rta));
+            }
+        }
+
+        std::string family;
+        if (rtm->rtm_family == AF_INET) {
+            sample.ipv4_routes++;
+            family = "ipv4";
+        } else if (rtm->rtm_family == AF_INET6) {
+            sample.ipv6_routes++;
+            family = "ipv6";
+        } else {
+            return;
+        }
+        sample.by_table[family + "/" + table_name(table)]++;
+    }, sample.error);
+
+    sample.collect_duration_ms = now_ms() - sample.timestamp_ms;
+    return sample;
+}
+
+std::vector<std::unordered_map<std::string, std::string>> RouteTableSampler::default_routes(std::string& error) {
+    std::vector<std::unordered_map<std::string, std::string>> routes;
+    dump_routes([&routes](const struct rtmsg* rtm, const struct rtattr* rta, int attr_len) {
+        if (rtm->rtm_dst_len != 0 || (rtm->rtm_family != AF_INET && rtm->rtm_family != AF_INET6)) {
+            return;
+        }
+        auto info = NetlinkMessageParser::parse_route_message(rtm, rta, attr_len);
+        if (info["table"] == std::to_string(RT_TABLE_MAIN)) {
+            routes.push_back(std::move(info));
+        }
+    }, error);
+    return routes;
+}
+
+void RouteTableSampler::dump_routes(const RouteCallback& callback, std::string& error) {
+    // 使用独立的短连接套接字，dump应答不会混入事件订阅套接字
+    int fd = socket(AF_NETLINK, SOCK_RAW | SOCK_CLOEXEC, NETLINK_ROUTE);
+    if (fd < 0) {
+        error = std::string("socket: ") + strerror(errno);
+        return;
+    }
+
+    struct {
+        struct nlmsghdr nlh;
+        struct rtmsg rtm;
+    } request{};
+    request.nlh.nlmsg_len = NLMSG_LENGTH(sizeof(struct rtmsg));
+    request.nlh.nlmsg_type = RTM_GETROUTE;
+    request.nlh.nlmsg_flags = NLM_F_REQUEST | NLM_F_DUMP;
+    request.nlh.nlmsg_seq = 1;
+    request.rtm.rtm_family = AF_UNSPEC;
+
+    if (send(fd, &request, request.nlh.nlmsg_len, 0) < 0) {
+        error = std::string("send: ") + strerror(errno);
+        close(fd);
+        return;
+    }
+
+    char buffer[65536];
+    bool done = false;
+    while (!done) {
+        ssize_t len = recv(fd, buffer, sizeof(buffer), 0);
+        if (len < 0) {
+            if (errno == EINTR) {
+                continue;
+            }
+            error = std::string("recv: ") + strerror(errno);
+            break;
+        }
+        if (len == 0) {
+            break;
+        }
+
+        int remaining = static_cast<int>(len);
+        for (struct nlmsghdr* nlh = reinterpret_cast<struct nlmsghdr*>(buffer);
+             NLMSG_OK(nlh, remaining); nlh = NLMSG_NEXT(nlh, remaining)) {
+            if (nlh->nlmsg_type == NLMSG_DONE) {
+                done = true;
+                break;
+            }
+            if (nlh->nlmsg_type == NLMSG_ERROR) {
+                auto* err = static_cast<struct nlmsgerr*>(NLMSG_DATA(nlh));
+                error = std::string("dump: ") + strerror(-err->error);
+                done = true;
+                break;
+            }
+            if (nlh->nlmsg_type != RTM_NEWROUTE) {
+                continue;
+            }
+
+            auto* rtm = static_cast<struct rtmsg*>(NLMSG_DATA(nlh));
+            // 路由缓存(如IPv6 PMTU例外)不属于路由表
+            if (rtm->rtm_flags & RTM_F_CLONED) {
+                continue;
+            }
+            callback(rtm, RTM_RTA(rtm), RTM_PAYLOAD(nlh));
+        }
+    }
+
+    close(fd);
+}
@@ -0,0 +1,69 @@
+#pragma once
+
+#include "logger.h"
+#include <atomic>
+#include <condition_variable>
+#include <functional>
+#include <map>
+#include <mutex>
+#include <string>
+#include <thread>
+#include <unordered_map>
+#include <vector>
+
+// 某一时刻的内核路由表规模
+struct RouteTableSample {
+    int64_t timestamp_ms = 0;
+    int64_t collect_duration_ms = 0;
+    int64_t ipv4_routes = 0;
+    int64_t ipv6_routes = 0;
+    std::map<std::string, int64_t> by_table;  // "ipv4/main" -> 路由数
+    std::string error;
+
+    int64_t total() const { return ipv4_routes + ipv6_routes; }
+
+    // 转为日志字段(按表计数序列化为JSON字符串)
+    JsonObject to_json() const;
+};
+
+// 路由表采样器：独立线程按固定间隔dump内核路由表(RTM_GETROUTE)并计数，
+// 为会话提供基线上下文，也能发现缓慢累积的陈旧路由
+class RouteTableSampler {
+public:
+    using Callback = std::function<void(const RouteTableSample&)>;
+    using RouteCallback = std::function<void(const struct rtmsg*, const struct rtattr*, int)>;
+
+private:
+    int64_t interval_ms_;
+    Callback callback_;
+
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+    std::mutex wait_mutex_;
+    std::condition_variable wait_cv_;
+
+    void worker_loop();
+
+public:
+    RouteTableSampler(int64_t interval_ms, Callback callback);
+    ~RouteTableSampler();
+
+    RouteTableSampler(const RouteTableSampler&) = delete;
+    RouteTableSampler& operator=(const RouteTableSampler&) = delete;
+
+    // 启动后立即采样一次，之后每interval_ms采样一次
+    void start();
+    void stop();
+
+    // 同步采样一次
+    static RouteTableSample collect();
+
+    // 同步读取main表中当前的IPv4/IPv6默认路由(已解析为路由事件相同的字段)
+    static std::vector<std::unordered_map<std::string, std::string>> default_routes(std::string& error);
+
+    // dump全部路由表项(跳过路由缓存)，对每条调用callback(消息头、首个属性、属性总长)
+    static void dump_routes(const RouteCallback& callback, std::string& error);
+
+    // 路由表ID转名称: main/local/default，其余为数字
+    static std::string table_name(uint32_t table);
+};
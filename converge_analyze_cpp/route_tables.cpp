@@ -0,0 +1,233 @@
+#include "route_tables.h"
+#include <algorithm>
+#include <chrono>
+#include <cstdint>
+#include <cstdlib>
+#include <cstring>
+#include <dirent.h>
+#include <fstream>
+#include <linux/if_link.h>
+#include <linux/netlink.h>
+#include <linux/rtnetlink.h>
+#include <map>
+#include <mutex>
+#include <sstream>
+#include <sys/socket.h>
+#include <unistd.h>
+
+namespace {
+
+constexpr int64_t REFRESH_INTERVAL_MS = 5000;
+
+struct TableNames {
+    std::mutex mutex;
+    std::map<uint32_t, std::string> names;
+    std::map<std::string, uint32_t> ids;
+    bool loaded = false;
+    int64_t loaded_ms = 0;
+};
+
+TableNames& table_names() {
+    static TableNames instance;
+    return instance;
+}
+
+int64_t now_ms() {
+    return std::chrono::duration_cast<std::chrono::milliseconds>(
+        std::chrono::steady_clock::now().time_since_epoch()).count();
+}
+
+bool parse_id(const std::string& text, uint32_t& id) {
+    if (text.empty()) {
+        return false;
+    }
+    char* end = nullptr;
+    unsigned long value = strtoul(text.c_str(), &end, 0);
+    if (*end != '\0' || value > UINT32_MAX) {
+        return false;
+    }
+    id = static_cast<uint32_t>(value);
+    return true;
+}
+
+// iproute2格式："ID 名称"，#之后为注释；先读到的名称优先
+void read_rt_tables(const std::string& path, std::map<uint32_t, std::string>& names) {
+    std::ifstream file(path);
+    std::string line;
+    while (std::getline(file, line)) {
+        size_t hash = line.find('#');
+        if (hash != std::string::npos) {
+            line.erase(hash);
+        }
+        std::istringstream iss(line);
+        std::string id_text;
+        std::string name;
+        uint32_t id;
+        if (iss >> id_text >> name && parse_id(id_text, id)) {
+            names.emplace(id, name);
+        }
+    }
+}
+
+void read_rt_tables_dir(const std::string& directory, std::map<uint32_t, std::string>& names) {
+    DIR* dir = opendir(directory.c_str());
+    if (dir == nullptr) {
+        return;
+    }
+    std::vector<std::string> files;
+    while (struct dirent* entry = readdir(dir)) {
+        std::string name = entry->d_name;
+        if (name.size() > 5 && name.compare(name.size() - 5, 5, ".conf") == 0) {
+            files.push_back(directory + "/" + name);
+        }
+    }
+    closedir(dir);
+    std::sort(files.begin(), files.end());
+    for (const auto& file : files) {
+        read_rt_tables(file, names);
+    }
+}
+
+// 当前网络命名空间内的VRF设备及其绑定的表
+void read_vrf_devices(std::map<uint32_t, std::string>& names) {
+    int fd = socket(AF_NETLINK, SOCK_RAW | SOCK_CLOEXEC, NETLINK_ROUTE);
+    if (fd < 0) {
+        return;
+    }
+    struct timeval timeout = {1, 0};
+    setsockopt(fd, SOL_SOCKET, SO_RCVTIMEO, &timeout, sizeof(timeout));
+
+    struct {
+        struct nlmsghdr header;
+        struct ifinfomsg ifi;
+    } request;
+    memset(&request, 0, sizeof(request));
+    request.header.nlmsg_len = NLMSG_LENGTH(sizeof(struct ifinfomsg));
+    request.header.nlmsg_type = RTM_GETLINK;
+    request.header.nlmsg_flags = NLM_F_REQUEST | NLM_F_DUMP;
+    request.header.nlmsg_seq = 1;
+    request.ifi.ifi_family = AF_UNSPEC;
+    if (send(fd, &request, request.header.nlmsg_len, 0) < 0) {
+        close(fd);
+        return;
+    }
+
+    std::vector<char> buffer(32768);
+    bool done = false;
+    while (!done) {
+        ssize_t len = recv(fd, buffer.data(), buffer.size(), 0);
+        if (len <= 0) {
+            break;
+        }
+        for (auto* nlh = reinterpret_cast<struct nlmsghdr*>(buffer.data()); NLMSG_OK(nlh, static_cast<unsigned>(len));
+             nlh = NLMSG_NEXT(nlh, len)) {
+            if (nlh->nlmsg_type == NLMSG_DONE || nlh->nlmsg_type == NLMSG_ERROR) {
+                done = true;
+                break;
+            }
+            if (nlh->nlmsg_type != RTM_NEWLINK) {
+                continue;
+            }
+            auto* ifi = static_cast<struct ifinfomsg*>(NLMSG_DATA(nlh));
+            int attr_len = static_cast<int>(nlh->nlmsg_len - NLMSG_LENGTH(sizeof(*ifi)));
+            std::string name;
+            std::string kind;
+            uint32_t table = 0;
+            for (auto* rta = IFLA_RTA(ifi); RTA_OK(rta, attr_len); rta = RTA_NEXT(rta, attr_len)) {
+                if (rta->rta_type == IFLA_IFNAME) {
+                    name = static_cast<const char*>(RTA_DATA(rta));
+                } else if (rta->rta_type == IFLA_LINKINFO) {
+                    int info_len = static_cast<int>(RTA_PAYLOAD(rta));
+                    for (auto* info = static_cast<struct rtattr*>(RTA_DATA(rta)); RTA_OK(info, info_len);
+                         info = RTA_NEXT(info, info_len)) {
+                        if (info->rta_type == IFLA_INFO_KIND) {
+                            kind = static_cast<const char*>(RTA_DATA(info));
+                        } else if (info->rta_type == IFLA_INFO_DATA) {
+                            int data_len = static_cast<int>(RTA_PAYLOAD(info));
+                            for (auto* data = static_cast<struct rtattr*>(RTA_DATA(info)); RTA_OK(data, data_len);
+                                 data = RTA_NEXT(data, data_len)) {
+                                if (data->rta_type == IFLA_VRF_TABLE) {
+                                    table = *static_cast<const uint32_t*>(RTA_DATA(data));
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+            if (kind == "vrf" && table != 0 && !name.empty()) {
+                names.emplace(table, name);
+            }
+        }
+    }
+    close(fd);
+}
+
+void load_locked(TableNames& tables) {
+    std::map<uint32_t, std::string> names;
+    read_rt_tables("/etc/iproute2/rt_tables", names);
+    read_rt_tables_dir("/etc/iproute2/rt_tables.d", names);
+    // 较新的iproute2把默认文件放在/usr下，/etc中只保留用户的修改
+    read_rt_tables("/usr/share/iproute2/rt_tables", names);
+    read_rt_tables("/usr/lib/iproute2/rt_tables", names);
+    read_vrf_devices(names);
+    names.emplace(RT_TABLE_UNSPEC, "unspec");
+    names.emplace(RT_TABLE_DEFAULT, "default");
+    names.emplace(RT_TABLE_MAIN, "main");
+    names.emplace(RT_TABLE_LOCAL, "local");
+
+    tables.ids.clear();
+    for (const auto& entry : names) {
+        tables.ids.emplace(entry.second, entry.first);
+    }
+    tables.names = std::move(names);
+    tables.loaded = true;
+    tables.loaded_ms = now_ms();
+}
+
+void ensure_loaded_locked(TableNames& tables) {
+    if (!tables.loaded) {
+        load_locked(tables);
+    }
+}
+
+} // namespace
+
+std::string route_table_name(uint32_t table) {
+    TableNames& tables = table_names();
+    std::lock_guard<std::mutex> lock(tables.mutex);
+    ensure_loaded_locked(tables);
+    auto it = tables.names.find(table);
+    if (it == tables.names.end() && now_ms() - tables.loaded_ms >= REFRESH_INTERVAL_MS) {
+        load_locked(tables);
+        it = tables.names.find(table);
+    }
+    return it != tables.names.end() ? it->second : std::to_string(table);
+}
+
+bool parse_route_table(const std::string& text, uint32_t& table) {
+    if (parse_id(text, table)) {
+        return true;
+    }
+    TableNames& tables = table_names();
+    std::lock_guard<std::mutex> lock(tables.mutex);
+    ensure_loaded_locked(tables);
+    auto it = tables.ids.find(text);
+    if (it == tables.ids.end()) {
+        return false;
+    }
+    table = it->second;
+    return true;
+}
+
+void reload_route_table_names() {
+    TableNames& tables = table_names();
+    std::lock_guard<std::mutex> lock(tables.mutex);
+    load_locked(tables);
+}
+
+std::vector<std::pair<uint32_t, std::string>> route_table_names() {
+    TableNames& tables = table_names();
+    std::lock_guard<std::mutex> lock(tables.mutex);
+    ensure_loaded_locked(tables);
+    return std::vector<std::pair<uint32_t, std::string>>(tables.names.begin(), tables.names.end());
+}
@@ -0,0 +1,21 @@
+#pragma once
+
+#include <cstdint>
+#include <string>
+#include <utility>
+#include <vector>
+
+// 路由表ID与名称的映射：/etc/iproute2/rt_tables(及rt_tables.d/*.conf)中的名称，
+// 没有命名的VRF表以绑定它的VRF设备命名。首次使用时加载，遇到未知的表ID时按需刷新(最多每5秒一次)
+
+// 表名称，没有名称时为十进制ID
+std::string route_table_name(uint32_t table);
+
+// 解析名称或数字ID(支持0x前缀)，未知名称返回false
+bool parse_route_table(const std::string& text, uint32_t& table);
+
+// 重新读取映射，VRF设备可能在启动后才创建
+void reload_route_table_names();
+
+// 已知的映射，按ID排序
+std::vector<std::pair<uint32_t, std::string>> route_table_names();
@@ -0,0 +1,138 @@
+#include "run_manifest.h"
+#include "convergence_monitor.h"
+#include <cstdio>
+#include <cstring>
+#include <map>
+#include <net/if.h>
+#include <net/if_arp.h>
+#include <sstream>
+#include <sys/ioctl.h>
+#include <sys/socket.h>
+#include <sys/utsname.h>
+#include <unistd.h>
+
+namespace {
+
+std::string quote(const std::string& text) {
+    return "\"" + Logger::escape_json_string(text) + "\"";
+}
+
+// 按首次出现的顺序列出选项；出现多次的选项(如--tag)为字符串数组
+std::string options_json(const std::vector<std::pair<std::string, std::string>>& options) {
+    std::vector<std::string> order;
+    std::map<std::string, std::vector<std::string>> values;
+    for (const auto& option : options) {
+        if (values.find(option.first) == values.end()) {
+            order.push_back(option.first);
+        }
+        values[option.first].push_back(option.second);
+    }
+
+    std::ostringstream oss;
+    oss << "{";
+    for (size_t i = 0; i < order.size(); ++i) {
+        const auto& items = values[order[i]];
+        oss << (i ? "," : "") << quote(order[i]) << ":";
+        if (items.size() == 1) {
+            oss << quote(items.front());
+        } else {
+            oss << "[";
+            for (size_t j = 0; j < items.size(); ++j) {
+                oss << (j ? "," : "") << quote(items[j]);
+            }
+            oss << "]";
+        }
+    }
+    oss << "}";
+    return oss.str();
+}
+
+} // namespace
+
+std::string interface_inventory_json(int64_t& count) {
+    count = 0;
+    struct if_nameindex* interfaces = if_nameindex();
+    if (interfaces == nullptr) {
+        return "[]";
+    }
+    // ioctl作用于套接字所在的网络命名空间，--netns时也能得到正确的结果(/sys/class/net则不一定)
+    int fd = socket(AF_INET, SOCK_DGRAM | SOCK_CLOEXEC, 0);
+
+    std::ostringstream oss;
+    oss << "[";
+    for (struct if_nameindex* it = interfaces; it->if_index != 0 && it->if_name != nullptr; ++it) {
+        struct ifreq ifr;
+        memset(&ifr, 0, sizeof(ifr));
+        strncpy(ifr.ifr_name, it->if_name, IFNAMSIZ - 1);
+
+        std::string state = "unknown";
+        int64_t mtu = 0;
+        std::string mac;
+        if (fd >= 0 && ioctl(fd, SIOCGIFFLAGS, &ifr) == 0) {
+            state = (ifr.ifr_flags & IFF_UP) ? ((ifr.ifr_flags & IFF_RUNNING) ? "up" : "no-carrier") : "down";
+        }
+        if (fd >= 0 && ioctl(fd, SIOCGIFMTU, &ifr) == 0) {
+            mtu = ifr.ifr_mtu;
+        }
+        if (fd >= 0 && ioctl(fd, SIOCGIFHWADDR, &ifr) == 0 && ifr.ifr_hwaddr.sa_family == ARPHRD_ETHER) {
+            const auto* addr = reinterpret_cast<const unsigned char*>(ifr.ifr_hwaddr.sa_data);
+            char buffer[18];
+            snprintf(buffer, sizeof(buffer), "%02x:%02x:%02x:%02x:%02x:%02x",
+                     addr[0], addr[1], addr[2], addr[3], addr[4], addr[5]);
+            mac = buffer;
+        }
+
+        oss << (count ? "," : "") << "{\"name\":" << quote(it->if_name) << ",\"ifindex\":" << it->if_index
+            << ",\"state\":" << quote(state) << ",\"mtu\":" << mtu << ",\"mac\":" << quote(mac) << "}";
+        count++;
+    }
+    oss << "]";
+
+    if (fd >= 0) {
+        close(fd);
+    }
+    if_freenameindex(interfaces);
+    return oss.str();
+}
+
+JsonObject create_run_manifest(const MonitorConfig& config) {
+    JsonObject manifest;
+    manifest["version"] = CONVERGE_VERSION;
+    manifest["git_commit"] = CONVERGE_GIT_COMMIT;
+    manifest["compiler"] = __VERSION__;
+
+    struct utsname name;
+    if (uname(&name) == 0) {
+        manifest["hostname"] = name.nodename;
+        manifest["kernel_release"] = name.release;
+        manifest["kernel_version"] = name.version;
+        manifest["machine"] = name.machine;
+    }
+    manifest["pid"] = static_cast<int64_t>(getpid());
+
+    manifest["command_line"] = config.command_line;
+    std::string variables;
+    for (const auto& variable : config.env_variables) {
+        variables += (variables.empty() ? "" : ",") + variable;
+    }
+    manifest["env_options"] = variables;
+    manifest["options"] = options_json(config.run_options);
+
+    // 配置文件加载后的生效值，与命令行可能不同
+    manifest["convergence_threshold_ms"] = config.convergence_threshold_ms;
+    manifest["threshold_overrides"] = config.threshold_overrides.text();
+    manifest["config_path"] = config.config_path;
+    manifest["filter_interfaces"] = config.filter.interfaces_text();
+    manifest["filter_prefixes"] = config.filter.prefixes_text();
+    manifest["filter_tables"] = config.filter.tables_text();
+    manifest["trigger_rules"] = config.trigger_rules.triggers_text();
+    manifest["ignore_rules"] = config.trigger_rules.ignores_text();
+    if (config.route_only) {
+        manifest["route_only"] = true;
+    }
+
+    int64_t interface_count = 0;
+    manifest["interfaces"] = interface_inventory_json(interface_count);
+    manifest["interface_count"] = interface_count;
+    return manifest;
+}
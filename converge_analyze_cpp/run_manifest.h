@@ -0,0 +1,20 @@
+#pragma once
+
+#include "logger.h"
+
+struct MonitorConfig;
+
+// 构建版本与提交，由CMake在编译时传入
+#ifndef CONVERGE_VERSION
+#define CONVERGE_VERSION "unknown"
+#endif
+#ifndef CONVERGE_GIT_COMMIT
+#define CONVERGE_GIT_COMMIT "unknown"
+#endif
+
+// run_started记录的内容：版本、内核、命令行与生效的选项、过滤条件及接口清单，使日志自带复现所需的信息。
+// 选项与接口清单以JSON字符串形式写入(与trigger_info相同)，不含event_type等公共字段
+JsonObject create_run_manifest(const MonitorConfig& config);
+
+// 当前网络命名空间内的接口清单，JSON数组：[{"name","ifindex","state","mtu","mac"}]
+std::string interface_inventory_json(int64_t& count);
@@ -0,0 +1,481 @@
+#include "schema.h"
+#include "log_reader.h"
+#include <fstream>
+#include <getopt.h>
+#include <iostream>
+#include <map>
+#include <sstream>
+
+namespace {
+
+constexpr FieldType S = FieldType::STRING;
+constexpr FieldType I = FieldType::INTEGER;
+constexpr FieldType N = FieldType::NUMBER;
+constexpr FieldType B = FieldType::BOOLEAN;
+constexpr FieldType T = FieldType::TIMESTAMP;
+
+// 监控进程写出的记录都带有 create_event_log 生成的公共字段
+RecordSchema monitor_record(const std::string& event_type, const std::string& description,
+                            std::vector<SchemaField> fields) {
+    std::vector<SchemaField> all = {
+        {"event_type", S, true},
+        {"schema_version", I, true},
+        {"router_name", S, true},
+        {"user", S, true},
+        {"timestamp", T, true},
+    };
+    all.insert(all.end(), fields.begin(), fields.end());
+    return {event_type, description, all};
+}
+
+// merge/analyze 子命令输出的记录只带 event_type/schema_version
+RecordSchema merge_record(const std::string& event_type, const std::string& description,
+                          std::vector<SchemaField> fields) {
+    std::vector<SchemaField> all = {
+        {"event_type", S, true},
+        {"schema_version", I, true},
+    };
+    all.insert(all.end(), fields.begin(), fields.end());
+    return {event_type, description, all};
+}
+
+std::vector<RecordSchema> build_schemas() {
+    return {
+        monitor_record("run_started", "Run manifest written before monitoring starts", {
+            {"monitor_id", S, true}, {"log_file_path", S, true}, {"version", S, true},
+            {"git_commit", S, true}, {"command_line", S, true}, {"options", S, true},
+            {"convergence_threshold_ms", I, true}, {"threshold_overrides", S, false},
+            {"trigger_rules", S, false}, {"ignore_rules", S, false},
+            {"interfaces", S, true},
+            {"interface_count", I, true}, {"hostname", S, false}, {"kernel_release", S, false},
+            {"kernel_version", S, false}, {"machine", S, false}, {"pid", I, false},
+            {"resumed_sessions", I, false}, {"route_only", B, false},
+        }),
+        monitor_record("monitoring_started", "Monitor started listening", {
+            {"monitor_id", S, true}, {"log_file_path", S, true},
+            {"convergence_threshold_ms", I, true}, {"utc_time", S, true},
+            {"listen_start_time", T, true},
+        }),
+        monitor_record("monitoring_completed", "Final statistics written on exit", {
+            {"monitor_id", S, true}, {"log_file_path", S, true},
+            {"total_listen_duration_ms", I, true}, {"total_listen_duration_seconds", N, true},
+            {"convergence_threshold_ms", I, true}, {"total_trigger_events", I, true},
+            {"netem_events_count", I, true}, {"route_events_in_trigger", I, true},
+            {"total_route_events", I, true}, {"completed_sessions_count", I, true},
+        }),
+        monitor_record("session_started", "A trigger opened a convergence session", {
+            {"session_id", I, true}, {"trigger_source", S, true},
+            {"trigger_event_type", S, true}, {"trigger_info", S, true}, {"link", S, false},
+            {"convergence_threshold_ms", I, false}, {"threshold_override", S, false},
+        }),
+        monitor_record("session_hook", "Result of the --on-session-complete command", {
+            {"session_id", I, true}, {"exit_code", I, true}, {"duration_ms", I, true},
+            {"hook_timed_out", B, true}, {"output", S, true}, {"error", S, false},
+        }),
+        monitor_record("source_event", "Non-trigger event from an --source plugin", {
+            {"source", S, true}, {"source_event_type", S, true}, {"fields", S, true},
+            {"session_id", I, false}, {"offset_from_trigger_ms", I, false},
+        }),
+        monitor_record("route_event", "Route change observed inside a session", {
+            {"session_id", I, true}, {"route_event_type", S, true},
+            {"route_event_number", I, true}, {"session_event_number", I, true},
+            {"offset_from_trigger_ms", I, true}, {"route_info", S, true},
+        }),
+        monitor_record("route_event_summary", "Per-second route event count in summary mode", {
+            {"session_id", I, true}, {"second", I, true}, {"route_events", I, true},
+            {"session_route_events", I, true}, {"last_offset_from_trigger_ms", I, true},
+        }),
+        monitor_record("session_completed", "Convergence session finished", {
+            {"session_id", I, true}, {"route_events_count", I, true},
+            {"session_duration_ms", I, true}, {"convergence_threshold_ms", I, true},
+            {"netem_info", S, true}, {"convergence_time_ms", I, false},
+            {"timed_out", B, false}, {"forced", B, false}, {"link", S, false},
+            {"trigger_interface", S, false},
+            {"convergence_criterion", S, false}, {"kernel_convergence_time_ms", N, false},
+            {"dataplane_restored", B, false}, {"dataplane_restoration_time_ms", N, false},
+            {"micro_loop_detected", B, false}, {"default_route_restored", B, false},
+            {"anomaly", B, false}, {"anomaly_z_score", N, false},
+            {"threshold_override", S, false},
+        }),
+        monitor_record("anomaly_detected", "Session convergence time deviated from the rolling baseline", {
+            {"session_id", I, true}, {"anomaly_type", S, true}, {"direction", S, true},
+            {"convergence_time_ms", I, true}, {"baseline_mean_ms", N, true},
+            {"baseline_stddev_ms", N, true}, {"baseline_sessions", I, true}, {"z_score", N, true},
+            {"sigma", N, true},
+        }),
+        monitor_record("netem_detected", "Netem qdisc change on a monitored interface", {
+            {"netem_event_type", S, true}, {"qdisc_info", S, false}, {"link", S, false},
+        }),
+        monitor_record("trigger_debounced", "Trigger suppressed during an open session", {
+            {"session_id", I, true}, {"qdisc_event_type", S, true},
+            {"offset_from_trigger_ms", I, true},
+        }),
+        monitor_record("link_event", "Interface link state change", {
+            {"link_event_type", S, true}, {"interface", S, true},
+        }),
+        monitor_record("nexthop_changed", "Prefix switched to a different nexthop", {
+            {"prefix", S, true}, {"table", S, true}, {"family", S, true},
+            {"old_nexthop", S, true}, {"new_nexthop", S, true},
+            {"old_gateway", S, true}, {"new_gateway", S, true},
+            {"change", S, true}, {"withdrawn_ms", I, false},
+            {"session_id", I, false}, {"offset_from_trigger_ms", I, false}, {"phase", S, false},
+        }),
+        monitor_record("micro_loop", "Forwarding loop seen by the traceroute probe", {
+            {"session_id", I, true}, {"offset_from_trigger_ms", I, true},
+            {"target", S, true}, {"hops", S, false},
+        }),
+        monitor_record("interface_counters", "Interface counter deltas for a session", {
+            {"session_id", I, true}, {"interface", S, true}, {"interval_ms", I, true},
+            {"link", S, false},
+        }),
+        monitor_record("warmup_completed", "Warm-up window ended", {
+            {"warmup_ms", I, true}, {"suppressed_triggers", I, true},
+        }),
+        monitor_record("route_churn_rate", "Route changes per second", {
+            {"second", T, true}, {"route_changes", I, true},
+        }),
+        monitor_record("status_report", "Periodic or on-demand status snapshot", {
+            {"monitor_id", S, true}, {"uptime_ms", I, true},
+            {"convergence_threshold_ms", I, true}, {"route_events", I, false},
+        }),
+        monitor_record("stats_reset", "Statistics reset through the control socket", {
+            {"discarded_sessions", I, true}, {"source", S, true},
+        }),
+        monitor_record("threshold_changed", "Convergence threshold changed at runtime", {
+            {"previous_threshold_ms", I, true}, {"convergence_threshold_ms", I, true},
+            {"source", S, true},
+        }),
+        monitor_record("filter_changed", "Interface/prefix/table filter changed at runtime", {
+            {"filter_interfaces", S, true}, {"filter_prefixes", S, true}, {"filter_tables", S, false},
+            {"source", S, true},
+        }),
+        monitor_record("frr_state", "FRR state snapshot around a session", {
+            {"session_id", I, true}, {"phase", S, true},
+        }),
+        monitor_record("route_table_sample", "Periodic route table size sample", {
+            {"change_since_start", I, false},
+        }),
+        monitor_record("clock_offset", "Clock offset against the --clock-reference (reference minus local)", {
+            {"reference", S, true}, {"offset_ms", N, true}, {"rtt_ms", N, true}, {"probes", I, true},
+            {"session_id", I, false},
+        }),
+        monitor_record("error", "Runtime error or degraded subsystem", {
+            {"severity", S, true}, {"component", S, true}, {"message", S, true},
+            {"session_id", I, false},
+        }),
+        monitor_record("subscription_restarted", "Netlink subscription was re-established", {
+            {"reason", S, true}, {"error", S, true}, {"restart_count", I, true},
+            {"session_id", I, false},
+        }),
+        monitor_record("frr_log_event", "Protocol event parsed from the FRR log", {
+            {"category", S, true}, {"daemon", S, true}, {"message", S, true},
+            {"frr_timestamp", T, true},
+        }),
+        monitor_record("igp_adjacency_event", "IGP adjacency state change", {
+            {"protocol", S, true}, {"neighbor", S, true}, {"interface", S, true},
+            {"old_state", S, true}, {"new_state", S, true}, {"link", S, false},
+        }),
+        monitor_record("snmp_trap", "SNMP trap received", {
+            {"snmp_agent", S, true}, {"trap_oid", S, true}, {"snmp_version", I, true},
+            {"varbinds", S, true},
+        }),
+        monitor_record("bgp_event", "BMP message from a BGP speaker", {
+            {"bmp_message_type", S, true}, {"bmp_router", S, true},
+            {"peer_address", S, false}, {"peer_as", I, false},
+        }),
+        monitor_record("fault_injected", "Fault applied by inject/campaign/clab", {
+            {"interface", S, false},
+        }),
+        monitor_record("fault_cleared", "Fault removed by inject/campaign/clab", {
+            {"interface", S, false},
+        }),
+        merge_record("fault_summary", "One aligned fault across routers (merge --format ndjson)", {
+            {"fault_id", I, true}, {"start_time_ms", I, true}, {"routers_count", I, true},
+            {"global_convergence_ms", I, true}, {"slowest_router", S, true},
+            {"routers", S, false}, {"slowest_router_convergence_ms", I, false}, {"timed_out_routers", I, false},
+            {"prefixes_count", I, false}, {"slowest_prefix", S, false}, {"slowest_prefix_router", S, false},
+            {"slowest_prefix_ms", I, false}, {"link", S, false}, {"netem", S, false}, {"ambiguous_sessions", I, false},
+        }),
+        merge_record("fault_router_session", "One router's session within an aligned fault", {
+            {"fault_id", I, true}, {"trigger_offset_ms", I, true},
+            {"router_name", S, true}, {"session_id", I, true}, {"clock_correction_ms", I, false},
+        }),
+        merge_record("analyze_session", "One session re-derived by analyze at the what-if threshold", {
+            {"router_name", S, true}, {"session_id", I, true}, {"start_timestamp", T, true},
+            {"threshold_ms", I, true}, {"original_sessions", S, true}, {"route_events_count", I, true},
+            {"convergence_time_ms", I, false}, {"timed_out", B, true},
+        }),
+        merge_record("analyze_summary", "Original vs what-if totals (last line of analyze --format ndjson)", {
+            {"original_threshold_ms", I, true}, {"threshold_ms", I, true},
+            {"original_sessions", I, true}, {"sessions", I, true},
+            {"original_timed_out", I, true}, {"timed_out", I, true},
+            {"merged_sessions", I, true}, {"split_sessions", I, true},
+            {"replayed_events", I, true}, {"filtered_events", I, true},
+            {"threshold_overrides", S, false}, {"filter_interfaces", S, false}, {"filter_prefixes", S, false},
+            {"original_median_ms", N, false}, {"original_p95_ms", N, false}, {"original_max_ms", N, false},
+            {"median_ms", N, false}, {"p95_ms", N, false}, {"max_ms", N, false},
+        }),
+        merge_record("analyze_sweep_point", "One threshold of analyze --sweep", {
+            {"threshold_ms", I, true}, {"sessions", I, true}, {"timed_out", I, true},
+            {"mean_ms", N, false}, {"median_ms", N, false}, {"p95_ms", N, false}, {"max_ms", N, false},
+            {"median_change_pct", N, false},
+        }),
+        merge_record("analyze_sweep_summary", "Stable plateau of a threshold sweep (last line of analyze --sweep)", {
+            {"original_threshold_ms", I, true}, {"points", I, true},
+            {"original_in_plateau", B, true},
+            {"plateau_from_ms", I, false}, {"plateau_to_ms", I, false}, {"plateau_sessions", I, false}, {"plateau_median_min_ms", N, false}, {"plateau_median_max_ms", N, false},
+        }),
+    };
+}
+
+const char* type_name(FieldType type) {
+    switch (type) {
+        case FieldType::STRING: return "string";
+        case FieldType::INTEGER: return "integer";
+        case FieldType::NUMBER: return "number";
+        case FieldType::BOOLEAN: return "boolean";
+        case FieldType::TIMESTAMP: return "timestamp";
+    }
+    return "string";
+}
+
+bool type_matches(FieldType expected, const JsonValue& value) {
+    switch (expected) {
+        case FieldType::STRING: return value.get_type() == JsonValue::STRING;
+        case FieldType::INTEGER: return value.get_type() == JsonValue::INT64;
+        case FieldType::NUMBER:
+            return value.get_type() == JsonValue::INT64 || value.get_type() == JsonValue::DOUBLE;
+        case FieldType::BOOLEAN: return value.get_type() == JsonValue::BOOL;
+        case FieldType::TIMESTAMP:
+            return value.get_type() == JsonValue::STRING || value.get_type() == JsonValue::INT64;
+    }
+    return false;
+}
+
+std::string quote(const std::string& text) {
+    return "\"" + Logger::escape_json_string(text) + "\"";
+}
+
+void write_properties(std::ostringstream& out, const std::vector<SchemaField>& fields,
+                      const std::string& indent) {
+    out << indent << "\"properties\": {\n";
+    for (size_t i = 0; i < fields.size(); ++i) {
+        std::string type = fields[i].type == FieldType::TIMESTAMP
+            ? "[\"string\", \"integer\"]" : quote(type_name(fields[i].type));
+        out << indent << "  " << quote(fields[i].name) << ": {\"type\": " << type << "}"
+            << (i + 1 < fields.size() ? "," : "") << "\n";
+    }
+    out << indent << "}";
+}
+
+void write_required(std::ostringstream& out, const std::vector<SchemaField>& fields,
+                    const std::string& indent) {
+    out << indent << "\"required\": [";
+    bool first = true;
+    for (const auto& field : fields) {
+        if (!field.required) {
+            continue;
+        }
+        out << (first ? "" : ", ") << quote(field.name);
+        first = false;
+    }
+    out << "]";
+}
+
+void print_schema_usage(const char* program_name) {
+    std::cout << "用法: " << program_name << " schema [--write PATH] [--validate LOG]\n\n";
+    std::cout << "输出全部事件类型的 JSON Schema，或按模式校验已有日志\n\n";
+    std::cout << "选项:\n";
+    std::cout << "  -w, --write PATH        将 JSON Schema 写入文件(默认输出到标准输出)\n";
+    std::cout << "  -V, --validate LOG      逐行校验日志文件(NDJSON)，发现问题时返回1\n";
+    std::cout << "      --max-errors N      最多打印N条问题(默认20)\n";
+    std::cout << "  -h, --help              显示此帮助信息\n";
+}
+
+enum SchemaOption {
+    OPT_MAX_ERRORS = 1000,
+};
+
+int validate_log(const std::string& path, int max_errors) {
+    std::ifstream in(path);
+    if (!in.is_open()) {
+        std::cerr << "❌ 无法打开日志文件: " << path << "\n";
+        return 1;
+    }
+
+    std::map<std::string, int64_t> counts;
+    int64_t records = 0;
+    int64_t invalid = 0;
+    int64_t line_number = 0;
+    std::string line;
+    while (std::getline(in, line)) {
+        ++line_number;
+        if (line.empty()) {
+            continue;
+        }
+        ++records;
+        JsonObject record;
+        std::vector<std::string> problems;
+        if (!LogReader::parse_object(line, record)) {
+            problems.push_back("malformed JSON");
+        } else {
+            problems = RecordSchemas::validate(record);
+            counts[LogReader::get_string(record, "event_type", "(none)")]++;
+        }
+        if (problems.empty()) {
+            continue;
+        }
+        ++invalid;
+        if (invalid <= max_errors) {
+            for (const auto& problem : problems) {
+                std::cerr << path << ":" << line_number << ": " << problem << "\n";
+            }
+        }
+    }
+
+    std::cout << "records: " << records << ", invalid: " << invalid << "\n";
+    for (const auto& pair : counts) {
+        std::cout << "  " << pair.first << ": " << pair.second << "\n";
+    }
+    if (invalid > max_errors) {
+        std::cout << "(" << (invalid - max_errors) << " more invalid records not shown)\n";
+    }
+    return invalid > 0 ? 1 : 0;
+}
+
+} // namespace
+
+const std::vector<RecordSchema>& RecordSchemas::all() {
+    static const std::vector<RecordSchema> schemas = build_schemas();
+    return schemas;
+}
+
+const RecordSchema* RecordSchemas::find(const std::string& event_type) {
+    for (const auto& schema : all()) {
+        if (schema.event_type == event_type) {
+            return &schema;
+        }
+    }
+    return nullptr;
+}
+
+std::string RecordSchemas::to_json_schema() {
+    const auto& schemas = all();
+    std::ostringstream out;
+    out << "{\n";
+    out << "  \"$schema\": \"https://json-schema.org/draft/2020-12/schema\",\n";
+    out << "  \"title\": \"converge_analyze record\",\n";
+    out << "  \"description\": \"One NDJSON line written by converge_analyze, schema_version "
+        << Logger::SCHEMA_VERSION << "\",\n";
+    out << "  \"type\": \"object\",\n";
+    out << "  \"required\": [\"event_type\", \"schema_version\"],\n";
+    out << "  \"properties\": {\n";
+    out << "    \"event_type\": {\"enum\": [";
+    for (size_t i = 0; i < schemas.size(); ++i) {
+        out << (i ? ", " : "") << quote(schemas[i].event_type);
+    }
+    out << "]},\n";
+    out << "    \"schema_version\": {\"const\": " << Logger::SCHEMA_VERSION << "}\n";
+    out << "  },\n";
+    out << "  \"allOf\": [\n";
+    for (size_t i = 0; i < schemas.size(); ++i) {
+        const auto& schema = schemas[i];
+        out << "    {\n";
+        out << "      \"if\": {\"properties\": {\"event_type\": {\"const\": "
+            << quote(schema.event_type) << "}}},\n";
+        out << "      \"then\": {\n";
+        out << "        \"description\": " << quote(schema.description) << ",\n";
+        write_required(out, schema.fields, "        ");
+        out << ",\n";
+        write_properties(out, schema.fields, "        ");
+        out << "\n      }\n";
+        out << "    }" << (i + 1 < schemas.size() ? "," : "") << "\n";
+    }
+    out << "  ]\n";
+    out << "}\n";
+    return out.str();
+}
+
+std::vector<std::string> RecordSchemas::validate(const JsonObject& record) {
+    std::vector<std::string> problems;
+    auto type_it = record.find("event_type");
+    if (type_it == record.end() || type_it->second.get_type() != JsonValue::STRING) {
+        problems.push_back("missing event_type");
+        return problems;
+    }
+    const std::string& event_type = type_it->second.as_string();
+    const RecordSchema* schema = find(event_type);
+    if (!schema) {
+        problems.push_back("unknown event_type " + event_type);
+        return problems;
+    }
+
+    for (const auto& field : schema->fields) {
+        auto it = record.find(field.name);
+        if (it == record.end()) {
+            if (field.required) {
+                problems.push_back(event_type + ": missing required field " + field.name);
+            }
+            continue;
+        }
+        if (!type_matches(field.type, it->second)) {
+            problems.push_back(event_type + ": field " + field.name + " should be " +
+                               type_name(field.type));
+        }
+    }
+
+    auto version_it = record.find("schema_version");
+    if (version_it != record.end() && version_it->second.get_type() == JsonValue::INT64 &&
+        version_it->second.as_int64() != Logger::SCHEMA_VERSION) {
+        problems.push_back(event_type + ": schema_version " +
+                           std::to_string(version_it->second.as_int64()) +
+                           " differs from supported version " +
+                           std::to_string(Logger::SCHEMA_VERSION));
+    }
+    return problems;
+}
+
+int schema_main(int argc, char* argv[]) {
+    std::string write_path;
+    std::string validate_path;
+    int max_errors = 20;
+
+    static struct option long_options[] = {
+        {"write", required_argument, 0, 'w'},
+        {"validate", required_argument, 0, 'V'},
+        {"max-errors", required_argument, 0, OPT_MAX_ERRORS},
+        {"help", no_argument, 0, 'h'},
+        {0, 0, 0, 0}
+    };
+
+    optind = 1;
+    int c;
+    while ((c = getopt_long(argc, argv, "w:V:h", long_options, nullptr)) != -1) {
+        switch (c) {
+            case 'w': write_path = optarg; break;
+            case 'V': validate_path = optarg; break;
+            case OPT_MAX_ERRORS: max_errors = std::stoi(optarg); break;
+            case 'h': print_schema_usage(argv[0]); return 0;
+            default:  print_schema_usage(argv[0]); return 1;
+        }
+    }
+
+    if (!validate_path.empty()) {
+        return validate_log(validate_path, max_errors);
+    }
+
+    std::string document = RecordSchemas::to_json_schema();
+    if (write_path.empty()) {
+        std::cout << document;
+        return 0;
+    }
+    std::ofstream out(write_path);
+    if (!out.is_open()) {
+        std::cerr << "❌ 无法写入文件: " << write_path << "\n";
+        return 1;
+    }
+    out << document;
+    std::cout << "✅ JSON Schema (schema_version " << Logger::SCHEMA_VERSION << ") 已写入 "
+              << write_path << "\n";
+    return 0;
+}
@@ -0,0 +1,40 @@
+#pragma once
+
+#include <string>
+#include <vector>
+#include "logger.h"
+
+// 字段的JSON类型；TIMESTAMP 按 --timestamp-format 为字符串或整数
+enum class FieldType { STRING, INTEGER, NUMBER, BOOLEAN, TIMESTAMP };
+
+struct SchemaField {
+    std::string name;
+    FieldType type;
+    bool required;
+};
+
+// 一种事件类型的记录格式
+struct RecordSchema {
+    std::string event_type;
+    std::string description;
+    std::vector<SchemaField> fields;
+};
+
+class RecordSchemas {
+public:
+    // 全部事件类型，公共字段(event_type/schema_version等)已包含在各条目中
+    static const std::vector<RecordSchema>& all();
+
+    // 按事件类型查找，未知类型返回nullptr
+    static const RecordSchema* find(const std::string& event_type);
+
+    // 生成 JSON Schema (draft 2020-12) 文档
+    static std::string to_json_schema();
+
+    // 校验单条记录，返回发现的问题，为空表示通过
+    // 未在模式中声明的字段视为扩展字段(如 label_*)，不报错
+    static std::vector<std::string> validate(const JsonObject& record);
+};
+
+// schema 子命令入口
+int schema_main(int argc, char* argv[]);
@@ -0,0 +1,88 @@
+#include "session_hook.h"
+#include "subprocess.h"
+#include <chrono>
+
+SessionHook::SessionHook(const std::string& command, int64_t timeout_ms, Callback callback)
+    : command_(command), timeout_ms_(timeout_ms), callback_(std::move(callback)) {
+}
+
+SessionHook::~SessionHook() {
+    stop();
+}
+
+void SessionHook::start() {
+    if (running_.load()) {
+        return;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&SessionHook::worker_loop, this);
+}
+
+void SessionHook::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    queue_cv_.notify_all();
+
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+}
+
+void SessionHook::run(int64_t session_id, const std::string& session_json, const std::vector<std::string>& env) {
+    std::unique_lock<std::mutex> lock(queue_mutex_);
+
+    // 钩子执行慢于会话完成的速度时丢弃最早的
+    if (pending_.size() >= MAX_PENDING) {
+        pending_.pop();
+        dropped_count_.fetch_add(1);
+    }
+
+    pending_.push(Job{session_id, session_json + "\n", env});
+    lock.unlock();
+
+    queue_cv_.notify_one();
+}
+
+void SessionHook::worker_loop() {
+    std::unique_lock<std::mutex> lock(queue_mutex_);
+
+    while (running_.load() || !pending_.empty()) {
+        queue_cv_.wait(lock, [this] {
+            return !pending_.empty() || !running_.load();
+        });
+
+        while (!pending_.empty()) {
+            Job job = std::move(pending_.front());
+            pending_.pop();
+            lock.unlock();
+
+            SessionHookResult result;
+            result.session_id = job.session_id;
+            auto started = std::chrono::steady_clock::now();
+            result.exit_code = run_command_input({"/bin/sh", "-c", command_}, job.input, job.env, timeout_ms_,
+                                                 result.output, result.timed_out, result.error);
+            result.duration_ms = std::chrono::duration_cast<std::chrono::milliseconds>(
+                std::chrono::steady_clock::now() - started).count();
+            if (result.timed_out) {
+                result.error = "timed out after " + std::to_string(timeout_ms_) + "ms";
+            }
+            if (result.output.size() > MAX_OUTPUT_BYTES) {
+                result.output.resize(MAX_OUTPUT_BYTES);
+            }
+
+            run_count_.fetch_add(1);
+            if (result.exit_code != 0) {
+                failed_count_.fetch_add(1);
+            }
+            if (callback_) {
+                callback_(result);
+            }
+
+            lock.lock();
+        }
+    }
+}
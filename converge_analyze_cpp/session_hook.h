@@ -0,0 +1,74 @@
+#pragma once
+
+#include <atomic>
+#include <condition_variable>
+#include <cstdint>
+#include <functional>
+#include <mutex>
+#include <queue>
+#include <string>
+#include <thread>
+#include <vector>
+
+// 一次钩子命令的执行结果
+struct SessionHookResult {
+    int64_t session_id = 0;
+    int exit_code = -1;        // 无法启动或超时时为-1
+    bool timed_out = false;
+    int64_t duration_ms = 0;
+    std::string output;        // 合并的stdout/stderr，截断到MAX_OUTPUT_BYTES
+    std::string error;
+};
+
+// 会话完成钩子(--on-session-complete)：每个会话结束后经/bin/sh -c执行用户命令，
+// session_completed记录的JSON写入其stdin，摘要字段同时以CONVERGE_*环境变量提供。
+// 命令在独立线程中按会话顺序逐个执行，不阻塞收敛检查
+class SessionHook {
+public:
+    using Callback = std::function<void(const SessionHookResult&)>;
+
+    static constexpr size_t MAX_PENDING = 100;
+    static constexpr size_t MAX_OUTPUT_BYTES = 4096;
+
+private:
+    struct Job {
+        int64_t session_id = 0;
+        std::string input;
+        std::vector<std::string> env;
+    };
+
+    std::string command_;
+    int64_t timeout_ms_;
+    Callback callback_;
+
+    std::queue<Job> pending_;
+    std::mutex queue_mutex_;
+    std::condition_variable queue_cv_;
+
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+
+    std::atomic<int64_t> run_count_{0};
+    std::atomic<int64_t> failed_count_{0};
+    std::atomic<int64_t> dropped_count_{0};
+
+    void worker_loop();
+
+public:
+    SessionHook(const std::string& command, int64_t timeout_ms, Callback callback);
+    ~SessionHook();
+
+    SessionHook(const SessionHook&) = delete;
+    SessionHook& operator=(const SessionHook&) = delete;
+
+    void start();
+    // 停止前执行完队列中的钩子
+    void stop();
+
+    // 入队一次执行；env为"KEY=VALUE"形式
+    void run(int64_t session_id, const std::string& session_json, const std::vector<std::string>& env);
+
+    int64_t run_count() const { return run_count_.load(); }
+    int64_t failed_count() const { return failed_count_.load(); }
+    int64_t dropped_count() const { return dropped_count_.load(); }
+};
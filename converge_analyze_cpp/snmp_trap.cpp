@@ -0,0 +1,376 @@
+#include "snmp_trap.h"
+#include "listen_socket.h"
+#include <arpa/inet.h>
+#include <cerrno>
+#include <chrono>
+#include <netinet/in.h>
+#include <poll.h>
+#include <sys/socket.h>
+#include <unistd.h>
+
+namespace {
+
+const std::string SNMP_TRAP_OID = "1.3.6.1.6.3.1.1.4.1.0";
+const std::string SYS_UPTIME = "1.3.6.1.2.1.1.3.0";
+
+const std::map<std::string, std::string> TRAP_NAMES = {
+    {"1.3.6.1.6.3.1.1.5.1", "coldStart"},
+    {"1.3.6.1.6.3.1.1.5.2", "warmStart"},
+    {"1.3.6.1.6.3.1.1.5.3", "linkDown"},
+    {"1.3.6.1.6.3.1.1.5.4", "linkUp"},
+    {"1.3.6.1.2.1.14.16.2.2", "ospfNbrStateChange"},
+    {"1.3.6.1.2.1.14.16.2.3", "ospfVirtNbrStateChange"},
+    {"1.3.6.1.2.1.14.16.2.16", "ospfIfStateChange"},
+    {"1.3.6.1.2.1.15.0.1", "bgpEstablishedNotification"},
+    {"1.3.6.1.2.1.15.0.2", "bgpBackwardTransNotification"},
+    {"1.3.6.1.2.1.15.7.1", "bgpEstablished"},
+    {"1.3.6.1.2.1.15.7.2", "bgpBackwardTransition"},
+    {"1.3.6.1.2.1.138.0.17", "isisAdjacencyChange"},
+};
+
+// 表列OID前缀(后接索引)
+const std::string IF_INDEX = "1.3.6.1.2.1.2.2.1.1.";
+const std::string IF_DESCR = "1.3.6.1.2.1.2.2.1.2.";
+const std::string IF_NAME = "1.3.6.1.2.1.31.1.1.1.1.";
+
+bool starts_with(const std::string& text, const std::string& prefix) {
+    return text.compare(0, prefix.size(), prefix) == 0;
+}
+
+// BER TLV读取器
+class BerReader {
+    const uint8_t* data_;
+    size_t length_;
+    size_t pos_ = 0;
+
+public:
+    BerReader(const uint8_t* data, size_t length) : data_(data), length_(length) {}
+
+    bool at_end() const { return pos_ >= length_; }
+
+    bool read(uint8_t& tag, const uint8_t*& value, size_t& value_length) {
+        if (pos_ + 2 > length_) {
+            return false;
+        }
+        tag = data_[pos_++];
+        size_t len = data_[pos_++];
+        if (len & 0x80) {
+            size_t bytes = len & 0x7f;
+            if (bytes == 0 || bytes > 4 || pos_ + bytes > length_) {
+                return false;
+            }
+            len = 0;
+            for (size_t i = 0; i < bytes; ++i) {
+                len = (len << 8) | data_[pos_++];
+            }
+        }
+        if (pos_ + len > length_) {
+            return false;
+        }
+        value = data_ + pos_;
+        value_length = len;
+        pos_ += len;
+        return true;
+    }
+
+    bool expect(uint8_t expected_tag, BerReader& inner) {
+        uint8_t tag;
+        const uint8_t* value;
+        size_t len;
+        if (!read(tag, value, len) || tag != expected_tag) {
+            return false;
+        }
+        inner = BerReader(value, len);
+        return true;
+    }
+};
+
+int64_t decode_integer(const uint8_t* value, size_t length) {
+    int64_t result = (length > 0 && (value[0] & 0x80)) ? -1 : 0;
+    for (size_t i = 0; i < length && i < 8; ++i) {
+        result = (result << 8) | value[i];
+    }
+    return result;
+}
+
+uint64_t decode_unsigned(const uint8_t* value, size_t length) {
+    uint64_t result = 0;
+    for (size_t i = 0; i < length && i < 9; ++i) {
+        result = (result << 8) | value[i];
+    }
+    return result;
+}
+
+std::string decode_oid(const uint8_t* value, size_t length) {
+    if (length == 0) {
+        return "";
+    }
+    std::string oid = std::to_string(value[0] / 40) + "." + std::to_string(value[0] % 40);
+    uint64_t sub = 0;
+    for (size_t i = 1; i < length; ++i) {
+        sub = (sub << 7) | (value[i] & 0x7f);
+        if (!(value[i] & 0x80)) {
+            oid += "." + std::to_string(sub);
+            sub = 0;
+        }
+    }
+    return oid;
+}
+
+std::string decode_octets(const uint8_t* value, size_t length) {
+    bool printable = true;
+    for (size_t i = 0; i < length; ++i) {
+        if ((value[i] < 0x20 || value[i] > 0x7e) && !(i == length - 1 && value[i] == 0)) {
+            printable = false;
+            break;
+        }
+    }
+    if (printable) {
+        std::string text(reinterpret_cast<const char*>(value), length);
+        if (!text.empty() && text.back() == '\0') {
+            text.pop_back();
+        }
+        return text;
+    }
+
+    static const char* hex = "0123456789abcdef";
+    std::string text;
+    for (size_t i = 0; i < length; ++i) {
+        if (i) text += ':';
+        text += hex[value[i] >> 4];
+        text += hex[value[i] & 0x0f];
+    }
+    return text;
+}
+
+std::string decode_value(uint8_t tag, const uint8_t* value, size_t length) {
+    switch (tag) {
+        case 0x02: return std::to_string(decode_integer(value, length));
+        case 0x04: return decode_octets(value, length);
+        case 0x05: return "";
+        case 0x06: return decode_oid(value, length);
+        case 0x40:  // IpAddress
+            if (length == 4) {
+                char buf[INET_ADDRSTRLEN];
+                inet_ntop(AF_INET, value, buf, sizeof(buf));
+                return buf;
+            }
+            return decode_octets(value, length);
+        case 0x41: case 0x42: case 0x43: case 0x46:  // Counter32/Gauge32/TimeTicks/Counter64
+            return std::to_string(decode_unsigned(value, length));
+        default:
+            return decode_octets(value, length);
+    }
+}
+
+bool parse_varbinds(BerReader& reader, SnmpTrap& trap) {
+    BerReader list(nullptr, 0);
+    if (!reader.expect(0x30, list)) {
+        return false;
+    }
+    while (!list.at_end()) {
+        BerReader varbind(nullptr, 0);
+        if (!list.expect(0x30, varbind)) {
+            return false;
+        }
+        uint8_t tag;
+        const uint8_t* value;
+        size_t len;
+        if (!varbind.read(tag, value, len) || tag != 0x06) {
+            return false;
+        }
+        std::string oid = decode_oid(value, len);
+        if (!varbind.read(tag, value, len)) {
+            return false;
+        }
+        trap.varbinds[oid] = decode_value(tag, value, len);
+    }
+    return true;
+}
+
+} // namespace
+
+std::string SnmpTrap::name() const {
+    auto it = TRAP_NAMES.find(trap_oid);
+    return it == TRAP_NAMES.end() ? "" : it->second;
+}
+
+std::string SnmpTrap::interface() const {
+    for (const auto* prefix : {&IF_NAME, &IF_DESCR}) {
+        for (const auto& varbind : varbinds) {
+            if (starts_with(varbind.first, *prefix) && !varbind.second.empty()) {
+                return varbind.second;
+            }
+        }
+    }
+    for (const auto& varbind : varbinds) {
+        if (starts_with(varbind.first, IF_INDEX)) {
+            return "ifIndex " + varbind.second;
+        }
+    }
+    return "";
+}
+
+bool SnmpTrapReceiver::parse_packet(const uint8_t* data, size_t length, SnmpTrap& trap) {
+    BerReader packet(data, length);
+    BerReader message(nullptr, 0);
+    if (!packet.expect(0x30, message)) {
+        return false;
+    }
+
+    uint8_t tag;
+    const uint8_t* value;
+    size_t len;
+    if (!message.read(tag, value, len) || tag != 0x02) {
+        return false;
+    }
+    int64_t version = decode_integer(value, len);
+    if (!message.read(tag, value, len) || tag != 0x04) {
+        return false;
+    }
+    trap.community = std::string(reinterpret_cast<const char*>(value), len);
+
+    if (!message.read(tag, value, len)) {
+        return false;
+    }
+    BerReader pdu(value, len);
+
+    if (version == 0 && tag == 0xa4) {
+        // v1 Trap-PDU: enterprise, agent-addr, generic-trap, specific-trap, time-stamp, varbinds
+        trap.version = 1;
+        if (!pdu.read(tag, value, len) || tag != 0x06) {
+            return false;
+        }
+        std::string enterprise = decode_oid(value, len);
+        if (!pdu.read(tag, value, len)) {
+            return false;
+        }
+        trap.varbinds["agent-addr"] = decode_value(tag, value, len);
+        if (!pdu.read(tag, value, len) || tag != 0x02) {
+            return false;
+        }
+        int64_t generic = decode_integer(value, len);
+        if (!pdu.read(tag, value, len) || tag != 0x02) {
+            return false;
+        }
+        int64_t specific = decode_integer(value, len);
+        if (!pdu.read(tag, value, len)) {
+            return false;
+        }
+        trap.varbinds[SYS_UPTIME] = decode_value(tag, value, len);
+
+        // RFC 3584 3.1: 通用trap映射为snmpTraps.(generic+1)，企业trap为enterprise.0.specific
+        // OSPF/BGP等MIB的trap OID没有".0"这一级(如ospfTraps.2)，优先匹配已知名称
+        if (generic == 6) {
+            std::string direct = enterprise + "." + std::to_string(specific);
+            trap.trap_oid = TRAP_NAMES.count(direct) ? direct : enterprise + ".0." + std::to_string(specific);
+        } else {
+            trap.trap_oid = "1.3.6.1.6.3.1.1.5." + std::to_string(generic + 1);
+        }
+        return parse_varbinds(pdu, trap);
+    }
+
+    if (version == 1 && tag == 0xa7) {
+        // v2c SNMPv2-Trap-PDU: request-id, error-status, error-index, varbinds
+        trap.version = 2;
+        for (int i = 0; i < 3; ++i) {
+            if (!pdu.read(tag, value, len) || tag != 0x02) {
+                return false;
+            }
+        }
+        if (!parse_varbinds(pdu, trap)) {
+            return false;
+        }
+        auto it = trap.varbinds.find(SNMP_TRAP_OID);
+        if (it == trap.varbinds.end()) {
+            return false;
+        }
+        trap.trap_oid = it->second;
+        return true;
+    }
+
+    return false;
+}
+
+SnmpTrapReceiver::SnmpTrapReceiver(const std::string& listen_address, int port,
+                                   const std::string& community, Callback callback)
+    : listen_address_(listen_address), port_(port), community_(community), callback_(std::move(callback)) {
+}
+
+SnmpTrapReceiver::~SnmpTrapReceiver() {
+    stop();
+}
+
+bool SnmpTrapReceiver::start(std::string& error) {
+    if (running_.load()) {
+        return true;
+    }
+
+    socket_fd_ = open_listen_socket(listen_address_, port_, SOCK_DGRAM, error);
+    if (socket_fd_ < 0) {
+        return false;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&SnmpTrapReceiver::worker_loop, this);
+    return true;
+}
+
+void SnmpTrapReceiver::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+    if (socket_fd_ >= 0) {
+        close(socket_fd_);
+        socket_fd_ = -1;
+    }
+}
+
+void SnmpTrapReceiver::worker_loop() {
+    uint8_t buffer[65536];
+
+    while (running_.load()) {
+        struct pollfd pfd = {socket_fd_, POLLIN, 0};
+        if (poll(&pfd, 1, 100) <= 0) {
+            continue;
+        }
+
+        struct sockaddr_storage peer;
+        socklen_t peer_length = sizeof(peer);
+        ssize_t len = recvfrom(socket_fd_, buffer, sizeof(buffer), 0,
+                               reinterpret_cast<struct sockaddr*>(&peer), &peer_length);
+        if (len <= 0) {
+            continue;
+        }
+
+        SnmpTrap trap;
+        if (!parse_packet(buffer, static_cast<size_t>(len), trap)) {
+            continue;
+        }
+        if (!community_.empty() && trap.community != community_) {
+            continue;
+        }
+
+        trap.timestamp_ms = std::chrono::duration_cast<std::chrono::milliseconds>(
+            std::chrono::system_clock::now().time_since_epoch()).count();
+        char host[INET6_ADDRSTRLEN] = {0};
+        if (peer.ss_family == AF_INET6) {
+            auto* addr6 = reinterpret_cast<struct sockaddr_in6*>(&peer);
+            if (IN6_IS_ADDR_V4MAPPED(&addr6->sin6_addr)) {
+                inet_ntop(AF_INET, &addr6->sin6_addr.s6_addr[12], host, sizeof(host));
+            } else {
+                inet_ntop(AF_INET6, &addr6->sin6_addr, host, sizeof(host));
+            }
+        } else {
+            inet_ntop(AF_INET, &reinterpret_cast<struct sockaddr_in*>(&peer)->sin_addr, host, sizeof(host));
+        }
+        trap.source = host;
+
+        callback_(trap);
+    }
+}
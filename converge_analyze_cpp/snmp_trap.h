@@ -0,0 +1,54 @@
+#pragma once
+
+#include <atomic>
+#include <cstdint>
+#include <functional>
+#include <map>
+#include <string>
+#include <thread>
+
+// 解析后的SNMP Trap(v1/v2c)
+struct SnmpTrap {
+    int64_t timestamp_ms = 0;                       // 接收时间
+    std::string source;                             // 发送方地址
+    int version = 0;                                // 1 或 2 (v2c)
+    std::string community;
+    std::string trap_oid;                           // v1 trap按RFC 3584转换为v2c形式
+    std::map<std::string, std::string> varbinds;    // OID -> 值
+
+    // 已知trap的名称(linkDown、ospfNbrStateChange等)，未知时返回空
+    std::string name() const;
+    // 从ifName/ifDescr/ifIndex推断接口，未知时返回空
+    std::string interface() const;
+};
+
+// UDP Trap接收器，将链路与路由协议trap转换为会话事件
+class SnmpTrapReceiver {
+public:
+    using Callback = std::function<void(const SnmpTrap&)>;
+
+private:
+    std::string listen_address_;
+    int port_;
+    std::string community_;  // 非空时丢弃community不匹配的trap
+    Callback callback_;
+    int socket_fd_ = -1;
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+
+    void worker_loop();
+
+public:
+    SnmpTrapReceiver(const std::string& listen_address, int port, const std::string& community,
+                     Callback callback);
+    ~SnmpTrapReceiver();
+
+    SnmpTrapReceiver(const SnmpTrapReceiver&) = delete;
+    SnmpTrapReceiver& operator=(const SnmpTrapReceiver&) = delete;
+
+    bool start(std::string& error);
+    void stop();
+
+    // 解析一个UDP报文，不支持v3与Inform
+    static bool parse_packet(const uint8_t* data, size_t length, SnmpTrap& trap);
+};
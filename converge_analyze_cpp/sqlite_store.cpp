@@ -0,0 +1,281 @@
+#include "sqlite_store.h"
+#include "log_reader.h"
+
+#ifdef HAVE_SQLITE3
+#include <sqlite3.h>
+
+namespace {
+
+const char* SCHEMA_SQL = R"SQL(
+CREATE TABLE IF NOT EXISTS runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    monitor_id TEXT NOT NULL UNIQUE,
+    router_name TEXT,
+    user TEXT,
+    started_at TEXT,
+    completed_at TEXT,
+    convergence_threshold_ms INTEGER,
+    log_file_path TEXT,
+    listen_duration_ms INTEGER,
+    trigger_events INTEGER,
+    route_events INTEGER,
+    completed_sessions INTEGER
+);
+CREATE TABLE IF NOT EXISTS sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id INTEGER NOT NULL REFERENCES runs(id),
+    session_id INTEGER NOT NULL,
+    router_name TEXT,
+    started_at TEXT,
+    started_at_ms INTEGER,
+    trigger_source TEXT,
+    trigger_event_type TEXT,
+    trigger_info TEXT,
+    interface TEXT,
+    link TEXT,
+    tags TEXT,
+    completed_at TEXT,
+    convergence_ms INTEGER,
+    route_events INTEGER,
+    duration_ms INTEGER,
+    timed_out INTEGER,
+    UNIQUE (run_id, session_id)
+);
+CREATE TABLE IF NOT EXISTS events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id INTEGER NOT NULL REFERENCES runs(id),
+    session_id INTEGER,
+    event_type TEXT NOT NULL,
+    timestamp TEXT,
+    timestamp_ms INTEGER,
+    offset_ms INTEGER,
+    record TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_events_session ON events (run_id, session_id);
+)SQL";
+
+void bind_text(sqlite3_stmt* stmt, int index, const std::string& value) {
+    if (value.empty() || value == "N/A") {
+        sqlite3_bind_null(stmt, index);
+    } else {
+        sqlite3_bind_text(stmt, index, value.c_str(), static_cast<int>(value.size()), SQLITE_TRANSIENT);
+    }
+}
+
+} // namespace
+
+bool SqliteStore::exec(const std::string& sql, std::string& error) {
+    char* message = nullptr;
+    if (sqlite3_exec(db_, sql.c_str(), nullptr, nullptr, &message) != SQLITE_OK) {
+        error = message ? message : sqlite3_errmsg(db_);
+        sqlite3_free(message);
+        return false;
+    }
+    return true;
+}
+
+bool SqliteStore::prepare(const char* sql, sqlite3_stmt** stmt, std::string& error) {
+    if (sqlite3_prepare_v2(db_, sql, -1, stmt, nullptr) != SQLITE_OK) {
+        error = sqlite3_errmsg(db_);
+        return false;
+    }
+    return true;
+}
+
+void SqliteStore::step(sqlite3_stmt* stmt) {
+    if (sqlite3_step(stmt) == SQLITE_DONE) {
+        written_count_++;
+    } else {
+        failed_count_++;
+    }
+    sqlite3_reset(stmt);
+    sqlite3_clear_bindings(stmt);
+}
+
+bool SqliteStore::start(std::string& error) {
+    if (db_) {
+        return true;
+    }
+
+    if (sqlite3_open(db_path_.c_str(), &db_) != SQLITE_OK) {
+        error = "无法打开数据库 " + db_path_ + ": " + (db_ ? sqlite3_errmsg(db_) : "out of memory");
+        close();
+        return false;
+    }
+    // 同一数据库可能被多次运行或其他读取者同时访问
+    sqlite3_busy_timeout(db_, 3000);
+
+    if (!exec("PRAGMA journal_mode=WAL; PRAGMA synchronous=NORMAL;", error) ||
+        !exec(SCHEMA_SQL, error) ||
+        !exec("INSERT INTO runs (monitor_id) VALUES ('" + monitor_id_ + "');", error)) {
+        close();
+        return false;
+    }
+    run_id_ = sqlite3_last_insert_rowid(db_);
+
+    if (!prepare("INSERT OR REPLACE INTO sessions (run_id, session_id, router_name, started_at, started_at_ms, "
+                 "trigger_source, trigger_event_type, trigger_info, interface, link, tags) "
+                 "VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+                 &insert_session_stmt_, error) ||
+        !prepare("UPDATE sessions SET completed_at = ?, convergence_ms = ?, route_events = ?, "
+                 "duration_ms = ?, timed_out = ? WHERE run_id = ? AND session_id = ?",
+                 &complete_session_stmt_, error) ||
+        !prepare("INSERT INTO events (run_id, session_id, event_type, timestamp, timestamp_ms, offset_ms, record) "
+                 "VALUES (?, ?, ?, ?, ?, ?, ?)",
+                 &insert_event_stmt_, error)) {
+        close();
+        return false;
+    }
+    return true;
+}
+
+void SqliteStore::close() {
+    sqlite3_finalize(insert_session_stmt_);
+    sqlite3_finalize(complete_session_stmt_);
+    sqlite3_finalize(insert_event_stmt_);
+    insert_session_stmt_ = nullptr;
+    complete_session_stmt_ = nullptr;
+    insert_event_stmt_ = nullptr;
+    if (db_) {
+        sqlite3_close(db_);
+        db_ = nullptr;
+    }
+}
+
+void SqliteStore::update_run(const JsonObject& record, const std::string& event_type) {
+    sqlite3_stmt* stmt = nullptr;
+    std::string error;
+    if (event_type == "monitoring_started") {
+        if (!prepare("UPDATE runs SET router_name = ?, user = ?, started_at = ?, convergence_threshold_ms = ?, "
+                     "log_file_path = ? WHERE id = ?",
+                     &stmt, error)) {
+            failed_count_++;
+            return;
+        }
+        bind_text(stmt, 1, LogReader::get_string(record, "router_name"));
+        bind_text(stmt, 2, LogReader::get_string(record, "user"));
+        bind_text(stmt, 3, LogReader::get_timestamp(record, "timestamp"));
+        sqlite3_bind_int64(stmt, 4, LogReader::get_int(record, "convergence_threshold_ms"));
+        bind_text(stmt, 5, LogReader::get_string(record, "log_file_path"));
+        sqlite3_bind_int64(stmt, 6, run_id_);
+    } else {
+        if (!prepare("UPDATE runs SET completed_at = ?, listen_duration_ms = ?, trigger_events = ?, "
+                     "route_events = ?, completed_sessions = ? WHERE id = ?",
+                     &stmt, error)) {
+            failed_count_++;
+            return;
+        }
+        bind_text(stmt, 1, LogReader::get_timestamp(record, "timestamp"));
+        sqlite3_bind_int64(stmt, 2, LogReader::get_int(record, "total_listen_duration_ms"));
+        sqlite3_bind_int64(stmt, 3, LogReader::get_int(record, "total_trigger_events"));
+        sqlite3_bind_int64(stmt, 4, LogReader::get_int(record, "total_route_events"));
+        sqlite3_bind_int64(stmt, 5, LogReader::get_int(record, "completed_sessions_count"));
+        sqlite3_bind_int64(stmt, 6, run_id_);
+    }
+    step(stmt);
+    sqlite3_finalize(stmt);
+}
+
+void SqliteStore::write_record(const JsonObject& record, const std::string& line) {
+    std::lock_guard<std::mutex> lock(record_mutex_);
+    if (!db_) {
+        return;
+    }
+
+    std::string event_type = LogReader::get_string(record, "event_type");
+    std::string timestamp = LogReader::get_timestamp(record, "timestamp");
+
+    if (event_type == "monitoring_started" || event_type == "monitoring_completed") {
+        update_run(record, event_type);
+    } else if (event_type == "session_started") {
+        std::string trigger_info = LogReader::get_string(record, "trigger_info");
+        auto info = LogReader::parse_string_map(trigger_info);
+        JsonObject tags;
+        for (const auto& key : tag_keys_) {
+            if (LogReader::has(record, key)) {
+                tags[key] = LogReader::get_string(record, key);
+            }
+        }
+
+        sqlite3_bind_int64(insert_session_stmt_, 1, run_id_);
+        sqlite3_bind_int64(insert_session_stmt_, 2, LogReader::get_int(record, "session_id"));
+        bind_text(insert_session_stmt_, 3, LogReader::get_string(record, "router_name"));
+        bind_text(insert_session_stmt_, 4, timestamp);
+        sqlite3_bind_int64(insert_session_stmt_, 5, LogReader::parse_timestamp_ms(timestamp));
+        bind_text(insert_session_stmt_, 6, LogReader::get_string(record, "trigger_source"));
+        bind_text(insert_session_stmt_, 7, LogReader::get_string(record, "trigger_event_type"));
+        bind_text(insert_session_stmt_, 8, trigger_info);
+        bind_text(insert_session_stmt_, 9, info.count("interface") ? info["interface"] : "");
+        bind_text(insert_session_stmt_, 10, LogReader::get_string(record, "link"));
+        bind_text(insert_session_stmt_, 11, tags.empty() ? "" : Logger::json_to_string(tags));
+        step(insert_session_stmt_);
+    } else if (event_type == "session_completed") {
+        bind_text(complete_session_stmt_, 1, timestamp);
+        if (LogReader::has(record, "convergence_time_ms")) {
+            sqlite3_bind_int64(complete_session_stmt_, 2, LogReader::get_int(record, "convergence_time_ms"));
+        }
+        sqlite3_bind_int64(complete_session_stmt_, 3, LogReader::get_int(record, "route_events_count"));
+        sqlite3_bind_int64(complete_session_stmt_, 4, LogReader::get_int(record, "session_duration_ms"));
+        sqlite3_bind_int(complete_session_stmt_, 5, LogReader::get_bool(record, "timed_out") ? 1 : 0);
+        sqlite3_bind_int64(complete_session_stmt_, 6, run_id_);
+        sqlite3_bind_int64(complete_session_stmt_, 7, LogReader::get_int(record, "session_id"));
+        step(complete_session_stmt_);
+    } else {
+        sqlite3_bind_int64(insert_event_stmt_, 1, run_id_);
+        if (LogReader::has(record, "session_id")) {
+            sqlite3_bind_int64(insert_event_stmt_, 2, LogReader::get_int(record, "session_id"));
+        }
+        bind_text(insert_event_stmt_, 3, event_type);
+        bind_text(insert_event_stmt_, 4, timestamp);
+        sqlite3_bind_int64(insert_event_stmt_, 5, LogReader::parse_timestamp_ms(timestamp));
+        if (LogReader::has(record, "offset_from_trigger_ms")) {
+            sqlite3_bind_int64(insert_event_stmt_, 6, LogReader::get_int(record, "offset_from_trigger_ms"));
+        }
+        bind_text(insert_event_stmt_, 7, line);
+        step(insert_event_stmt_);
+    }
+}
+
+#else
+
+// 编译时未找到sqlite3库
+bool SqliteStore::start(std::string& error) {
+    error = "编译时未启用SQLite支持(需要libsqlite3-dev)";
+    return false;
+}
+
+void SqliteStore::close() {}
+
+void SqliteStore::write_record(const JsonObject&, const std::string&) {}
+
+#endif
+
+SqliteStore::SqliteStore(const std::string& db_path, const std::string& monitor_id,
+                         const std::vector<std::string>& tag_keys)
+    : db_path_(db_path), monitor_id_(monitor_id), tag_keys_(tag_keys) {}
+
+SqliteStore::~SqliteStore() {
+    stop();
+}
+
+void SqliteStore::stop() {
+    std::lock_guard<std::mutex> lock(record_mutex_);
+    close();
+}
+
+std::string SqliteStore::summary() const {
+    std::string text = "SQLite: 已写入 " + std::to_string(written_count_.load()) + " 行";
+    if (failed_count_.load() > 0) {
+        text += "，失败 " + std::to_string(failed_count_.load()) + " 行";
+    }
+    return text;
+}
+
+bool SqliteStore::parse_spec(const std::string& spec, std::string& db_path) {
+    const std::string scheme = "sqlite:";
+    if (spec.compare(0, scheme.size(), scheme) != 0 || spec.size() == scheme.size()) {
+        return false;
+    }
+    db_path = spec.substr(scheme.size());
+    return true;
+}
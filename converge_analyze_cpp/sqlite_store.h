@@ -0,0 +1,58 @@
+#pragma once
+
+#include <atomic>
+#include <cstdint>
+#include <mutex>
+#include <string>
+#include <vector>
+#include "record_sink.h"
+
+struct sqlite3;
+struct sqlite3_stmt;
+
+// 将运行元数据、会话和事件写入SQLite数据库(--store sqlite:/path/db.sqlite)
+// 表结构:
+//   runs     每次监控运行一行，以monitor_id区分，多次运行追加到同一数据库
+//   sessions 每个会话一行，session_started时插入、session_completed时补全结果
+//   events   其余记录(路由事件、FRR/BGP/IGP事件等)，record列保存原始JSON行
+class SqliteStore : public RecordSink {
+private:
+    std::string db_path_;
+    std::string monitor_id_;
+    std::vector<std::string> tag_keys_;  // 作为会话标签保存的记录字段(如--tag的键)
+
+    sqlite3* db_ = nullptr;
+    sqlite3_stmt* insert_session_stmt_ = nullptr;
+    sqlite3_stmt* complete_session_stmt_ = nullptr;
+    sqlite3_stmt* insert_event_stmt_ = nullptr;
+    int64_t run_id_ = 0;
+
+    std::mutex record_mutex_;  // 日志线程与log_sync可能并发调用write_record
+
+    std::atomic<int64_t> written_count_{0};
+    std::atomic<int64_t> failed_count_{0};
+
+    bool exec(const std::string& sql, std::string& error);
+    bool prepare(const char* sql, sqlite3_stmt** stmt, std::string& error);
+    void step(sqlite3_stmt* stmt);
+    void update_run(const JsonObject& record, const std::string& event_type);
+    void close();
+
+public:
+    SqliteStore(const std::string& db_path, const std::string& monitor_id,
+                const std::vector<std::string>& tag_keys);
+    ~SqliteStore() override;
+
+    SqliteStore(const SqliteStore&) = delete;
+    SqliteStore& operator=(const SqliteStore&) = delete;
+
+    // 打开数据库、建表并登记本次运行
+    bool start(std::string& error) override;
+    void stop() override;
+
+    void write_record(const JsonObject& record, const std::string& line) override;
+    std::string summary() const override;
+
+    // 解析 "sqlite:PATH"，失败返回false
+    static bool parse_spec(const std::string& spec, std::string& db_path);
+};
@@ -0,0 +1,308 @@
+#include "subprocess.h"
+#include <algorithm>
+#include <cerrno>
+#include <chrono>
+#include <csignal>
+#include <cstring>
+#include <fcntl.h>
+#include <poll.h>
+#include <pthread.h>
+#include <thread>
+#include <sys/wait.h>
+#include <unistd.h>
+
+extern char** environ;
+
+int run_command(const std::vector<std::string>& args, std::string& output, std::string& error) {
+    output.clear();
+    if (args.empty()) {
+        error = "empty command";
+        return -1;
+    }
+
+    int pipe_fds[2];
+    if (pipe(pipe_fds) < 0) {
+        error = "pipe: " + std::string(strerror(errno));
+        return -1;
+    }
+
+    std::vector<char*> argv;
+    argv.reserve(args.size() + 1);
+    for (const auto& arg : args) {
+        argv.push_back(const_cast<char*>(arg.c_str()));
+    }
+    argv.push_back(nullptr);
+
+    pid_t pid = fork();
+    if (pid < 0) {
+        error = "fork: " + std::string(strerror(errno));
+        close(pipe_fds[0]);
+        close(pipe_fds[1]);
+        return -1;
+    }
+
+    if (pid == 0) {
+        dup2(pipe_fds[1], STDOUT_FILENO);
+        dup2(pipe_fds[1], STDERR_FILENO);
+        close(pipe_fds[0]);
+        close(pipe_fds[1]);
+        execvp(argv[0], argv.data());
+        // exec失败，按shell约定返回127
+        _exit(127);
+    }
+
+    close(pipe_fds[1]);
+    char buffer[4096];
+    ssize_t len;
+    while ((len = read(pipe_fds[0], buffer, sizeof(buffer))) != 0) {
+        if (len < 0) {
+            if (errno == EINTR) continue;
+            break;
+        }
+        output.append(buffer, static_cast<size_t>(len));
+    }
+    close(pipe_fds[0]);
+
+    int status = 0;
+    while (waitpid(pid, &status, 0) < 0) {
+        if (errno != EINTR) {
+            error = "waitpid: " + std::string(strerror(errno));
+            return -1;
+        }
+    }
+
+    if (WIFEXITED(status)) {
+        int code = WEXITSTATUS(status);
+        if (code == 127) {
+            error = args[0] + ": command not found";
+        }
+        return code;
+    }
+    error = args[0] + " terminated by signal";
+    return -1;
+}
+
+pid_t spawn_command(const std::vector<std::string>& args, int& output_fd, std::string& error) {
+    if (args.empty()) {
+        error = "empty command";
+        return -1;
+    }
+
+    int pipe_fds[2];
+    if (pipe(pipe_fds) < 0) {
+        error = "pipe: " + std::string(strerror(errno));
+        return -1;
+    }
+
+    std::vector<char*> argv;
+    argv.reserve(args.size() + 1);
+    for (const auto& arg : args) {
+        argv.push_back(const_cast<char*>(arg.c_str()));
+    }
+    argv.push_back(nullptr);
+
+    pid_t pid = fork();
+    if (pid < 0) {
+        error = "fork: " + std::string(strerror(errno));
+        close(pipe_fds[0]);
+        close(pipe_fds[1]);
+        return -1;
+    }
+
+    if (pid == 0) {
+        dup2(pipe_fds[1], STDOUT_FILENO);
+        dup2(pipe_fds[1], STDERR_FILENO);
+        close(pipe_fds[0]);
+        close(pipe_fds[1]);
+        execvp(argv[0], argv.data());
+        _exit(127);
+    }
+
+    close(pipe_fds[1]);
+    fcntl(pipe_fds[0], F_SETFL, fcntl(pipe_fds[0], F_GETFL) | O_NONBLOCK);
+    output_fd = pipe_fds[0];
+    return pid;
+}
+
+int run_command_input(const std::vector<std::string>& args, const std::string& input,
+                      const std::vector<std::string>& env, int64_t timeout_ms,
+                      std::string& output, bool& timed_out, std::string& error) {
+    output.clear();
+    timed_out = false;
+    if (args.empty()) {
+        error = "empty command";
+        return -1;
+    }
+
+    int in_fds[2];
+    int out_fds[2];
+    if (pipe(in_fds) < 0) {
+        error = "pipe: " + std::string(strerror(errno));
+        return -1;
+    }
+    if (pipe(out_fds) < 0) {
+        error = "pipe: " + std::string(strerror(errno));
+        close(in_fds[0]);
+        close(in_fds[1]);
+        return -1;
+    }
+
+    std::vector<char*> argv;
+    argv.reserve(args.size() + 1);
+    for (const auto& arg : args) {
+        argv.push_back(const_cast<char*>(arg.c_str()));
+    }
+    argv.push_back(nullptr);
+
+    // fork之后子进程只能调用异步信号安全的函数，环境变量在父进程中准备好：
+    // 继承当前环境，env中同名的变量覆盖原值
+    std::vector<char*> envp;
+    for (char** entry = environ; entry && *entry; ++entry) {
+        const char* eq = strchr(*entry, '=');
+        size_t name_len = eq ? static_cast<size_t>(eq - *entry) + 1 : strlen(*entry);
+        bool overridden = std::any_of(env.begin(), env.end(), [&](const std::string& var) {
+            return var.compare(0, name_len, *entry, name_len) == 0;
+        });
+        if (!overridden) {
+            envp.push_back(*entry);
+        }
+    }
+    for (const auto& entry : env) {
+        envp.push_back(const_cast<char*>(entry.c_str()));
+    }
+    envp.push_back(nullptr);
+
+    pid_t pid = fork();
+    if (pid < 0) {
+        error = "fork: " + std::string(strerror(errno));
+        close(in_fds[0]);
+        close(in_fds[1]);
+        close(out_fds[0]);
+        close(out_fds[1]);
+        return -1;
+    }
+
+    if (pid == 0) {
+        // 独立进程组，超时时连同其派生的子进程一起结束
+        setpgid(0, 0);
+        dup2(in_fds[0], STDIN_FILENO);
+        dup2(out_fds[1], STDOUT_FILENO);
+        dup2(out_fds[1], STDERR_FILENO);
+        close(in_fds[0]);
+        close(in_fds[1]);
+        close(out_fds[0]);
+        close(out_fds[1]);
+        execvpe(argv[0], argv.data(), envp.data());
+        _exit(127);
+    }
+
+    close(in_fds[0]);
+    close(out_fds[1]);
+    fcntl(in_fds[1], F_SETFL, fcntl(in_fds[1], F_GETFL) | O_NONBLOCK);
+
+    // 命令不读stdin就退出时写入会产生SIGPIPE，只在本线程屏蔽，结束前丢弃挂起的信号
+    sigset_t pipe_set, old_set;
+    sigemptyset(&pipe_set);
+    sigaddset(&pipe_set, SIGPIPE);
+    pthread_sigmask(SIG_BLOCK, &pipe_set, &old_set);
+
+    auto deadline = std::chrono::steady_clock::now() + std::chrono::milliseconds(timeout_ms);
+    int write_fd = in_fds[1];
+    size_t written = 0;
+    if (input.empty()) {
+        close(write_fd);
+        write_fd = -1;
+    }
+    bool eof = false;
+    while (!eof) {
+        int wait_ms = 100;
+        if (timeout_ms > 0) {
+            auto remaining = std::chrono::duration_cast<std::chrono::milliseconds>(
+                deadline - std::chrono::steady_clock::now()).count();
+            if (remaining <= 0) {
+                timed_out = true;
+                break;
+            }
+            wait_ms = static_cast<int>(std::min<int64_t>(remaining, 100));
+        }
+        struct pollfd fds[2] = {{out_fds[0], POLLIN, 0}, {write_fd, POLLOUT, 0}};
+        if (poll(fds, write_fd >= 0 ? 2 : 1, wait_ms) <= 0) {
+            continue;
+        }
+        if (write_fd >= 0 && (fds[1].revents & (POLLOUT | POLLERR | POLLHUP))) {
+            ssize_t len = write(write_fd, input.data() + written, input.size() - written);
+            if (len > 0) {
+                written += static_cast<size_t>(len);
+            }
+            if (written >= input.size() || (len < 0 && errno != EAGAIN && errno != EINTR)) {
+                close(write_fd);
+                write_fd = -1;
+            }
+        }
+        if (fds[0].revents & (POLLIN | POLLHUP | POLLERR)) {
+            char buffer[4096];
+            ssize_t len = read(out_fds[0], buffer, sizeof(buffer));
+            if (len > 0) {
+                output.append(buffer, static_cast<size_t>(len));
+            } else if (len == 0 || (errno != EAGAIN && errno != EINTR)) {
+                eof = true;
+            }
+        }
+    }
+    if (write_fd >= 0) {
+        close(write_fd);
+    }
+    close(out_fds[0]);
+
+    // 输出已关闭但进程可能仍在运行，同样受超时限制
+    int status = 0;
+    while (!timed_out) {
+        pid_t result = waitpid(pid, &status, WNOHANG);
+        if (result == pid || (result < 0 && errno != EINTR)) {
+            break;
+        }
+        if (timeout_ms > 0 && std::chrono::steady_clock::now() >= deadline) {
+            timed_out = true;
+            break;
+        }
+        std::this_thread::sleep_for(std::chrono::milliseconds(10));
+    }
+    if (timed_out) {
+        kill(-pid, SIGKILL);
+        while (waitpid(pid, &status, 0) < 0 && errno == EINTR) {
+        }
+    }
+    struct timespec no_wait = {0, 0};
+    while (sigtimedwait(&pipe_set, nullptr, &no_wait) > 0) {
+    }
+    pthread_sigmask(SIG_SETMASK, &old_set, nullptr);
+
+    if (timed_out) {
+        error = args[0] + " timed out";
+        return -1;
+    }
+    if (WIFEXITED(status)) {
+        int code = WEXITSTATUS(status);
+        if (code == 127) {
+            error = args[0] + ": command not found";
+        }
+        return code;
+    }
+    error = args[0] + " terminated by signal";
+    return -1;
+}
+
+std::string format_command(const std::vector<std::string>& args) {
+    std::string line;
+    for (const auto& arg : args) {
+        if (!line.empty()) {
+            line += ' ';
+        }
+        if (arg.find_first_of(" \t'\"$") != std::string::npos) {
+            line += "'" + arg + "'";
+        } else {
+            line += arg;
+        }
+    }
+    return line;
+}
@@ -0,0 +1,23 @@
+#pragma once
+
+#include <cstdint>
+#include <string>
+#include <sys/types.h>
+#include <vector>
+
+// 执行外部命令（不经过shell），捕获stdout与stderr
+// 返回进程退出码；无法启动时返回-1并设置error
+int run_command(const std::vector<std::string>& args, std::string& output, std::string& error);
+
+// 启动外部命令并返回其pid，stdout与stderr合并写入output_fd(非阻塞读端)
+// 调用方负责读取、关闭output_fd并waitpid；失败返回-1并设置error
+pid_t spawn_command(const std::vector<std::string>& args, int& output_fd, std::string& error);
+
+// 执行外部命令，input写入其stdin，env中的"KEY=VALUE"追加到其环境变量，捕获合并的stdout与stderr。
+// timeout_ms>0时超时杀掉整个进程组并返回-1，timed_out为true；其余同run_command
+int run_command_input(const std::vector<std::string>& args, const std::string& input,
+                      const std::vector<std::string>& env, int64_t timeout_ms,
+                      std::string& output, bool& timed_out, std::string& error);
+
+// 将参数拼接为便于展示/复制的命令行
+std::string format_command(const std::vector<std::string>& args);
@@ -0,0 +1,324 @@
+#include "syslog_sink.h"
+#include "log_reader.h"
+#include <cerrno>
+#include <cstring>
+#include <iostream>
+#include <map>
+#include <netdb.h>
+#include <sys/socket.h>
+#include <sys/un.h>
+#include <unistd.h>
+
+namespace {
+
+const std::string APP_NAME = "convergence-analyzer";
+// RFC 5612中保留给文档示例的企业号
+const std::string SD_ID = "convergence@32473";
+
+constexpr int SEVERITY_WARNING = 4;
+constexpr int SEVERITY_NOTICE = 5;
+constexpr int SEVERITY_INFO = 6;
+
+const std::map<std::string, int> FACILITIES = {
+    {"user", 1}, {"daemon", 3},
+    {"local0", 16}, {"local1", 17}, {"local2", 18}, {"local3", 19},
+    {"local4", 20}, {"local5", 21}, {"local6", 22}, {"local7", 23},
+};
+
+// HOSTNAME/APP-NAME/MSGID/PARAM-NAME只允许可打印ASCII
+std::string printable(const std::string& text, size_t max_length, bool param_name) {
+    std::string result;
+    for (char c : text) {
+        if (result.size() >= max_length) {
+            break;
+        }
+        bool ok = c > 32 && c < 127 && !(param_name && (c == '=' || c == ']' || c == '"'));
+        result += ok ? c : '_';
+    }
+    return result.empty() ? "-" : result;
+}
+
+std::string escape_param_value(const std::string& value) {
+    std::string escaped;
+    for (char c : value) {
+        if (c == '"' || c == '\\' || c == ']') {
+            escaped += '\\';
+        }
+        escaped += c;
+    }
+    return escaped;
+}
+
+} // namespace
+
+bool SyslogSink::parse_url(const std::string& url, Target& target) {
+    std::string rest;
+    if (url == "syslog") {
+        target.transport = Transport::UNIX;
+        target.path = "/dev/log";
+        return true;
+    } else if (url.compare(0, 13, "syslog+tcp://") == 0) {
+        target.transport = Transport::TCP;
+        rest = url.substr(13);
+    } else if (url.compare(0, 9, "syslog://") == 0) {
+        target.transport = Transport::UDP;
+        rest = url.substr(9);
+    } else {
+        return false;
+    }
+
+    size_t query = rest.find('?');
+    if (query != std::string::npos) {
+        std::string param = rest.substr(query + 1);
+        rest = rest.substr(0, query);
+        if (param.compare(0, 9, "facility=") != 0) {
+            return false;
+        }
+        auto it = FACILITIES.find(param.substr(9));
+        if (it == FACILITIES.end()) {
+            return false;
+        }
+        target.facility = it->second;
+    }
+
+    if (!rest.empty() && rest.front() == '/') {
+        if (target.transport == Transport::TCP) {
+            return false;
+        }
+        target.transport = Transport::UNIX;
+        target.path = rest;
+        return true;
+    }
+
+    // HOST、HOST:PORT、[V6]:PORT
+    if (!rest.empty() && rest.front() == '[') {
+        size_t close = rest.find(']');
+        if (close == std::string::npos) {
+            return false;
+        }
+        target.host = rest.substr(1, close - 1);
+        if (close + 1 < rest.size()) {
+            if (rest[close + 1] != ':') {
+                return false;
+            }
+            target.port = rest.substr(close + 2);
+        }
+    } else {
+        size_t colon = rest.find(':');
+        target.host = rest.substr(0, colon);
+        if (colon != std::string::npos) {
+            target.port = rest.substr(colon + 1);
+        }
+    }
+    return !target.host.empty() && !target.port.empty() &&
+           target.port.find_first_not_of("0123456789") == std::string::npos;
+}
+
+SyslogSink::SyslogSink(const Target& target)
+    : target_(target), procid_(std::to_string(getpid())) {
+    char hostname[256] = {0};
+    if (gethostname(hostname, sizeof(hostname) - 1) == 0) {
+        hostname_ = printable(hostname, 255, false);
+    } else {
+        hostname_ = "-";
+    }
+}
+
+SyslogSink::~SyslogSink() {
+    stop();
+}
+
+bool SyslogSink::start(std::string&) {
+    if (running_.load()) {
+        return true;
+    }
+
+    running_.store(true);
+    worker_thread_ = std::thread(&SyslogSink::worker_loop, this);
+    return true;
+}
+
+void SyslogSink::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    queue_cv_.notify_all();
+
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+    if (fd_ >= 0) {
+        close(fd_);
+        fd_ = -1;
+    }
+}
+
+std::string SyslogSink::format(const JsonObject& record, const std::string& line) const {
+    std::string event_type = LogReader::get_string(record, "event_type");
+    int severity = SEVERITY_INFO;
+    if (event_type == "session_completed" && LogReader::get_bool(record, "timed_out")) {
+        severity = SEVERITY_WARNING;
+    } else if (event_type == "monitoring_started" || event_type == "monitoring_completed") {
+        severity = SEVERITY_NOTICE;
+    }
+
+    std::string message = "<" + std::to_string(target_.facility * 8 + severity) + ">1 ";
+    message += printable(LogReader::get_string(record, "timestamp"), 64, false) + " ";
+    message += hostname_ + " " + APP_NAME + " " + procid_ + " ";
+    message += printable(event_type, 32, false) + " ";
+
+    // 结构化数据只包含标量字段，嵌套的JSON(如route_info)保留在MSG中
+    std::map<std::string, std::string> params;
+    for (const auto& field : record) {
+        if (field.first == "event_type" || field.first == "timestamp") {
+            continue;
+        }
+        std::string value = LogReader::get_string(record, field.first);
+        if (!value.empty() && (value.front() == '{' || value.front() == '[')) {
+            continue;
+        }
+        params[printable(field.first, 32, true)] = value;
+    }
+    message += "[" + SD_ID;
+    for (const auto& param : params) {
+        message += " " + param.first + "=\"" + escape_param_value(param.second) + "\"";
+    }
+    message += "] ";
+
+    // MSG为带BOM的UTF-8，即完整的JSON行
+    message += "\xEF\xBB\xBF" + line;
+    return message;
+}
+
+void SyslogSink::write_record(const JsonObject& record, const std::string& line) {
+    std::string message = format(record, line);
+
+    std::unique_lock<std::mutex> lock(queue_mutex_);
+    if (pending_.size() >= MAX_PENDING_MESSAGES) {
+        pending_.pop();
+        failed_count_.fetch_add(1);
+    }
+    pending_.push(std::move(message));
+    lock.unlock();
+
+    queue_cv_.notify_one();
+}
+
+bool SyslogSink::connect_target(std::string& error) {
+    if (target_.transport == Transport::UNIX) {
+        struct sockaddr_un addr;
+        memset(&addr, 0, sizeof(addr));
+        addr.sun_family = AF_UNIX;
+        strncpy(addr.sun_path, target_.path.c_str(), sizeof(addr.sun_path) - 1);
+        fd_ = socket(AF_UNIX, SOCK_DGRAM | SOCK_CLOEXEC, 0);
+        if (fd_ >= 0 && connect(fd_, reinterpret_cast<struct sockaddr*>(&addr), sizeof(addr)) == 0) {
+            return true;
+        }
+        error = "connect " + target_.path + ": " + strerror(errno);
+    } else {
+        struct addrinfo hints;
+        memset(&hints, 0, sizeof(hints));
+        hints.ai_family = AF_UNSPEC;
+        hints.ai_socktype = target_.transport == Transport::TCP ? SOCK_STREAM : SOCK_DGRAM;
+
+        struct addrinfo* result = nullptr;
+        int rc = getaddrinfo(target_.host.c_str(), target_.port.c_str(), &hints, &result);
+        if (rc != 0) {
+            error = "resolve " + target_.host + ": " + gai_strerror(rc);
+            return false;
+        }
+
+        struct timeval tv = {3, 0};
+        for (struct addrinfo* ai = result; ai != nullptr; ai = ai->ai_next) {
+            fd_ = socket(ai->ai_family, ai->ai_socktype | SOCK_CLOEXEC, ai->ai_protocol);
+            if (fd_ < 0) {
+                continue;
+            }
+            setsockopt(fd_, SOL_SOCKET, SO_SNDTIMEO, &tv, sizeof(tv));
+            if (connect(fd_, ai->ai_addr, ai->ai_addrlen) == 0) {
+                freeaddrinfo(result);
+                return true;
+            }
+            close(fd_);
+            fd_ = -1;
+        }
+        freeaddrinfo(result);
+        error = "connect " + target_.host + ":" + target_.port + ": " + strerror(errno);
+    }
+
+    if (fd_ >= 0) {
+        close(fd_);
+        fd_ = -1;
+    }
+    return false;
+}
+
+bool SyslogSink::deliver(const std::string& message, std::string& error) {
+    if (fd_ < 0 && !connect_target(error)) {
+        return false;
+    }
+
+    std::string data = message;
+    if (target_.transport == Transport::TCP) {
+        data = std::to_string(message.size()) + " " + message;
+    }
+
+    size_t sent = 0;
+    while (sent < data.size()) {
+        ssize_t n = send(fd_, data.data() + sent, data.size() - sent, MSG_NOSIGNAL);
+        if (n <= 0) {
+            error = "send: " + std::string(strerror(errno));
+            close(fd_);
+            fd_ = -1;
+            return false;
+        }
+        sent += static_cast<size_t>(n);
+    }
+    return true;
+}
+
+void SyslogSink::worker_loop() {
+    std::unique_lock<std::mutex> lock(queue_mutex_);
+    std::string last_error;
+
+    while (running_.load() || !pending_.empty()) {
+        queue_cv_.wait(lock, [this] {
+            return !pending_.empty() || !running_.load();
+        });
+
+        while (!pending_.empty()) {
+            std::string message = std::move(pending_.front());
+            pending_.pop();
+            lock.unlock();
+
+            // 连接断开(如接收端重启)时重连一次
+            std::string error;
+            bool ok = deliver(message, error) || (target_.transport != Transport::UDP && deliver(message, error));
+            if (ok) {
+                sent_count_.fetch_add(1);
+                last_error.clear();
+            } else {
+                failed_count_.fetch_add(1);
+                // 相同错误只提示一次
+                if (error != last_error) {
+                    std::cerr << "⚠️  syslog发送失败: " << error << "\n";
+                    last_error = error;
+                }
+            }
+
+            lock.lock();
+        }
+    }
+}
+
+std::string SyslogSink::summary() const {
+    std::string destination = target_.transport == Transport::UNIX ? target_.path
+                                                                     : target_.host + ":" + target_.port;
+    std::string text = "syslog(" + destination + "): 已发送 " + std::to_string(sent_count_.load()) + " 条记录";
+    if (failed_count_.load() > 0) {
+        text += "，失败 " + std::to_string(failed_count_.load()) + " 条";
+    }
+    return text;
+}
@@ -0,0 +1,65 @@
+#pragma once
+
+#include <atomic>
+#include <condition_variable>
+#include <mutex>
+#include <queue>
+#include <string>
+#include <thread>
+#include "record_sink.h"
+
+// 以RFC 5424格式(带结构化数据)发送记录到本地或远程syslog
+//   syslog://HOST[:514]            UDP (RFC 5426)
+//   syslog+tcp://HOST[:514]        TCP，octet-counting分帧 (RFC 6587)
+//   syslog:///dev/log 或 syslog   本地Unix域套接字
+// 可附加 ?facility=local0..local7|user|daemon，默认local0
+class SyslogSink : public RecordSink {
+public:
+    enum class Transport { UDP, TCP, UNIX };
+
+    struct Target {
+        Transport transport = Transport::UDP;
+        std::string host;
+        std::string port = "514";
+        std::string path;  // Unix域套接字路径
+        int facility = 16;
+    };
+
+private:
+    Target target_;
+    std::string hostname_;
+    std::string procid_;
+    int fd_ = -1;  // 仅由工作线程访问
+
+    std::queue<std::string> pending_;
+    std::mutex queue_mutex_;
+    std::condition_variable queue_cv_;
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+
+    std::atomic<int64_t> sent_count_{0};
+    std::atomic<int64_t> failed_count_{0};
+
+    static constexpr size_t MAX_PENDING_MESSAGES = 10000;
+
+    void worker_loop();
+    bool connect_target(std::string& error);
+    bool deliver(const std::string& message, std::string& error);
+
+public:
+    explicit SyslogSink(const Target& target);
+    ~SyslogSink() override;
+
+    SyslogSink(const SyslogSink&) = delete;
+    SyslogSink& operator=(const SyslogSink&) = delete;
+
+    bool start(std::string& error) override;
+    void write_record(const JsonObject& record, const std::string& line) override;
+    void stop() override;
+    std::string summary() const override;
+
+    // 生成一条RFC 5424消息(不含传输层分帧)
+    std::string format(const JsonObject& record, const std::string& line) const;
+
+    static bool parse_url(const std::string& url, Target& target);
+};
@@ -0,0 +1,188 @@
+#include "analyze.h"
+#include "test_util.h"
+
+namespace {
+
+std::string record(const std::string& event_type, int64_t time_ms, const std::string& fields) {
+    return "{\"event_type\":\"" + event_type + "\",\"router_name\":\"r1\",\"timestamp\":" +
+           std::to_string(time_ms) + (fields.empty() ? "" : "," + fields) + "}";
+}
+
+std::string trigger(int session_id, int64_t time_ms, const std::string& source = "netem") {
+    return record("session_started", time_ms,
+                  "\"session_id\":" + std::to_string(session_id) + ",\"trigger_source\":\"" + source +
+                  "\",\"trigger_event_type\":\"qdisc_add\",\"trigger_info\":\"interface=eth0\"");
+}
+
+std::string route(int session_id, int64_t time_ms) {
+    return record("route_event", time_ms,
+                  "\"session_id\":" + std::to_string(session_id) +
+                  ",\"route_event_type\":\"route_replace\",\"route_info\":\"dst=10.0.0.0/24,interface=eth1\"");
+}
+
+std::string completed(int session_id, int64_t time_ms, int64_t convergence_ms, int route_events) {
+    return record("session_completed", time_ms,
+                  "\"session_id\":" + std::to_string(session_id) + ",\"convergence_time_ms\":" +
+                  std::to_string(convergence_ms) + ",\"route_events_count\":" + std::to_string(route_events));
+}
+
+// 阈值1000ms下的三个会话：#1 两条路由事件，#2 一条，#3 只有触发(SNMP Trap)，相邻会话间隔1.2~1.3秒
+std::vector<std::string> three_sessions() {
+    return {
+        record("monitoring_started", 0, "\"convergence_threshold_ms\":1000"),
+        trigger(1, 10000), route(1, 10100), route(1, 10200), completed(1, 11200, 200, 2),
+        trigger(2, 11500), route(2, 11600), completed(2, 12600, 100, 1),
+        trigger(3, 12800, "snmp"), completed(3, 13800, 0, 0),
+        record("monitoring_completed", 30000, ""),
+    };
+}
+
+ReplayResult replay_at(const std::vector<std::string>& lines, int64_t threshold_ms) {
+    SessionReplay replay;
+    std::string error;
+    CHECK(replay.load(write_temp_file(lines), error));
+    ReplayOptions options;
+    options.threshold_ms = threshold_ms;
+    return replay.replay(options);
+}
+
+} // namespace
+
+TEST_CASE(replay_at_recorded_threshold_reproduces_sessions) {
+    ReplayResult result = replay_at(three_sessions(), 1000);
+    CHECK_EQ(result.data.sessions.size(), 3u);
+    CHECK_EQ(result.merged_sessions, 0);
+    CHECK_EQ(result.split_sessions, 0);
+    if (result.data.sessions.size() == 3) {
+        CHECK_EQ(result.data.sessions[0].convergence_time_ms.value_or(-1), 200);
+        CHECK_EQ(result.data.sessions[1].convergence_time_ms.value_or(-1), 100);
+        CHECK_EQ(result.data.sessions[2].convergence_time_ms.value_or(-1), 0);
+        CHECK(!result.data.sessions[2].timed_out);
+    }
+}
+
+TEST_CASE(replay_smaller_threshold_splits_sessions) {
+    // 50ms时事件间隔100ms即收敛，#1拆成3个会话、#2拆成2个，#3只有触发
+    ReplayResult result = replay_at(three_sessions(), 50);
+    CHECK_EQ(result.data.sessions.size(), 6u);
+    CHECK_EQ(result.split_sessions, 2);
+    CHECK_EQ(result.merged_sessions, 0);
+}
+
+TEST_CASE(replay_larger_threshold_ignores_triggers_inside_session) {
+    // 2000ms时三个会话合并为一个：#2与#3的触发被忽略，不算路由事件，也不延长静默期
+    ReplayResult result = replay_at(three_sessions(), 2000);
+    CHECK_EQ(result.data.sessions.size(), 1u);
+    CHECK_EQ(result.merged_sessions, 1);
+    CHECK_EQ(result.split_sessions, 0);
+    if (result.data.sessions.size() == 1) {
+        const ReportSession& session = result.data.sessions[0];
+        CHECK_EQ(session.route_events, 3);
+        CHECK_EQ(session.events.size(), 3u);
+        CHECK_EQ(session.convergence_time_ms.value_or(-1), 1600);
+        CHECK_EQ(session.duration_ms, 3600);
+        CHECK(!session.timed_out);
+        CHECK_EQ(session.churn_per_second.size(), 2u);
+        CHECK_EQ(result.origins[0], std::string("1,2,3"));
+    }
+}
+
+TEST_CASE(replay_marks_session_open_at_run_end_as_forced) {
+    std::vector<std::string> lines = {
+        record("monitoring_started", 0, "\"convergence_threshold_ms\":1000"),
+        trigger(1, 10000), route(1, 10100), route(1, 10900),
+        record("monitoring_completed", 11500, ""),
+    };
+    ReplayResult result = replay_at(lines, 1000);
+    CHECK_EQ(result.data.sessions.size(), 1u);
+    if (!result.data.sessions.empty()) {
+        CHECK(result.data.sessions[0].forced);
+        CHECK(!result.data.sessions[0].timed_out);
+        CHECK(!result.data.sessions[0].convergence_time_ms.has_value());
+        CHECK_EQ(result.data.sessions[0].duration_ms, 1500);
+    }
+}
+
+TEST_CASE(parse_sweep_range_and_list) {
+    std::vector<int64_t> thresholds;
+    std::string error;
+    CHECK(parse_sweep("500:2000:500", thresholds, error));
+    CHECK(thresholds == (std::vector<int64_t>{500, 1000, 1500, 2000}));
+
+    // 列表升序去重
+    CHECK(parse_sweep("3000,1000,2000,1000", thresholds, error));
+    CHECK(thresholds == (std::vector<int64_t>{1000, 2000, 3000}));
+
+    // 步长超过区间时只有一个阈值点，不足以比较
+    CHECK(!parse_sweep("500:900:1000", thresholds, error));
+}
+
+TEST_CASE(parse_sweep_rejects_invalid_specs) {
+    std::vector<int64_t> thresholds;
+    std::string error;
+    for (const char* spec : {"abc", "500:2000", "2000:500:100", "500:2000:0", "0:1000:100", "1000", "1000,-5",
+                             "1:100000:1", ""}) {
+        error.clear();
+        CHECK(!parse_sweep(spec, thresholds, error));
+        CHECK(!error.empty());
+    }
+}
+
+namespace {
+
+SweepPoint point(int64_t threshold_ms, int64_t sessions, double median_ms = 0.0) {
+    SweepPoint result;
+    result.threshold_ms = threshold_ms;
+    result.sessions = sessions;
+    if (median_ms > 0) {
+        result.stats.count = static_cast<size_t>(sessions);
+        result.stats.median = median_ms;
+    }
+    return result;
+}
+
+} // namespace
+
+TEST_CASE(sweep_plateau_picks_longest_stable_run) {
+    std::vector<SweepPoint> points = {
+        point(500, 9, 100), point(1000, 6, 200), point(1500, 4, 300), point(2000, 4, 320),
+        point(2500, 4, 310), point(3000, 2, 900),
+    };
+    SweepPlateau plateau = find_sweep_plateau(points, 1000);
+    CHECK(plateau.found);
+    CHECK_EQ(plateau.begin, 2u);
+    CHECK_EQ(plateau.end, 4u);
+    CHECK(plateau.has_stats);
+    CHECK_EQ(plateau.median_min_ms, 300.0);
+    CHECK_EQ(plateau.median_max_ms, 320.0);
+    CHECK(!plateau.contains_original);
+
+    CHECK(find_sweep_plateau(points, 2000).contains_original);
+    // 没有记录原阈值时不判定落在区间内
+    CHECK(!find_sweep_plateau(points, 0).contains_original);
+}
+
+TEST_CASE(sweep_plateau_prefers_run_with_original_threshold_on_tie) {
+    std::vector<SweepPoint> points = {
+        point(500, 5), point(1000, 5), point(1500, 3), point(2000, 3), point(2500, 1),
+    };
+    SweepPlateau plateau = find_sweep_plateau(points, 2000);
+    CHECK(plateau.found);
+    CHECK_EQ(plateau.begin, 2u);
+    CHECK_EQ(plateau.end, 3u);
+    CHECK(plateau.contains_original);
+    CHECK(!plateau.has_stats);
+
+    // 原阈值不在任何区间内时取第一个
+    plateau = find_sweep_plateau(points, 3000);
+    CHECK_EQ(plateau.begin, 0u);
+    CHECK_EQ(plateau.end, 1u);
+}
+
+TEST_CASE(sweep_plateau_absent_when_session_count_always_changes) {
+    std::vector<SweepPoint> points = {point(500, 6), point(1000, 3), point(1500, 1)};
+    SweepPlateau plateau = find_sweep_plateau(points, 1000);
+    CHECK(!plateau.found);
+    CHECK(!plateau.contains_original);
+    CHECK(!find_sweep_plateau({}, 1000).found);
+}
@@ -0,0 +1,76 @@
+#include "cli_utils.h"
+#include "test_util.h"
+#include <cstdlib>
+
+TEST_CASE(parse_int64_rejects_partial_and_overflowing_input) {
+    int64_t value = 7;
+    CHECK(parse_int64("1500", value));
+    CHECK_EQ(value, 1500);
+    CHECK(parse_int64("-5", value));
+    CHECK_EQ(value, -5);
+
+    value = 7;
+    CHECK(!parse_int64("abc", value));
+    CHECK(!parse_int64("12x", value));
+    CHECK(!parse_int64("", value));
+    CHECK(!parse_int64(" 12", value));
+    CHECK(!parse_int64("1e3", value));
+    CHECK(!parse_int64("99999999999999999999", value));
+    CHECK_EQ(value, 7);
+}
+
+TEST_CASE(parse_double_rejects_partial_and_non_finite_input) {
+    double value = 1.0;
+    CHECK(parse_double("0.05", value));
+    CHECK_EQ(value, 0.05);
+    CHECK(parse_double("3", value));
+    CHECK_EQ(value, 3.0);
+
+    value = 1.0;
+    CHECK(!parse_double("x", value));
+    CHECK(!parse_double("0.05%", value));
+    CHECK(!parse_double("inf", value));
+    CHECK(!parse_double("nan", value));
+    CHECK_EQ(value, 1.0);
+}
+
+TEST_CASE(parse_duration_accepts_units) {
+    CHECK_EQ(parse_duration_ms("250ms"), 250);
+    CHECK_EQ(parse_duration_ms("90"), 90000);
+    CHECK_EQ(parse_duration_ms("10m"), 600000);
+    CHECK_EQ(parse_duration_ms("1h"), 3600000);
+    CHECK_EQ(parse_duration_ms("5d"), -1);
+    CHECK_EQ(parse_duration_ms("abc"), -1);
+}
+
+TEST_CASE(options_from_env_maps_variables_to_long_options) {
+    CHECK_EQ(env_variable_name("sla-ms"), std::string("CONVERGE_SLA_MS"));
+
+    const struct option long_options[] = {
+        {"sla-ms", required_argument, 0, 1},
+        {"tag", required_argument, 0, 2},
+        {"quiet", no_argument, 0, 3},
+        {0, 0, 0, 0},
+    };
+    setenv("CONVERGE_SLA_MS", "1500", 1);
+    setenv("CONVERGE_TAG", "a=1,b=2", 1);
+    setenv("CONVERGE_QUIET", "off", 1);
+    std::vector<std::string> args;
+    std::vector<std::string> applied;
+    std::string error;
+    CHECK(options_from_env(long_options, {"tag"}, args, applied, error));
+    CHECK_EQ(args.size(), 3u);
+    if (args.size() == 3) {
+        CHECK_EQ(args[0], std::string("--sla-ms=1500"));
+        CHECK_EQ(args[1], std::string("--tag=a=1"));
+        CHECK_EQ(args[2], std::string("--tag=b=2"));
+    }
+    CHECK_EQ(applied.size(), 2u);
+
+    setenv("CONVERGE_QUIET", "maybe", 1);
+    CHECK(!options_from_env(long_options, {"tag"}, args, applied, error));
+    CHECK(error.find("CONVERGE_QUIET") != std::string::npos);
+    unsetenv("CONVERGE_SLA_MS");
+    unsetenv("CONVERGE_TAG");
+    unsetenv("CONVERGE_QUIET");
+}
@@ -0,0 +1,195 @@
+#include "convergence_monitor.h"
+#include "report.h"
+#include "test_util.h"
+#include <chrono>
+
+namespace {
+
+int64_t now_ms() {
+    return std::chrono::duration_cast<std::chrono::milliseconds>(
+        std::chrono::system_clock::now().time_since_epoch()).count();
+}
+
+// 5秒前触发、4秒前最后一条路由事件的会话，静默期远短于5秒时已可收敛
+std::unique_ptr<ConvergenceSession> open_session(int64_t now) {
+    auto session = std::make_unique<ConvergenceSession>(1, now - 5000,
+                                                        std::unordered_map<std::string, std::string>{});
+    session->add_route_event(now - 4500, "route_del", {{"interface", "eth1"}});
+    session->add_route_event(now - 4000, "route_add", {{"interface", "eth2"}});
+    return session;
+}
+
+} // namespace
+
+TEST_CASE(session_converges_after_quiet_period) {
+    int64_t now = now_ms();
+    auto session = open_session(now);
+    // 最后一条事件之后还没有静默60秒
+    CHECK(!session->check_convergence(60000));
+    CHECK(!session->is_converged.load());
+    CHECK(session->check_convergence(1000));
+    CHECK_EQ(session->convergence_time.value_or(-1), 1000);
+    CHECK(!session->timed_out);
+    CHECK(!session->forced);
+}
+
+TEST_CASE(session_exceeds_max_duration_only_while_open) {
+    int64_t now = now_ms();
+    auto session = open_session(now);
+    CHECK(session->exceeds_max_duration(now, 3000));
+    CHECK(session->exceeds_max_duration(now, 5000));
+    CHECK(!session->exceeds_max_duration(now, 10000));
+    // 0表示不限时长
+    CHECK(!session->exceeds_max_duration(now, 0));
+
+    session->check_convergence(1000);
+    CHECK(!session->exceeds_max_duration(now, 3000));
+}
+
+TEST_CASE(session_time_out_keeps_last_event_as_convergence_time) {
+    int64_t now = now_ms();
+    auto session = open_session(now);
+    session->time_out();
+    CHECK(session->is_converged.load());
+    CHECK(session->timed_out);
+    CHECK(!session->forced);
+    CHECK_EQ(session->convergence_time.value_or(-1), 1000);
+    CHECK_EQ(session->get_route_event_count(), 2);
+}
+
+TEST_CASE(session_force_finish_has_no_convergence_time) {
+    int64_t now = now_ms();
+    auto session = open_session(now);
+    session->force_finish();
+    CHECK(session->is_converged.load());
+    CHECK(session->forced);
+    CHECK(!session->timed_out);
+    CHECK(!session->convergence_time.has_value());
+    CHECK(session->get_session_duration() >= 5000);
+
+    // 只有触发没有路由事件的会话同样不给出收敛时间
+    ConvergenceSession trigger_only(2, now - 100, {});
+    trigger_only.force_finish();
+    CHECK(trigger_only.forced);
+    CHECK(!trigger_only.convergence_time.has_value());
+}
+
+namespace {
+
+std::unique_ptr<ConvergenceSession> finished_session(int id, int64_t convergence_ms) {
+    auto session = std::make_unique<ConvergenceSession>(id, 0, std::unordered_map<std::string, std::string>{});
+    session->is_converged.store(true);
+    session->convergence_time = convergence_ms;
+    return session;
+}
+
+} // namespace
+
+TEST_CASE(sla_counts_slow_and_timed_out_sessions_only) {
+    std::vector<std::unique_ptr<ConvergenceSession>> sessions;
+    sessions.push_back(finished_session(1, 1000));
+    sessions.push_back(finished_session(2, 2000));
+    sessions.push_back(finished_session(3, 500));
+    sessions.back()->timed_out = true;
+    // 强制结束与--resume恢复的会话不参与评估
+    sessions.push_back(std::make_unique<ConvergenceSession>(4, 0, std::unordered_map<std::string, std::string>{}));
+    sessions.back()->forced = true;
+    sessions.push_back(finished_session(5, 9000));
+    sessions.back()->resumed = true;
+
+    SlaSummary summary = ConvergenceMonitor::summarize_sla(sessions, 1500);
+    CHECK_EQ(summary.sla_ms, 1500);
+    CHECK_EQ(summary.sessions, 3);
+    CHECK_EQ(summary.violations, 2);
+    CHECK_EQ(summary.max_convergence_ms, 2000);
+    CHECK(!summary.passed());
+
+    // 没有SLA阈值时只有超时算违反
+    summary = ConvergenceMonitor::summarize_sla(sessions, 0);
+    CHECK_EQ(summary.violations, 1);
+
+    sessions.erase(sessions.begin() + 1, sessions.begin() + 3);
+    CHECK(ConvergenceMonitor::summarize_sla(sessions, 1500).passed());
+}
+
+namespace {
+
+std::string record(const std::string& router, const std::string& event_type, int64_t time_ms,
+                   const std::string& fields) {
+    return "{\"event_type\":\"" + event_type + "\",\"router_name\":\"" + router + "\",\"timestamp\":" +
+           std::to_string(time_ms) + "," + fields + "}";
+}
+
+std::string started(const std::string& router, int session_id, int64_t time_ms, const std::string& source) {
+    return record(router, "session_started", time_ms,
+                  "\"session_id\":" + std::to_string(session_id) + ",\"trigger_source\":\"" + source +
+                  "\",\"trigger_info\":\"interface=eth0\"");
+}
+
+std::string completed(const std::string& router, int session_id, int64_t time_ms, const std::string& fields) {
+    return record(router, "session_completed", time_ms,
+                  "\"session_id\":" + std::to_string(session_id) + ",\"session_duration_ms\":1000," + fields);
+}
+
+// r1：收敛、超时、强制结束各一个会话，#4在日志结尾仍未结束；r2的会话属于另一台路由器
+std::vector<std::string> resumable_log() {
+    return {
+        record("r1", "monitoring_started", 0, "\"convergence_threshold_ms\":1000"),
+        started("r1", 1, 10000, "netem"),
+        completed("r1", 1, 11000, "\"convergence_time_ms\":200,\"route_events_count\":2"),
+        started("r1", 2, 20000, "route"),
+        completed("r1", 2, 21000, "\"convergence_time_ms\":900,\"route_events_count\":7,\"timed_out\":true"),
+        started("r1", 3, 30000, "netem"),
+        completed("r1", 3, 31000, "\"route_events_count\":3,\"forced\":true"),
+        started("r1", 4, 40000, "netem"),
+        record("r2", "monitoring_started", 0, "\"convergence_threshold_ms\":1000"),
+        started("r2", 9, 10000, "netem"),
+        completed("r2", 9, 11000, "\"convergence_time_ms\":300,\"route_events_count\":1"),
+    };
+}
+
+} // namespace
+
+TEST_CASE(resume_restores_flags_and_seeds_baseline_with_converged_sessions) {
+    ReportData data;
+    std::string error;
+    CHECK(ConvergenceReport::load(write_temp_file(resumable_log()), data, error));
+
+    ResumedRun run = ConvergenceMonitor::restore_sessions(data, "r1");
+    CHECK_EQ(run.sessions.size(), 3u);
+    CHECK_EQ(run.last_session_id, 4);
+    CHECK_EQ(run.netem_triggers, 3);
+    CHECK_EQ(run.route_triggers, 1);
+    CHECK_EQ(run.route_events, 12);
+    // 超时与强制结束的会话不作为异常检测的基线
+    CHECK(run.baseline_convergence_ms == (std::vector<int64_t>{200}));
+    if (run.sessions.size() == 3) {
+        CHECK(run.sessions[0]->resumed);
+        CHECK(run.sessions[0]->is_converged.load());
+        CHECK_EQ(run.sessions[0]->get_route_event_count(), 2);
+        CHECK_EQ(run.sessions[0]->get_session_duration(), 1000);
+        CHECK(run.sessions[1]->timed_out);
+        CHECK(!run.sessions[1]->forced);
+        CHECK(run.sessions[2]->forced);
+        CHECK(!run.sessions[2]->convergence_time.has_value());
+    }
+
+    // 恢复的会话都不参与本次运行的SLA评估
+    CHECK_EQ(ConvergenceMonitor::summarize_sla(run.sessions, 100).sessions, 0);
+
+    ResumedRun other = ConvergenceMonitor::restore_sessions(data, "r2");
+    CHECK_EQ(other.sessions.size(), 1u);
+    CHECK_EQ(other.last_session_id, 9);
+}
+
+TEST_CASE(resume_single_router_log_ignores_router_name) {
+    std::vector<std::string> lines = resumable_log();
+    lines.resize(8);
+    ReportData data;
+    std::string error;
+    CHECK(ConvergenceReport::load(write_temp_file(lines), data, error));
+    // 默认路由器名称每次随机生成，只有一个路由器的日志按名称不一致也恢复
+    ResumedRun run = ConvergenceMonitor::restore_sessions(data, "r1-renamed");
+    CHECK_EQ(run.sessions.size(), 3u);
+    CHECK_EQ(run.last_session_id, 4);
+}
@@ -0,0 +1,57 @@
+#include "fib_tracer.h"
+#include "test_util.h"
+
+namespace {
+
+FibTraceEvent fib_event(int64_t timestamp_us, bool insert, const std::string& dst, int dst_len = 24) {
+    FibTraceEvent event;
+    event.timestamp_us = timestamp_us;
+    event.insert = insert;
+    event.dst = dst;
+    event.dst_len = dst_len;
+    return event;
+}
+
+std::vector<FibTraceEvent> trace() {
+    return {
+        fib_event(900000, true, "10.9.0.0"),
+        // 触发路由在内核中的删除早于收到netlink消息
+        fib_event(1000400, false, "10.0.0.0"),
+        fib_event(1020000, true, "10.1.0.0"),
+        fib_event(1250000, true, "10.0.0.0"),
+        // 晚于最后一条路由事件，不作为收敛终点
+        fib_event(1600000, false, "10.2.0.0"),
+    };
+}
+
+} // namespace
+
+TEST_CASE(fib_summary_starts_at_kernel_delete_of_trigger_route) {
+    FibTraceSummary summary = FibTraceSummary::summarize(trace(), 1000, "10.0.0.0", "24", 1250);
+    CHECK(summary.kernel_trigger);
+    CHECK_EQ(summary.inserts, 2);
+    CHECK_EQ(summary.deletes, 1);
+    CHECK_EQ(summary.convergence_us.value_or(-1), 249600);
+
+    JsonObject obj;
+    summary.add_to_json(obj);
+    CHECK(obj["kernel_trigger"].as_bool());
+    CHECK_EQ(obj["kernel_convergence_time_ms"].as_double(), 249.6);
+}
+
+TEST_CASE(fib_summary_without_route_trigger_starts_at_trigger_time) {
+    FibTraceSummary summary = FibTraceSummary::summarize(trace(), 1000, "", "", 1250);
+    CHECK(!summary.kernel_trigger);
+    CHECK_EQ(summary.inserts, 2);
+    CHECK_EQ(summary.deletes, 2);
+    CHECK_EQ(summary.convergence_us.value_or(-1), 250000);
+
+    // 前缀长度不同的删除不算触发路由
+    CHECK(!FibTraceSummary::summarize(trace(), 1000, "10.0.0.0", "16", 1250).kernel_trigger);
+
+    // 没有路由事件时没有收敛终点
+    JsonObject obj;
+    FibTraceSummary::summarize(trace(), 1000, "", "", std::nullopt).add_to_json(obj);
+    CHECK_EQ(obj.count("kernel_convergence_time_ms"), 0u);
+    CHECK_EQ(obj["fib_trace_inserts"].as_int64(), 2);
+}
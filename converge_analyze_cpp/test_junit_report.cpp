@@ -0,0 +1,55 @@
+#include "junit_report.h"
+#include "test_util.h"
+#include <fstream>
+#include <iterator>
+
+namespace {
+
+SessionSummary summary(int id, std::optional<int64_t> convergence_ms) {
+    SessionSummary result;
+    result.session_id = id;
+    result.convergence_time_ms = convergence_ms;
+    result.route_events = 2;
+    result.duration_ms = 3000;
+    result.trigger_info["interface"] = "eth<1>";
+    return result;
+}
+
+std::string write_report(const std::vector<SessionSummary>& sessions, int64_t sla_ms) {
+    std::string path = write_temp_file({});
+    std::string error;
+    CHECK(JUnitReport::write(path, "r1", sessions, sla_ms, error));
+    std::ifstream in(path);
+    return std::string(std::istreambuf_iterator<char>(in), std::istreambuf_iterator<char>());
+}
+
+bool contains(const std::string& text, const std::string& part) {
+    return text.find(part) != std::string::npos;
+}
+
+} // namespace
+
+TEST_CASE(junit_fails_slow_and_timed_out_sessions_and_skips_forced) {
+    std::vector<SessionSummary> sessions = {summary(1, 1000), summary(2, 2000), summary(3, 500),
+                                            summary(4, std::nullopt)};
+    sessions[2].timed_out = true;
+    sessions[3].forced = true;
+
+    std::string xml = write_report(sessions, 1500);
+    CHECK(contains(xml, "tests=\"4\" failures=\"2\" skipped=\"1\""));
+    CHECK(contains(xml, "convergence 2000ms exceeds SLA 1500ms"));
+    CHECK(contains(xml, "session timed out before convergence"));
+    CHECK(contains(xml, "<skipped message=\"session still open when monitoring stopped\"/>"));
+    CHECK(contains(xml, "<property name=\"sla_ms\" value=\"1500\"/>"));
+    CHECK(contains(xml, "interface=eth&lt;1&gt;"));
+
+    // 没有SLA阈值时只有超时会话失败
+    xml = write_report(sessions, 0);
+    CHECK(contains(xml, "failures=\"1\" skipped=\"1\""));
+}
+
+TEST_CASE(junit_reports_unwritable_path) {
+    std::string error;
+    CHECK(!JUnitReport::write("/nonexistent/dir/junit.xml", "r1", {summary(1, 100)}, 0, error));
+    CHECK(!error.empty());
+}
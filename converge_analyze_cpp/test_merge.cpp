@@ -0,0 +1,169 @@
+#include "merge.h"
+#include "test_util.h"
+
+namespace {
+
+ReportSession session(const std::string& router, int64_t start_ms, const std::string& source,
+                      const std::string& link = "", const std::string& netem = "") {
+    ReportSession result;
+    result.router_name = router;
+    result.session_id = 1;
+    result.start_time_ms = start_ms;
+    result.completed = true;
+    result.convergence_time_ms = 100;
+    result.trigger_source = source;
+    if (!link.empty()) {
+        result.trigger_info["link"] = link;
+    }
+    if (!netem.empty()) {
+        result.trigger_info["netem"] = netem;
+    }
+    return result;
+}
+
+} // namespace
+
+TEST_CASE(align_separates_concurrent_faults_by_link) {
+    // 两条链路几乎同时故障：路由触发的会话按触发接口所在链路归入对应故障，没有链路的按时间归入最近的故障
+    std::vector<ReportSession> sessions = {
+        session("spine1", 1000, "netem", "spine1:e1 <-> leaf1:e1", "delay 100ms"),
+        session("spine2", 1050, "netem", "spine2:e1 <-> leaf2:e1", "delay 100ms"),
+        session("leaf1", 1100, "route", "spine1:e1 <-> leaf1:e1"),
+        session("leaf3", 1200, "route"),
+    };
+    auto faults = LogMerger::align(sessions, 2000);
+    CHECK_EQ(faults.size(), 2u);
+    if (faults.size() == 2) {
+        CHECK_EQ(faults[0].routers(), std::string("spine1,leaf1"));
+        CHECK_EQ(faults[0].link, std::string("spine1:e1 <-> leaf1:e1"));
+        CHECK_EQ(faults[0].ambiguous_sessions, 0);
+        CHECK_EQ(faults[1].routers(), std::string("spine2,leaf3"));
+        CHECK_EQ(faults[1].start_time_ms, 1050);
+        CHECK_EQ(faults[1].ambiguous_sessions, 1);
+    }
+
+    // 只按时间对齐时窗口内的会话都属于同一故障
+    auto by_time = LogMerger::align(sessions, 2000, false);
+    CHECK_EQ(by_time.size(), 1u);
+    if (by_time.size() == 1) {
+        CHECK_EQ(by_time[0].sessions.size(), 4u);
+        CHECK_EQ(by_time[0].global_convergence_ms, 300);
+        CHECK_EQ(by_time[0].slowest_router, std::string("leaf3"));
+    }
+}
+
+TEST_CASE(align_separates_faults_by_netem_parameters) {
+    // 同一链路上不同的netem参数视为不同故障，特征未知的直接触发会话归入已有故障
+    std::vector<ReportSession> sessions = {
+        session("r1", 1000, "netem", "r1:eth1 <-> r2:eth1", "delay 100ms"),
+        session("r2", 1010, "netem", "r1:eth1 <-> r2:eth1", "loss 10%"),
+        session("r3", 1020, "snmp"),
+    };
+    auto faults = LogMerger::align(sessions, 500);
+    CHECK_EQ(faults.size(), 2u);
+    if (faults.size() == 2) {
+        CHECK_EQ(faults[0].netem, std::string("delay 100ms"));
+        CHECK_EQ(faults[0].routers(), std::string("r1"));
+        CHECK_EQ(faults[1].netem, std::string("loss 10%"));
+        CHECK_EQ(faults[1].routers(), std::string("r2,r3"));
+    }
+}
+
+TEST_CASE(align_counts_duplicates_and_respects_tolerance) {
+    std::vector<ReportSession> sessions = {
+        session("r1", 1000, "netem", "r1:eth1 <-> r2:eth1"),
+        session("r1", 1200, "route"),
+        session("r2", 1300, "route"),
+        session("r2", 2600, "route"),  // 超出以最早触发为起点的窗口
+    };
+    sessions[1].session_id = 2;
+    auto faults = LogMerger::align(sessions, 1500);
+    CHECK_EQ(faults.size(), 2u);
+    if (faults.size() == 2) {
+        CHECK_EQ(faults[0].routers(), std::string("r1,r2"));
+        CHECK_EQ(faults[0].duplicate_sessions, 1);
+        CHECK_EQ(faults[1].start_time_ms, 2600);
+    }
+
+    // 未完成或没有触发时间的会话不参与对齐，窗口改从r1的第二个会话算起
+    sessions[0].completed = false;
+    sessions[2].start_time_ms = -1;
+    faults = LogMerger::align(sessions, 1500);
+    CHECK_EQ(faults.size(), 1u);
+    if (faults.size() == 1) {
+        CHECK_EQ(faults[0].start_time_ms, 1200);
+        CHECK_EQ(faults[0].routers(), std::string("r1,r2"));
+    }
+}
+
+namespace {
+
+ReportClockOffset clock_offset(const std::string& router, int64_t time_ms, double offset_ms) {
+    ReportClockOffset result;
+    result.router_name = router;
+    result.time_ms = time_ms;
+    result.offset_ms = offset_ms;
+    result.rtt_ms = 1.0;
+    return result;
+}
+
+} // namespace
+
+TEST_CASE(apply_clock_offsets_adds_reference_minus_local) {
+    // offset为参考时钟减本地时钟：leaf1慢300ms，加上offset后换算到参考时钟
+    std::vector<ReportSession> sessions = {
+        session("spine1", 10000, "netem"),
+        session("leaf1", 9750, "route"),
+        session("leaf2", 10400, "route"),
+    };
+    std::vector<ReportClockOffset> offsets = {
+        clock_offset("leaf1", 0, 1000.0),      // 较早的样本，不是最近的
+        clock_offset("leaf1", 9000, 300.0),
+        clock_offset("leaf2", 12000, -349.6),  // 快约350ms
+    };
+    CHECK_EQ(LogMerger::apply_clock_offsets(sessions, offsets), 2);
+    CHECK_EQ(sessions[0].start_time_ms, 10000);
+    CHECK(!sessions[0].clock_correction_ms.has_value());
+    CHECK_EQ(sessions[1].start_time_ms, 10050);
+    CHECK_EQ(sessions[1].clock_correction_ms.value_or(0), 300);
+    CHECK_EQ(sessions[2].start_time_ms, 10050);
+    CHECK_EQ(sessions[2].clock_correction_ms.value_or(0), -350);
+
+    // 校正后三者落在同一个50ms窗口内
+    CHECK_EQ(LogMerger::align(sessions, 50).size(), 1u);
+}
+
+TEST_CASE(summarize_clock_offsets_reports_median_per_router) {
+    std::vector<ReportClockOffset> offsets = {
+        clock_offset("leaf1", 0, 10.0), clock_offset("leaf1", 1000, 30.0),
+        clock_offset("leaf1", 2000, 20.0), clock_offset("leaf2", 0, -4.0),
+        clock_offset("leaf2", 1000, -6.0),
+    };
+    offsets[1].rtt_ms = 0.5;
+    auto summaries = LogMerger::summarize_clock_offsets(offsets);
+    CHECK_EQ(summaries.size(), 2u);
+    if (summaries.size() == 2) {
+        CHECK_EQ(summaries[0].router_name, std::string("leaf1"));
+        CHECK_EQ(summaries[0].samples, 3u);
+        CHECK_EQ(summaries[0].median_offset_ms, 20.0);
+        CHECK_EQ(summaries[0].min_offset_ms, 10.0);
+        CHECK_EQ(summaries[0].max_offset_ms, 30.0);
+        CHECK_EQ(summaries[0].min_rtt_ms, 0.5);
+        CHECK_EQ(summaries[1].median_offset_ms, -5.0);
+    }
+}
+
+TEST_CASE(align_leaves_forced_router_out_of_global_convergence) {
+    // 监听结束时仍未收敛的路由器没有收敛时间，不能当作最慢的路由器
+    ReportSession forced = session("r2", 1100, "route");
+    forced.convergence_time_ms.reset();
+    forced.forced = true;
+    auto faults = LogMerger::align({session("r1", 1000, "netem"), forced}, 2000);
+    CHECK_EQ(faults.size(), 1u);
+    if (faults.size() == 1) {
+        CHECK_EQ(faults[0].sessions.size(), 2u);
+        CHECK_EQ(faults[0].forced_routers, 1);
+        CHECK_EQ(faults[0].global_convergence_ms, 100);
+        CHECK_EQ(faults[0].slowest_router, std::string("r1"));
+    }
+}
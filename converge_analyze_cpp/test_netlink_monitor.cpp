@@ -0,0 +1,165 @@
+#include "netlink_monitor.h"
+#include "test_util.h"
+#include <arpa/inet.h>
+#include <cstring>
+
+namespace {
+
+// 按内核的对齐规则拼出一条netlink消息：消息头、协议头(rtmsg/tcmsg)与若干rtattr
+class MessageBuilder {
+public:
+    template <typename Header>
+    MessageBuilder(uint16_t type, uint16_t flags, const Header& header) {
+        append(nullptr, NLMSG_HDRLEN);
+        append(&header, sizeof(header));
+        nlmsghdr* nlh = this->header();
+        nlh->nlmsg_type = type;
+        nlh->nlmsg_flags = flags;
+    }
+
+    void attribute(uint16_t type, const void* data, size_t len) {
+        struct rtattr rta;
+        rta.rta_type = type;
+        rta.rta_len = static_cast<unsigned short>(RTA_LENGTH(len));
+        append(&rta, sizeof(rta));
+        append(data, len);
+    }
+
+    void address(uint16_t type, int family, const char* text) {
+        unsigned char addr[16];
+        CHECK(inet_pton(family, text, addr) == 1);
+        attribute(type, addr, family == AF_INET ? 4 : 16);
+    }
+
+    void u32(uint16_t type, uint32_t value) { attribute(type, &value, sizeof(value)); }
+
+    nlmsghdr* header() { return reinterpret_cast<nlmsghdr*>(buffer_.data()); }
+
+    template <typename Header>
+    const Header* payload() { return static_cast<const Header*>(NLMSG_DATA(header())); }
+
+    // 协议头之后的属性区
+    template <typename Header>
+    std::pair<const struct rtattr*, int> attributes() {
+        size_t offset = NLMSG_HDRLEN + NLMSG_ALIGN(sizeof(Header));
+        return {reinterpret_cast<const struct rtattr*>(buffer_.data() + offset),
+                static_cast<int>(buffer_.size() - offset)};
+    }
+
+private:
+    std::vector<char> buffer_;
+
+    void append(const void* data, size_t len) {
+        size_t offset = buffer_.size();
+        buffer_.resize(offset + NLMSG_ALIGN(len), 0);
+        if (data) {
+            std::memcpy(buffer_.data() + offset, data, len);
+        }
+        header()->nlmsg_len = static_cast<uint32_t>(buffer_.size());
+    }
+};
+
+struct rtmsg route_header(unsigned char family, unsigned char dst_len) {
+    struct rtmsg rtm;
+    std::memset(&rtm, 0, sizeof(rtm));
+    rtm.rtm_family = family;
+    rtm.rtm_dst_len = dst_len;
+    rtm.rtm_table = RT_TABLE_MAIN;
+    rtm.rtm_protocol = RTPROT_STATIC;
+    rtm.rtm_scope = RT_SCOPE_UNIVERSE;
+    rtm.rtm_type = RTN_UNICAST;
+    return rtm;
+}
+
+std::string type_of(uint16_t type, uint16_t flags, unsigned char family = AF_INET) {
+    MessageBuilder message(type, flags, route_header(family, 24));
+    return NetlinkMonitor::message_type_to_string(NetlinkMonitor::get_message_type(message.header()));
+}
+
+} // namespace
+
+TEST_CASE(netlink_classifies_route_replace_separately_from_add) {
+    // ip route add与新建路由的ip route replace带NLM_F_CREATE|NLM_F_EXCL，替换已有路由时带NLM_F_REPLACE
+    CHECK_EQ(type_of(RTM_NEWROUTE, NLM_F_CREATE | NLM_F_EXCL), std::string("route_add"));
+    CHECK_EQ(type_of(RTM_NEWROUTE, NLM_F_CREATE), std::string("route_add"));
+    CHECK_EQ(type_of(RTM_NEWROUTE, NLM_F_REPLACE), std::string("route_replace"));
+    CHECK_EQ(type_of(RTM_DELROUTE, 0), std::string("route_del"));
+    // 组播路由复用同样的消息类型
+    CHECK_EQ(type_of(RTM_NEWROUTE, NLM_F_REPLACE, RTNL_FAMILY_IPMR), std::string("mroute_add"));
+    CHECK_EQ(type_of(RTM_DELROUTE, 0, RTNL_FAMILY_IP6MR), std::string("mroute_del"));
+}
+
+TEST_CASE(netlink_classifies_qdisc_add_and_change) {
+    struct tcmsg tcm;
+    std::memset(&tcm, 0, sizeof(tcm));
+    MessageBuilder added(RTM_NEWQDISC, NLM_F_CREATE | NLM_F_REPLACE, tcm);
+    MessageBuilder changed(RTM_NEWQDISC, 0, tcm);
+    MessageBuilder deleted(RTM_DELQDISC, 0, tcm);
+    CHECK(NetlinkMonitor::get_message_type(added.header()) == NetlinkMessageType::QDISC_ADD);
+    CHECK(NetlinkMonitor::get_message_type(changed.header()) == NetlinkMessageType::QDISC_CHANGE);
+    CHECK(NetlinkMonitor::get_message_type(deleted.header()) == NetlinkMessageType::QDISC_DEL);
+}
+
+TEST_CASE(netlink_decodes_source_route_scope_and_metrics) {
+    struct rtmsg rtm = route_header(AF_INET6, 64);
+    rtm.rtm_src_len = 48;
+    rtm.rtm_scope = RT_SCOPE_LINK;
+    rtm.rtm_protocol = RTPROT_ZEBRA;
+    rtm.rtm_flags = RTNH_F_ONLINK;
+    MessageBuilder message(RTM_NEWROUTE, NLM_F_CREATE | NLM_F_EXCL, rtm);
+    message.address(RTA_DST, AF_INET6, "2001:db8:1::");
+    message.address(RTA_SRC, AF_INET6, "2001:db8:ff::");
+    message.address(RTA_GATEWAY, AF_INET6, "fe80::1");
+    message.address(RTA_PREFSRC, AF_INET6, "2001:db8::10");
+    message.u32(RTA_OIF, 999999);
+    message.u32(RTA_PRIORITY, 4000000000u);
+    message.u32(RTA_TABLE, 1001);
+    struct rtattr mtu;
+    uint32_t mtu_value = 1400;
+    mtu.rta_type = RTAX_MTU;
+    mtu.rta_len = RTA_LENGTH(sizeof(mtu_value));
+    char metrics[RTA_SPACE(sizeof(uint32_t))] = {};
+    std::memcpy(metrics, &mtu, sizeof(mtu));
+    std::memcpy(metrics + RTA_LENGTH(0), &mtu_value, sizeof(mtu_value));
+    message.attribute(RTA_METRICS, metrics, sizeof(metrics));
+
+    auto attributes = message.attributes<struct rtmsg>();
+    auto info = NetlinkMessageParser::parse_route_message(message.payload<struct rtmsg>(), attributes.first,
+                                                          attributes.second);
+    CHECK_EQ(info["dst"], std::string("2001:db8:1::"));
+    CHECK_EQ(info["dst_len"], std::string("64"));
+    CHECK_EQ(info["src"], std::string("2001:db8:ff::"));
+    CHECK_EQ(info["src_len"], std::string("48"));
+    CHECK_EQ(info["gateway"], std::string("fe80::1"));
+    CHECK_EQ(info["prefsrc"], std::string("2001:db8::10"));
+    CHECK_EQ(info["interface"], std::string("if999999"));
+    CHECK_EQ(info["scope"], std::string("link"));
+    CHECK_EQ(info["protocol"], std::string("zebra"));
+    CHECK_EQ(info["origin"], std::string("protocol"));
+    CHECK_EQ(info["flags"], std::string("onlink"));
+    // metric按无符号解析，表ID以RTA_TABLE为准
+    CHECK_EQ(info["priority"], std::string("4000000000"));
+    CHECK_EQ(info["table"], std::string("1001"));
+    CHECK_EQ(info["mtu"], std::string("1400"));
+
+    auto described = NetlinkMessageParser::describe_message(message.header());
+    CHECK_EQ(described["nlmsg_type"], std::string("RTM_NEWROUTE"));
+    CHECK_EQ(described["nlmsg_flags"], std::string("0x0600"));
+    CHECK_EQ(described["src"], std::string("2001:db8:ff::"));
+}
+
+TEST_CASE(netlink_classifies_route_origin) {
+    struct rtmsg rtm = route_header(AF_INET, 24);
+    rtm.rtm_protocol = RTPROT_KERNEL;
+    rtm.rtm_scope = RT_SCOPE_LINK;
+    CHECK_EQ(NetlinkMessageParser::classify_route_origin(&rtm), std::string("connected"));
+    rtm.rtm_type = RTN_LOCAL;
+    CHECK_EQ(NetlinkMessageParser::classify_route_origin(&rtm), std::string("local"));
+    rtm.rtm_type = RTN_UNICAST;
+    rtm.rtm_protocol = RTPROT_BOOT;
+    CHECK_EQ(NetlinkMessageParser::classify_route_origin(&rtm), std::string("static"));
+    rtm.rtm_flags = RTM_F_CLONED;
+    CHECK_EQ(NetlinkMessageParser::classify_route_origin(&rtm), std::string("cache"));
+    CHECK_EQ(NetlinkMessageParser::get_route_scope_name(RT_SCOPE_HOST), std::string("host"));
+    CHECK_EQ(NetlinkMessageParser::get_route_scope_name(42), std::string("42"));
+}
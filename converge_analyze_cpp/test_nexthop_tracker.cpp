@@ -0,0 +1,114 @@
+#include "nexthop_tracker.h"
+#include "test_util.h"
+#include <sys/socket.h>
+
+namespace {
+
+NexthopTracker::RouteInfo route(const std::string& gateway, const std::string& interface = "eth1") {
+    return {{"family", std::to_string(AF_INET)}, {"table", "254"},  {"dst", "10.0.0.0"},
+            {"dst_len", "24"},                   {"gateway", gateway}, {"interface", interface},
+            {"origin", "protocol"},              {"type", "unicast"}};
+}
+
+} // namespace
+
+TEST_CASE(nexthop_replace_reports_gateway_change) {
+    NexthopTracker tracker(100);
+    NexthopChange change;
+    // 第一次出现的前缀只建立基线
+    CHECK(!tracker.update(1000, "route_add", route("192.0.2.1"), 500, change));
+    CHECK_EQ(tracker.size(), 1u);
+    // 下一跳不变的替换不算切换
+    CHECK(!tracker.update(1100, "route_replace", route("192.0.2.1"), 500, change));
+
+    CHECK(tracker.update(1200, "route_replace", route("192.0.2.2", "eth2"), 500, change));
+    CHECK_EQ(change.kind, std::string("replace"));
+    CHECK_EQ(change.prefix, std::string("10.0.0.0/24"));
+    CHECK_EQ(change.old_nexthop, std::string("192.0.2.1 dev eth1"));
+    CHECK_EQ(change.new_nexthop, std::string("192.0.2.2 dev eth2"));
+    CHECK_EQ(change.old_gateway, std::string("192.0.2.1"));
+    CHECK_EQ(change.new_gateway, std::string("192.0.2.2"));
+    CHECK_EQ(change.withdrawn_ms, 0);
+}
+
+TEST_CASE(nexthop_delete_then_add_within_window) {
+    NexthopTracker tracker(100);
+    NexthopChange change;
+    tracker.update(1000, "route_add", route("192.0.2.1"), 500, change);
+    CHECK(!tracker.update(2000, "route_del", route("192.0.2.1"), 500, change));
+    CHECK(tracker.update(2300, "route_add", route("192.0.2.2"), 500, change));
+    CHECK_EQ(change.kind, std::string("del_add"));
+    CHECK_EQ(change.withdrawn_ms, 300);
+    CHECK_EQ(change.old_gateway, std::string("192.0.2.1"));
+
+    // 超出窗口后重新添加视为新的可达性，不算切换
+    tracker.update(3000, "route_del", route("192.0.2.2"), 500, change);
+    CHECK(!tracker.update(3600, "route_add", route("192.0.2.3"), 500, change));
+}
+
+TEST_CASE(nexthop_tracks_ecmp_discard_routes_and_skips_local) {
+    NexthopTracker tracker(100);
+    NexthopChange change;
+    NexthopTracker::RouteInfo ecmp = route("N/A");
+    ecmp["nexthops"] = "192.0.2.1 dev eth1,192.0.2.2 dev eth2";
+    tracker.update(1000, "route_add", ecmp, 500, change);
+
+    NexthopTracker::RouteInfo blackhole = route("N/A");
+    blackhole["type"] = "blackhole";
+    CHECK(tracker.update(1100, "route_replace", blackhole, 500, change));
+    CHECK_EQ(change.old_nexthop, ecmp["nexthops"]);
+    CHECK_EQ(change.new_nexthop, std::string("blackhole"));
+    // ECMP没有单一网关
+    CHECK_EQ(change.old_gateway, std::string("N/A"));
+
+    NexthopTracker::RouteInfo local = route("N/A");
+    local["origin"] = "local";
+    CHECK(!NexthopTracker::trackable(local));
+    NexthopTracker::RouteInfo mroute = route("N/A");
+    mroute["family"] = "128";
+    CHECK(!NexthopTracker::trackable(mroute));
+}
+
+TEST_CASE(nexthop_cache_capacity_counts_overflow) {
+    NexthopTracker tracker(1);
+    NexthopChange change;
+    tracker.update(1000, "route_add", route("192.0.2.1"), 500, change);
+    NexthopTracker::RouteInfo other = route("192.0.2.1");
+    other["dst"] = "10.9.0.0";
+    tracker.update(1000, "route_add", other, 500, change);
+    CHECK_EQ(tracker.size(), 1u);
+    CHECK_EQ(tracker.overflow(), 1);
+    // 未记录的前缀不会产生切换
+    other["gateway"] = "192.0.2.9";
+    CHECK(!tracker.update(1100, "route_replace", other, 500, change));
+}
+
+TEST_CASE(session_nexthop_changes_split_fast_reroute_and_spf) {
+    SessionNexthopChanges changes;
+    JsonObject empty;
+    changes.add_to_json(empty, 50);
+    CHECK(empty.empty());
+
+    CHECK(changes.record(20, 50));
+    CHECK(changes.record(50, 50));
+    CHECK(!changes.record(900, 50));
+    CHECK_EQ(changes.frr_activation_offset.value_or(-1), 50);
+    CHECK_EQ(changes.last_change_offset.value_or(-1), 900);
+
+    JsonObject obj;
+    changes.add_to_json(obj, 50);
+    CHECK_EQ(obj["nexthop_changes"].as_int64(), 3);
+    CHECK_EQ(obj["last_nexthop_change_ms"].as_int64(), 900);
+    CHECK(obj["frr_activated"].as_bool());
+    CHECK_EQ(obj["frr_activation_ms"].as_int64(), 50);
+    CHECK_EQ(obj["frr_nexthop_changes"].as_int64(), 2);
+    CHECK_EQ(obj["spf_nexthop_changes"].as_int64(), 1);
+
+    // 不区分快速重路由时不写frr_*字段
+    SessionNexthopChanges plain;
+    CHECK(!plain.record(20, 0));
+    JsonObject plain_obj;
+    plain.add_to_json(plain_obj, 0);
+    CHECK_EQ(plain_obj.count("frr_activated"), 0u);
+    CHECK_EQ(plain_obj["nexthop_changes"].as_int64(), 1);
+}
@@ -0,0 +1,49 @@
+#include "qdisc_stats_poller.h"
+#include "test_util.h"
+
+namespace {
+
+QdiscStats sample(int64_t timestamp_ms, const std::string& handle, int64_t drops, int64_t backlog,
+                  int64_t packets) {
+    QdiscStats stats;
+    stats.timestamp_ms = timestamp_ms;
+    stats.kind = "netem";
+    stats.handle = handle;
+    stats.drops = drops;
+    stats.backlog = backlog;
+    stats.qlen = backlog / 100;
+    stats.packets = packets;
+    return stats;
+}
+
+} // namespace
+
+TEST_CASE(qdisc_summary_accumulates_deltas_across_replaced_qdisc) {
+    std::vector<QdiscStats> samples = {
+        sample(1100, "8001:", 10, 300, 1000),
+        sample(1200, "8001:", 15, 900, 1050),
+        // 计数倒退按0计
+        sample(1300, "8001:", 12, 200, 1040),
+        // qdisc被替换，新qdisc的计数从其创建时算起
+        sample(1400, "8002:", 4, 0, 30),
+    };
+    QdiscStatsSummary summary = QdiscStatsSummary::summarize(samples, 1000);
+    CHECK_EQ(summary.samples, 4);
+    CHECK_EQ(summary.drops, 9);
+    CHECK_EQ(summary.sent_packets, 80);
+    CHECK_EQ(summary.max_backlog, 900);
+    CHECK_EQ(summary.handle, std::string("8002:"));
+    CHECK_EQ(summary.offset_series, std::string("100,200,300,400"));
+    CHECK_EQ(summary.drops_series, std::string("0,5,5,9"));
+    CHECK_EQ(summary.qlen_series, std::string("3,9,2,0"));
+
+    JsonObject obj;
+    summary.add_to_json(obj, 100);
+    CHECK_EQ(obj["qdisc_stats_interval_ms"].as_int64(), 100);
+    CHECK_EQ(obj["qdisc_drops_delta"].as_int64(), 9);
+    CHECK_EQ(obj["qdisc_kind"].as_string(), std::string("netem"));
+
+    JsonObject empty;
+    QdiscStatsSummary::summarize({}, 1000).add_to_json(empty, 100);
+    CHECK(empty.empty());
+}
@@ -0,0 +1,229 @@
+#include "compare.h"
+#include "log_reader.h"
+#include "report.h"
+#include "test_util.h"
+
+namespace {
+
+std::string record(const std::string& event_type, int64_t time_ms, const std::string& fields) {
+    return "{\"event_type\":\"" + event_type + "\",\"router_name\":\"r1\",\"timestamp\":" +
+           std::to_string(time_ms) + (fields.empty() ? "" : "," + fields) + "}";
+}
+
+std::string started(int session_id, int64_t time_ms) {
+    return record("session_started", time_ms,
+                  "\"session_id\":" + std::to_string(session_id) +
+                  ",\"trigger_source\":\"netem\",\"trigger_event_type\":\"qdisc_add\","
+                  "\"trigger_info\":\"interface=eth0\"");
+}
+
+// 两个正常收敛的会话，第三个会话在监听结束时被强制结束，没有收敛时间
+std::vector<std::string> log_with_forced_session() {
+    return {
+        record("monitoring_started", 0, "\"convergence_threshold_ms\":1000"),
+        started(1, 10000),
+        record("session_completed", 11200,
+               "\"session_id\":1,\"convergence_time_ms\":200,\"route_events_count\":2,\"session_duration_ms\":1200"),
+        started(2, 20000),
+        record("session_completed", 21400,
+               "\"session_id\":2,\"convergence_time_ms\":400,\"route_events_count\":4,\"session_duration_ms\":1400"),
+        started(3, 30000),
+        record("session_completed", 30300,
+               "\"session_id\":3,\"route_events_count\":9,\"session_duration_ms\":300,\"forced\":true"),
+        record("monitoring_completed", 30300, ""),
+    };
+}
+
+} // namespace
+
+TEST_CASE(report_loads_forced_session_without_counting_it) {
+    ReportData data;
+    std::string error;
+    CHECK(ConvergenceReport::load(write_temp_file(log_with_forced_session()), data, error));
+    CHECK_EQ(data.sessions.size(), 3u);
+    if (data.sessions.size() != 3) {
+        return;
+    }
+    CHECK(!data.sessions[0].forced);
+    CHECK(data.sessions[2].completed);
+    CHECK(data.sessions[2].forced);
+    CHECK(!data.sessions[2].timed_out);
+    CHECK(!data.sessions[2].convergence_time_ms.has_value());
+    CHECK(LogReader::get_bool(ConvergenceReport::session_to_json(data.sessions[2]), "forced"));
+
+    auto times = ConvergenceReport::convergence_times(data.sessions);
+    CHECK_EQ(times.size(), 2u);
+    auto stats = ConvergenceReport::compute_stats(times);
+    CHECK_EQ(stats.max, 400.0);
+}
+
+TEST_CASE(compare_ignores_forced_sessions) {
+    // 强制结束的会话的事件数与时长只统计到一半，两边都不参与对比
+    ReportData baseline;
+    ReportData candidate;
+    std::string error;
+    CHECK(ConvergenceReport::load(write_temp_file(log_with_forced_session()), baseline, error));
+    CHECK(ConvergenceReport::load(write_temp_file(log_with_forced_session()), candidate, error));
+    candidate.sessions.pop_back();
+
+    auto results = RunComparison::compare(baseline, candidate, 10.0, 0.05);
+    CHECK_EQ(results.size(), 3u);
+    for (const auto& result : results) {
+        CHECK_EQ(result.baseline.count, 2u);
+        CHECK_EQ(result.candidate.count, 2u);
+        CHECK_EQ(result.mean_delta_pct, 0.0);
+        CHECK(!result.regression);
+    }
+}
+
+TEST_CASE(report_rebuilds_events_churn_and_phases) {
+    std::vector<std::string> lines = {
+        record("monitoring_started", 0, "\"convergence_threshold_ms\":1000"),
+        // 触发前300ms的邻接变化归入该会话，作为故障检测时刻
+        record("igp_adjacency_event", 9700,
+               "\"protocol\":\"ospf\",\"old_state\":\"Full\",\"new_state\":\"Down\",\"neighbor\":\"10.0.0.2\","
+               "\"interface\":\"eth0\""),
+        started(1, 10000),
+        record("route_event", 10100,
+               "\"session_id\":1,\"offset_from_trigger_ms\":100,\"route_event_type\":\"route_del\","
+               "\"route_info\":\"{\\\"dst\\\":\\\"10.1.0.0/24\\\",\\\"interface\\\":\\\"eth0\\\"}\""),
+        record("frr_log_event", 10150,
+               "\"session_id\":1,\"offset_from_trigger_ms\":150,\"category\":\"spf_end\",\"daemon\":\"ospfd\","
+               "\"message\":\"SPF done\""),
+        record("route_event", 11300,
+               "\"session_id\":1,\"offset_from_trigger_ms\":1300,\"route_event_type\":\"route_add\","
+               "\"coalesced_count\":3,\"route_info\":\"dst=10.1.0.0/24,interface=eth1\""),
+        record("session_completed", 12300,
+               "\"session_id\":1,\"convergence_time_ms\":1300,\"route_events_count\":4,"
+               "\"session_duration_ms\":2300,\"churn_per_second\":\"1,3\""),
+        record("monitoring_completed", 20000, "\"total_listen_duration_ms\":20000"),
+    };
+    ReportData data;
+    std::string error;
+    CHECK(ConvergenceReport::load(write_temp_file(lines), data, error));
+    CHECK_EQ(data.convergence_threshold_ms, 1000);
+    CHECK_EQ(data.total_listen_duration_ms, 20000);
+    CHECK(data.routers == (std::vector<std::string>{"r1"}));
+    CHECK_EQ(data.sessions.size(), 1u);
+    if (data.sessions.size() != 1) {
+        return;
+    }
+    ReportSession& session = data.sessions[0];
+    CHECK(session.completed);
+    CHECK_EQ(session.start_time_ms, 10000);
+    CHECK_EQ(session.route_events, 4);
+    CHECK(session.churn_per_second == (std::vector<int64_t>{1, 3}));
+    CHECK_EQ(session.events.size(), 4u);
+    if (session.events.size() == 4) {
+        CHECK_EQ(session.events[0].info["interface"], std::string("eth0"));
+        CHECK_EQ(session.events[2].type, std::string("route_add x3"));
+        CHECK_EQ(session.events[3].offset_ms, -300);
+    }
+
+    ConvergencePhases phases = ConvergenceReport::compute_phases(session);
+    CHECK_EQ(phases.detection_ms.value_or(0), -300);
+    CHECK_EQ(phases.spf_done_ms.value_or(0), 150);
+    CHECK_EQ(phases.fib_done_ms.value_or(0), 1300);
+
+    // 会话CSV附带阶段分解
+    std::string csv = ConvergenceReport::render_sessions_csv(data);
+    CHECK(csv.find(",netem,qdisc_add,,,1300,4,2300,false,false,true,,-300,150\n") != std::string::npos);
+}
+
+TEST_CASE(report_keeps_sessions_of_separate_runs_apart) {
+    // 同一日志中追加的两次运行都从#1编号
+    std::vector<std::string> lines = log_with_forced_session();
+    std::vector<std::string> second = {
+        record("monitoring_started", 40000, "\"convergence_threshold_ms\":1000"),
+        started(1, 50000),
+        record("session_completed", 51000,
+               "\"session_id\":1,\"convergence_time_ms\":600,\"route_events_count\":1,\"session_duration_ms\":1000"),
+    };
+    lines.insert(lines.end(), second.begin(), second.end());
+    ReportData data;
+    std::string error;
+    CHECK(ConvergenceReport::load(write_temp_file(lines), data, error));
+    CHECK_EQ(data.sessions.size(), 4u);
+    if (data.sessions.size() == 4) {
+        CHECK_EQ(data.sessions[3].session_id, 1);
+        CHECK(data.sessions[3].run_index != data.sessions[0].run_index);
+        CHECK_EQ(data.sessions[0].convergence_time_ms.value_or(0), 200);
+    }
+}
+
+TEST_CASE(report_stats_use_nearest_rank_p95) {
+    auto stats = ConvergenceReport::compute_stats({400, 100, 300, 200});
+    CHECK_EQ(stats.count, 4u);
+    CHECK_EQ(stats.min, 100.0);
+    CHECK_EQ(stats.max, 400.0);
+    CHECK_EQ(stats.mean, 250.0);
+    CHECK_EQ(stats.median, 250.0);
+    CHECK_EQ(stats.p95, 400.0);
+
+    std::vector<double> values;
+    for (int i = 1; i <= 20; ++i) {
+        values.push_back(i * 10.0);
+    }
+    stats = ConvergenceReport::compute_stats(values);
+    CHECK_EQ(stats.p95, 190.0);
+    CHECK_EQ(stats.median, 105.0);
+
+    CHECK_EQ(ConvergenceReport::compute_stats({}).count, 0u);
+}
+
+namespace {
+
+ReportData run_with_convergence(const std::vector<int64_t>& convergence_ms) {
+    ReportData data;
+    int id = 0;
+    for (int64_t value : convergence_ms) {
+        ReportSession session;
+        session.session_id = ++id;
+        session.completed = true;
+        session.convergence_time_ms = value;
+        session.route_events = 4;
+        session.duration_ms = 2000;
+        data.sessions.push_back(session);
+    }
+    return data;
+}
+
+} // namespace
+
+TEST_CASE(compare_flags_significant_regression_and_improvement) {
+    ReportData baseline = run_with_convergence({200, 210, 190, 205, 195});
+    ReportData slower = run_with_convergence({400, 410, 390, 405, 395});
+
+    auto results = RunComparison::compare(baseline, slower, 10.0, 0.05);
+    CHECK_EQ(results.size(), 3u);
+    if (results.size() == 3) {
+        CHECK_EQ(results[0].metric, std::string("convergence_time_ms"));
+        CHECK_EQ(results[0].mean_delta_pct, 100.0);
+        CHECK(results[0].p_value < 0.001);
+        CHECK(results[0].regression);
+        // 路由事件数与会话时长不变
+        CHECK(!results[1].regression);
+        CHECK_EQ(results[1].p_value, 1.0);
+        CHECK(!results[2].regression);
+        CHECK(RunComparison::render_table(results).find("REGRESSION") != std::string::npos);
+    }
+
+    results = RunComparison::compare(slower, baseline, 10.0, 0.05);
+    CHECK(!results[0].regression);
+    CHECK(results[0].improvement);
+
+    // 超过容差但不显著时不判定为回归
+    results = RunComparison::compare(run_with_convergence({100, 300}), run_with_convergence({150, 350}), 10.0, 0.05);
+    CHECK(results[0].mean_delta_pct > 10.0);
+    CHECK(!results[0].regression);
+}
+
+TEST_CASE(welch_p_value_edge_cases) {
+    // 每侧不足2个样本时无法判定
+    CHECK_EQ(RunComparison::welch_p_value({100}, {100, 200}), 1.0);
+    // 方差为0时按均值是否相同判定
+    CHECK_EQ(RunComparison::welch_p_value({100, 100}, {100, 100}), 1.0);
+    CHECK_EQ(RunComparison::welch_p_value({100, 100}, {200, 200}), 0.0);
+    double p = RunComparison::welch_p_value({1, 2, 3, 4, 5}, {2, 3, 4, 5, 6});
+    CHECK(p > 0.3 && p < 0.4);
+}
@@ -0,0 +1,56 @@
+#include "threshold_override.h"
+#include "test_util.h"
+
+TEST_CASE(threshold_override_first_matching_rule_wins) {
+    ThresholdOverrides overrides;
+    std::string error;
+    CHECK(overrides.empty());
+    CHECK(overrides.parse("eth0=1000, eth*=3000,type:qdisc=5000", error));
+    CHECK_EQ(overrides.rules.size(), 3u);
+    CHECK_EQ(overrides.text(), std::string("eth0=1000,eth*=3000,type:qdisc=5000"));
+
+    std::string rule;
+    CHECK_EQ(overrides.match("eth0", "route", "route_del", rule).value_or(0), 1000);
+    CHECK_EQ(rule, std::string("eth0=1000"));
+    CHECK_EQ(overrides.match("eth12", "qdisc", "qdisc_add", rule).value_or(0), 3000);
+    CHECK_EQ(rule, std::string("eth*=3000"));
+    // 接口规则都不命中时按触发类型匹配
+    CHECK_EQ(overrides.match("bond0", "qdisc", "qdisc_add", rule).value_or(0), 5000);
+    CHECK_EQ(rule, std::string("type:qdisc=5000"));
+    CHECK(!overrides.match("bond0", "route", "route_add", rule).has_value());
+    CHECK(!overrides.match("", "route", "", rule).has_value());
+}
+
+TEST_CASE(threshold_override_type_rules_match_source_or_event_type) {
+    ThresholdOverrides overrides;
+    std::string error;
+    CHECK(overrides.parse("type:route_del=800", error));
+    // 再次解析时追加到已有规则之后
+    CHECK(overrides.parse("type:multi*=2500,lo?=100", error));
+    CHECK_EQ(overrides.rules.size(), 3u);
+    CHECK(overrides.rules[1].by_type);
+    CHECK_EQ(overrides.rules[1].pattern, std::string("multi*"));
+
+    std::string rule;
+    CHECK_EQ(overrides.match("eth0", "route", "route_del", rule).value_or(0), 800);
+    CHECK_EQ(overrides.match("eth0", "multipath", "", rule).value_or(0), 2500);
+    CHECK_EQ(overrides.match("lo1", "route", "route_add", rule).value_or(0), 100);
+    CHECK(!overrides.match("lo", "route", "route_add", rule).has_value());
+}
+
+TEST_CASE(threshold_override_rejects_invalid_specs) {
+    for (const char* spec : {"", " , ", "eth0", "=1000", "eth0=", "eth0=abc", "eth0=0", "eth0=-5", "eth0=10ms",
+                             "type:=1000"}) {
+        ThresholdOverrides overrides;
+        std::string error;
+        CHECK(!overrides.parse(spec, error));
+        CHECK(!error.empty());
+        CHECK(overrides.empty());
+    }
+
+    // 任一条无效时不追加任何规则
+    ThresholdOverrides overrides;
+    std::string error;
+    CHECK(!overrides.parse("eth0=1000,eth1=x", error));
+    CHECK(overrides.empty());
+}
@@ -0,0 +1,107 @@
+#include "trigger_rule.h"
+#include "test_util.h"
+
+namespace {
+
+using Fields = std::unordered_map<std::string, std::string>;
+
+RuleEvent event(const std::string& category, const std::string& type, const Fields& info) {
+    RuleEvent result;
+    result.category = category;
+    result.type = type;
+    result.info = &info;
+    return result;
+}
+
+bool matches(const std::string& text, const RuleEvent& rule_event) {
+    std::string error;
+    auto expression = TriggerExpression::parse(text, error);
+    CHECK(expression != nullptr);
+    return expression && expression->evaluate(rule_event);
+}
+
+} // namespace
+
+TEST_CASE(default_triggers_match_netem_qdisc_and_route_changes) {
+    TriggerRules rules;
+    CHECK(!rules.custom());
+
+    Fields netem = {{"kind", "netem"}, {"is_netem", "true"}, {"interface", "eth1"}};
+    Fields fq = {{"kind", "fq_codel"}, {"is_netem", "false"}, {"interface", "eth1"}};
+    Fields replaced = {{"kind", "fq_codel"}, {"is_netem", "false"}, {"replaced_kind", "netem"}};
+    CHECK(rules.is_trigger(event("qdisc", "qdisc_add", netem)));
+    CHECK(!rules.is_trigger(event("qdisc", "qdisc_add", fq)));
+    CHECK(rules.is_trigger(event("qdisc", "qdisc_replace", replaced)));
+
+    // 删除事件不带kind，由监控核心补充的was_netem判断
+    Fields deleted = {{"interface", "eth1"}};
+    RuleEvent qdisc_del = event("qdisc", "qdisc_del", deleted);
+    qdisc_del.extra["was_netem"] = "false";
+    CHECK(!rules.is_trigger(qdisc_del));
+    qdisc_del.extra["was_netem"] = "true";
+    CHECK(rules.is_trigger(qdisc_del));
+
+    Fields route = {{"dst", "10.0.0.0/24"}, {"interface", "eth2"}};
+    for (const char* type : {"route_add", "route_del", "route_replace", "rule_add", "fdb_move", "tunnel_down",
+                             "lag_failover"}) {
+        CHECK(rules.is_trigger(event("route", type, route)));
+    }
+    CHECK(!rules.is_trigger(event("route", "gnmi_update", route)));
+    CHECK(!rules.is_trigger(event("route", "route_added", route)));
+    // 路由类别的表达式不匹配qdisc事件
+    CHECK(!rules.is_trigger(event("qdisc", "route_add", route)));
+}
+
+TEST_CASE(trigger_expression_operators) {
+    Fields info = {{"interface", "eth3"}, {"table", "254"}, {"kind", "netem"}, {"flag", "0"}};
+    RuleEvent qdisc = event("qdisc", "qdisc_add", info);
+
+    CHECK(matches("qdisc.kind == \"netem\" && iface =~ \"eth[1-4]\"", qdisc));
+    CHECK(!matches("route.kind == \"netem\"", qdisc));
+    CHECK(matches("event == \"qdisc_add\"", qdisc));
+    CHECK(matches("iface !~ \"^lo\"", qdisc));
+    // 两侧都是数字时按数值比较
+    CHECK(matches("table == 254.0", qdisc));
+    CHECK(matches("table >= 100 && table < 255", qdisc));
+    CHECK(!matches("kind > 1", qdisc));
+    // 单独的字段：非空且不是"false"/"0"时为真
+    CHECK(matches("kind", qdisc));
+    CHECK(!matches("flag", qdisc));
+    CHECK(!matches("missing", qdisc));
+    CHECK(matches("!(flag || missing) && (kind != \"tbf\")", qdisc));
+}
+
+TEST_CASE(trigger_expression_parse_errors) {
+    for (const char* text : {"", "iface ==", "(kind == \"netem\"", "iface =~ 5", "iface =~ \"[\"",
+                             "kind == \"netem", "kind $ 1", "kind kind"}) {
+        std::string error;
+        CHECK(TriggerExpression::parse(text, error) == nullptr);
+        CHECK(!error.empty());
+    }
+}
+
+TEST_CASE(custom_trigger_rules_replace_defaults_and_ignores_win) {
+    TriggerRules rules;
+    std::string error;
+    CHECK(rules.add_trigger("route.event == \"route_del\"", error));
+    CHECK(rules.custom());
+
+    Fields netem = {{"kind", "netem"}, {"is_netem", "true"}};
+    Fields route = {{"dst", "10.0.0.0/24"}, {"interface", "eth2"}};
+    CHECK(!rules.is_trigger(event("qdisc", "qdisc_add", netem)));
+    CHECK(!rules.is_trigger(event("route", "route_add", route)));
+    CHECK(rules.is_trigger(event("route", "route_del", route)));
+
+    // "default"在自定义规则之外追加默认规则
+    CHECK(rules.add_trigger("default", error));
+    CHECK(rules.is_trigger(event("qdisc", "qdisc_add", netem)));
+    CHECK(rules.triggers_text().find("route.event == \"route_del\" ; qdisc.is_netem") == 0);
+
+    CHECK(rules.add_ignore("iface == \"eth2\"", error));
+    CHECK(rules.is_ignored(event("route", "route_del", route)));
+    CHECK(!rules.is_ignored(event("qdisc", "qdisc_add", netem)));
+    CHECK_EQ(rules.ignores_text(), std::string("iface == \"eth2\""));
+
+    CHECK(!rules.add_ignore("iface ==", error));
+    CHECK(!rules.add_trigger("(", error));
+}
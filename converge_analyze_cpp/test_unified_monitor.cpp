@@ -0,0 +1,66 @@
+#include "test_util.h"
+#include <cstdlib>
+#include <fstream>
+#include <unistd.h>
+
+std::vector<TestCase>& test_registry() {
+    static std::vector<TestCase> registry;
+    return registry;
+}
+
+int& test_failures() {
+    static int failures = 0;
+    return failures;
+}
+
+namespace {
+
+std::vector<std::string>& temp_files() {
+    static std::vector<std::string> files;
+    return files;
+}
+
+} // namespace
+
+std::string write_temp_file(const std::vector<std::string>& lines) {
+    char path[] = "/tmp/converge_test_XXXXXX";
+    int fd = mkstemp(path);
+    if (fd < 0) {
+        std::cerr << "❌ 无法创建临时文件\n";
+        std::exit(1);
+    }
+    close(fd);
+    std::ofstream out(path, std::ios::out | std::ios::trunc);
+    for (const auto& line : lines) {
+        out << line << "\n";
+    }
+    temp_files().push_back(path);
+    return path;
+}
+
+// 依次运行所有注册的用例；可用参数只运行名称包含该子串的用例
+int main(int argc, char* argv[]) {
+    std::string only = argc > 1 ? argv[1] : "";
+    int run = 0;
+    int failed_cases = 0;
+    for (const auto& test : test_registry()) {
+        if (!only.empty() && std::string(test.name).find(only) == std::string::npos) {
+            continue;
+        }
+        int before = test_failures();
+        test.run();
+        run++;
+        if (test_failures() != before) {
+            failed_cases++;
+            std::cout << "❌ " << test.name << "\n";
+        } else {
+            std::cout << "✅ " << test.name << "\n";
+        }
+    }
+    for (const auto& path : temp_files()) {
+        unlink(path.c_str());
+    }
+
+    std::cout << "\n" << run << " 个用例，" << failed_cases << " 个失败\n";
+    return failed_cases == 0 ? 0 : 1;
+}
@@ -0,0 +1,49 @@
+#pragma once
+
+#include <iostream>
+#include <sstream>
+#include <string>
+#include <vector>
+
+// test_unified_monitor使用的极简测试框架：TEST_CASE注册用例，CHECK/CHECK_EQ失败时记录位置并继续执行
+
+struct TestCase {
+    const char* name;
+    void (*run)();
+};
+
+std::vector<TestCase>& test_registry();
+int& test_failures();
+
+struct TestRegistrar {
+    TestRegistrar(const char* name, void (*run)()) { test_registry().push_back({name, run}); }
+};
+
+// 把NDJSON行写入临时文件并返回路径，进程退出前由test_unified_monitor删除
+std::string write_temp_file(const std::vector<std::string>& lines);
+
+#define TEST_CASE(name)                                           \
+    static void name();                                           \
+    static TestRegistrar name##_registrar(#name, name);           \
+    static void name()
+
+#define CHECK(expr)                                                                               \
+    do {                                                                                          \
+        if (!(expr)) {                                                                            \
+            test_failures()++;                                                                    \
+            std::cerr << "❌ " << __FILE__ << ":" << __LINE__ << ": CHECK(" #expr ") 失败\n";      \
+        }                                                                                         \
+    } while (0)
+
+#define CHECK_EQ(actual, expected)                                                                \
+    do {                                                                                          \
+        auto actual_value = (actual);                                                             \
+        auto expected_value = (expected);                                                         \
+        if (!(actual_value == expected_value)) {                                                  \
+            test_failures()++;                                                                    \
+            std::ostringstream message;                                                           \
+            message << "❌ " << __FILE__ << ":" << __LINE__ << ": " #actual " = " << actual_value \
+                    << "，期望 " << expected_value << "\n";                                       \
+            std::cerr << message.str();                                                           \
+        }                                                                                         \
+    } while (0)
@@ -0,0 +1,129 @@
+#include "test_util.h"
+#include "yaml_lite.h"
+
+TEST_CASE(yaml_block_maps_and_sequences) {
+    const char* text =
+        "# 故障计划\n"
+        "name: leaf-failover   # 行尾注释\n"
+        "repeat: 3\n"
+        "steps:\n"
+        "  - name: down\n"
+        "    action: link_down\n"
+        "    targets:\n"
+        "    - leaf1:e1-1\n"
+        "    - leaf2:e1-1\n"
+        "  - name: up\n"
+        "    action: link_up\n"
+        "empty:\n";
+    YamlNode root;
+    std::string error;
+    CHECK(YamlParser::parse(text, root, error));
+    CHECK(root.is_map());
+    CHECK_EQ(root.get_string("name"), std::string("leaf-failover"));
+    CHECK_EQ(root.get_string("repeat"), std::string("3"));
+    CHECK_EQ(root.get_string("missing", "default"), std::string("default"));
+    CHECK(root.get("empty") != nullptr && root.get("empty")->is_nil());
+
+    const YamlNode* steps = root.get("steps");
+    CHECK(steps != nullptr && steps->is_sequence());
+    if (steps != nullptr && steps->items.size() == 2) {
+        const YamlNode& down = steps->items[0];
+        CHECK_EQ(down.get_string("action"), std::string("link_down"));
+        const YamlNode* targets = down.get("targets");
+        CHECK(targets != nullptr && targets->is_sequence() && targets->items.size() == 2);
+        if (targets != nullptr && targets->items.size() == 2) {
+            CHECK_EQ(targets->items[1].scalar, std::string("leaf2:e1-1"));
+        }
+        CHECK_EQ(steps->items[1].get_string("name"), std::string("up"));
+    } else {
+        CHECK(false);
+    }
+
+    // 映射保持原始顺序
+    CHECK_EQ(root.entries.size(), 4u);
+    if (root.entries.size() == 4) {
+        CHECK_EQ(root.entries[2].first, std::string("steps"));
+    }
+}
+
+TEST_CASE(yaml_flow_collections_and_quotes) {
+    const char* text =
+        "ports: [80, \"8080\", '9,9']\n"
+        "netem: {delay: 100ms, loss: \"1%\"}\n"
+        "nested: [{a: 1}, [x, y], []]\n"
+        "message: \"tab\\there # not a comment\"\n"
+        "single: 'it''s'\n";
+    YamlNode root;
+    std::string error;
+    CHECK(YamlParser::parse(text, root, error));
+
+    const YamlNode* ports = root.get("ports");
+    CHECK(ports != nullptr && ports->is_sequence() && ports->items.size() == 3);
+    if (ports != nullptr && ports->items.size() == 3) {
+        CHECK_EQ(ports->items[1].scalar, std::string("8080"));
+        CHECK_EQ(ports->items[2].scalar, std::string("9,9"));
+    }
+    const YamlNode* netem = root.get("netem");
+    CHECK(netem != nullptr && netem->is_map());
+    if (netem != nullptr) {
+        CHECK_EQ(netem->get_string("delay"), std::string("100ms"));
+        CHECK_EQ(netem->get_string("loss"), std::string("1%"));
+    }
+    const YamlNode* nested = root.get("nested");
+    CHECK(nested != nullptr && nested->items.size() == 3);
+    if (nested != nullptr && nested->items.size() == 3) {
+        CHECK_EQ(nested->items[0].get_string("a"), std::string("1"));
+        CHECK(nested->items[1].is_sequence() && nested->items[1].items.size() == 2);
+        CHECK(nested->items[2].is_sequence() && nested->items[2].items.empty());
+    }
+    CHECK_EQ(root.get_string("message"), std::string("tab\there # not a comment"));
+    CHECK_EQ(root.get_string("single"), std::string("it's"));
+}
+
+TEST_CASE(yaml_block_scalars) {
+    const char* text =
+        "script: |\n"
+        "  ip link set e1 down\n"
+        "    sleep 1\n"
+        "folded: >\n"
+        "  first\n"
+        "  second\n"
+        "after: done\n";
+    YamlNode root;
+    std::string error;
+    CHECK(YamlParser::parse(text, root, error));
+    CHECK_EQ(root.get_string("script"), std::string("ip link set e1 down\n  sleep 1\n"));
+    CHECK_EQ(root.get_string("folded"), std::string("first second"));
+    CHECK_EQ(root.get_string("after"), std::string("done"));
+}
+
+TEST_CASE(yaml_sequence_at_map_indent_and_empty_document) {
+    YamlNode root;
+    std::string error;
+    CHECK(YamlParser::parse("nodes:\n- r1\n- r2\nkind: linux\n", root, error));
+    const YamlNode* nodes = root.get("nodes");
+    CHECK(nodes != nullptr && nodes->is_sequence() && nodes->items.size() == 2);
+    CHECK_EQ(root.get_string("kind"), std::string("linux"));
+
+    CHECK(YamlParser::parse("# only comments\n---\n", root, error));
+    CHECK(root.is_nil());
+}
+
+TEST_CASE(yaml_reports_errors_with_line_numbers) {
+    YamlNode root;
+    std::string error;
+    CHECK(!YamlParser::parse("a: 1\n\tb: 2\n", root, error));
+    CHECK_EQ(error, std::string("line 2: tabs are not allowed for indentation"));
+
+    CHECK(!YamlParser::parse("a: 1\njust text\n", root, error));
+    CHECK_EQ(error, std::string("line 2: expected 'key: value'"));
+
+    CHECK(!YamlParser::parse("a: [1, 2\n", root, error));
+    CHECK_EQ(error, std::string("line 1: invalid flow collection"));
+
+    CHECK(!YamlParser::parse("a: 1\n  b: 2\n", root, error));
+    CHECK_EQ(error, std::string("line 2: unexpected indentation"));
+
+    CHECK(!YamlParser::parse("a: 1\n- b\n", root, error));
+    CHECK_EQ(error, std::string("line 2: unexpected sequence entry"));
+}
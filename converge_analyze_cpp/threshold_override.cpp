@@ -0,0 +1,84 @@
+#include "threshold_override.h"
+#include <fnmatch.h>
+#include <sstream>
+
+bool ThresholdOverrides::parse(const std::string& spec, std::string& error) {
+    std::vector<ThresholdRule> parsed;
+    std::istringstream iss(spec);
+    std::string item;
+    while (std::getline(iss, item, ',')) {
+        size_t start = item.find_first_not_of(" \t");
+        size_t end = item.find_last_not_of(" \t");
+        if (start == std::string::npos) {
+            continue;
+        }
+        item = item.substr(start, end - start + 1);
+
+        size_t eq = item.rfind('=');
+        if (eq == std::string::npos || eq == 0 || eq + 1 == item.size()) {
+            error = "expected PATTERN=MS: " + item;
+            return false;
+        }
+        ThresholdRule rule;
+        rule.pattern = item.substr(0, eq);
+        if (rule.pattern.rfind("type:", 0) == 0) {
+            rule.by_type = true;
+            rule.pattern = rule.pattern.substr(5);
+            if (rule.pattern.empty()) {
+                error = "empty trigger type: " + item;
+                return false;
+            }
+        }
+        std::string value = item.substr(eq + 1);
+        try {
+            size_t pos = 0;
+            rule.threshold_ms = std::stoll(value, &pos);
+            if (pos != value.size() || rule.threshold_ms <= 0) {
+                throw std::invalid_argument(value);
+            }
+        } catch (...) {
+            error = "invalid threshold: " + item;
+            return false;
+        }
+        parsed.push_back(rule);
+    }
+    if (parsed.empty()) {
+        error = "no rules";
+        return false;
+    }
+    rules.insert(rules.end(), parsed.begin(), parsed.end());
+    return true;
+}
+
+std::optional<int64_t> ThresholdOverrides::match(const std::string& interface, const std::string& trigger_source,
+                                                 const std::string& trigger_event_type, std::string& rule) const {
+    for (const auto& r : rules) {
+        bool hit;
+        if (r.by_type) {
+            hit = fnmatch(r.pattern.c_str(), trigger_source.c_str(), 0) == 0 ||
+                  (!trigger_event_type.empty() && fnmatch(r.pattern.c_str(), trigger_event_type.c_str(), 0) == 0);
+        } else {
+            hit = !interface.empty() && fnmatch(r.pattern.c_str(), interface.c_str(), 0) == 0;
+        }
+        if (hit) {
+            rule = rule_text(r);
+            return r.threshold_ms;
+        }
+    }
+    return std::nullopt;
+}
+
+std::string ThresholdOverrides::rule_text(const ThresholdRule& rule) {
+    return (rule.by_type ? "type:" : "") + rule.pattern + "=" + std::to_string(rule.threshold_ms);
+}
+
+std::string ThresholdOverrides::text() const {
+    std::string result;
+    for (const auto& rule : rules) {
+        if (!result.empty()) {
+            result += ",";
+        }
+        result += rule_text(rule);
+    }
+    return result;
+}
@@ -0,0 +1,31 @@
+#pragma once
+
+#include <cstdint>
+#include <optional>
+#include <string>
+#include <vector>
+
+// 按接口或触发类型覆盖收敛阈值(--threshold-override)，如 "eth0=1000,eth*=3000,type:qdisc=5000"。
+// 规则按出现顺序匹配，第一条命中的生效；都不命中时使用全局--threshold。
+struct ThresholdRule {
+    std::string pattern;    // 接口名或触发类型，支持 * ? [] 通配
+    bool by_type = false;   // type:前缀，按触发源(route/qdisc/multipath...)或事件类型匹配
+    int64_t threshold_ms = 0;
+};
+
+class ThresholdOverrides {
+public:
+    std::vector<ThresholdRule> rules;
+
+    bool empty() const { return rules.empty(); }
+
+    // 逗号分隔的 PATTERN=MS 或 type:TYPE=MS，追加到现有规则之后
+    bool parse(const std::string& spec, std::string& error);
+
+    // 返回命中规则的阈值，rule返回该规则的文本
+    std::optional<int64_t> match(const std::string& interface, const std::string& trigger_source,
+                                 const std::string& trigger_event_type, std::string& rule) const;
+
+    static std::string rule_text(const ThresholdRule& rule);
+    std::string text() const;
+};
@@ -0,0 +1,156 @@
+#include "timestamp_format.h"
+#include <chrono>
+#include <cstdio>
+#include <cstdlib>
+#include <ctime>
+#include <sys/stat.h>
+
+namespace {
+
+enum class ZoneMode { UTC, LOCAL, FIXED };
+
+TimestampFormat current_format = TimestampFormat::RFC3339;
+ZoneMode zone_mode = ZoneMode::UTC;
+int fixed_offset_seconds = 0;
+bool configured = false;
+
+std::string format_offset(long offset_seconds) {
+    char sign = offset_seconds < 0 ? '-' : '+';
+    long value = offset_seconds < 0 ? -offset_seconds : offset_seconds;
+    char buffer[32];
+    snprintf(buffer, sizeof(buffer), "%c%02ld:%02ld", sign, value / 3600, (value % 3600) / 60);
+    return buffer;
+}
+
+// 按时区展开为日历时间，suffix返回 Z 或 ±HH:MM
+void split_time(time_t seconds, struct tm& out, std::string& suffix) {
+    switch (zone_mode) {
+        case ZoneMode::UTC:
+            gmtime_r(&seconds, &out);
+            suffix = "Z";
+            break;
+        case ZoneMode::LOCAL:
+            localtime_r(&seconds, &out);
+            suffix = format_offset(out.tm_gmtoff);
+            break;
+        case ZoneMode::FIXED: {
+            time_t shifted = seconds + fixed_offset_seconds;
+            gmtime_r(&shifted, &out);
+            suffix = fixed_offset_seconds == 0 ? "Z" : format_offset(fixed_offset_seconds);
+            break;
+        }
+    }
+}
+
+int64_t floor_div(int64_t value, int64_t divisor) {
+    int64_t quotient = value / divisor;
+    return (value % divisor < 0) ? quotient - 1 : quotient;
+}
+
+} // namespace
+
+bool parse_timestamp_format(const std::string& text, TimestampFormat& format) {
+    if (text == "rfc3339" || text == "RFC3339") {
+        format = TimestampFormat::RFC3339;
+    } else if (text == "rfc3339nano" || text == "RFC3339Nano") {
+        format = TimestampFormat::RFC3339_NANO;
+    } else if (text == "epoch-ms") {
+        format = TimestampFormat::EPOCH_MS;
+    } else if (text == "epoch-ns") {
+        format = TimestampFormat::EPOCH_NS;
+    } else {
+        return false;
+    }
+    return true;
+}
+
+void set_timestamp_format(TimestampFormat format) {
+    current_format = format;
+    configured = true;
+}
+
+bool set_timestamp_timezone(const std::string& text, std::string& error) {
+    if (text == "UTC" || text == "utc" || text == "Z") {
+        zone_mode = ZoneMode::UTC;
+    } else if (text == "local") {
+        zone_mode = ZoneMode::LOCAL;
+        tzset();
+    } else if (!text.empty() && (text[0] == '+' || text[0] == '-')) {
+        int hours = 0;
+        int minutes = 0;
+        char extra = 0;
+        if (sscanf(text.c_str() + 1, "%2d:%2d%c", &hours, &minutes, &extra) != 2 ||
+            hours > 23 || minutes > 59) {
+            error = "invalid offset " + text + " (expected ±HH:MM)";
+            return false;
+        }
+        int offset = hours * 3600 + minutes * 60;
+        zone_mode = ZoneMode::FIXED;
+        fixed_offset_seconds = text[0] == '-' ? -offset : offset;
+    } else {
+        // IANA时区名，交给libc按TZ解析；提前检查时区文件，避免拼写错误时静默退化为UTC
+        struct stat st;
+        std::string path = "/usr/share/zoneinfo/" + text;
+        if (text.find("..") != std::string::npos || stat(path.c_str(), &st) != 0 || !S_ISREG(st.st_mode)) {
+            error = "unknown time zone " + text;
+            return false;
+        }
+        setenv("TZ", text.c_str(), 1);
+        tzset();
+        zone_mode = ZoneMode::LOCAL;
+    }
+    configured = true;
+    return true;
+}
+
+int64_t timestamp_now_ns() {
+    return std::chrono::duration_cast<std::chrono::nanoseconds>(
+        std::chrono::system_clock::now().time_since_epoch()).count();
+}
+
+std::string format_timestamp_ns(int64_t timestamp_ns) {
+    if (current_format == TimestampFormat::EPOCH_MS) {
+        return std::to_string(floor_div(timestamp_ns, 1000000));
+    }
+    if (current_format == TimestampFormat::EPOCH_NS) {
+        return std::to_string(timestamp_ns);
+    }
+
+    int64_t seconds = floor_div(timestamp_ns, 1000000000);
+    int64_t fraction_ns = timestamp_ns - seconds * 1000000000;
+    struct tm tm_value = {};
+    std::string suffix;
+    split_time(static_cast<time_t>(seconds), tm_value, suffix);
+
+    char buffer[64];
+    size_t length = strftime(buffer, sizeof(buffer), "%Y-%m-%dT%H:%M:%S", &tm_value);
+    if (current_format == TimestampFormat::RFC3339_NANO) {
+        snprintf(buffer + length, sizeof(buffer) - length, ".%09lld", static_cast<long long>(fraction_ns));
+    } else {
+        snprintf(buffer + length, sizeof(buffer) - length, ".%03lld",
+                 static_cast<long long>(fraction_ns / 1000000));
+    }
+    return std::string(buffer) + suffix;
+}
+
+JsonValue timestamp_json_value(int64_t timestamp_ns) {
+    if (current_format == TimestampFormat::EPOCH_MS) {
+        return JsonValue(floor_div(timestamp_ns, 1000000));
+    }
+    if (current_format == TimestampFormat::EPOCH_NS) {
+        return JsonValue(timestamp_ns);
+    }
+    return JsonValue(format_timestamp_ns(timestamp_ns));
+}
+
+std::string console_timestamp(int64_t timestamp_ns) {
+    if (configured) {
+        return format_timestamp_ns(timestamp_ns);
+    }
+    time_t seconds = static_cast<time_t>(floor_div(timestamp_ns, 1000000000));
+    struct tm tm_value = {};
+    localtime_r(&seconds, &tm_value);
+    char buffer[32];
+    strftime(buffer, sizeof(buffer), "%Y-%m-%d %H:%M:%S", &tm_value);
+    return buffer;
+}
@@ -0,0 +1,32 @@
+#pragma once
+
+#include <cstdint>
+#include <string>
+#include "logger.h"
+
+// 时间戳格式(--timestamp-format)与时区(--timezone)，同时作用于控制台和JSON记录。
+// 须在启动任何线程之前设置。
+enum class TimestampFormat {
+    RFC3339,        // 2024-08-04T10:30:15.123Z (默认，毫秒精度)
+    RFC3339_NANO,   // 2024-08-04T10:30:15.123456789Z
+    EPOCH_MS,       // 1722767415123 (JSON中为整数)
+    EPOCH_NS,       // 1722767415123456789
+};
+
+// 解析 rfc3339、rfc3339nano、epoch-ms、epoch-ns
+bool parse_timestamp_format(const std::string& text, TimestampFormat& format);
+void set_timestamp_format(TimestampFormat format);
+
+// 解析 UTC、local、±HH:MM 或 IANA时区名(如 Asia/Shanghai)，失败时error返回原因
+bool set_timestamp_timezone(const std::string& text, std::string& error);
+
+int64_t timestamp_now_ns();
+
+// 按当前格式和时区格式化，纪元格式返回十进制数字
+std::string format_timestamp_ns(int64_t timestamp_ns);
+
+// JSON记录中的时间戳值，纪元格式为整数，其余为字符串
+JsonValue timestamp_json_value(int64_t timestamp_ns);
+
+// 控制台显示；未显式设置时保持本地时间 "YYYY-MM-DD HH:MM:SS"
+std::string console_timestamp(int64_t timestamp_ns);
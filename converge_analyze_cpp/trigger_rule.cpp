@@ -0,0 +1,444 @@
+#include "trigger_rule.h"
+#include <cctype>
+#include <cstdlib>
+#include <regex>
+
+const std::vector<std::string> TriggerRules::DEFAULT_TRIGGERS = {
+    // 删除事件本身不带kind，was_netem取自同一handle最近的qdisc缓存
+    "qdisc.is_netem || qdisc.replaced_kind == \"netem\" || qdisc.was_netem",
+    "route.event =~ \"^(route_(add|del|replace)|rule_(add|del)|mroute_(add|del)|fdb_(add|del|move)|tunnel_.*|"
+    "lag_failover|lag_member_change|lacp_state_change)$\"",
+};
+
+std::string RuleEvent::lookup(const std::string& name) const {
+    std::string key = name;
+    size_t dot = name.find('.');
+    if (dot != std::string::npos) {
+        std::string prefix = name.substr(0, dot);
+        if (prefix == "qdisc" || prefix == "route") {
+            if (prefix != category) {
+                return "";
+            }
+            key = name.substr(dot + 1);
+        }
+    }
+    if (key == "event") {
+        return type;
+    }
+    if (key == "iface") {
+        key = "interface";
+    }
+    auto extra_it = extra.find(key);
+    if (extra_it != extra.end()) {
+        return extra_it->second;
+    }
+    if (info) {
+        auto it = info->find(key);
+        if (it != info->end()) {
+            return it->second;
+        }
+    }
+    return "";
+}
+
+struct TriggerExpression::Node {
+    enum Kind { LITERAL, FIELD, NOT, AND, OR, COMPARE, MATCH };
+
+    Kind kind = LITERAL;
+    std::string value;  // LITERAL的值、FIELD的名称或COMPARE/MATCH的运算符
+    std::shared_ptr<const Node> left;
+    std::shared_ptr<const Node> right;
+    std::regex pattern;
+};
+
+namespace {
+
+using Node = TriggerExpression::Node;
+using NodePtr = std::shared_ptr<const Node>;
+
+struct Token {
+    enum Kind { END, IDENT, STRING, NUMBER, OP, LPAREN, RPAREN };
+    Kind kind = END;
+    std::string text;
+    size_t position = 0;
+};
+
+bool tokenize(const std::string& text, std::vector<Token>& tokens, std::string& error) {
+    size_t i = 0;
+    while (i < text.size()) {
+        char c = text[i];
+        if (std::isspace(static_cast<unsigned char>(c))) {
+            i++;
+            continue;
+        }
+        Token token;
+        token.position = i;
+        if (c == '(' || c == ')') {
+            token.kind = c == '(' ? Token::LPAREN : Token::RPAREN;
+            token.text = std::string(1, c);
+            i++;
+        } else if (c == '"' || c == '\'') {
+            token.kind = Token::STRING;
+            size_t j = i + 1;
+            while (j < text.size() && text[j] != c) {
+                if (text[j] == '\\' && j + 1 < text.size()) {
+                    // 只处理\"与\\，其余转义原样保留给正则
+                    if (text[j + 1] == c || text[j + 1] == '\\') {
+                        token.text += text[j + 1];
+                        j += 2;
+                        continue;
+                    }
+                }
+                token.text += text[j++];
+            }
+            if (j >= text.size()) {
+                error = "unterminated string at " + std::to_string(i);
+                return false;
+            }
+            i = j + 1;
+        } else if (std::isdigit(static_cast<unsigned char>(c)) ||
+                   (c == '-' && i + 1 < text.size() && std::isdigit(static_cast<unsigned char>(text[i + 1])))) {
+            token.kind = Token::NUMBER;
+            size_t j = i + 1;
+            while (j < text.size() && (std::isdigit(static_cast<unsigned char>(text[j])) || text[j] == '.')) {
+                j++;
+            }
+            token.text = text.substr(i, j - i);
+            i = j;
+        } else if (std::isalpha(static_cast<unsigned char>(c)) || c == '_') {
+            token.kind = Token::IDENT;
+            size_t j = i + 1;
+            while (j < text.size() &&
+                   (std::isalnum(static_cast<unsigned char>(text[j])) || text[j] == '_' || text[j] == '.' ||
+                    text[j] == '-')) {
+                j++;
+            }
+            token.text = text.substr(i, j - i);
+            i = j;
+        } else {
+            static const char* const OPERATORS[] = {"==", "!=", "=~", "!~", "<=", ">=", "&&", "||", "<", ">", "!"};
+            for (const char* op : OPERATORS) {
+                if (text.compare(i, std::char_traits<char>::length(op), op) == 0) {
+                    token.kind = Token::OP;
+                    token.text = op;
+                    break;
+                }
+            }
+            if (token.kind != Token::OP) {
+                error = "unexpected '" + std::string(1, c) + "' at " + std::to_string(i);
+                return false;
+            }
+            i += token.text.size();
+        }
+        tokens.push_back(token);
+    }
+    Token end;
+    end.position = text.size();
+    tokens.push_back(end);
+    return true;
+}
+
+class Parser {
+public:
+    explicit Parser(const std::vector<Token>& tokens) : tokens_(tokens) {}
+
+    NodePtr parse(std::string& error) {
+        NodePtr node = parse_or(error);
+        if (node && peek().kind != Token::END) {
+            error = "unexpected '" + peek().text + "' at " + std::to_string(peek().position);
+            return nullptr;
+        }
+        return node;
+    }
+
+private:
+    const std::vector<Token>& tokens_;
+    size_t pos_ = 0;
+
+    const Token& peek() const { return tokens_[pos_]; }
+    bool accept_op(const char* op) {
+        if (peek().kind == Token::OP && peek().text == op) {
+            pos_++;
+            return true;
+        }
+        return false;
+    }
+
+    static NodePtr binary(Node::Kind kind, const std::string& op, NodePtr left, NodePtr right) {
+        auto node = std::make_shared<Node>();
+        node->kind = kind;
+        node->value = op;
+        node->left = std::move(left);
+        node->right = std::move(right);
+        return node;
+    }
+
+    NodePtr parse_or(std::string& error) {
+        NodePtr left = parse_and(error);
+        while (left && accept_op("||")) {
+            NodePtr right = parse_and(error);
+            if (!right) {
+                return nullptr;
+            }
+            left = binary(Node::OR, "||", left, right);
+        }
+        return left;
+    }
+
+    NodePtr parse_and(std::string& error) {
+        NodePtr left = parse_unary(error);
+        while (left && accept_op("&&")) {
+            NodePtr right = parse_unary(error);
+            if (!right) {
+                return nullptr;
+            }
+            left = binary(Node::AND, "&&", left, right);
+        }
+        return left;
+    }
+
+    NodePtr parse_unary(std::string& error) {
+        if (accept_op("!")) {
+            NodePtr operand = parse_unary(error);
+            if (!operand) {
+                return nullptr;
+            }
+            return binary(Node::NOT, "!", operand, nullptr);
+        }
+        return parse_comparison(error);
+    }
+
+    NodePtr parse_comparison(std::string& error) {
+        NodePtr left = parse_operand(error);
+        if (!left || peek().kind != Token::OP) {
+            return left;
+        }
+        std::string op = peek().text;
+        if (op == "=~" || op == "!~") {
+            pos_++;
+            if (peek().kind != Token::STRING) {
+                error = op + " expects a string pattern at " + std::to_string(peek().position);
+                return nullptr;
+            }
+            auto node = std::make_shared<Node>();
+            node->kind = Node::MATCH;
+            node->value = op;
+            node->left = left;
+            try {
+                node->pattern = std::regex(peek().text, std::regex::extended);
+            } catch (const std::regex_error& e) {
+                error = "invalid pattern \"" + peek().text + "\": " + e.what();
+                return nullptr;
+            }
+            pos_++;
+            return node;
+        }
+        if (op == "==" || op == "!=" || op == "<" || op == "<=" || op == ">" || op == ">=") {
+            pos_++;
+            NodePtr right = parse_operand(error);
+            if (!right) {
+                return nullptr;
+            }
+            return binary(Node::COMPARE, op, left, right);
+        }
+        return left;
+    }
+
+    NodePtr parse_operand(std::string& error) {
+        const Token& token = peek();
+        if (token.kind == Token::LPAREN) {
+            pos_++;
+            NodePtr inner = parse_or(error);
+            if (!inner) {
+                return nullptr;
+            }
+            if (peek().kind != Token::RPAREN) {
+                error = "missing ')' at " + std::to_string(peek().position);
+                return nullptr;
+            }
+            pos_++;
+            return inner;
+        }
+        auto node = std::make_shared<Node>();
+        if (token.kind == Token::STRING || token.kind == Token::NUMBER) {
+            node->kind = Node::LITERAL;
+            node->value = token.text;
+        } else if (token.kind == Token::IDENT && (token.text == "true" || token.text == "false")) {
+            node->kind = Node::LITERAL;
+            node->value = token.text;
+        } else if (token.kind == Token::IDENT) {
+            node->kind = Node::FIELD;
+            node->value = token.text;
+        } else {
+            error = token.kind == Token::END ? "unexpected end of expression"
+                                             : "unexpected '" + token.text + "' at " + std::to_string(token.position);
+            return nullptr;
+        }
+        pos_++;
+        return node;
+    }
+};
+
+bool parse_number(const std::string& text, double& value) {
+    if (text.empty()) {
+        return false;
+    }
+    char* end = nullptr;
+    value = std::strtod(text.c_str(), &end);
+    return *end == '\0';
+}
+
+std::string operand_value(const Node& node, const RuleEvent& event) {
+    if (node.kind == Node::LITERAL) {
+        return node.value;
+    }
+    if (node.kind == Node::FIELD) {
+        return event.lookup(node.value);
+    }
+    return "";
+}
+
+bool truthy(const std::string& value) {
+    return !value.empty() && value != "false" && value != "0";
+}
+
+bool evaluate_node(const Node& node, const RuleEvent& event) {
+    switch (node.kind) {
+        case Node::LITERAL:
+        case Node::FIELD:
+            return truthy(operand_value(node, event));
+        case Node::NOT:
+            return !evaluate_node(*node.left, event);
+        case Node::AND:
+            return evaluate_node(*node.left, event) && evaluate_node(*node.right, event);
+        case Node::OR:
+            return evaluate_node(*node.left, event) || evaluate_node(*node.right, event);
+        case Node::MATCH: {
+            bool matched = std::regex_search(operand_value(*node.left, event), node.pattern);
+            return node.value == "=~" ? matched : !matched;
+        }
+        case Node::COMPARE: {
+            // 括号中的子表达式作为操作数时取其真值
+            auto value_of = [&event](const Node& operand) {
+                if (operand.kind == Node::LITERAL || operand.kind == Node::FIELD) {
+                    return operand_value(operand, event);
+                }
+                return std::string(evaluate_node(operand, event) ? "true" : "false");
+            };
+            std::string left = value_of(*node.left);
+            std::string right = value_of(*node.right);
+            double left_number = 0, right_number = 0;
+            bool numeric = parse_number(left, left_number) && parse_number(right, right_number);
+            const std::string& op = node.value;
+            if (op == "==") {
+                return numeric ? left_number == right_number : left == right;
+            }
+            if (op == "!=") {
+                return numeric ? left_number != right_number : left != right;
+            }
+            if (!numeric) {
+                return false;
+            }
+            if (op == "<") return left_number < right_number;
+            if (op == "<=") return left_number <= right_number;
+            if (op == ">") return left_number > right_number;
+            return left_number >= right_number;
+        }
+    }
+    return false;
+}
+
+std::string join_rules(const std::vector<std::shared_ptr<TriggerExpression>>& rules) {
+    std::string text;
+    for (const auto& rule : rules) {
+        text += (text.empty() ? "" : " ; ") + rule->text();
+    }
+    return text;
+}
+
+const std::vector<std::shared_ptr<TriggerExpression>>& default_expressions() {
+    static const std::vector<std::shared_ptr<TriggerExpression>> defaults = [] {
+        std::vector<std::shared_ptr<TriggerExpression>> parsed;
+        for (const auto& text : TriggerRules::DEFAULT_TRIGGERS) {
+            std::string error;
+            parsed.push_back(TriggerExpression::parse(text, error));
+        }
+        return parsed;
+    }();
+    return defaults;
+}
+
+} // namespace
+
+std::unique_ptr<TriggerExpression> TriggerExpression::parse(const std::string& text, std::string& error) {
+    std::vector<Token> tokens;
+    if (!tokenize(text, tokens, error)) {
+        return nullptr;
+    }
+    Parser parser(tokens);
+    NodePtr root = parser.parse(error);
+    if (!root) {
+        return nullptr;
+    }
+    auto expression = std::make_unique<TriggerExpression>();
+    expression->root_ = root;
+    expression->text_ = text;
+    return expression;
+}
+
+bool TriggerExpression::evaluate(const RuleEvent& event) const {
+    return root_ && evaluate_node(*root_, event);
+}
+
+bool TriggerRules::add_trigger(const std::string& text, std::string& error) {
+    if (text == "default") {
+        const auto& defaults = default_expressions();
+        triggers_.insert(triggers_.end(), defaults.begin(), defaults.end());
+        return true;
+    }
+    auto expression = TriggerExpression::parse(text, error);
+    if (!expression) {
+        return false;
+    }
+    triggers_.push_back(std::move(expression));
+    return true;
+}
+
+bool TriggerRules::add_ignore(const std::string& text, std::string& error) {
+    auto expression = TriggerExpression::parse(text, error);
+    if (!expression) {
+        return false;
+    }
+    ignores_.push_back(std::move(expression));
+    return true;
+}
+
+const std::vector<std::shared_ptr<TriggerExpression>>& TriggerRules::effective_triggers() const {
+    return triggers_.empty() ? default_expressions() : triggers_;
+}
+
+bool TriggerRules::is_trigger(const RuleEvent& event) const {
+    for (const auto& rule : effective_triggers()) {
+        if (rule->evaluate(event)) {
+            return true;
+        }
+    }
+    return false;
+}
+
+bool TriggerRules::is_ignored(const RuleEvent& event) const {
+    for (const auto& rule : ignores_) {
+        if (rule->evaluate(event)) {
+            return true;
+        }
+    }
+    return false;
+}
+
+std::string TriggerRules::triggers_text() const {
+    return join_rules(effective_triggers());
+}
+
+std::string TriggerRules::ignores_text() const {
+    return join_rules(ignores_);
+}
@@ -0,0 +1,62 @@
+#pragma once
+
+#include <memory>
+#include <string>
+#include <unordered_map>
+#include <vector>
+
+// 规则求值时看到的一个事件
+struct RuleEvent {
+    std::string category;  // qdisc 或 route(含rule/mroute/fdb/tunnel/lag事件)
+    std::string type;      // 小写的事件类型，如 qdisc_del、route_add、fdb_move
+    const std::unordered_map<std::string, std::string>* info = nullptr;  // 解析后的事件字段
+    std::unordered_map<std::string, std::string> extra;                  // 监控核心补充的字段，如 was_netem
+
+    // 取字段值，不存在时返回空字符串。event为事件类型，iface为interface的别名；
+    // "qdisc.kind"这类带类别前缀的名称只在事件属于该类别时有值
+    std::string lookup(const std::string& name) const;
+};
+
+// 事件字段上的布尔表达式，如 qdisc.kind == "netem" && iface =~ "eth[1-4]"
+//   比较: == != (两侧都是数字时按数值比较) < <= > >= (仅数值)
+//   正则: =~ !~ (右侧为字符串字面量，部分匹配)
+//   逻辑: && || ! 与括号；单独的字段为真当且仅当非空且不是"false"/"0"
+class TriggerExpression {
+public:
+    struct Node;
+
+    static std::unique_ptr<TriggerExpression> parse(const std::string& text, std::string& error);
+
+    bool evaluate(const RuleEvent& event) const;
+    const std::string& text() const { return text_; }
+
+private:
+    std::shared_ptr<const Node> root_;
+    std::string text_;
+};
+
+// 触发与忽略规则(--trigger-rule/--ignore-rule)。
+// 命中忽略规则的事件既不触发会话也不计入会话；未指定触发规则时使用DEFAULT_TRIGGERS，
+// 即原有的netem qdisc变化与路由/规则/组播/FDB/隧道/链路聚合事件
+class TriggerRules {
+public:
+    static const std::vector<std::string> DEFAULT_TRIGGERS;
+
+    // "default"展开为DEFAULT_TRIGGERS，便于在默认规则之外追加
+    bool add_trigger(const std::string& text, std::string& error);
+    bool add_ignore(const std::string& text, std::string& error);
+
+    bool custom() const { return !triggers_.empty() || !ignores_.empty(); }
+    bool is_trigger(const RuleEvent& event) const;
+    bool is_ignored(const RuleEvent& event) const;
+
+    // 生效的规则文本，以" ; "分隔
+    std::string triggers_text() const;
+    std::string ignores_text() const;
+
+private:
+    std::vector<std::shared_ptr<TriggerExpression>> triggers_;
+    std::vector<std::shared_ptr<TriggerExpression>> ignores_;
+
+    const std::vector<std::shared_ptr<TriggerExpression>>& effective_triggers() const;
+};
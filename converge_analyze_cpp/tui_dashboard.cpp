@@ -0,0 +1,409 @@
+#include "tui_dashboard.h"
+#include "i18n.h"
+#include "log_reader.h"
+#include <algorithm>
+#include <cerrno>
+#include <chrono>
+#include <cstdio>
+#include <cstring>
+#include <ctime>
+#include <fcntl.h>
+#include <iostream>
+#include <poll.h>
+#include <sys/ioctl.h>
+#include <unistd.h>
+#include <vector>
+
+namespace {
+
+// 备用屏幕、隐藏光标、关闭自动换行；退出时按相反顺序恢复
+const std::string ENTER_SCREEN = "\x1b[?1049h\x1b[?25l\x1b[?7l";
+const std::string LEAVE_SCREEN = "\x1b[?7h\x1b[?25h\x1b[?1049l";
+
+const std::string RESET = "\x1b[0m";
+const std::string BOLD = "\x1b[1m";
+const std::string REVERSE = "\x1b[7m";
+const std::string DIM = "\x1b[2m";
+const std::string RED = "\x1b[31m";
+const std::string GREEN = "\x1b[32m";
+const std::string YELLOW = "\x1b[33m";
+const std::string CYAN = "\x1b[36m";
+
+int64_t now_ms() {
+    return std::chrono::duration_cast<std::chrono::milliseconds>(
+        std::chrono::system_clock::now().time_since_epoch()).count();
+}
+
+// 终端显示宽度：CJK与emoji占两列，变体选择符不占列
+size_t display_width(const std::string& text) {
+    size_t width = 0;
+    for (size_t i = 0; i < text.size();) {
+        unsigned char c = static_cast<unsigned char>(text[i]);
+        uint32_t cp = c;
+        size_t length = 1;
+        if (c >= 0xf0) {
+            cp = c & 0x07;
+            length = 4;
+        } else if (c >= 0xe0) {
+            cp = c & 0x0f;
+            length = 3;
+        } else if (c >= 0xc0) {
+            cp = c & 0x1f;
+            length = 2;
+        }
+        for (size_t k = 1; k < length && i + k < text.size(); ++k) {
+            cp = (cp << 6) | (static_cast<unsigned char>(text[i + k]) & 0x3f);
+        }
+        i += length;
+
+        if (cp == 0xfe0f || cp == 0x200d) {
+            continue;
+        }
+        width += (cp >= 0x1100 && (cp <= 0x115f || cp >= 0x2e80)) ? 2 : 1;
+    }
+    return width;
+}
+
+// 按显示宽度截断并补齐空格
+std::string fit(const std::string& text, size_t width) {
+    std::string result;
+    size_t used = 0;
+    for (size_t i = 0; i < text.size();) {
+        unsigned char c = static_cast<unsigned char>(text[i]);
+        size_t length = c >= 0xf0 ? 4 : c >= 0xe0 ? 3 : c >= 0xc0 ? 2 : 1;
+        std::string ch = text.substr(i, length);
+        size_t w = display_width(ch);
+        if (used + w > width) {
+            break;
+        }
+        result += ch;
+        used += w;
+        i += length;
+    }
+    return result + std::string(width - used, ' ');
+}
+
+std::string format_clock(int64_t timestamp_ms) {
+    time_t seconds = static_cast<time_t>(timestamp_ms / 1000);
+    struct tm local_tm;
+    localtime_r(&seconds, &local_tm);
+    char buffer[16];
+    strftime(buffer, sizeof(buffer), "%H:%M:%S", &local_tm);
+    return buffer;
+}
+
+std::string format_duration(int64_t ms) {
+    int64_t seconds = ms / 1000;
+    char buffer[32];
+    snprintf(buffer, sizeof(buffer), "%02lld:%02lld:%02lld", static_cast<long long>(seconds / 3600),
+             static_cast<long long>(seconds / 60 % 60), static_cast<long long>(seconds % 60));
+    return buffer;
+}
+
+std::string format_rate(size_t count, int seconds) {
+    char buffer[32];
+    snprintf(buffer, sizeof(buffer), "%.1f/s", static_cast<double>(count) / seconds);
+    return buffer;
+}
+
+} // namespace
+
+TuiDashboard::TuiDashboard(const std::string& router_name, int64_t threshold_ms)
+    : router_name_(router_name), threshold_ms_(threshold_ms) {
+}
+
+TuiDashboard::~TuiDashboard() {
+    stop();
+}
+
+bool TuiDashboard::start(std::string& error) {
+    if (running_.load()) {
+        return true;
+    }
+    if (!isatty(STDOUT_FILENO)) {
+        error = "--tui需要在终端中运行";
+        return false;
+    }
+
+    int fds[2];
+    if (pipe2(fds, O_CLOEXEC) < 0) {
+        error = "pipe: " + std::string(strerror(errno));
+        return false;
+    }
+    fcntl(fds[0], F_SETFL, O_NONBLOCK);
+
+    std::cout.flush();
+    fflush(stdout);
+    fflush(stderr);
+    tty_fd_ = fcntl(STDOUT_FILENO, F_DUPFD_CLOEXEC, 3);
+    saved_stderr_fd_ = fcntl(STDERR_FILENO, F_DUPFD_CLOEXEC, 3);
+    dup2(fds[1], STDOUT_FILENO);
+    dup2(fds[1], STDERR_FILENO);
+    close(fds[1]);
+    capture_fd_ = fds[0];
+
+    started_ms_ = now_ms();
+    write_terminal(ENTER_SCREEN);
+
+    running_.store(true);
+    capture_thread_ = std::thread(&TuiDashboard::capture_loop, this);
+    render_thread_ = std::thread(&TuiDashboard::render_loop, this);
+    return true;
+}
+
+void TuiDashboard::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    if (render_thread_.joinable()) {
+        render_thread_.join();
+    }
+
+    std::cout.flush();
+    fflush(stdout);
+    fflush(stderr);
+    dup2(tty_fd_, STDOUT_FILENO);
+    dup2(saved_stderr_fd_, STDERR_FILENO);
+    if (capture_thread_.joinable()) {
+        capture_thread_.join();
+    }
+
+    write_terminal(LEAVE_SCREEN);
+    close(tty_fd_);
+    close(saved_stderr_fd_);
+    close(capture_fd_);
+    tty_fd_ = saved_stderr_fd_ = capture_fd_ = -1;
+}
+
+void TuiDashboard::write_terminal(const std::string& data) const {
+    size_t written = 0;
+    while (written < data.size()) {
+        ssize_t len = write(tty_fd_, data.data() + written, data.size() - written);
+        if (len < 0 && errno == EINTR) {
+            continue;
+        }
+        if (len <= 0) {
+            return;
+        }
+        written += static_cast<size_t>(len);
+    }
+}
+
+void TuiDashboard::capture_loop() {
+    char buffer[4096];
+    while (true) {
+        struct pollfd pfd = {capture_fd_, POLLIN, 0};
+        poll(&pfd, 1, running_.load() ? 100 : 0);
+
+        ssize_t len = read(capture_fd_, buffer, sizeof(buffer));
+        if (len > 0) {
+            append_output(buffer, static_cast<size_t>(len));
+            continue;
+        }
+        // 停止后读完管道中剩余的输出即退出(子进程可能仍持有写端)
+        if (!running_.load()) {
+            break;
+        }
+    }
+}
+
+void TuiDashboard::append_output(const char* data, size_t length) {
+    std::lock_guard<std::mutex> lock(state_mutex_);
+    for (size_t i = 0; i < length; ++i) {
+        if (data[i] == '\n') {
+            // 跳过空行，保留有内容的提示
+            if (partial_line_.find_first_not_of(" \r") != std::string::npos) {
+                messages_.push_back(partial_line_);
+                if (messages_.size() > MAX_MESSAGES) {
+                    messages_.pop_front();
+                }
+            }
+            partial_line_.clear();
+        } else if (data[i] != '\r' && data[i] != '\x1b') {
+            partial_line_ += data[i];
+        }
+    }
+}
+
+void TuiDashboard::render_loop() {
+    while (running_.load()) {
+        struct winsize size;
+        int rows = 24, columns = 80;
+        if (ioctl(tty_fd_, TIOCGWINSZ, &size) == 0 && size.ws_row > 0 && size.ws_col > 0) {
+            rows = size.ws_row;
+            columns = size.ws_col;
+        }
+        write_terminal("\x1b[H" + render(rows, columns) + "\x1b[J");
+        std::this_thread::sleep_for(std::chrono::milliseconds(200));
+    }
+}
+
+void TuiDashboard::write_record(const JsonObject& record, const std::string&) {
+    std::string event_type = LogReader::get_string(record, "event_type");
+    int64_t timestamp_ms = LogReader::parse_timestamp_ms(LogReader::get_timestamp(record, "timestamp"));
+    int64_t session_id = LogReader::get_int(record, "session_id");
+
+    std::lock_guard<std::mutex> lock(state_mutex_);
+    if (event_type == "session_started") {
+        auto info = LogReader::parse_string_map(LogReader::get_string(record, "trigger_info"));
+        SessionRow row;
+        row.session_id = session_id;
+        row.started_ms = timestamp_ms;
+        row.trigger = event_type_label(LogReader::get_string(record, "trigger_event_type"));
+        if (LogReader::has(record, "link")) {
+            row.target = LogReader::get_string(record, "link");
+        } else if (info.count("interface") && info["interface"] != "N/A") {
+            row.target = info["interface"];
+        }
+        if (info.count("dst") && info["dst"] != "N/A") {
+            row.target += (row.target.empty() ? "" : " ") + info["dst"];
+        }
+        sessions_.push_front(row);
+        if (sessions_.size() > MAX_SESSIONS) {
+            sessions_.pop_back();
+        }
+        total_sessions_++;
+    } else if (event_type == "route_event" || event_type == "route_event_summary") {
+        // --coalesce-ms合并的记录与--event-detail summary的每秒汇总都代表多条事件
+        int64_t count = event_type == "route_event_summary"
+                            ? LogReader::get_int(record, "route_events")
+                            : LogReader::get_int(record, "coalesced_count", 1);
+        total_route_events_ += count;
+        event_times_ms_.emplace_back(timestamp_ms, count);
+        while (!event_times_ms_.empty() && timestamp_ms - event_times_ms_.front().first > 60000) {
+            event_times_ms_.pop_front();
+        }
+        for (auto& row : sessions_) {
+            if (row.session_id == session_id) {
+                row.route_events = event_type == "route_event_summary"
+                    ? LogReader::get_int(record, "session_route_events")
+                    : LogReader::get_int(record, "session_event_number", row.route_events + 1) + count - 1;
+                break;
+            }
+        }
+    } else if (event_type == "session_completed") {
+        for (auto& row : sessions_) {
+            if (row.session_id == session_id) {
+                row.completed = true;
+                row.timed_out = LogReader::get_bool(record, "timed_out");
+                row.route_events = LogReader::get_int(record, "route_events_count");
+                if (LogReader::has(record, "convergence_time_ms")) {
+                    row.convergence_ms = LogReader::get_int(record, "convergence_time_ms");
+                }
+                break;
+            }
+        }
+        if (LogReader::get_bool(record, "timed_out")) {
+            timed_out_sessions_++;
+        }
+    } else if (event_type == "threshold_changed") {
+        threshold_ms_ = LogReader::get_int(record, "convergence_threshold_ms", threshold_ms_);
+    } else if (event_type == "stats_reset") {
+        // 会话列表保留作为历史，计数与监控器的统计一起清零
+        total_sessions_ = 0;
+        total_route_events_ = 0;
+        timed_out_sessions_ = 0;
+    }
+}
+
+std::string TuiDashboard::render(int rows, int columns) const {
+    std::lock_guard<std::mutex> lock(state_mutex_);
+    int64_t now = now_ms();
+    size_t width = static_cast<size_t>(std::max(columns, 40));
+    std::vector<std::string> lines;
+
+    lines.push_back(REVERSE + BOLD +
+                    fit(std::string(tr(" 收敛监控 ", " Convergence monitor ")) + router_name_ + tr("   阈值 ", "   threshold ") +
+                        std::to_string(threshold_ms_) + tr("ms   已运行 ", "ms   elapsed ") +
+                        format_duration(now - started_ms_) + tr("   Ctrl+C 退出", "   Ctrl+C to quit"), width) +
+                    RESET);
+
+    // 当前状态
+    const SessionRow* active = (!sessions_.empty() && !sessions_.front().completed) ? &sessions_.front() : nullptr;
+    if (active) {
+        lines.push_back(tr(" 状态: ", " State: ") + CYAN + BOLD + tr("会话 #", "session #") +
+                        std::to_string(active->session_id) + tr(" 测量中", " measuring") + RESET +
+                        tr("   已持续 ", "   elapsed ") + std::to_string(std::max<int64_t>(0, now - active->started_ms)) +
+                        "ms" + tr("   路由事件 ", "   route events ") + std::to_string(active->route_events) +
+                        tr("   触发 ", "   trigger ") + active->trigger +
+                        (active->target.empty() ? "" : " (" + active->target + ")"));
+    } else {
+        lines.push_back(tr(" 状态: ", " State: ") + GREEN + BOLD + tr("空闲", "idle") + RESET +
+                        tr("，等待触发事件", ", waiting for trigger events"));
+    }
+
+    // 滚动事件速率
+    size_t last_1s = 0, last_10s = 0, last_60s = 0;
+    for (const auto& entry : event_times_ms_) {
+        int64_t age = now - entry.first;
+        size_t count = static_cast<size_t>(entry.second);
+        last_1s += age <= 1000 ? count : 0;
+        last_10s += age <= 10000 ? count : 0;
+        last_60s += age <= 60000 ? count : 0;
+    }
+    lines.push_back(tr(" 路由事件速率: 1s ", " Route event rate: 1s ") + format_rate(last_1s, 1) + "   10s " +
+                    format_rate(last_10s, 10) + "   60s " + format_rate(last_60s, 60) + tr("   累计 ", "   total ") +
+                    std::to_string(total_route_events_) + tr("   会话 ", "   sessions ") + std::to_string(total_sessions_) +
+                    (timed_out_sessions_ > 0
+                         ? "   " + RED + tr("超时 ", "timed out ") + std::to_string(timed_out_sessions_) + RESET
+                         : ""));
+    lines.push_back("");
+
+    // 最近会话：剩余高度的一半，至少3行
+    int available = rows - static_cast<int>(lines.size()) - 1;
+    int session_rows = std::max(3, available / 2 - 1);
+    lines.push_back(BOLD + tr(" 最近会话", " Recent sessions") + RESET);
+    lines.push_back(DIM + " " + fit(tr("会话", "Session"), 7) + fit(tr("开始", "Start"), 10) +
+                    fit(tr("触发", "Trigger"), 16) + fit(tr("目标", "Target"), 24) + fit(tr("收敛(ms)", "Conv(ms)"), 10) +
+                    fit(tr("事件", "Events"), 7) + tr("结果", "Result") + RESET);
+    int shown = 0;
+    for (const auto& row : sessions_) {
+        if (shown++ >= session_rows) {
+            break;
+        }
+        std::string convergence = row.convergence_ms ? std::to_string(*row.convergence_ms) : "-";
+        std::string result;
+        if (!row.completed) {
+            result = CYAN + tr("测量中", "measuring") + RESET;
+        } else if (row.timed_out) {
+            result = RED + tr("超时", "timed out") + RESET;
+        } else {
+            result = GREEN + tr("完成", "converged") + RESET;
+        }
+        std::string color = (row.convergence_ms && *row.convergence_ms > threshold_ms_) ? YELLOW : "";
+        lines.push_back(" " + fit("#" + std::to_string(row.session_id), 7) + fit(format_clock(row.started_ms), 10) +
+                        fit(row.trigger, 16) + fit(row.target.empty() ? "-" : row.target, 24) + color +
+                        fit(convergence, 10) + (color.empty() ? "" : RESET) +
+                        fit(std::to_string(row.route_events), 7) + result);
+    }
+    if (sessions_.empty()) {
+        lines.push_back(DIM + tr(" (暂无会话)", " (no sessions yet)") + RESET);
+    }
+    lines.push_back("");
+
+    // 最近消息填满剩余行
+    lines.push_back(BOLD + tr(" 最近消息", " Recent messages") + RESET);
+    int message_rows = rows - static_cast<int>(lines.size());
+    size_t first = messages_.size() > static_cast<size_t>(std::max(message_rows, 0))
+                       ? messages_.size() - static_cast<size_t>(std::max(message_rows, 0))
+                       : 0;
+    for (size_t i = first; i < messages_.size(); ++i) {
+        lines.push_back(" " + fit(messages_[i], width - 1));
+    }
+
+    std::string frame;
+    for (size_t i = 0; i < lines.size() && static_cast<int>(i) < rows; ++i) {
+        if (i > 0) {
+            frame += "\r\n";
+        }
+        frame += lines[i] + "\x1b[K";
+    }
+    return frame;
+}
+
+std::string TuiDashboard::summary() const {
+    std::lock_guard<std::mutex> lock(state_mutex_);
+    return "TUI: 显示 " + std::to_string(total_sessions_) + " 个会话";
+}
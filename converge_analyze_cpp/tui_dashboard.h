@@ -0,0 +1,71 @@
+#pragma once
+
+#include <atomic>
+#include <cstdint>
+#include <deque>
+#include <mutex>
+#include <optional>
+#include <string>
+#include <thread>
+#include "record_sink.h"
+
+// 交互式终端仪表盘(--tui)：在备用屏幕上周期性刷新当前状态、事件速率和最近会话。
+// 运行期间标准输出/错误被重定向到管道，原有的逐行控制台提示显示在"最近消息"面板中。
+class TuiDashboard : public RecordSink {
+private:
+    struct SessionRow {
+        int64_t session_id = 0;
+        int64_t started_ms = 0;
+        std::string trigger;
+        std::string target;
+        int64_t route_events = 0;
+        std::optional<int64_t> convergence_ms;
+        bool completed = false;
+        bool timed_out = false;
+    };
+
+    std::string router_name_;
+    int64_t threshold_ms_;
+    int64_t started_ms_ = 0;
+
+    int tty_fd_ = -1;           // 原标准输出(终端)
+    int saved_stderr_fd_ = -1;
+    int capture_fd_ = -1;       // 重定向管道的读端
+    std::thread render_thread_;
+    std::thread capture_thread_;
+    std::atomic<bool> running_{false};
+
+    mutable std::mutex state_mutex_;
+    std::deque<SessionRow> sessions_;        // 最近的会话，最新的在前
+    std::deque<std::pair<int64_t, int64_t>> event_times_ms_;  // 最近60秒内路由事件的时间戳与条数
+    std::deque<std::string> messages_;       // 最近的控制台输出行
+    std::string partial_line_;
+    int64_t total_sessions_ = 0;
+    int64_t total_route_events_ = 0;
+    int64_t timed_out_sessions_ = 0;
+
+    static constexpr size_t MAX_SESSIONS = 100;
+    static constexpr size_t MAX_MESSAGES = 200;
+
+    void render_loop();
+    void capture_loop();
+    void append_output(const char* data, size_t length);
+    void write_terminal(const std::string& data) const;
+
+public:
+    TuiDashboard(const std::string& router_name, int64_t threshold_ms);
+    ~TuiDashboard() override;
+
+    TuiDashboard(const TuiDashboard&) = delete;
+    TuiDashboard& operator=(const TuiDashboard&) = delete;
+
+    // 切换到备用屏幕并接管标准输出，标准输出不是终端时失败
+    bool start(std::string& error) override;
+    void write_record(const JsonObject& record, const std::string& line) override;
+    // 恢复终端与标准输出/错误
+    void stop() override;
+    std::string summary() const override;
+
+    // 生成一帧画面(不含光标定位)，rows/columns为终端大小
+    std::string render(int rows, int columns) const;
+};
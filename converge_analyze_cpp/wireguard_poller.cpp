@@ -0,0 +1,290 @@
+#include "wireguard_poller.h"
+#include <arpa/inet.h>
+#include <cerrno>
+#include <chrono>
+#include <cstring>
+#include <linux/genetlink.h>
+#include <linux/netlink.h>
+#include <linux/wireguard.h>
+#include <netinet/in.h>
+#include <sys/socket.h>
+#include <sys/time.h>
+#include <unistd.h>
+
+namespace {
+
+int64_t now_ms() {
+    return std::chrono::duration_cast<std::chrono::milliseconds>(
+        std::chrono::system_clock::now().time_since_epoch()).count();
+}
+
+std::string base64_encode(const uint8_t* data, size_t len) {
+    static const char table[] = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/";
+    std::string out;
+    for (size_t i = 0; i < len; i += 3) {
+        uint32_t chunk = static_cast<uint32_t>(data[i]) << 16;
+        if (i + 1 < len) chunk |= static_cast<uint32_t>(data[i + 1]) << 8;
+        if (i + 2 < len) chunk |= data[i + 2];
+        out += table[(chunk >> 18) & 0x3F];
+        out += table[(chunk >> 12) & 0x3F];
+        out += i + 1 < len ? table[(chunk >> 6) & 0x3F] : '=';
+        out += i + 2 < len ? table[chunk & 0x3F] : '=';
+    }
+    return out;
+}
+
+std::string format_endpoint(const void* data, size_t len) {
+    char buffer[INET6_ADDRSTRLEN] = {0};
+    if (len >= sizeof(struct sockaddr_in6) &&
+        static_cast<const struct sockaddr*>(data)->sa_family == AF_INET6) {
+        const auto* sin6 = static_cast<const struct sockaddr_in6*>(data);
+        inet_ntop(AF_INET6, &sin6->sin6_addr, buffer, sizeof(buffer));
+        return "[" + std::string(buffer) + "]:" + std::to_string(ntohs(sin6->sin6_port));
+    }
+    if (len >= sizeof(struct sockaddr_in) &&
+        static_cast<const struct sockaddr*>(data)->sa_family == AF_INET) {
+        const auto* sin = static_cast<const struct sockaddr_in*>(data);
+        inet_ntop(AF_INET, &sin->sin_addr, buffer, sizeof(buffer));
+        return std::string(buffer) + ":" + std::to_string(ntohs(sin->sin_port));
+    }
+    return "";
+}
+
+// 遍历一段连续的netlink属性，回调参数为去掉NLA_F_NESTED等标志后的类型
+template <typename Fn>
+void for_each_attr(const char* data, int len, Fn fn) {
+    while (len >= static_cast<int>(NLA_HDRLEN)) {
+        const auto* nla = reinterpret_cast<const struct nlattr*>(data);
+        if (nla->nla_len < NLA_HDRLEN || nla->nla_len > len) {
+            break;
+        }
+        fn(nla->nla_type & NLA_TYPE_MASK, data + NLA_HDRLEN, static_cast<int>(nla->nla_len - NLA_HDRLEN));
+        int aligned = NLA_ALIGN(nla->nla_len);
+        data += aligned;
+        len -= aligned;
+    }
+}
+
+void put_attr(char* buffer, struct nlmsghdr* nlh, uint16_t type, const void* data, size_t len) {
+    auto* nla = reinterpret_cast<struct nlattr*>(buffer + NLMSG_ALIGN(nlh->nlmsg_len));
+    nla->nla_type = type;
+    nla->nla_len = static_cast<uint16_t>(NLA_HDRLEN + len);
+    memcpy(reinterpret_cast<char*>(nla) + NLA_HDRLEN, data, len);
+    nlh->nlmsg_len = NLMSG_ALIGN(nlh->nlmsg_len) + NLA_ALIGN(nla->nla_len);
+}
+
+// 发送一个generic netlink请求并逐条处理应答，直到NLMSG_DONE或非dump请求的第一条应答
+template <typename Fn>
+bool genl_request(int fd, uint16_t family, uint8_t cmd, uint16_t flags, uint16_t attr_type,
+                  const std::string& attr_value, std::string& error, Fn on_message) {
+    char request[256] = {0};
+    auto* nlh = reinterpret_cast<struct nlmsghdr*>(request);
+    nlh->nlmsg_len = NLMSG_LENGTH(GENL_HDRLEN);
+    nlh->nlmsg_type = family;
+    nlh->nlmsg_flags = NLM_F_REQUEST | flags;
+    auto* genl = static_cast<struct genlmsghdr*>(NLMSG_DATA(nlh));
+    genl->cmd = cmd;
+    genl->version = 1;
+    put_attr(request, nlh, attr_type, attr_value.c_str(), attr_value.size() + 1);
+
+    if (send(fd, request, nlh->nlmsg_len, 0) < 0) {
+        error = std::string("send: ") + strerror(errno);
+        return false;
+    }
+
+    bool dump = (flags & NLM_F_DUMP) != 0;
+    char buffer[32768];
+    while (true) {
+        ssize_t len = recv(fd, buffer, sizeof(buffer), 0);
+        if (len < 0) {
+            if (errno == EINTR) {
+                continue;
+            }
+            error = std::string("recv: ") + strerror(errno);
+            return false;
+        }
+        int remaining = static_cast<int>(len);
+        for (auto* msg = reinterpret_cast<struct nlmsghdr*>(buffer); NLMSG_OK(msg, remaining);
+             msg = NLMSG_NEXT(msg, remaining)) {
+            if (msg->nlmsg_type == NLMSG_DONE) {
+                return true;
+            }
+            if (msg->nlmsg_type == NLMSG_ERROR) {
+                auto* err = static_cast<struct nlmsgerr*>(NLMSG_DATA(msg));
+                if (err->error == 0) {
+                    return true;
+                }
+                error = strerror(-err->error);
+                return false;
+            }
+            const char* payload = static_cast<const char*>(NLMSG_DATA(msg)) + GENL_HDRLEN;
+            on_message(payload, static_cast<int>(msg->nlmsg_len - NLMSG_LENGTH(GENL_HDRLEN)));
+            if (!dump) {
+                return true;
+            }
+        }
+    }
+}
+
+int open_genl_socket() {
+    int fd = socket(AF_NETLINK, SOCK_RAW | SOCK_CLOEXEC, NETLINK_GENERIC);
+    if (fd < 0) {
+        return -1;
+    }
+    // 避免内核无应答时轮询线程卡住，stop()无法及时返回
+    struct timeval timeout{1, 0};
+    setsockopt(fd, SOL_SOCKET, SO_RCVTIMEO, &timeout, sizeof(timeout));
+    return fd;
+}
+
+} // namespace
+
+WireguardPoller::WireguardPoller(int64_t poll_interval_ms, InterfaceSource interfaces, Callback callback)
+    : poll_interval_ms_(poll_interval_ms), interfaces_(std::move(interfaces)), callback_(std::move(callback)) {
+}
+
+WireguardPoller::~WireguardPoller() {
+    stop();
+}
+
+bool WireguardPoller::start(std::string& error) {
+    if (running_.load()) {
+        return true;
+    }
+
+    int fd = open_genl_socket();
+    if (fd < 0) {
+        error = std::string("socket: ") + strerror(errno);
+        return false;
+    }
+    int family_id = 0;
+    bool ok = genl_request(fd, GENL_ID_CTRL, CTRL_CMD_GETFAMILY, 0, CTRL_ATTR_FAMILY_NAME,
+                           WG_GENL_NAME, error, [&family_id](const char* data, int len) {
+        for_each_attr(data, len, [&family_id](int type, const char* value, int value_len) {
+            if (type == CTRL_ATTR_FAMILY_ID && value_len >= 2) {
+                family_id = *reinterpret_cast<const uint16_t*>(value);
+            }
+        });
+    });
+    close(fd);
+    if (!ok || family_id == 0) {
+        if (error.empty()) {
+            error = "wireguard family not found";
+        }
+        return false;
+    }
+
+    family_id_ = family_id;
+    running_.store(true);
+    worker_thread_ = std::thread(&WireguardPoller::worker_loop, this);
+    return true;
+}
+
+void WireguardPoller::stop() {
+    if (!running_.load()) {
+        return;
+    }
+
+    running_.store(false);
+    if (worker_thread_.joinable()) {
+        worker_thread_.join();
+    }
+}
+
+bool WireguardPoller::query_device(int fd, const std::string& interface, EndpointMap& endpoints,
+                                   std::string& error) {
+    return genl_request(fd, static_cast<uint16_t>(family_id_), WG_CMD_GET_DEVICE, NLM_F_DUMP,
+                        WGDEVICE_A_IFNAME, interface, error,
+                        [&](const char* data, int len) {
+        // 对端较多时会分成多条消息，每条都带WGDEVICE_A_PEERS
+        for_each_attr(data, len, [&](int type, const char* value, int value_len) {
+            if (type != WGDEVICE_A_PEERS) {
+                return;
+            }
+            for_each_attr(value, value_len, [&](int, const char* peer, int peer_len) {
+                std::string public_key, endpoint;
+                for_each_attr(peer, peer_len, [&](int peer_type, const char* attr, int attr_len) {
+                    if (peer_type == WGPEER_A_PUBLIC_KEY && attr_len == WG_KEY_LEN) {
+                        public_key = base64_encode(reinterpret_cast<const uint8_t*>(attr), attr_len);
+                    } else if (peer_type == WGPEER_A_ENDPOINT) {
+                        endpoint = format_endpoint(attr, attr_len);
+                    }
+                });
+                if (!public_key.empty()) {
+                    endpoints[interface + "|" + public_key] = endpoint;
+                }
+            });
+        });
+    });
+}
+
+void WireguardPoller::worker_loop() {
+    EndpointMap previous;
+    bool has_baseline = false;
+    int fd = open_genl_socket();
+
+    while (running_.load() && fd >= 0) {
+        auto poll_start = std::chrono::steady_clock::now();
+
+        EndpointMap current;
+        bool ok = true;
+        for (const auto& interface : interfaces_()) {
+            std::string error;
+            // 接口在两次轮询之间被删除时查询失败，不影响其他接口
+            if (!query_device(fd, interface, current, error) && error.find("No such device") == std::string::npos) {
+                ok = false;
+            }
+        }
+
+        // 查询失败时不更新基线，避免把失败误判为对端被移除
+        if (ok) {
+            if (has_baseline) {
+                for (const auto& event : diff(previous, current, now_ms())) {
+                    callback_(event);
+                }
+            }
+            previous = std::move(current);
+            has_baseline = true;
+        }
+
+        auto deadline = poll_start + std::chrono::milliseconds(poll_interval_ms_);
+        while (running_.load() && std::chrono::steady_clock::now() < deadline) {
+            std::this_thread::sleep_for(std::chrono::milliseconds(20));
+        }
+    }
+
+    if (fd >= 0) {
+        close(fd);
+    }
+}
+
+std::vector<WireguardPeerEvent> WireguardPoller::diff(const EndpointMap& before, const EndpointMap& after,
+                                                      int64_t timestamp_ms) {
+    std::vector<WireguardPeerEvent> events;
+    auto make_event = [timestamp_ms](const std::string& key, const std::string& previous,
+                                     const std::string& endpoint) {
+        WireguardPeerEvent event;
+        event.timestamp_ms = timestamp_ms;
+        size_t sep = key.find('|');
+        event.interface = key.substr(0, sep);
+        event.peer = key.substr(sep + 1);
+        event.previous_endpoint = previous;
+        event.endpoint = endpoint;
+        return event;
+    };
+
+    for (const auto& pair : after) {
+        auto it = before.find(pair.first);
+        std::string previous = it != before.end() ? it->second : "";
+        // 新增的对端尚无端点时不算变化
+        if (previous != pair.second) {
+            events.push_back(make_event(pair.first, previous, pair.second));
+        }
+    }
+    for (const auto& pair : before) {
+        if (after.find(pair.first) == after.end() && !pair.second.empty()) {
+            events.push_back(make_event(pair.first, pair.second, ""));
+        }
+    }
+    return events;
+}
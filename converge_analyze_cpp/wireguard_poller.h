@@ -0,0 +1,54 @@
+#pragma once
+
+#include <atomic>
+#include <functional>
+#include <map>
+#include <string>
+#include <thread>
+#include <vector>
+
+// WireGuard对端端点变化(漫游或重新配置endpoint)
+struct WireguardPeerEvent {
+    int64_t timestamp_ms = 0;  // 检测到变化的时间(轮询精度)
+    std::string interface;
+    std::string peer;               // 对端公钥(base64)
+    std::string endpoint;           // 新端点，如 "192.0.2.1:51820"，移除时为空
+    std::string previous_endpoint;  // 首次出现时为空
+};
+
+// WireGuard端点不在rtnetlink接口消息中，需通过generic netlink(WG_CMD_GET_DEVICE)周期性查询；
+// 内核没有wireguard模块时只在启动时记录一次，不影响其他隧道事件
+class WireguardPoller {
+public:
+    using Callback = std::function<void(const WireguardPeerEvent&)>;
+    // 提供当前wireguard接口名列表，通常来自LinkTracker
+    using InterfaceSource = std::function<std::vector<std::string>()>;
+    // 键为 "接口|公钥"，值为端点
+    using EndpointMap = std::map<std::string, std::string>;
+
+private:
+    int64_t poll_interval_ms_;
+    InterfaceSource interfaces_;
+    Callback callback_;
+    std::thread worker_thread_;
+    std::atomic<bool> running_{false};
+    int family_id_ = 0;
+
+    void worker_loop();
+    bool query_device(int fd, const std::string& interface, EndpointMap& endpoints, std::string& error);
+
+public:
+    WireguardPoller(int64_t poll_interval_ms, InterfaceSource interfaces, Callback callback);
+    ~WireguardPoller();
+
+    WireguardPoller(const WireguardPoller&) = delete;
+    WireguardPoller& operator=(const WireguardPoller&) = delete;
+
+    // 解析wireguard的generic netlink族ID，失败(未加载模块等)时返回false且不启动
+    bool start(std::string& error);
+    void stop();
+
+    // 比较两次快照，返回端点变化；新增或移除的对端按端点从空变为非空(或相反)处理
+    static std::vector<WireguardPeerEvent> diff(const EndpointMap& before, const EndpointMap& after,
+                                                int64_t timestamp_ms);
+};
@@ -0,0 +1,398 @@
+#include "yaml_lite.h"
+#include <fstream>
+#include <sstream>
+
+const YamlNode* YamlNode::get(const std::string& key) const {
+    if (type != MAP) {
+        return nullptr;
+    }
+    for (const auto& entry : entries) {
+        if (entry.first == key) {
+            return &entry.second;
+        }
+    }
+    return nullptr;
+}
+
+std::string YamlNode::get_string(const std::string& key, const std::string& default_value) const {
+    const YamlNode* node = get(key);
+    if (node == nullptr || !node->is_scalar()) {
+        return default_value;
+    }
+    return node->scalar;
+}
+
+namespace {
+
+struct Line {
+    int number;
+    int indent;
+    std::string content;  // 去掉缩进与注释后的内容
+    std::string raw;      // 原始行，供块标量使用
+};
+
+std::string trim(const std::string& s) {
+    size_t begin = s.find_first_not_of(" \t\r");
+    if (begin == std::string::npos) {
+        return "";
+    }
+    size_t end = s.find_last_not_of(" \t\r");
+    return s.substr(begin, end - begin + 1);
+}
+
+// 去除引号外的 # 注释
+std::string strip_comment(const std::string& s) {
+    char quote = 0;
+    for (size_t i = 0; i < s.size(); ++i) {
+        char c = s[i];
+        if (quote) {
+            if (c == '\\' && quote == '"') {
+                ++i;
+            } else if (c == quote) {
+                quote = 0;
+            }
+        } else if (c == '"' || c == '\'') {
+            quote = c;
+        } else if (c == '#' && (i == 0 || s[i - 1] == ' ' || s[i - 1] == '\t')) {
+            return s.substr(0, i);
+        }
+    }
+    return s;
+}
+
+std::string unquote(const std::string& s) {
+    if (s.size() >= 2 && s.front() == '\'' && s.back() == '\'') {
+        std::string out;
+        for (size_t i = 1; i + 1 < s.size(); ++i) {
+            out += s[i];
+            if (s[i] == '\'' && s[i + 1] == '\'') {
+                ++i;
+            }
+        }
+        return out;
+    }
+    if (s.size() >= 2 && s.front() == '"' && s.back() == '"') {
+        std::string out;
+        for (size_t i = 1; i + 1 < s.size(); ++i) {
+            if (s[i] == '\\' && i + 2 < s.size()) {
+                char n = s[++i];
+                switch (n) {
+                    case 'n': out += '\n'; break;
+                    case 't': out += '\t'; break;
+                    default:  out += n; break;
+                }
+            } else {
+                out += s[i];
+            }
+        }
+        return out;
+    }
+    return s;
+}
+
+// 查找映射键后的冒号（引号外、后跟空白或行尾）
+size_t find_key_colon(const std::string& s) {
+    char quote = 0;
+    for (size_t i = 0; i < s.size(); ++i) {
+        char c = s[i];
+        if (quote) {
+            if (c == quote) quote = 0;
+        } else if ((c == '"' || c == '\'') && i == 0) {
+            quote = c;
+        } else if (c == ':' && (i + 1 == s.size() || s[i + 1] == ' ' || s[i + 1] == '\t')) {
+            return i;
+        } else if ((c == '[' || c == '{') && i == 0) {
+            return std::string::npos;
+        }
+    }
+    return std::string::npos;
+}
+
+bool is_sequence_entry(const std::string& content) {
+    return content == "-" || content.compare(0, 2, "- ") == 0;
+}
+
+class FlowParser {
+private:
+    const std::string& text_;
+    size_t pos_ = 0;
+
+    void skip_spaces() {
+        while (pos_ < text_.size() && (text_[pos_] == ' ' || text_[pos_] == '\t')) ++pos_;
+    }
+
+    bool parse_scalar(YamlNode& node, const char* terminators) {
+        skip_spaces();
+        node.type = YamlNode::SCALAR;
+        if (pos_ < text_.size() && (text_[pos_] == '"' || text_[pos_] == '\'')) {
+            char quote = text_[pos_];
+            size_t start = pos_++;
+            while (pos_ < text_.size() && text_[pos_] != quote) {
+                if (text_[pos_] == '\\' && quote == '"') ++pos_;
+                ++pos_;
+            }
+            if (pos_ >= text_.size()) {
+                return false;
+            }
+            ++pos_;
+            node.scalar = unquote(text_.substr(start, pos_ - start));
+            return true;
+        }
+        size_t start = pos_;
+        while (pos_ < text_.size()) {
+            char c = text_[pos_];
+            bool stop = false;
+            for (const char* t = terminators; *t; ++t) {
+                if (c == *t) stop = true;
+            }
+            if (stop) break;
+            ++pos_;
+        }
+        node.scalar = trim(text_.substr(start, pos_ - start));
+        return true;
+    }
+
+public:
+    explicit FlowParser(const std::string& text) : text_(text) {}
+
+    bool parse_value(YamlNode& node, const char* terminators) {
+        skip_spaces();
+        if (pos_ >= text_.size()) {
+            return false;
+        }
+        if (text_[pos_] == '[') {
+            ++pos_;
+            node.type = YamlNode::SEQUENCE;
+            skip_spaces();
+            if (pos_ < text_.size() && text_[pos_] == ']') {
+                ++pos_;
+                return true;
+            }
+            while (true) {
+                YamlNode item;
+                if (!parse_value(item, ",]")) return false;
+                node.items.push_back(std::move(item));
+                skip_spaces();
+                if (pos_ >= text_.size()) return false;
+                if (text_[pos_] == ']') { ++pos_; return true; }
+                if (text_[pos_] != ',') return false;
+                ++pos_;
+            }
+        }
+        if (text_[pos_] == '{') {
+            ++pos_;
+            node.type = YamlNode::MAP;
+            skip_spaces();
+            if (pos_ < text_.size() && text_[pos_] == '}') {
+                ++pos_;
+                return true;
+            }
+            while (true) {
+                YamlNode key;
+                if (!parse_scalar(key, ":,}")) return false;
+                skip_spaces();
+                YamlNode value;
+                if (pos_ < text_.size() && text_[pos_] == ':') {
+                    ++pos_;
+                    if (!parse_value(value, ",}")) return false;
+                }
+                node.entries.emplace_back(key.scalar, std::move(value));
+                skip_spaces();
+                if (pos_ >= text_.size()) return false;
+                if (text_[pos_] == '}') { ++pos_; return true; }
+                if (text_[pos_] != ',') return false;
+                ++pos_;
+            }
+        }
+        return parse_scalar(node, terminators);
+    }
+
+    bool at_end() {
+        skip_spaces();
+        return pos_ >= text_.size();
+    }
+};
+
+class BlockParser {
+private:
+    std::vector<Line>& lines_;
+    std::string& error_;
+
+    bool fail(const Line& line, const std::string& message) {
+        error_ = "line " + std::to_string(line.number) + ": " + message;
+        return false;
+    }
+
+    bool parse_inline(const Line& line, const std::string& text, YamlNode& node) {
+        if (text.empty()) {
+            node.type = YamlNode::NIL;
+            return true;
+        }
+        if (text[0] == '[' || text[0] == '{') {
+            FlowParser parser(text);
+            if (!parser.parse_value(node, "") || !parser.at_end()) {
+                return fail(line, "invalid flow collection");
+            }
+            return true;
+        }
+        node.type = YamlNode::SCALAR;
+        node.scalar = unquote(text);
+        return true;
+    }
+
+    // | 与 > 块标量：收集所有缩进大于parent_indent的后续行
+    void parse_block_scalar(size_t& idx, int parent_indent, bool folded, YamlNode& node) {
+        node.type = YamlNode::SCALAR;
+        int block_indent = -1;
+        std::string text;
+        while (idx < lines_.size() && lines_[idx].indent > parent_indent) {
+            const Line& line = lines_[idx];
+            if (block_indent < 0) {
+                block_indent = line.indent;
+            }
+            std::string content = line.raw.size() > static_cast<size_t>(block_indent)
+                ? line.raw.substr(block_indent) : "";
+            if (!text.empty()) {
+                text += folded ? " " : "\n";
+            }
+            text += content;
+            ++idx;
+        }
+        if (!folded && !text.empty()) {
+            text += "\n";
+        }
+        node.scalar = text;
+    }
+
+    // 解析键后的值：行内值，或下一行开始的子块
+    bool parse_value_after(size_t& idx, int indent, const Line& line,
+                           const std::string& rest, bool allow_same_indent_seq, YamlNode& node) {
+        if (rest == "|" || rest == "|-" || rest == ">" || rest == ">-") {
+            parse_block_scalar(idx, indent, rest[0] == '>', node);
+            return true;
+        }
+        if (!rest.empty()) {
+            return parse_inline(line, rest, node);
+        }
+        if (idx < lines_.size()) {
+            const Line& next = lines_[idx];
+            if (next.indent > indent ||
+                (allow_same_indent_seq && next.indent == indent && is_sequence_entry(next.content))) {
+                return parse_block(idx, next.indent, node);
+            }
+        }
+        node.type = YamlNode::NIL;
+        return true;
+    }
+
+public:
+    BlockParser(std::vector<Line>& lines, std::string& error) : lines_(lines), error_(error) {}
+
+    bool parse_block(size_t& idx, int indent, YamlNode& node) {
+        if (idx >= lines_.size()) {
+            node.type = YamlNode::NIL;
+            return true;
+        }
+
+        if (is_sequence_entry(lines_[idx].content)) {
+            node.type = YamlNode::SEQUENCE;
+            while (idx < lines_.size() && lines_[idx].indent == indent &&
+                   is_sequence_entry(lines_[idx].content)) {
+                Line& line = lines_[idx];
+                std::string rest = trim(line.content.substr(1));
+                YamlNode item;
+                if (rest.empty()) {
+                    ++idx;
+                    if (!parse_value_after(idx, indent, line, "", false, item)) return false;
+                } else if (rest[0] != '[' && rest[0] != '{' && find_key_colon(rest) != std::string::npos) {
+                    // "- key: value" 开启一个映射，其缩进为"- "之后的列
+                    size_t offset = line.content.find(rest);
+                    line.indent += static_cast<int>(offset);
+                    line.content = rest;
+                    if (!parse_block(idx, line.indent, item)) return false;
+                } else {
+                    ++idx;
+                    if (!parse_inline(line, rest, item)) return false;
+                }
+                node.items.push_back(std::move(item));
+            }
+            return true;
+        }
+
+        node.type = YamlNode::MAP;
+        while (idx < lines_.size() && lines_[idx].indent == indent) {
+            const Line line = lines_[idx];
+            if (is_sequence_entry(line.content)) {
+                return fail(line, "unexpected sequence entry");
+            }
+            size_t colon = find_key_colon(line.content);
+            if (colon == std::string::npos) {
+                return fail(line, "expected 'key: value'");
+            }
+            std::string key = unquote(trim(line.content.substr(0, colon)));
+            std::string rest = trim(line.content.substr(colon + 1));
+            ++idx;
+
+            YamlNode value;
+            if (!parse_value_after(idx, indent, line, rest, true, value)) return false;
+            node.entries.emplace_back(key, std::move(value));
+        }
+        if (idx < lines_.size() && lines_[idx].indent > indent) {
+            return fail(lines_[idx], "unexpected indentation");
+        }
+        return true;
+    }
+};
+
+} // namespace
+
+bool YamlParser::parse(const std::string& text, YamlNode& root, std::string& error) {
+    std::vector<Line> lines;
+    std::istringstream iss(text);
+    std::string raw;
+    int number = 0;
+    while (std::getline(iss, raw)) {
+        ++number;
+        if (!raw.empty() && raw.back() == '\r') {
+            raw.pop_back();
+        }
+        if (raw.find('\t') != std::string::npos && raw.find_first_not_of(" \t") != std::string::npos &&
+            raw.find_first_not_of(" ") == raw.find('\t')) {
+            error = "line " + std::to_string(number) + ": tabs are not allowed for indentation";
+            return false;
+        }
+        std::string content = trim(strip_comment(raw));
+        if (content.empty() || content == "---" || content == "...") {
+            continue;
+        }
+        int indent = static_cast<int>(raw.find_first_not_of(' '));
+        lines.push_back({number, indent, content, raw});
+    }
+
+    root = YamlNode();
+    if (lines.empty()) {
+        return true;
+    }
+
+    BlockParser parser(lines, error);
+    size_t idx = 0;
+    if (!parser.parse_block(idx, lines[0].indent, root)) {
+        return false;
+    }
+    if (idx < lines.size()) {
+        error = "line " + std::to_string(lines[idx].number) + ": unexpected content";
+        return false;
+    }
+    return true;
+}
+
+bool YamlParser::parse_file(const std::string& path, YamlNode& root, std::string& error) {
+    std::ifstream file(path);
+    if (!file.is_open()) {
+        error = "cannot open " + path;
+        return false;
+    }
+    std::stringstream buffer;
+    buffer << file.rdbuf();
+    return parse(buffer.str(), root, error);
+}
@@ -0,0 +1,35 @@
+#pragma once
+
+#include <string>
+#include <utility>
+#include <vector>
+
+// 极简YAML节点，仅覆盖计划文件/containerlab拓扑用到的子集：
+// 块状映射与序列、流式 [a, b] / {k: v}、引号字符串、| 与 > 块标量、# 注释
+// 不支持锚点、别名、标签与多文档
+class YamlNode {
+public:
+    enum Type { NIL, SCALAR, MAP, SEQUENCE };
+
+    Type type = NIL;
+    std::string scalar;
+    std::vector<std::pair<std::string, YamlNode>> entries;  // MAP，保持原始顺序
+    std::vector<YamlNode> items;                            // SEQUENCE
+
+    bool is_nil() const { return type == NIL; }
+    bool is_scalar() const { return type == SCALAR; }
+    bool is_map() const { return type == MAP; }
+    bool is_sequence() const { return type == SEQUENCE; }
+
+    // 查找映射中的键，不存在或非映射时返回nullptr
+    const YamlNode* get(const std::string& key) const;
+
+    // 读取标量子节点，不存在时返回默认值
+    std::string get_string(const std::string& key, const std::string& default_value = "") const;
+};
+
+class YamlParser {
+public:
+    static bool parse(const std::string& text, YamlNode& root, std::string& error);
+    static bool parse_file(const std::string& path, YamlNode& root, std::string& error);
+};